@@ -0,0 +1,184 @@
+package client
+
+import (
+    "context"
+    "fmt"
+    "net/url"
+)
+
+// API is the Firecracker API as the typed methods of Client send it, so programs can be
+// tested against another implementation.
+type API interface {
+    InstanceInfo(ctx context.Context) (*InstanceInfo, error)
+    Version(ctx context.Context) (string, error)
+    VMConfig(ctx context.Context) (*VMConfig, error)
+    MachineConfig(ctx context.Context) (*MachineConfig, error)
+
+    PutBootSource(ctx context.Context, bootSource BootSource) error
+    PutMachineConfig(ctx context.Context, config MachineConfig) error
+    PutDrive(ctx context.Context, drive Drive) error
+    PatchDrive(ctx context.Context, drive PartialDrive) error
+    PutNetworkInterface(ctx context.Context, iface NetworkInterface) error
+    PutMMDSConfig(ctx context.Context, config MMDSConfig) error
+    PutVsock(ctx context.Context, vsock Vsock) error
+    PutEntropy(ctx context.Context, entropy Entropy) error
+    PutCPUConfig(ctx context.Context, config CPUConfig) error
+
+    MMDS(ctx context.Context) (map[string]interface{}, error)
+    PutMMDS(ctx context.Context, contents interface{}) error
+    PatchMMDS(ctx context.Context, patch interface{}) error
+
+    Action(ctx context.Context, actionType string) error
+    Start(ctx context.Context) error
+    Pause(ctx context.Context) error
+    Resume(ctx context.Context) error
+    CreateSnapshot(ctx context.Context, params SnapshotCreateParams) error
+    LoadSnapshot(ctx context.Context, params SnapshotLoadParams) error
+}
+
+var _ API = (*Client)(nil)
+
+// InstanceInfo returns the state of the VM and the release of the Firecracker process.
+func (c *Client) InstanceInfo(ctx context.Context) (*InstanceInfo, error) {
+    var info InstanceInfo
+    if err := c.Get(ctx, "/", &info); err != nil {
+        return nil, err
+    }
+    return &info, nil
+}
+
+// Version returns the Firecracker release serving the API, such as 1.10.1.
+func (c *Client) Version(ctx context.Context) (string, error) {
+    var version struct {
+        FirecrackerVersion string `json:"firecracker_version"`
+    }
+    if err := c.Get(ctx, "/version", &version); err != nil {
+        return "", err
+    }
+    return version.FirecrackerVersion, nil
+}
+
+// VMConfig returns the full configuration of the VM. Firecracker releases before 1.2 do not
+// report it and answer with an *APIError.
+func (c *Client) VMConfig(ctx context.Context) (*VMConfig, error) {
+    var config VMConfig
+    if err := c.Get(ctx, "/vm/config", &config); err != nil {
+        return nil, err
+    }
+    return &config, nil
+}
+
+// MachineConfig returns the machine configuration, Firecracker's defaults until it is
+// configured.
+func (c *Client) MachineConfig(ctx context.Context) (*MachineConfig, error) {
+    var config MachineConfig
+    if err := c.Get(ctx, "/machine-config", &config); err != nil {
+        return nil, err
+    }
+    return &config, nil
+}
+
+// PutBootSource configures the kernel the VM boots. It must be called before the VM starts.
+func (c *Client) PutBootSource(ctx context.Context, bootSource BootSource) error {
+    return c.Put(ctx, "/boot-source", bootSource)
+}
+
+// PutMachineConfig configures the VM's vCPUs and memory. It must be called before the VM
+// starts.
+func (c *Client) PutMachineConfig(ctx context.Context, config MachineConfig) error {
+    return c.Put(ctx, "/machine-config", config)
+}
+
+// PutDrive adds or replaces a drive. It must be called before the VM starts.
+func (c *Client) PutDrive(ctx context.Context, drive Drive) error {
+    return c.Put(ctx, "/drives/"+url.PathEscape(drive.DriveID), drive)
+}
+
+// PatchDrive changes the image or rate limiter of a drive of the running VM.
+func (c *Client) PatchDrive(ctx context.Context, drive PartialDrive) error {
+    return c.Patch(ctx, "/drives/"+url.PathEscape(drive.DriveID), drive)
+}
+
+// PutNetworkInterface adds or replaces a network interface. It must be called before the VM
+// starts.
+func (c *Client) PutNetworkInterface(ctx context.Context, iface NetworkInterface) error {
+    return c.Put(ctx, "/network-interfaces/"+url.PathEscape(iface.IfaceID), iface)
+}
+
+// PutMMDSConfig configures the metadata service. It must be called before the VM starts.
+func (c *Client) PutMMDSConfig(ctx context.Context, config MMDSConfig) error {
+    return c.Put(ctx, "/mmds/config", config)
+}
+
+// PutVsock configures the vsock device. It must be called before the VM starts.
+func (c *Client) PutVsock(ctx context.Context, vsock Vsock) error {
+    return c.Put(ctx, "/vsock", vsock)
+}
+
+// PutEntropy configures the entropy device. It must be called before the VM starts.
+func (c *Client) PutEntropy(ctx context.Context, entropy Entropy) error {
+    return c.Put(ctx, "/entropy", entropy)
+}
+
+// PutCPUConfig applies a custom CPU template. It must be called before the VM starts.
+func (c *Client) PutCPUConfig(ctx context.Context, config CPUConfig) error {
+    return c.Put(ctx, "/cpu-config", config)
+}
+
+// MMDS returns the contents of the metadata service's data store.
+func (c *Client) MMDS(ctx context.Context) (map[string]interface{}, error) {
+    contents := map[string]interface{}{}
+    if err := c.Get(ctx, "/mmds", &contents); err != nil {
+        return nil, err
+    }
+    return contents, nil
+}
+
+// PutMMDS replaces the contents of the metadata service's data store.
+func (c *Client) PutMMDS(ctx context.Context, contents interface{}) error {
+    return c.Put(ctx, "/mmds", contents)
+}
+
+// PatchMMDS applies a JSON merge patch (RFC 7396) to the contents of the metadata service's
+// data store: objects are merged and null removes a key.
+func (c *Client) PatchMMDS(ctx context.Context, patch interface{}) error {
+    return c.Patch(ctx, "/mmds", patch)
+}
+
+// Action performs an action, such as ActionInstanceStart.
+func (c *Client) Action(ctx context.Context, actionType string) error {
+    return c.Put(ctx, "/actions", map[string]string{"action_type": actionType})
+}
+
+// Start boots the configured VM.
+func (c *Client) Start(ctx context.Context) error {
+    return c.Action(ctx, ActionInstanceStart)
+}
+
+// Pause pauses the running VM.
+func (c *Client) Pause(ctx context.Context) error {
+    return c.setState(ctx, "Paused")
+}
+
+// Resume resumes the paused VM.
+func (c *Client) Resume(ctx context.Context) error {
+    return c.setState(ctx, "Resumed")
+}
+
+func (c *Client) setState(ctx context.Context, state string) error {
+    return c.Patch(ctx, "/vm", map[string]string{"state": state})
+}
+
+// CreateSnapshot writes a snapshot of the paused VM.
+func (c *Client) CreateSnapshot(ctx context.Context, params SnapshotCreateParams) error {
+    if params.SnapshotPath == "" || params.MemFilePath == "" {
+        return fmt.Errorf("snapshot path and memory file path are required")
+    }
+    return c.Put(ctx, "/snapshot/create", params)
+}
+
+// LoadSnapshot restores the VM from a snapshot. It must be called before the VM is
+// configured.
+func (c *Client) LoadSnapshot(ctx context.Context, params SnapshotLoadParams) error {
+    return c.Put(ctx, "/snapshot/load", params)
+}
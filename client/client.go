@@ -0,0 +1,149 @@
+// Package client is a Go client for the Firecracker API, as served by a Firecracker process
+// on its API socket or by a REST proxy in front of it. It is the client the Terraform
+// provider uses, and can be imported by other programs managing Firecracker VMs.
+//
+// A Client sends requests to a single Firecracker API, and so manages a single VM. Typed
+// methods cover the API the provider relies on; Get, Put and Patch send requests for the
+// rest:
+//
+//    c := client.New("http://localhost:8080")
+//    err := c.PutBootSource(ctx, client.BootSource{KernelImagePath: "/images/vmlinux"})
+//
+// To talk to the API socket directly, pass an HTTP client dialing it with WithHTTPClient.
+package client
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "time"
+)
+
+// Doer sends HTTP requests. *http.Client implements it; callers can wrap it to retry
+// requests, limit how many are in flight or serve them without a network.
+type Doer interface {
+    Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc adapts a function to the Doer interface.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req).
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+    return f(req)
+}
+
+// Client sends requests to a Firecracker API. It is safe for concurrent use, although a
+// Firecracker process handles its requests one at a time.
+type Client struct {
+    baseURL    string
+    httpClient Doer
+    headers    http.Header
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends requests with the given HTTP client instead of one with a 30 second
+// timeout.
+func WithHTTPClient(httpClient Doer) Option {
+    return func(c *Client) {
+        c.httpClient = httpClient
+    }
+}
+
+// WithHeaders sends the given headers with every request, such as the credentials of a
+// proxy in front of the API.
+func WithHeaders(headers http.Header) Option {
+    return func(c *Client) {
+        c.headers = headers.Clone()
+    }
+}
+
+// New returns a client for the Firecracker API at baseURL, such as http://localhost:8080.
+func New(baseURL string, opts ...Option) *Client {
+    c := &Client{
+        baseURL:    strings.TrimSuffix(baseURL, "/"),
+        httpClient: &http.Client{Timeout: 30 * time.Second},
+    }
+    for _, opt := range opts {
+        opt(c)
+    }
+    return c
+}
+
+// BaseURL returns the base URL of the API.
+func (c *Client) BaseURL() string {
+    return c.baseURL
+}
+
+// Get sends a GET request for path, such as /machine-config, and decodes the response into
+// out unless out is nil. Error responses are returned as *APIError.
+func (c *Client) Get(ctx context.Context, path string, out interface{}) error {
+    body, err := c.send(ctx, http.MethodGet, path, nil)
+    if err != nil {
+        return err
+    }
+    if out == nil || len(bytes.TrimSpace(body)) == 0 {
+        return nil
+    }
+    if err := json.Unmarshal(body, out); err != nil {
+        return fmt.Errorf("GET %s: failed to parse response: %w", path, err)
+    }
+    return nil
+}
+
+// Put sends a PUT request configuring the component at path with the JSON encoding of
+// payload. Error responses are returned as *APIError.
+func (c *Client) Put(ctx context.Context, path string, payload interface{}) error {
+    _, err := c.send(ctx, http.MethodPut, path, payload)
+    return err
+}
+
+// Patch sends a PATCH request changing the component at path with the JSON encoding of
+// payload. Error responses are returned as *APIError.
+func (c *Client) Patch(ctx context.Context, path string, payload interface{}) error {
+    _, err := c.send(ctx, http.MethodPatch, path, payload)
+    return err
+}
+
+// send sends a request and returns the body of a successful response. Errors sending the
+// request are returned as they are; error responses are wrapped with the request line.
+func (c *Client) send(ctx context.Context, method, path string, payload interface{}) ([]byte, error) {
+    var reqBody io.Reader
+    if payload != nil {
+        data, err := json.Marshal(payload)
+        if err != nil {
+            return nil, fmt.Errorf("failed to marshal payload: %w", err)
+        }
+        reqBody = bytes.NewReader(data)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+    if err != nil {
+        return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+    }
+    if payload != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    req.Header.Set("Accept", "application/json")
+    for name, values := range c.headers {
+        req.Header[name] = values
+    }
+
+    resp, err := c.httpClient.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    body, _ := io.ReadAll(resp.Body)
+    if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+        return nil, fmt.Errorf("%s %s: %w", method, path, ParseAPIError(resp.StatusCode, body))
+    }
+    return body, nil
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/avkcode/terraform-provider-firecracker/firecrackertest"
+)
+
+func TestClient_lifecycle(t *testing.T) {
+	ctx := context.Background()
+	server := firecrackertest.NewServer(t)
+	c := New(server.URL + "/")
+
+	if info, err := c.InstanceInfo(ctx); err != nil || info.State != StateNotStarted {
+		t.Fatalf("Expected a VM that has not started, got %+v, %v", info, err)
+	}
+	for _, step := range []func() error{
+		func() error {
+			return c.PutBootSource(ctx, BootSource{KernelImagePath: "/images/vmlinux", BootArgs: "console=ttyS0"})
+		},
+		func() error { return c.PutMachineConfig(ctx, MachineConfig{VCPUCount: 2, MemSizeMiB: 512}) },
+		func() error {
+			return c.PutDrive(ctx, Drive{DriveID: "rootfs", PathOnHost: "/images/rootfs.ext4", IsRootDevice: true})
+		},
+		func() error {
+			return c.PutNetworkInterface(ctx, NetworkInterface{IfaceID: "eth0", HostDevName: "tap0"})
+		},
+		func() error { return c.PutVsock(ctx, Vsock{GuestCID: 3, UDSPath: "/run/vsock.sock"}) },
+		func() error { return c.PutEntropy(ctx, Entropy{}) },
+		func() error {
+			return c.PutCPUConfig(ctx, CPUConfig{"msr_modifiers": []interface{}{map[string]interface{}{"addr": "0x10a", "bitmap": "0b0"}}})
+		},
+		func() error { return c.PutMMDSConfig(ctx, MMDSConfig{NetworkInterfaces: []string{"eth0"}}) },
+		func() error {
+			return c.PutMMDS(ctx, map[string]interface{}{"app": map[string]interface{}{"beta": true}})
+		},
+		func() error { return c.Start(ctx) },
+	} {
+		if err := step(); err != nil {
+			t.Fatalf("Failed to configure and start VM: %v", err)
+		}
+	}
+
+	config, err := c.VMConfig(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get VM configuration: %v", err)
+	}
+	if config.MachineConfig.MemSizeMiB != 512 || len(config.Drives) != 1 || config.Drives[0].PathOnHost != "/images/rootfs.ext4" {
+		t.Errorf("Unexpected VM configuration %+v", config)
+	}
+
+	if err := c.PatchDrive(ctx, PartialDrive{DriveID: "rootfs", PathOnHost: "/images/rootfs-v2.ext4"}); err != nil {
+		t.Fatalf("Failed to swap drive: %v", err)
+	}
+	if err := c.PatchMMDS(ctx, map[string]interface{}{"app": map[string]interface{}{"beta": nil}}); err != nil {
+		t.Fatalf("Failed to patch MMDS: %v", err)
+	}
+	if contents, err := c.MMDS(ctx); err != nil || len(contents["app"].(map[string]interface{})) != 0 {
+		t.Errorf("Expected the MMDS key to be removed, got %v, %v", contents, err)
+	}
+
+	dir := t.TempDir()
+	if err := c.Pause(ctx); err != nil {
+		t.Fatalf("Failed to pause VM: %v", err)
+	}
+	err = c.CreateSnapshot(ctx, SnapshotCreateParams{SnapshotType: SnapshotTypeFull, SnapshotPath: filepath.Join(dir, "vmstate"), MemFilePath: filepath.Join(dir, "memory")})
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if err := c.Resume(ctx); err != nil {
+		t.Fatalf("Failed to resume VM: %v", err)
+	}
+
+	restored := New(firecrackertest.NewServer(t).URL)
+	err = restored.LoadSnapshot(ctx, SnapshotLoadParams{
+		SnapshotPath: filepath.Join(dir, "vmstate"),
+		MemBackend:   &MemoryBackend{BackendType: "File", BackendPath: filepath.Join(dir, "memory")},
+		ResumeVM:     true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if info, err := restored.InstanceInfo(ctx); err != nil || info.State != StateRunning {
+		t.Errorf("Expected the restored VM to be running, got %+v, %v", info, err)
+	}
+}
+
+func TestClient_apiError(t *testing.T) {
+	ctx := context.Background()
+	c := New(firecrackertest.NewServer(t).URL)
+
+	err := c.PatchDrive(ctx, PartialDrive{DriveID: "rootfs", PathOnHost: "/images/rootfs.ext4"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.FaultMessage == "" {
+		t.Fatalf("Expected an API error with a fault message, got %v", err)
+	}
+	if !IsStatus(err, http.StatusBadRequest) || IsNotFound(err) {
+		t.Errorf("Expected the error to report status 400, got %v", err)
+	}
+}
+
+func TestClient_options(t *testing.T) {
+	var got *http.Request
+	c := New("http://firecracker.example", WithHeaders(http.Header{"Authorization": {"Bearer token"}}), WithHTTPClient(DoerFunc(func(req *http.Request) (*http.Response, error) {
+		got = req
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+	})))
+
+	if err := c.Action(context.Background(), ActionFlushMetrics); err != nil {
+		t.Fatalf("Failed to send action: %v", err)
+	}
+	if got.URL.String() != "http://firecracker.example/actions" || got.Method != http.MethodPut {
+		t.Errorf("Unexpected request %s %s", got.Method, got.URL)
+	}
+	if got.Header.Get("Authorization") != "Bearer token" || got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers %v", got.Header)
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+)
+
+// APIError is an error response of the Firecracker API.
+type APIError struct {
+    StatusCode int
+    // FaultMessage is the reason Firecracker gives for rejecting the request, if any.
+    FaultMessage string
+    // Body is the response, when it has no fault message.
+    Body string
+}
+
+// ParseAPIError returns the error of a response with the given status and body.
+func ParseAPIError(statusCode int, body []byte) *APIError {
+    var fault struct {
+        FaultMessage string `json:"fault_message"`
+    }
+    if json.Unmarshal(body, &fault) == nil && fault.FaultMessage != "" {
+        return &APIError{StatusCode: statusCode, FaultMessage: fault.FaultMessage}
+    }
+    return &APIError{StatusCode: statusCode, Body: strings.TrimSpace(string(body))}
+}
+
+func (e *APIError) Error() string {
+    status := fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+    switch {
+    case e.FaultMessage != "":
+        return fmt.Sprintf("Firecracker rejected the request (%s): %s", status, e.FaultMessage)
+    case e.Body != "":
+        return fmt.Sprintf("Firecracker API returned %s: %s", status, e.Body)
+    default:
+        return fmt.Sprintf("Firecracker API returned %s", status)
+    }
+}
+
+// IsStatus reports whether err is an error response of the API with the given status.
+func IsStatus(err error, statusCode int) bool {
+    var apiErr *APIError
+    return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
+
+// IsNotFound reports whether err is an error response saying the requested resource does
+// not exist.
+func IsNotFound(err error) bool {
+    return IsStatus(err, http.StatusNotFound)
+}
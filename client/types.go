@@ -0,0 +1,145 @@
+package client
+
+// VM states reported in InstanceInfo.
+const (
+    StateNotStarted = "Not started"
+    StateRunning    = "Running"
+    StatePaused     = "Paused"
+)
+
+// Action types accepted by PUT /actions.
+const (
+    ActionInstanceStart  = "InstanceStart"
+    ActionSendCtrlAltDel = "SendCtrlAltDel"
+    ActionFlushMetrics   = "FlushMetrics"
+)
+
+// Snapshot types accepted by PUT /snapshot/create.
+const (
+    SnapshotTypeFull = "Full"
+    SnapshotTypeDiff = "Diff"
+)
+
+// InstanceInfo describes the Firecracker process and the state of its VM, as reported by
+// GET /.
+type InstanceInfo struct {
+    ID         string `json:"id"`
+    State      string `json:"state"`
+    VMMVersion string `json:"vmm_version"`
+    AppName    string `json:"app_name"`
+}
+
+// BootSource is the kernel the VM boots, configured with PUT /boot-source.
+type BootSource struct {
+    KernelImagePath string `json:"kernel_image_path"`
+    BootArgs        string `json:"boot_args,omitempty"`
+    InitrdPath      string `json:"initrd_path,omitempty"`
+}
+
+// MachineConfig is the VM's vCPUs and memory, configured with PUT /machine-config.
+type MachineConfig struct {
+    VCPUCount       int    `json:"vcpu_count"`
+    MemSizeMiB      int    `json:"mem_size_mib"`
+    SMT             bool   `json:"smt,omitempty"`
+    TrackDirtyPages bool   `json:"track_dirty_pages,omitempty"`
+    HugePages       string `json:"huge_pages,omitempty"`
+}
+
+// TokenBucket limits a rate: Size tokens, of bytes or operations, are refilled every
+// RefillTime milliseconds, with an initial burst of OneTimeBurst tokens.
+type TokenBucket struct {
+    Size         int64 `json:"size"`
+    OneTimeBurst int64 `json:"one_time_burst,omitempty"`
+    RefillTime   int64 `json:"refill_time"`
+}
+
+// RateLimiter limits the bandwidth and operations of a drive or network interface.
+type RateLimiter struct {
+    Bandwidth *TokenBucket `json:"bandwidth,omitempty"`
+    Ops       *TokenBucket `json:"ops,omitempty"`
+}
+
+// Drive is a block device, configured with PUT /drives/{drive_id}.
+type Drive struct {
+    DriveID      string       `json:"drive_id"`
+    PathOnHost   string       `json:"path_on_host"`
+    IsRootDevice bool         `json:"is_root_device"`
+    IsReadOnly   bool         `json:"is_read_only"`
+    PartUUID     string       `json:"partuuid,omitempty"`
+    CacheType    string       `json:"cache_type,omitempty"`
+    IOEngine     string       `json:"io_engine,omitempty"`
+    RateLimiter  *RateLimiter `json:"rate_limiter,omitempty"`
+}
+
+// PartialDrive changes a drive of a running VM with PATCH /drives/{drive_id}. Empty fields
+// are left as they are.
+type PartialDrive struct {
+    DriveID     string       `json:"drive_id"`
+    PathOnHost  string       `json:"path_on_host,omitempty"`
+    RateLimiter *RateLimiter `json:"rate_limiter,omitempty"`
+}
+
+// NetworkInterface is a virtio-net device backed by a TAP device on the host, configured
+// with PUT /network-interfaces/{iface_id}.
+type NetworkInterface struct {
+    IfaceID       string       `json:"iface_id"`
+    HostDevName   string       `json:"host_dev_name"`
+    GuestMAC      string       `json:"guest_mac,omitempty"`
+    RxRateLimiter *RateLimiter `json:"rx_rate_limiter,omitempty"`
+    TxRateLimiter *RateLimiter `json:"tx_rate_limiter,omitempty"`
+}
+
+// MMDSConfig configures the microVM metadata service with PUT /mmds/config.
+type MMDSConfig struct {
+    Version           string   `json:"version,omitempty"`
+    NetworkInterfaces []string `json:"network_interfaces"`
+    IPv4Address       string   `json:"ipv4_address,omitempty"`
+}
+
+// Vsock is the virtio-vsock device, backed by a Unix socket on the host, configured with
+// PUT /vsock.
+type Vsock struct {
+    GuestCID int    `json:"guest_cid"`
+    UDSPath  string `json:"uds_path"`
+}
+
+// Entropy is the virtio-rng device, configured with PUT /entropy.
+type Entropy struct {
+    RateLimiter *RateLimiter `json:"rate_limiter,omitempty"`
+}
+
+// CPUConfig is a custom CPU template, configured with PUT /cpu-config. Its modifier lists,
+// such as cpuid_modifiers, are kept as they are written, since they differ between x86_64
+// and aarch64 hosts.
+type CPUConfig map[string]interface{}
+
+// VMConfig is the full configuration of the VM, as reported by GET /vm/config.
+type VMConfig struct {
+    BootSource        *BootSource        `json:"boot-source,omitempty"`
+    MachineConfig     *MachineConfig     `json:"machine-config,omitempty"`
+    Drives            []Drive            `json:"drives"`
+    NetworkInterfaces []NetworkInterface `json:"network-interfaces"`
+    MMDSConfig        *MMDSConfig        `json:"mmds-config,omitempty"`
+}
+
+// SnapshotCreateParams says where PUT /snapshot/create writes a snapshot of the paused VM.
+type SnapshotCreateParams struct {
+    SnapshotType string `json:"snapshot_type,omitempty"`
+    SnapshotPath string `json:"snapshot_path"`
+    MemFilePath  string `json:"mem_file_path"`
+}
+
+// MemoryBackend is where a snapshot's guest memory is loaded from: a file, or a userfaultfd
+// handler listening on a Unix socket.
+type MemoryBackend struct {
+    BackendType string `json:"backend_type"`
+    BackendPath string `json:"backend_path"`
+}
+
+// SnapshotLoadParams says which snapshot PUT /snapshot/load restores the VM from.
+type SnapshotLoadParams struct {
+    SnapshotPath        string         `json:"snapshot_path"`
+    MemBackend          *MemoryBackend `json:"mem_backend,omitempty"`
+    EnableDiffSnapshots bool           `json:"enable_diff_snapshots,omitempty"`
+    ResumeVM            bool           `json:"resume_vm"`
+}
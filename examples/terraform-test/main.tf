@@ -0,0 +1,55 @@
+terraform {
+  required_providers {
+    firecracker = {
+      source = "avkcode/firecracker"
+      version = "0.1.0"
+    }
+  }
+}
+
+variable "kernel_image_path" {
+  type = string
+}
+
+variable "rootfs_path" {
+  type = string
+}
+
+variable "vcpu_count" {
+  type    = number
+  default = 2
+
+  validation {
+    condition     = var.vcpu_count >= 1 && var.vcpu_count <= 32
+    error_message = "Firecracker supports 1 to 32 vCPUs."
+  }
+}
+
+resource "firecracker_vm" "app" {
+  name              = "app"
+  kernel_image_path = var.kernel_image_path
+  boot_args         = "console=ttyS0 reboot=k panic=1 pci=off"
+  hostname          = "app-1"
+
+  drives {
+    drive_id       = "rootfs"
+    path_on_host   = var.rootfs_path
+    is_root_device = true
+    is_read_only   = true
+  }
+
+  drives {
+    drive_id       = "var"
+    size_mib       = 256
+    is_root_device = false
+  }
+
+  machine_config {
+    vcpu_count   = var.vcpu_count
+    mem_size_mib = 1024
+  }
+}
+
+output "vm_id" {
+  value = firecracker_vm.app.id
+}
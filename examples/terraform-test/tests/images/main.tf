@@ -0,0 +1,22 @@
+terraform {
+  required_providers {
+    firecracker = {
+      source = "avkcode/firecracker"
+      version = "0.1.0"
+    }
+  }
+}
+
+resource "firecracker_test_image" "kernel" {}
+
+resource "firecracker_test_image" "rootfs" {
+  size_mib = 64
+}
+
+output "kernel_path" {
+  value = firecracker_test_image.kernel.path
+}
+
+output "rootfs_path" {
+  value = firecracker_test_image.rootfs.path
+}
@@ -0,0 +1,43 @@
+# Runs against a fake Firecracker API served by the provider, so no hypervisor is needed.
+provider "firecracker" {
+  base_url  = "fake://test"
+  state_dir = "./.terraform/firecracker-test"
+}
+
+# Placeholder kernel and rootfs images, deleted when the test finishes
+run "images" {
+  module {
+    source = "./tests/images"
+  }
+}
+
+run "creates_vm" {
+  variables {
+    kernel_image_path = run.images.kernel_path
+    rootfs_path       = run.images.rootfs_path
+  }
+
+  assert {
+    condition     = firecracker_vm.app.machine_config[0].vcpu_count == 2
+    error_message = "Expected 2 vCPUs"
+  }
+
+  assert {
+    condition     = length(firecracker_vm.app.drives) == 2
+    error_message = "Expected the rootfs and the scratch drive"
+  }
+}
+
+run "rejects_invalid_vcpu_count" {
+  command = plan
+
+  variables {
+    kernel_image_path = run.images.kernel_path
+    rootfs_path       = run.images.rootfs_path
+    vcpu_count        = 64
+  }
+
+  expect_failures = [
+    var.vcpu_count,
+  ]
+}
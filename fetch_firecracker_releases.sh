@@ -0,0 +1,35 @@
+#!/bin/bash
+
+# Script to download the Firecracker releases used by the contract tests
+
+# Releases the client is tested against, keep in sync with contractVersions in firecracker/contract_test.go
+FIRECRACKER_VERSIONS="1.4.1 1.5.1 1.6.0 1.7.0 1.8.0 1.9.1 1.10.1"
+
+ARCH=$(uname -m)
+DEST_DIR="${1:-$(pwd)/.firecracker-releases}"
+
+mkdir -p "$DEST_DIR"
+
+for VERSION in $FIRECRACKER_VERSIONS; do
+    BINARY="$DEST_DIR/firecracker-v${VERSION}"
+    if [ -x "$BINARY" ]; then
+        echo "Firecracker ${VERSION} already downloaded."
+        continue
+    fi
+
+    echo "Downloading Firecracker ${VERSION}..."
+    TMP_DIR=$(mktemp -d)
+    curl -sSfL -o "$TMP_DIR/release.tgz" \
+        "https://github.com/firecracker-microvm/firecracker/releases/download/v${VERSION}/firecracker-v${VERSION}-${ARCH}.tgz" || {
+        echo "❌ Failed to download Firecracker ${VERSION}"
+        rm -rf "$TMP_DIR"
+        exit 1
+    }
+
+    tar -xzf "$TMP_DIR/release.tgz" -C "$TMP_DIR"
+    mv "$TMP_DIR/release-v${VERSION}-${ARCH}/firecracker-v${VERSION}-${ARCH}" "$BINARY"
+    chmod +x "$BINARY"
+    rm -rf "$TMP_DIR"
+done
+
+echo "✅ Firecracker releases are in $DEST_DIR"
@@ -0,0 +1,84 @@
+package firecracker
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "fmt"
+    "net/http"
+    "os"
+    "strings"
+)
+
+// tlsConfigFromConfig builds the TLS settings for connecting to a Firecracker API behind an
+// HTTPS proxy from the provider's tls block. It returns nil when the block is not set, so the
+// system's defaults apply.
+func tlsConfigFromConfig(raw []interface{}) (*tls.Config, error) {
+    if len(raw) == 0 || raw[0] == nil {
+        return nil, nil
+    }
+    block := raw[0].(map[string]interface{})
+
+    config := &tls.Config{
+        MinVersion:         tls.VersionTLS12,
+        InsecureSkipVerify: block["insecure_skip_verify"].(bool),
+    }
+
+    if caFile := block["ca_file"].(string); caFile != "" {
+        pem, err := os.ReadFile(caFile)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read tls.ca_file: %w", err)
+        }
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(pem) {
+            return nil, fmt.Errorf("tls.ca_file %s contains no PEM encoded certificates", caFile)
+        }
+        config.RootCAs = pool
+    }
+
+    certFile := block["cert_file"].(string)
+    keyFile := block["key_file"].(string)
+    if (certFile == "") != (keyFile == "") {
+        return nil, fmt.Errorf("tls.cert_file and tls.key_file must be set together")
+    }
+    if certFile != "" {
+        cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+        if err != nil {
+            return nil, fmt.Errorf("failed to load the client certificate: %w", err)
+        }
+        config.Certificates = []tls.Certificate{cert}
+    }
+
+    return config, nil
+}
+
+// requestHeadersFromConfig returns the headers sent with every request to the Firecracker
+// APIs, from the provider's headers and bearer_token arguments.
+func requestHeadersFromConfig(headers map[string]interface{}, bearerToken string) (http.Header, error) {
+    result := http.Header{}
+    for name, value := range headers {
+        if strings.TrimSpace(name) == "" {
+            return nil, fmt.Errorf("headers must not contain an empty header name")
+        }
+        result.Set(name, value.(string))
+    }
+    if bearerToken != "" {
+        if result.Get("Authorization") != "" {
+            return nil, fmt.Errorf("bearer_token conflicts with the Authorization header in headers")
+        }
+        result.Set("Authorization", "Bearer "+bearerToken)
+    }
+    if len(result) == 0 {
+        return nil, nil
+    }
+    return result, nil
+}
+
+// setRequestHeaders adds the provider's headers to a request, keeping the headers it already
+// carries.
+func (c *FirecrackerClient) setRequestHeaders(req *http.Request) {
+    for name, values := range c.headers {
+        if req.Header.Get(name) == "" {
+            req.Header[name] = values
+        }
+    }
+}
@@ -0,0 +1,89 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRequestHeadersFromConfig(t *testing.T) {
+	headers, err := requestHeadersFromConfig(map[string]interface{}{"x-proxy-key": "secret"}, "token")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if headers.Get("X-Proxy-Key") != "secret" || headers.Get("Authorization") != "Bearer token" {
+		t.Errorf("Unexpected headers %v", headers)
+	}
+
+	if _, err := requestHeadersFromConfig(map[string]interface{}{"Authorization": "Basic abc"}, "token"); err == nil {
+		t.Error("Expected bearer_token to conflict with an Authorization header")
+	}
+
+	headers, err = requestHeadersFromConfig(map[string]interface{}{}, "")
+	if err != nil || headers != nil {
+		t.Errorf("Expected no headers, got %v, %v", headers, err)
+	}
+}
+
+func TestTLSConfigFromConfig(t *testing.T) {
+	if config, err := tlsConfigFromConfig(nil); err != nil || config != nil {
+		t.Errorf("Expected no TLS settings without a tls block, got %v, %v", config, err)
+	}
+
+	block := map[string]interface{}{"ca_file": "", "cert_file": "client.pem", "key_file": "", "insecure_skip_verify": false}
+	if _, err := tlsConfigFromConfig([]interface{}{block}); err == nil {
+		t.Error("Expected cert_file without key_file to be rejected")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	block = map[string]interface{}{"ca_file": caFile, "cert_file": "", "key_file": "", "insecure_skip_verify": false}
+	if _, err := tlsConfigFromConfig([]interface{}{block}); err == nil {
+		t.Error("Expected a CA file without certificates to be rejected")
+	}
+}
+
+func TestFirecrackerClient_proxiedAPI(t *testing.T) {
+	var authorization, proxyKey string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		proxyKey = r.Header.Get("X-Proxy-Key")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConfig, err := tlsConfigFromConfig([]interface{}{map[string]interface{}{
+		"ca_file": caFile, "cert_file": "", "key_file": "", "insecure_skip_verify": false,
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	headers, err := requestHeadersFromConfig(map[string]interface{}{"X-Proxy-Key": "secret"}, "token")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	client := &FirecrackerClient{
+		BaseURL:    server.URL,
+		HTTPClient: &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+		headers:    headers,
+	}
+	if err := client.sendComponent(context.Background(), http.MethodPut, server.URL+"/machine-config", map[string]interface{}{"vcpu_count": 1}); err != nil {
+		t.Fatalf("Expected the request to succeed over TLS, got %v", err)
+	}
+	if authorization != "Bearer token" || proxyKey != "secret" {
+		t.Errorf("Expected the configured headers, got Authorization %q and X-Proxy-Key %q", authorization, proxyKey)
+	}
+}
@@ -0,0 +1,101 @@
+package firecracker
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+
+    sdk "github.com/avkcode/terraform-provider-firecracker/client"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// apiError is an error response of the Firecracker API.
+type apiError = sdk.APIError
+
+// newAPIError returns the error of a response with the given status and body.
+func newAPIError(statusCode int, body []byte) *apiError {
+    return sdk.ParseAPIError(statusCode, body)
+}
+
+// faultHint explains a common fault Firecracker reports, and how to fix it.
+type faultHint struct {
+    // Pattern matches the fault messages of the fault.
+    Pattern *regexp.Regexp
+    // Problem names the fault in the diagnostic's summary.
+    Problem string
+    // Remediation says what to check or change.
+    Remediation string
+}
+
+// faultHints lists the faults with a known cause, most specific first.
+var faultHints = []faultHint{
+    {
+        Pattern:     regexp.MustCompile(`(?i)kernel.*(cannot be opened|no such file|invalid.*path)`),
+        Problem:     "kernel image not found",
+        Remediation: "Check that kernel_image_path names an uncompressed kernel (vmlinux) that exists on the host running Firecracker and is readable by the Firecracker process. For jailed VMs with stage_files = false, the path is relative to the jailer chroot.",
+    },
+    {
+        Pattern:     regexp.MustCompile(`(?i)initrd.*(cannot be opened|no such file|invalid.*path)`),
+        Problem:     "initrd not found",
+        Remediation: "Check that initrd_path exists on the host running Firecracker and is readable by the Firecracker process.",
+    },
+    {
+        Pattern:     regexp.MustCompile(`(?i)(block device|drive).*(no such file|invalid permission/path|cannot open|permission denied)`),
+        Problem:     "drive image cannot be opened",
+        Remediation: "Check that the path_on_host of every drive exists on the host running Firecracker, and that the Firecracker process can read it, and write it unless is_read_only is set.",
+    },
+    {
+        Pattern:     regexp.MustCompile(`(?i)tap.*(no such device|not found)|no such device.*tap`),
+        Problem:     "TAP device missing",
+        Remediation: "Create the TAP device named by host_dev_name before the VM, for example with a firecracker_tap resource the VM depends on, on the host running Firecracker.",
+    },
+    {
+        Pattern:     regexp.MustCompile(`(?i)tap.*(resource busy|device or resource busy)`),
+        Problem:     "TAP device in use",
+        Remediation: "The TAP device named by host_dev_name is attached to another process, such as another VM. Give each network interface its own TAP device.",
+    },
+    {
+        Pattern:     regexp.MustCompile(`(?i)(kvm.*permission denied|permission denied.*kvm)`),
+        Problem:     "no access to KVM",
+        Remediation: "The Firecracker process cannot open /dev/kvm. Run it as a user in the kvm group, or grant its user access with setfacl -m u:<user>:rw /dev/kvm. The firecracker_host data source reports whether KVM is accessible.",
+    },
+    {
+        Pattern:     regexp.MustCompile(`(?i)memory size.*invalid`),
+        Problem:     "invalid memory size",
+        Remediation: "Check machine_config.mem_size_mib. Firecracker rejects sizes the host cannot back, and the huge pages setting needs a multiple of the huge page size.",
+    },
+    {
+        Pattern:     regexp.MustCompile(`(?i)vcpu.*invalid`),
+        Problem:     "invalid vCPU count",
+        Remediation: "Check machine_config.vcpu_count. Firecracker supports 1 or an even number of vCPUs up to 32 when SMT is enabled.",
+    },
+    {
+        Pattern:     regexp.MustCompile(`(?i)mmds.*not (initiali[sz]ed|configured)`),
+        Problem:     "MMDS not enabled",
+        Remediation: "MMDS is only enabled for VMs created with an mmds block or with guest settings to publish. Add an mmds block to the firecracker_vm, which replaces the VM.",
+    },
+    {
+        Pattern:     regexp.MustCompile(`(?i)not supported after starting`),
+        Problem:     "VM already started",
+        Remediation: "The Firecracker process serving the API already runs a VM, which cannot be configured again. Give each VM its own Firecracker process and API socket, or start the Firecracker process again.",
+    },
+}
+
+// apiErrorDiagnostics returns the diagnostics of an operation that failed with err. When
+// Firecracker rejected a request for a known reason, the summary names the problem and the
+// detail says how to fix it.
+func apiErrorDiagnostics(summary string, err error) diag.Diagnostics {
+    var apiErr *apiError
+    if errors.As(err, &apiErr) && apiErr.FaultMessage != "" {
+        for _, hint := range faultHints {
+            if hint.Pattern.MatchString(apiErr.FaultMessage) {
+                return diag.Diagnostics{{
+                    Severity: diag.Error,
+                    Summary:  fmt.Sprintf("%s: %s", summary, hint.Problem),
+                    Detail:   fmt.Sprintf("%s\n\n%s", err, hint.Remediation),
+                }}
+            }
+        }
+    }
+    return diag.FromErr(fmt.Errorf("%s: %w", summary, err))
+}
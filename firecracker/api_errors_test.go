@@ -0,0 +1,53 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestApiErrorDiagnostics(t *testing.T) {
+	cases := []struct {
+		fault   string
+		problem string
+	}{
+		{"Cannot open the kernel file: No such file or directory (os error 2)", "kernel image not found"},
+		{"Cannot open the initrd file: No such file or directory (os error 2)", "initrd not found"},
+		{"Unable to create the block device: Invalid permission/path (PermissionDenied)", "drive image cannot be opened"},
+		{"Cannot create network device: Open tap device failed: Error while creating ifreq structure: No such device (os error 19)", "TAP device missing"},
+		{"Cannot create network device: Open tap device failed: Device or resource busy (os error 16)", "TAP device in use"},
+		{"Cannot open /dev/kvm: Permission denied (os error 13)", "no access to KVM"},
+		{"The memory size (MiB) is invalid.", "invalid memory size"},
+		{"The vCPU number is invalid!", "invalid vCPU count"},
+		{"The requested operation is not supported after starting the microVM.", "VM already started"},
+	}
+
+	for _, c := range cases {
+		err := fmt.Errorf("PUT /boot-source: %w", newAPIError(http.StatusBadRequest, []byte(fmt.Sprintf(`{"fault_message":%q}`, c.fault))))
+		diags := apiErrorDiagnostics("failed to create VM", err)
+		if len(diags) != 1 {
+			t.Fatalf("Expected one diagnostic for %q, got %v", c.fault, diags)
+		}
+		if expected := "failed to create VM: " + c.problem; diags[0].Summary != expected {
+			t.Errorf("Expected summary %q for %q, got %q", expected, c.fault, diags[0].Summary)
+		}
+		if !strings.Contains(diags[0].Detail, c.fault) {
+			t.Errorf("Expected the detail to carry the fault message %q, got %q", c.fault, diags[0].Detail)
+		}
+	}
+}
+
+func TestApiErrorDiagnostics_unknownFault(t *testing.T) {
+	err := newAPIError(http.StatusBadRequest, []byte(`{"fault_message":"Something unexpected."}`))
+	diags := apiErrorDiagnostics("failed to update VM", err)
+	if expected := "failed to update VM: " + err.Error(); len(diags) != 1 || diags[0].Summary != expected {
+		t.Errorf("Expected summary %q, got %v", expected, diags)
+	}
+
+	diags = apiErrorDiagnostics("failed to update VM", errors.New("connection refused"))
+	if len(diags) != 1 || diags[0].Summary != "failed to update VM: connection refused" {
+		t.Errorf("Expected the error as the summary, got %v", diags)
+	}
+}
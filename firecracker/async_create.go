@@ -0,0 +1,366 @@
+package firecracker
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    asyncCreateProvisioning = "provisioning"
+    asyncCreateReady        = "ready"
+    asyncCreateFailed       = "failed"
+)
+
+// defaultAsyncWorkers is how many async VMs the provider provisions at a time by default.
+const defaultAsyncWorkers = 4
+
+// asyncCreateMu serializes access to the records of async VMs within the provider process.
+var asyncCreateMu sync.Mutex
+
+// asyncCreateRecord is the record of the provisioning of an async VM, kept in the state
+// directory from its creation until a refresh finds it provisioned, so refreshes, other
+// runs of the provider and the firecracker_vm_status data source can tell how it went.
+type asyncCreateRecord struct {
+    ID       string    `json:"id"`
+    Status   string    `json:"status"`
+    PID      int       `json:"pid"`
+    Started  time.Time `json:"started"`
+    Finished time.Time `json:"finished,omitempty"`
+    Error    string    `json:"error,omitempty"`
+
+    // Attributes holds the computed attributes provisioning set, which the next refresh
+    // copies to the VM's state.
+    Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// asyncProvisioningKey marks the context of a worker provisioning an async VM.
+type asyncProvisioningKey struct{}
+
+// asyncCreatePath returns the file recording the provisioning of an async VM.
+func asyncCreatePath(stateDir, vmID string) string {
+    return filepath.Join(stateDir, "async", vmID+".json")
+}
+
+// writeAsyncCreate records the provisioning of an async VM.
+func writeAsyncCreate(stateDir string, record asyncCreateRecord) error {
+    asyncCreateMu.Lock()
+    defer asyncCreateMu.Unlock()
+
+    path := asyncCreatePath(stateDir, record.ID)
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return fmt.Errorf("failed to create async VM directory: %w", err)
+    }
+    data, err := json.MarshalIndent(record, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode provisioning of VM %s: %w", record.ID, err)
+    }
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0o600); err != nil {
+        return fmt.Errorf("failed to record provisioning of VM %s: %w", record.ID, err)
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        return fmt.Errorf("failed to record provisioning of VM %s: %w", record.ID, err)
+    }
+    return nil
+}
+
+// lookupAsyncCreate returns the record of the provisioning of an async VM, or nil if there
+// is none. Provisioning whose provider process exited before it finished is reported as
+// failed.
+func lookupAsyncCreate(stateDir, vmID string) (*asyncCreateRecord, error) {
+    asyncCreateMu.Lock()
+    defer asyncCreateMu.Unlock()
+
+    data, err := os.ReadFile(asyncCreatePath(stateDir, vmID))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read provisioning of VM %s: %w", vmID, err)
+    }
+    var record asyncCreateRecord
+    if err := json.Unmarshal(data, &record); err != nil {
+        return nil, fmt.Errorf("failed to parse provisioning of VM %s: %w", vmID, err)
+    }
+    if record.Status == asyncCreateProvisioning && !processAlive(record.PID) {
+        record.Status = asyncCreateFailed
+        record.Error = fmt.Sprintf("provisioning was interrupted, provider process %d exited before it finished", record.PID)
+    }
+    return &record, nil
+}
+
+// removeAsyncCreate removes the record of the provisioning of an async VM.
+func removeAsyncCreate(stateDir, vmID string) error {
+    asyncCreateMu.Lock()
+    defer asyncCreateMu.Unlock()
+
+    if err := os.Remove(asyncCreatePath(stateDir, vmID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("failed to remove provisioning of VM %s: %w", vmID, err)
+    }
+    return nil
+}
+
+// asyncCreates is the provider's pool of workers provisioning async VMs.
+type asyncCreates struct {
+    // slots has a buffer of async_workers.
+    slots chan struct{}
+
+    mu      sync.Mutex
+    running map[string]*asyncCreate
+}
+
+// asyncCreate is the provisioning of an async VM in this provider process.
+type asyncCreate struct {
+    cancel context.CancelFunc
+    done   chan struct{}
+}
+
+// newAsyncCreates returns a pool provisioning up to workers VMs at a time.
+func newAsyncCreates(workers int) *asyncCreates {
+    return &asyncCreates{
+        slots:   make(chan struct{}, workers),
+        running: map[string]*asyncCreate{},
+    }
+}
+
+// start provisions a VM with provision once a worker is free. The context is cancelled
+// when the VM is destroyed or the provider process is asked to terminate.
+func (a *asyncCreates) start(ctx context.Context, vmID string, provision func(context.Context)) {
+    ctx, cancel := context.WithCancel(ctx)
+    stop := context.AfterFunc(shutdownCtx, cancel)
+    create := &asyncCreate{cancel: cancel, done: make(chan struct{})}
+
+    a.mu.Lock()
+    a.running[vmID] = create
+    a.mu.Unlock()
+
+    go func() {
+        defer func() {
+            stop()
+            cancel()
+            a.mu.Lock()
+            delete(a.running, vmID)
+            a.mu.Unlock()
+            close(create.done)
+        }()
+
+        select {
+        case a.slots <- struct{}{}:
+        case <-ctx.Done():
+            provision(ctx)
+            return
+        }
+        defer func() { <-a.slots }()
+        provision(ctx)
+    }()
+}
+
+// wait blocks until the VM is provisioned, if this provider process provisions it, or the
+// context is done.
+func (a *asyncCreates) wait(ctx context.Context, vmID string) error {
+    if a == nil {
+        return nil
+    }
+    a.mu.Lock()
+    create := a.running[vmID]
+    a.mu.Unlock()
+    if create == nil {
+        return nil
+    }
+    select {
+    case <-create.done:
+        return nil
+    case <-ctx.Done():
+        return fmt.Errorf("gave up waiting for VM %s to be provisioned: %w", vmID, ctx.Err())
+    }
+}
+
+// stop aborts the provisioning of the VM, if this provider process provisions it, and
+// waits for the worker to clean up.
+func (a *asyncCreates) stop(vmID string) {
+    if a == nil {
+        return
+    }
+    a.mu.Lock()
+    create := a.running[vmID]
+    a.mu.Unlock()
+    if create != nil {
+        create.cancel()
+        <-create.done
+    }
+}
+
+// startAsyncCreate records the creation of an async VM, which resourceFirecrackerVMCreate
+// gave its ID and placed, and leaves configuring and booting it to the provider's workers.
+// The workers provision a copy of the VM's data, since Terraform is done with d once
+// creating returns, and record the outcome for the next refresh.
+func startAsyncCreate(ctx context.Context, d *schema.ResourceData, provider *FirecrackerClient, client *FirecrackerClient) diag.Diagnostics {
+    if provider.asyncCreates == nil {
+        return diag.FromErr(fmt.Errorf("async requires a configured provider"))
+    }
+    vmID := d.Id()
+    record := asyncCreateRecord{
+        ID:      vmID,
+        Status:  asyncCreateProvisioning,
+        PID:     os.Getpid(),
+        Started: time.Now().UTC(),
+    }
+    if err := writeAsyncCreate(provider.StateDir, record); err != nil {
+        return diag.FromErr(err)
+    }
+    recordVMHealth(d, vmHealthProvisioning, "provisioning asynchronously")
+
+    worker := resourceFirecrackerVM().Data(d.State())
+    timeout := d.Timeout(schema.TimeoutCreate)
+    tflog.Info(ctx, "Provisioning Firecracker VM asynchronously", map[string]interface{}{
+        "id":      vmID,
+        "timeout": timeout.String(),
+    })
+
+    // The worker outlives the request creating the VM, keeping only its logger and values
+    ctx = context.WithValue(context.WithoutCancel(ctx), asyncProvisioningKey{}, true)
+    provider.asyncCreates.start(ctx, vmID, func(ctx context.Context) {
+        ctx, cancel := context.WithTimeout(ctx, timeout)
+        defer cancel()
+
+        diags := auditOperation(ctx, provider, "provision", "firecracker_vm", worker, nil, func(ctx context.Context) diag.Diagnostics {
+            if err := ctx.Err(); err != nil {
+                return diag.FromErr(fmt.Errorf("provisioning was aborted before it started: %w", err))
+            }
+            return provisionVM(ctx, worker, provider, client)
+        })
+
+        record.Finished = time.Now().UTC()
+        if diags.HasError() {
+            record.Status = asyncCreateFailed
+            record.Error = asyncCreateError(diags)
+            tflog.Error(ctx, "Failed to provision Firecracker VM asynchronously", map[string]interface{}{
+                "id":    vmID,
+                "error": record.Error,
+            })
+        } else {
+            record.Status = asyncCreateReady
+            record.Attributes = asyncCreateAttributes(worker)
+            tflog.Info(ctx, "Provisioned Firecracker VM asynchronously", map[string]interface{}{
+                "id":          vmID,
+                "duration_ms": record.Finished.Sub(record.Started).Milliseconds(),
+            })
+        }
+        if err := writeAsyncCreate(provider.StateDir, record); err != nil {
+            tflog.Error(ctx, "Failed to record provisioning of Firecracker VM", map[string]interface{}{
+                "id":    vmID,
+                "error": err.Error(),
+            })
+        }
+    })
+    return nil
+}
+
+// asyncCreateError describes the errors provisioning an async VM failed with.
+func asyncCreateError(diags diag.Diagnostics) string {
+    var errs []error
+    for _, diagnostic := range diags {
+        if diagnostic.Severity != diag.Error {
+            continue
+        }
+        if diagnostic.Detail != "" {
+            errs = append(errs, fmt.Errorf("%s: %s", diagnostic.Summary, diagnostic.Detail))
+        } else {
+            errs = append(errs, errors.New(diagnostic.Summary))
+        }
+    }
+    return errors.Join(errs...).Error()
+}
+
+// asyncCreateAttributes returns the computed attributes of a provisioned VM, which only
+// provisioning sets, such as its rendered configuration and boot time. Sensitive ones are
+// left to refreshes, so they are not written to the state directory.
+func asyncCreateAttributes(d *schema.ResourceData) map[string]interface{} {
+    attributes := map[string]interface{}{}
+    for key, s := range resourceFirecrackerVM().Schema {
+        if s.Computed && !s.Optional && !s.Sensitive {
+            attributes[key] = d.Get(key)
+        }
+    }
+    return attributes
+}
+
+// refreshAsyncCreate reports the progress of an async VM still being provisioned, or how
+// its provisioning failed, in which case the VM is replaced on the next apply the way
+// recovery_policy replace replaces lost VMs. Once it is provisioned, the attributes
+// provisioning set are copied to d and the refresh goes on, which the returned bool says.
+func refreshAsyncCreate(ctx context.Context, d *schema.ResourceData, provider *FirecrackerClient) (bool, diag.Diagnostics) {
+    if ctx.Value(asyncProvisioningKey{}) != nil {
+        return true, nil
+    }
+    record, err := lookupAsyncCreate(provider.StateDir, d.Id())
+    if err != nil {
+        return false, diag.FromErr(err)
+    }
+    if record == nil {
+        return true, nil
+    }
+
+    switch record.Status {
+    case asyncCreateProvisioning:
+        recordVMHealth(d, vmHealthProvisioning, "provisioning asynchronously")
+        return false, nil
+
+    case asyncCreateFailed:
+        finished := record.Finished
+        if finished.IsZero() {
+            finished = healthNow()
+        }
+        d.Set("last_error", record.Error)
+        d.Set("last_error_time", finished.UTC().Format(time.RFC3339))
+        recordVMHealth(d, vmHealthFailed, record.Error)
+        d.Set("recovery_pending", true)
+        return false, diag.Diagnostics{{
+            Severity: diag.Warning,
+            Summary:  "Firecracker VM provisioning failed",
+            Detail:   fmt.Sprintf("Provisioning VM %s asynchronously failed: %s. It will be replaced on the next apply.", d.Id(), record.Error),
+        }}
+    }
+
+    for key, value := range record.Attributes {
+        if err := d.Set(key, value); err != nil {
+            return false, diag.FromErr(fmt.Errorf("failed to set %s of VM %s from its provisioning: %w", key, d.Id(), err))
+        }
+    }
+    if err := removeAsyncCreate(provider.StateDir, d.Id()); err != nil {
+        return false, diag.FromErr(err)
+    }
+    tflog.Debug(ctx, "Async VM was provisioned", map[string]interface{}{
+        "id": d.Id(),
+    })
+    return true, nil
+}
+
+// waitAsyncCreate waits for the provisioning of an async VM before it is updated, failing
+// when provisioning failed or another provider process is still at it.
+func waitAsyncCreate(ctx context.Context, provider *FirecrackerClient, vmID string) error {
+    if err := provider.asyncCreates.wait(ctx, vmID); err != nil {
+        return err
+    }
+    record, err := lookupAsyncCreate(provider.StateDir, vmID)
+    if err != nil || record == nil {
+        return err
+    }
+    switch record.Status {
+    case asyncCreateFailed:
+        return fmt.Errorf("VM %s failed to provision: %s. Refresh it to plan its replacement", vmID, record.Error)
+    case asyncCreateProvisioning:
+        return fmt.Errorf("VM %s is still being provisioned by provider process %d", vmID, record.PID)
+    }
+    return nil
+}
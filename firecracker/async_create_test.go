@@ -0,0 +1,156 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// asyncVMConfig returns the configuration of an async VM booting image.
+func asyncVMConfig(image string) map[string]interface{} {
+	return map[string]interface{}{
+		"async":             true,
+		"kernel_image_path": image,
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+		},
+	}
+}
+
+// readVMStatus reads the firecracker_vm_status data source for a VM.
+func readVMStatus(t *testing.T, client *FirecrackerClient, vmID string) *schema.ResourceData {
+	t.Helper()
+	d := schema.TestResourceDataRaw(t, dataSourceFirecrackerVMStatus().Schema, map[string]interface{}{
+		"ids":  []interface{}{vmID},
+		"wait": true,
+	})
+	if diags := dataSourceFirecrackerVMStatusRead(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("Failed to read VM status: %v", diags)
+	}
+	return d
+}
+
+func TestAsyncCreate(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := Provider().ResourcesMap["firecracker_vm"]
+	diff, err := r.Diff(ctx, nil, terraform.NewResourceConfigRaw(asyncVMConfig(image)), client)
+	if err != nil {
+		t.Fatalf("Failed to plan: %v", err)
+	}
+	state, diags := r.Apply(ctx, nil, diff, client)
+	if diags.HasError() || state == nil || state.ID == "" {
+		t.Fatalf("Expected the VM to be created, got %v, %v", state, diags)
+	}
+	if status := state.Attributes["health_status"]; status != vmHealthProvisioning {
+		t.Errorf("Expected the VM to be provisioning, got %q", status)
+	}
+
+	// The data source waits for the worker
+	status := readVMStatus(t, client, state.ID)
+	if !status.Get("ready").(bool) || status.Get("statuses").(map[string]interface{})[state.ID] != asyncCreateReady {
+		t.Errorf("Expected the VM to be provisioned, got %v", status.Get("statuses"))
+	}
+
+	// Refreshing picks up what provisioning set, in a later run of the provider
+	client = configureFakeProvider(t, stateDir)
+	state, diags = r.RefreshWithoutUpgrade(ctx, state, client)
+	if diags.HasError() || state == nil {
+		t.Fatalf("Failed to refresh: %v", diags)
+	}
+	if state.Attributes["rendered_config_json"] == "" || state.Attributes["health_status"] != vmHealthHealthy {
+		t.Errorf("Expected the provisioned VM to be refreshed, got %v", state.Attributes)
+	}
+	if record, err := lookupAsyncCreate(client.StateDir, state.ID); err != nil || record != nil {
+		t.Errorf("Expected the provisioning record to be removed, got %+v, %v", record, err)
+	}
+}
+
+func TestAsyncCreate_failed(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := Provider().ResourcesMap["firecracker_vm"]
+	config := asyncVMConfig(image)
+	diff, err := r.Diff(ctx, nil, terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("Failed to plan: %v", err)
+	}
+
+	// The kernel is gone by the time the worker boots the VM
+	if err := os.Remove(image); err != nil {
+		t.Fatal(err)
+	}
+	state, diags := r.Apply(ctx, nil, diff, client)
+	if diags.HasError() {
+		t.Fatalf("Expected creating the VM to succeed, got %v", diags)
+	}
+	status := readVMStatus(t, client, state.ID)
+	if status.Get("ready").(bool) || !strings.Contains(status.Get("errors").(map[string]interface{})[state.ID].(string), "kernel image file does not exist") {
+		t.Errorf("Expected provisioning to fail, got %v, %v", status.Get("statuses"), status.Get("errors"))
+	}
+
+	client = configureFakeProvider(t, stateDir)
+	state, diags = r.RefreshWithoutUpgrade(ctx, state, client)
+	if len(diags) != 1 || diags[0].Summary != "Firecracker VM provisioning failed" {
+		t.Errorf("Expected a warning about the failed provisioning, got %v", diags)
+	}
+	if state.Attributes["health_status"] != vmHealthFailed || state.Attributes["recovery_pending"] != "true" {
+		t.Errorf("Expected the VM to be failed and pending replacement, got %v", state.Attributes)
+	}
+
+	// The next plan replaces the VM
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	diff, err = r.Diff(ctx, state, terraform.NewResourceConfigRaw(config), nil)
+	if err != nil {
+		t.Fatalf("Failed to plan: %v", err)
+	}
+	if diff == nil || !diff.RequiresNew() {
+		t.Errorf("Expected the failed VM to be replaced, got %v", diff)
+	}
+
+	// Destroying it removes the provisioning record
+	if _, diags := r.Apply(ctx, state, &terraform.InstanceDiff{Destroy: true}, client); diags.HasError() {
+		t.Fatalf("Failed to destroy: %v", diags)
+	}
+	if record, err := lookupAsyncCreate(client.StateDir, state.ID); err != nil || record != nil {
+		t.Errorf("Expected the provisioning record to be removed, got %+v, %v", record, err)
+	}
+}
+
+func TestLookupAsyncCreate_interrupted(t *testing.T) {
+	stateDir := t.TempDir()
+	record := asyncCreateRecord{ID: "vm-1", Status: asyncCreateProvisioning, PID: 1 << 30, Started: time.Now()}
+	if err := writeAsyncCreate(stateDir, record); err != nil {
+		t.Fatal(err)
+	}
+	found, err := lookupAsyncCreate(stateDir, "vm-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Status != asyncCreateFailed || !strings.Contains(found.Error, "interrupted") {
+		t.Errorf("Expected provisioning by an exited provider to have failed, got %+v", found)
+	}
+}
@@ -0,0 +1,233 @@
+package firecracker
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "os/user"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// auditLog appends a record of every change the provider makes to a file kept across runs,
+// for change tracking. The file is only ever appended to.
+type auditLog struct {
+    path string
+
+    mu   sync.Mutex
+    file *os.File
+}
+
+// auditEntry is a create, update or destroy of a resource, or a refresh that changed a
+// Firecracker API, as recorded in the audit log.
+type auditEntry struct {
+    Time       string         `json:"time"`
+    DurationMS int64          `json:"duration_ms"`
+    User       string         `json:"user"`
+    UID        string         `json:"uid"`
+    Hostname   string         `json:"hostname"`
+    PID        int            `json:"pid"`
+    Operation  string         `json:"operation"`
+    Resource   string         `json:"resource"`
+    ID         string         `json:"id,omitempty"`
+    Changes    []string       `json:"changes,omitempty"`
+    Requests   []auditRequest `json:"requests,omitempty"`
+    Error      string         `json:"error,omitempty"`
+}
+
+// auditRequest is a request changing a Firecracker API, sent during an audited operation.
+type auditRequest struct {
+    Method  string `json:"method"`
+    URL     string `json:"url"`
+    Payload string `json:"payload,omitempty"`
+    Status  int    `json:"status,omitempty"`
+    Error   string `json:"error,omitempty"`
+}
+
+// openAuditLog opens the audit log at path for appending, creating it if needed, so a path
+// the provider cannot write to fails the configuration rather than the first change.
+func openAuditLog(path string) (*auditLog, error) {
+    if path == "" {
+        return nil, nil
+    }
+    file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open audit log: %w", err)
+    }
+    return &auditLog{path: path, file: file}, nil
+}
+
+// write appends an entry to the audit log as a line of JSON.
+func (a *auditLog) write(entry auditEntry) error {
+    line, err := json.Marshal(entry)
+    if err != nil {
+        return err
+    }
+
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    _, err = a.file.Write(append(line, '\n'))
+    return err
+}
+
+// auditRecorderKey is the context key of the recorder of the operation being audited.
+type auditRecorderKey struct{}
+
+// auditRecorder collects the requests changing a Firecracker API sent during an operation,
+// whichever host they are sent to.
+type auditRecorder struct {
+    mu       sync.Mutex
+    requests []auditRequest
+}
+
+// auditRecorderFrom returns the recorder of the operation being audited in ctx, if any.
+func auditRecorderFrom(ctx context.Context) *auditRecorder {
+    recorder, _ := ctx.Value(auditRecorderKey{}).(*auditRecorder)
+    return recorder
+}
+
+// record sends a request with send and records it, with its payload redacted as HTTP dumps
+// redact it.
+func (r *auditRecorder) record(c *FirecrackerClient, req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+    var payload []byte
+    if req.Body != nil && req.Body != http.NoBody {
+        body, err := io.ReadAll(req.Body)
+        req.Body.Close()
+        if err != nil {
+            return nil, fmt.Errorf("failed to read request payload: %w", err)
+        }
+        req.Body = io.NopCloser(bytes.NewReader(body))
+        payload = body
+    }
+
+    resp, err := send(req)
+    request := auditRequest{
+        Method:  req.Method,
+        URL:     req.URL.String(),
+        Payload: redactedRequestBody(c, req, payload),
+    }
+    if err != nil {
+        request.Error = err.Error()
+    } else {
+        request.Status = resp.StatusCode
+    }
+
+    r.mu.Lock()
+    r.requests = append(r.requests, request)
+    r.mu.Unlock()
+    return resp, err
+}
+
+// auditIdentity returns who runs the provider, for audit entries.
+func auditIdentity() (name, uid, hostname string) {
+    if current, err := user.Current(); err == nil {
+        name, uid = current.Username, current.Uid
+    } else {
+        uid = fmt.Sprint(os.Getuid())
+    }
+    hostname, _ = os.Hostname()
+    return name, uid, hostname
+}
+
+// auditResource records every create, update and destroy of instances of the resource in
+// the provider's audit log, along with the requests they sent to the Firecracker APIs.
+// Refreshes are recorded when they change an API, such as when relaunching a VM.
+func auditResource(name string, r *schema.Resource) {
+    wrap := func(op string, f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+        if f == nil {
+            return nil
+        }
+        return func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+            client, ok := m.(*FirecrackerClient)
+            if !ok || client == nil || client.auditLog == nil {
+                return f(ctx, d, m)
+            }
+
+            // An update's changes are gone once it is applied
+            var changes []string
+            if op == "update" {
+                for key := range r.Schema {
+                    if d.HasChange(key) {
+                        changes = append(changes, key)
+                    }
+                }
+                sort.Strings(changes)
+            }
+            return auditOperation(ctx, client, op, name, d, changes, func(ctx context.Context) diag.Diagnostics {
+                return f(ctx, d, m)
+            })
+        }
+    }
+
+    r.CreateContext = wrap("create", r.CreateContext)
+    r.ReadContext = wrap("refresh", r.ReadContext)
+    r.UpdateContext = wrap("update", r.UpdateContext)
+    r.DeleteContext = wrap("destroy", r.DeleteContext)
+}
+
+// auditOperation runs op on the resource d is an instance of with f, and records it in the
+// provider's audit log, if it keeps one, along with the requests f sent to the Firecracker
+// APIs. Refreshes sending none are not recorded.
+func auditOperation(ctx context.Context, client *FirecrackerClient, op, name string, d *schema.ResourceData, changes []string, f func(context.Context) diag.Diagnostics) diag.Diagnostics {
+    if client.auditLog == nil {
+        return f(ctx)
+    }
+
+    // Destroying clears the ID
+    id := d.Id()
+    recorder := &auditRecorder{}
+    start := time.Now()
+    diags := f(context.WithValue(ctx, auditRecorderKey{}, recorder))
+    recorder.mu.Lock()
+    requests := recorder.requests
+    recorder.mu.Unlock()
+    if op == "refresh" && len(requests) == 0 {
+        return diags
+    }
+
+    entry := auditEntry{
+        Time:       start.UTC().Format(time.RFC3339Nano),
+        DurationMS: time.Since(start).Milliseconds(),
+        PID:        os.Getpid(),
+        Operation:  op,
+        Resource:   name,
+        ID:         id,
+        Changes:    changes,
+        Requests:   requests,
+    }
+    entry.User, entry.UID, entry.Hostname = auditIdentity()
+    if entry.ID == "" {
+        entry.ID = d.Id()
+    }
+    for _, diagnostic := range diags {
+        if diagnostic.Severity == diag.Error {
+            entry.Error = diagnostic.Summary
+            if diagnostic.Detail != "" {
+                entry.Error += ": " + diagnostic.Detail
+            }
+            break
+        }
+    }
+
+    if err := client.auditLog.write(entry); err != nil {
+        tflog.Error(ctx, "Failed to write audit log", map[string]interface{}{
+            "path":  client.auditLog.path,
+            "error": err.Error(),
+        })
+        diags = append(diags, diag.Diagnostic{
+            Severity: diag.Warning,
+            Summary:  "Failed to write audit log",
+            Detail:   fmt.Sprintf("The %s of %s %s was not recorded in %s: %s", op, name, entry.ID, client.auditLog.path, err),
+        })
+    }
+    return diags
+}
@@ -0,0 +1,114 @@
+package firecracker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := openAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.auditLog = auditLog
+
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := map[string]interface{}{
+		"kernel_image_path":   image,
+		"boot_args":           "console=ttyS0",
+		"sensitive_boot_args": "password=hunter2",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+		},
+	}
+	r := Provider().ResourcesMap["firecracker_vm"]
+	apply := func(state *terraform.InstanceState, config map[string]interface{}) *terraform.InstanceState {
+		t.Helper()
+		diff, err := r.Diff(ctx, state, terraform.NewResourceConfigRaw(config), client)
+		if err != nil {
+			t.Fatalf("Failed to plan: %v", err)
+		}
+		state, diags := r.Apply(ctx, state, diff, client)
+		if diags.HasError() {
+			t.Fatalf("Failed to apply: %v", diags)
+		}
+		return state
+	}
+
+	state := apply(nil, config)
+
+	// Later runs of the provider append to the same log
+	client = configureFakeProvider(t, stateDir)
+	client.auditLog = auditLog
+	if _, diags := r.RefreshWithoutUpgrade(ctx, state, client); diags.HasError() {
+		t.Fatalf("Failed to refresh: %v", diags)
+	}
+	config["tags"] = map[string]interface{}{"role": "web"}
+	state = apply(state, config)
+	if _, diags := r.Apply(ctx, state, &terraform.InstanceDiff{Destroy: true}, client); diags.HasError() {
+		t.Fatalf("Failed to destroy: %v", diags)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []auditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), "hunter2") {
+			t.Errorf("Expected sensitive kernel parameters to be redacted, got %s", scanner.Text())
+		}
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Failed to parse audit entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	// Refreshing changes nothing, so only the changes are recorded
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 audit entries, got %d: %+v", len(entries), entries)
+	}
+	for i, op := range []string{"create", "update", "destroy"} {
+		entry := entries[i]
+		if entry.Operation != op || entry.Resource != "firecracker_vm" || entry.ID != state.ID || entry.UID == "" || entry.Error != "" {
+			t.Errorf("Unexpected %s entry: %+v", op, entry)
+		}
+	}
+	create := entries[0]
+	var bootSource bool
+	for _, request := range create.Requests {
+		if request.Method == "GET" {
+			t.Errorf("Expected only changes to be recorded, got %s %s", request.Method, request.URL)
+		}
+		if strings.HasSuffix(request.URL, "/boot-source") {
+			bootSource = strings.Contains(request.Payload, "console=ttyS0") && strings.Contains(request.Payload, redactedValue)
+		}
+	}
+	if !bootSource {
+		t.Errorf("Expected the redacted boot source payload to be recorded, got %+v", create.Requests)
+	}
+	if changes := strings.Join(entries[1].Changes, ","); !strings.Contains(changes, "tags") {
+		t.Errorf("Expected the update to record its changed tags, got %v", changes)
+	}
+}
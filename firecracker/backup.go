@@ -0,0 +1,288 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "syscall"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    backupMethodAuto    = "auto"
+    backupMethodReflink = "reflink"
+    backupMethodPause   = "pause"
+)
+
+// backupNow returns the current time, replaced in tests.
+var backupNow = time.Now
+
+// driveBackupSpec describes a copy of a VM's drive image to take.
+type driveBackupSpec struct {
+    VMID        string
+    DrivePath   string
+    Destination string
+    Method      string
+    StateDir    string
+}
+
+// driveBackup describes a backup that was taken.
+type driveBackup struct {
+    Location string
+    Method   string
+    SHA256   string
+    Size     int64
+    Created  time.Time
+}
+
+// isS3Destination reports whether a backup destination is an S3 URL rather than a directory.
+func isS3Destination(destination string) bool {
+    return strings.HasPrefix(destination, "s3://")
+}
+
+// backupName returns the file name of a backup, unique per VM, drive and time.
+func (s driveBackupSpec) backupName(now time.Time) string {
+    return fmt.Sprintf("%s-%s-%s", s.VMID, now.UTC().Format("20060102T150405Z"), filepath.Base(s.DrivePath))
+}
+
+// location returns where a backup with the given name is stored.
+func (s driveBackupSpec) location(name string) string {
+    if isS3Destination(s.Destination) {
+        return strings.TrimSuffix(s.Destination, "/") + "/" + name
+    }
+    return filepath.Join(s.Destination, name)
+}
+
+// backupDrive takes a consistent copy of the drive and stores it at the destination. With
+// the reflink method, the image is copied sharing its extents, which is instantaneous and
+// atomic with respect to the guest's writes, so the VM keeps running. Otherwise the VM is
+// paused while the image is copied, so the guest cannot write to it halfway through. The
+// auto method tries reflink first. Either way the copy is crash-consistent: writes the guest
+// has not flushed yet are not part of it.
+func backupDrive(ctx context.Context, client *FirecrackerClient, spec driveBackupSpec) (*driveBackup, error) {
+    now := backupNow().UTC()
+    name := spec.backupName(now)
+    backup := &driveBackup{Location: spec.location(name), Created: now}
+
+    // The copy is staged locally first and only moved or uploaded to the destination once complete
+    stagingDir := spec.Destination
+    if isS3Destination(spec.Destination) {
+        stagingDir = filepath.Join(spec.StateDir, "backups")
+    }
+    if err := os.MkdirAll(stagingDir, 0o755); err != nil {
+        return nil, fmt.Errorf("failed to create backup directory: %w", err)
+    }
+
+    var staged string
+    if spec.Method == backupMethodReflink || spec.Method == backupMethodAuto {
+        // Extents can only be shared within a filesystem, so the clone is made next to the image
+        staged = filepath.Join(filepath.Dir(spec.DrivePath), "."+name+".tmp")
+        err := reflinkDriveImage(ctx, spec.DrivePath, staged)
+        if err == nil {
+            backup.Method = backupMethodReflink
+        } else if spec.Method == backupMethodReflink {
+            return nil, err
+        }
+    }
+    if backup.Method == "" {
+        staged = filepath.Join(stagingDir, "."+name+".tmp")
+        if err := copyPausedDriveImage(ctx, client, spec.DrivePath, staged); err != nil {
+            return nil, err
+        }
+        backup.Method = backupMethodPause
+    }
+    defer os.Remove(staged)
+
+    checksum, err := fileSHA256(staged)
+    if err != nil {
+        return nil, err
+    }
+    info, err := os.Stat(staged)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read backup: %w", err)
+    }
+    backup.SHA256 = checksum
+    backup.Size = info.Size()
+
+    tflog.Info(ctx, "Storing drive backup", map[string]interface{}{
+        "vm_id":    spec.VMID,
+        "location": backup.Location,
+        "method":   backup.Method,
+    })
+    if isS3Destination(spec.Destination) {
+        err = uploadS3Object(ctx, staged, backup.Location)
+    } else {
+        err = moveFile(ctx, staged, backup.Location)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return backup, nil
+}
+
+// reflinkDriveImage copies a drive image sharing its extents. It only works for files on
+// filesystems such as XFS and Btrfs.
+func reflinkDriveImage(ctx context.Context, src, dst string) error {
+    if err := trackArtifact(ctx, hostArtifact{Path: dst}); err != nil {
+        return err
+    }
+    if output, err := runCommand(ctx, "cp", "--reflink=always", src, dst); err != nil {
+        os.Remove(dst)
+        return fmt.Errorf("failed to create reflink copy of %s: %w: %s", src, err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// copyPausedDriveImage copies a drive image while its VM is paused. The VM is resumed
+// whether or not the copy succeeds.
+func copyPausedDriveImage(ctx context.Context, client *FirecrackerClient, src, dst string) error {
+    if err := trackArtifact(ctx, hostArtifact{Path: dst}); err != nil {
+        return err
+    }
+
+    api := client.api()
+    if err := api.Pause(ctx); err != nil {
+        return fmt.Errorf("failed to pause VM: %w", err)
+    }
+    copyErr := copyFile(src, dst)
+    if err := api.Resume(ctx); err != nil {
+        return fmt.Errorf("failed to resume VM after copying its drive: %w", err)
+    }
+    if copyErr != nil {
+        os.Remove(dst)
+        return copyErr
+    }
+    return nil
+}
+
+// moveFile moves a file, copying it when the destination is on another filesystem.
+func moveFile(ctx context.Context, src, dst string) error {
+    err := os.Rename(src, dst)
+    if err == nil {
+        return nil
+    }
+    if !errors.Is(err, syscall.EXDEV) {
+        return fmt.Errorf("failed to move backup to %s: %w", dst, err)
+    }
+
+    if err := trackArtifact(ctx, hostArtifact{Path: dst}); err != nil {
+        return err
+    }
+    if err := copyFile(src, dst); err != nil {
+        os.Remove(dst)
+        return err
+    }
+    return nil
+}
+
+// uploadS3Object copies a local file to S3 with the AWS CLI, which picks up credentials
+// from the environment, shared configuration or instance profile.
+func uploadS3Object(ctx context.Context, src, rawURL string) error {
+    if _, err := lookPath("aws"); err != nil {
+        return fmt.Errorf("uploading to %s requires the AWS CLI: %w", rawURL, err)
+    }
+    if output, err := runCommand(ctx, "aws", "s3", "cp", "--only-show-errors", src, rawURL); err != nil {
+        return fmt.Errorf("failed to upload %s: %w: %s", rawURL, err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// removeDriveBackup deletes a stored backup. A backup that is already gone is not an error.
+func removeDriveBackup(ctx context.Context, location string) error {
+    if isS3Destination(location) {
+        if _, err := lookPath("aws"); err != nil {
+            return fmt.Errorf("deleting %s requires the AWS CLI: %w", location, err)
+        }
+        if output, err := runCommand(ctx, "aws", "s3", "rm", "--only-show-errors", location); err != nil {
+            return fmt.Errorf("failed to delete %s: %w: %s", location, err, strings.TrimSpace(string(output)))
+        }
+        return nil
+    }
+    if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("failed to delete %s: %w", location, err)
+    }
+    return nil
+}
+
+// driveRestore describes a drive whose image is recreated from a backup before the VM boots.
+type driveRestore struct {
+    DriveID string
+    Backup  string
+    Path    string
+}
+
+// driveRestoresFromConfig returns the VM's drives with a restore_from backup. The image of
+// a scratch drive is restored to where the provider creates it, that of a jailed VM's drive
+// in the chroot unless the provider stages it there afterwards.
+func driveRestoresFromConfig(d *schema.ResourceData, stateDir, vmID string) []driveRestore {
+    restores := []driveRestore{}
+    for _, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        backup, _ := drive["restore_from"].(string)
+        if backup == "" {
+            continue
+        }
+        driveID := drive["drive_id"].(string)
+        path, _ := drive["path_on_host"].(string)
+        if path == "" {
+            path = scratchDrivePath(stateDir, vmID, driveID)
+        } else if spec, ok := jailerSpecFromConfig(d.Get("jailer"), vmID); ok && !spec.StageFiles {
+            path = spec.hostPath(path)
+        }
+        restores = append(restores, driveRestore{DriveID: driveID, Backup: backup, Path: path})
+    }
+    return restores
+}
+
+// restoreDriveBackup recreates a drive image from a backup taken by firecracker_drive_backup.
+// The backup is fetched next to the image first and only replaces it once complete, so an
+// interrupted restore leaves the image as it was.
+func restoreDriveBackup(ctx context.Context, restore driveRestore) error {
+    if err := os.MkdirAll(filepath.Dir(restore.Path), 0o755); err != nil {
+        return fmt.Errorf("failed to create drive directory: %w", err)
+    }
+    staged := filepath.Join(filepath.Dir(restore.Path), "."+filepath.Base(restore.Path)+".restore.tmp")
+    if err := trackArtifact(ctx, hostArtifact{Path: staged}); err != nil {
+        return err
+    }
+    defer os.Remove(staged)
+
+    var err error
+    if isS3Destination(restore.Backup) {
+        err = downloadS3Object(ctx, restore.Backup, staged)
+    } else {
+        err = copyFile(restore.Backup, staged)
+    }
+    if err != nil {
+        return fmt.Errorf("failed to restore drive %s from %s: %w", restore.DriveID, restore.Backup, err)
+    }
+    if err := os.Rename(staged, restore.Path); err != nil {
+        return fmt.Errorf("failed to restore drive %s: %w", restore.DriveID, err)
+    }
+    return nil
+}
+
+// checkDriveRestores replaces a VM when one of its drives is given a new backup to restore
+// from, since backups are only restored before the VM boots. Removing restore_from keeps the
+// VM and its drive as they are.
+func checkDriveRestores(d *schema.ResourceDiff) error {
+    if d.Id() == "" {
+        return nil
+    }
+    for i := range d.Get("drives").([]interface{}) {
+        key := fmt.Sprintf("drives.%d.restore_from", i)
+        if backup, _ := d.Get(key).(string); d.HasChange(key) && (backup != "" || !d.NewValueKnown(key)) {
+            return d.ForceNew(key)
+        }
+    }
+    return nil
+}
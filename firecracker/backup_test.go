@@ -0,0 +1,232 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// stubBackupCommands replaces runCommand with a fake where reflink copies succeed only
+// when reflink is true, and records every command run.
+func stubBackupCommands(t *testing.T, reflink bool) *[]string {
+	t.Helper()
+
+	commands := []string{}
+	originalRun, originalLookPath, originalNow := runCommand, lookPath, backupNow
+	t.Cleanup(func() { runCommand, lookPath, backupNow = originalRun, originalLookPath, originalNow })
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		if name == "cp" {
+			if !reflink {
+				return []byte("cp: failed to clone: Operation not supported"), errors.New("exit status 1")
+			}
+			return nil, copyFile(args[len(args)-2], args[len(args)-1])
+		}
+		if name == "aws" && args[1] == "cp" && strings.HasPrefix(args[3], "s3://") {
+			return nil, os.WriteFile(args[4], []byte("backup"), 0o644)
+		}
+		return nil, nil
+	}
+	lookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+	backupNow = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	return &commands
+}
+
+func TestResourceFirecrackerDriveBackup(t *testing.T) {
+	for name, tc := range map[string]struct {
+		reflink bool
+		method  string
+		backend string
+		paused  bool
+	}{
+		"reflink":             {true, backupMethodAuto, backupMethodReflink, false},
+		"falls back to pause": {false, backupMethodAuto, backupMethodPause, true},
+		"pause":               {true, backupMethodPause, backupMethodPause, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			stubBackupCommands(t, tc.reflink)
+			ctx := context.Background()
+			stateDir := t.TempDir()
+
+			drive := filepath.Join(t.TempDir(), "data.img")
+			if err := os.WriteFile(drive, []byte("data"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			destination := t.TempDir()
+
+			d := schema.TestResourceDataRaw(t, resourceFirecrackerDriveBackup().Schema, map[string]interface{}{
+				"vm_id":       "web",
+				"drive_path":  drive,
+				"destination": destination,
+				"method":      tc.method,
+			})
+			if diags := resourceFirecrackerDriveBackupCreate(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+				t.Fatalf("Failed to back up drive: %v", diags)
+			}
+
+			location := filepath.Join(destination, "web-20240501T120000Z-data.img")
+			if d.Id() != location || d.Get("backend").(string) != tc.backend {
+				t.Errorf("Expected a %s backup at %s, got %s at %s", tc.backend, location, d.Get("backend"), d.Id())
+			}
+			if data, err := os.ReadFile(location); err != nil || string(data) != "data" {
+				t.Errorf("Expected the backup to hold the drive's contents, got %q, %v", data, err)
+			}
+			if d.Get("sha256").(string) != "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7" || d.Get("size_bytes").(int) != 4 {
+				t.Errorf("Unexpected checksum %s or size %d", d.Get("sha256"), d.Get("size_bytes"))
+			}
+
+			// Only the pause method pauses the VM, and it is always resumed
+			vm, err := os.ReadFile(filepath.Join(stateDir, "fake", "test", "vm.json"))
+			if tc.paused && (err != nil || !strings.Contains(string(vm), "Resumed")) {
+				t.Errorf("Expected the VM to be resumed, got %s, %v", vm, err)
+			}
+			if !tc.paused && err == nil {
+				t.Errorf("Expected the VM not to be paused, got %s", vm)
+			}
+
+			// Staged copies are never left behind
+			for _, dir := range []string{filepath.Dir(drive), destination} {
+				entries, _ := os.ReadDir(dir)
+				for _, entry := range entries {
+					if strings.HasSuffix(entry.Name(), ".tmp") {
+						t.Errorf("Expected no staged copy, found %s", filepath.Join(dir, entry.Name()))
+					}
+				}
+			}
+
+			// Backups are kept on destroy unless asked otherwise
+			if diags := resourceFirecrackerDriveBackupDelete(ctx, d, nil); diags.HasError() {
+				t.Fatalf("Failed to delete drive backup: %v", diags)
+			}
+			if _, err := os.Stat(location); err != nil {
+				t.Errorf("Expected the backup to be kept, got %v", err)
+			}
+			d.SetId(location)
+			d.Set("retain_on_destroy", false)
+			if diags := resourceFirecrackerDriveBackupDelete(ctx, d, nil); diags.HasError() {
+				t.Fatalf("Failed to delete drive backup: %v", diags)
+			}
+			if _, err := os.Stat(location); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Expected the backup to be deleted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBackupDrive_s3(t *testing.T) {
+	commands := stubBackupCommands(t, true)
+
+	drive := filepath.Join(t.TempDir(), "data.img")
+	if err := os.WriteFile(drive, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	spec := driveBackupSpec{VMID: "web", DrivePath: drive, Destination: "s3://backups/vms/", Method: backupMethodAuto, StateDir: t.TempDir()}
+	backup, err := backupDrive(context.Background(), &FirecrackerClient{}, spec)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if backup.Location != "s3://backups/vms/web-20240501T120000Z-data.img" {
+		t.Errorf("Unexpected location %s", backup.Location)
+	}
+
+	staged := filepath.Join(filepath.Dir(drive), ".web-20240501T120000Z-data.img.tmp")
+	expected := []string{
+		"cp --reflink=always " + drive + " " + staged,
+		"aws s3 cp --only-show-errors " + staged + " s3://backups/vms/web-20240501T120000Z-data.img",
+	}
+	if strings.Join(*commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected commands:\n%s", strings.Join(*commands, "\n"))
+	}
+	if _, err := os.Stat(staged); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the staged copy to be removed, got %v", err)
+	}
+}
+
+func TestRestoreDrives(t *testing.T) {
+	commands := stubBackupCommands(t, true)
+	ctx := context.Background()
+	stateDir := t.TempDir()
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+	defer resourceFirecrackerTestImageDelete(ctx, image, nil)
+
+	data := filepath.Join(t.TempDir(), "data.img")
+	if err := os.WriteFile(data, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backup := filepath.Join(t.TempDir(), "web-20240501T120000Z-data.img")
+	if err := os.WriteFile(backup, []byte("backup"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": image.Get("path").(string),
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true},
+			map[string]interface{}{"drive_id": "data", "path_on_host": data, "is_root_device": false, "restore_from": backup},
+			map[string]interface{}{"drive_id": "scratch", "size_mib": 1, "is_root_device": false, "restore_from": "s3://backups/vms/web-20240501T120000Z-scratch.img"},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	for _, path := range []string{data, scratchDrivePath(stateDir, d.Id(), "scratch")} {
+		if contents, err := os.ReadFile(path); err != nil || string(contents) != "backup" {
+			t.Errorf("Expected %s to be restored from its backup, got %q, %v", path, contents, err)
+		}
+	}
+	staged := filepath.Join(scratchDriveDir(stateDir, d.Id()), ".scratch.img.restore.tmp")
+	if got := strings.Join(*commands, "\n"); got != "aws s3 cp --only-show-errors s3://backups/vms/web-20240501T120000Z-scratch.img "+staged {
+		t.Errorf("Unexpected commands:\n%s", got)
+	}
+}
+
+func TestCheckDriveRestores(t *testing.T) {
+	config := func(restoreFrom string) map[string]interface{} {
+		return map[string]interface{}{
+			"kernel_image_path": "/images/vmlinux",
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true},
+				map[string]interface{}{"drive_id": "data", "path_on_host": "/images/data.ext4", "is_root_device": false, "restore_from": restoreFrom},
+			},
+			"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		from, to    string
+		requiresNew bool
+	}{
+		"restore":          {"", "/backups/data-1.img", true},
+		"other backup":     {"/backups/data-1.img", "/backups/data-2.img", true},
+		"restore finished": {"/backups/data-1.img", "", false},
+		"unchanged backup": {"/backups/data-1.img", "/backups/data-1.img", false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			prior := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config(tc.from))
+			prior.SetId("web")
+			diff, err := resourceFirecrackerVM().Diff(context.Background(), prior.State(), terraform.NewResourceConfigRaw(config(tc.to)), nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if requiresNew := diff != nil && diff.RequiresNew(); requiresNew != tc.requiresNew {
+				t.Errorf("Expected replacement to be %t, got %t", tc.requiresNew, requiresNew)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+)
+
+// memoryUsage summarizes the guest memory committed on a Firecracker API and how much of it
+// the balloon device has reclaimed for the host.
+type memoryUsage struct {
+    CommittedMiB int
+    BalloonedMiB int
+    Ballooned    bool
+}
+
+// EffectiveMiB returns the memory the guest actually holds on to.
+func (u *memoryUsage) EffectiveMiB() int {
+    if u.BalloonedMiB > u.CommittedMiB {
+        return 0
+    }
+    return u.CommittedMiB - u.BalloonedMiB
+}
+
+// GetMemoryUsage reports the memory committed to the VM and reclaimed by its balloon device.
+// Balloon statistics are preferred since they reflect what the guest has actually given back;
+// when statistics are disabled, the balloon's target size is used instead.
+func (c *FirecrackerClient) GetMemoryUsage(ctx context.Context) (*memoryUsage, error) {
+    machineConfig, err := c.getComponent(ctx, fmt.Sprintf("%s/machine-config", c.BaseURL))
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", errHostUnreachable, err)
+    }
+
+    usage := &memoryUsage{}
+    if memSize, ok := machineConfig["mem_size_mib"].(float64); ok {
+        usage.CommittedMiB = int(memSize)
+    }
+
+    stats, err := c.getComponent(ctx, fmt.Sprintf("%s/balloon/statistics", c.BaseURL))
+    if err != nil {
+        return nil, fmt.Errorf("failed to get balloon statistics: %w", err)
+    }
+    if actual, ok := stats["actual_mib"].(float64); ok {
+        usage.BalloonedMiB = int(actual)
+        usage.Ballooned = true
+        return usage, nil
+    }
+
+    balloon, err := c.getComponent(ctx, fmt.Sprintf("%s/balloon", c.BaseURL))
+    if err != nil {
+        return nil, fmt.Errorf("failed to get balloon configuration: %w", err)
+    }
+    if amount, ok := balloon["amount_mib"].(float64); ok {
+        usage.BalloonedMiB = int(amount)
+        usage.Ballooned = true
+    }
+
+    return usage, nil
+}
+
+// balloonStatistics are the counters GET /balloon/statistics reports. Balloon sizes are in
+// pages and MiB, guest memory figures in bytes.
+type balloonStatistics struct {
+    Values map[string]int
+}
+
+// balloonStatisticFields are the counters of balloonStatistics. The guest memory counters
+// are only present when the guest's balloon driver reports them.
+var balloonStatisticFields = []string{
+    "target_pages",
+    "actual_pages",
+    "target_mib",
+    "actual_mib",
+    "swap_in",
+    "swap_out",
+    "major_faults",
+    "minor_faults",
+    "free_memory",
+    "total_memory",
+    "available_memory",
+    "disk_caches",
+    "hugetlb_allocations",
+    "hugetlb_failures",
+}
+
+// GetBalloonStatistics returns the statistics of the VM's balloon device. It fails when the
+// VM has no balloon device or the device was configured without statistics.
+func (c *FirecrackerClient) GetBalloonStatistics(ctx context.Context) (*balloonStatistics, error) {
+    stats, err := c.getComponent(ctx, fmt.Sprintf("%s/balloon/statistics", c.BaseURL))
+    if err != nil {
+        return nil, fmt.Errorf("failed to get balloon statistics: %w", err)
+    }
+    if len(stats) == 0 {
+        return nil, fmt.Errorf("the VM at %s reports no balloon statistics: it needs a balloon device with stats_polling_interval_s set", c.BaseURL)
+    }
+
+    result := &balloonStatistics{Values: map[string]int{}}
+    for _, field := range balloonStatisticFields {
+        if value, ok := stats[field].(float64); ok {
+            result.Values[field] = int(value)
+        }
+    }
+    return result, nil
+}
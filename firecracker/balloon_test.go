@@ -0,0 +1,79 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestGetMemoryUsage(t *testing.T) {
+	responses := map[string]string{
+		"/machine-config":     `{"vcpu_count": 2, "mem_size_mib": 1024}`,
+		"/balloon/statistics": `{"target_mib": 256, "actual_mib": 200}`,
+	}
+
+	client := &FirecrackerClient{
+		BaseURL: "http://localhost:8080",
+		HTTPClient: &mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				body, ok := responses[req.URL.Path]
+				if !ok {
+					return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+			},
+		},
+	}
+
+	usage, err := client.GetMemoryUsage(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if usage.CommittedMiB != 1024 || usage.BalloonedMiB != 200 || !usage.Ballooned {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+	if usage.EffectiveMiB() != 824 {
+		t.Errorf("Expected 824 MiB effective, got %d", usage.EffectiveMiB())
+	}
+
+	// Without statistics the balloon target is used
+	delete(responses, "/balloon/statistics")
+	responses["/balloon"] = `{"amount_mib": 512, "deflate_on_oom": true}`
+	usage, err = client.GetMemoryUsage(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if usage.BalloonedMiB != 512 {
+		t.Errorf("Expected 512 MiB ballooned, got %d", usage.BalloonedMiB)
+	}
+
+	// No balloon device at all
+	delete(responses, "/balloon")
+	usage, err = client.GetMemoryUsage(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if usage.Ballooned || usage.EffectiveMiB() != 1024 {
+		t.Errorf("Expected unballooned 1024 MiB, got %+v", usage)
+	}
+}
+
+func TestSortHostMemoryUsage(t *testing.T) {
+	hosts := []hostMemoryUsage{
+		{Name: "edge-1", Reachable: true, Usage: memoryUsage{CommittedMiB: 2048, BalloonedMiB: 512}},
+		{Name: "edge-2", Reachable: false},
+		{Name: "edge-3", Reachable: true, Usage: memoryUsage{CommittedMiB: 1024}},
+		{Name: "edge-0", Reachable: true, Usage: memoryUsage{CommittedMiB: 1536}},
+	}
+
+	sortHostMemoryUsage(hosts)
+
+	expected := []string{"edge-3", "edge-0", "edge-1", "edge-2"}
+	for i, name := range expected {
+		if hosts[i].Name != name {
+			t.Errorf("Expected %s at position %d, got %s", name, i, hosts[i].Name)
+		}
+	}
+}
@@ -3,76 +3,156 @@ package firecracker
 import (
     "bytes"
     "context"
-    "encoding/json"
+    "errors"
     "fmt"
     "io"
     "net/http"
+    "net/url"
     "os"
-    "time"
+    "path/filepath"
+    "strings"
+    "sync"
 
-    "github.com/hashicorp/go-retryablehttp"
+    sdk "github.com/avkcode/terraform-provider-firecracker/client"
     "github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
-// VMConfig represents the configuration for a Firecracker VM.
-type VMConfig struct {
-    KernelImage string `json:"kernel_image_path"`
-    Rootfs      string `json:"rootfs_path"`
-    CPUCount    int    `json:"vcpu_count"`
-    MemorySize  int    `json:"mem_size_mib"`
-}
+// errHostUnreachable is returned when the Firecracker API cannot be reached at all,
+// as opposed to the API answering that the VM does not exist.
+var errHostUnreachable = errors.New("Firecracker API is unreachable")
 
 // httpClient is an interface for HTTP operations to make testing easier
 type httpClient interface {
     Do(req *http.Request) (*http.Response, error)
 }
 
-// defaultHTTPClient returns a default HTTP client with reasonable timeouts and retry logic
-func defaultHTTPClient() *http.Client {
-    retryClient := retryablehttp.NewClient()
-    retryClient.RetryMax = 3
-    retryClient.RetryWaitMin = 1 * time.Second
-    retryClient.RetryWaitMax = 5 * time.Second
-    retryClient.Logger = nil // Disable default logger
-    
-    // Configure the underlying transport
-    retryClient.HTTPClient.Timeout = 30 * time.Second
-    retryClient.HTTPClient.Transport = &http.Transport{
-        MaxIdleConns:        100,
-        MaxIdleConnsPerHost: 20,
-        IdleConnTimeout:     90 * time.Second,
+// do sends a request to the Firecracker API. Requests to the same API endpoint are sent one
+// at a time, since a Firecracker process handles its API requests one by one, and the total
+// number in flight is limited when the provider sets max_concurrent_requests. Requests the
+// API could not be reached for are sent again by the provider's retry policy.
+func (c *FirecrackerClient) do(req *http.Request) (*http.Response, error) {
+    send := c.send
+    if c.httpDump != nil {
+        send = func(req *http.Request) (*http.Response, error) {
+            return c.httpDump.record(c, req, c.send)
+        }
     }
-    
-    return retryClient.StandardClient()
+    // Changes are audited once, however many attempts sending them takes
+    if recorder := auditRecorderFrom(req.Context()); recorder != nil && !readOnlyMethod(req.Method) {
+        return recorder.record(c, req, func(req *http.Request) (*http.Response, error) {
+            return c.sendWithRetries(req, send)
+        })
+    }
+    return c.sendWithRetries(req, send)
+}
+
+// send makes a single attempt at sending a request to the Firecracker API. Requests that
+// would change the API are rejected while the provider is read-only.
+func (c *FirecrackerClient) send(req *http.Request) (*http.Response, error) {
+    if c.ReadOnly && !readOnlyMethod(req.Method) {
+        return nil, fmt.Errorf("refusing %s %s: %w", req.Method, req.URL.Path, errReadOnly)
+    }
+    client := c.HTTPClient
+    if client == nil {
+        client = http.DefaultClient
+    }
+    c.setRequestHeaders(req)
+    if c.requests == nil {
+        return client.Do(req)
+    }
+
+    release, err := c.requests.acquire(req.Context(), req.URL.Host)
+    if err != nil {
+        return nil, err
+    }
+    defer release()
+
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    // The exchange is only over once the response is read, so it is read before the next
+    // request to the endpoint is sent
+    body, err := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if err != nil {
+        return nil, err
+    }
+    resp.Body = io.NopCloser(bytes.NewReader(body))
+    return resp, nil
 }
 
 // CreateVM creates a new Firecracker VM by configuring its components one by one.
 // It takes a context for cancellation and a configuration map that defines the VM properties.
 func (c *FirecrackerClient) CreateVM(ctx context.Context, config map[string]interface{}) error {
+    if err := c.configureVM(ctx, config); err != nil {
+        return err
+    }
+    return c.startInstance(ctx)
+}
+
+// configureVM configures the components of a VM before it is started. The VM's API is held
+// until all are configured, so requests of other resources sharing it, such as a
+// firecracker_mmds_contents, cannot interleave with them.
+func (c *FirecrackerClient) configureVM(ctx context.Context, config map[string]interface{}) error {
+    ctx, release, err := c.holdAPI(ctx)
+    if err != nil {
+        return err
+    }
+    defer release()
+
+    if fromFile, _ := config[configFileKey].(bool); fromFile {
+        return c.applyConfigFile(ctx, config)
+    }
+
+    logged := redactVMConfig(config)
     tflog.Debug(ctx, "Creating VM by configuring components", map[string]interface{}{
-        "config": config,
+        "config": logged,
     })
 
-    // Boot source is now configured earlier in the process, before drives
-
-    // First, configure boot source before anything else
+    // Configure the boot source before anything else
     if bootSource, ok := config["boot-source"].(map[string]interface{}); ok {
-        bootSourceURL := fmt.Sprintf("%s/boot-source", c.BaseURL)
         tflog.Debug(ctx, "Configuring boot source", map[string]interface{}{
             "kernel_image_path": bootSource["kernel_image_path"],
-            "boot_args": bootSource["boot_args"],
+            "boot_args": logged["boot-source"].(map[string]interface{})["boot_args"],
         })
     
-        // Ensure the kernel image path exists
+        // Ensure the kernel image path exists, unless it is on another host or the API is
+        // mocked
         kernelPath := bootSource["kernel_image_path"].(string)
-        if _, err := os.Stat(kernelPath); os.IsNotExist(err) {
+        if chroot, ok := config["chroot"].(string); ok {
+            kernelPath = filepath.Join(chroot, kernelPath)
+        }
+        if _, staged := config["staging_dir"]; staged {
+            tflog.Debug(ctx, "Kernel image was uploaded to the Firecracker host", map[string]interface{}{
+                "kernel_path": kernelPath,
+            })
+        } else if host, ok := config["host"].(string); ok {
+            tflog.Debug(ctx, "Kernel image is on the Firecracker host", map[string]interface{}{
+                "kernel_path": kernelPath,
+                "host":        host,
+            })
+        } else if c.mock {
+            tflog.Debug(ctx, "Not checking the kernel image in mock mode", map[string]interface{}{
+                "kernel_path": kernelPath,
+            })
+        } else if _, err := os.Stat(kernelPath); os.IsNotExist(err) {
             tflog.Error(ctx, "Kernel image file does not exist", map[string]interface{}{
                 "kernel_path": kernelPath,
             })
             return fmt.Errorf("kernel image file does not exist: %s", kernelPath)
         }
     
-        if err := c.putComponent(ctx, bootSourceURL, bootSource); err != nil {
+        bootArgs, _ := bootSource["boot_args"].(string)
+        initrdPath, _ := bootSource["initrd_path"].(string)
+        err := c.callAPI(ctx, http.MethodPut, "/boot-source", func(ctx context.Context, api *sdk.Client) error {
+            return api.PutBootSource(ctx, sdk.BootSource{
+                KernelImagePath: bootSource["kernel_image_path"].(string),
+                BootArgs:        bootArgs,
+                InitrdPath:      initrdPath,
+            })
+        })
+        if err != nil {
             return fmt.Errorf("failed to configure boot source: %w", err)
         }
         tflog.Debug(ctx, "Boot source configured successfully", nil)
@@ -80,189 +160,142 @@ func (c *FirecrackerClient) CreateVM(ctx context.Context, config map[string]inte
         return fmt.Errorf("boot source configuration is required but was not provided")
     }
 
-    // Configure machine config
-    if machineConfig, ok := config["machine-config"].(map[string]interface{}); ok {
-        machineConfigURL := fmt.Sprintf("%s/machine-config", c.BaseURL)
-        if err := c.putComponent(ctx, machineConfigURL, machineConfig); err != nil {
+    // Configure the vCPUs and memory, unless Firecracker's defaults are kept
+    if raw, ok := config["machine-config"].(map[string]interface{}); ok && len(raw) > 0 {
+        machineConfig, err := apiMachineConfig(raw)
+        if err != nil {
+            return err
+        }
+        err = c.callAPI(ctx, http.MethodPut, "/machine-config", func(ctx context.Context, api *sdk.Client) error {
+            return api.PutMachineConfig(ctx, machineConfig)
+        })
+        if err != nil {
             return fmt.Errorf("failed to configure machine: %w", err)
         }
     }
 
-    // Configure drives - ensure root device is configured first
+    // Apply the custom CPU template, which must be set before the VM starts
+    if cpuConfig, ok := config["cpu-config"].(map[string]interface{}); ok {
+        err := c.callAPI(ctx, http.MethodPut, "/cpu-config", func(ctx context.Context, api *sdk.Client) error {
+            return api.PutCPUConfig(ctx, sdk.CPUConfig(cpuConfig))
+        })
+        if err != nil {
+            return fmt.Errorf("failed to configure CPU template: %w", err)
+        }
+    }
+
+    // Configure drives, the root device first. The other drives are configured along with
+    // the network interfaces below.
+    var devices []apiComponent
     if drives, ok := config["drives"].([]interface{}); ok {
-        // Log all drives for debugging
         tflog.Debug(ctx, "All drives configuration", map[string]interface{}{
             "drives_count": len(drives),
             "drives":       drives,
         })
-        
-        // First pass: configure root device
-        for _, driveRaw := range drives {
-            drive, ok := driveRaw.(map[string]interface{})
-            if !ok {
-                return fmt.Errorf("invalid drive configuration format")
-            }
-            
-            // Check if this is the root device
-            isRootDevice := false
-            if rootDeviceVal, ok := drive["is_root_device"]; ok {
-                if rootDeviceStr, ok := rootDeviceVal.(string); ok {
-                    isRootDevice = rootDeviceStr == "true"
-                } else if rootDeviceBool, ok := rootDeviceVal.(bool); ok {
-                    isRootDevice = rootDeviceBool
-                }
+
+        for _, raw := range drives {
+            drive, err := apiDrive(raw)
+            if err != nil {
+                return err
             }
-            
-            // Skip non-root devices in first pass
-            if !isRootDevice {
+            if !drive.IsRootDevice {
+                devices = append(devices, apiComponent{
+                    Name: "drive " + drive.DriveID,
+                    Path: "/drives/" + drive.DriveID,
+                    Put: func(ctx context.Context, api *sdk.Client) error {
+                        return api.PutDrive(ctx, drive)
+                    },
+                })
                 continue
             }
-            
-            // Configure the root device first
-            driveID := "rootfs" // Force root device ID to be "rootfs"
-            driveURL := fmt.Sprintf("%s/drives/%s", c.BaseURL, driveID)
-            
-            // Create a clean drive configuration for the API
-            apiDriveConfig := map[string]interface{}{
-                "drive_id":       driveID,
-                "path_on_host":   drive["path_on_host"],
-                "is_root_device": true,
-                "is_read_only":   false,
-                "rate_limiter":   map[string]interface{}{},
-            }
-            
-            // Set read-only flag
-            if readOnlyVal, ok := drive["is_read_only"]; ok {
-                if readOnlyStr, ok := readOnlyVal.(string); ok {
-                    apiDriveConfig["is_read_only"] = readOnlyStr == "true"
-                } else if readOnlyBool, ok := readOnlyVal.(bool); ok {
-                    apiDriveConfig["is_read_only"] = readOnlyBool
-                } else {
-                    apiDriveConfig["is_read_only"] = false
-                }
-            } else {
-                apiDriveConfig["is_read_only"] = false
-            }
-            
+
+            // The root device is always called rootfs
+            drive.DriveID = "rootfs"
             tflog.Debug(ctx, "Configuring root drive", map[string]interface{}{
-                "drive_id":     driveID,
-                "path_on_host": apiDriveConfig["path_on_host"],
-                "is_read_only": apiDriveConfig["is_read_only"],
+                "drive_id":     drive.DriveID,
+                "path_on_host": drive.PathOnHost,
+                "is_read_only": drive.IsReadOnly,
+            })
+            err = c.callAPI(ctx, http.MethodPut, "/drives/"+drive.DriveID, func(ctx context.Context, api *sdk.Client) error {
+                return api.PutDrive(ctx, drive)
             })
-            
-            if err := c.putComponent(ctx, driveURL, apiDriveConfig); err != nil {
+            if err != nil {
                 return fmt.Errorf("failed to configure root drive: %w", err)
             }
-            
             tflog.Debug(ctx, "Root drive configured successfully", nil)
         }
-        
-        // Second pass: configure non-root devices
-        
-        for i, driveRaw := range drives {
-            drive, ok := driveRaw.(map[string]interface{})
-            if !ok {
-                return fmt.Errorf("invalid drive configuration format")
-            }
-            
-            // Check if this is the root device
-            isRootDevice := false
-            if rootDeviceVal, ok := drive["is_root_device"]; ok {
-                if rootDeviceStr, ok := rootDeviceVal.(string); ok {
-                    isRootDevice = rootDeviceStr == "true"
-                } else if rootDeviceBool, ok := rootDeviceVal.(bool); ok {
-                    isRootDevice = rootDeviceBool
-                }
-            }
-            
-            // Skip root device in second pass as it's already configured
-            if isRootDevice {
-                continue
-            }
-            
-            driveID := drive["drive_id"].(string)
-            driveURL := fmt.Sprintf("%s/drives/%s", c.BaseURL, driveID)
-            
-            // Ensure drive configuration has all required fields
-            if _, ok := drive["is_read_only"]; !ok {
-                drive["is_read_only"] = false
-            }
-            
-            // Create a clean drive configuration for the API
-            apiDriveConfig := map[string]interface{}{
-                "drive_id":       driveID,
-                "path_on_host":   drive["path_on_host"],
-            }
-            
-            // Ensure boolean values are properly set
-            // Convert string values to boolean if needed
-            if rootDeviceStr, ok := drive["is_root_device"].(string); ok {
-                apiDriveConfig["is_root_device"] = rootDeviceStr == "true"
-            } else if rootDeviceBool, ok := drive["is_root_device"].(bool); ok {
-                apiDriveConfig["is_root_device"] = rootDeviceBool
-            } else {
-                // Default to false if not specified
-                apiDriveConfig["is_root_device"] = false
-            }
-            
-            if readOnlyStr, ok := drive["is_read_only"].(string); ok {
-                apiDriveConfig["is_read_only"] = readOnlyStr == "true"
-            } else if readOnlyBool, ok := drive["is_read_only"].(bool); ok {
-                apiDriveConfig["is_read_only"] = readOnlyBool
-            } else {
-                // Default to false if not specified
-                apiDriveConfig["is_read_only"] = false
-            }
-            
-            // For root devices, we need to ensure they can be properly mounted
-            if apiDriveConfig["is_root_device"].(bool) {
-                // Set the drive ID to "rootfs" for the root device to ensure consistent naming
-                apiDriveConfig["drive_id"] = "rootfs"
-            }
-            
-            // Enhanced debugging for each drive
-            tflog.Debug(ctx, fmt.Sprintf("Drive %d configuration details", i), map[string]interface{}{
-                "drive_id":       driveID,
-                "url":            driveURL,
-                "is_root_device": apiDriveConfig["is_root_device"],
-                "path_on_host":   apiDriveConfig["path_on_host"],
-                "is_read_only":   apiDriveConfig["is_read_only"],
-                "raw_config":     drive,
-                "api_config":     apiDriveConfig,
-            })
-            
-            // Log the final configuration we're sending to the API
-            tflog.Debug(ctx, "Final drive configuration for API", map[string]interface{}{
-                "drive_id":       driveID,
-                "path_on_host":   apiDriveConfig["path_on_host"],
-                "is_root_device": apiDriveConfig["is_root_device"],
-                "is_read_only":   apiDriveConfig["is_read_only"],
-            })
-            
-            if err := c.putComponent(ctx, driveURL, apiDriveConfig); err != nil {
-                return fmt.Errorf("failed to configure drive %s: %w", driveID, err)
-            }
-            
-            // Verify the drive was configured correctly
-            tflog.Debug(ctx, fmt.Sprintf("Drive %s configured successfully", driveID), map[string]interface{}{
-                "is_root_device": apiDriveConfig["is_root_device"],
-            })
-        }
     }
 
     // Configure network interfaces
     if networkInterfaces, ok := config["network-interfaces"].([]interface{}); ok {
-        for _, ifaceRaw := range networkInterfaces {
-            iface, ok := ifaceRaw.(map[string]interface{})
-            if !ok {
-                return fmt.Errorf("invalid network interface configuration format")
-            }
-            
-            ifaceID := iface["iface_id"].(string)
-            ifaceURL := fmt.Sprintf("%s/network-interfaces/%s", c.BaseURL, ifaceID)
-            if err := c.putComponent(ctx, ifaceURL, iface); err != nil {
-                return fmt.Errorf("failed to configure network interface %s: %w", ifaceID, err)
+        for _, raw := range networkInterfaces {
+            iface, err := apiNetworkInterface(raw)
+            if err != nil {
+                return err
             }
+            devices = append(devices, apiComponent{
+                Name: "network interface " + iface.IfaceID,
+                Path: "/network-interfaces/" + iface.IfaceID,
+                Put: func(ctx context.Context, api *sdk.Client) error {
+                    return api.PutNetworkInterface(ctx, iface)
+                },
+            })
+        }
+    }
+
+    // The non-root drives and network interfaces do not depend on each other, so they are
+    // configured concurrently
+    if err := c.putComponents(ctx, devices); err != nil {
+        return err
+    }
+    tflog.Debug(ctx, "Devices configured successfully", map[string]interface{}{
+        "devices": len(devices),
+    })
+
+    // Configure the vsock device used to talk to the guest agent
+    if raw, ok := config["vsock"].(map[string]interface{}); ok {
+        vsock, err := apiVsock(raw)
+        if err != nil {
+            return err
+        }
+        err = c.callAPI(ctx, http.MethodPut, "/vsock", func(ctx context.Context, api *sdk.Client) error {
+            return api.PutVsock(ctx, vsock)
+        })
+        if err != nil {
+            return fmt.Errorf("failed to configure vsock: %w", err)
+        }
+    }
+
+    // Configure the entropy device
+    if _, ok := config["entropy"].(map[string]interface{}); ok {
+        err := c.callAPI(ctx, http.MethodPut, "/entropy", func(ctx context.Context, api *sdk.Client) error {
+            return api.PutEntropy(ctx, sdk.Entropy{})
+        })
+        if err != nil {
+            return fmt.Errorf("failed to configure entropy device: %w", err)
+        }
+    }
+
+    // Configure MMDS. The config must be set before the data store is populated,
+    // and both must happen before the VM starts.
+    if raw, ok := config["mmds-config"].(map[string]interface{}); ok {
+        mmdsConfig, err := apiMMDSConfig(raw)
+        if err != nil {
+            return err
+        }
+        err = c.callAPI(ctx, http.MethodPut, "/mmds/config", func(ctx context.Context, api *sdk.Client) error {
+            return api.PutMMDSConfig(ctx, mmdsConfig)
+        })
+        if err != nil {
+            return fmt.Errorf("failed to configure MMDS: %w", err)
+        }
+    }
+    if mmds, ok := config["mmds"].(map[string]interface{}); ok {
+        err := c.callAPI(ctx, http.MethodPut, "/mmds", func(ctx context.Context, api *sdk.Client) error {
+            return api.PutMMDS(ctx, mmds)
+        })
+        if err != nil {
+            return fmt.Errorf("failed to populate MMDS: %w", err)
         }
     }
 
@@ -274,18 +307,25 @@ func (c *FirecrackerClient) CreateVM(ctx context.Context, config map[string]inte
     
     // Log the full configuration before starting the VM
     tflog.Debug(ctx, "Full VM configuration before starting", map[string]interface{}{
-        "boot_source":        config["boot-source"],
+        "boot_source":        logged["boot-source"],
         "machine_config":     config["machine-config"],
         "drives":             config["drives"],
         "network_interfaces": config["network-interfaces"],
     })
-    
-    // Start the VM
-    actionsURL := fmt.Sprintf("%s/actions", c.BaseURL)
-    startAction := map[string]interface{}{
-        "action_type": "InstanceStart",
+    return nil
+}
+
+// startInstance boots a configured VM.
+func (c *FirecrackerClient) startInstance(ctx context.Context) error {
+    // Wait for a boot slot if the provider limits boots per minute
+    if err := c.bootThrottle.Wait(ctx); err != nil {
+        return fmt.Errorf("cancelled while waiting for a boot slot: %w", err)
     }
-    if err := c.putComponent(ctx, actionsURL, startAction); err != nil {
+
+    err := c.callAPI(ctx, http.MethodPut, "/actions", func(ctx context.Context, api *sdk.Client) error {
+        return api.Start(ctx)
+    })
+    if err != nil {
         return fmt.Errorf("failed to start VM: %w", err)
     }
 
@@ -293,151 +333,235 @@ func (c *FirecrackerClient) CreateVM(ctx context.Context, config map[string]inte
     return nil
 }
 
-// Helper method to send PUT requests to configure components
-func (c *FirecrackerClient) putComponent(ctx context.Context, url string, payload interface{}) error {
-    jsonPayload, err := json.Marshal(payload)
-    if err != nil {
-        return fmt.Errorf("failed to marshal payload: %w", err)
-    }
+// componentParallelism is how many devices of a VM are configured at the same time.
+const componentParallelism = 4
+
+// apiComponent is a component of a VM configured with a PUT request.
+type apiComponent struct {
+    // Name describes the component in errors, such as "drive data".
+    Name string
+    // Path is the path of the component in the API, such as /drives/data.
+    Path string
+    // Put sends the request configuring the component.
+    Put func(ctx context.Context, api *sdk.Client) error
+}
 
-    tflog.Debug(ctx, "Sending PUT request to Firecracker API", map[string]interface{}{
-        "url": url,
-        "payload": string(jsonPayload),
-    })
+// apiDrive converts a drive of a VM's configuration to the drive configured in the API.
+func apiDrive(raw interface{}) (sdk.Drive, error) {
+    payload, err := drivePayload(raw)
+    if err != nil {
+        return sdk.Drive{}, err
+    }
+    partUUID, _ := payload["partuuid"].(string)
+    return sdk.Drive{
+        DriveID:      payload["drive_id"].(string),
+        PathOnHost:   payload["path_on_host"].(string),
+        IsRootDevice: payload["is_root_device"].(bool),
+        IsReadOnly:   payload["is_read_only"].(bool),
+        PartUUID:     partUUID,
+    }, nil
+}
 
-    req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewBuffer(jsonPayload))
+// apiNetworkInterface converts a network interface of a VM's configuration to the interface
+// configured in the API.
+func apiNetworkInterface(raw interface{}) (sdk.NetworkInterface, error) {
+    payload, err := networkInterfacePayload(raw)
     if err != nil {
-        return fmt.Errorf("failed to create HTTP request: %w", err)
-    }
-    req.Header.Set("Content-Type", "application/json")
+        return sdk.NetworkInterface{}, err
+    }
+    guestMAC, _ := payload["guest_mac"].(string)
+    return sdk.NetworkInterface{
+        IfaceID:     payload["iface_id"].(string),
+        HostDevName: payload["host_dev_name"].(string),
+        GuestMAC:    guestMAC,
+    }, nil
+}
 
-    client := c.HTTPClient
-    if client == nil {
-        client = defaultHTTPClient()
+// apiMachineConfig converts the machine-config of a VM's configuration to the machine
+// configuration of the API, which takes both the vCPUs and the memory.
+func apiMachineConfig(raw map[string]interface{}) (sdk.MachineConfig, error) {
+    vcpuCount, err := coerceInt(raw["vcpu_count"])
+    if err != nil {
+        return sdk.MachineConfig{}, fmt.Errorf("invalid machine vcpu_count: %w", err)
     }
-
-    resp, err := client.Do(req)
+    memSizeMiB, err := coerceInt(raw["mem_size_mib"])
     if err != nil {
-        tflog.Error(ctx, "Failed to send request to Firecracker API", map[string]interface{}{
-            "url":     url,
-            "error":   err.Error(),
-            "payload": string(jsonPayload),
-        })
-        return fmt.Errorf("failed to send request: %w", err)
+        return sdk.MachineConfig{}, fmt.Errorf("invalid machine mem_size_mib: %w", err)
     }
-    defer resp.Body.Close()
-
-    if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-        body, _ := io.ReadAll(resp.Body)
-        tflog.Error(ctx, "Firecracker API error", map[string]interface{}{
-            "url":             url,
-            "status":          resp.StatusCode,
-            "response":        string(body),
-            "request_payload": string(jsonPayload),
-            "headers":         resp.Header,
-        })
-        return fmt.Errorf("API error: status=%d, response=%s, url=%s, payload=%s", 
-                          resp.StatusCode, string(body), url, string(jsonPayload))
+    if vcpuCount == 0 || memSizeMiB == 0 {
+        return sdk.MachineConfig{}, fmt.Errorf("machine configuration requires both vcpu_count and mem_size_mib")
     }
-
-    tflog.Debug(ctx, "Firecracker API request successful", map[string]interface{}{
-        "url":    url,
-        "status": resp.StatusCode,
-    })
-
-    return nil
+    return sdk.MachineConfig{VCPUCount: vcpuCount, MemSizeMiB: memSizeMiB}, nil
 }
 
-// StartVM sends a request to start a Firecracker VM
-func (c *FirecrackerClient) StartVM(ctx context.Context, vmID string) error {
-    url := fmt.Sprintf("%s/vm/%s/actions", c.BaseURL, vmID)
-    tflog.Debug(ctx, "Starting VM", map[string]interface{}{
-        "url": url,
-        "id":  vmID,
-    })
-
-    payload := map[string]interface{}{
-        "action_type": "InstanceStart",
-    }
-
-    jsonPayload, err := json.Marshal(payload)
+// apiVsock converts the vsock device of a VM's configuration to the device configured in
+// the API.
+func apiVsock(raw map[string]interface{}) (sdk.Vsock, error) {
+    guestCID, err := coerceInt(raw["guest_cid"])
     if err != nil {
-        return fmt.Errorf("failed to marshal VM start payload: %w", err)
+        return sdk.Vsock{}, fmt.Errorf("invalid vsock guest_cid: %w", err)
     }
-
-    req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewBuffer(jsonPayload))
+    udsPath, err := requiredString(raw, "uds_path")
     if err != nil {
-        return fmt.Errorf("failed to create HTTP request for VM start: %w", err)
+        return sdk.Vsock{}, fmt.Errorf("invalid vsock: %w", err)
     }
-    req.Header.Set("Content-Type", "application/json")
+    return sdk.Vsock{GuestCID: guestCID, UDSPath: udsPath}, nil
+}
 
-    client := c.HTTPClient
-    if client == nil {
-        client = defaultHTTPClient()
+// apiMMDSConfig converts the MMDS configuration of a VM's configuration to the one of the
+// API.
+func apiMMDSConfig(raw map[string]interface{}) (sdk.MMDSConfig, error) {
+    version, _ := raw["version"].(string)
+    address, _ := raw["ipv4_address"].(string)
+    config := sdk.MMDSConfig{Version: version, IPv4Address: address, NetworkInterfaces: []string{}}
+    switch ifaceIDs := raw["network_interfaces"].(type) {
+    case []string:
+        config.NetworkInterfaces = append(config.NetworkInterfaces, ifaceIDs...)
+    case []interface{}:
+        for _, id := range ifaceIDs {
+            ifaceID, ok := id.(string)
+            if !ok {
+                return sdk.MMDSConfig{}, fmt.Errorf("invalid MMDS network interface of type %T", id)
+            }
+            config.NetworkInterfaces = append(config.NetworkInterfaces, ifaceID)
+        }
     }
+    return config, nil
+}
 
-    resp, err := client.Do(req)
+// holdAPI locks the client's API for a batch of requests, as the request limiter's hold
+// does. Within a batch holding it already, the batch is extended.
+func (c *FirecrackerClient) holdAPI(ctx context.Context) (context.Context, func(), error) {
+    endpoint, err := url.Parse(c.BaseURL)
     if err != nil {
-        return fmt.Errorf("failed to send VM start request: %w", err)
+        return nil, nil, fmt.Errorf("invalid base URL %q: %w", c.BaseURL, err)
     }
-    defer resp.Body.Close()
+    return c.requests.hold(ctx, endpoint.Host)
+}
 
-    if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-        body, _ := io.ReadAll(resp.Body)
-        return fmt.Errorf("API error when starting VM: status=%d, response=%s", resp.StatusCode, string(body))
+// putComponents configures components that do not depend on each other, sending up to
+// componentParallelism requests at a time. Other requests to the API wait until all are
+// sent, so they cannot interleave with them. The errors of all components that failed are
+// returned.
+func (c *FirecrackerClient) putComponents(ctx context.Context, components []apiComponent) error {
+    if len(components) == 0 {
+        return nil
     }
+    ctx, release, err := c.holdAPI(ctx)
+    if err != nil {
+        return err
+    }
+    defer release()
+
+    errs := make([]error, len(components))
+    slots := make(chan struct{}, componentParallelism)
+    var wg sync.WaitGroup
+    for i, component := range components {
+        wg.Add(1)
+        slots <- struct{}{}
+        go func(i int, component apiComponent) {
+            defer wg.Done()
+            defer func() { <-slots }()
+            if err := c.callAPI(ctx, http.MethodPut, component.Path, component.Put); err != nil {
+                errs[i] = fmt.Errorf("failed to configure %s: %w", component.Name, err)
+            }
+        }(i, component)
+    }
+    wg.Wait()
+    return errors.Join(errs...)
+}
 
-    tflog.Info(ctx, "VM started successfully", map[string]interface{}{
-        "id": vmID,
-    })
-    
-    return nil
+// Helper method to send PATCH requests to change components after the VM started
+func (c *FirecrackerClient) patchComponent(ctx context.Context, url string, payload interface{}) error {
+    return c.sendComponent(ctx, http.MethodPatch, url, payload)
 }
 
-// StopVM sends a request to stop a Firecracker VM
-func (c *FirecrackerClient) StopVM(ctx context.Context, vmID string) error {
-    url := fmt.Sprintf("%s/vm/%s/actions", c.BaseURL, vmID)
-    tflog.Debug(ctx, "Stopping VM", map[string]interface{}{
-        "url": url,
-        "id":  vmID,
+// Helper method to send a component to the API with a PUT or PATCH request
+func (c *FirecrackerClient) sendComponent(ctx context.Context, method, url string, payload interface{}) error {
+    path := strings.TrimPrefix(url, c.BaseURL)
+    return c.callAPI(ctx, method, path, func(ctx context.Context, api *sdk.Client) error {
+        if method == http.MethodPatch {
+            return api.Patch(ctx, path, payload)
+        }
+        return api.Put(ctx, path, payload)
     })
+}
 
-    payload := map[string]interface{}{
-        "action_type": "SendCtrlAltDel",
-    }
+// callAPI sends the request of call, a method of the Go client for the API, within the span
+// of a request to path. Errors sending the request, as opposed to Firecracker rejecting it,
+// are wrapped.
+func (c *FirecrackerClient) callAPI(ctx context.Context, method, path string, call func(ctx context.Context, api *sdk.Client) error) error {
+    ctx, span := c.startAPISpan(ctx, method, path)
+    err := call(ctx, c.api())
+    endAPISpan(span, err)
 
-    jsonPayload, err := json.Marshal(payload)
-    if err != nil {
-        return fmt.Errorf("failed to marshal VM stop payload: %w", err)
+    var apiErr *apiError
+    if err != nil && !errors.As(err, &apiErr) {
+        return fmt.Errorf("failed to send request: %w", err)
     }
+    return err
+}
 
-    req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewBuffer(jsonPayload))
-    if err != nil {
-        return fmt.Errorf("failed to create HTTP request for VM stop: %w", err)
+// api returns the Go client for the API. Its requests are sent through the provider's
+// request limits and retry policy, and logged.
+func (c *FirecrackerClient) api() *sdk.Client {
+    return sdk.New(c.BaseURL, sdk.WithHTTPClient(sdk.DoerFunc(c.doLogged)))
+}
+
+// doLogged sends a request with do, logging requests that change the VM along with their
+// outcome. Payloads are redacted, since they may hold secrets.
+func (c *FirecrackerClient) doLogged(req *http.Request) (*http.Response, error) {
+    if req.Method == http.MethodGet {
+        return c.do(req)
     }
-    req.Header.Set("Content-Type", "application/json")
 
-    client := c.HTTPClient
-    if client == nil {
-        client = defaultHTTPClient()
+    ctx := req.Context()
+    url := req.URL.String()
+    var payload string
+    if req.Body != nil {
+        body, err := io.ReadAll(req.Body)
+        req.Body.Close()
+        if err != nil {
+            return nil, fmt.Errorf("failed to read request payload: %w", err)
+        }
+        req.Body = io.NopCloser(bytes.NewReader(body))
+        payload = c.redactRequestPayload(url, body)
     }
 
-    resp, err := client.Do(req)
+    tflog.Debug(ctx, fmt.Sprintf("Sending %s request to Firecracker API", req.Method), map[string]interface{}{
+        "url": url,
+        "payload": payload,
+    })
+
+    resp, err := c.do(req)
     if err != nil {
-        return fmt.Errorf("failed to send VM stop request: %w", err)
+        tflog.Error(ctx, "Failed to send request to Firecracker API", map[string]interface{}{
+            "url":     url,
+            "error":   err.Error(),
+            "payload": payload,
+        })
+        return nil, err
     }
-    defer resp.Body.Close()
 
     if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
         body, _ := io.ReadAll(resp.Body)
-        return fmt.Errorf("API error when stopping VM: status=%d, response=%s", resp.StatusCode, string(body))
+        resp.Body.Close()
+        resp.Body = io.NopCloser(bytes.NewReader(body))
+        tflog.Error(ctx, "Firecracker API error", map[string]interface{}{
+            "url":             url,
+            "status":          resp.StatusCode,
+            "response":        string(body),
+            "request_payload": payload,
+            "headers":         resp.Header,
+        })
+        return resp, nil
     }
 
-    tflog.Info(ctx, "VM stop signal sent successfully", map[string]interface{}{
-        "id": vmID,
+    tflog.Debug(ctx, "Firecracker API request successful", map[string]interface{}{
+        "url":    url,
+        "status": resp.StatusCode,
     })
-    
-    return nil
+    return resp, nil
 }
 
 // GetVM retrieves information about a VM from the Firecracker API.
@@ -456,258 +580,142 @@ func (c *FirecrackerClient) GetVM(ctx context.Context, vmID string) (map[string]
         "vm-id": vmID,
     }
     
-    // Try to get machine config as a test to see if the VM exists
-    url := fmt.Sprintf("%s/machine-config", c.BaseURL)
-    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-    if err != nil {
-        return nil, fmt.Errorf("failed to create HTTP request: %w", err)
-    }
-    
-    client := c.HTTPClient
-    if client == nil {
-        client = defaultHTTPClient()
-    }
-    
-    resp, err := client.Do(req)
-    if err != nil {
-        // If we can't connect, assume the VM doesn't exist
-        tflog.Warn(ctx, "Failed to connect to Firecracker API, assuming VM doesn't exist", map[string]interface{}{
+    // Firecracker reports the state of its VM at GET /, which says the VM exists
+    info, err := c.GetInstanceInfo(ctx)
+    if errors.Is(err, errHostUnreachable) {
+        // Not being able to connect says nothing about whether the VM exists,
+        // so let the caller decide how to treat an unreachable host
+        tflog.Warn(ctx, "Failed to connect to Firecracker API", map[string]interface{}{
             "id": vmID,
             "error": err.Error(),
         })
-        return nil, nil
+        return nil, err
     }
-    defer resp.Body.Close()
-    
-    body, _ := io.ReadAll(resp.Body)
-    
-    // If we get a 200 OK, that's great! We can use the machine config
-    if resp.StatusCode == http.StatusOK {
-        var machineConfig map[string]interface{}
-        if err := json.Unmarshal(body, &machineConfig); err != nil {
-            return nil, fmt.Errorf("failed to parse machine config: %w", err)
-        }
-        
-        result["machine-config"] = machineConfig
-        
-        // Now try to get boot source info
-        bootSourceURL := fmt.Sprintf("%s/boot-source", c.BaseURL)
-        bootSource, err := c.getComponent(ctx, bootSourceURL)
-        if err != nil {
-            tflog.Warn(ctx, "Failed to get boot source info, using defaults", map[string]interface{}{
-                "error": err.Error(),
-            })
-            // Use empty map as fallback
-            bootSource = map[string]interface{}{}
-        }
-        result["boot-source"] = bootSource
-        
-        // Try to get drives info
-        drivesURL := fmt.Sprintf("%s/drives", c.BaseURL)
-        drives, err := c.listComponents(ctx, drivesURL)
+    if info != nil {
+        result["instance-info"] = info
+        machineConfig, err := c.getComponent(ctx, fmt.Sprintf("%s/machine-config", c.BaseURL))
         if err != nil {
-            tflog.Warn(ctx, "Failed to get drives info, using defaults", map[string]interface{}{
-                "error": err.Error(),
-            })
-            // Use empty list as fallback
-            drives = []interface{}{}
+            return nil, fmt.Errorf("failed to get machine config: %w", err)
         }
-        result["drives"] = drives
-        
-        // Try to get network interfaces
-        networkURL := fmt.Sprintf("%s/network-interfaces", c.BaseURL)
-        networkInterfaces, err := c.listComponents(ctx, networkURL)
-        if err != nil {
-            tflog.Warn(ctx, "Failed to get network interfaces info, using defaults", map[string]interface{}{
-                "error": err.Error(),
-            })
-            // Use empty list as fallback
-            networkInterfaces = []interface{}{}
+        if len(machineConfig) > 0 {
+            result["machine-config"] = machineConfig
         }
-        result["network-interfaces"] = networkInterfaces
-        
+        c.readVMComponents(ctx, result)
+        return result, nil
+    }
+    
+    // Older APIs and proxies not serving GET / are probed with the machine config instead
+    var machineConfig map[string]interface{}
+    err = c.api().Get(ctx, "/machine-config", &machineConfig)
+    var apiErr *apiError
+    switch {
+    case err == nil:
+        result["machine-config"] = machineConfig
+        c.readVMComponents(ctx, result)
         tflog.Info(ctx, "VM exists and machine config retrieved", map[string]interface{}{
             "id": vmID,
         })
-        
+        return result, nil
+    case !errors.As(err, &apiErr):
+        // Not being able to connect says nothing about whether the VM exists,
+        // so let the caller decide how to treat an unreachable host
+        tflog.Warn(ctx, "Failed to connect to Firecracker API", map[string]interface{}{
+            "id": vmID,
+            "error": err.Error(),
+        })
+        return nil, fmt.Errorf("%w: %v", errHostUnreachable, err)
+    case apiErr.StatusCode == http.StatusBadRequest && (apiErr.FaultMessage != "" || apiErr.Body != ""):
+        // The API is responding, but not to GET on this endpoint. The VM exists, but its
+        // config cannot be retrieved. Nothing is made up: the caller keeps what the Terraform
+        // state and the VM registry know about it
+        tflog.Info(ctx, "VM exists but detailed config cannot be retrieved from API", map[string]interface{}{
+            "id": vmID,
+        })
         return result, nil
     }
-    
-    // If we get a 400 error with "Invalid request method", that's also good!
-    // It means the API is responding, but we're using the wrong method (which is expected for some endpoints)
-    if resp.StatusCode == http.StatusBadRequest {
-        // Check if the error message indicates the API is working but method is wrong
-        if string(body) != "" {
-            // The VM exists, but we can't get its config directly
-            // We'll return what we know from the Terraform state
-            
-            // For a real implementation, you might want to:
-            // 1. Store VM configurations in a separate database
-            // 2. Use the Firecracker API's specific endpoints with correct methods
-            // 3. Implement a custom API layer on top of Firecracker
-            
-            // For now, we'll just return a minimal structure and let Terraform use its state
-            machineConfig := map[string]interface{}{
-                "vcpu_count":   4,  // Default values
-                "mem_size_mib": 1024,
-            }
-            result["machine-config"] = machineConfig
-            
-            // Add empty structures for other components
-            result["boot-source"] = map[string]interface{}{}
-            result["drives"] = []interface{}{}
-            result["network-interfaces"] = []interface{}{}
-            
-            tflog.Info(ctx, "VM exists but detailed config cannot be retrieved from API", map[string]interface{}{
-                "id": vmID,
-            })
-            
-            return result, nil
-        }
-    }
-    
-    // If we get here, something unexpected happened
-    return nil, fmt.Errorf("unexpected response from Firecracker API: status=%d, body=%s", resp.StatusCode, string(body))
+    return nil, fmt.Errorf("unexpected response from Firecracker API: %w", err)
 }
 
-// Helper method to get a component from the API
-func (c *FirecrackerClient) getComponent(ctx context.Context, url string) (map[string]interface{}, error) {
-    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// readVMComponents adds the boot source of the VM to result, falling back to an empty one
+// when the API does not report it. Firecracker has no endpoints listing the drives and
+// network interfaces, so they are left to GetVMConfig.
+func (c *FirecrackerClient) readVMComponents(ctx context.Context, result map[string]interface{}) {
+    bootSource, err := c.getComponent(ctx, fmt.Sprintf("%s/boot-source", c.BaseURL))
     if err != nil {
-        return nil, fmt.Errorf("failed to create HTTP request: %w", err)
-    }
-
-    client := c.HTTPClient
-    if client == nil {
-        client = defaultHTTPClient()
+        tflog.Warn(ctx, "Failed to get boot source info, using defaults", map[string]interface{}{
+            "error": err.Error(),
+        })
+        bootSource = map[string]interface{}{}
     }
+    result["boot-source"] = bootSource
+}
 
-    resp, err := client.Do(req)
+// GetVMConfig returns the full configuration of the VM, as reported by GET /vm/config, or
+// nil when the Firecracker release serving the API does not report it.
+func (c *FirecrackerClient) GetVMConfig(ctx context.Context) (map[string]interface{}, error) {
+    config, err := c.getComponent(ctx, fmt.Sprintf("%s/vm/config", c.BaseURL))
     if err != nil {
-        return nil, fmt.Errorf("failed to send request: %w", err)
+        return nil, fmt.Errorf("failed to get VM configuration: %w", err)
     }
-    defer resp.Body.Close()
-
-    if resp.StatusCode == http.StatusNotFound {
-        return nil, nil // Component not found
+    if len(config) == 0 {
+        return nil, nil
     }
+    return config, nil
+}
 
-    body, _ := io.ReadAll(resp.Body)
-    if resp.StatusCode != http.StatusOK {
+// Helper method to get a component from the API
+func (c *FirecrackerClient) getComponent(ctx context.Context, url string) (map[string]interface{}, error) {
+    var result map[string]interface{}
+    path := strings.TrimPrefix(url, c.BaseURL)
+    ctx, span := c.startAPISpan(ctx, http.MethodGet, path)
+    err := c.api().Get(ctx, path, &result)
+    endAPISpan(span, err)
+
+    var apiErr *apiError
+    switch {
+    case sdk.IsNotFound(err):
+        return nil, nil // Component not found
+    case sdk.IsStatus(err, http.StatusBadRequest):
         // If we get a 400 error, it might be because GET is not supported
         // We'll just return an empty map in this case
-        if resp.StatusCode == http.StatusBadRequest {
-            return map[string]interface{}{}, nil
-        }
-        return nil, fmt.Errorf("API error: status=%d, response=%s", resp.StatusCode, string(body))
-    }
-
-    var result map[string]interface{}
-    if err := json.Unmarshal(body, &result); err != nil {
-        return nil, fmt.Errorf("failed to parse response: %w", err)
+        return map[string]interface{}{}, nil
+    case errors.As(err, &apiErr):
+        return nil, err
+    case err != nil:
+        return nil, fmt.Errorf("failed to send request: %w", err)
     }
-
     return result, nil
 }
 
-// Helper method to list components from the API
-func (c *FirecrackerClient) listComponents(ctx context.Context, baseURL string) ([]interface{}, error) {
-    // This is a simplified implementation - in a real scenario, you might need to
-    // query each component individually
-    
-    // For now, we'll just return an empty list to avoid errors
-    return []interface{}{}, nil
-}
-
-// These helper methods are no longer used with our new GetVM implementation
-
-// DeleteVM sends a request to delete a Firecracker VM.
-// If the VM doesn't exist, it returns nil to indicate successful deletion.
-// This method is used by the Delete operation of the resource.
+// DeleteVM shuts a Firecracker VM down as part of deleting it. Deleting is best effort: a
+// VM whose API cannot be reached is assumed to be gone already, and one that refuses to shut
+// down is left to the termination of its Firecracker process.
 func (c *FirecrackerClient) DeleteVM(ctx context.Context, vmID string) error {
-    // For Firecracker, there's no direct "delete VM" endpoint
-    // Instead, we'll try to shut down the VM gracefully
-    
     tflog.Debug(ctx, "Attempting to shut down VM as part of deletion", map[string]interface{}{
         "id": vmID,
     })
-    
-    // First, try to send a shutdown action
-    url := fmt.Sprintf("%s/actions", c.BaseURL)
-    payload := map[string]interface{}{
-        "action_type": "SendCtrlAltDel",
-    }
-    
-    jsonPayload, err := json.Marshal(payload)
-    if err != nil {
-        return fmt.Errorf("failed to marshal shutdown payload: %w", err)
-    }
-    
-    req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewBuffer(jsonPayload))
-    if err != nil {
-        return fmt.Errorf("failed to create HTTP request for VM shutdown: %w", err)
-    }
-    req.Header.Set("Content-Type", "application/json")
-    
-    client := c.HTTPClient
-    if client == nil {
-        client = defaultHTTPClient()
-    }
-    
-    resp, err := client.Do(req)
-    if err != nil {
-        // If we can't connect, assume the VM is already gone
+
+    err := c.callAPI(ctx, http.MethodPut, "/actions", func(ctx context.Context, api *sdk.Client) error {
+        return api.Action(ctx, sdk.ActionSendCtrlAltDel)
+    })
+    var apiErr *apiError
+    switch {
+    case errors.As(err, &apiErr):
+        tflog.Warn(ctx, "Received non-success status when shutting down VM", map[string]interface{}{
+            "id":     vmID,
+            "status": apiErr.StatusCode,
+            "error":  err.Error(),
+        })
+    case err != nil:
         tflog.Warn(ctx, "Failed to connect to Firecracker API, assuming VM is already gone", map[string]interface{}{
-            "id": vmID,
+            "id":    vmID,
             "error": err.Error(),
         })
         return nil
     }
-    defer resp.Body.Close()
-    
-    // Check response - we'll consider any response as "good enough" for deletion
-    // since we're just trying to clean up as best we can
-    body, _ := io.ReadAll(resp.Body)
-    if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-        tflog.Warn(ctx, "Received non-success status when shutting down VM", map[string]interface{}{
-            "id": vmID,
-            "status": resp.StatusCode,
-            "body": string(body),
-        })
-        // We'll continue anyway - this is best effort
-    }
-    
-    // For Firecracker, the actual VM process termination would typically be handled
-    // by the host system (e.g., killing the Firecracker process)
-    // Here we're just considering the VM "deleted" from Terraform's perspective
-    
-    tflog.Info(ctx, "VM deletion process completed", map[string]interface{}{
-        "id": vmID,
-    })
-    
-    return nil
-}
 
-// UpdateVM sends a request to update a Firecracker VM.
-// It takes a VM ID and a configuration map containing the properties to update.
-// This method is used by the Update operation of the resource.
-func (c *FirecrackerClient) UpdateVM(ctx context.Context, vmID string, config map[string]interface{}) error {
-    // For Firecracker, we can't update most VM properties after creation
-    // Instead, we'll log a warning and return success
-    
-    tflog.Warn(ctx, "Firecracker doesn't support updating most VM properties after creation", map[string]interface{}{
-        "id": vmID,
-    })
-    
-    // For a real implementation, you might want to:
-    // 1. Store VM configurations in a separate database
-    // 2. Implement a custom API layer on top of Firecracker
-    // 3. Destroy and recreate the VM with new settings
-    
-    // For now, we'll just return success and let Terraform handle the state
-    tflog.Info(ctx, "VM update operation completed (no changes applied)", map[string]interface{}{
+    tflog.Info(ctx, "VM deletion process completed", map[string]interface{}{
         "id": vmID,
     })
-    
     return nil
 }
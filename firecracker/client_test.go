@@ -3,30 +3,40 @@ package firecracker
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	sdk "github.com/avkcode/terraform-provider-firecracker/client"
+	"github.com/avkcode/terraform-provider-firecracker/firecrackertest"
 )
 
 func TestCreateVM(t *testing.T) {
-	// Create a mock HTTP client
+	kernelPath := filepath.Join(t.TempDir(), "vmlinux")
+	if err := os.WriteFile(kernelPath, []byte("kernel"), 0o644); err != nil {
+		t.Fatalf("failed to write kernel image: %v", err)
+	}
+
+	// Record the sequence of API calls made while creating the VM
+	var calls []string
 	mockClient := &mockHTTPClient{
 		DoFunc: func(req *http.Request) (*http.Response, error) {
-			// Check if the request is as expected
-			if req.Method != http.MethodPost {
-				t.Errorf("Expected POST request, got %s", req.Method)
-			}
-			if req.URL.String() != "http://localhost:8080/vm" {
-				t.Errorf("Expected URL http://localhost:8080/vm, got %s", req.URL.String())
+			// Every component is configured with a JSON PUT
+			if req.Method != http.MethodPut {
+				t.Errorf("Expected PUT request, got %s", req.Method)
 			}
 			if req.Header.Get("Content-Type") != "application/json" {
 				t.Errorf("Expected Content-Type application/json, got %s", req.Header.Get("Content-Type"))
 			}
+			calls = append(calls, req.URL.Path)
 
 			// Return a successful response
 			return &http.Response{
-				StatusCode: http.StatusCreated,
+				StatusCode: http.StatusNoContent,
 				Body:       io.NopCloser(bytes.NewBufferString("")),
 			}, nil
 		},
@@ -41,11 +51,11 @@ func TestCreateVM(t *testing.T) {
 	// Create a VM
 	config := map[string]interface{}{
 		"boot-source": map[string]interface{}{
-			"kernel_image_path": "/path/to/vmlinux",
+			"kernel_image_path": kernelPath,
 			"boot_args":         "console=ttyS0 reboot=k panic=1 pci=off",
 		},
-		"drives": []map[string]interface{}{
-			{
+		"drives": []interface{}{
+			map[string]interface{}{
 				"drive_id":       "rootfs",
 				"path_on_host":   "/path/to/rootfs.ext4",
 				"is_root_device": true,
@@ -63,6 +73,182 @@ func TestCreateVM(t *testing.T) {
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
+
+	expected := []string{"/boot-source", "/machine-config", "/drives/rootfs", "/actions"}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected calls %v, got %v", expected, calls)
+	}
+}
+
+func TestCreateVM_mmds(t *testing.T) {
+	kernelPath := filepath.Join(t.TempDir(), "vmlinux")
+	if err := os.WriteFile(kernelPath, []byte("kernel"), 0o644); err != nil {
+		t.Fatalf("failed to write kernel image: %v", err)
+	}
+
+	var calls []string
+	mockClient := &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			calls = append(calls, req.URL.Path)
+			if req.URL.Path == "/mmds" {
+				body, _ := io.ReadAll(req.Body)
+				if !strings.Contains(string(body), `"timezone":"UTC"`) {
+					t.Errorf("Expected MMDS body to contain the timezone, got %s", string(body))
+				}
+			}
+			return &http.Response{
+				StatusCode: http.StatusNoContent,
+				Body:       io.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		},
+	}
+
+	client := &FirecrackerClient{
+		BaseURL:    "http://localhost:8080",
+		HTTPClient: mockClient,
+	}
+
+	config := map[string]interface{}{
+		"boot-source": map[string]interface{}{
+			"kernel_image_path": kernelPath,
+		},
+		"mmds-config": map[string]interface{}{
+			"network_interfaces": []string{"eth0"},
+		},
+		"mmds": map[string]interface{}{
+			"firecracker": map[string]interface{}{"timezone": "UTC"},
+		},
+	}
+
+	if err := client.CreateVM(context.Background(), config); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	// MMDS must be configured and populated before the VM starts
+	expected := []string{"/boot-source", "/mmds/config", "/mmds", "/actions"}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected calls %v, got %v", expected, calls)
+	}
+}
+
+func TestCreateVM_partuuid(t *testing.T) {
+	kernelPath := filepath.Join(t.TempDir(), "vmlinux")
+	if err := os.WriteFile(kernelPath, []byte("kernel"), 0o644); err != nil {
+		t.Fatalf("failed to write kernel image: %v", err)
+	}
+
+	httpClient, err := fakeAPIClient("fake://test", t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create fake API client: %v", err)
+	}
+	client := &FirecrackerClient{BaseURL: "fake://test", HTTPClient: httpClient}
+
+	config := map[string]interface{}{
+		"boot-source": map[string]interface{}{
+			"kernel_image_path": kernelPath,
+		},
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/disk.img", "is_root_device": true, "is_read_only": false, "partuuid": "1a2b3c4d-02"},
+			map[string]interface{}{"drive_id": "data", "path_on_host": "/images/data.img", "is_root_device": false, "is_read_only": false, "partuuid": "1a2b3c4d-03"},
+			map[string]interface{}{"drive_id": "scratch", "path_on_host": "/images/scratch.img", "is_root_device": false, "is_read_only": false},
+		},
+	}
+	if err := client.CreateVM(context.Background(), config); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	vmConfig, err := client.GetVMConfig(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	partUUIDs := map[interface{}]interface{}{}
+	for _, raw := range vmConfig["drives"].([]interface{}) {
+		drive := raw.(map[string]interface{})
+		partUUIDs[drive["drive_id"]] = drive["partuuid"]
+	}
+	expected := map[interface{}]interface{}{"rootfs": "1a2b3c4d-02", "data": "1a2b3c4d-03", "scratch": nil}
+	if len(partUUIDs) != len(expected) {
+		t.Fatalf("Expected drives %v, got %v", expected, partUUIDs)
+	}
+	for id, partUUID := range expected {
+		if partUUIDs[id] != partUUID {
+			t.Errorf("Expected drive %s to have partuuid %v, got %v", id, partUUID, partUUIDs[id])
+		}
+	}
+}
+
+func TestCreateVM_devices(t *testing.T) {
+	ctx := context.Background()
+	kernelPath := filepath.Join(t.TempDir(), "vmlinux")
+	if err := os.WriteFile(kernelPath, []byte("kernel"), 0o644); err != nil {
+		t.Fatalf("failed to write kernel image: %v", err)
+	}
+
+	// Without a machine_config block, Firecracker's defaults are kept
+	server := firecrackertest.NewServer(t)
+	client := &FirecrackerClient{BaseURL: server.URL, HTTPClient: http.DefaultClient}
+	config := map[string]interface{}{
+		"boot-source":    map[string]interface{}{"kernel_image_path": kernelPath},
+		"machine-config": map[string]interface{}{},
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true},
+		},
+		"network-interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+		},
+		"vsock":       map[string]interface{}{"guest_cid": 3, "uds_path": "/run/vsock.sock"},
+		"entropy":     map[string]interface{}{},
+		"cpu-config":  map[string]interface{}{"msr_modifiers": []interface{}{map[string]interface{}{"addr": "0x10a", "bitmap": "0b0"}}},
+		"mmds-config": map[string]interface{}{"version": "V2", "network_interfaces": []string{"eth0"}},
+		"mmds":        map[string]interface{}{"role": "web"},
+	}
+	if err := client.CreateVM(ctx, config); err != nil {
+		t.Fatalf("Failed to create VM: %v", err)
+	}
+
+	vm := server.Config()
+	if vcpus := server.MachineConfig()["vcpu_count"]; vcpus != 1 {
+		t.Errorf("Expected the default machine configuration, got %v", server.MachineConfig())
+	}
+	if vsock, _ := vm["vsock"].(map[string]interface{}); vsock["guest_cid"] != float64(3) || vsock["uds_path"] != "/run/vsock.sock" {
+		t.Errorf("Expected the vsock device to be configured, got %v", vm["vsock"])
+	}
+	for _, key := range []string{"entropy", "cpu-config"} {
+		if vm[key] == nil {
+			t.Errorf("Expected %s to be configured, got %v", key, vm)
+		}
+	}
+	if mmdsConfig, _ := vm["mmds-config"].(map[string]interface{}); mmdsConfig["version"] != "V2" {
+		t.Errorf("Expected MMDS to be configured, got %v", vm["mmds-config"])
+	}
+	if contents, _ := server.MMDS().(map[string]interface{}); contents["role"] != "web" {
+		t.Errorf("Expected MMDS to be populated, got %v", server.MMDS())
+	}
+}
+
+func TestCreateVM_missingKernel(t *testing.T) {
+	mockClient := &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			t.Errorf("Expected no API calls, got %s %s", req.Method, req.URL.String())
+			return nil, nil
+		},
+	}
+
+	client := &FirecrackerClient{
+		BaseURL:    "http://localhost:8080",
+		HTTPClient: mockClient,
+	}
+
+	config := map[string]interface{}{
+		"boot-source": map[string]interface{}{
+			"kernel_image_path": "/path/that/does/not/exist/vmlinux",
+		},
+	}
+
+	err := client.CreateVM(context.Background(), config)
+	if err == nil || !strings.Contains(err.Error(), "kernel image file does not exist") {
+		t.Errorf("Expected missing kernel error, got %v", err)
+	}
 }
 
 func TestGetVM(t *testing.T) {
@@ -73,31 +259,26 @@ func TestGetVM(t *testing.T) {
 			if req.Method != http.MethodGet {
 				t.Errorf("Expected GET request, got %s", req.Method)
 			}
-			if req.URL.String() != "http://localhost:8080/vm/test-vm" {
-				t.Errorf("Expected URL http://localhost:8080/vm/test-vm, got %s", req.URL.String())
+
+			var body string
+			switch req.URL.String() {
+			case "http://localhost:8080/":
+				body = `{"id": "test-vm", "state": "Running", "vmm_version": "1.10.1", "app_name": "Firecracker"}`
+			case "http://localhost:8080/machine-config":
+				body = `{"vcpu_count": 2, "mem_size_mib": 1024}`
+			case "http://localhost:8080/boot-source":
+				body = `{
+					"kernel_image_path": "/path/to/vmlinux",
+					"boot_args": "console=ttyS0 reboot=k panic=1 pci=off"
+				}`
+			default:
+				t.Errorf("Unexpected URL %s", req.URL.String())
 			}
 
-			// Return a successful response with VM info
+			// Return a successful response with component info
 			return &http.Response{
 				StatusCode: http.StatusOK,
-				Body: io.NopCloser(bytes.NewBufferString(`{
-					"boot-source": {
-						"kernel_image_path": "/path/to/vmlinux",
-						"boot_args": "console=ttyS0 reboot=k panic=1 pci=off"
-					},
-					"drives": [
-						{
-							"drive_id": "rootfs",
-							"path_on_host": "/path/to/rootfs.ext4",
-							"is_root_device": true,
-							"is_read_only": false
-						}
-					],
-					"machine-config": {
-						"vcpu_count": 2,
-						"mem_size_mib": 1024
-					}
-				}`)),
+				Body:       io.NopCloser(bytes.NewBufferString(body)),
 				Header: http.Header{
 					"Content-Type": []string{"application/json"},
 				},
@@ -125,60 +306,85 @@ func TestGetVM(t *testing.T) {
 	if bootSource["kernel_image_path"] != "/path/to/vmlinux" {
 		t.Errorf("Expected kernel_image_path to be /path/to/vmlinux, got %s", bootSource["kernel_image_path"])
 	}
+	machineConfig, ok := vmInfo["machine-config"].(map[string]interface{})
+	if !ok {
+		t.Errorf("Expected machine-config to be a map, got %T", vmInfo["machine-config"])
+	}
+	if machineConfig["vcpu_count"] != float64(2) {
+		t.Errorf("Expected vcpu_count to be 2, got %v", machineConfig["vcpu_count"])
+	}
+	info, ok := vmInfo["instance-info"].(*sdk.InstanceInfo)
+	if !ok || info.State != "Running" || info.VMMVersion != "1.10.1" {
+		t.Errorf("Expected the instance info, got %#v", vmInfo["instance-info"])
+	}
 }
 
-func TestDeleteVM(t *testing.T) {
-	// Create a mock HTTP client
+func TestGetVM_withoutInstanceInfo(t *testing.T) {
+	// APIs not serving GET / are probed with the machine config
 	mockClient := &mockHTTPClient{
 		DoFunc: func(req *http.Request) (*http.Response, error) {
-			// Check if the request is as expected
-			if req.Method != http.MethodDelete {
-				t.Errorf("Expected DELETE request, got %s", req.Method)
+			switch req.URL.Path {
+			case "/":
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+			case "/machine-config":
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(`{"vcpu_count": 2, "mem_size_mib": 1024}`))}, nil
 			}
-			if req.URL.String() != "http://localhost:8080/vm/test-vm" {
-				t.Errorf("Expected URL http://localhost:8080/vm/test-vm, got %s", req.URL.String())
-			}
-
-			// Return a successful response
-			return &http.Response{
-				StatusCode: http.StatusNoContent,
-				Body:       io.NopCloser(bytes.NewBufferString("")),
-			}, nil
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString("{}"))}, nil
 		},
 	}
 
-	// Create a client with the mock HTTP client
 	client := &FirecrackerClient{
 		BaseURL:    "http://localhost:8080",
 		HTTPClient: mockClient,
 	}
 
-	// Delete VM
-	err := client.DeleteVM(context.Background(), "test-vm")
+	vmInfo, err := client.GetVM(context.Background(), "test-vm")
 	if err != nil {
-		t.Errorf("Expected no error, got %v", err)
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := vmInfo["instance-info"]; ok {
+		t.Errorf("Expected no instance info, got %v", vmInfo["instance-info"])
+	}
+	if machineConfig, _ := vmInfo["machine-config"].(map[string]interface{}); machineConfig["vcpu_count"] != float64(2) {
+		t.Errorf("Expected the machine config, got %v", vmInfo["machine-config"])
 	}
 }
 
-func TestUpdateVM(t *testing.T) {
+func TestGetVM_unreachable(t *testing.T) {
+	mockClient := &mockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("dial unix /tmp/firecracker.sock: connect: connection refused")
+		},
+	}
+
+	client := &FirecrackerClient{
+		BaseURL:    "http://localhost:8080",
+		HTTPClient: mockClient,
+	}
+
+	vmInfo, err := client.GetVM(context.Background(), "test-vm")
+	if !errors.Is(err, errHostUnreachable) {
+		t.Errorf("Expected errHostUnreachable, got %v", err)
+	}
+	if vmInfo != nil {
+		t.Errorf("Expected no VM info, got %v", vmInfo)
+	}
+}
+
+func TestDeleteVM(t *testing.T) {
 	// Create a mock HTTP client
 	mockClient := &mockHTTPClient{
 		DoFunc: func(req *http.Request) (*http.Response, error) {
-			// Check if the request is as expected
-			if req.Method != http.MethodPatch {
-				t.Errorf("Expected PATCH request, got %s", req.Method)
+			// Deleting a VM sends a graceful shutdown action
+			if req.Method != http.MethodPut {
+				t.Errorf("Expected PUT request, got %s", req.Method)
 			}
-			if req.URL.String() != "http://localhost:8080/vm/test-vm" {
-				t.Errorf("Expected URL http://localhost:8080/vm/test-vm, got %s", req.URL.String())
+			if req.URL.String() != "http://localhost:8080/actions" {
+				t.Errorf("Expected URL http://localhost:8080/actions, got %s", req.URL.String())
 			}
-			if req.Header.Get("Content-Type") != "application/json" {
-				t.Errorf("Expected Content-Type application/json, got %s", req.Header.Get("Content-Type"))
-			}
-
-			// Check request body
 			body, _ := io.ReadAll(req.Body)
-			if !strings.Contains(string(body), "machine-config") {
-				t.Errorf("Expected request body to contain machine-config, got %s", string(body))
+			if !strings.Contains(string(body), "SendCtrlAltDel") {
+				t.Errorf("Expected request body to contain SendCtrlAltDel, got %s", string(body))
 			}
 
 			// Return a successful response
@@ -195,16 +401,67 @@ func TestUpdateVM(t *testing.T) {
 		HTTPClient: mockClient,
 	}
 
-	// Update VM
+	// Delete VM
+	err := client.DeleteVM(context.Background(), "test-vm")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestClient_firecrackerLifecycle(t *testing.T) {
+	ctx := context.Background()
+	kernelPath := filepath.Join(t.TempDir(), "vmlinux")
+	if err := os.WriteFile(kernelPath, []byte("kernel"), 0o644); err != nil {
+		t.Fatalf("failed to write kernel image: %v", err)
+	}
+
+	source := firecrackertest.NewServer(t)
+	client := &FirecrackerClient{BaseURL: source.URL, HTTPClient: http.DefaultClient}
 	config := map[string]interface{}{
-		"machine-config": map[string]interface{}{
-			"vcpu_count":   4,
-			"mem_size_mib": 2048,
+		"boot-source": map[string]interface{}{"kernel_image_path": kernelPath},
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true, "is_read_only": false},
+			map[string]interface{}{"drive_id": "data", "path_on_host": "/images/placeholder.ext4", "is_root_device": false, "is_read_only": false},
 		},
+		"machine-config": map[string]interface{}{"vcpu_count": 2, "mem_size_mib": 1024},
+	}
+	if err := client.CreateVM(ctx, config); err != nil {
+		t.Fatalf("Failed to create VM: %v", err)
+	}
+	if state, err := client.GetInstanceState(ctx); err != nil || state != firecrackertest.StateRunning {
+		t.Errorf("Expected the VM to be running, got %q, %v", state, err)
 	}
 
-	err := client.UpdateVM(context.Background(), "test-vm", config)
-	if err != nil {
-		t.Errorf("Expected no error, got %v", err)
+	// A started VM cannot be configured again
+	if err := client.CreateVM(ctx, config); err == nil {
+		t.Error("Expected configuring a started VM to fail")
+	}
+
+	if err := client.UpdateDrivePath(ctx, "data", "/volumes/data.ext4"); err != nil {
+		t.Fatalf("Failed to swap drive: %v", err)
+	}
+	if drive, err := client.vmDrive(ctx, "data"); err != nil || drive["path_on_host"] != "/volumes/data.ext4" {
+		t.Errorf("Expected the drive to use the swapped image, got %v, %v", drive, err)
+	}
+
+	// A snapshot taken on one host restores the VM on another
+	dir := t.TempDir()
+	if err := client.CreateSnapshot(ctx, filepath.Join(dir, "vmstate"), filepath.Join(dir, "memory")); err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if source.State() != firecrackertest.StateRunning {
+		t.Errorf("Expected the VM to be resumed after the snapshot, got %s", source.State())
+	}
+
+	target := firecrackertest.NewServer(t)
+	restored := &FirecrackerClient{BaseURL: target.URL, HTTPClient: http.DefaultClient}
+	if err := restored.LoadSnapshot(ctx, filepath.Join(dir, "vmstate"), filepath.Join(dir, "memory"), true); err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if got := target.MachineConfig()["mem_size_mib"]; got != float64(1024) {
+		t.Errorf("Expected the restored VM to have 1024 MiB, got %v", got)
+	}
+	if target.State() != firecrackertest.StateRunning {
+		t.Errorf("Expected the restored VM to be running, got %s", target.State())
 	}
 }
@@ -0,0 +1,227 @@
+package firecracker
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    cloudInitDatasourceNoCloud = "nocloud"
+    cloudInitDatasourceMMDS    = "mmds"
+
+    // cloudInitDriveID is the ID of the drive holding the NoCloud seed image.
+    cloudInitDriveID = "cidata"
+)
+
+// seedImageTools are the commands that can build an ISO 9660 seed image, in order of preference.
+var seedImageTools = []string{"genisoimage", "mkisofs", "xorrisofs"}
+
+// lookPath finds a host command. It is a variable so tests can pretend tools are installed.
+var lookPath = exec.LookPath
+
+// cloudInitSpec is the cloud-init configuration of a VM.
+type cloudInitSpec struct {
+    Datasource    string
+    UserData      string
+    MetaData      map[string]string
+    PublicKeys    []string
+    NetworkConfig string
+}
+
+// cloudInitSpecFromConfig returns the cloud-init configuration of the VM's cloud_init block, if any.
+// The instance ID defaults to the VM ID so cloud-init runs once per VM, and the VM's
+// hostname and ssh_authorized_keys are handed to cloud-init as local-hostname and public-keys.
+func cloudInitSpecFromConfig(d *schema.ResourceData, vmID string) (cloudInitSpec, bool) {
+    raw := d.Get("cloud_init").([]interface{})
+    if len(raw) == 0 || raw[0] == nil {
+        return cloudInitSpec{}, false
+    }
+    cfg := raw[0].(map[string]interface{})
+
+    spec := cloudInitSpec{
+        Datasource:    cfg["datasource"].(string),
+        UserData:      cfg["user_data"].(string),
+        MetaData:      expandLabels(cfg["meta_data"].(map[string]interface{})),
+        PublicKeys:    authorizedKeysFromConfig(d),
+        NetworkConfig: cfg["network_config"].(string),
+    }
+    if spec.MetaData["instance-id"] == "" {
+        spec.MetaData["instance-id"] = vmID
+    }
+    if hostname, ok := d.GetOk("hostname"); ok && spec.MetaData["local-hostname"] == "" {
+        spec.MetaData["local-hostname"] = hostname.(string)
+    }
+    spec.UserData = withGuestSettingsUserData(spec.UserData, d.Get("timezone").(string), d.Get("locale").(string))
+
+    return spec, true
+}
+
+// guestSettingsBoundary separates the parts of user data combined by withGuestSettingsUserData.
+const guestSettingsBoundary = "==firecracker-guest-settings=="
+
+// withGuestSettingsUserData adds the VM's timezone and locale to its user data, as a
+// #cloud-config document cloud-init applies them from. User data of the VM's own is
+// combined with it in a MIME multi-part archive, after it, so settings of its own take
+// precedence. Its part is typed text/plain, which cloud-init types by its first line.
+func withGuestSettingsUserData(userData, timezone, locale string) string {
+    if timezone == "" && locale == "" {
+        return userData
+    }
+
+    var config strings.Builder
+    config.WriteString("#cloud-config\n")
+    if timezone != "" {
+        value, _ := json.Marshal(timezone)
+        fmt.Fprintf(&config, "timezone: %s\n", value)
+    }
+    if locale != "" {
+        value, _ := json.Marshal(locale)
+        fmt.Fprintf(&config, "locale: %s\n", value)
+    }
+    if userData == "" {
+        return config.String()
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=\"%s\"\nMIME-Version: 1.0\n\n", guestSettingsBoundary)
+    fmt.Fprintf(&b, "--%s\nContent-Type: text/cloud-config; charset=\"utf-8\"\nMIME-Version: 1.0\n\n%s\n", guestSettingsBoundary, config.String())
+    fmt.Fprintf(&b, "--%s\nContent-Type: text/plain; charset=\"utf-8\"\nMIME-Version: 1.0\n\n%s\n", guestSettingsBoundary, userData)
+    fmt.Fprintf(&b, "--%s--\n", guestSettingsBoundary)
+    return b.String()
+}
+
+// renderNoCloudMetaData renders meta-data as YAML with sorted keys, followed by the public keys.
+// Values are written as JSON strings, which YAML parses as plain strings.
+func renderNoCloudMetaData(metaData map[string]string, publicKeys []string) string {
+    keys := make([]string, 0, len(metaData))
+    for key := range metaData {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    var b strings.Builder
+    for _, key := range keys {
+        value, _ := json.Marshal(metaData[key])
+        fmt.Fprintf(&b, "%s: %s\n", key, value)
+    }
+    if len(publicKeys) > 0 {
+        b.WriteString("public-keys:\n")
+        for _, key := range publicKeys {
+            value, _ := json.Marshal(key)
+            fmt.Fprintf(&b, "  - %s\n", value)
+        }
+    }
+    return b.String()
+}
+
+// seedImagePath returns where the NoCloud seed image of a VM is kept.
+func seedImagePath(stateDir, vmID string) string {
+    return filepath.Join(stateDir, "cloud-init", vmID+".iso")
+}
+
+// buildSeedImage writes a NoCloud seed image, an ISO 9660 filesystem labelled "cidata"
+// holding the user-data, meta-data and network-config files cloud-init looks for.
+func buildSeedImage(ctx context.Context, path string, spec cloudInitSpec) error {
+    tool := ""
+    for _, candidate := range seedImageTools {
+        if _, err := lookPath(candidate); err == nil {
+            tool = candidate
+            break
+        }
+    }
+    if tool == "" {
+        return fmt.Errorf("building a cloud-init seed image requires one of %s on the host", strings.Join(seedImageTools, ", "))
+    }
+
+    dir, err := os.MkdirTemp("", "cidata")
+    if err != nil {
+        return fmt.Errorf("failed to create seed directory: %w", err)
+    }
+    defer os.RemoveAll(dir)
+    if err := trackArtifact(ctx, hostArtifact{Path: dir}); err != nil {
+        return err
+    }
+
+    files := map[string]string{
+        "user-data": spec.UserData,
+        "meta-data": renderNoCloudMetaData(spec.MetaData, spec.PublicKeys),
+    }
+    if spec.NetworkConfig != "" {
+        files["network-config"] = spec.NetworkConfig
+    }
+
+    args := []string{"-output", path, "-volid", "cidata", "-joliet", "-rock"}
+    names := make([]string, 0, len(files))
+    for name := range files {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        file := filepath.Join(dir, name)
+        if err := os.WriteFile(file, []byte(files[name]), 0o600); err != nil {
+            return fmt.Errorf("failed to write %s: %w", name, err)
+        }
+        args = append(args, file)
+    }
+
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return fmt.Errorf("failed to create seed image directory: %w", err)
+    }
+    if err := trackArtifact(ctx, hostArtifact{Path: path}); err != nil {
+        return err
+    }
+
+    if output, err := runCommand(ctx, tool, args...); err != nil {
+        return fmt.Errorf("failed to build cloud-init seed image: %w: %s", err, strings.TrimSpace(string(output)))
+    }
+
+    return nil
+}
+
+// removeSeedImage deletes a VM's seed image. A missing image is not an error.
+func removeSeedImage(path string) error {
+    if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("failed to remove cloud-init seed image: %w", err)
+    }
+    return nil
+}
+
+// cloudInitMMDSContents lays out the cloud-init data the way the EC2 instance metadata
+// service does, so cloud-init's EC2 datasource finds it under /latest.
+func cloudInitMMDSContents(spec cloudInitSpec) map[string]interface{} {
+    metaData := make(map[string]interface{}, len(spec.MetaData))
+    for key, value := range spec.MetaData {
+        metaData[key] = value
+    }
+    // EC2 lists public keys by index, each with its OpenSSH form
+    if len(spec.PublicKeys) > 0 {
+        publicKeys := map[string]interface{}{}
+        for i, key := range spec.PublicKeys {
+            publicKeys[strconv.Itoa(i)] = map[string]interface{}{
+                "openssh-key": key,
+            }
+        }
+        metaData["public-keys"] = publicKeys
+    }
+
+    latest := map[string]interface{}{
+        "meta-data": metaData,
+    }
+    if spec.UserData != "" {
+        latest["user-data"] = spec.UserData
+    }
+
+    return map[string]interface{}{
+        "latest": latest,
+    }
+}
@@ -0,0 +1,200 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestCloudInitSpecFromConfig(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"cloud_init": []interface{}{
+			map[string]interface{}{
+				"user_data": "#cloud-config\n",
+				"meta_data": map[string]interface{}{
+					"local-hostname": "web-1",
+				},
+			},
+		},
+	})
+
+	spec, ok := cloudInitSpecFromConfig(d, "vm-1")
+	if !ok {
+		t.Fatal("Expected a cloud-init spec")
+	}
+	if spec.Datasource != cloudInitDatasourceNoCloud {
+		t.Errorf("Expected the nocloud datasource by default, got %s", spec.Datasource)
+	}
+	if spec.MetaData["instance-id"] != "vm-1" || spec.MetaData["local-hostname"] != "web-1" {
+		t.Errorf("Unexpected meta-data %v", spec.MetaData)
+	}
+
+	expected := "instance-id: \"vm-1\"\nlocal-hostname: \"web-1\"\n"
+	if got := renderNoCloudMetaData(spec.MetaData, nil); got != expected {
+		t.Errorf("Expected meta-data %q, got %q", expected, got)
+	}
+}
+
+func TestCloudInitSpecFromConfig_personalization(t *testing.T) {
+	key := testAuthorizedKey(t)
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"hostname":            "web-2",
+		"ssh_authorized_keys": []interface{}{key},
+		"cloud_init": []interface{}{
+			map[string]interface{}{
+				"datasource": "mmds",
+			},
+		},
+	})
+
+	spec, _ := cloudInitSpecFromConfig(d, "vm-2")
+	if spec.MetaData["local-hostname"] != "web-2" {
+		t.Errorf("Expected local-hostname from hostname, got %v", spec.MetaData)
+	}
+	if !strings.Contains(renderNoCloudMetaData(spec.MetaData, spec.PublicKeys), "public-keys:\n  - \""+key+"\"\n") {
+		t.Errorf("Expected the key in the NoCloud meta-data")
+	}
+
+	metaData := cloudInitMMDSContents(spec)["latest"].(map[string]interface{})["meta-data"].(map[string]interface{})
+	publicKeys := metaData["public-keys"].(map[string]interface{})
+	if publicKeys["0"].(map[string]interface{})["openssh-key"] != key {
+		t.Errorf("Expected the key in the EC2 layout, got %v", publicKeys)
+	}
+
+	settings := guestSettingsFromConfig(d)
+	if settings["hostname"] != "web-2" || len(settings["ssh_authorized_keys"].([]string)) != 1 {
+		t.Errorf("Expected hostname and keys in the guest settings, got %v", settings)
+	}
+}
+
+func TestWithGuestSettingsUserData(t *testing.T) {
+	if got := withGuestSettingsUserData("#!/bin/sh\n", "", ""); got != "#!/bin/sh\n" {
+		t.Errorf("Expected user data without settings to be kept, got %q", got)
+	}
+
+	expected := "#cloud-config\ntimezone: \"Europe/Berlin\"\nlocale: \"en_US.UTF-8\"\n"
+	if got := withGuestSettingsUserData("", "Europe/Berlin", "en_US.UTF-8"); got != expected {
+		t.Errorf("Expected user data %q, got %q", expected, got)
+	}
+
+	// User data of the VM's own follows the settings, so its own settings take precedence
+	combined := withGuestSettingsUserData("#!/bin/sh\necho hello\n", "UTC", "")
+	msg, err := mail.ReadMessage(strings.NewReader(combined))
+	if err != nil {
+		t.Fatalf("Expected a MIME message, got %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Expected a multi-part archive, got %q, %v", mediaType, err)
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		parts = append(parts, part.Header.Get("Content-Type")+"|"+string(body))
+	}
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "text/cloud-config") || !strings.Contains(parts[0], "timezone: \"UTC\"") ||
+		!strings.HasPrefix(parts[1], "text/plain") || !strings.Contains(parts[1], "echo hello") {
+		t.Errorf("Expected the settings followed by the user data, got %q", parts)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"timezone":   "UTC",
+		"cloud_init": []interface{}{map[string]interface{}{"datasource": "mmds"}},
+	})
+	spec, _ := cloudInitSpecFromConfig(d, "vm-1")
+	if !strings.Contains(spec.UserData, "timezone: \"UTC\"") {
+		t.Errorf("Expected the timezone in the cloud-init user data, got %q", spec.UserData)
+	}
+}
+
+func TestBuildSeedImage(t *testing.T) {
+	originalLookPath, originalRunCommand := lookPath, runCommand
+	defer func() { lookPath, runCommand = originalLookPath, originalRunCommand }()
+	lookPath = func(file string) (string, error) {
+		if file == "mkisofs" {
+			return "/usr/bin/mkisofs", nil
+		}
+		return "", fmt.Errorf("%s not found", file)
+	}
+
+	var command []string
+	var userData []byte
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		command = append([]string{name}, args...)
+		for _, arg := range args {
+			if filepath.Base(arg) == "user-data" {
+				userData, _ = os.ReadFile(arg)
+			}
+		}
+		return nil, nil
+	}
+
+	path := seedImagePath(t.TempDir(), "vm-1")
+	spec := cloudInitSpec{
+		UserData:      "#cloud-config\n",
+		MetaData:      map[string]string{"instance-id": "vm-1"},
+		NetworkConfig: "version: 2\n",
+	}
+	if err := buildSeedImage(context.Background(), path, spec); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	args := strings.Join(command, " ")
+	if !strings.HasPrefix(args, "mkisofs -output "+path+" -volid cidata") {
+		t.Errorf("Unexpected command %s", args)
+	}
+	if !strings.Contains(args, "network-config") {
+		t.Errorf("Expected network-config in the seed image, got %s", args)
+	}
+	if string(userData) != spec.UserData {
+		t.Errorf("Expected user-data %q, got %q", spec.UserData, userData)
+	}
+}
+
+func TestBuildSeedImage_noTool(t *testing.T) {
+	original := lookPath
+	defer func() { lookPath = original }()
+	lookPath = func(file string) (string, error) {
+		return "", fmt.Errorf("%s not found", file)
+	}
+
+	err := buildSeedImage(context.Background(), seedImagePath(t.TempDir(), "vm-1"), cloudInitSpec{})
+	if err == nil || !strings.Contains(err.Error(), "genisoimage") {
+		t.Errorf("Expected an error naming the required tools, got %v", err)
+	}
+}
+
+func TestCloudInitMMDSContents(t *testing.T) {
+	contents := cloudInitMMDSContents(cloudInitSpec{
+		UserData: "#cloud-config\n",
+		MetaData: map[string]string{"instance-id": "vm-1"},
+	})
+
+	latest, ok := contents["latest"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected a latest key, got %v", contents)
+	}
+	if latest["user-data"] != "#cloud-config\n" {
+		t.Errorf("Unexpected user-data %v", latest["user-data"])
+	}
+	if metaData := latest["meta-data"].(map[string]interface{}); metaData["instance-id"] != "vm-1" {
+		t.Errorf("Unexpected meta-data %v", metaData)
+	}
+}
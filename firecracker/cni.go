@@ -0,0 +1,151 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/containernetworking/cni/libcni"
+    current "github.com/containernetworking/cni/pkg/types/100"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    defaultCNIConfigDir = "/etc/cni/conf.d"
+    defaultCNIBinDir    = "/opt/cni/bin"
+    defaultCNIIfName    = "veth0"
+)
+
+// cniSpec describes the CNI network a VM is attached to.
+type cniSpec struct {
+    NetworkName string
+    ConfigDir   string
+    BinDir      string
+    NetNS       string
+    IfName      string
+}
+
+// cniAttachment is the result of attaching a VM to a CNI network: the TAP device
+// Firecracker should use and the addressing the guest should configure.
+type cniAttachment struct {
+    TapName     string
+    GuestMAC    string
+    GuestIPCIDR string
+    Gateway     string
+}
+
+// runtimeConf returns the CNI runtime configuration for a VM. The VM ID is used as the
+// container ID, matching what tc-redirect-tap expects.
+func (s cniSpec) runtimeConf(vmID string) *libcni.RuntimeConf {
+    return &libcni.RuntimeConf{
+        ContainerID: vmID,
+        NetNS:       s.NetNS,
+        IfName:      s.IfName,
+    }
+}
+
+// cniAdd invokes the plugins of the CNI network list with the ADD command.
+func cniAdd(ctx context.Context, spec cniSpec, vmID string) (*cniAttachment, error) {
+    list, err := libcni.LoadConfList(spec.ConfigDir, spec.NetworkName)
+    if err != nil {
+        return nil, fmt.Errorf("failed to load CNI network %s from %s: %w", spec.NetworkName, spec.ConfigDir, err)
+    }
+
+    cni := libcni.NewCNIConfig([]string{spec.BinDir}, nil)
+    rawResult, err := cni.AddNetworkList(ctx, list, spec.runtimeConf(vmID))
+    if err != nil {
+        return nil, fmt.Errorf("failed to add VM to CNI network %s: %w", spec.NetworkName, err)
+    }
+
+    result, err := current.NewResultFromResult(rawResult)
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse CNI result: %w", err)
+    }
+
+    return vmTapPair(result, vmID, spec.NetNS)
+}
+
+// cniDel invokes the plugins of the CNI network list with the DEL command.
+func cniDel(ctx context.Context, spec cniSpec, vmID string) error {
+    list, err := libcni.LoadConfList(spec.ConfigDir, spec.NetworkName)
+    if err != nil {
+        return fmt.Errorf("failed to load CNI network %s from %s: %w", spec.NetworkName, spec.ConfigDir, err)
+    }
+
+    cni := libcni.NewCNIConfig([]string{spec.BinDir}, nil)
+    if err := cni.DelNetworkList(ctx, list, spec.runtimeConf(vmID)); err != nil {
+        return fmt.Errorf("failed to remove VM from CNI network %s: %w", spec.NetworkName, err)
+    }
+
+    return nil
+}
+
+// vmTapPair extracts the VM's network settings from a CNI result, following the conventions
+// of the tc-redirect-tap plugin: the guest interface has the VM ID as its sandbox, and the
+// TAP device with the same name lives in the network namespace Firecracker runs in.
+func vmTapPair(result *current.Result, vmID, netns string) (*cniAttachment, error) {
+    vmIndex := -1
+    for i, iface := range result.Interfaces {
+        if iface.Sandbox == vmID {
+            vmIndex = i
+            break
+        }
+    }
+    if vmIndex == -1 {
+        return nil, fmt.Errorf("CNI result has no interface for VM %s, is tc-redirect-tap the last plugin of the chain?", vmID)
+    }
+    vmIface := result.Interfaces[vmIndex]
+
+    tapName := ""
+    for _, iface := range result.Interfaces {
+        if iface.Name == vmIface.Name && iface.Sandbox == netns {
+            tapName = iface.Name
+            break
+        }
+    }
+    if tapName == "" {
+        return nil, fmt.Errorf("CNI result has no TAP device %s in network namespace %s", vmIface.Name, netns)
+    }
+
+    attachment := &cniAttachment{
+        TapName:  tapName,
+        GuestMAC: vmIface.Mac,
+    }
+    for _, ip := range result.IPs {
+        if ip.Interface != nil && *ip.Interface == vmIndex && ip.Address.IP.To4() != nil {
+            attachment.GuestIPCIDR = ip.Address.String()
+            if ip.Gateway != nil {
+                attachment.Gateway = ip.Gateway.String()
+            }
+            break
+        }
+    }
+
+    return attachment, nil
+}
+
+// cniSpecFromConfig returns the CNI network described by the VM's cni block, if any.
+func cniSpecFromConfig(d *schema.ResourceData) (cniSpec, bool) {
+    raw := d.Get("cni").([]interface{})
+    if len(raw) == 0 || raw[0] == nil {
+        return cniSpec{}, false
+    }
+    cfg := raw[0].(map[string]interface{})
+
+    return cniSpec{
+        NetworkName: cfg["network_name"].(string),
+        ConfigDir:   cfg["config_dir"].(string),
+        BinDir:      cfg["bin_dir"].(string),
+        NetNS:       cfg["netns"].(string),
+        IfName:      cfg["if_name"].(string),
+    }, true
+}
+
+// setCNIAttachment records the result of a CNI ADD in the computed attributes of the cni block.
+func setCNIAttachment(d *schema.ResourceData, attachment *cniAttachment) {
+    cfg := d.Get("cni").([]interface{})[0].(map[string]interface{})
+    cfg["tap_device"] = attachment.TapName
+    cfg["guest_mac"] = attachment.GuestMAC
+    cfg["guest_ip"] = attachment.GuestIPCIDR
+    cfg["gateway"] = attachment.Gateway
+    d.Set("cni", []interface{}{cfg})
+}
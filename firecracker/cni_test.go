@@ -0,0 +1,53 @@
+package firecracker
+
+import (
+	"net"
+	"testing"
+
+	current "github.com/containernetworking/cni/pkg/types/100"
+)
+
+func TestVMTapPair(t *testing.T) {
+	vmIndex := 2
+	result := &current.Result{
+		Interfaces: []*current.Interface{
+			{Name: "cni0"},
+			{Name: "veth0", Sandbox: "/var/run/netns/vm-1"},
+			{Name: "tap0", Mac: "AA:FC:00:00:00:01", Sandbox: "vm-1"},
+			{Name: "tap0", Sandbox: "/var/run/netns/vm-1"},
+		},
+		IPs: []*current.IPConfig{
+			{
+				Interface: &vmIndex,
+				Address:   net.IPNet{IP: net.ParseIP("10.1.0.5").To4(), Mask: net.CIDRMask(24, 32)},
+				Gateway:   net.ParseIP("10.1.0.1"),
+			},
+		},
+	}
+
+	attachment, err := vmTapPair(result, "vm-1", "/var/run/netns/vm-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if attachment.TapName != "tap0" {
+		t.Errorf("Expected tap0, got %s", attachment.TapName)
+	}
+	if attachment.GuestMAC != "AA:FC:00:00:00:01" {
+		t.Errorf("Expected guest MAC from the VM interface, got %s", attachment.GuestMAC)
+	}
+	if attachment.GuestIPCIDR != "10.1.0.5/24" || attachment.Gateway != "10.1.0.1" {
+		t.Errorf("Expected 10.1.0.5/24 via 10.1.0.1, got %s via %s", attachment.GuestIPCIDR, attachment.Gateway)
+	}
+}
+
+func TestVMTapPair_noRedirectTap(t *testing.T) {
+	result := &current.Result{
+		Interfaces: []*current.Interface{
+			{Name: "veth0", Sandbox: "/var/run/netns/vm-1"},
+		},
+	}
+
+	if _, err := vmTapPair(result, "vm-1", "/var/run/netns/vm-1"); err == nil {
+		t.Errorf("Expected an error when the result has no VM interface")
+	}
+}
@@ -0,0 +1,149 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    sdk "github.com/avkcode/terraform-provider-firecracker/client"
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// compactionNow returns the current time, replaced in tests.
+var compactionNow = time.Now
+
+// compactDriveImage punches holes into the zeroed ranges of a drive image, so blocks the
+// guest freed and zeroed take no space on the host again.
+func compactDriveImage(ctx context.Context, path string) error {
+    if output, err := runCommand(ctx, "fallocate", "--dig-holes", path); err != nil {
+        return fmt.Errorf("failed to compact %s: %w: %s", path, err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// compactionConfig is implemented by both *schema.ResourceData and *schema.ResourceDiff, so
+// compacting drives can be planned.
+type compactionConfig interface {
+    Get(key string) interface{}
+}
+
+// drivesDueForCompaction returns the VM's writable drives with a compact_interval that have
+// not been compacted within it.
+func drivesDueForCompaction(d compactionConfig, now time.Time) ([]map[string]interface{}, error) {
+    compacted := d.Get("drive_compacted_at").(map[string]interface{})
+
+    var due []map[string]interface{}
+    for _, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        interval, _ := drive["compact_interval"].(string)
+        if readOnly, _ := drive["is_read_only"].(bool); interval == "" || readOnly {
+            continue
+        }
+        every, err := time.ParseDuration(interval)
+        if err != nil {
+            return nil, fmt.Errorf("invalid compact_interval of drive %s: %w", drive["drive_id"], err)
+        }
+
+        if last, ok := compacted[drive["drive_id"].(string)].(string); ok {
+            if at, err := time.Parse(time.RFC3339, last); err == nil && now.Sub(at) < every {
+                continue
+            }
+        }
+        due = append(due, drive)
+    }
+    return due, nil
+}
+
+// planCompaction has the next apply compact the drives of a VM on the host running Terraform
+// once their compact_interval has elapsed. A read-only provider leaves the images alone.
+func planCompaction(d *schema.ResourceDiff, m interface{}) error {
+    if d.Id() == "" || d.Get("host").(string) != "" || providerReadOnly(m) {
+        return nil
+    }
+    due, err := drivesDueForCompaction(d, compactionNow().UTC())
+    if err != nil || len(due) == 0 {
+        return err
+    }
+    return d.SetNewComputed("drive_compacted_at")
+}
+
+// compactionPath returns the path of a drive's image on the host running Terraform.
+func compactionPath(d *schema.ResourceData, stateDir string, drive map[string]interface{}) string {
+    driveID := drive["drive_id"].(string)
+    path, _ := drive["path_on_host"].(string)
+    if path == "" {
+        path = scratchDrivePath(stateDir, d.Id(), driveID)
+        if spec, ok := jailerSpecFromConfig(d.Get("jailer"), d.Id()); ok && spec.StageFiles {
+            path = spec.hostPath(stagedDrivePath(driveID, path))
+        }
+        return path
+    }
+    return vmDriveHostPath(d, driveID, path)
+}
+
+// compactDrives compacts the VM's drives whose compact_interval has elapsed, on the apply a
+// refresh finding them due planned. A running VM is paused while its images are compacted,
+// so the guest cannot write to a range as its hole is punched. Failures are reported as
+// warnings and planned again by the next refresh.
+func compactDrives(ctx context.Context, client *FirecrackerClient, d *schema.ResourceData, stateDir string) diag.Diagnostics {
+    now := compactionNow().UTC()
+    due, err := drivesDueForCompaction(d, now)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    if len(due) == 0 {
+        return nil
+    }
+
+    // A VM that is paused or not started does not write to its drives
+    api := client.api()
+    info, err := api.InstanceInfo(ctx)
+    if err != nil {
+        return compactionWarning(d.Id(), fmt.Errorf("failed to get the state of the VM: %w", err))
+    }
+    running := info.State == sdk.StateRunning
+    if running {
+        if err := api.Pause(ctx); err != nil {
+            return compactionWarning(d.Id(), fmt.Errorf("failed to pause VM: %w", err))
+        }
+    }
+
+    var diags diag.Diagnostics
+    compacted := d.Get("drive_compacted_at").(map[string]interface{})
+    for _, drive := range due {
+        driveID, path := drive["drive_id"].(string), compactionPath(d, stateDir, drive)
+        tflog.Info(ctx, "Compacting drive image", map[string]interface{}{
+            "id":       d.Id(),
+            "drive_id": driveID,
+            "path":     path,
+        })
+        if err := compactDriveImage(ctx, path); err != nil {
+            diags = append(diags, compactionWarning(d.Id(), err)...)
+            continue
+        }
+        compacted[driveID] = now.Format(time.RFC3339)
+    }
+
+    if running {
+        if err := api.Resume(ctx); err != nil {
+            return append(diags, diag.FromErr(fmt.Errorf("failed to resume VM %s after compacting its drives: %w", d.Id(), err))...)
+        }
+    }
+    d.Set("drive_compacted_at", compacted)
+    return diags
+}
+
+// compactionWarning reports that a VM's drives could not be compacted.
+func compactionWarning(vmID string, err error) diag.Diagnostics {
+    return diag.Diagnostics{{
+        Severity: diag.Warning,
+        Summary:  "Failed to compact drive images",
+        Detail:   fmt.Sprintf("VM %s: %s. Compacting is planned again by the next refresh.", vmID, err),
+    }}
+}
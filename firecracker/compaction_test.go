@@ -0,0 +1,113 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestCompactDrives(t *testing.T) {
+	stateDir := t.TempDir()
+	ctx := context.Background()
+
+	var commands []string
+	originalRun := runCommand
+	defer func() { runCommand = originalRun }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		return nil, nil
+	}
+	originalNow := compactionNow
+	defer func() { compactionNow = originalNow }()
+	clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	compactionNow = func() time.Time { return clock }
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+	defer resourceFirecrackerTestImageDelete(ctx, image, nil)
+	data := filepath.Join(t.TempDir(), "data.img")
+
+	config := map[string]interface{}{
+		"kernel_image_path": image.Get("path").(string),
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true, "is_read_only": true, "compact_interval": "1h"},
+			map[string]interface{}{"drive_id": "data", "path_on_host": data, "is_root_device": false, "compact_interval": "24h"},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config)
+	if diags := resourceFirecrackerVMCreate(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+	d = resourceFirecrackerVM().Data(d.State())
+	d.Set("drive_compacted_at", map[string]interface{}{"data": clock.Format(time.RFC3339)})
+
+	// Refreshing leaves the images alone, and only a plan with the writable drive due updates the VM
+	for _, elapsed := range []time.Duration{time.Hour, 24 * time.Hour} {
+		clock = clock.Add(elapsed)
+		client := configureFakeProvider(t, stateDir)
+		if diags := resourceFirecrackerVMRead(ctx, d, client); diags.HasError() {
+			t.Fatalf("Failed to read VM: %v", diags)
+		}
+		diff, err := resourceFirecrackerVM().Diff(ctx, d.State(), terraform.NewResourceConfigRaw(config), client)
+		if err != nil {
+			t.Fatalf("Failed to plan VM: %v", err)
+		}
+		if planned := diff != nil && diff.Attributes["drive_compacted_at.%"] != nil; planned != (elapsed == 24*time.Hour) {
+			t.Errorf("Expected compaction to be planned after %s: %v, got %v", elapsed, !planned, planned)
+		}
+		if len(commands) != 0 {
+			t.Fatalf("Expected no commands before the apply, got %v", commands)
+		}
+	}
+
+	if diags := resourceFirecrackerVMUpdate(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to update VM: %v", diags)
+	}
+	expected := "fallocate --dig-holes " + data
+	if strings.Join(commands, "\n") != expected {
+		t.Errorf("Unexpected commands:\n%s", strings.Join(commands, "\n"))
+	}
+	if got := d.Get("drive_compacted_at.data").(string); got != "2024-05-02T13:00:00Z" {
+		t.Errorf("Expected the drive to be compacted on the apply, got %q", got)
+	}
+	if len(d.Get("drives").([]interface{})) != 2 {
+		t.Errorf("Expected the configured drives to be kept, got %v", d.Get("drives"))
+	}
+
+	vm, err := os.ReadFile(filepath.Join(stateDir, "fake", "test", "vm.json"))
+	if err != nil || !strings.Contains(string(vm), "Resumed") {
+		t.Errorf("Expected the VM to be resumed after compacting, got %s, %v", vm, err)
+	}
+
+	// A paused VM stays paused
+	client := configureFakeProvider(t, stateDir)
+	if err := client.api().Pause(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(24 * time.Hour)
+	if diags := compactDrives(ctx, client, d, stateDir); diags.HasError() || len(commands) != 2 {
+		t.Fatalf("Expected the drive to be compacted again, got %v, %v", diags, commands)
+	}
+	if vm, _ := os.ReadFile(filepath.Join(stateDir, "fake", "test", "vm.json")); !strings.Contains(string(vm), "Paused") {
+		t.Errorf("Expected the VM to stay paused, got %s", vm)
+	}
+
+	// A read-only provider does not plan compaction
+	clock = clock.Add(24 * time.Hour)
+	client.ReadOnly = true
+	if diff, err := resourceFirecrackerVM().Diff(ctx, d.State(), terraform.NewResourceConfigRaw(config), client); err != nil || (diff != nil && diff.Attributes["drive_compacted_at.%"] != nil) {
+		t.Errorf("Expected a read-only provider not to plan compaction, got %v, %v", diff, err)
+	}
+}
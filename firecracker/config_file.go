@@ -0,0 +1,260 @@
+package firecracker
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+
+    sdk "github.com/avkcode/terraform-provider-firecracker/client"
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// configFileKey marks a rendered VM payload built from config_json, which CreateVM applies
+// as it is instead of from the VM's structured attributes. It is not part of the API.
+const configFileKey = "config_file"
+
+// configFileConflicts are the attributes of a firecracker_vm describing what its config_json
+// does, or adding to the configuration Firecracker is given, so they cannot be set with it.
+var configFileConflicts = []string{
+    "kernel_image_path", "initrd_path", "boot_args", "sensitive_boot_args", "drives", "machine_config",
+    "network_interfaces", "entropy_device", "cpu_config_json", "vsock", "mmds", "cloud_init", "jailer", "staging", "cni",
+    "timezone", "locale", "hostname", "ssh_authorized_keys", "guest_ip", "guest_gateway", "publish_tags",
+}
+
+// configFileSection is a section of a Firecracker configuration file, with the API endpoint
+// it is applied through.
+type configFileSection struct {
+    Key  string
+    Path string
+
+    // IDField is set for sections listing devices, each applied at Path/<ID>
+    IDField string
+
+    // Required maps the fields a section must set to their JSON type
+    Required map[string]string
+}
+
+// configFileSections are the sections `firecracker --config-file` takes, in the order
+// Firecracker applies them: MMDS is configured once the interfaces it is reachable from are.
+var configFileSections = []configFileSection{
+    {Key: "logger", Path: "/logger"},
+    {Key: "metrics", Path: "/metrics", Required: map[string]string{"metrics_path": "string"}},
+    {Key: "machine-config", Path: "/machine-config", Required: map[string]string{"vcpu_count": "number", "mem_size_mib": "number"}},
+    {Key: "cpu-config", Path: "/cpu-config"},
+    {Key: "boot-source", Path: "/boot-source", Required: map[string]string{"kernel_image_path": "string"}},
+    {Key: "drives", Path: "/drives", IDField: "drive_id", Required: map[string]string{"drive_id": "string", "is_root_device": "bool"}},
+    {Key: "network-interfaces", Path: "/network-interfaces", IDField: "iface_id", Required: map[string]string{"iface_id": "string", "host_dev_name": "string"}},
+    {Key: "vsock", Path: "/vsock", Required: map[string]string{"guest_cid": "number", "uds_path": "string"}},
+    {Key: "balloon", Path: "/balloon", Required: map[string]string{"amount_mib": "number", "deflate_on_oom": "bool"}},
+    {Key: "entropy", Path: "/entropy"},
+    {Key: "mmds-config", Path: "/mmds/config", Required: map[string]string{"network_interfaces": "array"}},
+}
+
+// configFileTypeNames describe the JSON types of required fields in errors.
+var configFileTypeNames = map[string]string{"string": "non-empty string", "number": "number", "bool": "boolean", "array": "list"}
+
+// validateConfigJSON checks at plan time that config_json is a configuration file
+// Firecracker takes.
+func validateConfigJSON(v interface{}, k string) ([]string, []error) {
+    if _, err := parseConfigFile(v.(string)); err != nil {
+        return nil, []error{fmt.Errorf("%s: %w", k, err)}
+    }
+    return nil, nil
+}
+
+// parseConfigFile parses a Firecracker configuration file, failing on what Firecracker would
+// reject it for: unknown sections, sections of the wrong type, missing required fields, and
+// devices sharing an ID. Numbers are kept as they are written, so they are applied verbatim.
+func parseConfigFile(raw string) (map[string]interface{}, error) {
+    decoder := json.NewDecoder(strings.NewReader(raw))
+    decoder.UseNumber()
+    var config map[string]interface{}
+    if err := decoder.Decode(&config); err != nil {
+        return nil, fmt.Errorf("invalid Firecracker configuration: %w", err)
+    }
+    if decoder.More() {
+        return nil, fmt.Errorf("invalid Firecracker configuration: unexpected data after the JSON object")
+    }
+
+    known := map[string]bool{}
+    for _, section := range configFileSections {
+        known[section.Key] = true
+    }
+    var unknown []string
+    for key := range config {
+        if !known[key] {
+            unknown = append(unknown, key)
+        }
+    }
+    if len(unknown) > 0 {
+        sort.Strings(unknown)
+        return nil, fmt.Errorf("unknown sections %s in Firecracker configuration", strings.Join(unknown, ", "))
+    }
+    if config["boot-source"] == nil {
+        return nil, fmt.Errorf("boot-source section is required in Firecracker configuration")
+    }
+
+    ifaceIDs := map[string]bool{}
+    for _, section := range configFileSections {
+        raw, ok := config[section.Key]
+        if !ok {
+            continue
+        }
+        if section.IDField == "" {
+            if err := checkConfigFileObject(section, section.Key, raw); err != nil {
+                return nil, err
+            }
+            continue
+        }
+
+        list, ok := raw.([]interface{})
+        if !ok {
+            return nil, fmt.Errorf("%s must be a list", section.Key)
+        }
+        seen := map[string]bool{}
+        roots := 0
+        for i, item := range list {
+            if err := checkConfigFileObject(section, fmt.Sprintf("%s[%d]", section.Key, i), item); err != nil {
+                return nil, err
+            }
+            device := item.(map[string]interface{})
+            id := device[section.IDField].(string)
+            if seen[id] {
+                return nil, fmt.Errorf("%s %q is used by more than one device in %s", section.IDField, id, section.Key)
+            }
+            seen[id] = true
+            if root, _ := device["is_root_device"].(bool); root {
+                roots++
+            }
+        }
+        if roots > 1 {
+            return nil, fmt.Errorf("more than one drive sets is_root_device to true")
+        }
+        if section.Key == "network-interfaces" {
+            ifaceIDs = seen
+        }
+    }
+
+    // MMDS is only reachable from interfaces of the VM
+    if mmdsConfig, ok := config["mmds-config"].(map[string]interface{}); ok {
+        for _, raw := range mmdsConfig["network_interfaces"].([]interface{}) {
+            id, ok := raw.(string)
+            if !ok || !ifaceIDs[id] {
+                return nil, fmt.Errorf("mmds-config network_interfaces refers to %v, which is not in network-interfaces", raw)
+            }
+        }
+    }
+    return config, nil
+}
+
+// checkConfigFileObject checks that an object of a configuration file section sets the
+// fields the section requires, with the right types.
+func checkConfigFileObject(section configFileSection, name string, raw interface{}) error {
+    object, ok := raw.(map[string]interface{})
+    if !ok {
+        return fmt.Errorf("%s must be an object", name)
+    }
+    fields := make([]string, 0, len(section.Required))
+    for field := range section.Required {
+        fields = append(fields, field)
+    }
+    sort.Strings(fields)
+    for _, field := range fields {
+        value, ok := object[field]
+        if !ok {
+            return fmt.Errorf("%s must set %s", name, field)
+        }
+        var valid bool
+        switch section.Required[field] {
+        case "string":
+            s, isString := value.(string)
+            valid = isString && s != ""
+        case "number":
+            _, valid = value.(json.Number)
+        case "bool":
+            _, valid = value.(bool)
+        case "array":
+            _, valid = value.([]interface{})
+        }
+        if !valid {
+            return fmt.Errorf("%s %s must be a %s", name, field, configFileTypeNames[section.Required[field]])
+        }
+    }
+    return nil
+}
+
+// checkConfigFileFiles fails the plan when the kernel, initrd or drive images a configuration
+// file refers to cannot be read, as checkHostFiles does for the VM's attributes.
+func checkConfigFileFiles(raw string) error {
+    config, err := parseConfigFile(raw)
+    if err != nil {
+        return nil
+    }
+    bootSource := config["boot-source"].(map[string]interface{})
+    for _, key := range []string{"kernel_image_path", "initrd_path"} {
+        if path, _ := bootSource[key].(string); path != "" {
+            if err := checkReadableFile("config_json boot-source "+key, path); err != nil {
+                return err
+            }
+        }
+    }
+    drives, _ := config["drives"].([]interface{})
+    for _, raw := range drives {
+        drive := raw.(map[string]interface{})
+        if path, _ := drive["path_on_host"].(string); path != "" {
+            if err := checkReadableFile(fmt.Sprintf("config_json path_on_host of drive %s", drive["drive_id"]), path); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// applyConfigFile configures a VM from a Firecracker configuration file, applying each of
+// its sections through the API as it is written.
+func (c *FirecrackerClient) applyConfigFile(ctx context.Context, config map[string]interface{}) error {
+    tflog.Debug(ctx, "Creating VM from a Firecracker configuration file", map[string]interface{}{
+        "config": redactVMConfig(config),
+    })
+
+    for _, section := range configFileSections {
+        raw, ok := config[section.Key]
+        if !ok {
+            continue
+        }
+        if section.IDField == "" {
+            if err := c.putSection(ctx, section.Path, raw); err != nil {
+                return fmt.Errorf("failed to apply %s: %w", section.Key, err)
+            }
+            continue
+        }
+        for _, item := range raw.([]interface{}) {
+            id := item.(map[string]interface{})[section.IDField].(string)
+            if err := c.putSection(ctx, section.Path+"/"+id, item); err != nil {
+                return fmt.Errorf("failed to apply %s %s: %w", section.Key, id, err)
+            }
+        }
+    }
+    return nil
+}
+
+// putSection sends a section of a configuration file, or an item of it, as it is written.
+func (c *FirecrackerClient) putSection(ctx context.Context, path string, payload interface{}) error {
+    return c.callAPI(ctx, http.MethodPut, path, func(ctx context.Context, api *sdk.Client) error {
+        return api.Put(ctx, path, payload)
+    })
+}
+
+// configFilePayload returns the rendered VM payload of a VM created from config_json.
+func configFilePayload(raw, vmID string) (map[string]interface{}, error) {
+    config, err := parseConfigFile(raw)
+    if err != nil {
+        return nil, err
+    }
+    config["vm-id"] = vmID
+    config[configFileKey] = true
+    return config, nil
+}
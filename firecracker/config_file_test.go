@@ -0,0 +1,148 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{name: "minimal", config: `{"boot-source": {"kernel_image_path": "/vmlinux"}}`},
+		{name: "full", config: `{
+			"boot-source": {"kernel_image_path": "/vmlinux", "boot_args": "console=ttyS0"},
+			"drives": [{"drive_id": "rootfs", "path_on_host": "/rootfs.ext4", "is_root_device": true, "is_read_only": false}],
+			"machine-config": {"vcpu_count": 2, "mem_size_mib": 1024, "smt": false},
+			"network-interfaces": [{"iface_id": "eth0", "host_dev_name": "tap0"}],
+			"mmds-config": {"version": "V2", "network_interfaces": ["eth0"]},
+			"balloon": {"amount_mib": 0, "deflate_on_oom": true},
+			"logger": {"level": "Info"},
+			"entropy": {}
+		}`},
+		{name: "not JSON", config: `boot-source`, err: "invalid Firecracker configuration"},
+		{name: "trailing data", config: `{"boot-source": {"kernel_image_path": "/vmlinux"}} {}`, err: "unexpected data"},
+		{name: "unknown section", config: `{"boot-source": {"kernel_image_path": "/vmlinux"}, "boot_source": {}}`, err: "unknown sections boot_source"},
+		{name: "no boot source", config: `{"machine-config": {"vcpu_count": 1, "mem_size_mib": 128}}`, err: "boot-source section is required"},
+		{name: "no kernel", config: `{"boot-source": {"boot_args": "console=ttyS0"}}`, err: "boot-source must set kernel_image_path"},
+		{name: "wrong type", config: `{"boot-source": {"kernel_image_path": "/vmlinux"}, "machine-config": {"vcpu_count": "2", "mem_size_mib": 128}}`, err: "vcpu_count must be a number"},
+		{name: "drives not a list", config: `{"boot-source": {"kernel_image_path": "/vmlinux"}, "drives": {}}`, err: "drives must be a list"},
+		{name: "drive without root flag", config: `{"boot-source": {"kernel_image_path": "/vmlinux"}, "drives": [{"drive_id": "rootfs"}]}`, err: "drives[0] must set is_root_device"},
+		{name: "duplicate drive", config: `{"boot-source": {"kernel_image_path": "/vmlinux"}, "drives": [{"drive_id": "data", "is_root_device": false}, {"drive_id": "data", "is_root_device": false}]}`, err: `drive_id "data" is used by more than one device`},
+		{name: "two roots", config: `{"boot-source": {"kernel_image_path": "/vmlinux"}, "drives": [{"drive_id": "a", "is_root_device": true}, {"drive_id": "b", "is_root_device": true}]}`, err: "more than one drive"},
+		{name: "MMDS interface", config: `{"boot-source": {"kernel_image_path": "/vmlinux"}, "mmds-config": {"network_interfaces": ["eth0"]}}`, err: "not in network-interfaces"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseConfigFile(tc.config)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("Expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestResourceFirecrackerVMDiff_configJSON(t *testing.T) {
+	config := `{"boot-source": {"kernel_image_path": "/vmlinux"}}`
+	for name, tc := range map[string]struct {
+		config map[string]interface{}
+		err    string
+	}{
+		"config file":  {config: map[string]interface{}{"config_json": config}},
+		"attributes":   {config: map[string]interface{}{"kernel_image_path": "/vmlinux"}, err: "must be specified"},
+		"conflict":     {config: map[string]interface{}{"config_json": config, "kernel_image_path": "/vmlinux"}, err: "conflicts with"},
+		"invalid file": {config: map[string]interface{}{"config_json": `{}`}, err: "boot-source section is required"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := resourceFirecrackerVM()
+			c := terraform.NewResourceConfigRaw(tc.config)
+			var err error
+			if diags := r.Validate(c); diags.HasError() {
+				err = errors.New(diags[0].Summary + ": " + diags[0].Detail)
+			} else {
+				_, err = r.Diff(context.Background(), nil, c, nil)
+			}
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("Expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestResourceFirecrackerVMCreate_configJSON(t *testing.T) {
+	dir := t.TempDir()
+	kernel := filepath.Join(dir, "vmlinux")
+	if err := os.WriteFile(kernel, []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := `{
+		"boot-source": {"kernel_image_path": "` + kernel + `", "boot_args": "console=ttyS0 quiet"},
+		"drives": [{"drive_id": "root", "path_on_host": "/images/rootfs.ext4", "is_root_device": true, "is_read_only": true}],
+		"machine-config": {"vcpu_count": 2, "mem_size_mib": 512, "smt": false},
+		"network-interfaces": [{"iface_id": "eth0", "host_dev_name": "tap0", "guest_mac": "06:00:ac:10:00:02"}],
+		"mmds-config": {"version": "V2", "network_interfaces": ["eth0"]}
+	}`
+	stateDir := t.TempDir()
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"config_json": config,
+	})
+	if diags := resourceFirecrackerVMCreate(context.Background(), d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	// Sections are applied as they are written, without the provider's defaults
+	fake := filepath.Join(stateDir, "fake", "test")
+	for file, want := range map[string]string{
+		"boot-source.json":             `{"boot_args":"console=ttyS0 quiet","kernel_image_path":"` + kernel + `"}`,
+		"drives_root.json":             `{"drive_id":"root","is_read_only":true,"is_root_device":true,"path_on_host":"/images/rootfs.ext4"}`,
+		"machine-config.json":          `{"mem_size_mib":512,"smt":false,"vcpu_count":2}`,
+		"network-interfaces_eth0.json": `{"guest_mac":"06:00:ac:10:00:02","host_dev_name":"tap0","iface_id":"eth0"}`,
+		"mmds_config.json":             `{"network_interfaces":["eth0"],"version":"V2"}`,
+	} {
+		data, err := os.ReadFile(filepath.Join(fake, file))
+		if err != nil {
+			t.Fatalf("Expected %s to be configured: %v", file, err)
+		}
+		var got interface{}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Failed to parse %s: %v", file, err)
+		}
+		encoded, _ := json.Marshal(got)
+		if string(encoded) != want {
+			t.Errorf("Expected %s to be %s, got %s", file, want, encoded)
+		}
+	}
+	if d.Get("instance_state").(string) != "Running" {
+		t.Errorf("Expected the VM to be started, got %q", d.Get("instance_state"))
+	}
+
+	var rendered map[string]interface{}
+	if err := json.Unmarshal([]byte(d.Get("rendered_config_json").(string)), &rendered); err != nil {
+		t.Fatalf("Expected rendered_config_json to be JSON: %v", err)
+	}
+	if _, ok := rendered[configFileKey]; ok || rendered["boot-source"] == nil {
+		t.Errorf("Expected the configuration file to be rendered without the provider's marker, got %v", rendered)
+	}
+}
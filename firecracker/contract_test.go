@@ -0,0 +1,227 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// contractBinariesEnv names the directory holding the pinned Firecracker binaries, as
+// downloaded by fetch_firecracker_releases.sh. Contract tests are skipped when it is unset.
+const contractBinariesEnv = "FIRECRACKER_CONTRACT_BINARIES"
+
+// contractVersions are the Firecracker releases the client is tested against.
+// Keep in sync with fetch_firecracker_releases.sh.
+var contractVersions = []string{"1.4.1", "1.5.1", "1.6.0", "1.7.0", "1.8.0", "1.9.1", "1.10.1"}
+
+// contractCapabilities records which release introduced each API capability the provider
+// relies on. Releases before since must reject the request, later ones must accept it.
+// Capabilities of VM settings are also listed in versionedFeatures.
+var contractCapabilities = []struct {
+	name    string
+	since   string
+	path    string
+	payload map[string]interface{}
+}{
+	{
+		name:    "entropy device",
+		since:   "1.4.0",
+		path:    "/entropy",
+		payload: map[string]interface{}{},
+	},
+	{
+		name:    "custom CPU template",
+		since:   "1.4.0",
+		path:    "/cpu-config",
+		payload: map[string]interface{}{},
+	},
+	{
+		name:  "vsock device",
+		since: "1.0.0",
+		path:  "/vsock",
+		payload: map[string]interface{}{
+			"guest_cid": 3,
+			"uds_path":  "v.sock",
+		},
+	},
+	{
+		name:  "balloon device",
+		since: "1.0.0",
+		path:  "/balloon",
+		payload: map[string]interface{}{
+			"amount_mib":               0,
+			"deflate_on_oom":           true,
+			"stats_polling_interval_s": 1,
+		},
+	},
+	{
+		name:  "hugetlbfs guest memory",
+		since: "1.7.0",
+		path:  "/machine-config",
+		payload: map[string]interface{}{
+			"vcpu_count":   1,
+			"mem_size_mib": 128,
+			"huge_pages":   "2M",
+		},
+	},
+}
+
+// parseVersion parses a release such as "1.10.1" into its numeric components.
+func parseVersion(t *testing.T, version string) [3]int {
+	var parsed [3]int
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			t.Fatalf("Invalid version %q: %v", version, err)
+		}
+		parsed[i] = n
+	}
+	return parsed
+}
+
+// versionAtLeast reports whether version is the same as or newer than since.
+func versionAtLeast(t *testing.T, version, since string) bool {
+	v, s := parseVersion(t, version), parseVersion(t, since)
+	for i := range v {
+		if v[i] != s[i] {
+			return v[i] > s[i]
+		}
+	}
+	return true
+}
+
+// startContractFirecracker starts a Firecracker binary with its API on a Unix socket
+// and returns a client talking to it. The process is killed when the test ends.
+func startContractFirecracker(t *testing.T, binary string) *FirecrackerClient {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "firecracker.sock")
+
+	cmd := exec.Command(binary, "--api-sock", socket)
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start %s: %v", binary, err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s to create its API socket", binary)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return &FirecrackerClient{
+		BaseURL: "http://localhost",
+		HTTPClient: &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+// TestContract runs the client against every pinned Firecracker release.
+func TestContract(t *testing.T) {
+	dir := os.Getenv(contractBinariesEnv)
+	if dir == "" {
+		t.Skipf("%s is not set, run fetch_firecracker_releases.sh and point it at the download directory", contractBinariesEnv)
+	}
+
+	for _, version := range contractVersions {
+		version := version
+		t.Run(version, func(t *testing.T) {
+			binary := filepath.Join(dir, fmt.Sprintf("firecracker-v%s", version))
+			if _, err := os.Stat(binary); err != nil {
+				t.Fatalf("Firecracker %s is missing from %s: %v", version, dir, err)
+			}
+
+			t.Run("version", func(t *testing.T) {
+				client := startContractFirecracker(t, binary)
+				info, err := client.getComponent(context.Background(), client.BaseURL+"/version")
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if info["firecracker_version"] != version {
+					t.Errorf("Expected version %s, got %v", version, info["firecracker_version"])
+				}
+			})
+
+			t.Run("pre-boot configuration", func(t *testing.T) {
+				testContractPreBoot(t, startContractFirecracker(t, binary))
+			})
+
+			for _, capability := range contractCapabilities {
+				capability := capability
+				t.Run(capability.name, func(t *testing.T) {
+					client := startContractFirecracker(t, binary)
+					err := client.sendComponent(context.Background(), http.MethodPut, client.BaseURL+capability.path, capability.payload)
+					if versionAtLeast(t, version, capability.since) {
+						if err != nil {
+							t.Errorf("Expected %s to be supported since %s, got %v", capability.name, capability.since, err)
+						}
+					} else if err == nil {
+						t.Errorf("Expected %s to be rejected before %s", capability.name, capability.since)
+					}
+				})
+			}
+		})
+	}
+}
+
+// testContractPreBoot configures a VM the way CreateVM does, up to but not including
+// InstanceStart, and checks that GetVM reads the configuration back.
+func testContractPreBoot(t *testing.T, client *FirecrackerClient) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	kernel := filepath.Join(dir, "vmlinux")
+	rootfs := filepath.Join(dir, "rootfs.ext4")
+	for _, path := range []string{kernel, rootfs} {
+		if err := os.WriteFile(path, make([]byte, 4096), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	requests := []struct {
+		path    string
+		payload map[string]interface{}
+	}{
+		{"/machine-config", map[string]interface{}{"vcpu_count": 2, "mem_size_mib": 256}},
+		{"/boot-source", map[string]interface{}{"kernel_image_path": kernel, "boot_args": "console=ttyS0"}},
+		{"/drives/rootfs", map[string]interface{}{"drive_id": "rootfs", "path_on_host": rootfs, "is_root_device": true, "is_read_only": false}},
+	}
+	for _, req := range requests {
+		if err := client.sendComponent(ctx, http.MethodPut, client.BaseURL+req.path, req.payload); err != nil {
+			t.Fatalf("Expected PUT %s to succeed, got %v", req.path, err)
+		}
+	}
+
+	vm, err := client.GetVM(ctx, "contract")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	machineConfig := vm["machine-config"].(map[string]interface{})
+	if machineConfig["vcpu_count"] != float64(2) || machineConfig["mem_size_mib"] != float64(256) {
+		t.Errorf("Expected the machine config to be read back, got %v", machineConfig)
+	}
+}
@@ -0,0 +1,126 @@
+package firecracker
+
+import (
+    "encoding/json"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// cpuConfigBitmapRegexp matches the bitmaps of custom CPU template modifiers, such as
+// "0bxxxx0xxx": bits set to 0 or 1, or left as the host has them with x.
+var cpuConfigBitmapRegexp = regexp.MustCompile(`^0b[01x]+$`)
+
+// cpuConfigModifiers are the modifier lists a custom CPU template may hold, with the fields
+// each modifier requires. cpuid_modifiers and msr_modifiers are for x86_64 hosts,
+// reg_modifiers and vcpu_features for aarch64 ones.
+var cpuConfigModifiers = map[string][]string{
+    "cpuid_modifiers": {"leaf", "subleaf", "flags", "modifiers"},
+    "msr_modifiers":   {"addr", "bitmap"},
+    "reg_modifiers":   {"addr", "bitmap"},
+    "vcpu_features":   {"index", "bitmap"},
+}
+
+// cpuidRegisters are the registers a CPUID leaf modifier may change.
+var cpuidRegisters = map[string]bool{"eax": true, "ebx": true, "ecx": true, "edx": true}
+
+// validateCPUConfigJSON checks at plan time that cpu_config_json is a custom CPU template
+// Firecracker takes.
+func validateCPUConfigJSON(v interface{}, k string) ([]string, []error) {
+    if _, err := parseCPUConfig(v.(string)); err != nil {
+        return nil, []error{fmt.Errorf("%s: %w", k, err)}
+    }
+    return nil, nil
+}
+
+// parseCPUConfig parses a custom CPU template, as PUT /cpu-config takes it, failing on
+// unknown fields, modifiers missing required fields and malformed bitmaps. Numbers are kept
+// as they are written, so the template is applied verbatim.
+func parseCPUConfig(raw string) (map[string]interface{}, error) {
+    decoder := json.NewDecoder(strings.NewReader(raw))
+    decoder.UseNumber()
+    var config map[string]interface{}
+    if err := decoder.Decode(&config); err != nil {
+        return nil, fmt.Errorf("invalid CPU template: %w", err)
+    }
+    if decoder.More() {
+        return nil, fmt.Errorf("invalid CPU template: unexpected data after the JSON object")
+    }
+
+    for key, value := range config {
+        if key == "kvm_capabilities" {
+            capabilities, ok := value.([]interface{})
+            if !ok {
+                return nil, fmt.Errorf("kvm_capabilities must be a list")
+            }
+            for _, capability := range capabilities {
+                if _, ok := capability.(string); !ok {
+                    return nil, fmt.Errorf("kvm_capabilities must be a list of strings, such as \"56\" or \"!56\"")
+                }
+            }
+            continue
+        }
+
+        required, known := cpuConfigModifiers[key]
+        if !known {
+            return nil, fmt.Errorf("unknown field %s in CPU template, expected cpuid_modifiers, msr_modifiers, reg_modifiers, vcpu_features or kvm_capabilities", key)
+        }
+        modifiers, ok := value.([]interface{})
+        if !ok {
+            return nil, fmt.Errorf("%s must be a list", key)
+        }
+        for i, raw := range modifiers {
+            name := fmt.Sprintf("%s[%d]", key, i)
+            modifier, ok := raw.(map[string]interface{})
+            if !ok {
+                return nil, fmt.Errorf("%s must be an object", name)
+            }
+            for _, field := range required {
+                if _, ok := modifier[field]; !ok {
+                    return nil, fmt.Errorf("%s must set %s", name, field)
+                }
+            }
+            if bitmap, ok := modifier["bitmap"]; ok {
+                if err := checkCPUConfigBitmap(name, bitmap); err != nil {
+                    return nil, err
+                }
+            }
+            if key == "cpuid_modifiers" {
+                if err := checkCPUIDModifiers(name, modifier["modifiers"]); err != nil {
+                    return nil, err
+                }
+            }
+        }
+    }
+    return config, nil
+}
+
+// checkCPUIDModifiers checks the register modifiers of a CPUID leaf modifier.
+func checkCPUIDModifiers(name string, raw interface{}) error {
+    modifiers, ok := raw.([]interface{})
+    if !ok {
+        return fmt.Errorf("%s modifiers must be a list", name)
+    }
+    for i, raw := range modifiers {
+        modifier, ok := raw.(map[string]interface{})
+        if !ok {
+            return fmt.Errorf("%s modifiers[%d] must be an object", name, i)
+        }
+        if register, _ := modifier["register"].(string); !cpuidRegisters[register] {
+            return fmt.Errorf("%s modifiers[%d] register must be eax, ebx, ecx or edx, got %v", name, i, modifier["register"])
+        }
+        if err := checkCPUConfigBitmap(fmt.Sprintf("%s modifiers[%d]", name, i), modifier["bitmap"]); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// checkCPUConfigBitmap checks the bitmap of a modifier.
+func checkCPUConfigBitmap(name string, raw interface{}) error {
+    bitmap, _ := raw.(string)
+    if !cpuConfigBitmapRegexp.MatchString(bitmap) {
+        return fmt.Errorf("%s bitmap must be a string of 0, 1 and x prefixed with 0b, such as \"0bxxxx0xxx\", got %v", name, raw)
+    }
+    return nil
+}
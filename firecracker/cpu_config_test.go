@@ -0,0 +1,89 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// testCPUTemplate masks AVX-512 from the guest, as a fleet template would.
+const testCPUTemplate = `{
+	"cpuid_modifiers": [
+		{"leaf": "0x7", "subleaf": "0x0", "flags": 0, "modifiers": [{"register": "ebx", "bitmap": "0bxxxxxxxxxxxxxx0xxxxxxxxxxxxxxxxx"}]}
+	],
+	"msr_modifiers": [{"addr": "0x10a", "bitmap": "0b0000000000000000000000000000000000000000000000000000000000000000"}]
+}`
+
+func TestParseCPUConfig(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{name: "x86_64", config: testCPUTemplate},
+		{name: "aarch64", config: `{"reg_modifiers": [{"addr": "0x603000000013c020", "bitmap": "0bxxxxxxxxxxxx0000xxxxxxxxxxxxxxxx"}], "vcpu_features": [{"index": 0, "bitmap": "0b1100000"}], "kvm_capabilities": ["!56"]}`},
+		{name: "empty", config: `{}`},
+		{name: "not JSON", config: `cpuid`, err: "invalid CPU template"},
+		{name: "unknown field", config: `{"cpu_template": "T2"}`, err: "unknown field cpu_template"},
+		{name: "not a list", config: `{"msr_modifiers": {}}`, err: "msr_modifiers must be a list"},
+		{name: "missing field", config: `{"msr_modifiers": [{"addr": "0x10a"}]}`, err: "msr_modifiers[0] must set bitmap"},
+		{name: "bad bitmap", config: `{"msr_modifiers": [{"addr": "0x10a", "bitmap": "0x0"}]}`, err: "bitmap must be a string of 0, 1 and x"},
+		{name: "bad register", config: `{"cpuid_modifiers": [{"leaf": "0x7", "subleaf": "0x0", "flags": 0, "modifiers": [{"register": "rax", "bitmap": "0bx"}]}]}`, err: "register must be eax, ebx, ecx or edx"},
+		{name: "bad capability", config: `{"kvm_capabilities": [56]}`, err: "list of strings"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseCPUConfig(tc.config)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("Expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestResourceFirecrackerVMCreate_cpuConfig(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stateDir := t.TempDir()
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": image,
+		"cpu_config_json":   testCPUTemplate,
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(context.Background(), d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	data, err := os.ReadFile(filepath.Join(stateDir, "fake", "test", "cpu-config.json"))
+	if err != nil {
+		t.Fatalf("Expected the CPU template to be applied: %v", err)
+	}
+	var applied, want interface{}
+	json.Unmarshal(data, &applied)
+	json.Unmarshal([]byte(testCPUTemplate), &want)
+	got, _ := json.Marshal(applied)
+	if expected, _ := json.Marshal(want); string(got) != string(expected) {
+		t.Errorf("Expected the template to be applied as written, got %s", data)
+	}
+	if !strings.Contains(d.Get("rendered_config_json").(string), `"cpu-config"`) {
+		t.Errorf("Expected the template in rendered_config_json, got %s", d.Get("rendered_config_json"))
+	}
+}
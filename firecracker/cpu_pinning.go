@@ -0,0 +1,174 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// cpuListRegexp matches the CPU lists cpuset.cpus takes, such as "0-3,8,10-11".
+var cpuListRegexp = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`)
+
+// numaNodeRootDir is where the kernel describes the host's NUMA nodes. It is a variable so
+// tests can fake the host.
+var numaNodeRootDir = "/sys/devices/system/node"
+
+// validateCPUList checks that cpuset is a CPU list with ranges running upwards.
+func validateCPUList(v interface{}, k string) ([]string, []error) {
+    value := v.(string)
+    if !cpuListRegexp.MatchString(value) {
+        return nil, []error{fmt.Errorf("%s must be a list of CPUs and CPU ranges, such as \"0-3,8\", got %q", k, value)}
+    }
+    for _, part := range strings.Split(value, ",") {
+        if first, last, ok := strings.Cut(part, "-"); ok {
+            start, _ := strconv.Atoi(first)
+            end, _ := strconv.Atoi(last)
+            if start > end {
+                return nil, []error{fmt.Errorf("%s range %s must not run downwards", k, part)}
+            }
+        }
+    }
+    return nil, nil
+}
+
+// numaNodeCPUs returns the CPUs of a NUMA node of the host, as a CPU list.
+func numaNodeCPUs(node int) (string, error) {
+    data, err := os.ReadFile(filepath.Join(numaNodeRootDir, fmt.Sprintf("node%d", node), "cpulist"))
+    if err != nil {
+        return "", fmt.Errorf("failed to read the CPUs of NUMA node %d: %w", node, err)
+    }
+    return strings.TrimSpace(string(data)), nil
+}
+
+// vmCPUPinning returns the cpuset.cpus and cpuset.mems values pinning the VM's Firecracker
+// process, empty when it is not pinned. Without cpuset, a VM pinned to a NUMA node runs on
+// the CPUs of the node.
+func vmCPUPinning(d *schema.ResourceData) (cpus, mems string, err error) {
+    cpus = d.Get("cpuset").(string)
+    node := d.Get("numa_node").(int)
+    if node < 0 {
+        return cpus, "", nil
+    }
+    if cpus == "" {
+        if cpus, err = numaNodeCPUs(node); err != nil {
+            return "", "", err
+        }
+    }
+    return cpus, strconv.Itoa(node), nil
+}
+
+// cpusetPath returns the directory of the cgroup the jailer places the VM's Firecracker
+// process in, whose cpuset files pin it, if the VM is jailed with the cpuset controller.
+func cpusetPath(d *schema.ResourceData, vmID string) (string, bool) {
+    spec, ok := jailerSpecFromConfig(d.Get("jailer"), vmID)
+    if !ok {
+        return "", false
+    }
+    if spec.CgroupVersion == 2 {
+        return spec.cgroupPaths()[0], true
+    }
+    for i, controller := range spec.CgroupControllers {
+        if controller == "cpuset" {
+            return spec.cgroupPaths()[i], true
+        }
+    }
+    return "", false
+}
+
+// checkCPUPinning fails the plan when cpuset or numa_node is set for a VM whose cpuset
+// cgroup the provider cannot find, or numa_node names a node the host does not have.
+func checkCPUPinning(d *schema.ResourceDiff) error {
+    pinned := d.Get("cpuset").(string) != "" || d.Get("numa_node").(int) >= 0
+    if !pinned {
+        return nil
+    }
+    if len(d.Get("jailer").([]interface{})) == 0 {
+        return fmt.Errorf("cpuset and numa_node require a jailer block, the VM is pinned through the cgroup the jailer creates")
+    }
+    if d.Get("jailer.0.cgroup_version").(int) != 2 {
+        controllers := d.Get("jailer.0.cgroup_controllers").([]interface{})
+        found := len(controllers) == 0
+        for _, controller := range controllers {
+            found = found || controller.(string) == "cpuset"
+        }
+        if !found {
+            return fmt.Errorf("cpuset and numa_node require the cpuset controller in the jailer's cgroup_controllers")
+        }
+    }
+    if node := d.Get("numa_node").(int); node >= 0 && d.Get("host").(string) == "" && d.NewValueKnown("numa_node") {
+        if _, err := os.Stat(filepath.Join(numaNodeRootDir, fmt.Sprintf("node%d", node))); err != nil {
+            return fmt.Errorf("numa_node %d does not exist on this host", node)
+        }
+    }
+    return nil
+}
+
+// applyCPUPinning writes the VM's cpuset and numa_node to the cpuset.cpus and cpuset.mems
+// files of its cgroup, which the jailer created when it started. Removing them gives the
+// VM the CPUs and memory nodes of its parent cgroup back.
+func applyCPUPinning(ctx context.Context, d *schema.ResourceData, vmID string) error {
+    path, ok := cpusetPath(d, vmID)
+    if !ok {
+        return fmt.Errorf("cpuset and numa_node require a jailer block using the cpuset controller")
+    }
+    cpus, mems, err := vmCPUPinning(d)
+    if err != nil {
+        return err
+    }
+
+    // cgroup v1 has no empty cpuset meaning the parent's, so unpinned VMs are given its values
+    if d.Get("jailer.0.cgroup_version").(int) != 2 {
+        for _, value := range []struct {
+            file   string
+            target *string
+        }{{"cpuset.cpus", &cpus}, {"cpuset.mems", &mems}} {
+            if *value.target != "" {
+                continue
+            }
+            data, err := os.ReadFile(filepath.Join(filepath.Dir(path), value.file))
+            if err != nil {
+                return fmt.Errorf("failed to read %s of the parent cgroup of VM %s: %w", value.file, vmID, err)
+            }
+            *value.target = strings.TrimSpace(string(data))
+        }
+    }
+
+    // The memory nodes are written first, so they are never left without a CPU of theirs
+    if err := os.WriteFile(filepath.Join(path, "cpuset.mems"), []byte(mems+"\n"), 0o644); err != nil {
+        return fmt.Errorf("failed to set the NUMA node of VM %s: %w", vmID, err)
+    }
+    if err := os.WriteFile(filepath.Join(path, "cpuset.cpus"), []byte(cpus+"\n"), 0o644); err != nil {
+        return fmt.Errorf("failed to set the CPUs of VM %s: %w", vmID, err)
+    }
+    tflog.Info(ctx, "Pinned VM", map[string]interface{}{
+        "id":          vmID,
+        "cgroup":      path,
+        "cpuset_cpus": cpus,
+        "cpuset_mems": mems,
+    })
+    return nil
+}
+
+// cpuPinningJailerArgs returns the --cgroup arguments pinning the VM's Firecracker process
+// when the jailer starts it, so it runs pinned from the start.
+func cpuPinningJailerArgs(d *schema.ResourceData) ([]string, error) {
+    cpus, mems, err := vmCPUPinning(d)
+    if err != nil {
+        return nil, err
+    }
+    args := []string{}
+    if cpus != "" {
+        args = append(args, "--cgroup", "cpuset.cpus="+cpus)
+    }
+    if mems != "" {
+        args = append(args, "--cgroup", "cpuset.mems="+mems)
+    }
+    return args, nil
+}
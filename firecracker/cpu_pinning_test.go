@@ -0,0 +1,176 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// fakeNUMAHost fakes a host with two NUMA nodes of four CPUs each.
+func fakeNUMAHost(t *testing.T) {
+	t.Helper()
+	original := numaNodeRootDir
+	t.Cleanup(func() { numaNodeRootDir = original })
+	numaNodeRootDir = t.TempDir()
+	for node, cpus := range []string{"0-3", "4-7"} {
+		dir := filepath.Join(numaNodeRootDir, "node"+strconv.Itoa(node))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cpulist"), []byte(cpus+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestValidateCPUList(t *testing.T) {
+	for value, valid := range map[string]bool{
+		"0":        true,
+		"0-3,8":    true,
+		"2,10-11":  true,
+		"":         false,
+		"0-":       false,
+		"3-1":      false,
+		"0, 1":     false,
+		"cpu0-cpu": false,
+	} {
+		if _, errs := validateCPUList(value, "cpuset"); (len(errs) == 0) != valid {
+			t.Errorf("Expected %q valid: %t, got %v", value, valid, errs)
+		}
+	}
+}
+
+func TestApplyCPUPinning(t *testing.T) {
+	fakeNUMAHost(t)
+	original := cgroupRootDir
+	t.Cleanup(func() { cgroupRootDir = original })
+	cgroupRootDir = t.TempDir()
+
+	cgroup := filepath.Join(cgroupRootDir, "firecracker", "vm-1")
+	if err := os.MkdirAll(cgroup, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	read := func(file string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(cgroup, file))
+		if err != nil {
+			t.Fatalf("Expected %s to be written: %v", file, err)
+		}
+		return string(data)
+	}
+
+	// A NUMA node alone pins the VM to the node's CPUs
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"numa_node": 1,
+		"jailer":    []interface{}{map[string]interface{}{"cgroup_version": 2}},
+	})
+	d.SetId("vm-1")
+	if err := applyCPUPinning(context.Background(), d, d.Id()); err != nil {
+		t.Fatalf("Failed to pin VM: %v", err)
+	}
+	if cpus, mems := read("cpuset.cpus"), read("cpuset.mems"); cpus != "4-7\n" || mems != "1\n" {
+		t.Errorf("Expected the VM pinned to the CPUs and memory of node 1, got %q and %q", cpus, mems)
+	}
+
+	// Unpinning hands the VM the CPUs and memory nodes of its parent
+	d = schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"jailer": []interface{}{map[string]interface{}{"cgroup_version": 2}},
+	})
+	if err := applyCPUPinning(context.Background(), d, "vm-1"); err != nil {
+		t.Fatalf("Failed to unpin VM: %v", err)
+	}
+	if cpus, mems := read("cpuset.cpus"), read("cpuset.mems"); cpus != "\n" || mems != "\n" {
+		t.Errorf("Expected the pinning to be cleared, got %q and %q", cpus, mems)
+	}
+}
+
+func TestApplyCPUPinning_cgroupV1(t *testing.T) {
+	original := cgroupRootDir
+	t.Cleanup(func() { cgroupRootDir = original })
+	cgroupRootDir = t.TempDir()
+
+	parent := filepath.Join(cgroupRootDir, "cpuset", "firecracker")
+	cgroup := filepath.Join(parent, "vm-1")
+	if err := os.MkdirAll(cgroup, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(parent, "cpuset.mems"), []byte("0-1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"cpuset": "2-3",
+		"jailer": []interface{}{map[string]interface{}{"cgroup_version": 1}},
+	})
+	if err := applyCPUPinning(context.Background(), d, "vm-1"); err != nil {
+		t.Fatalf("Failed to pin VM: %v", err)
+	}
+	cpus, _ := os.ReadFile(filepath.Join(cgroup, "cpuset.cpus"))
+	mems, _ := os.ReadFile(filepath.Join(cgroup, "cpuset.mems"))
+	if string(cpus) != "2-3\n" || string(mems) != "0-1\n" {
+		t.Errorf("Expected the CPUs to be pinned with the parent's memory nodes, got %q and %q", cpus, mems)
+	}
+}
+
+func TestCheckCPUPinning(t *testing.T) {
+	fakeNUMAHost(t)
+	config := func(pinning map[string]interface{}, jailer map[string]interface{}) map[string]interface{} {
+		config := map[string]interface{}{
+			"kernel_image_path": "/images/vmlinux",
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true},
+			},
+			"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 2, "mem_size_mib": 128}},
+		}
+		if jailer != nil {
+			config["jailer"] = []interface{}{jailer}
+		}
+		for key, value := range pinning {
+			config[key] = value
+		}
+		return config
+	}
+
+	for name, tc := range map[string]struct {
+		config map[string]interface{}
+		err    string
+	}{
+		"cpuset":            {config(map[string]interface{}{"cpuset": "0-1"}, map[string]interface{}{"id": "vm-1", "cgroup_version": 2}), ""},
+		"numa node":         {config(map[string]interface{}{"numa_node": 1}, map[string]interface{}{"id": "vm-1", "cgroup_version": 1}), ""},
+		"missing node":      {config(map[string]interface{}{"numa_node": 2}, map[string]interface{}{"id": "vm-1", "cgroup_version": 2}), "numa_node 2 does not exist"},
+		"without cpuset v1": {config(map[string]interface{}{"cpuset": "0"}, map[string]interface{}{"id": "vm-1", "cgroup_version": 1, "cgroup_controllers": []interface{}{"cpu"}}), "cpuset controller"},
+		"without jailer":    {config(map[string]interface{}{"numa_node": 0}, nil), "require a jailer block"},
+		"unpinned":          {config(nil, nil), ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tc.config), nil)
+			if tc.err == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Errorf("Expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestCPUPinningJailerArgs(t *testing.T) {
+	fakeNUMAHost(t)
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"numa_node": 0,
+	})
+	args, err := cpuPinningJailerArgs(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"--cgroup", "cpuset.cpus=0-3", "--cgroup", "cpuset.mems=0"}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected %v, got %v", expected, args)
+	}
+}
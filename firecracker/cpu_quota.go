@@ -0,0 +1,119 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// cpuMaxPeriod is the period, in microseconds, of the CPU quotas written to cpu.max. It is
+// the kernel's default period.
+const cpuMaxPeriod = 100000
+
+// cpuMaxValue returns the contents of cpu.max enforcing a quota in percent of one CPU, or
+// lifting the limit when percent is 0.
+func cpuMaxValue(percent int) string {
+    if percent == 0 {
+        return fmt.Sprintf("max %d", cpuMaxPeriod)
+    }
+    return fmt.Sprintf("%d %d", percent*cpuMaxPeriod/100, cpuMaxPeriod)
+}
+
+// parseCPUMax returns the quota in percent of one CPU that the contents of cpu.max enforce,
+// 0 when it sets no limit.
+func parseCPUMax(raw string) (int, error) {
+    fields := strings.Fields(raw)
+    if len(fields) == 0 || len(fields) > 2 {
+        return 0, fmt.Errorf("unexpected cpu.max contents %q", strings.TrimSpace(raw))
+    }
+    if fields[0] == "max" {
+        return 0, nil
+    }
+    quota, err := strconv.Atoi(fields[0])
+    if err != nil {
+        return 0, fmt.Errorf("unexpected cpu.max contents %q", strings.TrimSpace(raw))
+    }
+    period := cpuMaxPeriod
+    if len(fields) == 2 {
+        if period, err = strconv.Atoi(fields[1]); err != nil || period <= 0 {
+            return 0, fmt.Errorf("unexpected cpu.max contents %q", strings.TrimSpace(raw))
+        }
+    }
+    return (quota*100 + period/2) / period, nil
+}
+
+// cpuMaxPath returns the cpu.max file of the cgroup the jailer places the VM's Firecracker
+// process in, if the VM is jailed with cgroup v2.
+func cpuMaxPath(d *schema.ResourceData, vmID string) (string, bool) {
+    spec, ok := jailerSpecFromConfig(d.Get("jailer"), vmID)
+    if !ok || spec.CgroupVersion != 2 {
+        return "", false
+    }
+    return filepath.Join(spec.cgroupPaths()[0], "cpu.max"), true
+}
+
+// checkCPUQuota fails the plan when cpu_quota_percent is set for a VM whose cgroup the
+// provider cannot find, or exceeds what the VM's vCPUs can use.
+func checkCPUQuota(d *schema.ResourceDiff) error {
+    percent := d.Get("cpu_quota_percent").(int)
+    if percent == 0 || !d.NewValueKnown("cpu_quota_percent") {
+        return nil
+    }
+    if len(d.Get("jailer").([]interface{})) == 0 || d.Get("jailer.0.cgroup_version").(int) != 2 {
+        return fmt.Errorf("cpu_quota_percent requires a jailer block with cgroup_version = 2, the quota is set on the cgroup the jailer creates")
+    }
+    if d.NewValueKnown("machine_config") {
+        if vcpus := d.Get("machine_config.0.vcpu_count").(int); vcpus > 0 && percent > vcpus*100 {
+            return fmt.Errorf("cpu_quota_percent %d exceeds the %d%% the VM's %d vCPUs can use", percent, vcpus*100, vcpus)
+        }
+    }
+    return nil
+}
+
+// applyCPUQuota writes the VM's cpu_quota_percent to the cpu.max file of its cgroup, which
+// the jailer created when it started. Removing the quota lifts the limit.
+func applyCPUQuota(ctx context.Context, d *schema.ResourceData, vmID string) error {
+    path, ok := cpuMaxPath(d, vmID)
+    if !ok {
+        return fmt.Errorf("cpu_quota_percent requires a jailer block with cgroup_version = 2")
+    }
+    value := cpuMaxValue(d.Get("cpu_quota_percent").(int))
+    if err := os.WriteFile(path, []byte(value+"\n"), 0o644); err != nil {
+        return fmt.Errorf("failed to set the CPU quota of VM %s: %w", vmID, err)
+    }
+    tflog.Info(ctx, "Set CPU quota", map[string]interface{}{
+        "id":      vmID,
+        "cgroup":  filepath.Dir(path),
+        "cpu_max": value,
+    })
+    return nil
+}
+
+// readCPUQuota records the quota the VM's cgroup enforces, so changes made outside
+// Terraform show up in the plan. Nothing is recorded while the cgroup does not exist.
+func readCPUQuota(d *schema.ResourceData) error {
+    path, ok := cpuMaxPath(d, d.Id())
+    if !ok {
+        return nil
+    }
+    raw, err := os.ReadFile(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("failed to read the CPU quota: %w", err)
+    }
+    percent, err := parseCPUMax(string(raw))
+    if err != nil {
+        return err
+    }
+    d.Set("cpu_quota_percent", percent)
+    return nil
+}
@@ -0,0 +1,102 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestCPUMax(t *testing.T) {
+	for percent, expected := range map[int]string{
+		0:   "max 100000",
+		50:  "50000 100000",
+		250: "250000 100000",
+	} {
+		if got := cpuMaxValue(percent); got != expected {
+			t.Errorf("Expected %d%% to be written as %q, got %q", percent, expected, got)
+		}
+		if got, err := parseCPUMax(expected + "\n"); err != nil || got != percent {
+			t.Errorf("Expected %q to parse as %d%%, got %d, %v", expected, percent, got, err)
+		}
+	}
+
+	if got, err := parseCPUMax("25000 50000"); err != nil || got != 50 {
+		t.Errorf("Expected a quota of half the period to be 50%%, got %d, %v", got, err)
+	}
+	if _, err := parseCPUMax("unlimited"); err == nil {
+		t.Error("Expected an error for malformed contents")
+	}
+}
+
+func TestApplyCPUQuota(t *testing.T) {
+	original := cgroupRootDir
+	t.Cleanup(func() { cgroupRootDir = original })
+	cgroupRootDir = t.TempDir()
+
+	cgroup := filepath.Join(cgroupRootDir, "firecracker", "vm-1")
+	if err := os.MkdirAll(cgroup, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"cpu_quota_percent": 150,
+		"jailer":            []interface{}{map[string]interface{}{"cgroup_version": 2}},
+	})
+	d.SetId("vm-1")
+	if err := applyCPUQuota(context.Background(), d, d.Id()); err != nil {
+		t.Fatalf("Failed to apply CPU quota: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(cgroup, "cpu.max")); err != nil || string(data) != "150000 100000\n" {
+		t.Errorf("Expected the quota to be written to cpu.max, got %q, %v", data, err)
+	}
+
+	// A quota changed outside Terraform is reported
+	if err := os.WriteFile(filepath.Join(cgroup, "cpu.max"), []byte("max 100000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := readCPUQuota(d); err != nil || d.Get("cpu_quota_percent").(int) != 0 {
+		t.Errorf("Expected the lifted quota to be read, got %d, %v", d.Get("cpu_quota_percent"), err)
+	}
+}
+
+func TestCheckCPUQuota(t *testing.T) {
+	config := func(quota, cgroupVersion int) map[string]interface{} {
+		return map[string]interface{}{
+			"kernel_image_path": "/images/vmlinux",
+			"cpu_quota_percent": quota,
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true},
+			},
+			"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 2, "mem_size_mib": 128}},
+			"jailer":         []interface{}{map[string]interface{}{"id": "vm-1", "cgroup_version": cgroupVersion}},
+		}
+	}
+
+	withoutJailer := config(50, 2)
+	delete(withoutJailer, "jailer")
+
+	for name, tc := range map[string]struct {
+		config map[string]interface{}
+		err    string
+	}{
+		"quota":          {config(150, 2), ""},
+		"cgroup v1":      {config(50, 1), "cgroup_version = 2"},
+		"exceeds vcpus":  {config(250, 2), "exceeds the 200%"},
+		"without jailer": {withoutJailer, "requires a jailer block"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tc.config), nil)
+			if tc.err == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Errorf("Expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
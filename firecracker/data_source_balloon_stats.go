@@ -0,0 +1,132 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceFirecrackerBalloonStats defines the firecracker_balloon_stats data source, which
+// reports the statistics of a VM's balloon device and the guest memory pressure behind them.
+func dataSourceFirecrackerBalloonStats() *schema.Resource {
+    return &schema.Resource{
+        ReadContext: dataSourceFirecrackerBalloonStatsRead,
+        Schema: map[string]*schema.Schema{
+            "host": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "Name of the provider host to query. Defaults to the provider's base_url.",
+            },
+            "target_pages": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Size the balloon is asked to reach, in 4 KiB pages.",
+            },
+            "actual_pages": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Size the balloon has reached, in 4 KiB pages.",
+            },
+            "target_mib": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Size the balloon is asked to reach, in MiB.",
+            },
+            "actual_mib": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Size the balloon has reached, in MiB.",
+            },
+            "swap_in": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Memory swapped in by the guest, in bytes.",
+            },
+            "swap_out": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Memory swapped out by the guest, in bytes.",
+            },
+            "major_faults": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Page faults in the guest that needed disk I/O.",
+            },
+            "minor_faults": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Page faults in the guest that did not need disk I/O.",
+            },
+            "free_memory": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Guest memory left unused, in bytes.",
+            },
+            "total_memory": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Guest memory available to the guest kernel, in bytes.",
+            },
+            "available_memory": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Guest memory available for new applications without swapping, in bytes.",
+            },
+            "disk_caches": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Guest memory used for disk caches that can be reclaimed quickly, in bytes.",
+            },
+            "hugetlb_allocations": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Successful huge page allocations in the guest.",
+            },
+            "hugetlb_failures": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Failed huge page allocations in the guest.",
+            },
+            "used_memory": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Guest memory that cannot be reclaimed without swapping (total_memory minus available_memory), in bytes.",
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Read: schema.DefaultTimeout(1 * time.Minute),
+        },
+    }
+}
+
+func dataSourceFirecrackerBalloonStatsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client, err := m.(*FirecrackerClient).clientForHost(d.Get("host").(string))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    tflog.Debug(ctx, "Reading balloon statistics", map[string]interface{}{
+        "base_url": client.BaseURL,
+    })
+
+    stats, err := client.GetBalloonStatistics(ctx)
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading balloon statistics: %w", err))
+    }
+
+    d.SetId(client.BaseURL)
+    for _, field := range balloonStatisticFields {
+        d.Set(field, stats.Values[field])
+    }
+    used := 0
+    if stats.Values["total_memory"] > stats.Values["available_memory"] {
+        used = stats.Values["total_memory"] - stats.Values["available_memory"]
+    }
+    d.Set("used_memory", used)
+
+    return nil
+}
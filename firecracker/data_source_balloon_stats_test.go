@@ -0,0 +1,53 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDataSourceFirecrackerBalloonStats(t *testing.T) {
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+
+	d := dataSourceFirecrackerBalloonStats().TestResourceData()
+	diags := dataSourceFirecrackerBalloonStatsRead(context.Background(), d, client)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "stats_polling_interval_s") {
+		t.Fatalf("Expected an error about missing balloon statistics, got %v", diags)
+	}
+
+	stats := `{"target_pages": 65536, "actual_pages": 32768, "target_mib": 256, "actual_mib": 128,
+		"swap_in": 4096, "major_faults": 12, "minor_faults": 3400,
+		"total_memory": 1073741824, "available_memory": 805306368, "free_memory": 536870912}`
+	dir := filepath.Join(stateDir, "fake", "test")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "balloon_statistics.json"), []byte(stats), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if diags := dataSourceFirecrackerBalloonStatsRead(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("Failed to read balloon statistics: %v", diags)
+	}
+	expected := map[string]int{
+		"target_mib":       256,
+		"actual_pages":     32768,
+		"swap_in":          4096,
+		"swap_out":         0,
+		"minor_faults":     3400,
+		"available_memory": 805306368,
+		"used_memory":      268435456,
+		"hugetlb_failures": 0,
+	}
+	for name, value := range expected {
+		if got := d.Get(name).(int); got != value {
+			t.Errorf("Expected %s to be %d, got %d", name, value, got)
+		}
+	}
+	if d.Id() != "fake://test" {
+		t.Errorf("Expected the data source to be identified by its base URL, got %s", d.Id())
+	}
+}
@@ -0,0 +1,124 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceFirecrackerHost defines the firecracker_host data source, which reports whether
+// the host running Terraform can run Firecracker VMs.
+func dataSourceFirecrackerHost() *schema.Resource {
+    return &schema.Resource{
+        ReadContext: dataSourceFirecrackerHostRead,
+        Schema: map[string]*schema.Schema{
+            "firecracker_binary": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Default:     "firecracker",
+                Description: "Firecracker binary to report the version of, a path or a command looked up in PATH.",
+            },
+            "kvm_accessible": {
+                Type:        schema.TypeBool,
+                Computed:    true,
+                Description: "Whether /dev/kvm can be opened for reading and writing. Always true when the provider runs in mock mode.",
+            },
+            "kvm_error": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Why /dev/kvm cannot be opened, when it cannot.",
+            },
+            "kernel_version": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Release of the host's kernel, such as 6.1.0-18-amd64.",
+            },
+            "architecture": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "CPU architecture of the host, amd64 or arm64.",
+            },
+            "cpu_vendor": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "CPU vendor, such as GenuineIntel or AuthenticAMD. On arm64 hosts, the CPU implementer code.",
+            },
+            "cpu_model": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "CPU model name. Empty when the host does not report it.",
+            },
+            "cpu_count": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Number of CPUs available on the host.",
+            },
+            "memory_total_mib": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Total memory of the host in MiB.",
+            },
+            "memory_available_mib": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Memory available for new VMs without swapping in MiB.",
+            },
+            "firecracker_path": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Path of the Firecracker binary. Empty when it is not installed.",
+            },
+            "firecracker_version": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Version of the Firecracker binary, such as 1.10.1. Empty when it is not installed or its version could not be determined.",
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Read: schema.DefaultTimeout(1 * time.Minute),
+        },
+    }
+}
+
+func dataSourceFirecrackerHostRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    info, err := probeHost(ctx, d.Get("firecracker_binary").(string))
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error inspecting host: %w", err))
+    }
+
+    // VMs do not need KVM in mock mode, so preconditions checking for it pass
+    if provider, ok := m.(*FirecrackerClient); ok && provider.mock {
+        info.KVMAccessible = true
+        info.KVMError = ""
+    }
+
+    tflog.Debug(ctx, "Inspected host", map[string]interface{}{
+        "kvm_accessible":      info.KVMAccessible,
+        "kernel_version":      info.KernelVersion,
+        "firecracker_version": info.FirecrackerVersion,
+    })
+
+    hostname, err := os.Hostname()
+    if err != nil {
+        hostname = "localhost"
+    }
+    d.SetId(hostname)
+    d.Set("kvm_accessible", info.KVMAccessible)
+    d.Set("kvm_error", info.KVMError)
+    d.Set("kernel_version", info.KernelVersion)
+    d.Set("architecture", info.Architecture)
+    d.Set("cpu_vendor", info.CPUVendor)
+    d.Set("cpu_model", info.CPUModel)
+    d.Set("cpu_count", info.CPUCount)
+    d.Set("memory_total_mib", info.MemoryTotalMiB)
+    d.Set("memory_available_mib", info.MemoryAvailableMiB)
+    d.Set("firecracker_path", info.FirecrackerPath)
+    d.Set("firecracker_version", info.FirecrackerVersion)
+
+    return nil
+}
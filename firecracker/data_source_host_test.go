@@ -0,0 +1,96 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeHost points host inspection at a fake /proc and /dev/kvm, and pretends Firecracker
+// is installed when version is not empty.
+func fakeHost(t *testing.T, kvm bool, version string) {
+	t.Helper()
+
+	proc := t.TempDir()
+	files := map[string]string{
+		"sys/kernel/osrelease": "6.1.0-18-amd64\n",
+		"cpuinfo": "processor\t: 0\nvendor_id\t: GenuineIntel\nmodel name\t: Intel(R) Xeon(R) Platinum 8375C CPU @ 2.90GHz\n\n" +
+			"processor\t: 1\nvendor_id\t: GenuineIntel\nmodel name\t: Intel(R) Xeon(R) Platinum 8375C CPU @ 2.90GHz\n",
+		"meminfo": "MemTotal:       16384000 kB\nMemFree:         1024000 kB\nMemAvailable:    8192000 kB\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(proc, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kvmPath := filepath.Join(t.TempDir(), "kvm")
+	if kvm {
+		if err := os.WriteFile(kvmPath, nil, 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	originalProc, originalKVM, originalRun, originalLookPath := hostProcDir, kvmDevicePath, runCommand, lookPath
+	t.Cleanup(func() {
+		hostProcDir, kvmDevicePath, runCommand, lookPath = originalProc, originalKVM, originalRun, originalLookPath
+	})
+	hostProcDir, kvmDevicePath = proc, kvmPath
+	lookPath = func(file string) (string, error) {
+		if version == "" {
+			return "", errors.New("executable file not found in $PATH")
+		}
+		return "/usr/local/bin/" + file, nil
+	}
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return []byte("Firecracker " + version + "\n\nSupported snapshot data format versions: v1.0.0\n"), nil
+	}
+}
+
+func TestDataSourceFirecrackerHost(t *testing.T) {
+	fakeHost(t, true, "v1.10.1")
+
+	d := dataSourceFirecrackerHost().TestResourceData()
+	d.Set("firecracker_binary", "firecracker")
+	if diags := dataSourceFirecrackerHostRead(context.Background(), d, nil); diags.HasError() {
+		t.Fatalf("Failed to inspect host: %v", diags)
+	}
+
+	expected := map[string]interface{}{
+		"kvm_accessible":       true,
+		"kvm_error":            "",
+		"kernel_version":       "6.1.0-18-amd64",
+		"cpu_vendor":           "GenuineIntel",
+		"cpu_model":            "Intel(R) Xeon(R) Platinum 8375C CPU @ 2.90GHz",
+		"cpu_count":            2,
+		"memory_total_mib":     16000,
+		"memory_available_mib": 8000,
+		"firecracker_path":     "/usr/local/bin/firecracker",
+		"firecracker_version":  "1.10.1",
+	}
+	for key, value := range expected {
+		if got := d.Get(key); got != value {
+			t.Errorf("Expected %s to be %v, got %v", key, value, got)
+		}
+	}
+}
+
+func TestProbeHost_unsuitable(t *testing.T) {
+	fakeHost(t, false, "")
+
+	info, err := probeHost(context.Background(), "firecracker")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if info.KVMAccessible || info.KVMError == "" {
+		t.Errorf("Expected /dev/kvm to be reported inaccessible with a reason, got %+v", info)
+	}
+	if info.FirecrackerPath != "" || info.FirecrackerVersion != "" {
+		t.Errorf("Expected no Firecracker binary, got %+v", info)
+	}
+}
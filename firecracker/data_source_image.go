@@ -0,0 +1,84 @@
+package firecracker
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// dataSourceFirecrackerImage defines the firecracker_image data source, which fetches a
+// kernel or rootfs image into a local cache on the host running Terraform.
+func dataSourceFirecrackerImage() *schema.Resource {
+    return &schema.Resource{
+        ReadContext: dataSourceFirecrackerImageRead,
+        Schema: map[string]*schema.Schema{
+            "url": {
+                Type:         schema.TypeString,
+                Required:     true,
+                Description:  "URL of the image. Supports http, https and s3 URLs; s3 URLs are downloaded with the AWS CLI.",
+                ValidateFunc: validateImageURL,
+            },
+            "sha256": {
+                Type:         schema.TypeString,
+                Required:     true,
+                Description:  "Expected SHA-256 checksum of the image in hex. The image is rejected if it does not match.",
+                ValidateFunc: validation.StringMatch(sha256Regexp, "must be a hex-encoded SHA-256 checksum"),
+            },
+            "cache_dir": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "Directory the image is cached in. Defaults to images in the provider's state_dir.",
+            },
+            "path": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Local path of the cached image.",
+            },
+            "size": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Size of the image in bytes.",
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Read: schema.DefaultTimeout(20 * time.Minute),
+        },
+    }
+}
+
+func dataSourceFirecrackerImageRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+
+    spec := imageSpec{
+        URL:      d.Get("url").(string),
+        SHA256:   d.Get("sha256").(string),
+        CacheDir: d.Get("cache_dir").(string),
+    }
+    if spec.CacheDir == "" {
+        spec.CacheDir = filepath.Join(provider.StateDir, "images")
+    }
+
+    opCtx, op, err := startHostOperation(ctx, provider.StateDir, "download "+spec.URL)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    path, err := fetchImage(opCtx, spec)
+    if err := op.finish(err); err != nil {
+        return diag.FromErr(err)
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    d.SetId(path)
+    d.Set("path", path)
+    d.Set("size", int(info.Size()))
+
+    return nil
+}
@@ -0,0 +1,109 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func dataSourceFirecrackerInterfaceStats() *schema.Resource {
+    return &schema.Resource{
+        ReadContext: dataSourceFirecrackerInterfaceStatsRead,
+        Schema: map[string]*schema.Schema{
+            "host_dev_name": {
+                Type:         schema.TypeString,
+                Required:     true,
+                Description:  "Name of the TAP device backing the VM's network interface (e.g., 'tap0').",
+                ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+            },
+            "netns": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                Description:  "Name of the network namespace the TAP device is in, as given to the netns of firecracker_tap.",
+                ValidateFunc: validation.StringMatch(netnsNameRegexp, "must be a network namespace name of at most 64 letters, digits, '_', '.' or '-'"),
+            },
+            "rx_bytes": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Bytes received by the guest.",
+            },
+            "rx_packets": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Packets received by the guest.",
+            },
+            "rx_dropped": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Packets dropped on their way to the guest.",
+            },
+            "tx_bytes": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Bytes sent by the guest.",
+            },
+            "tx_packets": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Packets sent by the guest.",
+            },
+            "tx_dropped": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Packets sent by the guest that were dropped on the host.",
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Read: schema.DefaultTimeout(1 * time.Minute),
+        },
+    }
+}
+
+// guestTrafficCounters converts the counters of a TAP device to the guest's point of view.
+// Whatever the host receives on the TAP device was sent by the guest, and vice versa.
+func guestTrafficCounters(stats *hostLinkStats) map[string]int {
+    return map[string]int{
+        "rx_bytes":   int(stats.TxBytes),
+        "rx_packets": int(stats.TxPackets),
+        "rx_dropped": int(stats.TxDropped),
+        "tx_bytes":   int(stats.RxBytes),
+        "tx_packets": int(stats.RxPackets),
+        "tx_dropped": int(stats.RxDropped),
+    }
+}
+
+func dataSourceFirecrackerInterfaceStatsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    var diags diag.Diagnostics
+
+    name := d.Get("host_dev_name").(string)
+    netns := d.Get("netns").(string)
+    tflog.Debug(ctx, "Reading network interface statistics", map[string]interface{}{
+        "host_dev_name": name,
+        "netns":         netns,
+    })
+
+    var stats *hostLinkStats
+    err := withNetNS(netns, func() error {
+        var err error
+        stats, err = getLinkStats(name)
+        return err
+    })
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading statistics of %s: %w", name, err))
+    }
+    if stats == nil {
+        return diag.FromErr(fmt.Errorf("network device %s not found", name))
+    }
+
+    d.SetId(tapID(netns, name))
+    for counter, value := range guestTrafficCounters(stats) {
+        d.Set(counter, value)
+    }
+
+    return diags
+}
@@ -0,0 +1,28 @@
+package firecracker
+
+import "testing"
+
+func TestGuestTrafficCounters(t *testing.T) {
+	counters := guestTrafficCounters(&hostLinkStats{
+		RxBytes:   1000,
+		RxPackets: 10,
+		RxDropped: 1,
+		TxBytes:   2000,
+		TxPackets: 20,
+		TxDropped: 2,
+	})
+
+	expected := map[string]int{
+		"rx_bytes":   2000,
+		"rx_packets": 20,
+		"rx_dropped": 2,
+		"tx_bytes":   1000,
+		"tx_packets": 10,
+		"tx_dropped": 1,
+	}
+	for name, value := range expected {
+		if counters[name] != value {
+			t.Errorf("Expected %s to be %d, got %d", name, value, counters[name])
+		}
+	}
+}
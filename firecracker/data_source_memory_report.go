@@ -0,0 +1,185 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceFirecrackerMemoryReport() *schema.Resource {
+    return &schema.Resource{
+        ReadContext: dataSourceFirecrackerMemoryReportRead,
+        Schema: map[string]*schema.Schema{
+            "selector": {
+                Type:        schema.TypeMap,
+                Optional:    true,
+                Description: "Only report hosts whose labels contain all of these key/value pairs.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "hosts": {
+                Type:        schema.TypeList,
+                Computed:    true,
+                Description: "Memory usage of each host, ordered from the least to the most effectively used memory.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "name": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Name of the host. Empty when the provider is configured with a single base_url.",
+                        },
+                        "reachable": {
+                            Type:        schema.TypeBool,
+                            Computed:    true,
+                            Description: "Whether the host's Firecracker API could be reached.",
+                        },
+                        "committed_mib": {
+                            Type:        schema.TypeInt,
+                            Computed:    true,
+                            Description: "Guest memory configured on the host in MiB.",
+                        },
+                        "ballooned_mib": {
+                            Type:        schema.TypeInt,
+                            Computed:    true,
+                            Description: "Memory reclaimed from the guest by its balloon device in MiB.",
+                        },
+                        "effective_mib": {
+                            Type:        schema.TypeInt,
+                            Computed:    true,
+                            Description: "Memory the guest actually holds in MiB (committed minus ballooned).",
+                        },
+                        "has_balloon": {
+                            Type:        schema.TypeBool,
+                            Computed:    true,
+                            Description: "Whether the guest has a balloon device.",
+                        },
+                    },
+                },
+            },
+            "total_committed_mib": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Guest memory configured across all reachable hosts in MiB.",
+            },
+            "total_ballooned_mib": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Memory reclaimed by balloon devices across all reachable hosts in MiB.",
+            },
+            "total_effective_mib": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Memory held by guests across all reachable hosts in MiB.",
+            },
+            "least_loaded_host": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Name of the reachable host with the least effectively used memory.",
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Read: schema.DefaultTimeout(1 * time.Minute),
+        },
+    }
+}
+
+// hostMemoryUsage is the memory usage reported for one host.
+type hostMemoryUsage struct {
+    Name      string
+    Reachable bool
+    Usage     memoryUsage
+}
+
+// sortHostMemoryUsage orders reachable hosts from the least to the most effectively used
+// memory, followed by unreachable hosts. Ties are broken by name to keep the order stable.
+func sortHostMemoryUsage(hosts []hostMemoryUsage) {
+    sort.SliceStable(hosts, func(i, j int) bool {
+        if hosts[i].Reachable != hosts[j].Reachable {
+            return hosts[i].Reachable
+        }
+        if hosts[i].Usage.EffectiveMiB() != hosts[j].Usage.EffectiveMiB() {
+            return hosts[i].Usage.EffectiveMiB() < hosts[j].Usage.EffectiveMiB()
+        }
+        return hosts[i].Name < hosts[j].Name
+    })
+}
+
+func dataSourceFirecrackerMemoryReportRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    var diags diag.Diagnostics
+
+    // Without a host pool the report covers the provider's base_url
+    hosts := provider.hosts
+    if len(hosts) == 0 {
+        hosts = []*hostEntry{{Client: provider}}
+    } else {
+        hosts = provider.matchingHosts(expandLabels(d.Get("selector").(map[string]interface{})))
+    }
+
+    report := make([]hostMemoryUsage, 0, len(hosts))
+    for _, host := range hosts {
+        entry := hostMemoryUsage{Name: host.Name}
+
+        usage, err := host.Client.GetMemoryUsage(ctx)
+        if err != nil {
+            if !errors.Is(err, errHostUnreachable) {
+                return diag.FromErr(fmt.Errorf("error reading memory usage of host %q: %w", host.Name, err))
+            }
+            diags = append(diags, diag.Diagnostic{
+                Severity: diag.Warning,
+                Summary:  "Firecracker host unreachable",
+                Detail:   fmt.Sprintf("Host %q is left out of the memory totals: %v", host.Name, err),
+            })
+        } else {
+            entry.Reachable = true
+            entry.Usage = *usage
+        }
+
+        report = append(report, entry)
+    }
+
+    sortHostMemoryUsage(report)
+
+    var totalCommitted, totalBallooned, totalEffective int
+    leastLoaded := ""
+    hostsList := make([]map[string]interface{}, 0, len(report))
+    for _, entry := range report {
+        if entry.Reachable {
+            totalCommitted += entry.Usage.CommittedMiB
+            totalBallooned += entry.Usage.BalloonedMiB
+            totalEffective += entry.Usage.EffectiveMiB()
+            if leastLoaded == "" {
+                leastLoaded = entry.Name
+            }
+        }
+
+        hostsList = append(hostsList, map[string]interface{}{
+            "name":          entry.Name,
+            "reachable":     entry.Reachable,
+            "committed_mib": entry.Usage.CommittedMiB,
+            "ballooned_mib": entry.Usage.BalloonedMiB,
+            "effective_mib": entry.Usage.EffectiveMiB(),
+            "has_balloon":   entry.Usage.Ballooned,
+        })
+    }
+
+    tflog.Debug(ctx, "Firecracker memory report completed", map[string]interface{}{
+        "hosts":               len(report),
+        "total_committed_mib": totalCommitted,
+        "total_ballooned_mib": totalBallooned,
+    })
+
+    d.SetId("memory-report")
+    d.Set("hosts", hostsList)
+    d.Set("total_committed_mib", totalCommitted)
+    d.Set("total_ballooned_mib", totalBallooned)
+    d.Set("total_effective_mib", totalEffective)
+    d.Set("least_loaded_host", leastLoaded)
+
+    return diags
+}
@@ -0,0 +1,74 @@
+package firecracker
+
+import (
+    "context"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceFirecrackerVersion defines the firecracker_version data source, which reports
+// the release of the Firecracker process serving the provider's API, or one of its hosts.
+func dataSourceFirecrackerVersion() *schema.Resource {
+    return &schema.Resource{
+        ReadContext: dataSourceFirecrackerVersionRead,
+        Schema: map[string]*schema.Schema{
+            "host": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "Name of the provider host to query. Defaults to the provider's base_url.",
+            },
+            "version": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Firecracker release, such as 1.10.1.",
+            },
+            "major": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Major version of the release.",
+            },
+            "minor": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Minor version of the release.",
+            },
+            "patch": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Patch version of the release.",
+            },
+            "supported_features": {
+                Type:        schema.TypeList,
+                Computed:    true,
+                Description: "VM settings needing a minimum Firecracker release that this release supports, such as entropy_device.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Read: schema.DefaultTimeout(1 * time.Minute),
+        },
+    }
+}
+
+func dataSourceFirecrackerVersionRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client, err := m.(*FirecrackerClient).clientForHost(d.Get("host").(string))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    version, err := client.GetVersion(ctx)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    d.SetId(client.BaseURL)
+    d.Set("version", version.String())
+    d.Set("major", version.Major)
+    d.Set("minor", version.Minor)
+    d.Set("patch", version.Patch)
+    d.Set("supported_features", supportedFeatures(version))
+
+    return nil
+}
@@ -3,6 +3,8 @@ package firecracker
 import (
     "context"
     "fmt"
+    "sort"
+    "strings"
     "time"
 
     "github.com/hashicorp/terraform-plugin-log/tflog"
@@ -15,15 +17,57 @@ func dataSourceFirecrackerVM() *schema.Resource {
         ReadContext: dataSourceFirecrackerVMRead,
         Schema: map[string]*schema.Schema{
             "vm_id": {
+                Type:          schema.TypeString,
+                Optional:      true,
+                Computed:      true,
+                Description:   "ID of the Firecracker VM to retrieve information about. Either this or name and tags must be set.",
+                AtLeastOneOf:  []string{"vm_id", "name", "tags"},
+                ConflictsWith: []string{"name", "tags"},
+            },
+            "name": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Computed:    true,
+                Description: "Look the VM up by its name in the provider's VM registry. Exactly one VM must match it and tags.",
+            },
+            "tags": {
+                Type:        schema.TypeMap,
+                Optional:    true,
+                Computed:    true,
+                Description: "Look the VM up by tags in the provider's VM registry: the VM must have all of them. Exactly one VM must match them and name.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "host": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Computed:    true,
+                Description: "Name of the host from the provider's host pool that runs the VM. Not needed when the provider is configured with a single base_url, or for VMs in the provider's VM registry.",
+            },
+            "state": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "State Firecracker reports for the VM: Not started, Running or Paused.",
+            },
+            "api_socket_path": {
                 Type:        schema.TypeString,
-                Required:    true,
-                Description: "ID of the Firecracker VM to retrieve information about.",
+                Computed:    true,
+                Description: "Path of the API socket of a jailed VM on the host. Empty for VMs that are not jailed or not in the provider's VM registry.",
+            },
+            "ip_address": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Address of the guest from its guest_ip or CNI attachment, without the prefix length. Empty when not known.",
             },
             "kernel_image_path": {
                 Type:        schema.TypeString,
                 Computed:    true,
                 Description: "Path to the kernel image used by the VM.",
             },
+            "initrd_path": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Path to the initrd image used by the VM, if any.",
+            },
             "boot_args": {
                 Type:        schema.TypeString,
                 Computed:    true,
@@ -55,6 +99,11 @@ func dataSourceFirecrackerVM() *schema.Resource {
                             Computed:    true,
                             Description: "Whether the drive is read-only.",
                         },
+                        "partuuid": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Unique ID of the partition holding the root filesystem.",
+                        },
                     },
                 },
             },
@@ -109,10 +158,27 @@ func dataSourceFirecrackerVM() *schema.Resource {
 }
 
 func dataSourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-    client := m.(*FirecrackerClient)
+    provider := m.(*FirecrackerClient)
     var diags diag.Diagnostics
 
+    // VMs are looked up in the registry, which also knows the host of a VM given by ID
     vmID := d.Get("vm_id").(string)
+    host := d.Get("host").(string)
+    record, err := lookupDataSourceVM(provider.vmRegistryDir(), vmID, d.Get("name").(string), host, d.Get("tags").(map[string]interface{}))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    if record != nil {
+        vmID = record.ID
+        if host == "" {
+            host = record.Host
+        }
+    }
+    client, err := provider.clientForHost(host)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
     tflog.Debug(ctx, "Reading Firecracker VM for data source", map[string]interface{}{
         "id": vmID,
     })
@@ -130,12 +196,28 @@ func dataSourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m
 
     // Set the ID
     d.SetId(vmID)
+    d.Set("vm_id", vmID)
+    d.Set("host", host)
+    if record != nil {
+        d.Set("name", record.Name)
+        d.Set("tags", record.Tags)
+        d.Set("api_socket_path", record.APISocketPath)
+        d.Set("ip_address", record.guestAddress())
+    }
+    state, err := client.GetInstanceState(ctx)
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading state of VM %s: %w", vmID, err))
+    }
+    d.Set("state", state)
 
     // Update the resource data based on the VM info
     if bootSource, ok := vmInfo["boot-source"].(map[string]interface{}); ok {
         if kernelPath, ok := bootSource["kernel_image_path"].(string); ok {
             d.Set("kernel_image_path", kernelPath)
         }
+        if initrdPath, ok := bootSource["initrd_path"].(string); ok {
+            d.Set("initrd_path", initrdPath)
+        }
         if bootArgs, ok := bootSource["boot_args"].(string); ok {
             d.Set("boot_args", bootArgs)
         }
@@ -162,6 +244,7 @@ func dataSourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m
                     "path_on_host":   drive["path_on_host"],
                     "is_root_device": drive["is_root_device"],
                     "is_read_only":   drive["is_read_only"],
+                    "partuuid":       drive["partuuid"],
                 }
                 newDrives = append(newDrives, newDrive)
             }
@@ -193,3 +276,54 @@ func dataSourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m
 
     return diags
 }
+
+// lookupDataSourceVM returns the registry record of the VM a firecracker_vm data source is
+// for: the VM with ID vmID, which may not be registered, or else the one VM with the given
+// name, host and tags.
+func lookupDataSourceVM(dir, vmID, name, host string, tags map[string]interface{}) (*vmRecord, error) {
+    if vmID != "" {
+        return lookupVMRecord(dir, vmID)
+    }
+
+    records, err := findRegisteredVMs(dir, name, host, tags)
+    if err != nil {
+        return nil, err
+    }
+    selector := vmSelectorDescription(name, host, tags)
+    switch len(records) {
+    case 0:
+        return nil, fmt.Errorf("no VM in the provider's VM registry has %s", selector)
+    case 1:
+        return &records[0], nil
+    default:
+        ids := make([]string, 0, len(records))
+        for _, record := range records {
+            ids = append(ids, record.ID)
+        }
+        return nil, fmt.Errorf("%d VMs have %s (%s), narrow the lookup down to one or use the firecracker_vms data source", len(records), selector, strings.Join(ids, ", "))
+    }
+}
+
+// vmSelectorDescription describes the name, host and tags VMs are looked up by in errors.
+func vmSelectorDescription(name, host string, tags map[string]interface{}) string {
+    var parts []string
+    if name != "" {
+        parts = append(parts, fmt.Sprintf("name %q", name))
+    }
+    if host != "" {
+        parts = append(parts, fmt.Sprintf("host %q", host))
+    }
+    if len(tags) > 0 {
+        keys := make([]string, 0, len(tags))
+        for key := range tags {
+            keys = append(keys, key)
+        }
+        sort.Strings(keys)
+        pairs := make([]string, 0, len(keys))
+        for _, key := range keys {
+            pairs = append(pairs, fmt.Sprintf("%s=%s", key, tags[key]))
+        }
+        parts = append(parts, "tags "+strings.Join(pairs, ","))
+    }
+    return strings.Join(parts, " and ")
+}
@@ -0,0 +1,134 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// vmStatusUnknown is reported for VMs the provider has no record of.
+const vmStatusUnknown = "unknown"
+
+// asyncCreatePollInterval is how often firecracker_vm_status checks on VMs it waits for.
+var asyncCreatePollInterval = time.Second
+
+// dataSourceFirecrackerVMStatus defines the firecracker_vm_status data source, which reports
+// whether VMs created with async have been provisioned, optionally waiting until they are.
+func dataSourceFirecrackerVMStatus() *schema.Resource {
+    return &schema.Resource{
+        ReadContext: dataSourceFirecrackerVMStatusRead,
+        Schema: map[string]*schema.Schema{
+            "ids": {
+                Type:        schema.TypeList,
+                Required:    true,
+                Description: "IDs of the VMs to report on, such as firecracker_vm.web[*].id.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "wait": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                Default:     false,
+                Description: "When true, reading the data source waits until none of the VMs is still being provisioned, up to the read timeout.",
+            },
+            "statuses": {
+                Type:        schema.TypeMap,
+                Computed:    true,
+                Description: "Status of each VM by ID: `provisioning`, `ready`, `failed`, or `unknown` for VMs the provider has no record of.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "errors": {
+                Type:        schema.TypeMap,
+                Computed:    true,
+                Description: "Error each failed VM's provisioning failed with, by ID.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "pending": {
+                Type:        schema.TypeList,
+                Computed:    true,
+                Description: "IDs of the VMs still being provisioned.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "ready": {
+                Type:        schema.TypeBool,
+                Computed:    true,
+                Description: "Whether every VM is provisioned.",
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Read: schema.DefaultTimeout(30 * time.Minute),
+        },
+    }
+}
+
+func dataSourceFirecrackerVMStatusRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    var ids []string
+    for _, raw := range d.Get("ids").([]interface{}) {
+        id, _ := raw.(string)
+        ids = append(ids, id)
+    }
+
+    for {
+        statuses := map[string]interface{}{}
+        errs := map[string]interface{}{}
+        pending := []string{}
+        ready := true
+        for _, id := range ids {
+            status, message, err := vmProvisioningStatus(provider, id)
+            if err != nil {
+                return diag.FromErr(err)
+            }
+            statuses[id] = status
+            if message != "" {
+                errs[id] = message
+            }
+            if status == asyncCreateProvisioning {
+                pending = append(pending, id)
+            }
+            ready = ready && status == asyncCreateReady
+        }
+
+        if len(pending) == 0 || !d.Get("wait").(bool) {
+            d.SetId(strings.Join(ids, ","))
+            d.Set("statuses", statuses)
+            d.Set("errors", errs)
+            d.Set("pending", pending)
+            d.Set("ready", ready)
+            return nil
+        }
+
+        tflog.Debug(ctx, "Waiting for VMs to be provisioned", map[string]interface{}{
+            "pending": pending,
+        })
+        select {
+        case <-ctx.Done():
+            return diag.FromErr(fmt.Errorf("timed out waiting for VMs %s to be provisioned: %w", strings.Join(pending, ", "), ctx.Err()))
+        case <-time.After(asyncCreatePollInterval):
+        }
+    }
+}
+
+// vmProvisioningStatus returns the provisioning status of a VM, and the error it failed
+// with. VMs created without async are ready once they are registered.
+func vmProvisioningStatus(provider *FirecrackerClient, vmID string) (string, string, error) {
+    record, err := lookupAsyncCreate(provider.StateDir, vmID)
+    if err != nil {
+        return "", "", err
+    }
+    if record != nil {
+        return record.Status, record.Error, nil
+    }
+    registered, err := lookupVMRecord(provider.vmRegistryDir(), vmID)
+    if err != nil {
+        return "", "", err
+    }
+    if registered == nil {
+        return vmStatusUnknown, "", nil
+    }
+    return asyncCreateReady, "", nil
+}
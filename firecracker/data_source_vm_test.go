@@ -0,0 +1,86 @@
+package firecracker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceFirecrackerVM_lookup(t *testing.T) {
+	ctx := context.Background()
+	client := configureFakeProvider(t, t.TempDir())
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+	defer resourceFirecrackerTestImageDelete(ctx, image, nil)
+
+	ids := map[string]string{}
+	for name, ip := range map[string]string{"web": "10.0.0.2/24", "db": "10.0.0.3/24"} {
+		d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+			"name":              name,
+			"tags":              map[string]interface{}{"role": name, "env": "prod"},
+			"guest_ip":          ip,
+			"kernel_image_path": image.Get("path").(string),
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true, "is_read_only": true},
+			},
+			"machine_config": []interface{}{
+				map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+			},
+		})
+		if diags := resourceFirecrackerVMCreate(ctx, d, client); diags.HasError() {
+			t.Fatalf("Failed to create VM %s: %v", name, diags)
+		}
+		ids[name] = d.Id()
+	}
+
+	for name, tc := range map[string]struct {
+		config map[string]interface{}
+		vm     string
+		err    string
+	}{
+		"by ID":     {config: map[string]interface{}{"vm_id": ids["db"]}, vm: "db"},
+		"by name":   {config: map[string]interface{}{"name": "web"}, vm: "web"},
+		"by tags":   {config: map[string]interface{}{"tags": map[string]interface{}{"role": "db", "env": "prod"}}, vm: "db"},
+		"ambiguous": {config: map[string]interface{}{"tags": map[string]interface{}{"env": "prod"}}, err: "2 VMs have tags env=prod"},
+		"no match":  {config: map[string]interface{}{"name": "web", "tags": map[string]interface{}{"role": "db"}}, err: `no VM in the provider's VM registry has name "web" and tags role=db`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, dataSourceFirecrackerVM().Schema, tc.config)
+			diags := dataSourceFirecrackerVMRead(ctx, d, client)
+			if tc.err != "" {
+				if !diags.HasError() || !strings.Contains(diags[0].Summary, tc.err) {
+					t.Fatalf("Expected error containing %q, got %v", tc.err, diags)
+				}
+				return
+			}
+			if diags.HasError() {
+				t.Fatalf("Failed to read VM: %v", diags)
+			}
+			if d.Id() != ids[tc.vm] || d.Get("vm_id") != ids[tc.vm] || d.Get("name") != tc.vm {
+				t.Errorf("Expected VM %s (%s), got %s named %q", tc.vm, ids[tc.vm], d.Id(), d.Get("name"))
+			}
+			if d.Get("state") != "Running" || d.Get("tags.role") != tc.vm {
+				t.Errorf("Expected the running VM with its tags, got state %q and tags %v", d.Get("state"), d.Get("tags"))
+			}
+			if want := map[string]string{"web": "10.0.0.2", "db": "10.0.0.3"}[tc.vm]; d.Get("ip_address") != want {
+				t.Errorf("Expected IP address %s, got %q", want, d.Get("ip_address"))
+			}
+		})
+	}
+
+	// The list can be narrowed down by name too
+	list := dataSourceFirecrackerVMs().TestResourceData()
+	list.Set("name", "db")
+	if diags := dataSourceFirecrackerVMsRead(ctx, list, client); diags.HasError() {
+		t.Fatalf("Failed to list VMs: %v", diags)
+	}
+	if vms := list.Get("vms").([]interface{}); len(vms) != 1 || vms[0].(map[string]interface{})["ip_address"] != "10.0.0.3" {
+		t.Errorf("Expected only VM db with its IP address, got %v", vms)
+	}
+}
@@ -0,0 +1,161 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// dataSourceFirecrackerVMs defines the firecracker_vms data source, which lists the VMs the
+// provider manages from its VM registry, along with their current state.
+func dataSourceFirecrackerVMs() *schema.Resource {
+    return &schema.Resource{
+        ReadContext: dataSourceFirecrackerVMsRead,
+        Schema: map[string]*schema.Schema{
+            "host": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "Only list VMs placed on this host from the provider's host pool.",
+            },
+            "name": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "Only list VMs with this name.",
+            },
+            "tags": {
+                Type:        schema.TypeMap,
+                Optional:    true,
+                Description: "Only list VMs with all of these tags.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "ids": {
+                Type:        schema.TypeList,
+                Computed:    true,
+                Description: "IDs of the listed VMs, ordered.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "vms": {
+                Type:        schema.TypeList,
+                Computed:    true,
+                Description: "VMs managed by the provider, ordered by ID.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "id": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "ID of the VM.",
+                        },
+                        "name": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Name of the VM, if it has one.",
+                        },
+                        "tags": {
+                            Type:        schema.TypeMap,
+                            Computed:    true,
+                            Description: "Tags of the VM.",
+                            Elem:        &schema.Schema{Type: schema.TypeString},
+                        },
+                        "host": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Host from the provider's host pool the VM runs on. Empty for VMs served by the provider's base_url.",
+                        },
+                        "base_url": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "URL of the Firecracker API serving the VM.",
+                        },
+                        "api_socket_path": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Path of the API socket of a jailed VM on the host. Empty for VMs that are not jailed.",
+                        },
+                        "ip_address": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Address of the guest from its guest_ip or CNI attachment, without the prefix length. Empty when not known.",
+                        },
+                        "pid": {
+                            Type:        schema.TypeInt,
+                            Computed:    true,
+                            Description: "PID of the Firecracker process serving a jailed VM, as written by the jailer. 0 when not known.",
+                        },
+                        "state": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "State Firecracker reports for the VM: Not started, Running or Paused. Unreachable when its API cannot be reached.",
+                        },
+                    },
+                },
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Read: schema.DefaultTimeout(1 * time.Minute),
+        },
+    }
+}
+
+func dataSourceFirecrackerVMsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    var diags diag.Diagnostics
+
+    records, err := findRegisteredVMs(provider.vmRegistryDir(), d.Get("name").(string), d.Get("host").(string), d.Get("tags").(map[string]interface{}))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    // VMs served by the same API share its state, which is only asked for once
+    states := map[string]string{}
+    ids := []string{}
+    vms := []map[string]interface{}{}
+    for _, record := range records {
+        state := vmStateUnreachable
+        if client, err := provider.clientForHost(record.Host); err != nil {
+            diags = append(diags, diag.Diagnostic{
+                Severity: diag.Warning,
+                Summary:  "VM on unknown host",
+                Detail:   fmt.Sprintf("VM %s is registered on host %q, which is not in the provider configuration: %v", record.ID, record.Host, err),
+            })
+        } else if cached, ok := states[client.BaseURL]; ok {
+            state = cached
+        } else {
+            state, err = client.GetInstanceState(ctx)
+            if err != nil {
+                if !errors.Is(err, errHostUnreachable) {
+                    return diag.FromErr(fmt.Errorf("error reading state of VM %s: %w", record.ID, err))
+                }
+                tflog.Warn(ctx, "Firecracker API unreachable", map[string]interface{}{
+                    "id":    record.ID,
+                    "error": err.Error(),
+                })
+                state = vmStateUnreachable
+            }
+            states[client.BaseURL] = state
+        }
+
+        ids = append(ids, record.ID)
+        vms = append(vms, map[string]interface{}{
+            "id":              record.ID,
+            "name":            record.Name,
+            "tags":            record.Tags,
+            "host":            record.Host,
+            "base_url":        record.BaseURL,
+            "api_socket_path": record.APISocketPath,
+            "ip_address":      record.guestAddress(),
+            "pid":             record.PID,
+            "state":           state,
+        })
+    }
+
+    d.SetId(provider.vmRegistryDir())
+    d.Set("ids", ids)
+    d.Set("vms", vms)
+
+    return diags
+}
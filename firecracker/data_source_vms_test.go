@@ -0,0 +1,124 @@
+package firecracker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceFirecrackerVMs(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+	defer resourceFirecrackerTestImageDelete(ctx, image, nil)
+
+	vms := map[string]*schema.ResourceData{}
+	for _, name := range []string{"web", "db"} {
+		d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+			"name":              name,
+			"tags":              map[string]interface{}{"role": name, "env": "prod"},
+			"kernel_image_path": image.Get("path").(string),
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true, "is_read_only": true},
+			},
+			"machine_config": []interface{}{
+				map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+			},
+		})
+		if diags := resourceFirecrackerVMCreate(ctx, d, client); diags.HasError() {
+			t.Fatalf("Failed to create VM %s: %v", name, diags)
+		}
+		vms[name] = d
+	}
+
+	list := dataSourceFirecrackerVMs().TestResourceData()
+	if diags := dataSourceFirecrackerVMsRead(ctx, list, client); diags.HasError() {
+		t.Fatalf("Failed to list VMs: %v", diags)
+	}
+	if list.Get("ids.#").(int) != 2 || list.Get("vms.#").(int) != 2 {
+		t.Fatalf("Expected 2 VMs, got %v", list.Get("vms"))
+	}
+	for i, raw := range list.Get("vms").([]interface{}) {
+		vm := raw.(map[string]interface{})
+		expected := vms[vm["name"].(string)]
+		if expected == nil || vm["id"] != expected.Id() || list.Get("ids").([]interface{})[i] != expected.Id() {
+			t.Errorf("Unexpected VM %v", vm)
+		}
+		if tags := vm["tags"].(map[string]interface{}); tags["role"] != vm["name"] || tags["env"] != "prod" {
+			t.Errorf("Expected VM %s to be listed with its tags, got %v", vm["id"], tags)
+		}
+		if vm["state"] != "Running" || vm["base_url"] != "fake://test" {
+			t.Errorf("Expected VM %s to be running on fake://test, got %v", vm["id"], vm)
+		}
+	}
+
+	// VMs are filtered by tags
+	list.Set("tags", map[string]interface{}{"role": "db", "env": "prod"})
+	if diags := dataSourceFirecrackerVMsRead(ctx, list, client); diags.HasError() {
+		t.Fatalf("Failed to list VMs: %v", diags)
+	}
+	if ids := list.Get("ids").([]interface{}); len(ids) != 1 || ids[0] != vms["db"].Id() {
+		t.Errorf("Expected only VM %s to have the tags, got %v", vms["db"].Id(), ids)
+	}
+	list.Set("tags", map[string]interface{}{})
+
+	// Deleted VMs are no longer listed
+	if diags := resourceFirecrackerVMDelete(ctx, vms["db"], client); diags.HasError() {
+		t.Fatalf("Failed to delete VM: %v", diags)
+	}
+	if diags := dataSourceFirecrackerVMsRead(ctx, list, client); diags.HasError() {
+		t.Fatalf("Failed to list VMs: %v", diags)
+	}
+	if ids := list.Get("ids").([]interface{}); len(ids) != 1 || ids[0] != vms["web"].Id() {
+		t.Errorf("Expected only VM %s to be listed, got %v", vms["web"].Id(), ids)
+	}
+
+	// VMs on other hosts are filtered out
+	list.Set("host", "edge-1")
+	if diags := dataSourceFirecrackerVMsRead(ctx, list, client); diags.HasError() {
+		t.Fatalf("Failed to list VMs: %v", diags)
+	}
+	if list.Get("vms.#").(int) != 0 {
+		t.Errorf("Expected no VMs on edge-1, got %v", list.Get("vms"))
+	}
+}
+
+func TestEnsureVMRegistered(t *testing.T) {
+	stateDir := t.TempDir()
+	record := vmRecord{ID: "vm-1", Name: "web", BaseURL: "http://localhost:8080"}
+	if err := ensureVMRegistered(stateDir, record); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	records, err := listRegisteredVMs(stateDir)
+	if err != nil || len(records) != 1 || records[0].Registered.IsZero() {
+		t.Fatalf("Expected one registered VM, got %v, %v", records, err)
+	}
+	registered := records[0].Registered
+
+	// Registering the VM again keeps when it was first registered, but updates the record
+	record.Host = "edge-1"
+	if err := ensureVMRegistered(stateDir, record); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	records, _ = listRegisteredVMs(stateDir)
+	if len(records) != 1 || records[0].Host != "edge-1" || !records[0].Registered.Equal(registered) {
+		t.Errorf("Unexpected records %v", records)
+	}
+
+	if err := unregisterVM(stateDir, "vm-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := unregisterVM(stateDir, "vm-1"); err != nil {
+		t.Errorf("Expected unregistering twice to succeed, got %v", err)
+	}
+	if records, _ := listRegisteredVMs(stateDir); len(records) != 0 {
+		t.Errorf("Expected no registered VMs, got %v", records)
+	}
+}
@@ -0,0 +1,148 @@
+package firecracker
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+
+    sdk "github.com/avkcode/terraform-provider-firecracker/client"
+)
+
+// driveAttachmentMu serializes access to the drive attachments within the provider process.
+var driveAttachmentMu sync.Mutex
+
+// driveAttachment is the record of a firecracker_drive: a drive of a running VM whose image
+// is managed apart from the VM. Firecracker cannot add drives to a running guest, so the VM
+// boots with the drive and firecracker_drive swaps the image it is backed by.
+type driveAttachment struct {
+    VMID       string `json:"vm_id"`
+    DriveID    string `json:"drive_id"`
+    PathOnHost string `json:"path_on_host"`
+
+    // OriginalPath is the image the VM was created with, restored when the drive is detached.
+    OriginalPath string `json:"original_path"`
+}
+
+// driveAttachmentPath returns the file recording the attachment of a VM's drive.
+func driveAttachmentPath(stateDir, vmID, driveID string) string {
+    return filepath.Join(stateDir, "drives", vmID, driveID+".json")
+}
+
+// loadDriveAttachment returns an attachment, or nil if it does not exist.
+func loadDriveAttachment(stateDir, vmID, driveID string) (*driveAttachment, error) {
+    driveAttachmentMu.Lock()
+    defer driveAttachmentMu.Unlock()
+
+    data, err := os.ReadFile(driveAttachmentPath(stateDir, vmID, driveID))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read drive %s of VM %s: %w", driveID, vmID, err)
+    }
+
+    var record driveAttachment
+    if err := json.Unmarshal(data, &record); err != nil {
+        return nil, fmt.Errorf("failed to parse drive %s of VM %s: %w", driveID, vmID, err)
+    }
+    return &record, nil
+}
+
+// saveDriveAttachment records an attachment atomically.
+func saveDriveAttachment(stateDir string, record driveAttachment) error {
+    driveAttachmentMu.Lock()
+    defer driveAttachmentMu.Unlock()
+
+    path := driveAttachmentPath(stateDir, record.VMID, record.DriveID)
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return fmt.Errorf("failed to create drive directory: %w", err)
+    }
+
+    data, err := json.MarshalIndent(record, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode drive %s: %w", record.DriveID, err)
+    }
+
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0o644); err != nil {
+        return fmt.Errorf("failed to write drive %s: %w", record.DriveID, err)
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        return fmt.Errorf("failed to write drive %s: %w", record.DriveID, err)
+    }
+    return nil
+}
+
+// deleteDriveAttachment removes an attachment. Removing one that does not exist is not an
+// error.
+func deleteDriveAttachment(stateDir, vmID, driveID string) error {
+    driveAttachmentMu.Lock()
+    defer driveAttachmentMu.Unlock()
+
+    err := os.Remove(driveAttachmentPath(stateDir, vmID, driveID))
+    if err != nil && !errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("failed to remove drive %s of VM %s: %w", driveID, vmID, err)
+    }
+    return nil
+}
+
+// attachedDriveIDs returns the IDs of the VM's drives managed by firecracker_drive. VM IDs
+// that cannot be directory names have none.
+func attachedDriveIDs(stateDir, vmID string) (map[string]bool, error) {
+    if !networkNameRegexp.MatchString(vmID) {
+        return nil, nil
+    }
+
+    driveAttachmentMu.Lock()
+    defer driveAttachmentMu.Unlock()
+
+    entries, err := os.ReadDir(filepath.Join(stateDir, "drives", vmID))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to list drives of VM %s: %w", vmID, err)
+    }
+
+    ids := map[string]bool{}
+    for _, entry := range entries {
+        if name := entry.Name(); !entry.IsDir() && strings.HasSuffix(name, ".json") {
+            ids[strings.TrimSuffix(name, ".json")] = true
+        }
+    }
+    return ids, nil
+}
+
+// vmDrive returns the drive with the given ID from the VM's configuration, or nil if the VM
+// has no such drive.
+func (c *FirecrackerClient) vmDrive(ctx context.Context, driveID string) (map[string]interface{}, error) {
+    config, err := c.GetVMConfig(ctx)
+    if err != nil {
+        return nil, err
+    }
+    drives, _ := config["drives"].([]interface{})
+    for _, raw := range drives {
+        if drive, ok := raw.(map[string]interface{}); ok && drive["drive_id"] == driveID {
+            return drive, nil
+        }
+    }
+    return nil, nil
+}
+
+// UpdateDrivePath makes a drive of the running VM use another image. The guest sees the
+// new image once it rescans the block device.
+func (c *FirecrackerClient) UpdateDrivePath(ctx context.Context, driveID, pathOnHost string) error {
+    err := c.api().PatchDrive(ctx, sdk.PartialDrive{
+        DriveID:    driveID,
+        PathOnHost: pathOnHost,
+    })
+    if err != nil {
+        return fmt.Errorf("failed to update drive %s: %w", driveID, err)
+    }
+    return nil
+}
@@ -0,0 +1,114 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// createVMWithDataDrive creates a VM with a root drive and a data drive backed by a
+// placeholder image against the fake API.
+func createVMWithDataDrive(t *testing.T, stateDir string) *schema.ResourceData {
+	t.Helper()
+
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": image,
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+			map[string]interface{}{"drive_id": "data", "path_on_host": "/images/placeholder.ext4"},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(context.Background(), d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+	return d
+}
+
+// fakeDrivePath returns the image the fake API has for a drive.
+func fakeDrivePath(t *testing.T, client *FirecrackerClient, driveID string) string {
+	t.Helper()
+
+	drive, err := client.vmDrive(context.Background(), driveID)
+	if err != nil || drive == nil {
+		t.Fatalf("Failed to read drive %s: %v", driveID, err)
+	}
+	return drive["path_on_host"].(string)
+}
+
+func TestResourceFirecrackerDrive(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	vm := createVMWithDataDrive(t, stateDir)
+	provider := configureFakeProvider(t, stateDir)
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerDrive().Schema, map[string]interface{}{
+		"vm_id":        vm.Id(),
+		"drive_id":     "data",
+		"path_on_host": "/volumes/data-v1.ext4",
+	})
+	if diags := resourceFirecrackerDriveCreate(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to attach drive: %v", diags)
+	}
+	if d.Id() != vm.Id()+"/data" || d.Get("original_path") != "/images/placeholder.ext4" {
+		t.Errorf("Expected ID %s/data and the placeholder as original path, got %s and %v", vm.Id(), d.Id(), d.Get("original_path"))
+	}
+	if got := fakeDrivePath(t, provider, "data"); got != "/volumes/data-v1.ext4" {
+		t.Errorf("Expected the drive to use the attached image, got %s", got)
+	}
+
+	// The VM keeps its configured image, so it shows no drift
+	if diags := resourceFirecrackerVMRead(ctx, vm, provider); diags.HasError() {
+		t.Fatalf("Failed to read VM: %v", diags)
+	}
+	if got := vm.Get("drives.1.path_on_host"); got != "/images/placeholder.ext4" {
+		t.Errorf("Expected the VM to keep the placeholder image, got %v", got)
+	}
+
+	// Swapping the image patches the running VM
+	d.Set("path_on_host", "/volumes/data-v2.ext4")
+	if diags := resourceFirecrackerDriveUpdate(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to swap drive: %v", diags)
+	}
+	if got := fakeDrivePath(t, provider, "data"); got != "/volumes/data-v2.ext4" {
+		t.Errorf("Expected the drive to use the swapped image, got %s", got)
+	}
+
+	if diags := resourceFirecrackerDriveDelete(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to detach drive: %v", diags)
+	}
+	if got := fakeDrivePath(t, provider, "data"); got != "/images/placeholder.ext4" {
+		t.Errorf("Expected the placeholder image to be restored, got %s", got)
+	}
+	if record, err := loadDriveAttachment(stateDir, vm.Id(), "data"); err != nil || record != nil {
+		t.Errorf("Expected the attachment to be removed, got %+v, %v", record, err)
+	}
+}
+
+func TestResourceFirecrackerDrive_invalidDrive(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	vm := createVMWithDataDrive(t, stateDir)
+
+	for driveID, want := range map[string]string{
+		"rootfs":  "root device",
+		"missing": "cannot add drives to a running VM",
+	} {
+		d := schema.TestResourceDataRaw(t, resourceFirecrackerDrive().Schema, map[string]interface{}{
+			"vm_id":        vm.Id(),
+			"drive_id":     driveID,
+			"path_on_host": "/volumes/data.ext4",
+		})
+		diags := resourceFirecrackerDriveCreate(ctx, d, configureFakeProvider(t, stateDir))
+		if !diags.HasError() || !strings.Contains(diags[0].Summary, want) {
+			t.Errorf("Expected an error mentioning %q for drive %s, got %v", want, driveID, diags)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package firecracker
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// fakeAPIScheme is the base_url scheme of fake Firecracker APIs served inside the provider,
+// such as fake://default, for running `terraform test` without a hypervisor.
+const fakeAPIScheme = "fake"
+
+// Provider modes: api sends requests to the Firecracker APIs, mock serves every API from a
+// fake one inside the provider.
+const (
+    providerModeAPI  = "api"
+    providerModeMock = "mock"
+)
+
+// mockAPIDefaultName is the fake API serving the provider's base_url in mock mode, and the
+// base_url used when none is configured.
+const mockAPIDefaultName = "default"
+
+// fakeAPIDefaultMachineConfig is what Firecracker reports before the machine is configured.
+var fakeAPIDefaultMachineConfig = []byte(`{"vcpu_count":1,"mem_size_mib":128,"smt":false}`)
+
+// fakeAPIDefaultVersion is the Firecracker release fake APIs report unless a test stores
+// another one in version.json.
+var fakeAPIDefaultVersion = []byte(`{"firecracker_version":"1.10.1"}`)
+
+// isFakeAPIURL reports whether a base URL refers to a fake Firecracker API.
+func isFakeAPIURL(baseURL string) bool {
+    return strings.HasPrefix(baseURL, fakeAPIScheme+"://")
+}
+
+// mockAPIURL returns the fake API serving a Firecracker API in mock mode. Fake APIs serve
+// themselves, other APIs are served by the fake API called name.
+func mockAPIURL(baseURL, name string) string {
+    if isFakeAPIURL(baseURL) {
+        return baseURL
+    }
+    return fakeAPIScheme + "://" + name
+}
+
+// fakeAPIClient returns an HTTP client answering requests for a fake Firecracker API.
+// Its state is kept in files under stateDir, so it survives the provider being restarted
+// between the runs of a test.
+func fakeAPIClient(baseURL, stateDir string) (*http.Client, error) {
+    u, err := url.Parse(baseURL)
+    if err != nil || u.Host == "" {
+        return nil, fmt.Errorf("invalid fake API URL %q, expected fake://<name>", baseURL)
+    }
+    return &http.Client{
+        Transport: &fakeAPITransport{dir: filepath.Join(stateDir, "fake", u.Host)},
+    }, nil
+}
+
+// fakeAPITransport serves the Firecracker API from files: PUT and PATCH store a component,
+// GET returns it. Actions are accepted and recorded in actions.log.
+type fakeAPITransport struct {
+    dir string
+    mu  sync.Mutex
+}
+
+func (t *fakeAPITransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    var body []byte
+    if req.Body != nil {
+        var err error
+        body, err = io.ReadAll(req.Body)
+        req.Body.Close()
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    if err := os.MkdirAll(t.dir, 0o755); err != nil {
+        return nil, fmt.Errorf("failed to create fake API state directory: %w", err)
+    }
+    path := strings.Trim(req.URL.Path, "/")
+    file := filepath.Join(t.dir, strings.ReplaceAll(path, "/", "_")+".json")
+
+    switch {
+    case req.Method == http.MethodPut && (path == "actions" || strings.HasSuffix(path, "/actions")):
+        f, err := os.OpenFile(filepath.Join(t.dir, "actions.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+        if err != nil {
+            return nil, err
+        }
+        defer f.Close()
+        if _, err := fmt.Fprintf(f, "%s\n", bytes.TrimSpace(body)); err != nil {
+            return nil, err
+        }
+        return fakeAPIResponse(req, http.StatusNoContent, nil), nil
+    case req.Method == http.MethodPut || req.Method == http.MethodPatch:
+        if !json.Valid(body) {
+            return fakeAPIResponse(req, http.StatusBadRequest, []byte(`{"fault_message":"invalid JSON body"}`)), nil
+        }
+        if req.Method == http.MethodPatch {
+            merged, err := fakeAPIMerge(file, body)
+            if err != nil {
+                return nil, err
+            }
+            body = merged
+        }
+        if err := os.WriteFile(file, body, 0o644); err != nil {
+            return nil, err
+        }
+        return fakeAPIResponse(req, http.StatusNoContent, nil), nil
+    case req.Method == http.MethodGet:
+        data, err := os.ReadFile(file)
+        if errors.Is(err, os.ErrNotExist) {
+            switch path {
+            case "machine-config":
+                return fakeAPIResponse(req, http.StatusOK, fakeAPIDefaultMachineConfig), nil
+            case "version":
+                return fakeAPIResponse(req, http.StatusOK, fakeAPIDefaultVersion), nil
+            case "":
+                return fakeAPIResponse(req, http.StatusOK, fakeAPIInstanceInfo(t.dir)), nil
+            case "vm/config":
+                return fakeAPIResponse(req, http.StatusOK, fakeAPIVMConfig(t.dir)), nil
+            }
+            return fakeAPIResponse(req, http.StatusNotFound, []byte(`{"fault_message":"not found"}`)), nil
+        } else if err != nil {
+            return nil, err
+        }
+        return fakeAPIResponse(req, http.StatusOK, data), nil
+    default:
+        return fakeAPIResponse(req, http.StatusMethodNotAllowed, []byte(`{"fault_message":"method not allowed"}`)), nil
+    }
+}
+
+// fakeAPIInstanceInfo reports the state of the fake VM the way GET / does: running once it
+// was started or restored from a snapshot, unless it was paused since.
+func fakeAPIInstanceInfo(dir string) []byte {
+    state := "Not started"
+    vm, _ := os.ReadFile(filepath.Join(dir, "vm.json"))
+    if load, err := os.ReadFile(filepath.Join(dir, "snapshot_load.json")); err == nil {
+        // A VM restored from a snapshot is paused until it is resumed
+        state = "Paused"
+        if bytes.Contains(load, []byte(`"resume_vm":true`)) {
+            state = "Running"
+        }
+        if bytes.Contains(vm, []byte(`"Paused"`)) {
+            state = "Paused"
+        } else if bytes.Contains(vm, []byte(`"Resumed"`)) {
+            state = "Running"
+        }
+    } else if actions, err := os.ReadFile(filepath.Join(dir, "actions.log")); err == nil && bytes.Contains(actions, []byte("InstanceStart")) {
+        state = "Running"
+        if bytes.Contains(vm, []byte(`"Paused"`)) {
+            state = "Paused"
+        }
+    }
+    info, _ := json.Marshal(map[string]interface{}{
+        "id":          "anonymous-instance",
+        "state":       state,
+        "vmm_version": "1.10.1",
+        "app_name":    "Firecracker",
+    })
+    return info
+}
+
+// fakeAPIVMConfig assembles the configuration of the fake VM from its stored components,
+// the way GET /vm/config reports it.
+func fakeAPIVMConfig(dir string) []byte {
+    read := func(name string) interface{} {
+        data, err := os.ReadFile(filepath.Join(dir, name))
+        if err != nil {
+            return nil
+        }
+        var component interface{}
+        if json.Unmarshal(data, &component) != nil {
+            return nil
+        }
+        return component
+    }
+
+    config := map[string]interface{}{"machine-config": read("machine-config.json")}
+    if config["machine-config"] == nil {
+        var machineConfig interface{}
+        json.Unmarshal(fakeAPIDefaultMachineConfig, &machineConfig)
+        config["machine-config"] = machineConfig
+    }
+    for key, file := range map[string]string{"boot-source": "boot-source.json", "vsock": "vsock.json", "entropy": "entropy.json", "mmds-config": "mmds_config.json"} {
+        if component := read(file); component != nil {
+            config[key] = component
+        }
+    }
+    for key, prefix := range map[string]string{"drives": "drives_", "network-interfaces": "network-interfaces_"} {
+        components := []interface{}{}
+        files, _ := filepath.Glob(filepath.Join(dir, prefix+"*.json"))
+        sort.Strings(files)
+        for _, file := range files {
+            if component := read(filepath.Base(file)); component != nil {
+                components = append(components, component)
+            }
+        }
+        config[key] = components
+    }
+
+    data, _ := json.Marshal(config)
+    return data
+}
+
+// fakeAPIMerge applies a PATCH body on top of the stored component as a JSON merge patch,
+// as Firecracker does for MMDS: objects are merged and null removes a key.
+func fakeAPIMerge(file string, patch []byte) ([]byte, error) {
+    stored := map[string]interface{}{}
+    if data, err := os.ReadFile(file); err == nil {
+        if err := json.Unmarshal(data, &stored); err != nil {
+            return nil, err
+        }
+    } else if !errors.Is(err, os.ErrNotExist) {
+        return nil, err
+    }
+
+    changes := map[string]interface{}{}
+    if err := json.Unmarshal(patch, &changes); err != nil {
+        return patch, nil
+    }
+    return json.Marshal(fakeAPIMergePatch(stored, changes))
+}
+
+// fakeAPIMergePatch applies a JSON merge patch (RFC 7396) to target.
+func fakeAPIMergePatch(target, patch interface{}) interface{} {
+    patchMap, ok := patch.(map[string]interface{})
+    if !ok {
+        return patch
+    }
+    targetMap, ok := target.(map[string]interface{})
+    if !ok {
+        targetMap = map[string]interface{}{}
+    }
+    for key, value := range patchMap {
+        if value == nil {
+            delete(targetMap, key)
+        } else {
+            targetMap[key] = fakeAPIMergePatch(targetMap[key], value)
+        }
+    }
+    return targetMap
+}
+
+// fakeAPIResponse builds a response to req.
+func fakeAPIResponse(req *http.Request, status int, body []byte) *http.Response {
+    header := http.Header{}
+    if body != nil {
+        header.Set("Content-Type", "application/json")
+    }
+    return &http.Response{
+        Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+        StatusCode:    status,
+        Proto:         "HTTP/1.1",
+        ProtoMajor:    1,
+        ProtoMinor:    1,
+        Header:        header,
+        Body:          io.NopCloser(bytes.NewReader(body)),
+        ContentLength: int64(len(body)),
+        Request:       req,
+    }
+}
@@ -0,0 +1,135 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// configureFakeProvider configures the provider against a fake API, as a new provider
+// process started for a `terraform test` run would.
+func configureFakeProvider(t *testing.T, stateDir string) *FirecrackerClient {
+	t.Helper()
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"base_url":  "fake://test",
+		"state_dir": stateDir,
+	})
+	client, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("Failed to configure provider: %v", diags)
+	}
+	return client.(*FirecrackerClient)
+}
+
+func TestFakeAPI_vmLifecycle(t *testing.T) {
+	stateDir := t.TempDir()
+	ctx := context.Background()
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": image.Get("path").(string),
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 2, "mem_size_mib": 512},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	// A new provider process sees what the previous one configured
+	if diags := resourceFirecrackerVMRead(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to read VM: %v", diags)
+	}
+	if d.Id() == "" || d.Get("machine_config.0.mem_size_mib").(int) != 512 {
+		t.Errorf("Expected the VM to be read back from the fake API, got ID %q and %d MiB", d.Id(), d.Get("machine_config.0.mem_size_mib"))
+	}
+	if d.Get("instance_state") != "Running" || d.Get("vmm_version") != "1.10.1" || d.Get("app_name") != "Firecracker" {
+		t.Errorf("Expected the instance info of the fake API, got %v, %v and %v", d.Get("instance_state"), d.Get("vmm_version"), d.Get("app_name"))
+	}
+
+	if diags := resourceFirecrackerVMDelete(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to delete VM: %v", diags)
+	}
+	actions, err := os.ReadFile(filepath.Join(stateDir, "fake", "test", "actions.log"))
+	if err != nil {
+		t.Fatalf("Failed to read actions: %v", err)
+	}
+	if !strings.Contains(string(actions), "InstanceStart") || !strings.Contains(string(actions), "SendCtrlAltDel") {
+		t.Errorf("Expected the VM to be started and shut down, got actions:\n%s", actions)
+	}
+
+	if diags := resourceFirecrackerTestImageDelete(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to delete test image: %v", diags)
+	}
+	if _, err := os.Stat(image.Id()); !os.IsNotExist(err) {
+		t.Errorf("Expected the test image to be deleted, got %v", err)
+	}
+}
+
+func TestFakeAPIClient_invalidURL(t *testing.T) {
+	if _, err := fakeAPIClient("fake://", t.TempDir()); err == nil {
+		t.Error("Expected an error for a fake API URL without a name")
+	}
+}
+
+func TestMockMode(t *testing.T) {
+	stateDir := t.TempDir()
+	ctx := context.Background()
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"mode":      "mock",
+		"state_dir": stateDir,
+		"host": []interface{}{
+			map[string]interface{}{"name": "node-1", "base_url": "http://10.0.0.1:8080"},
+		},
+	})
+	raw, diags := configureProvider(ctx, d)
+	if diags.HasError() {
+		t.Fatalf("Failed to configure provider: %v", diags)
+	}
+	provider := raw.(*FirecrackerClient)
+
+	// Kernel images are not checked, since there is no Firecracker host
+	vm := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": "/images/vmlinux",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, vm, provider); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+	if vm.Get("host") != "node-1" {
+		t.Errorf("Expected the VM to be placed on node-1, got %v", vm.Get("host"))
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "fake", "node-1", "boot-source.json")); err != nil {
+		t.Errorf("Expected the host's API to be served by the fake API node-1: %v", err)
+	}
+}
+
+func TestMockMode_defaultBaseURL(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"mode":      "mock",
+		"state_dir": t.TempDir(),
+	})
+	raw, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("Failed to configure provider: %v", diags)
+	}
+	if got := raw.(*FirecrackerClient).BaseURL; got != "fake://default" {
+		t.Errorf("Expected base_url fake://default, got %s", got)
+	}
+}
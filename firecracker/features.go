@@ -0,0 +1,85 @@
+package firecracker
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+const (
+    featureWarmPools         = "warm_pools"
+    featureMigration         = "migration"
+    featureContainerdBackend = "containerd_backend"
+)
+
+// experimentalFeatures describes the capabilities that must be enabled through the
+// provider's experiments argument before they can be used.
+var experimentalFeatures = map[string]string{
+    featureWarmPools:         "pools of pre-booted VMs handed out on creation",
+    featureMigration:         "moving VMs between hosts through snapshots",
+    featureContainerdBackend: "running VMs through firecracker-containerd instead of the Firecracker API",
+}
+
+// experimentalFeatureNames returns the names of all experimental features, sorted.
+func experimentalFeatureNames() []string {
+    names := make([]string, 0, len(experimentalFeatures))
+    for name := range experimentalFeatures {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// enabledFeatures parses the provider's experiments argument.
+func enabledFeatures(raw []interface{}) (map[string]bool, error) {
+    features := map[string]bool{}
+    for _, item := range raw {
+        name := item.(string)
+        if _, ok := experimentalFeatures[name]; !ok {
+            return nil, fmt.Errorf("unknown experiment %q, known experiments are %s", name, strings.Join(experimentalFeatureNames(), ", "))
+        }
+        features[name] = true
+    }
+    return features, nil
+}
+
+// featureEnabled reports whether an experimental feature was enabled in the provider configuration.
+func (c *FirecrackerClient) featureEnabled(name string) bool {
+    return c.experiments[name]
+}
+
+// requireFeature returns an error diagnostic explaining how to enable an experimental
+// feature when the configuration uses it without opting in. usedBy names what needs it.
+func (c *FirecrackerClient) requireFeature(name, usedBy string) diag.Diagnostics {
+    if c.featureEnabled(name) {
+        return nil
+    }
+
+    return diag.Diagnostics{{
+        Severity: diag.Error,
+        Summary:  fmt.Sprintf("Experimental feature %q is not enabled", name),
+        Detail: fmt.Sprintf("%s requires the experimental %s feature (%s). Experimental features may change or be removed in future releases. To use it anyway, enable it in the provider configuration:\n\nprovider \"firecracker\" {\n  experiments = [%q]\n}",
+            usedBy, name, experimentalFeatures[name], name),
+    }}
+}
+
+// experimentsWarning reminds the user which experimental features are enabled.
+func experimentsWarning(features map[string]bool) diag.Diagnostics {
+    if len(features) == 0 {
+        return nil
+    }
+
+    names := make([]string, 0, len(features))
+    for name := range features {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    return diag.Diagnostics{{
+        Severity: diag.Warning,
+        Summary:  "Experimental features enabled",
+        Detail:   fmt.Sprintf("The following experimental features are enabled: %s. Their behavior and configuration may change in future releases without a deprecation period.", strings.Join(names, ", ")),
+    }}
+}
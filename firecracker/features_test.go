@@ -0,0 +1,49 @@
+package firecracker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+func TestEnabledFeatures(t *testing.T) {
+	features, err := enabledFeatures([]interface{}{featureWarmPools})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !features[featureWarmPools] || features[featureMigration] {
+		t.Errorf("Unexpected features %v", features)
+	}
+
+	if _, err := enabledFeatures([]interface{}{"teleport"}); err == nil || !strings.Contains(err.Error(), featureMigration) {
+		t.Errorf("Expected an error listing the known experiments, got %v", err)
+	}
+}
+
+func TestRequireFeature(t *testing.T) {
+	client := &FirecrackerClient{experiments: map[string]bool{featureMigration: true}}
+
+	if diags := client.requireFeature(featureMigration, "migrate_to"); diags.HasError() {
+		t.Errorf("Expected an enabled feature to pass, got %v", diags)
+	}
+
+	diags := client.requireFeature(featureWarmPools, "warm_pool")
+	if !diags.HasError() || diags[0].Severity != diag.Error {
+		t.Fatalf("Expected an error for a disabled feature, got %v", diags)
+	}
+	if !strings.Contains(diags[0].Detail, `experiments = ["warm_pools"]`) {
+		t.Errorf("Expected the diagnostic to explain how to enable the feature, got %q", diags[0].Detail)
+	}
+}
+
+func TestExperimentsWarning(t *testing.T) {
+	if diags := experimentsWarning(nil); len(diags) != 0 {
+		t.Errorf("Expected no warning without experiments, got %v", diags)
+	}
+
+	diags := experimentsWarning(map[string]bool{featureMigration: true, featureWarmPools: true})
+	if len(diags) != 1 || diags[0].Severity != diag.Warning || !strings.Contains(diags[0].Detail, "migration, warm_pools") {
+		t.Errorf("Unexpected warning %v", diags)
+	}
+}
@@ -0,0 +1,309 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	// fleetHosts is the number of fake Firecracker hosts VMs are spread across.
+	fleetHosts = 10
+
+	// fleetParallelism matches Terraform's default -parallelism.
+	fleetParallelism = 10
+)
+
+// fakeFirecracker is an in-memory stand-in for the Firecracker API of one host. It stores
+// the components configured with PUT and returns them on GET, after an optional delay
+// simulating the latency of a real API socket.
+type fakeFirecracker struct {
+	latency  time.Duration
+	requests atomic.Int64
+
+	mu         sync.Mutex
+	components map[string][]byte
+}
+
+func (f *fakeFirecracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests.Add(1)
+	if f.latency > 0 {
+		time.Sleep(f.latency)
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.components[r.URL.Path] = body
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		f.mu.Lock()
+		body, ok := f.components[r.URL.Path]
+		f.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// fakeFleet is a provider client whose host pool is made of fake Firecracker hosts.
+type fakeFleet struct {
+	client     *FirecrackerClient
+	hosts      []*fakeFirecracker
+	kernelPath string
+}
+
+// newFakeFleet starts fleetHosts fake Firecracker hosts and returns a provider client
+// placing VMs across them, configured the way configureProvider does.
+func newFakeFleet(tb testing.TB, latency time.Duration) *fakeFleet {
+	tb.Helper()
+
+	kernelPath := filepath.Join(tb.TempDir(), "vmlinux")
+	if err := os.WriteFile(kernelPath, []byte("kernel"), 0o644); err != nil {
+		tb.Fatalf("Failed to write kernel image: %v", err)
+	}
+
+	httpClient := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 20,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	fleet := &fakeFleet{
+		client: &FirecrackerClient{
+			HTTPClient:      httpClient,
+			Timeout:         30 * time.Second,
+			StateDir:        tb.TempDir(),
+			placementGroups: newPlacementGroups(),
+		},
+		kernelPath: kernelPath,
+	}
+
+	for i := 0; i < fleetHosts; i++ {
+		host := &fakeFirecracker{latency: latency, components: map[string][]byte{}}
+		server := httptest.NewServer(host)
+		tb.Cleanup(server.Close)
+
+		fleet.hosts = append(fleet.hosts, host)
+		fleet.client.hosts = append(fleet.client.hosts, &hostEntry{
+			Name:   fmt.Sprintf("host-%d", i),
+			Labels: map[string]string{},
+			Client: &FirecrackerClient{
+				BaseURL:    server.URL,
+				HTTPClient: httpClient,
+				Timeout:    30 * time.Second,
+			},
+		})
+	}
+
+	return fleet
+}
+
+// requests returns the number of API requests the fleet's hosts have served.
+func (f *fakeFleet) requests() int64 {
+	var total int64
+	for _, host := range f.hosts {
+		total += host.requests.Load()
+	}
+	return total
+}
+
+// vms returns the resource data of count firecracker_vm resources with a root drive
+// and a network interface each, as Terraform would pass them to Create.
+func (f *fakeFleet) vms(tb testing.TB, count int) []*schema.ResourceData {
+	tb.Helper()
+
+	vms := make([]*schema.ResourceData, count)
+	for i := range vms {
+		d := resourceFirecrackerVM().TestResourceData()
+		name := "vm-" + strconv.Itoa(i)
+		for key, value := range map[string]interface{}{
+			"name":              name,
+			"kernel_image_path": f.kernelPath,
+			"boot_args":         "console=ttyS0 reboot=k panic=1 pci=off",
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/" + name + ".ext4", "is_root_device": true, "is_read_only": false},
+			},
+			"machine_config": []interface{}{
+				map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 256},
+			},
+			"network_interfaces": []interface{}{
+				map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap" + strconv.Itoa(i)},
+			},
+		} {
+			if err := d.Set(key, value); err != nil {
+				tb.Fatalf("Failed to set %s: %v", key, err)
+			}
+		}
+		vms[i] = d
+	}
+	return vms
+}
+
+// run applies a CRUD operation to every VM with fleetParallelism operations in flight,
+// like Terraform walking a plan, and fails on the first error.
+func (f *fakeFleet) run(tb testing.TB, vms []*schema.ResourceData, op func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) {
+	tb.Helper()
+
+	ctx := context.Background()
+	work := make(chan *schema.ResourceData)
+	errs := make(chan error, len(vms))
+
+	var wg sync.WaitGroup
+	for i := 0; i < fleetParallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range work {
+				if diags := op(ctx, d, f.client); diags.HasError() {
+					errs <- fmt.Errorf("%s: %s: %s", d.Get("name"), diags[0].Summary, diags[0].Detail)
+				}
+			}
+		}()
+	}
+	for _, d := range vms {
+		work <- d
+	}
+	close(work)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// fleetSizes are the fleet sizes the benchmarks run at.
+var fleetSizes = []int{100, 1000}
+
+// reportThroughput reports VMs handled per second and API requests per VM.
+func reportThroughput(b *testing.B, fleet *fakeFleet, vmsPerOp int, requestsBefore int64) {
+	b.ReportMetric(float64(vmsPerOp*b.N)/b.Elapsed().Seconds(), "vms/s")
+	b.ReportMetric(float64(fleet.requests()-requestsBefore)/float64(vmsPerOp*b.N), "requests/vm")
+}
+
+func BenchmarkFleetCreate(b *testing.B) {
+	for _, size := range fleetSizes {
+		b.Run(fmt.Sprintf("vms=%d", size), func(b *testing.B) {
+			fleet := newFakeFleet(b, 0)
+			requestsBefore := fleet.requests()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				vms := fleet.vms(b, size)
+				b.StartTimer()
+
+				fleet.run(b, vms, resourceFirecrackerVMCreate)
+			}
+			reportThroughput(b, fleet, size, requestsBefore)
+		})
+	}
+}
+
+func BenchmarkFleetRefresh(b *testing.B) {
+	for _, size := range fleetSizes {
+		b.Run(fmt.Sprintf("vms=%d", size), func(b *testing.B) {
+			fleet := newFakeFleet(b, 0)
+			vms := fleet.vms(b, size)
+			fleet.run(b, vms, resourceFirecrackerVMCreate)
+			requestsBefore := fleet.requests()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fleet.run(b, vms, resourceFirecrackerVMRead)
+			}
+			reportThroughput(b, fleet, size, requestsBefore)
+		})
+	}
+}
+
+func BenchmarkFleetDestroy(b *testing.B) {
+	for _, size := range fleetSizes {
+		b.Run(fmt.Sprintf("vms=%d", size), func(b *testing.B) {
+			fleet := newFakeFleet(b, 0)
+			var requests int64
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				vms := fleet.vms(b, size)
+				fleet.run(b, vms, resourceFirecrackerVMCreate)
+				requestsBefore := fleet.requests()
+				b.StartTimer()
+
+				fleet.run(b, vms, resourceFirecrackerVMDelete)
+				requests += fleet.requests() - requestsBefore
+			}
+			b.ReportMetric(float64(size*b.N)/b.Elapsed().Seconds(), "vms/s")
+			b.ReportMetric(float64(requests)/float64(size*b.N), "requests/vm")
+		})
+	}
+}
+
+// TestFleetPerformance creates, refreshes and destroys a fleet of 1,000 VMs against fake
+// hosts answering with a realistic API latency, and reports the throughput of each phase.
+// It only runs when FIRECRACKER_PERF_TEST is set. Setting FIRECRACKER_PERF_MIN_VMS_PER_SEC
+// makes it fail when a phase is slower than that.
+func TestFleetPerformance(t *testing.T) {
+	if os.Getenv("FIRECRACKER_PERF_TEST") == "" {
+		t.Skip("FIRECRACKER_PERF_TEST not set")
+	}
+
+	var minThroughput float64
+	if raw := os.Getenv("FIRECRACKER_PERF_MIN_VMS_PER_SEC"); raw != "" {
+		var err error
+		if minThroughput, err = strconv.ParseFloat(raw, 64); err != nil {
+			t.Fatalf("Invalid FIRECRACKER_PERF_MIN_VMS_PER_SEC %q: %v", raw, err)
+		}
+	}
+
+	const size = 1000
+	fleet := newFakeFleet(t, time.Millisecond)
+	vms := fleet.vms(t, size)
+
+	for _, phase := range []struct {
+		name string
+		op   func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics
+	}{
+		{"create", resourceFirecrackerVMCreate},
+		{"refresh", resourceFirecrackerVMRead},
+		{"destroy", resourceFirecrackerVMDelete},
+	} {
+		requestsBefore := fleet.requests()
+		start := time.Now()
+		fleet.run(t, vms, phase.op)
+		elapsed := time.Since(start)
+
+		throughput := float64(size) / elapsed.Seconds()
+		t.Logf("%s: %d VMs in %s, %.1f VMs/s, %.1f requests/VM", phase.name, size, elapsed.Round(time.Millisecond), throughput, float64(fleet.requests()-requestsBefore)/size)
+		if minThroughput > 0 && throughput < minThroughput {
+			t.Errorf("%s throughput of %.1f VMs/s is below the minimum of %.1f", phase.name, throughput, minThroughput)
+		}
+	}
+}
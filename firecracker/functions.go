@@ -0,0 +1,189 @@
+package firecracker
+
+import (
+    "context"
+    "crypto/sha256"
+    "net"
+    "sort"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-go/tfprotov5"
+    "github.com/hashicorp/terraform-plugin-go/tftypes"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// providerFunction is a provider-defined function, called in configurations as
+// provider::firecracker::<name>(...). Terraform 1.8 or later is needed to call them.
+type providerFunction struct {
+    Definition *tfprotov5.Function
+    // Call returns the result for the arguments, which Terraform has already checked
+    // against the parameters of Definition and which are never null or unknown.
+    Call func(args []tftypes.Value) (string, *tfprotov5.FunctionError)
+}
+
+// providerFunctions are the functions the provider defines, by name.
+var providerFunctions = map[string]providerFunction{
+    "mac": {
+        Definition: &tfprotov5.Function{
+            Summary:     "Generate a MAC address for a VM",
+            Description: "Returns a locally administered unicast MAC address derived from name, such as the VM's name, for the guest_mac of a network interface. The same name always gives the same address.",
+            Parameters: []*tfprotov5.FunctionParameter{
+                {Name: "name", Type: tftypes.String, Description: "Name the address is derived from."},
+            },
+            Return: &tfprotov5.FunctionReturn{Type: tftypes.String},
+        },
+        Call: func(args []tftypes.Value) (string, *tfprotov5.FunctionError) {
+            var name string
+            if err := args[0].As(&name); err != nil {
+                return "", functionArgumentError(0, err.Error())
+            }
+            return guestMAC(name), nil
+        },
+    },
+    "boot_args": {
+        Definition: &tfprotov5.Function{
+            Summary:     "Assemble a kernel command line",
+            Description: "Returns the kernel command line for boot_args from a map of kernel parameters, ordered by name. A parameter with an empty value is passed as a flag, without a value.",
+            Parameters: []*tfprotov5.FunctionParameter{
+                {Name: "params", Type: tftypes.Map{ElementType: tftypes.String}, Description: "Kernel parameters, such as { console = \"ttyS0\", root = \"/dev/vda\" }."},
+            },
+            Return: &tfprotov5.FunctionReturn{Type: tftypes.String},
+        },
+        Call: func(args []tftypes.Value) (string, *tfprotov5.FunctionError) {
+            var values map[string]tftypes.Value
+            if err := args[0].As(&values); err != nil {
+                return "", functionArgumentError(0, err.Error())
+            }
+            params := make(map[string]string, len(values))
+            for key, value := range values {
+                var param string
+                if value.IsNull() {
+                    return "", functionArgumentError(0, "kernel parameter "+key+" must not be null")
+                }
+                if err := value.As(&param); err != nil {
+                    return "", functionArgumentError(0, err.Error())
+                }
+                params[key] = param
+            }
+            return bootArgsFromParams(params)
+        },
+    },
+}
+
+// guestMAC derives a locally administered unicast MAC address from name.
+func guestMAC(name string) string {
+    sum := sha256.Sum256([]byte(name))
+    mac := net.HardwareAddr(sum[:6])
+    // Set the locally administered bit and clear the multicast bit
+    mac[0] = mac[0]&^0x01 | 0x02
+    return mac.String()
+}
+
+// bootArgsFromParams joins kernel parameters into a command line, ordered by name.
+func bootArgsFromParams(params map[string]string) (string, *tfprotov5.FunctionError) {
+    keys := make([]string, 0, len(params))
+    for key := range params {
+        if key == "" || key == "--" || strings.ContainsAny(key, " \t\n=") {
+            return "", functionArgumentError(0, "invalid kernel parameter name "+`"`+key+`"`)
+        }
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    fields := make([]string, 0, len(keys))
+    for _, key := range keys {
+        value := params[key]
+        if strings.ContainsAny(value, " \t\n") {
+            return "", functionArgumentError(0, "kernel parameter "+key+" must not contain whitespace")
+        }
+        if value == "" {
+            fields = append(fields, key)
+        } else {
+            fields = append(fields, key+"="+value)
+        }
+    }
+    return strings.Join(fields, " "), nil
+}
+
+// functionArgumentError reports an invalid argument of a provider function.
+func functionArgumentError(arg int64, text string) *tfprotov5.FunctionError {
+    return &tfprotov5.FunctionError{Text: text, FunctionArgument: &arg}
+}
+
+// ProviderServer returns the provider's gRPC server: the SDK's server for the resources and
+// data sources, along with the provider-defined functions, which the SDK does not support.
+func ProviderServer() tfprotov5.ProviderServer {
+    return &functionServer{ProviderServer: schema.NewGRPCProviderServer(Provider())}
+}
+
+// functionServer adds providerFunctions to a provider server.
+type functionServer struct {
+    tfprotov5.ProviderServer
+}
+
+func (s *functionServer) GetMetadata(ctx context.Context, req *tfprotov5.GetMetadataRequest) (*tfprotov5.GetMetadataResponse, error) {
+    resp, err := s.ProviderServer.GetMetadata(ctx, req)
+    if err != nil {
+        return nil, err
+    }
+    for name := range providerFunctions {
+        resp.Functions = append(resp.Functions, tfprotov5.FunctionMetadata{Name: name})
+    }
+    sort.Slice(resp.Functions, func(i, j int) bool { return resp.Functions[i].Name < resp.Functions[j].Name })
+    return resp, nil
+}
+
+func (s *functionServer) GetProviderSchema(ctx context.Context, req *tfprotov5.GetProviderSchemaRequest) (*tfprotov5.GetProviderSchemaResponse, error) {
+    resp, err := s.ProviderServer.GetProviderSchema(ctx, req)
+    if err != nil {
+        return nil, err
+    }
+    resp.Functions = functionDefinitions()
+    return resp, nil
+}
+
+func (s *functionServer) GetFunctions(ctx context.Context, req *tfprotov5.GetFunctionsRequest) (*tfprotov5.GetFunctionsResponse, error) {
+    return &tfprotov5.GetFunctionsResponse{Functions: functionDefinitions()}, nil
+}
+
+func (s *functionServer) CallFunction(ctx context.Context, req *tfprotov5.CallFunctionRequest) (*tfprotov5.CallFunctionResponse, error) {
+    function, ok := providerFunctions[req.Name]
+    if !ok {
+        return &tfprotov5.CallFunctionResponse{Error: &tfprotov5.FunctionError{Text: "unknown function " + req.Name}}, nil
+    }
+
+    params := function.Definition.Parameters
+    if len(req.Arguments) != len(params) {
+        return &tfprotov5.CallFunctionResponse{Error: &tfprotov5.FunctionError{Text: "wrong number of arguments to " + req.Name}}, nil
+    }
+    args := make([]tftypes.Value, len(params))
+    for i, param := range params {
+        value, err := req.Arguments[i].Unmarshal(param.Type)
+        if err != nil {
+            return &tfprotov5.CallFunctionResponse{Error: functionArgumentError(int64(i), err.Error())}, nil
+        }
+        if value.IsNull() || !value.IsKnown() {
+            return &tfprotov5.CallFunctionResponse{Error: functionArgumentError(int64(i), "argument "+param.Name+" must be known and not null")}, nil
+        }
+        args[i] = value
+    }
+
+    result, fnErr := function.Call(args)
+    if fnErr != nil {
+        return &tfprotov5.CallFunctionResponse{Error: fnErr}, nil
+    }
+    value, err := tfprotov5.NewDynamicValue(tftypes.String, tftypes.NewValue(tftypes.String, result))
+    if err != nil {
+        return &tfprotov5.CallFunctionResponse{Error: &tfprotov5.FunctionError{Text: err.Error()}}, nil
+    }
+    return &tfprotov5.CallFunctionResponse{Result: &value}, nil
+}
+
+// functionDefinitions returns the definitions of providerFunctions by name.
+func functionDefinitions() map[string]*tfprotov5.Function {
+    definitions := make(map[string]*tfprotov5.Function, len(providerFunctions))
+    for name, function := range providerFunctions {
+        definitions[name] = function.Definition
+    }
+    return definitions
+}
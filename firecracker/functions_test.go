@@ -0,0 +1,102 @@
+package firecracker
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// callFunction calls a provider function through the provider server, as Terraform does.
+func callFunction(t *testing.T, name string, typ tftypes.Type, arg tftypes.Value) (string, *tfprotov5.FunctionError) {
+	t.Helper()
+
+	dv, err := tfprotov5.NewDynamicValue(typ, arg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ProviderServer().CallFunction(context.Background(), &tfprotov5.CallFunctionRequest{
+		Name:      name,
+		Arguments: []*tfprotov5.DynamicValue{&dv},
+	})
+	if err != nil {
+		t.Fatalf("Failed to call %s: %v", name, err)
+	}
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+	value, err := resp.Result.Unmarshal(tftypes.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result string
+	value.As(&result)
+	return result, nil
+}
+
+func TestFunctionMAC(t *testing.T) {
+	name := tftypes.NewValue(tftypes.String, "web-1")
+	mac, fnErr := callFunction(t, "mac", tftypes.String, name)
+	if fnErr != nil {
+		t.Fatalf("Unexpected error: %s", fnErr.Text)
+	}
+	addr, err := net.ParseMAC(mac)
+	if err != nil {
+		t.Fatalf("Expected a MAC address, got %q", mac)
+	}
+	if addr[0]&0x02 == 0 || addr[0]&0x01 != 0 {
+		t.Errorf("Expected a locally administered unicast address, got %s", mac)
+	}
+
+	if again, _ := callFunction(t, "mac", tftypes.String, name); again != mac {
+		t.Errorf("Expected the same address for the same name, got %s and %s", mac, again)
+	}
+	if other, _ := callFunction(t, "mac", tftypes.String, tftypes.NewValue(tftypes.String, "web-2")); other == mac {
+		t.Errorf("Expected different names to get different addresses, got %s for both", mac)
+	}
+}
+
+func TestFunctionBootArgs(t *testing.T) {
+	typ := tftypes.Map{ElementType: tftypes.String}
+	params := func(values map[string]string) tftypes.Value {
+		elems := map[string]tftypes.Value{}
+		for key, value := range values {
+			elems[key] = tftypes.NewValue(tftypes.String, value)
+		}
+		return tftypes.NewValue(typ, elems)
+	}
+
+	got, fnErr := callFunction(t, "boot_args", typ, params(map[string]string{"root": "/dev/vda", "console": "ttyS0", "ro": "", "reboot": "k"}))
+	if fnErr != nil {
+		t.Fatalf("Unexpected error: %s", fnErr.Text)
+	}
+	if want := "console=ttyS0 reboot=k ro root=/dev/vda"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	for name, values := range map[string]map[string]string{
+		"whitespace in value": {"init": "/sbin/init quiet"},
+		"separator as name":   {"--": ""},
+	} {
+		if _, fnErr := callFunction(t, "boot_args", typ, params(values)); fnErr == nil || fnErr.FunctionArgument == nil {
+			t.Errorf("%s: expected an argument error", name)
+		}
+	}
+}
+
+func TestProviderServer_functions(t *testing.T) {
+	resp, err := ProviderServer().GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"mac", "boot_args"} {
+		if resp.Functions[name] == nil {
+			t.Errorf("Expected function %s in the provider schema", name)
+		}
+	}
+	if resp.ResourceSchemas["firecracker_vm"] == nil {
+		t.Error("Expected the SDK's resources in the provider schema")
+	}
+}
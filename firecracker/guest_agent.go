@@ -0,0 +1,236 @@
+package firecracker
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net"
+    "strings"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// defaultGuestAgentPort is the vsock port the guest agent listens on by default.
+const defaultGuestAgentPort = 52
+
+// guestAgentRetryInterval is how long to wait between attempts to reach the guest agent.
+const guestAgentRetryInterval = 1 * time.Second
+
+// guestAgentPingTimeout bounds the health check made when a VM is refreshed.
+const guestAgentPingTimeout = 5 * time.Second
+
+// guestAgentSpec describes how to reach the guest agent through the VM's vsock device.
+type guestAgentSpec struct {
+    UDSPath       string
+    Port          int
+    HealthTimeout time.Duration
+}
+
+// guestAgentRequest is a single request sent to the guest agent as a line of JSON.
+type guestAgentRequest struct {
+    Type           string `json:"type"`
+    Command        string `json:"command,omitempty"`
+    TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// guestAgentResponse is the guest agent's answer to a request, sent as a line of JSON.
+type guestAgentResponse struct {
+    OK       bool   `json:"ok"`
+    ExitCode int    `json:"exit_code"`
+    Stdout   string `json:"stdout,omitempty"`
+    Stderr   string `json:"stderr,omitempty"`
+    Error    string `json:"error,omitempty"`
+}
+
+// guestExecCommand is a command run in the guest through the agent.
+type guestExecCommand struct {
+    Command string
+    Timeout time.Duration
+}
+
+// guestAgentSpecFromConfig returns how to reach the guest agent described by the VM's
+// guest_agent and vsock blocks, if any.
+func guestAgentSpecFromConfig(d *schema.ResourceData) (guestAgentSpec, bool, error) {
+    raw := d.Get("guest_agent").([]interface{})
+    if len(raw) == 0 || raw[0] == nil {
+        return guestAgentSpec{}, false, nil
+    }
+    cfg := raw[0].(map[string]interface{})
+
+    udsPath, ok := d.GetOk("vsock.0.uds_path")
+    if !ok {
+        return guestAgentSpec{}, false, fmt.Errorf("guest_agent requires a vsock block")
+    }
+
+    timeout, err := time.ParseDuration(cfg["health_timeout"].(string))
+    if err != nil {
+        return guestAgentSpec{}, false, fmt.Errorf("invalid guest_agent health_timeout: %w", err)
+    }
+
+    return guestAgentSpec{
+        UDSPath:       udsPath.(string),
+        Port:          cfg["port"].(int),
+        HealthTimeout: timeout,
+    }, true, nil
+}
+
+// guestExecCommandsFromConfig returns the commands of the VM's guest_exec blocks in order.
+func guestExecCommandsFromConfig(d *schema.ResourceData) ([]guestExecCommand, error) {
+    commands := []guestExecCommand{}
+    for _, raw := range d.Get("guest_exec").([]interface{}) {
+        cfg := raw.(map[string]interface{})
+        timeout, err := time.ParseDuration(cfg["timeout"].(string))
+        if err != nil {
+            return nil, fmt.Errorf("invalid guest_exec timeout: %w", err)
+        }
+        commands = append(commands, guestExecCommand{
+            Command: cfg["command"].(string),
+            Timeout: timeout,
+        })
+    }
+    return commands, nil
+}
+
+// dialGuestAgent connects to the guest agent through the host side of Firecracker's vsock device.
+// Firecracker forwards a connection on the UDS to the guest port named in a CONNECT line.
+func dialGuestAgent(ctx context.Context, spec guestAgentSpec) (net.Conn, *bufio.Reader, error) {
+    dialer := &net.Dialer{}
+    conn, err := dialer.DialContext(ctx, "unix", spec.UDSPath)
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to connect to vsock socket %s: %w", spec.UDSPath, err)
+    }
+
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetDeadline(deadline)
+    }
+
+    if _, err := fmt.Fprintf(conn, "CONNECT %d\n", spec.Port); err != nil {
+        conn.Close()
+        return nil, nil, fmt.Errorf("failed to request vsock port %d: %w", spec.Port, err)
+    }
+
+    reader := bufio.NewReader(conn)
+    ack, err := reader.ReadString('\n')
+    if err != nil {
+        conn.Close()
+        return nil, nil, fmt.Errorf("no answer from guest agent on vsock port %d: %w", spec.Port, err)
+    }
+    if !strings.HasPrefix(ack, "OK ") {
+        conn.Close()
+        return nil, nil, fmt.Errorf("unexpected vsock answer %q", strings.TrimSpace(ack))
+    }
+
+    return conn, reader, nil
+}
+
+// callGuestAgent sends a request to the guest agent and waits for its response.
+func callGuestAgent(ctx context.Context, spec guestAgentSpec, req guestAgentRequest) (*guestAgentResponse, error) {
+    conn, reader, err := dialGuestAgent(ctx, spec)
+    if err != nil {
+        return nil, err
+    }
+    defer conn.Close()
+
+    payload, err := json.Marshal(req)
+    if err != nil {
+        return nil, fmt.Errorf("failed to marshal guest agent request: %w", err)
+    }
+    if _, err := conn.Write(append(payload, '\n')); err != nil {
+        return nil, fmt.Errorf("failed to send guest agent request: %w", err)
+    }
+
+    line, err := reader.ReadBytes('\n')
+    if err != nil {
+        return nil, fmt.Errorf("failed to read guest agent response: %w", err)
+    }
+
+    var resp guestAgentResponse
+    if err := json.Unmarshal(line, &resp); err != nil {
+        return nil, fmt.Errorf("failed to parse guest agent response: %w", err)
+    }
+
+    return &resp, nil
+}
+
+// pingGuestAgent checks that the guest agent answers health checks.
+func pingGuestAgent(ctx context.Context, spec guestAgentSpec) error {
+    resp, err := callGuestAgent(ctx, spec, guestAgentRequest{Type: "ping"})
+    if err != nil {
+        return err
+    }
+    if !resp.OK {
+        return fmt.Errorf("guest agent reported unhealthy: %s", resp.Error)
+    }
+    return nil
+}
+
+// waitForGuestAgent polls the guest agent until it is healthy or the health timeout expires.
+func waitForGuestAgent(operation context.Context, spec guestAgentSpec) error {
+    ctx, cancel := context.WithTimeout(operation, spec.HealthTimeout)
+    defer cancel()
+
+    tflog.Info(ctx, "Waiting for guest agent", map[string]interface{}{
+        "uds_path": spec.UDSPath,
+        "port":     spec.Port,
+    })
+
+    for attempt := 1; ; attempt++ {
+        err := pingGuestAgent(ctx, spec)
+        if err == nil {
+            tflog.Info(ctx, "Guest agent is healthy", map[string]interface{}{
+                "attempts": attempt,
+            })
+            return nil
+        }
+
+        tflog.Debug(ctx, "Guest agent not ready yet", map[string]interface{}{
+            "attempt": attempt,
+            "error":   err.Error(),
+        })
+
+        select {
+        case <-ctx.Done():
+            return waitAbortedError(operation, fmt.Sprintf("guest agent on vsock port %d", spec.Port), spec.HealthTimeout, err)
+        case <-time.After(guestAgentRetryInterval):
+        }
+    }
+}
+
+// execInGuest runs a command in the guest through the agent and fails if it exits non-zero.
+func execInGuest(ctx context.Context, spec guestAgentSpec, cmd guestExecCommand) error {
+    // Give the agent a moment beyond the command timeout to report the result
+    ctx, cancel := context.WithTimeout(ctx, cmd.Timeout+5*time.Second)
+    defer cancel()
+
+    tflog.Info(ctx, "Running command in guest", map[string]interface{}{
+        "command": cmd.Command,
+    })
+
+    resp, err := callGuestAgent(ctx, spec, guestAgentRequest{
+        Type:           "exec",
+        Command:        cmd.Command,
+        TimeoutSeconds: int(cmd.Timeout.Seconds()),
+    })
+    if err != nil {
+        return fmt.Errorf("failed to run %q in guest: %w", cmd.Command, err)
+    }
+
+    tflog.Debug(ctx, "Guest command finished", map[string]interface{}{
+        "command":   cmd.Command,
+        "exit_code": resp.ExitCode,
+        "stdout":    resp.Stdout,
+        "stderr":    resp.Stderr,
+    })
+
+    if resp.Error != "" {
+        return fmt.Errorf("failed to run %q in guest: %s", cmd.Command, resp.Error)
+    }
+    if resp.ExitCode != 0 {
+        return fmt.Errorf("command %q exited with status %d in guest: %s", cmd.Command, resp.ExitCode, strings.TrimSpace(resp.Stderr))
+    }
+
+    return nil
+}
@@ -0,0 +1,104 @@
+package firecracker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startFakeVsock serves the host side of a Firecracker vsock device with a guest agent
+// that answers pings and runs commands through handle.
+func startFakeVsock(t *testing.T, port int, handle func(req guestAgentRequest) guestAgentResponse) string {
+	t.Helper()
+
+	udsPath := filepath.Join(t.TempDir(), "vsock.sock")
+	listener, err := net.Listen("unix", udsPath)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+
+				connect, _ := reader.ReadString('\n')
+				if strings.TrimSpace(connect) != fmt.Sprintf("CONNECT %d", port) {
+					return
+				}
+				fmt.Fprintf(conn, "OK 1073741824\n")
+
+				line, err := reader.ReadBytes('\n')
+				if err != nil {
+					return
+				}
+				var req guestAgentRequest
+				json.Unmarshal(line, &req)
+				payload, _ := json.Marshal(handle(req))
+				conn.Write(append(payload, '\n'))
+			}(conn)
+		}
+	}()
+
+	return udsPath
+}
+
+func TestGuestAgent(t *testing.T) {
+	var commands []string
+	udsPath := startFakeVsock(t, 52, func(req guestAgentRequest) guestAgentResponse {
+		switch req.Type {
+		case "ping":
+			return guestAgentResponse{OK: true}
+		case "exec":
+			commands = append(commands, req.Command)
+			if req.Command == "false" {
+				return guestAgentResponse{OK: true, ExitCode: 1, Stderr: "failed\n"}
+			}
+			return guestAgentResponse{OK: true}
+		default:
+			return guestAgentResponse{Error: "unknown request"}
+		}
+	})
+	spec := guestAgentSpec{UDSPath: udsPath, Port: 52, HealthTimeout: 5 * time.Second}
+
+	if err := waitForGuestAgent(context.Background(), spec); err != nil {
+		t.Fatalf("Expected guest agent to be healthy, got %v", err)
+	}
+
+	if err := execInGuest(context.Background(), spec, guestExecCommand{Command: "hostname web-1", Timeout: time.Second}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	err := execInGuest(context.Background(), spec, guestExecCommand{Command: "false", Timeout: time.Second})
+	if err == nil || !strings.Contains(err.Error(), "exited with status 1") {
+		t.Errorf("Expected exit status error, got %v", err)
+	}
+
+	if strings.Join(commands, ",") != "hostname web-1,false" {
+		t.Errorf("Unexpected commands %v", commands)
+	}
+}
+
+func TestGuestAgent_wrongPort(t *testing.T) {
+	udsPath := startFakeVsock(t, 52, func(req guestAgentRequest) guestAgentResponse {
+		return guestAgentResponse{OK: true}
+	})
+	spec := guestAgentSpec{UDSPath: udsPath, Port: 53, HealthTimeout: 100 * time.Millisecond}
+
+	err := waitForGuestAgent(context.Background(), spec)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "os/exec"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Lifecycle events of a VM that hooks run commands on.
+const (
+    hookEventCreate  = "create"
+    hookEventStart   = "start"
+    hookEventDestroy = "destroy"
+)
+
+// defaultHookInterpreter runs hook commands when the hooks block sets no interpreter.
+var defaultHookInterpreter = []string{"/bin/sh", "-c"}
+
+// runHook runs a hook command with the given interpreter, adding env to the environment of
+// Terraform. It is a variable so tests can record hooks without running them.
+var runHook = func(ctx context.Context, interpreter []string, command string, env []string) ([]byte, error) {
+    cmd := exec.CommandContext(ctx, interpreter[0], append(append([]string{}, interpreter[1:]...), command)...)
+    cmd.Env = append(os.Environ(), env...)
+    return cmd.CombinedOutput()
+}
+
+// hookEnvironment returns the variables describing the VM to its hooks.
+func hookEnvironment(d *schema.ResourceData, client *FirecrackerClient, event string) []string {
+    ip := ""
+    if guestIP, ok := d.GetOk("guest_ip"); ok {
+        ip = hostFromCIDR(guestIP.(string))
+    } else if guestIP, ok := d.GetOk("cni.0.guest_ip"); ok {
+        ip = hostFromCIDR(guestIP.(string))
+    }
+    socket := d.Get("systemd_unit.0.api_socket").(string)
+    if jailer, ok := jailerSpecFromConfig(d.Get("jailer"), d.Id()); ok {
+        socket = jailer.hostPath(jailerAPISocket)
+    }
+
+    return []string{
+        "FIRECRACKER_EVENT=" + event,
+        "FIRECRACKER_VM_ID=" + d.Id(),
+        "FIRECRACKER_VM_NAME=" + d.Get("name").(string),
+        "FIRECRACKER_VM_TAGS=" + tagsJSON(d),
+        "FIRECRACKER_VM_IP=" + ip,
+        "FIRECRACKER_HOST=" + d.Get("host").(string),
+        "FIRECRACKER_BASE_URL=" + client.BaseURL,
+        "FIRECRACKER_API_SOCKET=" + socket,
+    }
+}
+
+// runVMHook runs the command the VM's hooks block has for event, if any, on the host running
+// Terraform.
+func runVMHook(ctx context.Context, d *schema.ResourceData, client *FirecrackerClient, event string) error {
+    blocks := d.Get("hooks").([]interface{})
+    if len(blocks) == 0 || blocks[0] == nil {
+        return nil
+    }
+    block := blocks[0].(map[string]interface{})
+    command, _ := block["on_"+event].(string)
+    if command == "" {
+        return nil
+    }
+    interpreter := defaultHookInterpreter
+    if raw := block["interpreter"].([]interface{}); len(raw) > 0 {
+        interpreter = make([]string, len(raw))
+        for i, arg := range raw {
+            interpreter[i], _ = arg.(string)
+        }
+    }
+
+    tflog.Info(ctx, "Running Firecracker VM hook", map[string]interface{}{
+        "id":    d.Id(),
+        "event": event,
+    })
+    output, err := runHook(ctx, interpreter, command, hookEnvironment(d, client, event))
+    if err != nil {
+        return fmt.Errorf("on_%s hook of VM %s failed: %w: %s", event, d.Id(), err, strings.TrimSpace(string(output)))
+    }
+    tflog.Debug(ctx, "Firecracker VM hook finished", map[string]interface{}{
+        "id":     d.Id(),
+        "event":  event,
+        "output": string(output),
+    })
+    return nil
+}
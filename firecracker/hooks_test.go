@@ -0,0 +1,110 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestResourceFirecrackerVM_hooks(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+
+	type hookRun struct {
+		command string
+		env     map[string]string
+	}
+	runs := []hookRun{}
+	originalRunHook := runHook
+	t.Cleanup(func() { runHook = originalRunHook })
+	runHook = func(ctx context.Context, interpreter []string, command string, env []string) ([]byte, error) {
+		if strings.Join(interpreter, " ") != "/bin/sh -c" {
+			t.Errorf("Expected the default interpreter, got %v", interpreter)
+		}
+		run := hookRun{command: command, env: map[string]string{}}
+		for _, variable := range env {
+			name, value, _ := strings.Cut(variable, "=")
+			run.env[name] = value
+		}
+		runs = append(runs, run)
+		return nil, nil
+	}
+
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"name":              "web",
+		"tags":              map[string]interface{}{"role": "frontend"},
+		"kernel_image_path": image,
+		"guest_ip":          "10.0.0.2/24",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+		"hooks": []interface{}{
+			map[string]interface{}{
+				"on_create":  "register-dns",
+				"on_start":   "enroll-monitoring",
+				"on_destroy": "deregister-dns",
+			},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+	if diags := resourceFirecrackerVMDelete(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to delete VM: %v", diags)
+	}
+
+	expected := []string{"register-dns", "enroll-monitoring", "deregister-dns"}
+	if len(runs) != len(expected) {
+		t.Fatalf("Expected hooks %v, got %v", expected, runs)
+	}
+	for i, event := range []string{"create", "start", "destroy"} {
+		run := runs[i]
+		if run.command != expected[i] || run.env["FIRECRACKER_EVENT"] != event {
+			t.Errorf("Expected %s for the %s event, got %s for %s", expected[i], event, run.command, run.env["FIRECRACKER_EVENT"])
+		}
+		if run.env["FIRECRACKER_VM_ID"] == "" || run.env["FIRECRACKER_VM_NAME"] != "web" || run.env["FIRECRACKER_VM_TAGS"] != `{"role":"frontend"}` || run.env["FIRECRACKER_VM_IP"] != "10.0.0.2" || run.env["FIRECRACKER_BASE_URL"] != "fake://test" {
+			t.Errorf("Unexpected environment of the %s hook: %v", event, run.env)
+		}
+	}
+}
+
+func TestRunVMHook(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "output")
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"hooks": []interface{}{
+			map[string]interface{}{
+				"on_create":  `echo "$FIRECRACKER_EVENT $FIRECRACKER_VM_ID" > ` + output,
+				"on_destroy": "echo unreachable >&2; exit 3",
+			},
+		},
+	})
+	d.SetId("vm-1")
+	client := &FirecrackerClient{BaseURL: "http://localhost:8080"}
+
+	if err := runVMHook(context.Background(), d, client, hookEventCreate); err != nil {
+		t.Fatalf("Failed to run hook: %v", err)
+	}
+	if data, err := os.ReadFile(output); err != nil || string(data) != "create vm-1\n" {
+		t.Errorf("Expected the hook to see the VM, got %q, %v", data, err)
+	}
+
+	// Events without a command run nothing
+	if err := runVMHook(context.Background(), d, client, hookEventStart); err != nil {
+		t.Errorf("Expected no hook to run, got %v", err)
+	}
+
+	err := runVMHook(context.Background(), d, client, hookEventDestroy)
+	var exitErr interface{ ExitCode() int }
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 || !strings.Contains(err.Error(), "unreachable") {
+		t.Errorf("Expected the failure and output of the hook, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "os/exec"
+    "strings"
+)
+
+// iptablesRule is a single rule in an iptables table and chain.
+type iptablesRule struct {
+    Table string
+    Chain string
+    Args  []string
+}
+
+// runCommand executes a host command and returns its combined output.
+// It is a variable so tests can intercept host commands.
+var runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+    return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// natRules returns the rules giving VMs on a bridge outbound access through masquerading.
+// An empty outboundInterface masquerades traffic leaving through any other interface.
+func natRules(bridge, subnet, outboundInterface string) []iptablesRule {
+    comment := []string{"-m", "comment", "--comment", "firecracker:" + bridge}
+
+    masquerade := []string{"-s", subnet, "!", "-o", bridge}
+    forwardOut := []string{"-i", bridge}
+    forwardIn := []string{"-o", bridge}
+    if outboundInterface != "" {
+        masquerade = append(masquerade, "-o", outboundInterface)
+        forwardOut = append(forwardOut, "-o", outboundInterface)
+        forwardIn = append(forwardIn, "-i", outboundInterface)
+    }
+    forwardIn = append(forwardIn, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED")
+
+    return []iptablesRule{
+        {Table: "nat", Chain: "POSTROUTING", Args: append(append(masquerade, comment...), "-j", "MASQUERADE")},
+        {Table: "filter", Chain: "FORWARD", Args: append(append(forwardOut, comment...), "-j", "ACCEPT")},
+        {Table: "filter", Chain: "FORWARD", Args: append(append(forwardIn, comment...), "-j", "ACCEPT")},
+    }
+}
+
+// iptablesRuleExists reports whether the rule is installed.
+func iptablesRuleExists(ctx context.Context, rule iptablesRule) (bool, error) {
+    args := append([]string{"-t", rule.Table, "-C", rule.Chain}, rule.Args...)
+    output, err := runCommand(ctx, "iptables", args...)
+    if err == nil {
+        return true, nil
+    }
+
+    // iptables exits with status 1 when the rule does not exist
+    if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+        return false, nil
+    }
+
+    return false, fmt.Errorf("failed to check iptables rule: %w: %s", err, strings.TrimSpace(string(output)))
+}
+
+// ensureIptablesRule appends the rule unless it is already installed.
+func ensureIptablesRule(ctx context.Context, rule iptablesRule) error {
+    exists, err := iptablesRuleExists(ctx, rule)
+    if err != nil || exists {
+        return err
+    }
+
+    args := append([]string{"-t", rule.Table, "-A", rule.Chain}, rule.Args...)
+    if output, err := runCommand(ctx, "iptables", args...); err != nil {
+        return fmt.Errorf("failed to add iptables rule: %w: %s", err, strings.TrimSpace(string(output)))
+    }
+
+    return nil
+}
+
+// removeIptablesRule deletes the rule if it is installed.
+func removeIptablesRule(ctx context.Context, rule iptablesRule) error {
+    exists, err := iptablesRuleExists(ctx, rule)
+    if err != nil || !exists {
+        return err
+    }
+
+    args := append([]string{"-t", rule.Table, "-D", rule.Chain}, rule.Args...)
+    if output, err := runCommand(ctx, "iptables", args...); err != nil {
+        return fmt.Errorf("failed to delete iptables rule: %w: %s", err, strings.TrimSpace(string(output)))
+    }
+
+    return nil
+}
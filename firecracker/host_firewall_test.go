@@ -0,0 +1,55 @@
+package firecracker
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNATRules(t *testing.T) {
+	rules := natRules("fcbr0", "172.16.0.0/24", "eth0")
+	if len(rules) != 3 {
+		t.Fatalf("Expected 3 rules, got %d", len(rules))
+	}
+
+	masquerade := strings.Join(rules[0].Args, " ")
+	if rules[0].Table != "nat" || rules[0].Chain != "POSTROUTING" {
+		t.Errorf("Expected masquerade rule in nat/POSTROUTING, got %s/%s", rules[0].Table, rules[0].Chain)
+	}
+	if masquerade != "-s 172.16.0.0/24 ! -o fcbr0 -o eth0 -m comment --comment firecracker:fcbr0 -j MASQUERADE" {
+		t.Errorf("Unexpected masquerade rule: %s", masquerade)
+	}
+
+	returnTraffic := strings.Join(rules[2].Args, " ")
+	if !strings.Contains(returnTraffic, "-i eth0") || !strings.Contains(returnTraffic, "RELATED,ESTABLISHED") {
+		t.Errorf("Expected return traffic rule to match established connections from eth0, got %s", returnTraffic)
+	}
+}
+
+func TestNATRules_anyOutboundInterface(t *testing.T) {
+	for _, rule := range natRules("fcbr0", "172.16.0.0/24", "") {
+		args := strings.Join(rule.Args, " ")
+		if strings.Contains(args, "eth0") {
+			t.Errorf("Expected no outbound interface in rule, got %s", args)
+		}
+	}
+}
+
+func TestEnsureIptablesRule(t *testing.T) {
+	var commands []string
+	original := runCommand
+	defer func() { runCommand = original }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		return nil, nil
+	}
+
+	// The check succeeds, so the rule is already installed and must not be appended again
+	rule := natRules("fcbr0", "172.16.0.0/24", "")[0]
+	if err := ensureIptablesRule(context.Background(), rule); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(commands) != 1 || !strings.HasPrefix(commands[0], "iptables -t nat -C POSTROUTING") {
+		t.Errorf("Expected a single check command, got %v", commands)
+	}
+}
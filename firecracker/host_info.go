@@ -0,0 +1,137 @@
+package firecracker
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "os"
+    "runtime"
+    "strconv"
+    "strings"
+)
+
+// hostProcDir and kvmDevicePath are where host capabilities are read from. They are
+// variables so tests can fake the host.
+var (
+    hostProcDir   = "/proc"
+    kvmDevicePath = "/dev/kvm"
+)
+
+// hostInfo describes the capabilities of the host running Terraform relevant to running
+// Firecracker VMs.
+type hostInfo struct {
+    KVMAccessible bool
+    // KVMError says why /dev/kvm cannot be opened, when it cannot.
+    KVMError           string
+    KernelVersion      string
+    Architecture       string
+    CPUVendor          string
+    CPUModel           string
+    CPUCount           int
+    MemoryTotalMiB     int
+    MemoryAvailableMiB int
+    // FirecrackerPath is the Firecracker binary found, if any.
+    FirecrackerPath    string
+    FirecrackerVersion string
+}
+
+// probeHost inspects the host running Terraform. Capabilities that cannot be determined are
+// left empty rather than failing, so the result can be checked in preconditions; only an
+// unreadable /proc is an error.
+func probeHost(ctx context.Context, firecrackerBinary string) (*hostInfo, error) {
+    info := &hostInfo{Architecture: runtime.GOARCH}
+
+    // Firecracker needs read and write access to /dev/kvm
+    if f, err := os.OpenFile(kvmDevicePath, os.O_RDWR, 0); err != nil {
+        info.KVMError = err.Error()
+    } else {
+        f.Close()
+        info.KVMAccessible = true
+    }
+
+    release, err := os.ReadFile(hostProcDir + "/sys/kernel/osrelease")
+    if err != nil {
+        return nil, fmt.Errorf("failed to read the kernel version: %w", err)
+    }
+    info.KernelVersion = strings.TrimSpace(string(release))
+
+    if err := readCPUInfo(info); err != nil {
+        return nil, err
+    }
+    if err := readMemInfo(info); err != nil {
+        return nil, err
+    }
+
+    if path, err := lookPath(firecrackerBinary); err == nil {
+        info.FirecrackerPath = path
+        // Firecracker prints "Firecracker v1.10.1" followed by build details
+        if output, err := runCommand(ctx, path, "--version"); err == nil {
+            fields := strings.Fields(string(output))
+            if len(fields) >= 2 {
+                if version, err := parseFirecrackerVersion(fields[1]); err == nil {
+                    info.FirecrackerVersion = version.String()
+                }
+            }
+        }
+    }
+    return info, nil
+}
+
+// readCPUInfo fills in the CPU vendor, model and count from /proc/cpuinfo. x86 hosts report
+// vendor_id and model name, arm64 hosts only the CPU implementer.
+func readCPUInfo(info *hostInfo) error {
+    f, err := os.Open(hostProcDir + "/cpuinfo")
+    if err != nil {
+        return fmt.Errorf("failed to read CPU information: %w", err)
+    }
+    defer f.Close()
+
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        key, value, ok := strings.Cut(scanner.Text(), ":")
+        if !ok {
+            continue
+        }
+        key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+        switch {
+        case key == "processor":
+            info.CPUCount++
+        case (key == "vendor_id" || key == "CPU implementer") && info.CPUVendor == "":
+            info.CPUVendor = value
+        case key == "model name" && info.CPUModel == "":
+            info.CPUModel = value
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return fmt.Errorf("failed to read CPU information: %w", err)
+    }
+    if info.CPUCount == 0 {
+        info.CPUCount = runtime.NumCPU()
+    }
+    return nil
+}
+
+// readMemInfo fills in the host's total and available memory from /proc/meminfo.
+func readMemInfo(info *hostInfo) error {
+    data, err := os.ReadFile(hostProcDir + "/meminfo")
+    if err != nil {
+        return fmt.Errorf("failed to read memory information: %w", err)
+    }
+    for _, line := range strings.Split(string(data), "\n") {
+        fields := strings.Fields(line)
+        if len(fields) < 2 {
+            continue
+        }
+        kib, err := strconv.Atoi(fields[1])
+        if err != nil {
+            continue
+        }
+        switch fields[0] {
+        case "MemTotal:":
+            info.MemoryTotalMiB = kib / 1024
+        case "MemAvailable:":
+            info.MemoryAvailableMiB = kib / 1024
+        }
+    }
+    return nil
+}
@@ -0,0 +1,56 @@
+package firecracker
+
+import (
+    "net"
+    "path/filepath"
+    "regexp"
+)
+
+// netFlagUp mirrors net.FlagUp for checking link state reported by netlink.
+const netFlagUp = net.FlagUp
+
+// netnsDir is where named network namespaces are mounted, as by ip netns.
+const netnsDir = "/run/netns"
+
+// netnsNameRegexp matches the names of network namespaces, which are file names in netnsDir.
+var netnsNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// netnsPath returns the path of a named network namespace.
+func netnsPath(name string) string {
+    return filepath.Join(netnsDir, name)
+}
+
+// tapDeviceSpec describes a TAP device to create on the host.
+type tapDeviceSpec struct {
+    Name  string
+    Owner int
+    Group int
+    MTU   int
+}
+
+// bondSpec describes a bonding device aggregating several host NICs.
+type bondSpec struct {
+    Name    string
+    Mode    string
+    Miimon  int
+    Members []string
+}
+
+// hostLinkInfo is the subset of host link state tracked by the network resources.
+type hostLinkInfo struct {
+    Name   string
+    Type   string
+    MTU    int
+    Master string
+    Up     bool
+}
+
+// hostLinkStats holds the traffic counters of a host network link as seen by the host.
+type hostLinkStats struct {
+    RxBytes   uint64
+    RxPackets uint64
+    RxDropped uint64
+    TxBytes   uint64
+    TxPackets uint64
+    TxDropped uint64
+}
@@ -0,0 +1,452 @@
+//go:build linux
+
+package firecracker
+
+import (
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "runtime"
+
+    "github.com/vishvananda/netlink"
+    "github.com/vishvananda/netns"
+    "golang.org/x/sys/unix"
+)
+
+// ipForwardPath is the sysctl controlling IPv4 forwarding between interfaces.
+const ipForwardPath = "/proc/sys/net/ipv4/ip_forward"
+
+// linkSetMTU sets the MTU of a host network link, replaced in tests.
+var linkSetMTU = netlink.LinkSetMTU
+
+// createTapDevice creates a persistent TAP device on the local host and brings it up.
+// The flags match the ones Firecracker uses when it opens the device (no packet info,
+// virtio-net header), so the device can be handed to a VM as-is. The owner and group are
+// only set when they are not -1, as the kernel rejects -1 rather than leaving them unset.
+// A device that cannot be set up is removed again, so creating it again does not fail on it.
+func createTapDevice(spec tapDeviceSpec) (err error) {
+    if err := addTapDevice(spec); err != nil {
+        return fmt.Errorf("failed to create TAP device %s: %w", spec.Name, err)
+    }
+
+    tap, err := netlink.LinkByName(spec.Name)
+    if err != nil {
+        return fmt.Errorf("failed to find TAP device %s: %w", spec.Name, err)
+    }
+    defer func() {
+        if err != nil {
+            if delErr := netlink.LinkDel(tap); delErr != nil {
+                err = fmt.Errorf("%w (removing %s also failed: %v)", err, spec.Name, delErr)
+            }
+        }
+    }()
+
+    if spec.MTU > 0 {
+        if err := linkSetMTU(tap, spec.MTU); err != nil {
+            return fmt.Errorf("failed to set MTU on %s: %w", spec.Name, err)
+        }
+    }
+
+    if err := netlink.LinkSetUp(tap); err != nil {
+        return fmt.Errorf("failed to bring up %s: %w", spec.Name, err)
+    }
+
+    return nil
+}
+
+// addTapDevice creates a TAP device through /dev/net/tun and makes it persistent, so the
+// file descriptor used to create it can be released. netlink always sets the owner and
+// group, so it cannot create devices without them.
+func addTapDevice(spec tapDeviceSpec) error {
+    fd, err := unix.Open("/dev/net/tun", unix.O_RDWR|unix.O_CLOEXEC, 0)
+    if err != nil {
+        return fmt.Errorf("failed to open /dev/net/tun: %w", err)
+    }
+    defer unix.Close(fd)
+
+    ifr, err := unix.NewIfreq(spec.Name)
+    if err != nil {
+        return err
+    }
+    ifr.SetUint16(unix.IFF_TAP | unix.IFF_NO_PI | unix.IFF_VNET_HDR)
+    if err := unix.IoctlIfreq(fd, unix.TUNSETIFF, ifr); err != nil {
+        return fmt.Errorf("TUNSETIFF failed: %w", err)
+    }
+
+    // Until it is made persistent, the device goes away with the file descriptor
+    if spec.Owner >= 0 {
+        if err := unix.IoctlSetInt(fd, unix.TUNSETOWNER, spec.Owner); err != nil {
+            return fmt.Errorf("failed to set owner %d: %w", spec.Owner, err)
+        }
+    }
+    if spec.Group >= 0 {
+        if err := unix.IoctlSetInt(fd, unix.TUNSETGROUP, spec.Group); err != nil {
+            return fmt.Errorf("failed to set group %d: %w", spec.Group, err)
+        }
+    }
+    if err := unix.IoctlSetInt(fd, unix.TUNSETPERSIST, 1); err != nil {
+        return fmt.Errorf("TUNSETPERSIST failed: %w", err)
+    }
+    return nil
+}
+
+// createBridge creates a Linux bridge on the local host and brings it up.
+func createBridge(name string, mtu int) error {
+    bridge := &netlink.Bridge{
+        LinkAttrs: netlink.LinkAttrs{Name: name, MTU: mtu},
+    }
+
+    if err := netlink.LinkAdd(bridge); err != nil {
+        return fmt.Errorf("failed to create bridge %s: %w", name, err)
+    }
+
+    if err := netlink.LinkSetUp(bridge); err != nil {
+        return fmt.Errorf("failed to bring up %s: %w", name, err)
+    }
+
+    return nil
+}
+
+// createBond creates a bonding device on the local host and brings it up.
+// Members are added separately with addBondMember.
+func createBond(spec bondSpec) error {
+    bond := netlink.NewLinkBond(netlink.LinkAttrs{Name: spec.Name})
+    bond.Mode = netlink.StringToBondMode(spec.Mode)
+    bond.Miimon = spec.Miimon
+
+    if err := netlink.LinkAdd(bond); err != nil {
+        return fmt.Errorf("failed to create bond %s: %w", spec.Name, err)
+    }
+
+    if err := netlink.LinkSetUp(bond); err != nil {
+        return fmt.Errorf("failed to bring up %s: %w", spec.Name, err)
+    }
+
+    return nil
+}
+
+// addBondMember enslaves a host interface to a bond. The kernel only accepts
+// interfaces that are down, so the interface is brought up again afterwards.
+func addBondMember(bond, member string) error {
+    link, err := netlink.LinkByName(member)
+    if err != nil {
+        return fmt.Errorf("failed to look up link %s: %w", member, err)
+    }
+
+    bondLink, err := netlink.LinkByName(bond)
+    if err != nil {
+        return fmt.Errorf("failed to look up bond %s: %w", bond, err)
+    }
+
+    if err := netlink.LinkSetDown(link); err != nil {
+        return fmt.Errorf("failed to bring down %s: %w", member, err)
+    }
+
+    if err := netlink.LinkSetMaster(link, bondLink); err != nil {
+        return fmt.Errorf("failed to add %s to bond %s: %w", member, bond, err)
+    }
+
+    if err := netlink.LinkSetUp(link); err != nil {
+        return fmt.Errorf("failed to bring up %s: %w", member, err)
+    }
+
+    return nil
+}
+
+// getLinkInfo returns the current state of a host network link.
+// It returns nil if the link does not exist.
+func getLinkInfo(name string) (*hostLinkInfo, error) {
+    link, err := netlink.LinkByName(name)
+    if err != nil {
+        var notFound netlink.LinkNotFoundError
+        if errors.As(err, &notFound) {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("failed to look up link %s: %w", name, err)
+    }
+
+    attrs := link.Attrs()
+    info := &hostLinkInfo{
+        Name: attrs.Name,
+        Type: link.Type(),
+        MTU:  attrs.MTU,
+        Up:   attrs.Flags&netFlagUp != 0,
+    }
+
+    if attrs.MasterIndex != 0 {
+        master, err := netlink.LinkByIndex(attrs.MasterIndex)
+        if err != nil {
+            return nil, fmt.Errorf("failed to look up master of %s: %w", name, err)
+        }
+        info.Master = master.Attrs().Name
+    }
+
+    return info, nil
+}
+
+// getLinkStats returns the traffic counters of a host network link.
+// It returns nil if the link does not exist.
+func getLinkStats(name string) (*hostLinkStats, error) {
+    link, err := netlink.LinkByName(name)
+    if err != nil {
+        var notFound netlink.LinkNotFoundError
+        if errors.As(err, &notFound) {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("failed to look up link %s: %w", name, err)
+    }
+
+    stats := link.Attrs().Statistics
+    if stats == nil {
+        return nil, fmt.Errorf("no statistics reported for link %s", name)
+    }
+
+    return &hostLinkStats{
+        RxBytes:   stats.RxBytes,
+        RxPackets: stats.RxPackets,
+        RxDropped: stats.RxDropped,
+        TxBytes:   stats.TxBytes,
+        TxPackets: stats.TxPackets,
+        TxDropped: stats.TxDropped,
+    }, nil
+}
+
+// setLinkMTU changes the MTU of a host network link.
+func setLinkMTU(name string, mtu int) error {
+    link, err := netlink.LinkByName(name)
+    if err != nil {
+        return fmt.Errorf("failed to look up link %s: %w", name, err)
+    }
+
+    if err := netlink.LinkSetMTU(link, mtu); err != nil {
+        return fmt.Errorf("failed to set MTU on %s: %w", name, err)
+    }
+
+    return nil
+}
+
+// setLinkMaster enslaves a link to the given master device (typically a bridge).
+// An empty master detaches the link from its current master.
+func setLinkMaster(name, master string) error {
+    link, err := netlink.LinkByName(name)
+    if err != nil {
+        return fmt.Errorf("failed to look up link %s: %w", name, err)
+    }
+
+    if master == "" {
+        if err := netlink.LinkSetNoMaster(link); err != nil {
+            return fmt.Errorf("failed to detach %s from its master: %w", name, err)
+        }
+        return nil
+    }
+
+    masterLink, err := netlink.LinkByName(master)
+    if err != nil {
+        return fmt.Errorf("failed to look up master %s: %w", master, err)
+    }
+
+    if err := netlink.LinkSetMaster(link, masterLink); err != nil {
+        return fmt.Errorf("failed to attach %s to %s: %w", name, master, err)
+    }
+
+    return nil
+}
+
+// deleteLink removes a host network link. A link that is already gone is not an error.
+func deleteLink(name string) error {
+    link, err := netlink.LinkByName(name)
+    if err != nil {
+        var notFound netlink.LinkNotFoundError
+        if errors.As(err, &notFound) {
+            return nil
+        }
+        return fmt.Errorf("failed to look up link %s: %w", name, err)
+    }
+
+    if err := netlink.LinkDel(link); err != nil {
+        return fmt.Errorf("failed to delete link %s: %w", name, err)
+    }
+
+    return nil
+}
+
+// getLinkAddresses returns the IPv4 addresses assigned to a link in CIDR notation.
+func getLinkAddresses(name string) ([]string, error) {
+    link, err := netlink.LinkByName(name)
+    if err != nil {
+        return nil, fmt.Errorf("failed to look up link %s: %w", name, err)
+    }
+
+    addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+    if err != nil {
+        return nil, fmt.Errorf("failed to list addresses of %s: %w", name, err)
+    }
+
+    result := make([]string, 0, len(addrs))
+    for _, addr := range addrs {
+        result = append(result, addr.IPNet.String())
+    }
+
+    return result, nil
+}
+
+// addLinkAddress assigns an address in CIDR notation to a link.
+func addLinkAddress(name, cidr string) error {
+    link, err := netlink.LinkByName(name)
+    if err != nil {
+        return fmt.Errorf("failed to look up link %s: %w", name, err)
+    }
+
+    addr, err := netlink.ParseAddr(cidr)
+    if err != nil {
+        return fmt.Errorf("invalid address %s: %w", cidr, err)
+    }
+
+    if err := netlink.AddrReplace(link, addr); err != nil {
+        return fmt.Errorf("failed to assign %s to %s: %w", cidr, name, err)
+    }
+
+    return nil
+}
+
+// deleteLinkAddress removes an address in CIDR notation from a link.
+func deleteLinkAddress(name, cidr string) error {
+    link, err := netlink.LinkByName(name)
+    if err != nil {
+        return fmt.Errorf("failed to look up link %s: %w", name, err)
+    }
+
+    addr, err := netlink.ParseAddr(cidr)
+    if err != nil {
+        return fmt.Errorf("invalid address %s: %w", cidr, err)
+    }
+
+    if err := netlink.AddrDel(link, addr); err != nil {
+        return fmt.Errorf("failed to remove %s from %s: %w", cidr, name, err)
+    }
+
+    return nil
+}
+
+// listLinkMembers returns the names of the links enslaved to the given master.
+func listLinkMembers(master string) ([]string, error) {
+    masterLink, err := netlink.LinkByName(master)
+    if err != nil {
+        return nil, fmt.Errorf("failed to look up link %s: %w", master, err)
+    }
+
+    links, err := netlink.LinkList()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list links: %w", err)
+    }
+
+    members := []string{}
+    for _, link := range links {
+        if link.Attrs().MasterIndex == masterLink.Attrs().Index {
+            members = append(members, link.Attrs().Name)
+        }
+    }
+
+    return members, nil
+}
+
+// enableIPForwarding turns on IPv4 forwarding so traffic from VMs can be routed off the bridge.
+func enableIPForwarding() error {
+    if err := os.WriteFile(ipForwardPath, []byte("1"), 0o644); err != nil {
+        return fmt.Errorf("failed to enable IP forwarding: %w", err)
+    }
+
+    return nil
+}
+
+// ensureNetNS creates the named network namespace, as ip netns add does, unless it exists.
+// It reports whether the namespace was created.
+func ensureNetNS(name string) (bool, error) {
+    if _, err := os.Stat(netnsPath(name)); err == nil {
+        return false, nil
+    }
+
+    // Creating a namespace moves the calling thread into it, so the thread is moved back
+    err := lockedInNetNS(func() error {
+        created, err := netns.NewNamed(name)
+        if err != nil {
+            return err
+        }
+        return created.Close()
+    })
+    if err != nil {
+        return false, fmt.Errorf("failed to create network namespace %s: %w", name, err)
+    }
+    return true, nil
+}
+
+// deleteNetNSIfEmpty removes the named network namespace once no interface but its loopback
+// device is left in it. It reports whether the namespace was removed.
+func deleteNetNSIfEmpty(name string) (bool, error) {
+    if _, err := os.Stat(netnsPath(name)); errors.Is(err, os.ErrNotExist) {
+        return false, nil
+    }
+
+    empty := true
+    err := withNetNS(name, func() error {
+        links, err := netlink.LinkList()
+        if err != nil {
+            return fmt.Errorf("failed to list links: %w", err)
+        }
+        for _, link := range links {
+            if link.Attrs().Flags&net.FlagLoopback == 0 {
+                empty = false
+            }
+        }
+        return nil
+    })
+    if err != nil || !empty {
+        return false, err
+    }
+
+    if err := netns.DeleteNamed(name); err != nil {
+        return false, fmt.Errorf("failed to delete network namespace %s: %w", name, err)
+    }
+    return true, nil
+}
+
+// withNetNS runs f with its OS thread in the named network namespace, so the netlink calls
+// it makes act on the links of that namespace. An empty name runs f where the provider runs.
+func withNetNS(name string, f func() error) error {
+    if name == "" {
+        return f()
+    }
+    return lockedInNetNS(func() error {
+        target, err := netns.GetFromPath(netnsPath(name))
+        if err != nil {
+            return fmt.Errorf("failed to open network namespace %s: %w", name, err)
+        }
+        defer target.Close()
+
+        if err := netns.Set(target); err != nil {
+            return fmt.Errorf("failed to enter network namespace %s: %w", name, err)
+        }
+        return f()
+    })
+}
+
+// lockedInNetNS runs f locked to its OS thread, and returns the thread to the network
+// namespace it started in afterwards. A thread that cannot be returned is left locked, so
+// the Go runtime ends it rather than running other goroutines in the wrong namespace.
+func lockedInNetNS(f func() error) error {
+    runtime.LockOSThread()
+    origin, err := netns.Get()
+    if err != nil {
+        runtime.UnlockOSThread()
+        return fmt.Errorf("failed to open the current network namespace: %w", err)
+    }
+    defer origin.Close()
+
+    defer func() {
+        if err := netns.Set(origin); err == nil {
+            runtime.UnlockOSThread()
+        }
+    }()
+    return f()
+}
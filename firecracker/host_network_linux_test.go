@@ -0,0 +1,94 @@
+//go:build linux
+
+package firecracker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+func TestCreateTapDevice(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  tapDeviceSpec
+		owner uint32
+		group uint32
+	}{
+		// The kernel does not report an owner or group the device does not have
+		{"default", tapDeviceSpec{Name: "tap-test0", Owner: -1, Group: -1}, 0, 0},
+		{"owned", tapDeviceSpec{Name: "tap-test1", Owner: 1000, Group: 1001, MTU: 1400}, 1000, 1001},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create the device in a namespace of its own, which is gone once the test is done
+			err := lockedInNetNS(func() error {
+				scratch, err := netns.New()
+				if err != nil {
+					t.Skipf("Network namespaces unavailable: %v", err)
+				}
+				defer scratch.Close()
+
+				if err := createTapDevice(tt.spec); err != nil {
+					return err
+				}
+				link, err := netlink.LinkByName(tt.spec.Name)
+				if err != nil {
+					t.Fatalf("Expected the TAP device to exist, got %v", err)
+				}
+				tap, ok := link.(*netlink.Tuntap)
+				if !ok {
+					t.Fatalf("Expected a TAP device, got %s", link.Type())
+				}
+				if tap.Owner != tt.owner || tap.Group != tt.group {
+					t.Errorf("Expected owner %d and group %d, got %d and %d", tt.owner, tt.group, tap.Owner, tap.Group)
+				}
+				if tt.spec.MTU > 0 && tap.Attrs().MTU != tt.spec.MTU {
+					t.Errorf("Expected MTU %d, got %d", tt.spec.MTU, tap.Attrs().MTU)
+				}
+				if tap.Attrs().Flags&netFlagUp == 0 {
+					t.Error("Expected the TAP device to be up")
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Expected the TAP device to be created, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCreateTapDevice_cleanup(t *testing.T) {
+	original := linkSetMTU
+	defer func() { linkSetMTU = original }()
+	linkSetMTU = func(link netlink.Link, mtu int) error {
+		return errors.New("MTU out of range")
+	}
+
+	err := lockedInNetNS(func() error {
+		scratch, err := netns.New()
+		if err != nil {
+			t.Skipf("Network namespaces unavailable: %v", err)
+		}
+		defer scratch.Close()
+
+		spec := tapDeviceSpec{Name: "tap-test0", Owner: -1, Group: -1, MTU: 1400}
+		if err := createTapDevice(spec); err == nil || !strings.Contains(err.Error(), "MTU out of range") {
+			t.Fatalf("Expected setting the MTU to fail, got %v", err)
+		}
+		// The device is gone, so creating it again works
+		var notFound netlink.LinkNotFoundError
+		if _, err := netlink.LinkByName(spec.Name); !errors.As(err, &notFound) {
+			t.Errorf("Expected the TAP device to be removed, got %v", err)
+		}
+		linkSetMTU = original
+		return createTapDevice(spec)
+	})
+	if err != nil {
+		t.Fatalf("Expected the TAP device to be created again, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+//go:build !linux
+
+package firecracker
+
+import (
+    "fmt"
+    "runtime"
+)
+
+// Host network management relies on netlink and is only available on Linux hosts,
+// which is the only platform Firecracker runs on.
+var errHostNetworkUnsupported = fmt.Errorf("host network management is not supported on %s", runtime.GOOS)
+
+func createTapDevice(spec tapDeviceSpec) error {
+    return errHostNetworkUnsupported
+}
+
+func createBridge(name string, mtu int) error {
+    return errHostNetworkUnsupported
+}
+
+func createBond(spec bondSpec) error {
+    return errHostNetworkUnsupported
+}
+
+func addBondMember(bond, member string) error {
+    return errHostNetworkUnsupported
+}
+
+func getLinkInfo(name string) (*hostLinkInfo, error) {
+    return nil, errHostNetworkUnsupported
+}
+
+func setLinkMTU(name string, mtu int) error {
+    return errHostNetworkUnsupported
+}
+
+func setLinkMaster(name, master string) error {
+    return errHostNetworkUnsupported
+}
+
+func deleteLink(name string) error {
+    return errHostNetworkUnsupported
+}
+
+func getLinkAddresses(name string) ([]string, error) {
+    return nil, errHostNetworkUnsupported
+}
+
+func addLinkAddress(name, cidr string) error {
+    return errHostNetworkUnsupported
+}
+
+func deleteLinkAddress(name, cidr string) error {
+    return errHostNetworkUnsupported
+}
+
+func listLinkMembers(master string) ([]string, error) {
+    return nil, errHostNetworkUnsupported
+}
+
+func enableIPForwarding() error {
+    return errHostNetworkUnsupported
+}
+
+func getLinkStats(name string) (*hostLinkStats, error) {
+    return nil, errHostNetworkUnsupported
+}
+
+func ensureNetNS(name string) (bool, error) {
+    return false, errHostNetworkUnsupported
+}
+
+func deleteNetNSIfEmpty(name string) (bool, error) {
+    return false, errHostNetworkUnsupported
+}
+
+func withNetNS(name string, f func() error) error {
+    if name == "" {
+        return f()
+    }
+    return errHostNetworkUnsupported
+}
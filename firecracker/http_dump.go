@@ -0,0 +1,177 @@
+package firecracker
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// redactedHeaders are the request headers whose values are left out of HTTP dumps, along
+// with the headers set in the provider configuration.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// httpDump records the exchanges of a provider run with the Firecracker APIs in a file of
+// its own, to attach to bug reports. Secrets are redacted as they are in logs.
+type httpDump struct {
+    dir string
+
+    // redacted holds the canonical names of the headers whose values are left out.
+    redacted map[string]bool
+
+    mu   sync.Mutex
+    file *os.File
+}
+
+// httpExchange is a request to a Firecracker API and its response, as recorded in a dump.
+type httpExchange struct {
+    Time            string            `json:"time"`
+    DurationMS      int64             `json:"duration_ms"`
+    Method          string            `json:"method"`
+    URL             string            `json:"url"`
+    RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+    RequestBody     string            `json:"request_body,omitempty"`
+    Status          int               `json:"status,omitempty"`
+    ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+    ResponseBody    string            `json:"response_body,omitempty"`
+    Error           string            `json:"error,omitempty"`
+}
+
+// httpDumpFromConfig returns the recorder the provider's debug block asks for, if any.
+// headers are the headers set in the provider configuration, which may hold credentials.
+func httpDumpFromConfig(raw []interface{}, headers http.Header) *httpDump {
+    if len(raw) == 0 || raw[0] == nil {
+        return nil
+    }
+    dir := raw[0].(map[string]interface{})["dump_http_to"].(string)
+    if dir == "" {
+        return nil
+    }
+
+    redacted := map[string]bool{}
+    for _, name := range redactedHeaders {
+        redacted[http.CanonicalHeaderKey(name)] = true
+    }
+    for name := range headers {
+        redacted[http.CanonicalHeaderKey(name)] = true
+    }
+    return &httpDump{dir: dir, redacted: redacted}
+}
+
+// record sends a request with send and records it along with its response.
+func (h *httpDump) record(c *FirecrackerClient, req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+    var requestBody []byte
+    if req.Body != nil && req.Body != http.NoBody {
+        body, err := io.ReadAll(req.Body)
+        req.Body.Close()
+        if err != nil {
+            return nil, fmt.Errorf("failed to read request payload: %w", err)
+        }
+        req.Body = io.NopCloser(bytes.NewReader(body))
+        requestBody = body
+    }
+
+    start := time.Now()
+    resp, err := send(req)
+    exchange := httpExchange{
+        Time:           start.UTC().Format(time.RFC3339Nano),
+        DurationMS:     time.Since(start).Milliseconds(),
+        Method:         req.Method,
+        URL:            req.URL.String(),
+        RequestHeaders: h.headers(req.Header),
+        RequestBody:    h.body(c, req, requestBody),
+    }
+    if err != nil {
+        exchange.Error = err.Error()
+    } else {
+        body, readErr := io.ReadAll(resp.Body)
+        resp.Body.Close()
+        resp.Body = io.NopCloser(bytes.NewReader(body))
+        if readErr != nil {
+            return nil, readErr
+        }
+        exchange.Status = resp.StatusCode
+        exchange.ResponseHeaders = h.headers(resp.Header)
+        exchange.ResponseBody = h.body(c, req, body)
+    }
+
+    if writeErr := h.write(exchange); writeErr != nil {
+        tflog.Warn(req.Context(), "Failed to record Firecracker API exchange", map[string]interface{}{
+            "url":   exchange.URL,
+            "error": writeErr.Error(),
+        })
+    }
+    return resp, err
+}
+
+// headers returns the headers of a request or response for a dump, without the values of
+// those that may hold credentials.
+func (h *httpDump) headers(header http.Header) map[string]string {
+    if len(header) == 0 {
+        return nil
+    }
+    recorded := make(map[string]string, len(header))
+    for name, values := range header {
+        if h.redacted[http.CanonicalHeaderKey(name)] {
+            recorded[name] = redactedValue
+            continue
+        }
+        recorded[name] = strings.Join(values, ", ")
+    }
+    return recorded
+}
+
+// body returns the body of a request to the API or of its response for a dump.
+func (h *httpDump) body(c *FirecrackerClient, req *http.Request, body []byte) string {
+    return redactedRequestBody(c, req, body)
+}
+
+// redactedRequestBody returns the body of a request to the API or of its response for a
+// dump or the audit log. MMDS contents are left out, and so are the sensitive kernel
+// parameters of the VM the request was sent for.
+func redactedRequestBody(c *FirecrackerClient, req *http.Request, body []byte) string {
+    if len(body) == 0 {
+        return ""
+    }
+    recorded := c.redactRequestPayload(req.URL.String(), body)
+    sensitive := sensitiveValues(req.Context())
+    // Longer values first, so none is left partly visible by a shorter one it contains
+    sort.Slice(sensitive, func(i, j int) bool { return len(sensitive[i]) > len(sensitive[j]) })
+    for _, value := range sensitive {
+        recorded = strings.ReplaceAll(recorded, value, redactedValue)
+    }
+    return recorded
+}
+
+// write appends an exchange to the dump of this run, creating it on the first exchange.
+func (h *httpDump) write(exchange httpExchange) error {
+    line, err := json.Marshal(exchange)
+    if err != nil {
+        return err
+    }
+
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    if h.file == nil {
+        if err := os.MkdirAll(h.dir, 0o700); err != nil {
+            return fmt.Errorf("failed to create HTTP dump directory: %w", err)
+        }
+        name := fmt.Sprintf("firecracker-http-%s-%d.jsonl", time.Now().UTC().Format("20060102T150405"), os.Getpid())
+        file, err := os.OpenFile(filepath.Join(h.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+        if err != nil {
+            return fmt.Errorf("failed to create HTTP dump: %w", err)
+        }
+        h.file = file
+    }
+    _, err = h.file.Write(append(line, '\n'))
+    return err
+}
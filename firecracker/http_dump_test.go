@@ -0,0 +1,149 @@
+package firecracker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// readHTTPDump returns the exchanges of the single dump in dir.
+func readHTTPDump(t *testing.T, dir string) []httpExchange {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "firecracker-http-*.jsonl"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one HTTP dump in %s, got %v (%v)", dir, files, err)
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatalf("Failed to stat HTTP dump: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected HTTP dump to be private, got mode %v", info.Mode().Perm())
+	}
+
+	file, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("Failed to open HTTP dump: %v", err)
+	}
+	defer file.Close()
+	var exchanges []httpExchange
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var exchange httpExchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			t.Fatalf("Failed to parse HTTP dump line %q: %v", scanner.Text(), err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	return exchanges
+}
+
+func TestHTTPDump(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dumps")
+	headers, err := requestHeadersFromConfig(map[string]interface{}{"X-Proxy-Key": "proxy-secret"}, "token-secret")
+	if err != nil {
+		t.Fatalf("Failed to build headers: %v", err)
+	}
+	client := &FirecrackerClient{
+		BaseURL: "http://localhost:8080",
+		headers: headers,
+		HTTPClient: &mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Path == "/mmds" {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": []string{"application/json"}},
+						Body:       io.NopCloser(strings.NewReader(`{"api_key": "mmds-secret"}`)),
+					}, nil
+				}
+				body, _ := io.ReadAll(req.Body)
+				if !strings.Contains(string(body), "root-password=hunter2") {
+					t.Errorf("Expected the API to receive the sensitive kernel parameters, got %s", body)
+				}
+				return &http.Response{
+					StatusCode: http.StatusBadRequest,
+					Body:       io.NopCloser(strings.NewReader(`{"fault_message": "Invalid kernel"}`)),
+				}, nil
+			},
+		},
+		httpDump: httpDumpFromConfig([]interface{}{map[string]interface{}{"dump_http_to": dir}}, headers),
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"sensitive_boot_args": "root-password=hunter2",
+	})
+	ctx := maskSensitiveBootArgs(context.Background(), d)
+	err = client.sendComponent(ctx, http.MethodPut, client.BaseURL+"/boot-source", map[string]interface{}{
+		"kernel_image_path": "/vmlinux",
+		"boot_args":         "console=ttyS0 root-password=hunter2",
+	})
+	if err == nil {
+		t.Fatalf("Expected the rejected request to fail")
+	}
+	if _, err := client.getComponent(ctx, client.BaseURL+"/mmds"); err != nil {
+		t.Fatalf("Expected MMDS contents, got %v", err)
+	}
+
+	exchanges := readHTTPDump(t, dir)
+	if len(exchanges) != 2 {
+		t.Fatalf("Expected 2 exchanges, got %d", len(exchanges))
+	}
+	put, get := exchanges[0], exchanges[1]
+	if put.Method != http.MethodPut || put.URL != "http://localhost:8080/boot-source" || put.Status != http.StatusBadRequest {
+		t.Errorf("Expected the rejected PUT of the boot source, got %+v", put)
+	}
+	if !strings.Contains(put.RequestBody, "console=ttyS0") || !strings.Contains(put.ResponseBody, "Invalid kernel") {
+		t.Errorf("Expected the request and response bodies to be recorded, got %+v", put)
+	}
+	if put.RequestHeaders["Authorization"] != redactedValue || put.RequestHeaders["X-Proxy-Key"] != redactedValue {
+		t.Errorf("Expected credentials to be redacted, got %v", put.RequestHeaders)
+	}
+	if get.Method != http.MethodGet || get.ResponseBody != redactedValue {
+		t.Errorf("Expected MMDS contents to be redacted, got %+v", get)
+	}
+
+	raw, _ := json.Marshal(exchanges)
+	for _, secret := range []string{"hunter2", "token-secret", "proxy-secret", "mmds-secret"} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("Expected %q to be redacted from the dump, got %s", secret, raw)
+		}
+	}
+}
+
+func TestHTTPDump_transportError(t *testing.T) {
+	dir := t.TempDir()
+	client := &FirecrackerClient{
+		BaseURL: "http://localhost:8080",
+		HTTPClient: &mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return nil, io.ErrUnexpectedEOF
+			},
+		},
+		httpDump: httpDumpFromConfig([]interface{}{map[string]interface{}{"dump_http_to": dir}}, nil),
+	}
+
+	if _, err := client.getComponent(context.Background(), client.BaseURL+"/machine-config"); err == nil {
+		t.Fatalf("Expected the request to fail")
+	}
+	exchanges := readHTTPDump(t, dir)
+	if len(exchanges) != 1 || exchanges[0].Error == "" || exchanges[0].Status != 0 {
+		t.Errorf("Expected the failed exchange to be recorded with its error, got %+v", exchanges)
+	}
+}
+
+func TestHTTPDumpFromConfig(t *testing.T) {
+	if httpDumpFromConfig(nil, nil) != nil {
+		t.Errorf("Expected no dump without a debug block")
+	}
+	if httpDumpFromConfig([]interface{}{map[string]interface{}{"dump_http_to": ""}}, nil) != nil {
+		t.Errorf("Expected no dump without dump_http_to")
+	}
+}
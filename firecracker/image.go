@@ -0,0 +1,171 @@
+package firecracker
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "path"
+    "path/filepath"
+    "regexp"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// sha256Regexp matches a hex-encoded SHA-256 checksum.
+var sha256Regexp = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// imageHTTPClient downloads images. It has no overall timeout since large images take a
+// while, and relies on the data source's read timeout instead.
+var imageHTTPClient httpClient = &http.Client{}
+
+// imageSpec describes a kernel or rootfs image to fetch into the local cache.
+type imageSpec struct {
+    URL      string
+    SHA256   string
+    CacheDir string
+}
+
+// cachePath returns where the image is kept in the cache. Images are stored by checksum,
+// so different versions of a file with the same name never overwrite each other.
+func (s imageSpec) cachePath() (string, error) {
+    u, err := url.Parse(s.URL)
+    if err != nil {
+        return "", fmt.Errorf("invalid image URL %q: %w", s.URL, err)
+    }
+    name := path.Base(u.Path)
+    if name == "." || name == "/" {
+        name = "image"
+    }
+    return filepath.Join(s.CacheDir, strings.ToLower(s.SHA256), name), nil
+}
+
+// validateImageURL checks that an image URL uses a scheme fetchImage can download.
+func validateImageURL(v interface{}, k string) ([]string, []error) {
+    u, err := url.Parse(v.(string))
+    if err != nil || u.Host == "" {
+        return nil, []error{fmt.Errorf("%q must be an absolute URL such as 'https://example.com/vmlinux'", k)}
+    }
+    switch u.Scheme {
+    case "http", "https", "s3":
+        return nil, nil
+    default:
+        return nil, []error{fmt.Errorf("%q must use the http, https or s3 scheme, got %q", k, u.Scheme)}
+    }
+}
+
+// fetchImage returns the path of the image in the cache, downloading it first unless a
+// copy with the expected checksum is already there. The download goes to a temporary file
+// that is only moved into place once its checksum has been verified.
+func fetchImage(ctx context.Context, spec imageSpec) (string, error) {
+    dest, err := spec.cachePath()
+    if err != nil {
+        return "", err
+    }
+
+    if checksum, err := fileSHA256(dest); err == nil {
+        if strings.EqualFold(checksum, spec.SHA256) {
+            return dest, nil
+        }
+        tflog.Warn(ctx, "Cached image does not match its checksum, downloading it again", map[string]interface{}{
+            "path": dest,
+        })
+    } else if !errors.Is(err, os.ErrNotExist) {
+        return "", err
+    }
+
+    if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+        return "", fmt.Errorf("failed to create image cache directory: %w", err)
+    }
+    tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.tmp")
+    if err != nil {
+        return "", fmt.Errorf("failed to create image download file: %w", err)
+    }
+    defer os.Remove(tmp.Name())
+    if err := trackArtifact(ctx, hostArtifact{Path: tmp.Name()}); err != nil {
+        tmp.Close()
+        return "", err
+    }
+
+    tflog.Info(ctx, "Downloading image", map[string]interface{}{
+        "url":  spec.URL,
+        "path": dest,
+    })
+
+    if strings.HasPrefix(spec.URL, "s3://") {
+        tmp.Close()
+        err = downloadS3Object(ctx, spec.URL, tmp.Name())
+    } else {
+        err = downloadHTTP(ctx, spec.URL, tmp)
+        if closeErr := tmp.Close(); err == nil && closeErr != nil {
+            err = fmt.Errorf("failed to write image: %w", closeErr)
+        }
+    }
+    if err != nil {
+        return "", err
+    }
+
+    checksum, err := fileSHA256(tmp.Name())
+    if err != nil {
+        return "", err
+    }
+    if !strings.EqualFold(checksum, spec.SHA256) {
+        return "", fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", spec.URL, strings.ToLower(spec.SHA256), checksum)
+    }
+
+    if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+        return "", fmt.Errorf("failed to set mode of image: %w", err)
+    }
+    if err := os.Rename(tmp.Name(), dest); err != nil {
+        return "", fmt.Errorf("failed to move image into the cache: %w", err)
+    }
+    return dest, nil
+}
+
+// downloadHTTP writes the body of an HTTP(S) URL to w.
+func downloadHTTP(ctx context.Context, rawURL string, w io.Writer) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+    if err != nil {
+        return fmt.Errorf("invalid image URL %q: %w", rawURL, err)
+    }
+    resp, err := imageHTTPClient.Do(req)
+    if err != nil {
+        return fmt.Errorf("failed to download %s: %w", rawURL, err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("failed to download %s: %s", rawURL, resp.Status)
+    }
+    if _, err := io.Copy(w, resp.Body); err != nil {
+        return fmt.Errorf("failed to download %s: %w", rawURL, err)
+    }
+    return nil
+}
+
+// downloadS3Object copies an S3 object to a local file with the AWS CLI, which picks up
+// credentials from the environment, shared configuration or instance profile.
+func downloadS3Object(ctx context.Context, rawURL, dest string) error {
+    if _, err := lookPath("aws"); err != nil {
+        return fmt.Errorf("downloading %s requires the AWS CLI: %w", rawURL, err)
+    }
+    if output, err := runCommand(ctx, "aws", "s3", "cp", "--only-show-errors", rawURL, dest); err != nil {
+        return fmt.Errorf("failed to download %s: %w: %s", rawURL, err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 checksum of a file.
+func fileSHA256(name string) (string, error) {
+    h := sha256.New()
+    if err := hashFile(h, name); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,126 @@
+package firecracker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchImage_http(t *testing.T) {
+	content := []byte("kernel image")
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	spec := imageSpec{URL: server.URL + "/images/vmlinux", SHA256: strings.ToUpper(checksum), CacheDir: t.TempDir()}
+	path, err := fetchImage(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := filepath.Join(spec.CacheDir, checksum, "vmlinux"); path != expected {
+		t.Errorf("Expected image at %s, got %s", expected, path)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(content) {
+		t.Errorf("Unexpected image contents %q", data)
+	}
+
+	// A cached image with the right checksum is not downloaded again
+	if _, err := fetchImage(context.Background(), spec); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected one download, got %d", requests)
+	}
+
+	// A corrupted cached image is replaced
+	if err := os.WriteFile(path, []byte("corrupted"), 0o644); err != nil {
+		t.Fatalf("Failed to corrupt image: %v", err)
+	}
+	if _, err := fetchImage(context.Background(), spec); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(content) || requests != 2 {
+		t.Errorf("Expected the corrupted image to be downloaded again, got %q after %d downloads", data, requests)
+	}
+}
+
+func TestFetchImage_checksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}))
+	defer server.Close()
+
+	spec := imageSpec{URL: server.URL + "/rootfs.ext4", SHA256: strings.Repeat("0", 64), CacheDir: t.TempDir()}
+	if _, err := fetchImage(context.Background(), spec); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Expected a checksum mismatch, got %v", err)
+	}
+
+	// Nothing is left in the cache
+	entries, _ := os.ReadDir(filepath.Join(spec.CacheDir, spec.SHA256))
+	if len(entries) != 0 {
+		t.Errorf("Expected an empty cache, found %d entries", len(entries))
+	}
+}
+
+func TestFetchImage_httpError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	spec := imageSpec{URL: server.URL + "/missing", SHA256: strings.Repeat("0", 64), CacheDir: t.TempDir()}
+	if _, err := fetchImage(context.Background(), spec); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Expected a download error, got %v", err)
+	}
+}
+
+func TestFetchImage_s3(t *testing.T) {
+	content := []byte("rootfs image")
+	sum := sha256.Sum256(content)
+
+	var command []string
+	originalLookPath, originalRunCommand := lookPath, runCommand
+	defer func() { lookPath, runCommand = originalLookPath, originalRunCommand }()
+	lookPath = func(file string) (string, error) {
+		return "/usr/bin/" + file, nil
+	}
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		command = append([]string{name}, args...)
+		return nil, os.WriteFile(args[len(args)-1], content, 0o600)
+	}
+
+	spec := imageSpec{URL: "s3://images/ubuntu/rootfs.ext4", SHA256: hex.EncodeToString(sum[:]), CacheDir: t.TempDir()}
+	path, err := fetchImage(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(strings.Join(command, " "), "aws s3 cp --only-show-errors s3://images/ubuntu/rootfs.ext4 ") {
+		t.Errorf("Unexpected command %v", command)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("Expected a readable image at %s, got %v", path, err)
+	}
+}
+
+func TestValidateImageURL(t *testing.T) {
+	for _, valid := range []string{"https://example.com/vmlinux", "http://10.0.0.1:8080/rootfs.ext4", "s3://bucket/key"} {
+		if _, errs := validateImageURL(valid, "url"); len(errs) > 0 {
+			t.Errorf("Expected %q to be valid, got %v", valid, errs)
+		}
+	}
+	for _, invalid := range []string{"/images/vmlinux", "ftp://example.com/vmlinux", "file:///images/vmlinux"} {
+		if _, errs := validateImageURL(invalid, "url"); len(errs) == 0 {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+}
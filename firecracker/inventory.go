@@ -0,0 +1,296 @@
+package firecracker
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "syscall"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// shutdownGracePeriod is how long the provider waits on SIGTERM for in-flight host
+// operations to abort and clean up before it exits.
+var shutdownGracePeriod = 10 * time.Second
+
+var (
+    // shutdownCtx is cancelled when the provider process is asked to terminate.
+    shutdownCtx, cancelShutdown = context.WithCancel(context.Background())
+
+    // hostOperations counts the host operations in flight in the provider process.
+    hostOperations sync.WaitGroup
+
+    // hostOperationSeq numbers host operations, keeping their inventory entries apart.
+    hostOperationSeq atomic.Int64
+
+    shutdownSignalsOnce sync.Once
+)
+
+// handleShutdownSignals makes the provider abort its in-flight host operations on SIGTERM
+// and wait for them to clean up before exiting. Terraform itself stops providers through
+// the plugin protocol, which cancels the context of every operation, but a provider killed
+// along with Terraform, such as when a CI job times out, only gets a signal.
+func handleShutdownSignals() {
+    shutdownSignalsOnce.Do(func() {
+        signals := make(chan os.Signal, 1)
+        signal.Notify(signals, syscall.SIGTERM)
+        go func() {
+            <-signals
+            log.Printf("[WARN] Received SIGTERM, aborting in-flight host operations")
+            cancelShutdown()
+
+            done := make(chan struct{})
+            go func() {
+                hostOperations.Wait()
+                close(done)
+            }()
+            select {
+            case <-done:
+            case <-time.After(shutdownGracePeriod):
+                log.Printf("[WARN] Host operations did not finish within %s, leaving them to be cleaned up by the next run", shutdownGracePeriod)
+            }
+            os.Exit(128 + int(syscall.SIGTERM))
+        }()
+    })
+}
+
+// hostArtifact is something a host operation creates: a file or directory at Path, or the
+// dm-snapshot device of the overlay named Overlay.
+type hostArtifact struct {
+    Path    string `json:"path,omitempty"`
+    Overlay string `json:"overlay,omitempty"`
+}
+
+// hostOperationRecord is the inventory entry of a host operation in flight, kept in the
+// state directory until the operation completes or is cleaned up, so that the artifacts
+// of an operation interrupted by the provider being killed are removed by the next run.
+type hostOperationRecord struct {
+    Description string         `json:"description"`
+    PID         int            `json:"pid"`
+    Started     time.Time      `json:"started"`
+    Artifacts   []hostArtifact `json:"artifacts"`
+}
+
+// hostOperation is a host operation in flight, such as building an image or creating a drive.
+type hostOperation struct {
+    path   string
+    record hostOperationRecord
+    mu     sync.Mutex
+    cancel context.CancelFunc
+}
+
+type hostOperationKey struct{}
+
+// hostOperationsDir returns the directory holding the inventory of host operations.
+func hostOperationsDir(stateDir string) string {
+    return filepath.Join(stateDir, "operations")
+}
+
+// startHostOperation records a host operation in the inventory and returns a context that
+// carries it, so the functions doing the work can track what they create with trackArtifact.
+// The context is also cancelled when the provider is asked to terminate. The operation must
+// be ended with finish.
+func startHostOperation(ctx context.Context, stateDir, description string) (context.Context, *hostOperation, error) {
+    dir := hostOperationsDir(stateDir)
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, nil, fmt.Errorf("failed to create operations directory: %w", err)
+    }
+
+    now := time.Now().UTC()
+    op := &hostOperation{
+        path: filepath.Join(dir, fmt.Sprintf("%d-%d-%d.json", os.Getpid(), now.UnixNano(), hostOperationSeq.Add(1))),
+        record: hostOperationRecord{
+            Description: description,
+            PID:         os.Getpid(),
+            Started:     now,
+            Artifacts:   []hostArtifact{},
+        },
+    }
+    if err := op.save(); err != nil {
+        return nil, nil, err
+    }
+
+    hostOperations.Add(1)
+    ctx, cancel := context.WithCancel(ctx)
+    stop := context.AfterFunc(shutdownCtx, cancel)
+    op.cancel = func() {
+        stop()
+        cancel()
+    }
+    return context.WithValue(ctx, hostOperationKey{}, op), op, nil
+}
+
+// save writes the operation's record atomically.
+func (op *hostOperation) save() error {
+    data, err := json.MarshalIndent(op.record, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode operation: %w", err)
+    }
+    tmp := op.path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0o644); err != nil {
+        return fmt.Errorf("failed to record operation: %w", err)
+    }
+    if err := os.Rename(tmp, op.path); err != nil {
+        return fmt.Errorf("failed to record operation: %w", err)
+    }
+    return nil
+}
+
+// trackArtifact records in the inventory that the operation carried by ctx is about to
+// create an artifact. It must be called before the artifact is created, so that it is
+// cleaned up however far its creation got. It does nothing outside of a host operation.
+func trackArtifact(ctx context.Context, artifact hostArtifact) error {
+    op, ok := ctx.Value(hostOperationKey{}).(*hostOperation)
+    if !ok {
+        return nil
+    }
+    op.mu.Lock()
+    defer op.mu.Unlock()
+    op.record.Artifacts = append(op.record.Artifacts, artifact)
+    return op.save()
+}
+
+// finish ends the operation with its result. A failed or aborted operation has its
+// artifacts removed. The operation leaves the inventory unless its cleanup failed, in which
+// case the next run tries again. finish returns err, with any cleanup error added.
+func (op *hostOperation) finish(err error) error {
+    defer hostOperations.Done()
+    defer op.cancel()
+
+    op.mu.Lock()
+    defer op.mu.Unlock()
+
+    if err != nil {
+        // The operation's context may be cancelled, cleaning up must not be
+        if cleanupErr := removeHostArtifacts(context.Background(), op.record.Artifacts); cleanupErr != nil {
+            return fmt.Errorf("%w (cleaning up after it also failed, it will be retried by the next run: %v)", err, cleanupErr)
+        }
+    }
+    if removeErr := os.Remove(op.path); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) && err == nil {
+        return fmt.Errorf("failed to remove operation from the inventory: %w", removeErr)
+    }
+    return err
+}
+
+// removeHostArtifacts removes artifacts in the reverse order of their creation.
+func removeHostArtifacts(ctx context.Context, artifacts []hostArtifact) error {
+    var errs []error
+    for i := len(artifacts) - 1; i >= 0; i-- {
+        artifact := artifacts[i]
+        if artifact.Overlay != "" {
+            if err := disassembleSnapshotOverlay(ctx, overlaySpec{Name: artifact.Overlay}); err != nil {
+                errs = append(errs, err)
+            }
+        }
+        if artifact.Path != "" {
+            if err := os.RemoveAll(artifact.Path); err != nil {
+                errs = append(errs, fmt.Errorf("failed to remove %s: %w", artifact.Path, err))
+            }
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// interruptedHostOperation is a host operation in the inventory whose provider process is
+// gone, which was killed before it could finish or abort it.
+type interruptedHostOperation struct {
+    path   string
+    record hostOperationRecord
+}
+
+// interruptedHostOperations returns the interrupted host operations in the inventory.
+// Operations of running providers sharing the state directory are left out.
+func interruptedHostOperations(stateDir string) ([]interruptedHostOperation, error) {
+    paths, err := filepath.Glob(filepath.Join(hostOperationsDir(stateDir), "*.json"))
+    if err != nil {
+        return nil, err
+    }
+
+    var interrupted []interruptedHostOperation
+    var errs []error
+    for _, path := range paths {
+        data, err := os.ReadFile(path)
+        if errors.Is(err, os.ErrNotExist) {
+            continue
+        } else if err != nil {
+            errs = append(errs, fmt.Errorf("failed to read operation %s: %w", path, err))
+            continue
+        }
+
+        var record hostOperationRecord
+        if err := json.Unmarshal(data, &record); err != nil {
+            errs = append(errs, fmt.Errorf("failed to parse operation %s: %w", path, err))
+            continue
+        }
+        if processAlive(record.PID) {
+            continue
+        }
+        interrupted = append(interrupted, interruptedHostOperation{path: path, record: record})
+    }
+    return interrupted, errors.Join(errs...)
+}
+
+// recoverHostOperations cleans up after the interrupted host operations in the inventory. It
+// returns a description of each operation cleaned up.
+func recoverHostOperations(ctx context.Context, stateDir string) ([]string, error) {
+    interrupted, err := interruptedHostOperations(stateDir)
+    errs := []error{err}
+
+    recovered := []string{}
+    for _, op := range interrupted {
+        if err := removeHostArtifacts(ctx, op.record.Artifacts); err != nil {
+            errs = append(errs, fmt.Errorf("failed to clean up after interrupted operation %q: %w", op.record.Description, err))
+            continue
+        }
+        if err := os.Remove(op.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+            errs = append(errs, fmt.Errorf("failed to remove operation %s: %w", op.path, err))
+            continue
+        }
+        recovered = append(recovered, op.record.Description)
+    }
+    return recovered, errors.Join(errs...)
+}
+
+// processAlive reports whether a process with the PID is running.
+func processAlive(pid int) bool {
+    if pid == os.Getpid() {
+        return true
+    }
+    process, err := os.FindProcess(pid)
+    if err != nil {
+        return false
+    }
+    err = process.Signal(syscall.Signal(0))
+    return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// recoveredOperationsWarning tells the user about the interrupted operations cleaned up by
+// recoverHostOperations, and about those that could not be. Failing to clean up is not an
+// error, since the leftovers do not keep the provider from working.
+func recoveredOperationsWarning(recovered []string, err error) diag.Diagnostics {
+    var diags diag.Diagnostics
+    if len(recovered) > 0 {
+        diags = append(diags, diag.Diagnostic{
+            Severity: diag.Warning,
+            Summary:  "Cleaned up interrupted host operations",
+            Detail:   fmt.Sprintf("An earlier run was stopped before it could finish the following operations, and what they had created on the host was removed: %s. Resources they belonged to are created again by the next apply.", strings.Join(recovered, "; ")),
+        })
+    }
+    if err != nil {
+        diags = append(diags, diag.Diagnostic{
+            Severity: diag.Warning,
+            Summary:  "Failed to clean up interrupted host operations",
+            Detail:   fmt.Sprintf("%s. Cleaning up is retried every time the provider is configured.", err),
+        })
+    }
+    return diags
+}
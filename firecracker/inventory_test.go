@@ -0,0 +1,161 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHostOperation_completed(t *testing.T) {
+	stateDir := t.TempDir()
+	artifact := filepath.Join(stateDir, "image.img")
+
+	ctx, op, err := startHostOperation(context.Background(), stateDir, "build image")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := trackArtifact(ctx, hostArtifact{Path: artifact}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := os.WriteFile(artifact, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var record hostOperationRecord
+	data, err := os.ReadFile(op.path)
+	if err != nil {
+		t.Fatalf("Expected the operation to be in the inventory, got %v", err)
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.PID != os.Getpid() || len(record.Artifacts) != 1 || record.Artifacts[0].Path != artifact {
+		t.Errorf("Unexpected record %+v", record)
+	}
+
+	if err := op.finish(nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(op.path); !os.IsNotExist(err) {
+		t.Errorf("Expected the operation to leave the inventory, got %v", err)
+	}
+	if _, err := os.Stat(artifact); err != nil {
+		t.Errorf("Expected the artifact of a completed operation to be kept, got %v", err)
+	}
+}
+
+func TestHostOperation_aborted(t *testing.T) {
+	stateDir := t.TempDir()
+	artifact := filepath.Join(stateDir, "scratch", "vm-1", "data.img")
+
+	ctx, op, err := startHostOperation(context.Background(), stateDir, "create drives of VM vm-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	original := runCommand
+	defer func() { runCommand = original }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		// The provider is stopped while the drive is being formatted
+		op.cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	err = op.finish(createScratchDrive(ctx, artifact, scratchDrive{DriveID: "data", SizeMiB: 1, Format: scratchFormatExt4}))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the operation to be cancelled, got %v", err)
+	}
+	if _, err := os.Stat(artifact); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial drive to be removed, got %v", err)
+	}
+	if _, err := os.Stat(op.path); !os.IsNotExist(err) {
+		t.Errorf("Expected the operation to leave the inventory, got %v", err)
+	}
+}
+
+func TestHostOperation_shutdown(t *testing.T) {
+	originalCtx, originalCancel := shutdownCtx, cancelShutdown
+	defer func() { shutdownCtx, cancelShutdown = originalCtx, originalCancel }()
+	shutdownCtx, cancelShutdown = context.WithCancel(context.Background())
+
+	ctx, op, err := startHostOperation(context.Background(), t.TempDir(), "build image")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer op.finish(nil)
+
+	cancelShutdown()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Error("Expected the operation to be cancelled when the provider is terminated")
+	}
+}
+
+func TestRecoverHostOperations(t *testing.T) {
+	stateDir := t.TempDir()
+
+	// A process that has exited stands in for a provider killed during an operation
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("Cannot run a process: %v", err)
+	}
+
+	leftover := filepath.Join(stateDir, "rootfs", "app.ext4.tmp")
+	running := filepath.Join(stateDir, "rootfs", "web.ext4.tmp")
+	for _, path := range []string{leftover, running} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("partial"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	killed := &hostOperation{
+		path: filepath.Join(hostOperationsDir(stateDir), "killed.json"),
+		record: hostOperationRecord{
+			Description: "build rootfs app.ext4",
+			PID:         cmd.Process.Pid,
+			Artifacts:   []hostArtifact{{Path: leftover}},
+		},
+	}
+	_, op, err := startHostOperation(context.Background(), stateDir, "build rootfs web.ext4")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer op.finish(nil)
+	op.record.Artifacts = []hostArtifact{{Path: running}}
+	for _, o := range []*hostOperation{killed, op} {
+		if err := o.save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recovered, err := recoverHostOperations(context.Background(), stateDir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Join(recovered, ",") != "build rootfs app.ext4" {
+		t.Errorf("Unexpected recovered operations %v", recovered)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("Expected the leftover of the killed operation to be removed, got %v", err)
+	}
+	if _, err := os.Stat(killed.path); !os.IsNotExist(err) {
+		t.Errorf("Expected the killed operation to leave the inventory, got %v", err)
+	}
+	if _, err := os.Stat(running); err != nil {
+		t.Errorf("Expected the artifact of a running operation to be kept, got %v", err)
+	}
+	if _, err := os.Stat(op.path); err != nil {
+		t.Errorf("Expected the running operation to stay in the inventory, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package firecracker
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash/fnv"
+    "math/big"
+    "net"
+    "os"
+    "path/filepath"
+    "sync"
+)
+
+// ipamMu serializes access to IPAM files within the provider process.
+var ipamMu sync.Mutex
+
+// ipamNetwork is the on-disk record of a network and its address allocations.
+type ipamNetwork struct {
+    Name        string            `json:"name"`
+    CIDR        string            `json:"cidr"`
+    Gateway     string            `json:"gateway"`
+    Allocations map[string]string `json:"allocations"`
+}
+
+// ipamPath returns the file holding a network's allocations.
+func ipamPath(stateDir, network string) string {
+    return filepath.Join(stateDir, "ipam", network+".json")
+}
+
+// loadIPAMNetwork reads a network record. It returns nil if the network does not exist.
+func loadIPAMNetwork(stateDir, network string) (*ipamNetwork, error) {
+    data, err := os.ReadFile(ipamPath(stateDir, network))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read network %s: %w", network, err)
+    }
+
+    var record ipamNetwork
+    if err := json.Unmarshal(data, &record); err != nil {
+        return nil, fmt.Errorf("failed to parse network %s: %w", network, err)
+    }
+    if record.Allocations == nil {
+        record.Allocations = map[string]string{}
+    }
+
+    return &record, nil
+}
+
+// saveIPAMNetwork writes a network record atomically.
+func saveIPAMNetwork(stateDir string, record *ipamNetwork) error {
+    path := ipamPath(stateDir, record.Name)
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return fmt.Errorf("failed to create IPAM directory: %w", err)
+    }
+
+    data, err := json.MarshalIndent(record, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode network %s: %w", record.Name, err)
+    }
+
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0o644); err != nil {
+        return fmt.Errorf("failed to write network %s: %w", record.Name, err)
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        return fmt.Errorf("failed to write network %s: %w", record.Name, err)
+    }
+
+    return nil
+}
+
+// createIPAMNetwork registers a network. The gateway defaults to the first host address.
+func createIPAMNetwork(stateDir, name, cidr, gateway string) (*ipamNetwork, error) {
+    ipamMu.Lock()
+    defer ipamMu.Unlock()
+
+    _, subnet, err := net.ParseCIDR(cidr)
+    if err != nil {
+        return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+    }
+    if subnet.IP.To4() == nil {
+        return nil, fmt.Errorf("only IPv4 networks are supported, got %s", cidr)
+    }
+    if size := hostCount(subnet); size < 2 {
+        return nil, fmt.Errorf("network %s is too small for a gateway and guests", cidr)
+    }
+
+    if gateway == "" {
+        gateway = nthHost(subnet, 0).String()
+    } else if ip := net.ParseIP(gateway); ip == nil || !subnet.Contains(ip) {
+        return nil, fmt.Errorf("gateway %s is not inside %s", gateway, cidr)
+    }
+
+    existing, err := loadIPAMNetwork(stateDir, name)
+    if err != nil {
+        return nil, err
+    }
+    if existing != nil && existing.CIDR != subnet.String() {
+        return nil, fmt.Errorf("network %s already exists with CIDR %s", name, existing.CIDR)
+    }
+
+    record := &ipamNetwork{
+        Name:        name,
+        CIDR:        subnet.String(),
+        Gateway:     gateway,
+        Allocations: map[string]string{},
+    }
+    if existing != nil {
+        record.Allocations = existing.Allocations
+    }
+
+    return record, saveIPAMNetwork(stateDir, record)
+}
+
+// deleteIPAMNetwork removes a network record.
+func deleteIPAMNetwork(stateDir, name string) error {
+    ipamMu.Lock()
+    defer ipamMu.Unlock()
+
+    if err := os.Remove(ipamPath(stateDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("failed to delete network %s: %w", name, err)
+    }
+    return nil
+}
+
+// allocateIP returns the address allocated to key in the network, allocating one if needed.
+// The first candidate is derived from the key, so the same key gets the same address as long
+// as it is free; collisions probe forward to the next free address.
+func allocateIP(stateDir, network, key string) (string, *ipamNetwork, error) {
+    ipamMu.Lock()
+    defer ipamMu.Unlock()
+
+    record, err := loadIPAMNetwork(stateDir, network)
+    if err != nil {
+        return "", nil, err
+    }
+    if record == nil {
+        return "", nil, fmt.Errorf("network %s does not exist", network)
+    }
+    if ip, ok := record.Allocations[key]; ok {
+        return ip, record, nil
+    }
+
+    _, subnet, err := net.ParseCIDR(record.CIDR)
+    if err != nil {
+        return "", nil, fmt.Errorf("invalid CIDR %q in network %s: %w", record.CIDR, network, err)
+    }
+
+    used := map[string]bool{record.Gateway: true}
+    for _, ip := range record.Allocations {
+        used[ip] = true
+    }
+
+    size := hostCount(subnet)
+    h := fnv.New32a()
+    h.Write([]byte(key))
+    start := uint64(h.Sum32()) % size
+    for i := uint64(0); i < size; i++ {
+        ip := nthHost(subnet, (start+i)%size).String()
+        if !used[ip] {
+            record.Allocations[key] = ip
+            if err := saveIPAMNetwork(stateDir, record); err != nil {
+                return "", nil, err
+            }
+            return ip, record, nil
+        }
+    }
+
+    return "", nil, fmt.Errorf("network %s (%s) has no free addresses", network, record.CIDR)
+}
+
+// releaseIP frees the address allocated to key. Releasing from a missing network is not an error.
+func releaseIP(stateDir, network, key string) error {
+    ipamMu.Lock()
+    defer ipamMu.Unlock()
+
+    record, err := loadIPAMNetwork(stateDir, network)
+    if err != nil || record == nil {
+        return err
+    }
+    if _, ok := record.Allocations[key]; !ok {
+        return nil
+    }
+
+    delete(record.Allocations, key)
+    return saveIPAMNetwork(stateDir, record)
+}
+
+// hostCount returns the number of usable host addresses in an IPv4 subnet,
+// excluding the network and broadcast addresses.
+func hostCount(subnet *net.IPNet) uint64 {
+    ones, bits := subnet.Mask.Size()
+    total := uint64(1) << uint(bits-ones)
+    if total <= 2 {
+        return 0
+    }
+    return total - 2
+}
+
+// nthHost returns the n-th usable host address (0-based) of an IPv4 subnet.
+func nthHost(subnet *net.IPNet, n uint64) net.IP {
+    base := new(big.Int).SetBytes(subnet.IP.To4())
+    base.Add(base, new(big.Int).SetUint64(n+1))
+    ip := make(net.IP, 4)
+    base.FillBytes(ip)
+    return ip
+}
+
+// ipamSettings describes an address within an IPAM network.
+type ipamSettings struct {
+    AddressCIDR string
+    Netmask     string
+}
+
+// networkSettings returns the CIDR form and netmask of an address in the network.
+func networkSettings(record *ipamNetwork, ip string) (ipamSettings, error) {
+    _, subnet, err := net.ParseCIDR(record.CIDR)
+    if err != nil {
+        return ipamSettings{}, fmt.Errorf("invalid CIDR %q in network %s: %w", record.CIDR, record.Name, err)
+    }
+
+    ones, _ := subnet.Mask.Size()
+    return ipamSettings{
+        AddressCIDR: fmt.Sprintf("%s/%d", ip, ones),
+        Netmask:     net.IP(subnet.Mask).String(),
+    }, nil
+}
@@ -0,0 +1,70 @@
+package firecracker
+
+import (
+	"testing"
+)
+
+func TestIPAM_allocate(t *testing.T) {
+	stateDir := t.TempDir()
+
+	network, err := createIPAMNetwork(stateDir, "fleet", "172.16.0.0/29", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if network.Gateway != "172.16.0.1" {
+		t.Errorf("Expected gateway 172.16.0.1, got %s", network.Gateway)
+	}
+
+	// A /29 has 6 host addresses, one of which is the gateway
+	seen := map[string]bool{}
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		ip, _, err := allocateIP(stateDir, "fleet", key)
+		if err != nil {
+			t.Fatalf("Expected no error allocating %s, got %v", key, err)
+		}
+		if ip == "172.16.0.1" || seen[ip] {
+			t.Errorf("Expected a free address for %s, got %s", key, ip)
+		}
+		seen[ip] = true
+	}
+
+	if _, _, err := allocateIP(stateDir, "fleet", "f"); err == nil {
+		t.Errorf("Expected an error when the network is exhausted")
+	}
+
+	// The same key keeps its address
+	first, _, _ := allocateIP(stateDir, "fleet", "c")
+	second, _, _ := allocateIP(stateDir, "fleet", "c")
+	if first != second {
+		t.Errorf("Expected a stable allocation, got %s and %s", first, second)
+	}
+
+	// Releasing an address makes room for a new key
+	if err := releaseIP(stateDir, "fleet", "c"); err != nil {
+		t.Fatalf("Expected no error releasing, got %v", err)
+	}
+	ip, _, err := allocateIP(stateDir, "fleet", "f")
+	if err != nil {
+		t.Fatalf("Expected no error after release, got %v", err)
+	}
+	if ip != first {
+		t.Errorf("Expected the released address %s to be reused, got %s", first, ip)
+	}
+}
+
+func TestIPAM_deterministic(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	createIPAMNetwork(dirA, "fleet", "10.0.0.0/16", "")
+	createIPAMNetwork(dirB, "fleet", "10.0.0.0/16", "")
+	ipA, _, _ := allocateIP(dirA, "fleet", "web-1")
+	ipB, _, _ := allocateIP(dirB, "fleet", "web-1")
+	if ipA != ipB {
+		t.Errorf("Expected the same key to get the same address on a fresh network, got %s and %s", ipA, ipB)
+	}
+}
+
+func TestIPAM_invalidGateway(t *testing.T) {
+	if _, err := createIPAMNetwork(t.TempDir(), "fleet", "172.16.0.0/24", "10.0.0.1"); err == nil {
+		t.Errorf("Expected an error for a gateway outside the network")
+	}
+}
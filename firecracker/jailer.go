@@ -0,0 +1,271 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    defaultJailerExecFile      = "firecracker"
+    defaultJailerChrootBaseDir = "/srv/jailer"
+
+    // jailerAPISocket is where the jailer has Firecracker create its API socket, in the chroot.
+    jailerAPISocket = "/run/firecracker.socket"
+
+    // jailerKernelPath and jailerInitrdPath are where staged boot files are put in the chroot.
+    jailerKernelPath = "/boot/kernel"
+    jailerInitrdPath = "/boot/initrd"
+
+    // jailerDrivesDir is where staged drive images are put in the chroot.
+    jailerDrivesDir = "/drives"
+)
+
+// jailerIDRegexp matches the VM IDs the jailer accepts.
+var jailerIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9-]{1,64}$`)
+
+// cgroupRootDir is where the cgroup filesystem is mounted. It is a variable so tests can
+// fake the host.
+var cgroupRootDir = "/sys/fs/cgroup"
+
+// defaultJailerCgroupControllers are the cgroup v1 controllers listed when none are configured.
+var defaultJailerCgroupControllers = []string{"cpu", "cpuset", "memory"}
+
+// jailerDevices are the device nodes the jailer creates in the chroot, by name.
+var jailerDevices = map[string]string{
+    "kvm":     "/dev/kvm",
+    "net_tun": "/dev/net/tun",
+    "urandom": "/dev/urandom",
+}
+
+// jailerSpec describes how the jailer runs a VM's Firecracker process.
+type jailerSpec struct {
+    ID                string
+    ExecFile          string
+    ChrootBaseDir     string
+    CgroupVersion     int
+    ParentCgroup      string
+    CgroupControllers []string
+
+    // StageFiles is set when the provider puts the VM's kernel, initrd and drive images in
+    // the chroot, rather than expecting them to be there already.
+    StageFiles bool
+}
+
+// jailerSpecFromConfig returns the settings of the VM's jailer block, the value of its
+// jailer attribute, if it has one. The jailer ID defaults to vmID.
+func jailerSpecFromConfig(raw interface{}, vmID string) (jailerSpec, bool) {
+    blocks, _ := raw.([]interface{})
+    if len(blocks) == 0 || blocks[0] == nil {
+        return jailerSpec{}, false
+    }
+    block := blocks[0].(map[string]interface{})
+
+    spec := jailerSpec{
+        ID:            block["id"].(string),
+        ExecFile:      block["exec_file"].(string),
+        ChrootBaseDir: block["chroot_base_dir"].(string),
+        CgroupVersion: block["cgroup_version"].(int),
+        ParentCgroup:  block["parent_cgroup"].(string),
+    }
+    spec.StageFiles, _ = block["stage_files"].(bool)
+    if spec.ID == "" {
+        spec.ID = vmID
+    }
+    if spec.ParentCgroup == "" {
+        spec.ParentCgroup = filepath.Base(spec.ExecFile)
+    }
+    for _, controller := range block["cgroup_controllers"].([]interface{}) {
+        spec.CgroupControllers = append(spec.CgroupControllers, controller.(string))
+    }
+    if len(spec.CgroupControllers) == 0 {
+        spec.CgroupControllers = defaultJailerCgroupControllers
+    }
+    return spec, true
+}
+
+// chrootPath returns the directory the jailer chroots Firecracker into.
+func (s jailerSpec) chrootPath() string {
+    return filepath.Join(s.ChrootBaseDir, filepath.Base(s.ExecFile), s.ID, "root")
+}
+
+// hostPath returns where a path seen by the jailed Firecracker process is on the host.
+func (s jailerSpec) hostPath(path string) string {
+    return filepath.Join(s.chrootPath(), path)
+}
+
+// cgroupPaths returns the cgroups the jailer places Firecracker in: one per controller
+// with cgroup v1, a single one in the unified hierarchy with cgroup v2.
+func (s jailerSpec) cgroupPaths() []string {
+    if s.CgroupVersion == 2 {
+        return []string{filepath.Join(cgroupRootDir, s.ParentCgroup, s.ID)}
+    }
+    paths := make([]string, 0, len(s.CgroupControllers))
+    for _, controller := range s.CgroupControllers {
+        paths = append(paths, filepath.Join(cgroupRootDir, controller, s.ParentCgroup, s.ID))
+    }
+    return paths
+}
+
+// stagedDrivePath returns the path in the chroot a staged drive image is given to Firecracker
+// as. It is named after the drive, so images with the same file name cannot collide.
+func stagedDrivePath(driveID, path string) string {
+    return filepath.Join(jailerDrivesDir, driveID+filepath.Ext(path))
+}
+
+// drivePath returns the path Firecracker is given for the image of a drive configured with
+// path_on_host.
+func (s jailerSpec) drivePath(driveID, path string) string {
+    if s.StageFiles {
+        return stagedDrivePath(driveID, path)
+    }
+    return path
+}
+
+// vmDriveHostPath returns where the image the VM's Firecracker process opens for a drive
+// configured with path_on_host is on the host: in the chroot when the VM is jailed.
+func vmDriveHostPath(d *schema.ResourceData, driveID, path string) string {
+    if spec, ok := jailerSpecFromConfig(d.Get("jailer"), d.Id()); ok {
+        return spec.hostPath(spec.drivePath(driveID, path))
+    }
+    return path
+}
+
+// jailerFile is a file staged in a jailed VM's chroot.
+type jailerFile struct {
+    // Source is the file on the host.
+    Source string
+    // Path is where the file is put, in the chroot.
+    Path string
+    // DriveID is the drive the file is the image of, if any.
+    DriveID string
+}
+
+// jailerStagedFiles returns the files the provider stages in the chroot of a VM whose jailer
+// block has stage_files set: its kernel, initrd and drive images, including its scratch
+// drives and cloud-init seed image.
+func jailerStagedFiles(d *schema.ResourceData, stateDir, vmID string) (jailerSpec, []jailerFile) {
+    spec, ok := jailerSpecFromConfig(d.Get("jailer"), vmID)
+    if !ok || !spec.StageFiles {
+        return spec, nil
+    }
+
+    files := []jailerFile{{Source: d.Get("kernel_image_path").(string), Path: jailerKernelPath}}
+    if initrdPath := d.Get("initrd_path").(string); initrdPath != "" {
+        files = append(files, jailerFile{Source: initrdPath, Path: jailerInitrdPath})
+    }
+    for _, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        driveID := drive["drive_id"].(string)
+        path, _ := drive["path_on_host"].(string)
+        if path == "" {
+            path = scratchDrivePath(stateDir, vmID, driveID)
+        }
+        files = append(files, jailerFile{Source: path, Path: stagedDrivePath(driveID, path), DriveID: driveID})
+    }
+    if seed, ok := cloudInitSpecFromConfig(d, vmID); ok && seed.Datasource == cloudInitDatasourceNoCloud {
+        path := seedImagePath(stateDir, vmID)
+        files = append(files, jailerFile{Source: path, Path: stagedDrivePath(cloudInitDriveID, path), DriveID: cloudInitDriveID})
+    }
+    return spec, files
+}
+
+// stageJailerFiles puts files in the VM's chroot, where the jailed Firecracker process can
+// open them. Files are hard-linked, so the VM writes to the images it was configured with,
+// and copied when the chroot is on another filesystem.
+func stageJailerFiles(ctx context.Context, spec jailerSpec, files []jailerFile) error {
+    for _, file := range files {
+        dest := spec.hostPath(file.Path)
+        if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+            return fmt.Errorf("failed to create %s in the jailer chroot: %w", filepath.Dir(file.Path), err)
+        }
+        // Replace whatever a previous attempt left behind
+        if err := os.Remove(dest); err != nil && !errors.Is(err, os.ErrNotExist) {
+            return fmt.Errorf("failed to replace %s: %w", dest, err)
+        }
+        if err := trackArtifact(ctx, hostArtifact{Path: dest}); err != nil {
+            return err
+        }
+
+        method := "link"
+        if err := os.Link(file.Source, dest); err != nil {
+            method = "copy"
+            if err := copyFile(file.Source, dest); err != nil {
+                return fmt.Errorf("failed to stage %s in the jailer chroot: %w", file.Source, err)
+            }
+        }
+        tflog.Debug(ctx, "Staged file in jailer chroot", map[string]interface{}{
+            "source": file.Source,
+            "path":   dest,
+            "method": method,
+        })
+    }
+    return nil
+}
+
+// removeJailerFiles removes the files staged in the VM's chroot. The files they were staged
+// from are left alone.
+func removeJailerFiles(spec jailerSpec, files []jailerFile) error {
+    for _, file := range files {
+        if err := os.Remove(spec.hostPath(file.Path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+            return fmt.Errorf("failed to remove staged file: %w", err)
+        }
+    }
+    return nil
+}
+
+// setJailerPaths records in the VM's state where the jailer puts its chroot, cgroups,
+// device nodes and drives, for preparing bind mounts and debugging.
+func setJailerPaths(d *schema.ResourceData, stateDir string) error {
+    spec, ok := jailerSpecFromConfig(d.Get("jailer"), d.Id())
+    if !ok {
+        return nil
+    }
+    block := d.Get("jailer").([]interface{})[0].(map[string]interface{})
+
+    devices := map[string]interface{}{}
+    for name, path := range jailerDevices {
+        devices[name] = spec.hostPath(path)
+    }
+    drives := map[string]interface{}{}
+    if _, files := jailerStagedFiles(d, stateDir, d.Id()); len(files) > 0 {
+        for _, file := range files {
+            if file.DriveID != "" {
+                drives[file.DriveID] = spec.hostPath(file.Path)
+            }
+        }
+    } else {
+        for _, raw := range d.Get("drives").([]interface{}) {
+            drive, ok := raw.(map[string]interface{})
+            if !ok {
+                continue
+            }
+            if path, _ := drive["path_on_host"].(string); path != "" {
+                drives[drive["drive_id"].(string)] = spec.hostPath(path)
+            }
+        }
+    }
+    cgroups := []interface{}{}
+    for _, path := range spec.cgroupPaths() {
+        cgroups = append(cgroups, path)
+    }
+
+    block["chroot_path"] = spec.chrootPath()
+    block["api_socket_path"] = spec.hostPath(jailerAPISocket)
+    block["cgroup_paths"] = cgroups
+    block["device_paths"] = devices
+    block["drive_paths"] = drives
+    if err := d.Set("jailer", []interface{}{block}); err != nil {
+        return fmt.Errorf("failed to record jailer paths: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,215 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestJailerSpecCgroupPaths(t *testing.T) {
+	cases := map[string]struct {
+		block    map[string]interface{}
+		expected []string
+	}{
+		"v1 defaults": {
+			map[string]interface{}{"id": "web-1"},
+			[]string{"/sys/fs/cgroup/cpu/firecracker/web-1", "/sys/fs/cgroup/cpuset/firecracker/web-1", "/sys/fs/cgroup/memory/firecracker/web-1"},
+		},
+		"v1 controllers": {
+			map[string]interface{}{"id": "web-1", "exec_file": "/usr/bin/firecracker-v1.10", "cgroup_controllers": []interface{}{"pids"}},
+			[]string{"/sys/fs/cgroup/pids/firecracker-v1.10/web-1"},
+		},
+		"v2": {
+			map[string]interface{}{"id": "web-1", "cgroup_version": 2, "parent_cgroup": "vms.slice"},
+			[]string{"/sys/fs/cgroup/vms.slice/web-1"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+				"jailer": []interface{}{tc.block},
+			})
+			spec, ok := jailerSpecFromConfig(d.Get("jailer"), "vm")
+			if !ok {
+				t.Fatal("Expected a jailer spec")
+			}
+			if got := spec.cgroupPaths(); strings.Join(got, " ") != strings.Join(tc.expected, " ") {
+				t.Errorf("Expected cgroups %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSetJailerPaths(t *testing.T) {
+	ctx := context.Background()
+
+	// The jailed Firecracker process finds the kernel in its chroot
+	base := t.TempDir()
+	chroot := filepath.Join(base, "firecracker", "web-1", "root")
+	if err := os.MkdirAll(chroot, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(chroot, "vmlinux"), []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": "/vmlinux",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/rootfs.ext4", "is_root_device": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+		},
+		"jailer": []interface{}{
+			map[string]interface{}{"id": "web-1", "chroot_base_dir": base, "cgroup_version": 2, "stage_files": false},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, d, configureFakeProvider(t, t.TempDir())); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	expected := map[string]string{
+		"jailer.0.chroot_path":          chroot,
+		"jailer.0.api_socket_path":      chroot + "/run/firecracker.socket",
+		"jailer.0.cgroup_paths.0":       "/sys/fs/cgroup/firecracker/web-1",
+		"jailer.0.device_paths.kvm":     chroot + "/dev/kvm",
+		"jailer.0.device_paths.net_tun": chroot + "/dev/net/tun",
+		"jailer.0.drive_paths.rootfs":   chroot + "/rootfs.ext4",
+	}
+	for key, value := range expected {
+		if got := d.Get(key); got != value {
+			t.Errorf("Expected %s to be %q, got %q", key, value, got)
+		}
+	}
+}
+
+func TestJailerIDDefaultsToVMID(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"jailer": []interface{}{map[string]interface{}{}},
+	})
+	d.SetId("vm-1")
+	if got := vmDriveHostPath(d, "rootfs", "/images/rootfs.ext4"); got != "/srv/jailer/firecracker/vm-1/root/drives/rootfs.ext4" {
+		t.Errorf("Unexpected host path %s", got)
+	}
+}
+
+func TestCheckHostFiles_jailed(t *testing.T) {
+	base := t.TempDir()
+	chroot := filepath.Join(base, "firecracker", "web-1", "root")
+	if err := os.MkdirAll(chroot, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(chroot, "vmlinux"), []byte("kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		jailer   map[string]interface{}
+		expected string
+	}{
+		"missing in chroot": {map[string]interface{}{"id": "web-1", "chroot_base_dir": base, "stage_files": false}, "path_on_host of drive rootfs " + filepath.Join(chroot, "rootfs.ext4") + " does not exist"},
+		"id not known yet":  {map[string]interface{}{"chroot_base_dir": base, "stage_files": false}, ""},
+		"staged from host":  {map[string]interface{}{"chroot_base_dir": base}, "kernel_image_path /vmlinux does not exist"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"kernel_image_path": "/vmlinux",
+				"drives": []interface{}{
+					map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/rootfs.ext4", "is_root_device": true},
+				},
+				"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+				"jailer":         []interface{}{tc.jailer},
+			}
+			_, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), &FirecrackerClient{})
+			if tc.expected == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tc.expected != "" && (err == nil || !strings.Contains(err.Error(), tc.expected)) {
+				t.Errorf("Expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestStageJailerFiles(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+
+	images := t.TempDir()
+	for _, name := range []string{"vmlinux", "rootfs.ext4"} {
+		if err := os.WriteFile(filepath.Join(images, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := t.TempDir()
+	chroot := filepath.Join(base, "firecracker", "web-1", "root")
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": filepath.Join(images, "vmlinux"),
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": filepath.Join(images, "rootfs.ext4"), "is_root_device": true},
+			map[string]interface{}{"drive_id": "scratch", "size_mib": 1, "is_root_device": false},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+		},
+		"jailer": []interface{}{
+			map[string]interface{}{"id": "web-1", "chroot_base_dir": base},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, d, client); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	// Files are linked into the chroot, so the VM writes to the images it was configured with
+	staged := map[string]string{
+		filepath.Join(images, "vmlinux"):              filepath.Join(chroot, "boot", "kernel"),
+		filepath.Join(images, "rootfs.ext4"):          filepath.Join(chroot, "drives", "rootfs.ext4"),
+		scratchDrivePath(stateDir, d.Id(), "scratch"): filepath.Join(chroot, "drives", "scratch.img"),
+	}
+	for source, dest := range staged {
+		sourceInfo, err := os.Stat(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		destInfo, err := os.Stat(dest)
+		if err != nil || !os.SameFile(sourceInfo, destInfo) {
+			t.Errorf("Expected %s to be linked to %s, got %v", dest, source, err)
+		}
+	}
+	if got := d.Get("jailer.0.drive_paths.scratch"); got != filepath.Join(chroot, "drives", "scratch.img") {
+		t.Errorf("Unexpected scratch drive path %q", got)
+	}
+
+	// Firecracker is given the paths in the chroot
+	for file, expected := range map[string]string{
+		"boot-source.json":   `"kernel_image_path":"/boot/kernel"`,
+		"drives_rootfs.json": `"path_on_host":"/drives/rootfs.ext4"`,
+	} {
+		data, err := os.ReadFile(filepath.Join(stateDir, "fake", "test", file))
+		if err != nil || !strings.Contains(string(data), expected) {
+			t.Errorf("Expected %s to contain %s, got %s, %v", file, expected, data, err)
+		}
+	}
+
+	// Deleting the VM removes the staged files but not the images they were staged from
+	if diags := resourceFirecrackerVMDelete(ctx, d, client); diags.HasError() {
+		t.Fatalf("Failed to delete VM: %v", diags)
+	}
+	for source, dest := range staged {
+		if _, err := os.Stat(dest); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", dest, err)
+		}
+		if _, err := os.Stat(source); err != nil && !strings.HasPrefix(source, stateDir) {
+			t.Errorf("Expected %s to be kept, got %v", source, err)
+		}
+	}
+}
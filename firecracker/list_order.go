@@ -0,0 +1,79 @@
+package firecracker
+
+import (
+    "reflect"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// matchElementsByID makes the blocks of a list attribute identified by idKey, such as the
+// drives of a VM by drive_id, keep their identity when they are reordered: a plan only
+// reordering the blocks has no diff, instead of changing every block whose position moved.
+// Plans changing blocks compare them by position as before.
+func matchElementsByID(listKey, idKey string, elem *schema.Resource) *schema.Resource {
+    for _, field := range elem.Schema {
+        suppress := field.DiffSuppressFunc
+        field.DiffSuppressFunc = func(k, old, new string, d *schema.ResourceData) bool {
+            if onlyReordered(d, listKey, idKey, elem) {
+                return true
+            }
+            return suppress != nil && suppress(k, old, new, d)
+        }
+    }
+    return elem
+}
+
+// onlyReordered reports whether the configuration of a list holds the blocks of the state in
+// another order, each block unchanged but for its position.
+func onlyReordered(d *schema.ResourceData, listKey, idKey string, elem *schema.Resource) bool {
+    rawOld, rawNew := d.GetChange(listKey)
+    oldList, _ := rawOld.([]interface{})
+    newList, _ := rawNew.([]interface{})
+    if len(oldList) != len(newList) || len(oldList) == 0 {
+        return false
+    }
+
+    oldByID := make(map[string]map[string]interface{}, len(oldList))
+    reordered := false
+    for i, raw := range oldList {
+        block, ok := raw.(map[string]interface{})
+        if !ok {
+            return false
+        }
+        id, _ := block[idKey].(string)
+        if _, dup := oldByID[id]; dup || id == "" {
+            return false
+        }
+        oldByID[id] = block
+
+        if next, ok := newList[i].(map[string]interface{}); !ok || next[idKey] != id {
+            reordered = true
+        }
+    }
+    if !reordered {
+        return false
+    }
+
+    seen := make(map[string]bool, len(newList))
+    for _, raw := range newList {
+        block, _ := raw.(map[string]interface{})
+        id, _ := block[idKey].(string)
+        previous, ok := oldByID[id]
+        if !ok || seen[id] {
+            return false
+        }
+        seen[id] = true
+
+        for name, field := range elem.Schema {
+            if reflect.DeepEqual(block[name], previous[name]) {
+                continue
+            }
+            // Computed values not set in the configuration are kept from the state
+            if field.Computed && (block[name] == nil || reflect.ValueOf(block[name]).IsZero()) {
+                continue
+            }
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,106 @@
+package firecracker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestMatchElementsByID_drives(t *testing.T) {
+	rootfs := map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true}
+	data := map[string]interface{}{"drive_id": "data", "path_on_host": "/images/data.ext4", "is_root_device": false}
+	moved := map[string]interface{}{"drive_id": "data", "path_on_host": "/images/data-2.ext4", "is_root_device": false}
+	logs := map[string]interface{}{"drive_id": "logs", "path_on_host": "/images/logs.ext4", "is_root_device": false}
+	config := func(drives ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"kernel_image_path": "/images/vmlinux",
+			"drives":            drives,
+			"machine_config":    []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		drives []interface{}
+		diff   bool
+	}{
+		"unchanged":           {[]interface{}{rootfs, data}, false},
+		"reordered":           {[]interface{}{data, rootfs}, false},
+		"reordered and moved": {[]interface{}{moved, rootfs}, true},
+		"moved":               {[]interface{}{rootfs, moved}, true},
+		"replaced":            {[]interface{}{logs, rootfs}, true},
+		"added":               {[]interface{}{data, rootfs, logs}, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			prior := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config(rootfs, data))
+			prior.SetId("web")
+			diff, err := resourceFirecrackerVM().Diff(context.Background(), prior.State(), terraform.NewResourceConfigRaw(config(tc.drives...)), nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := listDiff(diff, "drives."); (len(got) > 0) != tc.diff {
+				t.Errorf("Expected diff to be %t, got %v", tc.diff, got)
+			}
+		})
+	}
+}
+
+func TestMatchElementsByID_computedMAC(t *testing.T) {
+	iface := func(id, dev, mac string) map[string]interface{} {
+		block := map[string]interface{}{"iface_id": id, "host_dev_name": dev}
+		if mac != "" {
+			block["guest_mac"] = mac
+		}
+		return block
+	}
+	config := func(ifaces ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"kernel_image_path":  "/images/vmlinux",
+			"drives":             []interface{}{map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true}},
+			"machine_config":     []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+			"network_interfaces": ifaces,
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		ifaces []interface{}
+		diff   bool
+	}{
+		"derived MACs reordered": {[]interface{}{iface("eth1", "tap1", ""), iface("eth0", "tap0", "")}, false},
+		"set MACs reordered":     {[]interface{}{iface("eth1", "tap1", "02:00:00:00:00:02"), iface("eth0", "tap0", "02:00:00:00:00:01")}, false},
+		"MAC changed":            {[]interface{}{iface("eth1", "tap1", "02:00:00:00:00:09"), iface("eth0", "tap0", "")}, true},
+		"device changed":         {[]interface{}{iface("eth1", "tap9", ""), iface("eth0", "tap0", "")}, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// The MACs were derived when the VM was created
+			prior := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config(
+				iface("eth0", "tap0", "02:00:00:00:00:01"),
+				iface("eth1", "tap1", "02:00:00:00:00:02"),
+			))
+			prior.SetId("web")
+			diff, err := resourceFirecrackerVM().Diff(context.Background(), prior.State(), terraform.NewResourceConfigRaw(config(tc.ifaces...)), nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := listDiff(diff, "network_interfaces."); (len(got) > 0) != tc.diff {
+				t.Errorf("Expected diff to be %t, got %v", tc.diff, got)
+			}
+		})
+	}
+}
+
+// listDiff returns the attributes of a diff under prefix.
+func listDiff(diff *terraform.InstanceDiff, prefix string) []string {
+	var keys []string
+	if diff == nil {
+		return keys
+	}
+	for key := range diff.Attributes {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
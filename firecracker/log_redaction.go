@@ -0,0 +1,68 @@
+package firecracker
+
+import (
+    "context"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// redactedValue replaces sensitive values in log output.
+const redactedValue = "(sensitive)"
+
+// redactVMConfig returns a copy of a VM configuration for logging, without the contents of
+// its MMDS data store, which may hold user data and resolved secrets, and without its
+// sensitive kernel parameters.
+func redactVMConfig(config map[string]interface{}) map[string]interface{} {
+    redacted := make(map[string]interface{}, len(config))
+    for key, value := range config {
+        redacted[key] = value
+    }
+    if redacted["mmds"] != nil {
+        redacted["mmds"] = redactedValue
+    }
+    if sensitive, ok := config["sensitive_boot_args"].(string); ok {
+        redacted["sensitive_boot_args"] = redactedValue
+        if bootSource, ok := config["boot-source"].(map[string]interface{}); ok {
+            copied := make(map[string]interface{}, len(bootSource))
+            for key, value := range bootSource {
+                copied[key] = value
+            }
+            if bootArgs, ok := bootSource["boot_args"].(string); ok {
+                copied["boot_args"] = withoutSensitiveBootArgs(bootArgs, sensitive)
+            }
+            redacted["boot-source"] = copied
+        }
+    }
+    return redacted
+}
+
+// redactRequestPayload returns the body of a request to the API for logging. Bodies sent to
+// the MMDS data store are left out, as they may hold user data and resolved secrets.
+func (c *FirecrackerClient) redactRequestPayload(url string, payload []byte) string {
+    if strings.TrimSuffix(strings.TrimPrefix(url, c.BaseURL), "/") == "/mmds" {
+        return redactedValue
+    }
+    return string(payload)
+}
+
+// sensitiveValuesKey is the context key of the values HTTP dumps leave out.
+type sensitiveValuesKey struct{}
+
+// maskSensitiveBootArgs returns a context whose log output and HTTP dumps hide the VM's
+// sensitive kernel parameters wherever they appear, such as in the boot source sent to the API.
+func maskSensitiveBootArgs(ctx context.Context, d *schema.ResourceData) context.Context {
+    fields := strings.Fields(d.Get("sensitive_boot_args").(string))
+    if len(fields) == 0 {
+        return ctx
+    }
+    ctx = context.WithValue(ctx, sensitiveValuesKey{}, fields)
+    return tflog.MaskAllFieldValuesStrings(ctx, fields...)
+}
+
+// sensitiveValues returns a copy of the values maskSensitiveBootArgs hides in ctx.
+func sensitiveValues(ctx context.Context) []string {
+    fields, _ := ctx.Value(sensitiveValuesKey{}).([]string)
+    return append([]string(nil), fields...)
+}
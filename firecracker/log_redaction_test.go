@@ -0,0 +1,77 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestRedactRequestPayload(t *testing.T) {
+	client := &FirecrackerClient{BaseURL: "http://localhost:8080"}
+	for url, expected := range map[string]string{
+		"http://localhost:8080/mmds":        redactedValue,
+		"http://localhost:8080/mmds/":       redactedValue,
+		"http://localhost:8080/mmds/config": `{"a":1}`,
+		"http://localhost:8080/boot-source": `{"a":1}`,
+	} {
+		if got := client.redactRequestPayload(url, []byte(`{"a":1}`)); got != expected {
+			t.Errorf("redactRequestPayload(%q) = %q, expected %q", url, got, expected)
+		}
+	}
+}
+
+func TestResourceFirecrackerVMCreate_redactsLogs(t *testing.T) {
+	stubSecretCommands(t, map[string]string{"db/password": "hunter2"})
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+	stateDir := t.TempDir()
+
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	provider := configureFakeProvider(t, stateDir)
+	provider.secrets = secretSources{"pass": {Name: "pass", Type: secretSourceExec, Command: []string{"pass", "show"}}}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path":   image,
+		"sensitive_boot_args": "agent.token=t0ps3cret",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+		"network_interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+		},
+		"cloud_init": []interface{}{
+			map[string]interface{}{"datasource": "mmds", "user_data": "#cloud-config\npassword: secret://pass/db/password\n"},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	logs := output.String()
+	if !strings.Contains(logs, "Rendered VM configuration") {
+		t.Fatalf("Expected debug logs to be captured, got:\n%s", logs)
+	}
+	for _, secret := range []string{"t0ps3cret", "hunter2", "#cloud-config"} {
+		if strings.Contains(logs, secret) {
+			t.Errorf("Expected %q to be redacted from the logs", secret)
+		}
+	}
+
+	// The VM boots with the sensitive parameters, which stay out of boot_args
+	bootSource, err := os.ReadFile(filepath.Join(stateDir, "fake", "test", "boot-source.json"))
+	if err != nil || !strings.Contains(string(bootSource), "agent.token=t0ps3cret") {
+		t.Errorf("Expected the sensitive parameters on the kernel command line, got %s, %v", bootSource, err)
+	}
+	if got := d.Get("boot_args").(string); strings.Contains(got, "t0ps3cret") {
+		t.Errorf("Expected boot_args to leave out the sensitive parameters, got %q", got)
+	}
+}
@@ -0,0 +1,240 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/url"
+    "path"
+    "sort"
+    "strconv"
+    "strings"
+
+    sdk "github.com/avkcode/terraform-provider-firecracker/client"
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// defaultMigrationDir is where the snapshot of a migrated VM is written on both hosts.
+const defaultMigrationDir = "/var/lib/firecracker/migrations"
+
+// migrationSpec describes how a VM is moved to another host of the provider's host pool.
+type migrationSpec struct {
+    Directory string
+    SSHUser   string
+    SSHPort   int
+}
+
+// migrationSpecFromConfig returns the settings of the VM's migration block, or the
+// defaults when it has none.
+func migrationSpecFromConfig(d *schema.ResourceData) migrationSpec {
+    spec := migrationSpec{Directory: defaultMigrationDir}
+    raw := d.Get("migration").([]interface{})
+    if len(raw) == 0 || raw[0] == nil {
+        return spec
+    }
+    block := raw[0].(map[string]interface{})
+    if directory := block["directory"].(string); directory != "" {
+        spec.Directory = directory
+    }
+    spec.SSHUser = block["ssh_user"].(string)
+    spec.SSHPort = block["ssh_port"].(int)
+    return spec
+}
+
+// sshArgs returns the options of the ssh commands run on the hosts. Batch mode makes ssh
+// fail instead of prompting, which Terraform could not answer.
+func (s migrationSpec) sshArgs() []string {
+    args := []string{"-o", "BatchMode=yes"}
+    if s.SSHPort != 0 {
+        args = append(args, "-p", strconv.Itoa(s.SSHPort))
+    }
+    return args
+}
+
+// planMigration decides whether changing the host of a VM moves it or replaces it. VMs are
+// only moved when the migration experiment is enabled, between hosts of the host pool, and
+// when they are not jailed, since the jailer's chroot is tied to the host.
+func planMigration(provider *FirecrackerClient, d *schema.ResourceDiff) error {
+    if d.Id() == "" || !d.HasChange("host") {
+        return nil
+    }
+    old, _ := d.GetChange("host")
+    if provider == nil || !provider.featureEnabled(featureMigration) || old.(string) == "" || len(d.Get("jailer").([]interface{})) > 0 {
+        return d.ForceNew("host")
+    }
+    return nil
+}
+
+// sshDestination returns the SSH destination of a pooled host: its ssh_host, or the host of
+// its Firecracker API URL.
+func (h *hostEntry) sshDestination(user string) (string, error) {
+    host := h.SSHHost
+    if host == "" {
+        u, err := url.Parse(h.Client.BaseURL)
+        if err != nil || u.Hostname() == "" || isFakeAPIURL(h.Client.BaseURL) {
+            return "", fmt.Errorf("host %s must set ssh_host, it cannot be derived from the Firecracker API URL %q", h.Name, h.Client.BaseURL)
+        }
+        host = u.Hostname()
+    }
+    if user != "" {
+        host = user + "@" + host
+    }
+    return host, nil
+}
+
+// migrationFiles returns the files a VM needs on the host it is moved to besides its
+// snapshot: its drive images, where Firecracker opened them on the source host.
+func migrationFiles(d *schema.ResourceData, vmID string) []string {
+    staged := map[string]string{}
+    if spec, ok := stagingSpecFromConfig(d); ok {
+        staged = stagedPaths(d, spec, vmID)
+    }
+
+    var files []string
+    for _, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        if remotePath, ok := staged[drive["drive_id"].(string)]; ok {
+            files = append(files, remotePath)
+        } else if source, _ := drive["path_on_host"].(string); source != "" {
+            files = append(files, source)
+        }
+    }
+    return files
+}
+
+// migrateVM moves a VM between hosts of the provider's host pool: it pauses the VM and
+// snapshots it on the source host, copies the snapshot and the drive images to the target
+// host, restores the VM there and shuts down the source. Until the VM runs on the target,
+// failures resume it on the source, so it keeps running where it was.
+func migrateVM(ctx context.Context, d *schema.ResourceData, provider *FirecrackerClient, vmID, from, to string) diag.Diagnostics {
+    source := provider.hostByName(from)
+    if source == nil {
+        return diag.FromErr(fmt.Errorf("host %q is not configured in the provider", from))
+    }
+    if _, err := provider.placeOnHost(vmID, to); err != nil {
+        return diag.FromErr(fmt.Errorf("cannot move VM %s to host %s: %w", vmID, to, err))
+    }
+    target := provider.hostByName(to)
+
+    spec := migrationSpecFromConfig(d)
+    sourceDest, err := source.sshDestination(spec.SSHUser)
+    if err == nil {
+        var targetDest string
+        if targetDest, err = target.sshDestination(spec.SSHUser); err == nil {
+            err = transferVM(ctx, d, spec, source, target, sourceDest, targetDest, vmID)
+        }
+    }
+    if err != nil {
+        provider.hostLoads.record(vmID, from)
+        return diag.FromErr(fmt.Errorf("failed to move VM %s from host %s to %s: %w", vmID, from, to, err))
+    }
+
+    // The VM runs on the target now, so failing to clean up the source only warrants a warning
+    var diags diag.Diagnostics
+    if err := source.Client.DeleteVM(ctx, vmID); err != nil {
+        diags = append(diags, migrationCleanupWarning(vmID, from, err))
+    }
+    args := append(spec.sshArgs(), sourceDest, "rm", "-rf", path.Join(spec.Directory, vmID))
+    if output, err := runCommand(ctx, "ssh", args...); err != nil {
+        diags = append(diags, migrationCleanupWarning(vmID, from, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))))
+    }
+    if staging, ok := stagingSpecFromConfig(d); ok {
+        if destination, err := staging.destination(source.Client); err == nil {
+            if err := removeStagedFiles(ctx, staging, destination, vmID); err != nil {
+                diags = append(diags, migrationCleanupWarning(vmID, from, err))
+            }
+        }
+    }
+    return diags
+}
+
+// transferVM snapshots the VM on the source host, copies its files to the target host and
+// restores it there. The VM is resumed on the source when any step fails.
+func transferVM(ctx context.Context, d *schema.ResourceData, spec migrationSpec, source, target *hostEntry, sourceDest, targetDest, vmID string) error {
+    dir := path.Join(spec.Directory, vmID)
+    snapshotPath, memFilePath := path.Join(dir, "vmstate"), path.Join(dir, "memory")
+    files := append([]string{snapshotPath, memFilePath}, migrationFiles(d, vmID)...)
+
+    tflog.Info(ctx, "Moving Firecracker VM", map[string]interface{}{
+        "id":    vmID,
+        "from":  source.Name,
+        "to":    target.Name,
+        "files": files,
+    })
+
+    args := append(spec.sshArgs(), sourceDest, "mkdir", "-p", dir)
+    if output, err := runCommand(ctx, "ssh", args...); err != nil {
+        return fmt.Errorf("failed to create %s on %s: %w: %s", dir, sourceDest, err, strings.TrimSpace(string(output)))
+    }
+
+    // The VM stays paused from the snapshot on, so its drives do not change while they are
+    // copied
+    api := source.Client.api()
+    if err := api.Pause(ctx); err != nil {
+        return fmt.Errorf("failed to pause VM: %w", err)
+    }
+    err := copyMigrationFiles(ctx, spec, source.Client, sourceDest, targetDest, snapshotPath, memFilePath, files)
+    if err == nil {
+        err = target.Client.LoadSnapshot(ctx, snapshotPath, memFilePath, true)
+    }
+    if err != nil {
+        if resumeErr := api.Resume(ctx); resumeErr != nil {
+            return errors.Join(err, fmt.Errorf("failed to resume VM on the source host: %w", resumeErr))
+        }
+        return err
+    }
+    return nil
+}
+
+// copyMigrationFiles writes the snapshot of the paused VM on the source host and copies it
+// and the VM's drive images to the same paths on the target host. scp relays the files
+// through the host running Terraform, so the hosts need no access to each other.
+func copyMigrationFiles(ctx context.Context, spec migrationSpec, source *FirecrackerClient, sourceDest, targetDest, snapshotPath, memFilePath string, files []string) error {
+    err := source.api().CreateSnapshot(ctx, sdk.SnapshotCreateParams{
+        SnapshotType: sdk.SnapshotTypeFull,
+        SnapshotPath: snapshotPath,
+        MemFilePath:  memFilePath,
+    })
+    if err != nil {
+        return fmt.Errorf("failed to create snapshot: %w", err)
+    }
+
+    dirs := map[string]bool{}
+    for _, file := range files {
+        dirs[path.Dir(file)] = true
+    }
+    mkdir := append(spec.sshArgs(), targetDest, "mkdir", "-p")
+    for dir := range dirs {
+        mkdir = append(mkdir, dir)
+    }
+    sort.Strings(mkdir[len(mkdir)-len(dirs):])
+    if output, err := runCommand(ctx, "ssh", mkdir...); err != nil {
+        return fmt.Errorf("failed to create directories on %s: %w: %s", targetDest, err, strings.TrimSpace(string(output)))
+    }
+
+    for _, file := range files {
+        args := []string{"-3", "-q", "-o", "BatchMode=yes"}
+        if spec.SSHPort != 0 {
+            args = append(args, "-P", strconv.Itoa(spec.SSHPort))
+        }
+        args = append(args, sourceDest+":"+file, targetDest+":"+file)
+        if output, err := runCommand(ctx, "scp", args...); err != nil {
+            return fmt.Errorf("failed to copy %s to %s: %w: %s", file, targetDest, err, strings.TrimSpace(string(output)))
+        }
+    }
+    return nil
+}
+
+// migrationCleanupWarning reports that the source host of a moved VM was not cleaned up.
+func migrationCleanupWarning(vmID, host string, err error) diag.Diagnostic {
+    return diag.Diagnostic{
+        Severity: diag.Warning,
+        Summary:  "Failed to clean up after moving VM",
+        Detail:   fmt.Sprintf("VM %s was moved, but cleaning up host %s failed: %s. Remove its snapshot and shut down its Firecracker process manually.", vmID, host, err),
+    }
+}
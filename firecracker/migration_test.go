@@ -0,0 +1,161 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// configureMigrationProvider configures a provider with two fake hosts, with or without the
+// migration experiment.
+func configureMigrationProvider(t *testing.T, stateDir string, experiments []interface{}) *FirecrackerClient {
+	t.Helper()
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"state_dir":   stateDir,
+		"experiments": experiments,
+		"host": []interface{}{
+			map[string]interface{}{"name": "edge-1", "base_url": "fake://edge-1", "ssh_host": "10.0.0.1"},
+			map[string]interface{}{"name": "edge-2", "base_url": "fake://edge-2", "ssh_host": "10.0.0.2"},
+		},
+	})
+	client, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("Failed to configure provider: %v", diags)
+	}
+	return client.(*FirecrackerClient)
+}
+
+func TestPlanMigration(t *testing.T) {
+	vm := func(host string) map[string]interface{} {
+		return map[string]interface{}{
+			"host":              host,
+			"kernel_image_path": "/images/vmlinux",
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true, "is_read_only": true},
+			},
+			"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+		}
+	}
+	current := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, vm("edge-1"))
+	current.SetId("vm-1")
+	state := current.State()
+	config := terraform.NewResourceConfigRaw(vm("edge-2"))
+
+	for name, tc := range map[string]struct {
+		experiments []interface{}
+		replace     bool
+	}{
+		"without migration": {nil, true},
+		"with migration":    {[]interface{}{featureMigration}, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			provider := configureMigrationProvider(t, t.TempDir(), tc.experiments)
+			diff, err := resourceFirecrackerVM().Diff(context.Background(), state, config, provider)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			host := diff.Attributes["host"]
+			if host == nil || host.New != "edge-2" {
+				t.Fatalf("Expected the host to change, got %v", host)
+			}
+			if host.RequiresNew != tc.replace {
+				t.Errorf("Expected RequiresNew %v, got %v", tc.replace, host.RequiresNew)
+			}
+		})
+	}
+}
+
+func TestMigrateVM(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	provider := configureMigrationProvider(t, stateDir, []interface{}{featureMigration})
+
+	commands := stubStagingCommands(t)
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": "/images/vmlinux",
+		"host":              "edge-1",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true},
+		},
+		"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+		"migration":      []interface{}{map[string]interface{}{"ssh_user": "deploy"}},
+	})
+	d.SetId("vm-1")
+
+	if diags := migrateVM(ctx, d, provider, "vm-1", "edge-1", "edge-2"); diags.HasError() {
+		t.Fatalf("Failed to move VM: %v", diags)
+	}
+
+	expected := []string{
+		"ssh -o BatchMode=yes deploy@10.0.0.1 mkdir -p /var/lib/firecracker/migrations/vm-1",
+		"ssh -o BatchMode=yes deploy@10.0.0.2 mkdir -p /images /var/lib/firecracker/migrations/vm-1",
+		"scp -3 -q -o BatchMode=yes deploy@10.0.0.1:/var/lib/firecracker/migrations/vm-1/vmstate deploy@10.0.0.2:/var/lib/firecracker/migrations/vm-1/vmstate",
+		"scp -3 -q -o BatchMode=yes deploy@10.0.0.1:/var/lib/firecracker/migrations/vm-1/memory deploy@10.0.0.2:/var/lib/firecracker/migrations/vm-1/memory",
+		"scp -3 -q -o BatchMode=yes deploy@10.0.0.1:/images/rootfs.ext4 deploy@10.0.0.2:/images/rootfs.ext4",
+		"ssh -o BatchMode=yes deploy@10.0.0.1 rm -rf /var/lib/firecracker/migrations/vm-1",
+	}
+	if got := strings.Join(*commands, "\n"); got != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected commands:\n%s", got)
+	}
+
+	snapshot, err := os.ReadFile(filepath.Join(stateDir, "fake", "edge-1", "snapshot_create.json"))
+	if err != nil || !strings.Contains(string(snapshot), `"snapshot_path":"/var/lib/firecracker/migrations/vm-1/vmstate"`) {
+		t.Errorf("Expected the VM to be snapshotted on edge-1, got %s, %v", snapshot, err)
+	}
+	state, err := provider.hosts[1].Client.GetInstanceState(ctx)
+	if err != nil || state != "Running" {
+		t.Errorf("Expected the VM to run on edge-2, got %q, %v", state, err)
+	}
+	actions, _ := os.ReadFile(filepath.Join(stateDir, "fake", "edge-1", "actions.log"))
+	if !strings.Contains(string(actions), "SendCtrlAltDel") {
+		t.Errorf("Expected the VM to be shut down on edge-1, got %s", actions)
+	}
+	if provider.hostLoads.placed["vm-1"] != "edge-2" {
+		t.Errorf("Expected the VM to count against edge-2, got %v", provider.hostLoads.placed)
+	}
+}
+
+func TestMigrateVM_failure(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	provider := configureMigrationProvider(t, stateDir, []interface{}{featureMigration})
+
+	original := runCommand
+	t.Cleanup(func() { runCommand = original })
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		if name == "scp" {
+			return []byte("Connection refused"), errors.New("exit status 1")
+		}
+		return nil, nil
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": "/images/vmlinux",
+		"host":              "edge-1",
+		"machine_config":    []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+	})
+	d.SetId("vm-1")
+
+	diags := migrateVM(ctx, d, provider, "vm-1", "edge-1", "edge-2")
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "Connection refused") {
+		t.Fatalf("Expected the copy to fail, got %v", diags)
+	}
+
+	vm, err := os.ReadFile(filepath.Join(stateDir, "fake", "edge-1", "vm.json"))
+	if err != nil || !strings.Contains(string(vm), `"Resumed"`) {
+		t.Errorf("Expected the VM to be resumed on edge-1, got %s, %v", vm, err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "fake", "edge-2", "snapshot_load.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected no VM to be restored on edge-2, got %v", err)
+	}
+	if provider.hostLoads.placed["vm-1"] != "edge-1" {
+		t.Errorf("Expected the VM to count against edge-1 again, got %v", provider.hostLoads.placed)
+	}
+}
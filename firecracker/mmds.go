@@ -0,0 +1,177 @@
+package firecracker
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// mmdsProviderKey is the top-level MMDS key under which the provider publishes
+// the settings it manages, keeping them apart from user-supplied metadata.
+const mmdsProviderKey = "firecracker"
+
+const (
+    mmdsVersionV1 = "V1"
+    mmdsVersionV2 = "V2"
+
+    // mmdsMaxTokenTTL is the longest session token lifetime Firecracker accepts, in seconds.
+    mmdsMaxTokenTTL = 21600
+)
+
+var (
+    // timezoneRegexp matches IANA time zone names such as "UTC" or "America/New_York".
+    timezoneRegexp = regexp.MustCompile(`^[A-Za-z0-9_+\-]+(/[A-Za-z0-9_+\-]+)*$`)
+
+    // localeRegexp matches POSIX locale names such as "C.UTF-8" or "en_US.UTF-8".
+    localeRegexp = regexp.MustCompile(`^([A-Za-z]{2,3}(_[A-Za-z]{2})?|C|POSIX)(\.[A-Za-z0-9\-]+)?(@[A-Za-z0-9]+)?$`)
+
+    // hostnameRegexp matches RFC 1123 hostnames such as "web-1" or "web-1.example.com".
+    hostnameRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9\-]{0,61}[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9\-]{0,61}[A-Za-z0-9])?)*$`)
+)
+
+// guestSettingsFromConfig collects the guest personalization settings that are
+// published to the guest through MMDS under mmdsProviderKey.
+func guestSettingsFromConfig(d *schema.ResourceData) map[string]interface{} {
+    settings := map[string]interface{}{}
+
+    if timezone, ok := d.GetOk("timezone"); ok {
+        settings["timezone"] = timezone.(string)
+    }
+    if locale, ok := d.GetOk("locale"); ok {
+        settings["locale"] = locale.(string)
+    }
+    if hostname, ok := d.GetOk("hostname"); ok {
+        settings["hostname"] = hostname.(string)
+    }
+    if keys := authorizedKeysFromConfig(d); len(keys) > 0 {
+        settings["ssh_authorized_keys"] = keys
+    }
+    if d.Get("publish_tags").(bool) {
+        if name := d.Get("name").(string); name != "" {
+            settings["name"] = name
+        }
+        if tags := d.Get("tags").(map[string]interface{}); len(tags) > 0 {
+            settings["tags"] = tags
+        }
+    }
+
+    // Explicit addressing takes precedence over what the CNI plugins assigned
+    network := map[string]interface{}{}
+    if guestIP, ok := d.GetOk("guest_ip"); ok {
+        network["address"] = guestIP.(string)
+    } else if guestIP, ok := d.GetOk("cni.0.guest_ip"); ok {
+        network["address"] = guestIP.(string)
+    }
+    if gateway, ok := d.GetOk("guest_gateway"); ok {
+        network["gateway"] = gateway.(string)
+    } else if gateway, ok := d.GetOk("cni.0.gateway"); ok {
+        network["gateway"] = gateway.(string)
+    }
+    if len(network) > 0 {
+        settings["network"] = network
+    }
+
+    // Tell the guest which MMDS version to speak and how long its session tokens should live
+    if cfg := mmdsBlock(d); cfg != nil && cfg["version"].(string) == mmdsVersionV2 {
+        ttl := cfg["token_ttl_seconds"].(int)
+        if ttl == 0 {
+            ttl = mmdsMaxTokenTTL
+        }
+        settings["mmds"] = map[string]interface{}{
+            "version":           mmdsVersionV2,
+            "token_ttl_seconds": ttl,
+        }
+    }
+
+    return settings
+}
+
+// mmdsContentsFromConfig builds the MMDS data store contents for a VM.
+// It returns nil when there is nothing to publish.
+func mmdsContentsFromConfig(d *schema.ResourceData) map[string]interface{} {
+    settings := guestSettingsFromConfig(d)
+    if len(settings) == 0 {
+        return nil
+    }
+
+    return map[string]interface{}{
+        mmdsProviderKey: settings,
+    }
+}
+
+// mmdsBlock returns the settings of the VM's mmds block, or nil if it has none.
+func mmdsBlock(d *schema.ResourceData) map[string]interface{} {
+    raw := d.Get("mmds").([]interface{})
+    if len(raw) == 0 || raw[0] == nil {
+        return nil
+    }
+    return raw[0].(map[string]interface{})
+}
+
+// mmdsConfigFromConfig builds the MMDS configuration enabling the data store on the given
+// network interfaces, with the version and address from the VM's mmds block. When the block
+// lists network_interfaces, the data store is only enabled on those, which must be among
+// ifaceIDs.
+func mmdsConfigFromConfig(d *schema.ResourceData, ifaceIDs []string) (map[string]interface{}, error) {
+    config := map[string]interface{}{
+        "network_interfaces": ifaceIDs,
+    }
+
+    if cfg := mmdsBlock(d); cfg != nil {
+        config["version"] = cfg["version"].(string)
+        if address := cfg["ipv4_address"].(string); address != "" {
+            config["ipv4_address"] = address
+        }
+        if raw := cfg["network_interfaces"].([]interface{}); len(raw) > 0 {
+            known := map[string]bool{}
+            for _, id := range ifaceIDs {
+                known[id] = true
+            }
+            bound := make([]string, 0, len(raw))
+            for _, id := range raw {
+                if !known[id.(string)] {
+                    return nil, fmt.Errorf("mmds network_interfaces names interface %s, which the VM does not have", id)
+                }
+                bound = append(bound, id.(string))
+            }
+            config["network_interfaces"] = bound
+        }
+    }
+
+    return config, nil
+}
+
+// checkMMDSInterfaces fails the plan when the mmds block binds MMDS to an interface the VM
+// does not have. Interfaces attached with firecracker_network_interface are only known once
+// the VM is created, so named VMs are checked then.
+func checkMMDSInterfaces(d *schema.ResourceDiff) error {
+    if !d.NewValueKnown("mmds") || !d.NewValueKnown("network_interfaces") || !d.NewValueKnown("cni") || !d.NewValueKnown("name") || d.Get("name").(string) != "" {
+        return nil
+    }
+    known := map[string]bool{}
+    for _, raw := range d.Get("network_interfaces").([]interface{}) {
+        if iface, ok := raw.(map[string]interface{}); ok {
+            known[iface["iface_id"].(string)] = true
+        }
+    }
+    if len(d.Get("cni").([]interface{})) > 0 {
+        known[d.Get("cni.0.iface_id").(string)] = true
+    }
+    for _, id := range d.Get("mmds.0.network_interfaces").([]interface{}) {
+        if !known[id.(string)] {
+            return fmt.Errorf("mmds network_interfaces names interface %s, which is in neither network_interfaces nor the cni block", id)
+        }
+    }
+    return nil
+}
+
+// authorizedKeysFromConfig returns the SSH public keys of the VM's ssh_authorized_keys attribute.
+func authorizedKeysFromConfig(d *schema.ResourceData) []string {
+    keys := []string{}
+    for _, key := range d.Get("ssh_authorized_keys").([]interface{}) {
+        keys = append(keys, strings.TrimSpace(key.(string)))
+    }
+    return keys
+}
@@ -0,0 +1,73 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "reflect"
+)
+
+// mmdsReservedKeys are the top-level MMDS keys the provider publishes for firecracker_vm,
+// which firecracker_mmds_contents must not overwrite. latest holds cloud-init's EC2 layout.
+var mmdsReservedKeys = []string{mmdsProviderKey, "latest"}
+
+// mmdsMergePatch returns the JSON merge patch (RFC 7396) turning current into desired.
+// Firecracker applies PATCH /mmds as a merge patch, which merges objects rather than
+// replacing them, so keys that are no longer desired must be removed with null.
+func mmdsMergePatch(current, desired interface{}) interface{} {
+    currentMap, ok := current.(map[string]interface{})
+    desiredMap, ok2 := desired.(map[string]interface{})
+    if !ok || !ok2 {
+        return desired
+    }
+
+    patch := map[string]interface{}{}
+    for key := range currentMap {
+        if _, ok := desiredMap[key]; !ok {
+            patch[key] = nil
+        }
+    }
+    for key, value := range desiredMap {
+        if !reflect.DeepEqual(currentMap[key], value) {
+            patch[key] = mmdsMergePatch(currentMap[key], value)
+        }
+    }
+    return patch
+}
+
+// GetMMDSKey returns the value of a top-level key of the VM's MMDS data store, and whether
+// the key is set.
+func (c *FirecrackerClient) GetMMDSKey(ctx context.Context, key string) (interface{}, bool, error) {
+    contents, err := c.getComponent(ctx, fmt.Sprintf("%s/mmds", c.BaseURL))
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to read MMDS: %w", err)
+    }
+    value, ok := contents[key]
+    return value, ok && value != nil, nil
+}
+
+// SetMMDSKey sets a top-level key of the running VM's MMDS data store to value, leaving the
+// other keys as they are.
+func (c *FirecrackerClient) SetMMDSKey(ctx context.Context, key string, value interface{}) error {
+    current, _, err := c.GetMMDSKey(ctx, key)
+    if err != nil {
+        return err
+    }
+    err = c.patchComponent(ctx, fmt.Sprintf("%s/mmds", c.BaseURL), map[string]interface{}{
+        key: mmdsMergePatch(current, value),
+    })
+    if err != nil {
+        return fmt.Errorf("failed to update MMDS key %s: %w", key, err)
+    }
+    return nil
+}
+
+// DeleteMMDSKey removes a top-level key from the running VM's MMDS data store.
+func (c *FirecrackerClient) DeleteMMDSKey(ctx context.Context, key string) error {
+    err := c.patchComponent(ctx, fmt.Sprintf("%s/mmds", c.BaseURL), map[string]interface{}{
+        key: nil,
+    })
+    if err != nil {
+        return fmt.Errorf("failed to remove MMDS key %s: %w", key, err)
+    }
+    return nil
+}
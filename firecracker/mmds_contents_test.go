@@ -0,0 +1,131 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestMMDSMergePatch(t *testing.T) {
+	cases := map[string]struct {
+		current, desired string
+		want             string
+	}{
+		"new key":         {`null`, `{"a":1}`, `{"a":1}`},
+		"nested removal":  {`{"flags":{"a":true,"b":true}}`, `{"flags":{"a":false}}`, `{"flags":{"a":false,"b":null}}`},
+		"unchanged keys":  {`{"a":1,"b":2}`, `{"a":1,"b":3}`, `{"b":3}`},
+		"scalar replaced": {`{"a":1}`, `"text"`, `"text"`},
+		"list replaced":   {`{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var current, desired, want interface{}
+			json.Unmarshal([]byte(tc.current), &current)
+			json.Unmarshal([]byte(tc.desired), &desired)
+			json.Unmarshal([]byte(tc.want), &want)
+			if got := mmdsMergePatch(current, desired); !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected patch %s, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// readFakeMMDS returns the MMDS data store of the fake API.
+func readFakeMMDS(t *testing.T, stateDir string) map[string]interface{} {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(stateDir, "fake", "test", "mmds.json"))
+	if err != nil {
+		t.Fatalf("Failed to read MMDS: %v", err)
+	}
+	contents := map[string]interface{}{}
+	if err := json.Unmarshal(data, &contents); err != nil {
+		t.Fatalf("Failed to parse MMDS: %v", err)
+	}
+	return contents
+}
+
+func TestResourceFirecrackerMMDSContents(t *testing.T) {
+	stubSecretCommands(t, map[string]string{"app/token": "s3cr3t"})
+	ctx := context.Background()
+	stateDir := t.TempDir()
+
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vm := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": image,
+		"hostname":          "web-1",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+		"network_interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, vm, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	provider := configureFakeProvider(t, stateDir)
+	provider.secrets = secretSources{"pass": {Name: "pass", Type: secretSourceExec, Command: []string{"pass", "show"}}}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerMMDSContents().Schema, map[string]interface{}{
+		"vm_id": vm.Id(),
+		"key":   "app",
+		"data":  `{"flags":{"beta":true,"dark_mode":true}}`,
+	})
+	if diags := resourceFirecrackerMMDSContentsCreate(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to publish MMDS contents: %v", diags)
+	}
+	if d.Id() != vm.Id()+"/app" {
+		t.Errorf("Expected ID %s/app, got %s", vm.Id(), d.Id())
+	}
+	contents := readFakeMMDS(t, stateDir)
+	if contents[mmdsProviderKey] == nil {
+		t.Errorf("Expected the VM's own settings to be kept, got %v", contents)
+	}
+
+	// Flags removed from the contents are removed from the data store
+	d.Set("data", `{"flags":{"beta":false},"token":"secret://pass/app/token"}`)
+	if diags := resourceFirecrackerMMDSContentsUpdate(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to update MMDS contents: %v", diags)
+	}
+	want := map[string]interface{}{"flags": map[string]interface{}{"beta": false}, "token": "s3cr3t"}
+	if got := readFakeMMDS(t, stateDir)["app"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected contents %v, got %v", want, got)
+	}
+	if got := d.Get("data").(string); !strings.Contains(got, "secret://pass/app/token") {
+		t.Errorf("Expected the state to keep the secret reference, got %s", got)
+	}
+
+	if diags := resourceFirecrackerMMDSContentsDelete(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to remove MMDS contents: %v", diags)
+	}
+	contents = readFakeMMDS(t, stateDir)
+	if _, ok := contents["app"]; ok || contents[mmdsProviderKey] == nil {
+		t.Errorf("Expected only the key to be removed, got %v", contents)
+	}
+
+	// Contents that are gone are published again
+	d.SetId(vm.Id() + "/app")
+	if diags := resourceFirecrackerMMDSContentsRead(ctx, d, provider); diags.HasError() || d.Id() != "" {
+		t.Errorf("Expected the removed contents to be removed from state, got ID %q and %v", d.Id(), diags)
+	}
+}
+
+func TestResourceFirecrackerMMDSContents_reservedKey(t *testing.T) {
+	for _, key := range mmdsReservedKeys {
+		_, errs := resourceFirecrackerMMDSContents().Schema["key"].ValidateFunc(key, "key")
+		if len(errs) == 0 {
+			t.Errorf("Expected key %q to be rejected", key)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package firecracker
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestMMDSConfigFromConfig_v2(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"mmds": []interface{}{
+			map[string]interface{}{
+				"version":           "V2",
+				"ipv4_address":      "169.254.170.2",
+				"token_ttl_seconds": 300,
+			},
+		},
+	})
+
+	config, err := mmdsConfigFromConfig(d, []string{"eth0"})
+	if err != nil || config["version"] != "V2" || config["ipv4_address"] != "169.254.170.2" {
+		t.Errorf("Unexpected MMDS config %v", config)
+	}
+
+	settings := guestSettingsFromConfig(d)
+	mmds, ok := settings["mmds"].(map[string]interface{})
+	if !ok || mmds["token_ttl_seconds"] != 300 {
+		t.Errorf("Expected the token TTL to be published, got %v", settings)
+	}
+}
+
+func TestMMDSConfigFromConfig_default(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{})
+
+	config, _ := mmdsConfigFromConfig(d, []string{"eth0"})
+	if _, ok := config["version"]; ok {
+		t.Errorf("Expected no version without an mmds block, got %v", config)
+	}
+	if _, ok := guestSettingsFromConfig(d)["mmds"]; ok {
+		t.Errorf("Expected no MMDS settings without an mmds block")
+	}
+}
+
+func TestMMDSConfigFromConfig_interfaces(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"mmds": []interface{}{
+			map[string]interface{}{"version": "V2", "network_interfaces": []interface{}{"eth1"}},
+		},
+	})
+
+	config, err := mmdsConfigFromConfig(d, []string{"eth0", "eth1"})
+	if err != nil || !reflect.DeepEqual(config["network_interfaces"], []string{"eth1"}) {
+		t.Errorf("Expected MMDS to be bound to eth1 only, got %v, %v", config, err)
+	}
+	if _, err := mmdsConfigFromConfig(d, []string{"eth0"}); err == nil || !strings.Contains(err.Error(), "eth1, which the VM does not have") {
+		t.Errorf("Expected an error for an interface the VM does not have, got %v", err)
+	}
+}
+
+func TestCheckMMDSInterfaces(t *testing.T) {
+	config := func(mmdsIfaces ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"kernel_image_path": "/images/vmlinux",
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true},
+			},
+			"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+			"network_interfaces": []interface{}{
+				map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+				map[string]interface{}{"iface_id": "eth1", "host_dev_name": "tap1"},
+			},
+			"mmds": []interface{}{map[string]interface{}{"version": "V2", "network_interfaces": mmdsIfaces}},
+		}
+	}
+
+	if _, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config("eth1")), nil); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	_, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config("eth2")), nil)
+	if err == nil || !strings.Contains(err.Error(), "names interface eth2") {
+		t.Errorf("Expected an error for an unknown interface, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// bridgeAttachmentDrift describes how a TAP device differs from being attached to bridge.
+// It returns an empty string when the device is attached as expected.
+func bridgeAttachmentDrift(tap string, info *hostLinkInfo, bridge string) string {
+    switch {
+    case info == nil:
+        return fmt.Sprintf("TAP device %s does not exist", tap)
+    case info.Master == "":
+        return fmt.Sprintf("TAP device %s is not attached to bridge %s", tap, bridge)
+    case info.Master != bridge:
+        return fmt.Sprintf("TAP device %s is attached to %s instead of bridge %s", tap, info.Master, bridge)
+    default:
+        return ""
+    }
+}
+
+// checkBridgeAttachments verifies that the TAP devices of a VM's network interfaces, in the
+// network namespace netns, are still attached to their bridges, which is lost when host
+// networking is restarted. With heal set, detached devices are attached again; otherwise the
+// drift is reported as a warning.
+func checkBridgeAttachments(ctx context.Context, vmID, netns string, ifaces []interface{}, heal bool) diag.Diagnostics {
+    var diags diag.Diagnostics
+
+    for _, raw := range ifaces {
+        iface, ok := raw.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        tap, _ := iface["host_dev_name"].(string)
+        bridge, _ := iface["bridge"].(string)
+        if tap == "" || bridge == "" {
+            continue
+        }
+
+        var info *hostLinkInfo
+        err := withNetNS(netns, func() error {
+            var err error
+            info, err = getLinkInfo(tap)
+            return err
+        })
+        if err != nil {
+            return append(diags, diag.FromErr(fmt.Errorf("error checking bridge attachment of %s: %w", tap, err))...)
+        }
+
+        drift := bridgeAttachmentDrift(tap, info, bridge)
+        if drift == "" {
+            continue
+        }
+
+        // A missing device cannot be healed by attaching it again
+        if heal && info != nil {
+            tflog.Info(ctx, "Reattaching TAP device to its bridge", map[string]interface{}{
+                "id":     vmID,
+                "tap":    tap,
+                "bridge": bridge,
+            })
+            if err := withNetNS(netns, func() error { return setLinkMaster(tap, bridge) }); err != nil {
+                return append(diags, diag.FromErr(fmt.Errorf("error reattaching %s to %s: %w", tap, bridge, err))...)
+            }
+            continue
+        }
+
+        detail := fmt.Sprintf("VM %s: %s.", vmID, drift)
+        if info != nil {
+            detail += " Set heal_networking = true to reattach it automatically."
+        }
+        diags = append(diags, diag.Diagnostic{
+            Severity: diag.Warning,
+            Summary:  "VM network interface detached from its bridge",
+            Detail:   detail,
+        })
+    }
+
+    return diags
+}
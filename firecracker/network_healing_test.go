@@ -0,0 +1,24 @@
+package firecracker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBridgeAttachmentDrift(t *testing.T) {
+	if drift := bridgeAttachmentDrift("tap0", &hostLinkInfo{Name: "tap0", Master: "fcbr0"}, "fcbr0"); drift != "" {
+		t.Errorf("Expected no drift, got %q", drift)
+	}
+
+	cases := map[string]*hostLinkInfo{
+		"does not exist":          nil,
+		"is not attached":         {Name: "tap0"},
+		"attached to br1 instead": {Name: "tap0", Master: "br1"},
+	}
+	for expected, info := range cases {
+		drift := bridgeAttachmentDrift("tap0", info, "fcbr0")
+		if !strings.Contains(drift, expected) {
+			t.Errorf("Expected drift to contain %q, got %q", expected, drift)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package firecracker
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// interfaceAttachmentMu serializes access to the network interface attachments within the
+// provider process.
+var interfaceAttachmentMu sync.Mutex
+
+// interfaceAttachment is the record of a firecracker_network_interface: a network interface
+// the VM with the given name is created with, besides those of its network_interfaces.
+// Firecracker only adds network interfaces before the guest boots, so the VM picks up its
+// attachments when it is created rather than the attachments being added to it.
+type interfaceAttachment struct {
+    VM          string `json:"vm"`
+    IfaceID     string `json:"iface_id"`
+    HostDevName string `json:"host_dev_name"`
+    GuestMAC    string `json:"guest_mac,omitempty"`
+    Bridge      string `json:"bridge,omitempty"`
+}
+
+// id returns the ID of the firecracker_network_interface recording the attachment.
+func (a interfaceAttachment) id() string {
+    return a.VM + "/" + a.IfaceID
+}
+
+// block returns the attachment in the form of a network_interfaces block.
+func (a interfaceAttachment) block() map[string]interface{} {
+    return map[string]interface{}{
+        "iface_id":      a.IfaceID,
+        "host_dev_name": a.HostDevName,
+        "guest_mac":     a.GuestMAC,
+        "bridge":        a.Bridge,
+    }
+}
+
+// interfaceAttachmentDir returns the directory of the attachments of a VM.
+func interfaceAttachmentDir(stateDir, vm string) string {
+    return filepath.Join(stateDir, "network_interfaces", vm)
+}
+
+// loadInterfaceAttachment returns an attachment, or nil if it does not exist.
+func loadInterfaceAttachment(stateDir, vm, ifaceID string) (*interfaceAttachment, error) {
+    interfaceAttachmentMu.Lock()
+    defer interfaceAttachmentMu.Unlock()
+
+    data, err := os.ReadFile(filepath.Join(interfaceAttachmentDir(stateDir, vm), ifaceID+".json"))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read network interface %s/%s: %w", vm, ifaceID, err)
+    }
+
+    var record interfaceAttachment
+    if err := json.Unmarshal(data, &record); err != nil {
+        return nil, fmt.Errorf("failed to parse network interface %s/%s: %w", vm, ifaceID, err)
+    }
+    return &record, nil
+}
+
+// createInterfaceAttachment records an attachment atomically. It fails if the VM already
+// has an attachment with the same interface ID.
+func createInterfaceAttachment(stateDir string, record interfaceAttachment) error {
+    interfaceAttachmentMu.Lock()
+    defer interfaceAttachmentMu.Unlock()
+
+    dir := interfaceAttachmentDir(stateDir, record.VM)
+    path := filepath.Join(dir, record.IfaceID+".json")
+    if _, err := os.Stat(path); err == nil {
+        return fmt.Errorf("network interface %s is already attached to VM %s", record.IfaceID, record.VM)
+    }
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return fmt.Errorf("failed to create network interface directory: %w", err)
+    }
+
+    data, err := json.MarshalIndent(record, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode network interface %s: %w", record.id(), err)
+    }
+
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0o644); err != nil {
+        return fmt.Errorf("failed to write network interface %s: %w", record.id(), err)
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        return fmt.Errorf("failed to write network interface %s: %w", record.id(), err)
+    }
+    return nil
+}
+
+// deleteInterfaceAttachment removes an attachment. Removing one that does not exist is
+// not an error.
+func deleteInterfaceAttachment(stateDir, vm, ifaceID string) error {
+    interfaceAttachmentMu.Lock()
+    defer interfaceAttachmentMu.Unlock()
+
+    err := os.Remove(filepath.Join(interfaceAttachmentDir(stateDir, vm), ifaceID+".json"))
+    if err != nil && !errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("failed to remove network interface %s/%s: %w", vm, ifaceID, err)
+    }
+    return nil
+}
+
+// listInterfaceAttachments returns the attachments of the VM with the given name, ordered by
+// interface ID. VMs whose name cannot be a directory name have no attachments.
+func listInterfaceAttachments(stateDir, vm string) ([]interfaceAttachment, error) {
+    if !networkNameRegexp.MatchString(vm) {
+        return nil, nil
+    }
+
+    interfaceAttachmentMu.Lock()
+    defer interfaceAttachmentMu.Unlock()
+
+    dir := interfaceAttachmentDir(stateDir, vm)
+    entries, err := os.ReadDir(dir)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to list network interfaces of VM %s: %w", vm, err)
+    }
+
+    var records []interfaceAttachment
+    for _, entry := range entries {
+        if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+            continue
+        }
+        data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+        if err != nil {
+            return nil, fmt.Errorf("failed to read network interface %s: %w", entry.Name(), err)
+        }
+        var record interfaceAttachment
+        if err := json.Unmarshal(data, &record); err != nil {
+            return nil, fmt.Errorf("failed to parse network interface %s: %w", entry.Name(), err)
+        }
+        records = append(records, record)
+    }
+    sort.Slice(records, func(i, j int) bool { return records[i].IfaceID < records[j].IfaceID })
+    return records, nil
+}
+
+// attachInterfaces records the firecracker_network_interface attachments of a VM being
+// created in its attached_network_interfaces, so they are part of its configuration from
+// then on. Attachments need the VM to have a name.
+func attachInterfaces(d *schema.ResourceData, stateDir string) error {
+    name := d.Get("name").(string)
+    if name == "" {
+        return nil
+    }
+    records, err := listInterfaceAttachments(stateDir, name)
+    if err != nil {
+        return err
+    }
+
+    ifaceIDs := map[string]bool{}
+    for _, raw := range d.Get("network_interfaces").([]interface{}) {
+        if iface, ok := raw.(map[string]interface{}); ok {
+            ifaceIDs[iface["iface_id"].(string)] = true
+        }
+    }
+    attached := make([]interface{}, 0, len(records))
+    for _, record := range records {
+        if ifaceIDs[record.IfaceID] {
+            return fmt.Errorf("network interface %s of firecracker_network_interface %s is already in network_interfaces", record.IfaceID, record.id())
+        }
+        attached = append(attached, record.block())
+    }
+    if err := d.Set("attached_network_interfaces", attached); err != nil {
+        return fmt.Errorf("failed to set attached network interfaces: %w", err)
+    }
+    return nil
+}
+
+// vmNetworkInterfaces returns the network interfaces of a VM: those of its
+// network_interfaces followed by those attached with firecracker_network_interface.
+func vmNetworkInterfaces(d *schema.ResourceData) []interface{} {
+    ifaces := append([]interface{}{}, d.Get("network_interfaces").([]interface{})...)
+    return append(ifaces, d.Get("attached_network_interfaces").([]interface{})...)
+}
@@ -0,0 +1,140 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestInterfaceAttachments(t *testing.T) {
+	stateDir := t.TempDir()
+
+	for _, record := range []interfaceAttachment{
+		{VM: "web", IfaceID: "eth2", HostDevName: "tap2"},
+		{VM: "web", IfaceID: "eth1", HostDevName: "tap1", GuestMAC: "02:00:00:00:00:01", Bridge: "br0"},
+		{VM: "db", IfaceID: "eth1", HostDevName: "tap3"},
+	} {
+		if err := createInterfaceAttachment(stateDir, record); err != nil {
+			t.Fatalf("Expected no error attaching %s, got %v", record.id(), err)
+		}
+	}
+	if err := createInterfaceAttachment(stateDir, interfaceAttachment{VM: "web", IfaceID: "eth1", HostDevName: "tap9"}); err == nil {
+		t.Error("Expected an error attaching an interface ID twice")
+	}
+
+	records, err := listInterfaceAttachments(stateDir, "web")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(records) != 2 || records[0].IfaceID != "eth1" || records[1].IfaceID != "eth2" || records[0].Bridge != "br0" {
+		t.Errorf("Expected the attachments of web ordered by interface ID, got %+v", records)
+	}
+
+	if err := deleteInterfaceAttachment(stateDir, "web", "eth1"); err != nil {
+		t.Fatalf("Expected no error detaching, got %v", err)
+	}
+	if err := deleteInterfaceAttachment(stateDir, "web", "eth1"); err != nil {
+		t.Errorf("Expected detaching twice to succeed, got %v", err)
+	}
+	record, err := loadInterfaceAttachment(stateDir, "web", "eth1")
+	if err != nil || record != nil {
+		t.Errorf("Expected the attachment to be gone, got %+v, %v", record, err)
+	}
+
+	// Names that cannot be directory names have no attachments
+	if records, err := listInterfaceAttachments(stateDir, "../web"); err != nil || records != nil {
+		t.Errorf("Expected no attachments for an invalid name, got %+v, %v", records, err)
+	}
+}
+
+func TestAttachInterfaces_conflict(t *testing.T) {
+	stateDir := t.TempDir()
+	if err := createInterfaceAttachment(stateDir, interfaceAttachment{VM: "web", IfaceID: "eth0", HostDevName: "tap1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"name":              "web",
+		"kernel_image_path": "/boot/vmlinux",
+		"network_interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+		},
+	})
+	err := attachInterfaces(d, stateDir)
+	if err == nil || !strings.Contains(err.Error(), "already in network_interfaces") {
+		t.Errorf("Expected a conflict with network_interfaces, got %v", err)
+	}
+}
+
+func TestResourceFirecrackerNetworkInterface(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+
+	nic := schema.TestResourceDataRaw(t, resourceFirecrackerNetworkInterface().Schema, map[string]interface{}{
+		"vm":            "web",
+		"iface_id":      "eth1",
+		"host_dev_name": "tap1",
+		"guest_mac":     "02:00:00:00:00:01",
+	})
+	if diags := resourceFirecrackerNetworkInterfaceCreate(ctx, nic, configureFakeProvider(t, stateDir)); diags.HasError() || len(diags) > 0 {
+		t.Fatalf("Expected the interface to be attached without diagnostics, got %v", diags)
+	}
+	if nic.Id() != "web/eth1" {
+		t.Errorf("Expected ID web/eth1, got %s", nic.Id())
+	}
+
+	// The VM picks up the interface when it is created, after its own interfaces
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"name":              "web",
+		"kernel_image_path": image,
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+		"network_interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "fake", "test", "network-interfaces_eth1.json")); err != nil {
+		t.Errorf("Expected the attached interface to be configured before boot, got %v", err)
+	}
+	if got := d.Get("attached_network_interfaces.0.host_dev_name"); got != "tap1" {
+		t.Errorf("Expected the attached interface in attached_network_interfaces, got %v", got)
+	}
+	if diags := resourceFirecrackerVMRead(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to read VM: %v", diags)
+	}
+	if ifaces := d.Get("network_interfaces").([]interface{}); len(ifaces) != 1 {
+		t.Errorf("Expected the attached interface to stay out of network_interfaces, got %v", ifaces)
+	}
+
+	// Interfaces attached to a running VM only take effect when it is created again
+	late := schema.TestResourceDataRaw(t, resourceFirecrackerNetworkInterface().Schema, map[string]interface{}{
+		"vm":            "web",
+		"iface_id":      "eth2",
+		"host_dev_name": "tap2",
+	})
+	diags := resourceFirecrackerNetworkInterfaceCreate(ctx, late, configureFakeProvider(t, stateDir))
+	if diags.HasError() || len(diags) != 1 || diags[0].Summary != "VM already running" {
+		t.Errorf("Expected a warning that the VM is already running, got %v", diags)
+	}
+
+	diags = resourceFirecrackerNetworkInterfaceDelete(ctx, nic, configureFakeProvider(t, stateDir))
+	if diags.HasError() || len(diags) != 1 || diags[0].Summary != "VM still uses the network interface" {
+		t.Errorf("Expected a warning that the VM keeps the interface, got %v", diags)
+	}
+	nic.SetId("web/eth1")
+	if diags := resourceFirecrackerNetworkInterfaceRead(ctx, nic, configureFakeProvider(t, stateDir)); diags.HasError() || nic.Id() != "" {
+		t.Errorf("Expected the detached interface to be removed from state, got ID %q and %v", nic.Id(), diags)
+	}
+}
@@ -0,0 +1,192 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+const (
+    overlayMethodAuto       = "auto"
+    overlayMethodReflink    = "reflink"
+    overlayMethodDMSnapshot = "dm-snapshot"
+
+    // overlayChunkSectors is the dm-snapshot chunk size in 512-byte sectors (4 KiB).
+    overlayChunkSectors = 8
+)
+
+// dmDepsRegexp matches the device names listed by `dmsetup deps -o devname`.
+var dmDepsRegexp = regexp.MustCompile(`\(([^)]+)\)`)
+
+// overlaySpec describes a per-VM copy-on-write overlay of a shared base image.
+type overlaySpec struct {
+    Name            string
+    BaseImage       string
+    Path            string
+    Method          string
+    SnapshotSizeMiB int
+}
+
+// dmName returns the device-mapper name of the overlay's snapshot device.
+func (s overlaySpec) dmName() string {
+    return "firecracker-overlay-" + s.Name
+}
+
+// createOverlay creates the overlay with the requested method and returns the method used
+// and the path VMs attach as their drive. With the auto method, a reflink copy is tried
+// first since it needs no privileges, falling back to a dm-snapshot where the filesystem
+// cannot share extents.
+func createOverlay(ctx context.Context, spec overlaySpec) (string, string, error) {
+    if err := os.MkdirAll(filepath.Dir(spec.Path), 0o755); err != nil {
+        return "", "", fmt.Errorf("failed to create overlay directory: %w", err)
+    }
+    if err := trackArtifact(ctx, hostArtifact{Path: spec.Path}); err != nil {
+        return "", "", err
+    }
+
+    if spec.Method == overlayMethodReflink || spec.Method == overlayMethodAuto {
+        err := createReflinkOverlay(ctx, spec)
+        if err == nil {
+            return overlayMethodReflink, spec.Path, nil
+        }
+        if spec.Method == overlayMethodReflink {
+            return "", "", err
+        }
+    }
+
+    devicePath, err := createSnapshotOverlay(ctx, spec)
+    if err != nil {
+        return "", "", err
+    }
+    return overlayMethodDMSnapshot, devicePath, nil
+}
+
+// createReflinkOverlay copies the base image sharing its extents, so the copy takes no
+// space until the guest writes to it. It only works on filesystems such as XFS and Btrfs.
+func createReflinkOverlay(ctx context.Context, spec overlaySpec) error {
+    if output, err := runCommand(ctx, "cp", "--reflink=always", spec.BaseImage, spec.Path); err != nil {
+        os.Remove(spec.Path)
+        return fmt.Errorf("failed to create reflink copy of %s: %w: %s", spec.BaseImage, err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// createSnapshotOverlay creates a sparse file holding the guest's writes and a persistent
+// dm-snapshot device combining it with the read-only base image.
+func createSnapshotOverlay(ctx context.Context, spec overlaySpec) (string, error) {
+    f, err := os.OpenFile(spec.Path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+    if err != nil {
+        return "", fmt.Errorf("failed to create overlay file: %w", err)
+    }
+    err = f.Truncate(int64(spec.SnapshotSizeMiB) * 1024 * 1024)
+    if closeErr := f.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        os.Remove(spec.Path)
+        return "", fmt.Errorf("failed to size overlay file: %w", err)
+    }
+
+    devicePath, err := assembleSnapshotOverlay(ctx, spec)
+    if err != nil {
+        os.Remove(spec.Path)
+        return "", err
+    }
+    return devicePath, nil
+}
+
+// assembleSnapshotOverlay attaches the base image and the overlay file to loop devices and
+// creates the snapshot device from them. The snapshot is persistent, so an existing overlay
+// file is assembled again with the writes it already holds, such as after a host reboot.
+func assembleSnapshotOverlay(ctx context.Context, spec overlaySpec) (string, error) {
+    baseLoop, err := attachLoopDevice(ctx, spec.BaseImage, true)
+    if err != nil {
+        return "", err
+    }
+    cowLoop, err := attachLoopDevice(ctx, spec.Path, false)
+    if err != nil {
+        detachLoopDevice(ctx, baseLoop)
+        return "", err
+    }
+
+    output, err := runCommand(ctx, "blockdev", "--getsz", baseLoop)
+    if err == nil {
+        err = trackArtifact(ctx, hostArtifact{Overlay: spec.Name})
+    }
+    if err == nil {
+        table := fmt.Sprintf("0 %s snapshot %s %s P %d", strings.TrimSpace(string(output)), baseLoop, cowLoop, overlayChunkSectors)
+        output, err = runCommand(ctx, "dmsetup", "create", spec.dmName(), "--table", table)
+    }
+    if err != nil {
+        detachLoopDevice(ctx, cowLoop)
+        detachLoopDevice(ctx, baseLoop)
+        return "", fmt.Errorf("failed to create snapshot device %s: %w: %s", spec.dmName(), err, strings.TrimSpace(string(output)))
+    }
+
+    return filepath.Join("/dev/mapper", spec.dmName()), nil
+}
+
+// removeOverlay tears down the overlay's snapshot device, if any, and deletes its file.
+func removeOverlay(ctx context.Context, spec overlaySpec, method string) error {
+    if method == overlayMethodDMSnapshot {
+        if err := disassembleSnapshotOverlay(ctx, spec); err != nil {
+            return err
+        }
+    }
+    if err := os.Remove(spec.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("failed to delete overlay file: %w", err)
+    }
+    return nil
+}
+
+// disassembleSnapshotOverlay removes the snapshot device and detaches its loop devices.
+// A device that is already gone is not an error.
+func disassembleSnapshotOverlay(ctx context.Context, spec overlaySpec) error {
+    if _, err := os.Stat(filepath.Join("/dev/mapper", spec.dmName())); errors.Is(err, os.ErrNotExist) {
+        return nil
+    }
+
+    output, err := runCommand(ctx, "dmsetup", "deps", "-o", "devname", spec.dmName())
+    if err != nil {
+        return fmt.Errorf("failed to list devices of %s: %w: %s", spec.dmName(), err, strings.TrimSpace(string(output)))
+    }
+    loops := []string{}
+    for _, match := range dmDepsRegexp.FindAllStringSubmatch(string(output), -1) {
+        loops = append(loops, filepath.Join("/dev", match[1]))
+    }
+
+    if output, err := runCommand(ctx, "dmsetup", "remove", spec.dmName()); err != nil {
+        return fmt.Errorf("failed to remove snapshot device %s: %w: %s", spec.dmName(), err, strings.TrimSpace(string(output)))
+    }
+    for _, loop := range loops {
+        if err := detachLoopDevice(ctx, loop); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// attachLoopDevice attaches a file to a free loop device and returns the device path.
+func attachLoopDevice(ctx context.Context, file string, readOnly bool) (string, error) {
+    args := []string{"--find", "--show"}
+    if readOnly {
+        args = append(args, "--read-only")
+    }
+    output, err := runCommand(ctx, "losetup", append(args, file)...)
+    if err != nil {
+        return "", fmt.Errorf("failed to attach %s to a loop device: %w: %s", file, err, strings.TrimSpace(string(output)))
+    }
+    return strings.TrimSpace(string(output)), nil
+}
+
+// detachLoopDevice detaches a loop device.
+func detachLoopDevice(ctx context.Context, device string) error {
+    if output, err := runCommand(ctx, "losetup", "-d", device); err != nil {
+        return fmt.Errorf("failed to detach %s: %w: %s", device, err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
@@ -0,0 +1,124 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// stubOverlayCommands replaces runCommand with a fake where reflink copies succeed only
+// when reflink is true, and records every command run.
+func stubOverlayCommands(t *testing.T, reflink bool) *[]string {
+	t.Helper()
+
+	commands := []string{}
+	loops := 0
+	original := runCommand
+	t.Cleanup(func() { runCommand = original })
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		switch name {
+		case "cp":
+			if !reflink {
+				return []byte("cp: failed to clone: Operation not supported"), errors.New("exit status 1")
+			}
+			return nil, os.WriteFile(args[len(args)-1], []byte("rootfs"), 0o644)
+		case "losetup":
+			if args[0] == "--find" {
+				loops++
+				return []byte("/dev/loop" + strconv.Itoa(loops-1) + "\n"), nil
+			}
+		case "blockdev":
+			return []byte("2097152\n"), nil
+		}
+		return nil, nil
+	}
+	return &commands
+}
+
+func TestCreateOverlay_reflink(t *testing.T) {
+	commands := stubOverlayCommands(t, true)
+
+	dir := t.TempDir()
+	spec := overlaySpec{Name: "web-1", BaseImage: "/images/golden.ext4", Path: filepath.Join(dir, "overlays", "web-1.img"), Method: overlayMethodAuto, SnapshotSizeMiB: 512}
+	backend, drivePath, err := createOverlay(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if backend != overlayMethodReflink || drivePath != spec.Path {
+		t.Errorf("Expected a reflink overlay at %s, got %s at %s", spec.Path, backend, drivePath)
+	}
+	if len(*commands) != 1 || (*commands)[0] != "cp --reflink=always /images/golden.ext4 "+spec.Path {
+		t.Errorf("Unexpected commands %v", *commands)
+	}
+}
+
+func TestCreateOverlay_fallsBackToSnapshot(t *testing.T) {
+	commands := stubOverlayCommands(t, false)
+
+	spec := overlaySpec{Name: "web-1", BaseImage: "/images/golden.ext4", Path: filepath.Join(t.TempDir(), "web-1.img"), Method: overlayMethodAuto, SnapshotSizeMiB: 512}
+	backend, drivePath, err := createOverlay(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if backend != overlayMethodDMSnapshot || drivePath != "/dev/mapper/firecracker-overlay-web-1" {
+		t.Errorf("Expected a snapshot device, got %s at %s", backend, drivePath)
+	}
+
+	expected := []string{
+		"cp --reflink=always /images/golden.ext4 " + spec.Path,
+		"losetup --find --show --read-only /images/golden.ext4",
+		"losetup --find --show " + spec.Path,
+		"blockdev --getsz /dev/loop0",
+		"dmsetup create firecracker-overlay-web-1 --table 0 2097152 snapshot /dev/loop0 /dev/loop1 P 8",
+	}
+	if strings.Join(*commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected commands:\n%s\nexpected:\n%s", strings.Join(*commands, "\n"), strings.Join(expected, "\n"))
+	}
+
+	// The overlay file is sparse and sized for the guest's writes
+	info, err := os.Stat(spec.Path)
+	if err != nil || info.Size() != 512*1024*1024 {
+		t.Errorf("Expected a 512 MiB overlay file, got %v, %v", info, err)
+	}
+}
+
+func TestCreateOverlay_reflinkRequired(t *testing.T) {
+	commands := stubOverlayCommands(t, false)
+
+	spec := overlaySpec{Name: "web-1", BaseImage: "/images/golden.ext4", Path: filepath.Join(t.TempDir(), "web-1.img"), Method: overlayMethodReflink}
+	if _, _, err := createOverlay(context.Background(), spec); err == nil || !strings.Contains(err.Error(), "Operation not supported") {
+		t.Fatalf("Expected the reflink error, got %v", err)
+	}
+	if len(*commands) != 1 {
+		t.Errorf("Expected no fallback, got commands %v", *commands)
+	}
+}
+
+func TestCreateOverlay_snapshotFailureCleansUp(t *testing.T) {
+	commands := stubOverlayCommands(t, false)
+	stubbed := runCommand
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		if name == "dmsetup" {
+			*commands = append(*commands, "dmsetup")
+			return []byte("device-mapper: reload ioctl failed"), errors.New("exit status 1")
+		}
+		return stubbed(ctx, name, args...)
+	}
+
+	spec := overlaySpec{Name: "web-1", BaseImage: "/images/golden.ext4", Path: filepath.Join(t.TempDir(), "web-1.img"), Method: overlayMethodDMSnapshot, SnapshotSizeMiB: 64}
+	if _, _, err := createOverlay(context.Background(), spec); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	if got := strings.Join((*commands)[len(*commands)-2:], " "); got != "losetup -d /dev/loop1 losetup -d /dev/loop0" {
+		t.Errorf("Expected both loop devices to be detached, got %v", *commands)
+	}
+	if _, err := os.Stat(spec.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the overlay file to be removed, got %v", err)
+	}
+}
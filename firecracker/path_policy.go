@@ -0,0 +1,139 @@
+package firecracker
+
+import (
+    "fmt"
+    "path/filepath"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// allowedPathsFromConfig returns the provider's allowed_paths, checking that each is an
+// absolute glob.
+func allowedPathsFromConfig(raw []interface{}) ([]string, error) {
+    patterns := make([]string, 0, len(raw))
+    for _, value := range raw {
+        pattern := value.(string)
+        if !filepath.IsAbs(pattern) {
+            return nil, fmt.Errorf("allowed_paths %q must be an absolute path", pattern)
+        }
+        if _, err := filepath.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil {
+            return nil, fmt.Errorf("allowed_paths %q is not a valid glob: %w", pattern, err)
+        }
+        patterns = append(patterns, filepath.Clean(pattern))
+    }
+    return patterns, nil
+}
+
+// pathAllowed reports whether path matches one of the allowed_paths patterns. A pattern
+// ending in /** matches the directory before it and everything beneath; other patterns
+// are matched as filepath.Match does, so * does not cross directories. The path is
+// cleaned first, so .. cannot lead out of an allowed directory.
+func pathAllowed(patterns []string, path string) bool {
+    path = filepath.Clean(path)
+    for _, pattern := range patterns {
+        if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+            for p := path; ; p = filepath.Dir(p) {
+                if matched, _ := filepath.Match(dir, p); matched {
+                    return true
+                }
+                if p == filepath.Dir(p) {
+                    break
+                }
+            }
+            continue
+        }
+        if matched, _ := filepath.Match(pattern, path); matched {
+            return true
+        }
+    }
+    return false
+}
+
+// checkAllowedPath fails when path, named by attr, is outside the allowed_paths patterns.
+// With resolve set, a path that is a symbolic link must lead to an allowed path too.
+func checkAllowedPath(patterns []string, attr, path string, resolve bool) error {
+    if len(patterns) == 0 || path == "" {
+        return nil
+    }
+    if !filepath.IsAbs(path) {
+        return fmt.Errorf("%s %s must be an absolute path, as the provider's allowed_paths are set", attr, path)
+    }
+    if !pathAllowed(patterns, path) {
+        return fmt.Errorf("%s %s is outside the provider's allowed_paths (%s)", attr, path, strings.Join(patterns, ", "))
+    }
+    if resolve {
+        if target, err := filepath.EvalSymlinks(path); err == nil && !pathAllowed(patterns, target) {
+            return fmt.Errorf("%s %s is a link to %s, which is outside the provider's allowed_paths (%s)", attr, path, target, strings.Join(patterns, ", "))
+        }
+    }
+    return nil
+}
+
+// checkVMAllowedPaths fails the plan when a VM refers to a host file outside the provider's
+// allowed_paths: its kernel, initrd and drive images, those of its config_json, and the
+// directory of its golden snapshot. The kernel, initrd and drive images of jailed VMs that
+// are not staged are in the chroot, so the chroot base directory must be allowed instead.
+// With local set, the files are on the host running Terraform and links are followed. Paths
+// not known until apply are checked then.
+func checkVMAllowedPaths(d *schema.ResourceDiff, patterns []string, local bool) error {
+    if len(patterns) == 0 {
+        return nil
+    }
+
+    check := func(key, attr string) error {
+        if !d.NewValueKnown(key) {
+            return nil
+        }
+        path, _ := d.Get(key).(string)
+        return checkAllowedPath(patterns, attr, path, local)
+    }
+    if jailer, ok := jailerSpecFromConfig(d.Get("jailer"), d.Id()); ok && d.NewValueKnown("jailer") && !jailer.StageFiles {
+        if err := checkAllowedPath(patterns, "jailer chroot_base_dir", jailer.ChrootBaseDir, local); err != nil {
+            return err
+        }
+    } else {
+        if err := check("kernel_image_path", "kernel_image_path"); err != nil {
+            return err
+        }
+        if err := check("initrd_path", "initrd_path"); err != nil {
+            return err
+        }
+        for i, raw := range d.Get("drives").([]interface{}) {
+            drive, ok := raw.(map[string]interface{})
+            if !ok {
+                continue
+            }
+            if err := check(fmt.Sprintf("drives.%d.path_on_host", i), fmt.Sprintf("path_on_host of drive %s", drive["drive_id"])); err != nil {
+                return err
+            }
+        }
+    }
+    if err := check("golden_snapshot.0.directory", "golden_snapshot directory"); err != nil {
+        return err
+    }
+
+    if !d.NewValueKnown("config_json") || d.Get("config_json").(string) == "" {
+        return nil
+    }
+    config, err := parseConfigFile(d.Get("config_json").(string))
+    if err != nil || config == nil {
+        return nil
+    }
+    bootSource := config["boot-source"].(map[string]interface{})
+    for _, key := range []string{"kernel_image_path", "initrd_path"} {
+        path, _ := bootSource[key].(string)
+        if err := checkAllowedPath(patterns, "config_json boot-source "+key, path, local); err != nil {
+            return err
+        }
+    }
+    drives, _ := config["drives"].([]interface{})
+    for _, raw := range drives {
+        drive := raw.(map[string]interface{})
+        path, _ := drive["path_on_host"].(string)
+        if err := checkAllowedPath(patterns, fmt.Sprintf("config_json path_on_host of drive %s", drive["drive_id"]), path, local); err != nil {
+            return err
+        }
+    }
+    return nil
+}
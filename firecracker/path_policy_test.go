@@ -0,0 +1,110 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestPathAllowed(t *testing.T) {
+	patterns := []string{"/srv/images/*.ext4", "/srv/tenants/**"}
+	for path, allowed := range map[string]bool{
+		"/srv/images/rootfs.ext4":           true,
+		"/srv/images/nested/rootfs.ext4":    false,
+		"/srv/images/vmlinux":               false,
+		"/srv/tenants":                      true,
+		"/srv/tenants/a/drives/data.ext4":   true,
+		"/srv/tenants/../../etc/shadow":     false,
+		"/srv/tenants-other/rootfs.ext4":    false,
+		"/etc/shadow":                       false,
+		"/srv/images/../images/data.ext4":   true,
+		"/srv/tenants/a/../../images/x.img": false,
+	} {
+		if got := pathAllowed(patterns, path); got != allowed {
+			t.Errorf("Expected %s allowed: %t, got %t", path, allowed, got)
+		}
+	}
+}
+
+func TestAllowedPathsFromConfig(t *testing.T) {
+	if _, err := allowedPathsFromConfig([]interface{}{"images/*"}); err == nil || !strings.Contains(err.Error(), "absolute") {
+		t.Errorf("Expected relative patterns to be rejected, got %v", err)
+	}
+	if _, err := allowedPathsFromConfig([]interface{}{"/srv/[images"}); err == nil || !strings.Contains(err.Error(), "not a valid glob") {
+		t.Errorf("Expected malformed patterns to be rejected, got %v", err)
+	}
+}
+
+func TestCheckVMAllowedPaths(t *testing.T) {
+	allowed := t.TempDir()
+	outside := t.TempDir()
+	for _, path := range []string{filepath.Join(allowed, "vmlinux"), filepath.Join(allowed, "rootfs.ext4"), filepath.Join(outside, "secret")} {
+		if err := os.WriteFile(path, []byte("image"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(allowed, "link.ext4")); err != nil {
+		t.Fatal(err)
+	}
+
+	client := configureFakeProvider(t, t.TempDir())
+	client.allowedPaths = []string{allowed + "/**"}
+	config := func(rootfs string) map[string]interface{} {
+		return map[string]interface{}{
+			"kernel_image_path": filepath.Join(allowed, "vmlinux"),
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": rootfs, "is_root_device": true},
+			},
+			"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		rootfs string
+		err    string
+	}{
+		"allowed": {rootfs: filepath.Join(allowed, "rootfs.ext4")},
+		"outside": {rootfs: filepath.Join(outside, "secret"), err: "path_on_host of drive rootfs " + filepath.Join(outside, "secret") + " is outside the provider's allowed_paths"},
+		"link":    {rootfs: filepath.Join(allowed, "link.ext4"), err: "is a link to " + filepath.Join(outside, "secret")},
+		"escape":  {rootfs: allowed + "/../" + filepath.Base(outside) + "/secret", err: "is outside the provider's allowed_paths"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config(tc.rootfs)), client)
+			if tc.err == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Errorf("Expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	// Files of a jailed VM are in the chroot, but its golden snapshot is not
+	jailed := map[string]interface{}{
+		"kernel_image_path": "/vmlinux",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/rootfs.ext4", "is_root_device": true},
+		},
+		"machine_config":  []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+		"jailer":          []interface{}{map[string]interface{}{"chroot_base_dir": filepath.Join(allowed, "jailer"), "stage_files": false}},
+		"golden_snapshot": []interface{}{map[string]interface{}{"name": "web", "directory": outside}},
+	}
+	_, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(jailed), client)
+	if err == nil || !strings.Contains(err.Error(), "golden_snapshot directory "+outside+" is outside the provider's allowed_paths") {
+		t.Errorf("Expected the golden snapshot directory of the jailed VM to be refused, got %v", err)
+	}
+
+	// Swapping the image of a running VM is held to the same policy
+	_, err = resourceFirecrackerDrive().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"vm_id":        "vm-1",
+		"drive_id":     "data",
+		"path_on_host": filepath.Join(outside, "secret"),
+	}), client)
+	if err == nil || !strings.Contains(err.Error(), "is outside the provider's allowed_paths") {
+		t.Errorf("Expected the drive to be refused, got %v", err)
+	}
+}
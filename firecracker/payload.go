@@ -0,0 +1,469 @@
+package firecracker
+
+import (
+    "encoding/json"
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    // defaultRootFSType is the root filesystem type assumed when boot_args does not name one.
+    defaultRootFSType = "ext4"
+
+    // defaultRootDevice is the root device assumed when boot_args does not name one and the
+    // root drive has no partuuid: the root drive is the first virtio block device.
+    defaultRootDevice = "/dev/vda"
+)
+
+// partUUIDRegexp matches a partition UUID: a GPT partition GUID, or the disk signature and
+// partition number of an MBR partition such as 1a2b3c4d-01.
+var partUUIDRegexp = regexp.MustCompile(`^[0-9a-fA-F]+(-[0-9a-fA-F]+)*$`)
+
+// normalizeBootArgs rewrites the kernel command line so it names the guest's root filesystem
+// exactly once, along with its type and read-only mode. The root device the user asked for is
+// kept. Without one, the root partition is found by rootPartUUID when the root drive has a
+// partuuid, and is the whole root drive otherwise. Parameters after "--" belong to init and
+// are left alone. Applying it to its own output changes nothing.
+func normalizeBootArgs(bootArgs, rootPartUUID string) string {
+    fields := strings.Fields(bootArgs)
+
+    initArgs := []string{}
+    for i, field := range fields {
+        if field == "--" {
+            initArgs = fields[i:]
+            fields = fields[:i]
+            break
+        }
+    }
+
+    root := ""
+    rootFSType := defaultRootFSType
+    mode := "rw"
+    hasConsole := false
+    kernelArgs := make([]string, 0, len(fields)+4)
+    for _, field := range fields {
+        switch {
+        case strings.HasPrefix(field, "root="):
+            // Like the kernel, the last root parameter wins
+            if device := strings.TrimPrefix(field, "root="); device != "" {
+                root = device
+            }
+            continue
+        case strings.HasPrefix(field, "rootfstype="):
+            if fsType := strings.TrimPrefix(field, "rootfstype="); fsType != "" {
+                rootFSType = fsType
+            }
+            continue
+        case field == "rw" || field == "ro":
+            mode = field
+            continue
+        case strings.HasPrefix(field, "console="):
+            hasConsole = true
+        }
+        kernelArgs = append(kernelArgs, field)
+    }
+
+    if !hasConsole {
+        kernelArgs = append(kernelArgs, "console=ttyS0")
+    }
+    if root == "" && rootPartUUID != "" {
+        root = "PARTUUID=" + rootPartUUID
+    } else if root == "" {
+        root = defaultRootDevice
+    }
+    kernelArgs = append(kernelArgs, "root="+root, "rootfstype="+rootFSType, mode)
+
+    return strings.Join(append(kernelArgs, initArgs...), " ")
+}
+
+// withSensitiveBootArgs adds the kernel parameters of sensitive to the kernel command line,
+// before the parameters belonging to init.
+func withSensitiveBootArgs(bootArgs, sensitive string) string {
+    extra := strings.Fields(sensitive)
+    if len(extra) == 0 {
+        return bootArgs
+    }
+    fields := strings.Fields(bootArgs)
+    for i, field := range fields {
+        if field == "--" {
+            return strings.Join(append(append(fields[:i:i], extra...), fields[i:]...), " ")
+        }
+    }
+    return strings.Join(append(fields, extra...), " ")
+}
+
+// withoutSensitiveBootArgs removes the parameters of sensitive from a kernel command line
+// read back from Firecracker, so they do not end up in boot_args.
+func withoutSensitiveBootArgs(bootArgs, sensitive string) string {
+    remove := map[string]int{}
+    for _, field := range strings.Fields(sensitive) {
+        remove[field]++
+    }
+    if len(remove) == 0 {
+        return bootArgs
+    }
+    fields := make([]string, 0, len(strings.Fields(bootArgs)))
+    for _, field := range strings.Fields(bootArgs) {
+        if remove[field] > 0 {
+            remove[field]--
+            continue
+        }
+        fields = append(fields, field)
+    }
+    return strings.Join(fields, " ")
+}
+
+// coerceBool converts a configuration value to a bool. Strings are parsed rather than
+// compared to "true", so a malformed value is reported instead of silently becoming false.
+// A missing value is false.
+func coerceBool(value interface{}) (bool, error) {
+    switch v := value.(type) {
+    case nil:
+        return false, nil
+    case bool:
+        return v, nil
+    case string:
+        b, err := strconv.ParseBool(v)
+        if err != nil {
+            return false, fmt.Errorf("invalid boolean %q", v)
+        }
+        return b, nil
+    default:
+        return false, fmt.Errorf("invalid boolean of type %T", value)
+    }
+}
+
+// coerceInt converts a configuration value to an int, as it is rendered or decoded from
+// JSON. A missing value is 0.
+func coerceInt(value interface{}) (int, error) {
+    switch v := value.(type) {
+    case nil:
+        return 0, nil
+    case int:
+        return v, nil
+    case float64:
+        if v != float64(int(v)) {
+            return 0, fmt.Errorf("invalid integer %v", v)
+        }
+        return int(v), nil
+    case json.Number:
+        i, err := strconv.Atoi(v.String())
+        if err != nil {
+            return 0, fmt.Errorf("invalid integer %q", v)
+        }
+        return i, nil
+    default:
+        return 0, fmt.Errorf("invalid integer of type %T", value)
+    }
+}
+
+// requiredString returns a non-empty string field of a configuration block.
+func requiredString(block map[string]interface{}, key string) (string, error) {
+    value, ok := block[key].(string)
+    if !ok || value == "" {
+        return "", fmt.Errorf("%s is required", key)
+    }
+    return value, nil
+}
+
+// drivePayload converts a drives block to the Firecracker API representation of the drive.
+func drivePayload(raw interface{}) (map[string]interface{}, error) {
+    drive, ok := raw.(map[string]interface{})
+    if !ok {
+        return nil, fmt.Errorf("invalid drive configuration of type %T", raw)
+    }
+
+    driveID, err := requiredString(drive, "drive_id")
+    if err != nil {
+        return nil, fmt.Errorf("invalid drive: %w", err)
+    }
+    pathOnHost, err := requiredString(drive, "path_on_host")
+    if err != nil {
+        return nil, fmt.Errorf("invalid drive %s: %w", driveID, err)
+    }
+    isRootDevice, err := coerceBool(drive["is_root_device"])
+    if err != nil {
+        return nil, fmt.Errorf("invalid is_root_device of drive %s: %w", driveID, err)
+    }
+    isReadOnly, err := coerceBool(drive["is_read_only"])
+    if err != nil {
+        return nil, fmt.Errorf("invalid is_read_only of drive %s: %w", driveID, err)
+    }
+
+    payload := map[string]interface{}{
+        "drive_id":       driveID,
+        "path_on_host":   pathOnHost,
+        "is_root_device": isRootDevice,
+        "is_read_only":   isReadOnly,
+    }
+    if partUUID, _ := drive["partuuid"].(string); partUUID != "" {
+        payload["partuuid"] = partUUID
+    }
+    return payload, nil
+}
+
+// rootPartUUID returns the partuuid of the VM's root drive, if it has one.
+func rootPartUUID(d *schema.ResourceData) string {
+    for _, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        if isRootDevice, _ := drive["is_root_device"].(bool); isRootDevice {
+            partUUID, _ := drive["partuuid"].(string)
+            return partUUID
+        }
+    }
+    return ""
+}
+
+// networkInterfacePayload converts a network_interfaces block to the Firecracker API
+// representation of the interface.
+func networkInterfacePayload(raw interface{}) (map[string]interface{}, error) {
+    iface, ok := raw.(map[string]interface{})
+    if !ok {
+        return nil, fmt.Errorf("invalid network interface configuration of type %T", raw)
+    }
+
+    ifaceID, err := requiredString(iface, "iface_id")
+    if err != nil {
+        return nil, fmt.Errorf("invalid network interface: %w", err)
+    }
+    hostDevName, err := requiredString(iface, "host_dev_name")
+    if err != nil {
+        return nil, fmt.Errorf("invalid network interface %s: %w", ifaceID, err)
+    }
+
+    payload := map[string]interface{}{
+        "iface_id":      ifaceID,
+        "host_dev_name": hostDevName,
+    }
+
+    // Only add guest_mac if it's set
+    if mac, ok := iface["guest_mac"].(string); ok && mac != "" {
+        payload["guest_mac"] = mac
+    }
+
+    return payload, nil
+}
+
+// payloadList converts a list of API objects to the []interface{} form CreateVM expects.
+func payloadList(items []map[string]interface{}) []interface{} {
+    list := make([]interface{}, 0, len(items))
+    for _, item := range items {
+        list = append(list, item)
+    }
+    return list
+}
+
+// vmPayloadExtras are the parts of a VM's configuration that come from side effects of
+// creating it rather than from its attributes alone.
+type vmPayloadExtras struct {
+    // SeedImagePath is the cloud-init seed image built for the VM, if any.
+    SeedImagePath string
+
+    // CNIInterface is the network interface created by the VM's CNI plugins, if any.
+    CNIInterface map[string]interface{}
+
+    // ScratchDrivePaths are the paths of the scratch drives created for the VM, by drive ID.
+    ScratchDrivePaths map[string]string
+}
+
+// existingVMPayloadExtras returns the extras of a VM that was created, whose seed image and
+// scratch drives are where creating it put them. A CNI interface cannot be known again.
+func existingVMPayloadExtras(d *schema.ResourceData, stateDir, vmID string) vmPayloadExtras {
+    var extras vmPayloadExtras
+    if seed, ok := cloudInitSpecFromConfig(d, vmID); ok && seed.Datasource == cloudInitDatasourceNoCloud {
+        extras.SeedImagePath = seedImagePath(stateDir, vmID)
+    }
+    for _, drive := range scratchDrivesFromConfig(d) {
+        if extras.ScratchDrivePaths == nil {
+            extras.ScratchDrivePaths = map[string]string{}
+        }
+        extras.ScratchDrivePaths[drive.DriveID] = scratchDrivePath(stateDir, vmID, drive.DriveID)
+    }
+    return extras
+}
+
+// setRenderedConfig records the configuration file a VM can be booted from outside Terraform,
+// without the secrets logs leave out.
+func setRenderedConfig(d *schema.ResourceData, payload map[string]interface{}) error {
+    config, err := json.MarshalIndent(bootConfig(redactVMConfig(payload)), "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode the rendered VM configuration: %w", err)
+    }
+    return d.Set("rendered_config_json", string(config))
+}
+
+// renderVMPayload builds the complete configuration CreateVM sends to the Firecracker API
+// for a firecracker_vm. It has no side effects, so the rendered configuration can be
+// compared against golden files.
+func renderVMPayload(d *schema.ResourceData, vmID string, extras vmPayloadExtras) (map[string]interface{}, error) {
+    // VMs created from a configuration file are configured with it as it is
+    if raw := d.Get("config_json").(string); raw != "" {
+        return configFilePayload(raw, vmID)
+    }
+
+    // Construct the boot source payload, with the root device the guest mounts
+    bootSource := map[string]interface{}{
+        "kernel_image_path": d.Get("kernel_image_path").(string),
+        "boot_args":         normalizeBootArgs(withSensitiveBootArgs(d.Get("boot_args").(string), d.Get("sensitive_boot_args").(string)), rootPartUUID(d)),
+    }
+    if initrdPath := d.Get("initrd_path").(string); initrdPath != "" {
+        bootSource["initrd_path"] = initrdPath
+    }
+
+    // Construct the drives payload
+    drives := []map[string]interface{}{}
+    for _, rawDrive := range d.Get("drives").([]interface{}) {
+        // Scratch drives are attached from where they were created
+        if cfg, ok := rawDrive.(map[string]interface{}); ok && cfg["path_on_host"] == "" {
+            if path, ok := extras.ScratchDrivePaths[cfg["drive_id"].(string)]; ok {
+                scratch := make(map[string]interface{}, len(cfg))
+                for key, value := range cfg {
+                    scratch[key] = value
+                }
+                scratch["path_on_host"] = path
+                rawDrive = scratch
+            }
+        }
+        drive, err := drivePayload(rawDrive)
+        if err != nil {
+            return nil, err
+        }
+        drives = append(drives, drive)
+    }
+    if extras.SeedImagePath != "" {
+        drives = append(drives, map[string]interface{}{
+            "drive_id":       cloudInitDriveID,
+            "path_on_host":   extras.SeedImagePath,
+            "is_root_device": false,
+            "is_read_only":   true,
+        })
+    }
+
+    // Construct the machine config payload
+    machineConfig := map[string]interface{}{}
+    if raw := d.Get("machine_config").([]interface{}); len(raw) > 0 && raw[0] != nil {
+        cfg := raw[0].(map[string]interface{})
+        machineConfig["vcpu_count"] = cfg["vcpu_count"].(int)
+        machineConfig["mem_size_mib"] = cfg["mem_size_mib"].(int)
+    }
+
+    // Construct the network interfaces payload
+    networkInterfaces := []map[string]interface{}{}
+    for _, rawIface := range vmNetworkInterfaces(d) {
+        iface, err := networkInterfacePayload(rawIface)
+        if err != nil {
+            return nil, err
+        }
+        networkInterfaces = append(networkInterfaces, iface)
+    }
+    if extras.CNIInterface != nil {
+        networkInterfaces = append(networkInterfaces, extras.CNIInterface)
+    }
+
+    payload := map[string]interface{}{
+        "boot-source":        bootSource,
+        "drives":             payloadList(drives),
+        "machine-config":     machineConfig,
+        "network-interfaces": payloadList(networkInterfaces),
+        "vm-id":              vmID,
+    }
+
+    // The sensitive kernel parameters are not part of the API either, CreateVM's logs leave
+    // them out
+    if sensitive := d.Get("sensitive_boot_args").(string); sensitive != "" {
+        payload["sensitive_boot_args"] = sensitive
+    }
+
+    if vsock := d.Get("vsock").([]interface{}); len(vsock) > 0 && vsock[0] != nil {
+        cfg := vsock[0].(map[string]interface{})
+        payload["vsock"] = map[string]interface{}{
+            "guest_cid": cfg["guest_cid"].(int),
+            "uds_path":  cfg["uds_path"].(string),
+        }
+    }
+
+    // Paths of a jailed VM are in its chroot, where staged files are given their own names.
+    // The chroot is not part of the API, CreateVM only uses it to find the kernel on the host.
+    if spec, ok := jailerSpecFromConfig(d.Get("jailer"), vmID); ok {
+        if spec.StageFiles {
+            bootSource["kernel_image_path"] = jailerKernelPath
+            if bootSource["initrd_path"] != nil {
+                bootSource["initrd_path"] = jailerInitrdPath
+            }
+            for _, drive := range drives {
+                drive["path_on_host"] = stagedDrivePath(drive["drive_id"].(string), drive["path_on_host"].(string))
+            }
+        }
+        payload["chroot"] = spec.chrootPath()
+    }
+
+    // Uploaded files are opened where they were uploaded to on the remote host. The staging
+    // directory is not part of the API either, it tells CreateVM the kernel is not on this host.
+    if spec, ok := stagingSpecFromConfig(d); ok {
+        staged := stagedPaths(d, spec, vmID)
+        bootSource["kernel_image_path"] = staged[stagingKernelKey]
+        if bootSource["initrd_path"] != nil {
+            bootSource["initrd_path"] = staged[stagingInitrdKey]
+        }
+        for _, drive := range drives {
+            if remotePath, ok := staged[drive["drive_id"].(string)]; ok {
+                drive["path_on_host"] = remotePath
+            }
+        }
+        payload["staging_dir"] = spec.vmDir(vmID)
+    }
+
+    // Files of a VM placed on a host from the provider's host pool are opened on that host.
+    // The host is not part of the API either, it tells CreateVM the kernel is not on this host.
+    if host := d.Get("host").(string); host != "" {
+        payload["host"] = host
+    }
+
+    if d.Get("entropy_device").(bool) {
+        payload["entropy"] = map[string]interface{}{}
+    }
+
+    if raw := d.Get("cpu_config_json").(string); raw != "" {
+        cpuConfig, err := parseCPUConfig(raw)
+        if err != nil {
+            return nil, err
+        }
+        payload["cpu-config"] = cpuConfig
+    }
+
+    // Publish guest metadata through MMDS, reachable from the interfaces of the mmds block or every one
+    mmdsContents := mmdsContentsFromConfig(d)
+    if spec, ok := cloudInitSpecFromConfig(d, vmID); ok && spec.Datasource == cloudInitDatasourceMMDS {
+        if mmdsContents == nil {
+            mmdsContents = map[string]interface{}{}
+        }
+        for key, value := range cloudInitMMDSContents(spec) {
+            mmdsContents[key] = value
+        }
+    }
+    if mmdsContents != nil || mmdsBlock(d) != nil {
+        ifaceIDs := make([]string, 0, len(networkInterfaces))
+        for _, iface := range networkInterfaces {
+            ifaceIDs = append(ifaceIDs, iface["iface_id"].(string))
+        }
+        if len(ifaceIDs) > 0 {
+            mmdsConfig, err := mmdsConfigFromConfig(d, ifaceIDs)
+            if err != nil {
+                return nil, err
+            }
+            payload["mmds-config"] = mmdsConfig
+        }
+        if mmdsContents != nil {
+            payload["mmds"] = mmdsContents
+        }
+    }
+
+    return payload, nil
+}
@@ -0,0 +1,279 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestNormalizeBootArgs(t *testing.T) {
+	cases := []struct {
+		input    string
+		partUUID string
+		expected string
+	}{
+		{"console=ttyS0 noapic reboot=k panic=1 pci=off root=/dev/vda rootfstype=ext4 rw init=/sbin/init", "", "console=ttyS0 noapic reboot=k panic=1 pci=off init=/sbin/init root=/dev/vda rootfstype=ext4 rw"},
+		{"", "", "console=ttyS0 root=/dev/vda rootfstype=ext4 rw"},
+		{"rootfstype=xfs ro", "", "console=ttyS0 root=/dev/vda rootfstype=xfs ro"},
+		{"root=/dev/vda1 root=/dev/vdb2", "", "console=ttyS0 root=/dev/vdb2 rootfstype=ext4 rw"},
+		{"nfsroot=10.0.0.1:/srv quiet", "", "nfsroot=10.0.0.1:/srv quiet console=ttyS0 root=/dev/vda rootfstype=ext4 rw"},
+		{"console=hvc0 -- root=/data single", "", "console=hvc0 root=/dev/vda rootfstype=ext4 rw -- root=/data single"},
+		{"console=ttyS0 quiet", "1a2b3c4d-01", "console=ttyS0 quiet root=PARTUUID=1a2b3c4d-01 rootfstype=ext4 rw"},
+		{"root=/dev/vda2 ro", "1a2b3c4d-01", "console=ttyS0 root=/dev/vda2 rootfstype=ext4 ro"},
+	}
+	for _, tc := range cases {
+		if got := normalizeBootArgs(tc.input, tc.partUUID); got != tc.expected {
+			t.Errorf("normalizeBootArgs(%q, %q) = %q, expected %q", tc.input, tc.partUUID, got, tc.expected)
+		}
+	}
+}
+
+func TestSensitiveBootArgs(t *testing.T) {
+	cases := []struct {
+		bootArgs, sensitive, expected string
+	}{
+		{"console=ttyS0 quiet", "", "console=ttyS0 quiet"},
+		{"console=ttyS0 quiet", "token=abc key=def", "console=ttyS0 quiet token=abc key=def"},
+		{"console=ttyS0 -- single", "token=abc", "console=ttyS0 token=abc -- single"},
+	}
+	for _, tc := range cases {
+		got := withSensitiveBootArgs(tc.bootArgs, tc.sensitive)
+		if got != tc.expected {
+			t.Errorf("withSensitiveBootArgs(%q, %q) = %q, expected %q", tc.bootArgs, tc.sensitive, got, tc.expected)
+		}
+		if back := withoutSensitiveBootArgs(normalizeBootArgs(got, ""), tc.sensitive); back != normalizeBootArgs(tc.bootArgs, "") {
+			t.Errorf("Expected the sensitive parameters to be removed again, got %q", back)
+		}
+	}
+}
+
+func FuzzNormalizeBootArgs(f *testing.F) {
+	f.Add("console=ttyS0 noapic reboot=k panic=1 pci=off root=/dev/vda rootfstype=ext4 rw init=/sbin/init", "")
+	f.Add("root=/dev/vda root=/dev/vdb rootfstype= ro rw", "")
+	f.Add("nfsroot=x\troot=y\n-- rw root=z", "")
+	f.Add("root= quiet", "1a2b3c4d-01")
+	f.Add("", "")
+
+	f.Fuzz(func(t *testing.T, input, partUUID string) {
+		if !partUUIDRegexp.MatchString(partUUID) {
+			partUUID = ""
+		}
+		output := normalizeBootArgs(input, partUUID)
+
+		if again := normalizeBootArgs(output, partUUID); again != output {
+			t.Fatalf("Not idempotent: %q became %q, then %q", input, output, again)
+		}
+
+		kernel, init := strings.Fields(output), []string{}
+		for i, field := range kernel {
+			if field == "--" {
+				kernel, init = kernel[:i], kernel[i:]
+				break
+			}
+		}
+
+		counts := map[string]int{}
+		for _, field := range kernel {
+			switch {
+			case strings.HasPrefix(field, "root="):
+				counts["root"]++
+			case strings.HasPrefix(field, "rootfstype="):
+				counts["rootfstype"]++
+			case field == "rw" || field == "ro":
+				counts["mode"]++
+			case strings.HasPrefix(field, "console="):
+				counts["console"]++
+			}
+		}
+		if counts["root"] != 1 || counts["rootfstype"] != 1 || counts["mode"] != 1 || counts["console"] == 0 {
+			t.Fatalf("Unexpected root parameters %v in %q from %q", counts, output, input)
+		}
+
+		// Every other kernel parameter and everything after "--" survives in order
+		inputKernel, inputInit := strings.Fields(input), []string{}
+		for i, field := range inputKernel {
+			if field == "--" {
+				inputKernel, inputInit = inputKernel[:i], inputKernel[i:]
+				break
+			}
+		}
+		kept := []string{}
+		for _, field := range inputKernel {
+			if !strings.HasPrefix(field, "root=") && !strings.HasPrefix(field, "rootfstype=") && field != "rw" && field != "ro" {
+				kept = append(kept, field)
+			}
+		}
+		if got := strings.Join(kernel[:len(kept)], " "); got != strings.Join(kept, " ") {
+			t.Fatalf("Kernel parameters of %q were not preserved: %q", input, output)
+		}
+		if strings.Join(init, " ") != strings.Join(inputInit, " ") {
+			t.Fatalf("Init parameters of %q were not preserved: %q", input, output)
+		}
+	})
+}
+
+func TestCoerceBool(t *testing.T) {
+	for value, expected := range map[interface{}]bool{nil: false, true: true, false: false, "true": true, "1": true, "FALSE": false} {
+		got, err := coerceBool(value)
+		if err != nil || got != expected {
+			t.Errorf("coerceBool(%#v) = %v, %v, expected %v", value, got, err, expected)
+		}
+	}
+	for _, value := range []interface{}{"yes", "", 1} {
+		if _, err := coerceBool(value); err == nil {
+			t.Errorf("Expected coerceBool(%#v) to fail", value)
+		}
+	}
+}
+
+func FuzzDrivePayload(f *testing.F) {
+	f.Add("rootfs", "/images/rootfs.ext4", "true", "false", true)
+	f.Add("data", "", "yes", "0", false)
+	f.Add("", "/x", "", "", true)
+
+	f.Fuzz(func(t *testing.T, driveID, path, isRootDevice, isReadOnly string, asBool bool) {
+		drive := map[string]interface{}{
+			"drive_id":       driveID,
+			"path_on_host":   path,
+			"is_root_device": isRootDevice,
+			"is_read_only":   isReadOnly,
+		}
+		// The schema delivers bools, older state and hand-built maps may hold strings
+		if asBool {
+			drive["is_root_device"], _ = strconv.ParseBool(isRootDevice)
+			drive["is_read_only"], _ = strconv.ParseBool(isReadOnly)
+		}
+
+		payload, err := drivePayload(drive)
+		if err != nil {
+			return
+		}
+
+		if payload["drive_id"] != driveID || payload["path_on_host"] != path {
+			t.Fatalf("Drive fields were altered: %v from %v", payload, drive)
+		}
+		for _, key := range []string{"is_root_device", "is_read_only"} {
+			got, ok := payload[key].(bool)
+			if !ok {
+				t.Fatalf("%s is %T, expected bool", key, payload[key])
+			}
+			expected, _ := coerceBool(drive[key])
+			if got != expected {
+				t.Fatalf("%s is %v, expected %v from %#v", key, got, expected, drive[key])
+			}
+		}
+	})
+}
+
+func FuzzNetworkInterfacePayload(f *testing.F) {
+	f.Add("eth0", "tap0", "AA:FC:00:00:00:01")
+	f.Add("eth1", "", "")
+
+	f.Fuzz(func(t *testing.T, ifaceID, hostDevName, guestMAC string) {
+		payload, err := networkInterfacePayload(map[string]interface{}{
+			"iface_id":      ifaceID,
+			"host_dev_name": hostDevName,
+			"guest_mac":     guestMAC,
+			"bridge":        "fcbr0",
+		})
+		if err != nil {
+			if ifaceID != "" && hostDevName != "" {
+				t.Fatalf("Unexpected error for a complete interface: %v", err)
+			}
+			return
+		}
+
+		if payload["iface_id"] != ifaceID || payload["host_dev_name"] != hostDevName {
+			t.Fatalf("Interface fields were altered: %v", payload)
+		}
+		if _, ok := payload["bridge"]; ok {
+			t.Fatalf("Provider-only bridge attribute leaked into the API payload: %v", payload)
+		}
+		if mac, ok := payload["guest_mac"]; ok != (guestMAC != "") || (ok && mac != guestMAC) {
+			t.Fatalf("guest_mac %q became %v", guestMAC, payload["guest_mac"])
+		}
+	})
+}
+
+func TestDrivePayload_rejectsMalformedInput(t *testing.T) {
+	for _, raw := range []interface{}{
+		nil,
+		"rootfs",
+		map[string]interface{}{"drive_id": "rootfs"},
+		map[string]interface{}{"drive_id": 1, "path_on_host": "/x"},
+		map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/x", "is_read_only": "maybe"},
+	} {
+		if _, err := drivePayload(raw); err == nil {
+			t.Errorf("Expected drivePayload(%#v) to fail", raw)
+		}
+	}
+}
+
+// TestPayloadList_reachesAPI checks that drives and network interfaces converted from the
+// schema are all configured by CreateVM rather than dropped on a type mismatch.
+func TestPayloadList_reachesAPI(t *testing.T) {
+	kernelPath := filepath.Join(t.TempDir(), "vmlinux")
+	if err := os.WriteFile(kernelPath, []byte("kernel"), 0o644); err != nil {
+		t.Fatalf("failed to write kernel image: %v", err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true},
+			map[string]interface{}{"drive_id": "data", "path_on_host": "/images/data.ext4", "is_root_device": false, "is_read_only": true},
+		},
+		"network_interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+		},
+	})
+
+	drives := []map[string]interface{}{}
+	for _, raw := range d.Get("drives").([]interface{}) {
+		drive, err := drivePayload(raw)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		drives = append(drives, drive)
+	}
+	ifaces := []map[string]interface{}{}
+	for _, raw := range d.Get("network_interfaces").([]interface{}) {
+		iface, err := networkInterfacePayload(raw)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		ifaces = append(ifaces, iface)
+	}
+
+	var calls []string
+	client := &FirecrackerClient{
+		BaseURL: "http://localhost:8080",
+		HTTPClient: &mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, req.URL.Path)
+				return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+			},
+		},
+	}
+	err := client.CreateVM(context.Background(), map[string]interface{}{
+		"boot-source":        map[string]interface{}{"kernel_image_path": kernelPath, "boot_args": normalizeBootArgs("", "")},
+		"drives":             payloadList(drives),
+		"network-interfaces": payloadList(ifaces),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, path := range []string{"/drives/rootfs", "/drives/data", "/network-interfaces/eth0"} {
+		if !strings.Contains(strings.Join(calls, " "), path) {
+			t.Errorf("Expected %s to be configured, got calls %v", path, calls)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package firecracker
+
+import (
+    "fmt"
+    "hash/fnv"
+    "sort"
+    "strings"
+    "sync"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// hostEntry is a Firecracker host in the provider's host pool.
+type hostEntry struct {
+    Name   string
+    Labels map[string]string
+    Client *FirecrackerClient
+
+    // Capacity is the largest number of VMs placed on the host; 0 means unlimited.
+    Capacity int
+
+    // SSHHost is the address files are copied to and from the host over SSH; empty means
+    // the host of the Firecracker API URL.
+    SSHHost string
+}
+
+// matchesSelector reports whether labels contain every key/value pair in selector.
+// An empty selector matches every host.
+func matchesSelector(labels, selector map[string]string) bool {
+    for key, value := range selector {
+        if labels[key] != value {
+            return false
+        }
+    }
+    return true
+}
+
+// placementPolicyAntiAffinity places every member of a placement group on a different host.
+const placementPolicyAntiAffinity = "anti-affinity"
+
+// Placement strategies choose among the hosts a new VM may be placed on.
+const (
+    // placementStrategySpread places VMs on the host running the fewest VMs.
+    placementStrategySpread = "spread"
+    // placementStrategyBinpack places VMs on the host running the most VMs that has
+    // capacity left, keeping the other hosts free.
+    placementStrategyBinpack = "binpack"
+)
+
+// hostLoads tracks the hosts VMs were placed on during this run, which the registry does
+// not know about until the VMs are created.
+type hostLoads struct {
+    mu     sync.Mutex
+    placed map[string]string // VM ID -> host
+}
+
+func newHostLoads() *hostLoads {
+    return &hostLoads{placed: map[string]string{}}
+}
+
+// record registers the host a VM runs on.
+func (l *hostLoads) record(vmID, host string) {
+    if l == nil {
+        return
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.placed[vmID] = host
+}
+
+// release forgets the placement of a VM that was deleted or failed to be created.
+func (l *hostLoads) release(vmID string) {
+    if l == nil {
+        return
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    delete(l.placed, vmID)
+}
+
+// countHostLoads returns the number of VMs on each host other than vmID, from the VM
+// registry and the placements made during this run. The caller holds the hostLoads lock.
+func (c *FirecrackerClient) countHostLoads(vmID string) (map[string]int, error) {
+    hosts := map[string]string{}
+    if c.StateDir != "" || c.VMRegistryDir != "" {
+        records, err := listRegisteredVMs(c.vmRegistryDir())
+        if err != nil {
+            return nil, err
+        }
+        for _, record := range records {
+            if record.Host != "" {
+                hosts[record.ID] = record.Host
+            }
+        }
+    }
+    if c.hostLoads != nil {
+        for id, host := range c.hostLoads.placed {
+            hosts[id] = host
+        }
+    }
+
+    loads := map[string]int{}
+    for id, host := range hosts {
+        if id != vmID {
+            loads[host]++
+        }
+    }
+    return loads, nil
+}
+
+// matchingHosts returns the hosts whose labels match selector.
+func (c *FirecrackerClient) matchingHosts(selector map[string]string) []*hostEntry {
+    candidates := []*hostEntry{}
+    for _, host := range c.hosts {
+        if matchesSelector(host.Labels, selector) {
+            candidates = append(candidates, host)
+        }
+    }
+    return candidates
+}
+
+// selectHost chooses the host a new VM is placed on among the hosts matching selector,
+// skipping the hosts in exclude and the hosts at capacity. The provider's placement
+// strategy picks among the rest by the number of VMs they run. Hosts running equally
+// many VMs are considered starting from one derived from the VM ID, so VMs spread
+// across them.
+func (c *FirecrackerClient) selectHost(vmID string, selector map[string]string, exclude map[string]bool) (*hostEntry, error) {
+    matching := c.matchingHosts(selector)
+    if len(matching) == 0 {
+        return nil, fmt.Errorf("no host matches placement selector %s", formatSelector(selector))
+    }
+
+    candidates := []*hostEntry{}
+    for _, host := range matching {
+        if !exclude[host.Name] {
+            candidates = append(candidates, host)
+        }
+    }
+
+    if len(candidates) == 0 {
+        return nil, fmt.Errorf("all %d hosts matching placement selector %s are already used by the placement group", len(matching), formatSelector(selector))
+    }
+
+    // Holding the lock from counting to recording keeps concurrent creates from
+    // overcommitting a host
+    if c.hostLoads != nil {
+        c.hostLoads.mu.Lock()
+        defer c.hostLoads.mu.Unlock()
+    }
+    loads, err := c.countHostLoads(vmID)
+    if err != nil {
+        return nil, err
+    }
+
+    h := fnv.New32a()
+    h.Write([]byte(vmID))
+    start := int(h.Sum32() % uint32(len(candidates)))
+
+    var chosen *hostEntry
+    for i := range candidates {
+        host := candidates[(start+i)%len(candidates)]
+        if host.Capacity > 0 && loads[host.Name] >= host.Capacity {
+            continue
+        }
+        if chosen == nil || c.prefersHost(loads[host.Name], loads[chosen.Name]) {
+            chosen = host
+        }
+    }
+    if chosen == nil {
+        return nil, fmt.Errorf("all %d hosts matching placement selector %s are at capacity", len(candidates), formatSelector(selector))
+    }
+
+    if c.hostLoads != nil {
+        c.hostLoads.placed[vmID] = chosen.Name
+    }
+    return chosen, nil
+}
+
+// prefersHost reports whether the placement strategy prefers a host running load VMs over
+// one running current VMs.
+func (c *FirecrackerClient) prefersHost(load, current int) bool {
+    if c.placementStrategy == placementStrategyBinpack {
+        return load > current
+    }
+    return load < current
+}
+
+// hostByName returns the pooled host with the given name, or nil.
+func (c *FirecrackerClient) hostByName(name string) *hostEntry {
+    for _, host := range c.hosts {
+        if host.Name == name {
+            return host
+        }
+    }
+    return nil
+}
+
+// placeOnHost places a new VM on the named host, as configured in the VM's host argument.
+func (c *FirecrackerClient) placeOnHost(vmID, name string) (*hostEntry, error) {
+    host := c.hostByName(name)
+    if host == nil {
+        return nil, fmt.Errorf("host %q is not configured in the provider", name)
+    }
+
+    if c.hostLoads != nil {
+        c.hostLoads.mu.Lock()
+        defer c.hostLoads.mu.Unlock()
+    }
+    loads, err := c.countHostLoads(vmID)
+    if err != nil {
+        return nil, err
+    }
+    if host.Capacity > 0 && loads[host.Name] >= host.Capacity {
+        return nil, fmt.Errorf("host %q is at its capacity of %d VMs", name, host.Capacity)
+    }
+
+    if c.hostLoads != nil {
+        c.hostLoads.placed[vmID] = host.Name
+    }
+    return host, nil
+}
+
+// clientForHost returns the client for the named host. An empty name refers to the
+// provider's base_url, which is used when no host pool is configured.
+func (c *FirecrackerClient) clientForHost(name string) (*FirecrackerClient, error) {
+    if name == "" {
+        if c.BaseURL == "" {
+            return nil, fmt.Errorf("no host recorded for VM and the provider has no base_url")
+        }
+        return c, nil
+    }
+
+    for _, host := range c.hosts {
+        if host.Name == name {
+            return host.Client, nil
+        }
+    }
+
+    return nil, fmt.Errorf("host %q is not configured in the provider", name)
+}
+
+// clientForBaseURL returns a client for another Firecracker API, sharing the provider's
+// HTTP client, retry policy, request limits and headers.
+func (c *FirecrackerClient) clientForBaseURL(baseURL string) (*FirecrackerClient, error) {
+    client := &FirecrackerClient{
+        BaseURL:    baseURL,
+        HTTPClient: c.HTTPClient,
+        Timeout:    c.Timeout,
+        StateDir:   c.StateDir,
+
+        TolerateUnreachableHosts: c.TolerateUnreachableHosts,
+        ReadOnly:                 c.ReadOnly,
+
+        mock: c.mock,
+
+        retry:    c.retry,
+        requests: c.requests,
+        headers:  c.headers,
+
+        experiments: c.experiments,
+    }
+    if isFakeAPIURL(baseURL) || c.mock {
+        fake, err := fakeAPIClient(mockAPIURL(baseURL, mockAPIDefaultName), c.StateDir)
+        if err != nil {
+            return nil, err
+        }
+        client.HTTPClient = fake
+    }
+    return client, nil
+}
+
+// vmClient returns the client for the host a VM resource is placed on.
+func vmClient(d *schema.ResourceData, m interface{}) (*FirecrackerClient, error) {
+    host, _ := d.Get("host").(string)
+    return m.(*FirecrackerClient).clientForHost(host)
+}
+
+// clientForVM returns the client for the host a VM runs on, for resources referring to the
+// VM by ID. The host is looked up in the registry when it is not given.
+func (c *FirecrackerClient) clientForVM(vmID, host string) (*FirecrackerClient, error) {
+    if host == "" {
+        record, err := lookupVMRecord(c.vmRegistryDir(), vmID)
+        if err != nil {
+            return nil, err
+        }
+        if record != nil {
+            host = record.Host
+        }
+    }
+    return c.clientForHost(host)
+}
+
+// expandLabels converts a schema map into a map of strings.
+func expandLabels(raw map[string]interface{}) map[string]string {
+    labels := make(map[string]string, len(raw))
+    for key, value := range raw {
+        labels[key] = value.(string)
+    }
+    return labels
+}
+
+// formatSelector renders a selector as sorted key=value pairs for error messages.
+func formatSelector(selector map[string]string) string {
+    pairs := make([]string, 0, len(selector))
+    for key, value := range selector {
+        pairs = append(pairs, key+"="+value)
+    }
+    sort.Strings(pairs)
+    return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// placementGroups tracks which hosts the members of each placement group run on.
+// Resources do not see each other, so the provider records members as they are
+// planned, created, and refreshed within a single Terraform run.
+type placementGroups struct {
+    mu      sync.Mutex
+    members map[string]map[string]string // group -> member key -> host
+}
+
+func newPlacementGroups() *placementGroups {
+    return &placementGroups{members: map[string]map[string]string{}}
+}
+
+// record registers a member of a group. The host may be empty for members not placed yet.
+func (g *placementGroups) record(group, key, host string) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    g.recordLocked(group, key, host)
+}
+
+func (g *placementGroups) recordLocked(group, key, host string) {
+    if g.members[group] == nil {
+        g.members[group] = map[string]string{}
+    }
+    g.members[group][key] = host
+}
+
+// plannedMemberKey returns the key of a group member that has no ID yet. Terraform plans a
+// VM again before creating it, so, like plannedSharedDriveKey, the key is derived from its
+// configuration: its name, or its drives when it has none. Members without a name attaching
+// the same drives cannot be told apart until they are placed.
+func plannedMemberKey(get func(string) interface{}) string {
+    if name, _ := get("name").(string); name != "" {
+        return "planned:" + name
+    }
+    paths := []string{}
+    for _, raw := range get("drives").([]interface{}) {
+        if drive, ok := raw.(map[string]interface{}); ok {
+            path, _ := drive["path_on_host"].(string)
+            paths = append(paths, path)
+        }
+    }
+    return "planned:" + strings.Join(paths, ",")
+}
+
+// recordPlanned registers a member that has no ID yet under its plannedMemberKey and returns
+// the group size.
+func (g *placementGroups) recordPlanned(group, key string) int {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    g.recordLocked(group, key, "")
+    return len(g.members[group])
+}
+
+// size returns the number of known members of a group.
+func (g *placementGroups) size(group string) int {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    return len(g.members[group])
+}
+
+// remove unregisters a member from a group.
+func (g *placementGroups) remove(group, key string) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    delete(g.members[group], key)
+}
+
+// place selects a host for a new group member that no other member uses, and records it
+// under its ID instead of the plannedKey it was planned with. Holding the lock across
+// selection keeps concurrent creates from picking the same host.
+func (g *placementGroups) place(c *FirecrackerClient, group, vmID, plannedKey string, selector map[string]string) (*hostEntry, error) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+
+    used := map[string]bool{}
+    for key, host := range g.members[group] {
+        if key != vmID && host != "" {
+            used[host] = true
+        }
+    }
+
+    host, err := c.selectHost(vmID, selector, used)
+    if err != nil {
+        return nil, fmt.Errorf("cannot satisfy anti-affinity for placement group %q: %w", group, err)
+    }
+
+    delete(g.members[group], plannedKey)
+    g.recordLocked(group, vmID, host.Name)
+    return host, nil
+}
@@ -0,0 +1,289 @@
+package firecracker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/avkcode/terraform-provider-firecracker/firecrackertest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func testHostPool() *FirecrackerClient {
+	return &FirecrackerClient{
+		hosts: []*hostEntry{
+			{Name: "edge-1", Labels: map[string]string{"zone": "rack1", "gpu": "false"}, Client: &FirecrackerClient{BaseURL: "http://edge-1:8080"}},
+			{Name: "edge-2", Labels: map[string]string{"zone": "rack2", "gpu": "false"}, Client: &FirecrackerClient{BaseURL: "http://edge-2:8080"}},
+			{Name: "gpu-1", Labels: map[string]string{"zone": "rack1", "gpu": "true"}, Client: &FirecrackerClient{BaseURL: "http://gpu-1:8080"}},
+		},
+	}
+}
+
+func TestSelectHost_selector(t *testing.T) {
+	pool := testHostPool()
+
+	host, err := pool.selectHost("vm-1", map[string]string{"gpu": "true"}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if host.Name != "gpu-1" {
+		t.Errorf("Expected gpu-1, got %s", host.Name)
+	}
+
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		host, err := pool.selectHost(id, map[string]string{"zone": "rack1", "gpu": "false"}, nil)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if host.Name != "edge-1" {
+			t.Errorf("Expected edge-1 for %s, got %s", id, host.Name)
+		}
+	}
+}
+
+func TestSelectHost_stable(t *testing.T) {
+	pool := testHostPool()
+
+	first, _ := pool.selectHost("vm-1", nil, nil)
+	second, _ := pool.selectHost("vm-1", nil, nil)
+	if first.Name != second.Name {
+		t.Errorf("Expected the same VM ID to be placed on the same host, got %s and %s", first.Name, second.Name)
+	}
+}
+
+func TestSelectHost_noMatch(t *testing.T) {
+	_, err := testHostPool().selectHost("vm-1", map[string]string{"zone": "rack9"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "zone=rack9") {
+		t.Errorf("Expected an error naming the selector, got %v", err)
+	}
+}
+
+func TestPlacementGroups_antiAffinity(t *testing.T) {
+	pool := testHostPool()
+	groups := newPlacementGroups()
+	selector := map[string]string{"gpu": "false"}
+
+	// An existing member already runs on edge-1
+	groups.record("web", "vm-existing", "edge-1")
+
+	host, err := groups.place(pool, "web", "vm-new", "planned:new", selector)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if host.Name != "edge-2" {
+		t.Errorf("Expected the new member on edge-2, got %s", host.Name)
+	}
+
+	// Both matching hosts are taken now
+	if _, err := groups.place(pool, "web", "vm-third", "planned:third", selector); err == nil {
+		t.Errorf("Expected an error when no host is left for the group")
+	}
+
+	// Removing a member frees its host
+	groups.remove("web", "vm-existing")
+	host, err = groups.place(pool, "web", "vm-third", "planned:third", selector)
+	if err != nil {
+		t.Fatalf("Expected no error after removing a member, got %v", err)
+	}
+	if host.Name != "edge-1" {
+		t.Errorf("Expected the freed host edge-1, got %s", host.Name)
+	}
+}
+
+func TestPlacementGroups_planned(t *testing.T) {
+	pool := testHostPool()
+	groups := newPlacementGroups()
+	selector := map[string]string{"gpu": "false"}
+	member := func(name string) func(string) interface{} {
+		return func(key string) interface{} {
+			if key == "name" {
+				return name
+			}
+			return []interface{}{}
+		}
+	}
+
+	// Terraform plans each member again before creating it
+	for i := 0; i < 2; i++ {
+		groups.recordPlanned("web", plannedMemberKey(member("web-0")))
+		groups.recordPlanned("web", plannedMemberKey(member("web-1")))
+	}
+	if size := groups.size("web"); size != 2 {
+		t.Fatalf("Expected 2 members, got %d", size)
+	}
+
+	// Placed members replace their planned entries
+	if _, err := groups.place(pool, "web", "vm-0", plannedMemberKey(member("web-0")), selector); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if size := groups.recordPlanned("web", plannedMemberKey(member("web-1"))); size != 2 {
+		t.Errorf("Expected 2 members after placing one, got %d", size)
+	}
+	if _, err := groups.place(pool, "web", "vm-1", plannedMemberKey(member("web-1")), selector); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if size := groups.size("web"); size != 2 {
+		t.Errorf("Expected 2 members once all are placed, got %d", size)
+	}
+}
+
+func TestClientForHost(t *testing.T) {
+	pool := testHostPool()
+	pool.BaseURL = "http://localhost:8080"
+
+	client, err := pool.clientForHost("edge-2")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if client.BaseURL != "http://edge-2:8080" {
+		t.Errorf("Expected edge-2 client, got %s", client.BaseURL)
+	}
+
+	client, err = pool.clientForHost("")
+	if err != nil || client != pool {
+		t.Errorf("Expected the provider client for an empty host, got %v, %v", client, err)
+	}
+
+	if _, err := pool.clientForHost("missing"); err == nil {
+		t.Errorf("Expected an error for an unknown host")
+	}
+}
+
+func TestSelectHost_strategies(t *testing.T) {
+	pool := testHostPool()
+	pool.hostLoads = newHostLoads()
+	pool.hostLoads.placed["vm-a"] = "edge-1"
+	pool.hostLoads.placed["vm-b"] = "edge-1"
+	pool.hostLoads.placed["vm-c"] = "edge-2"
+	selector := map[string]string{"gpu": "false"}
+
+	host, err := pool.selectHost("vm-new", selector, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if host.Name != "edge-2" {
+		t.Errorf("Expected spread to pick the least loaded edge-2, got %s", host.Name)
+	}
+	pool.hostLoads.release("vm-new")
+
+	pool.placementStrategy = placementStrategyBinpack
+	host, err = pool.selectHost("vm-new", selector, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if host.Name != "edge-1" {
+		t.Errorf("Expected binpack to pick the most loaded edge-1, got %s", host.Name)
+	}
+	if pool.hostLoads.placed["vm-new"] != "edge-1" {
+		t.Errorf("Expected the placement to be recorded, got %v", pool.hostLoads.placed)
+	}
+}
+
+func TestSelectHost_capacity(t *testing.T) {
+	pool := testHostPool()
+	pool.placementStrategy = placementStrategyBinpack
+	pool.hostLoads = newHostLoads()
+	pool.hosts[0].Capacity = 2
+	pool.hosts[1].Capacity = 1
+	selector := map[string]string{"gpu": "false"}
+
+	placed := map[string]int{}
+	for _, id := range []string{"vm-1", "vm-2", "vm-3"} {
+		host, err := pool.selectHost(id, selector, nil)
+		if err != nil {
+			t.Fatalf("Expected %s to be placed, got %v", id, err)
+		}
+		placed[host.Name]++
+	}
+	if placed["edge-1"] != 2 || placed["edge-2"] != 1 {
+		t.Errorf("Expected hosts filled to capacity, got %v", placed)
+	}
+
+	_, err := pool.selectHost("vm-4", selector, nil)
+	if err == nil || !strings.Contains(err.Error(), "at capacity") {
+		t.Errorf("Expected an error when all hosts are at capacity, got %v", err)
+	}
+
+	// Deleting a VM frees its slot
+	pool.hostLoads.release("vm-1")
+	if _, err := pool.selectHost("vm-4", selector, nil); err != nil {
+		t.Errorf("Expected a freed slot to be used, got %v", err)
+	}
+}
+
+func TestSelectHost_registryLoads(t *testing.T) {
+	pool := testHostPool()
+	pool.StateDir = t.TempDir()
+	for id, host := range map[string]string{"vm-a": "edge-1", "vm-b": "edge-1"} {
+		if err := ensureVMRegistered(pool.vmRegistryDir(), vmRecord{ID: id, Host: host, BaseURL: "http://" + host + ":8080"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	host, err := pool.selectHost("vm-new", map[string]string{"gpu": "false"}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if host.Name != "edge-2" {
+		t.Errorf("Expected the VMs in the registry to count, got %s", host.Name)
+	}
+}
+
+func TestPlaceOnHost(t *testing.T) {
+	pool := testHostPool()
+	pool.hostLoads = newHostLoads()
+	pool.hosts[2].Capacity = 1
+
+	host, err := pool.placeOnHost("vm-1", "gpu-1")
+	if err != nil || host.Name != "gpu-1" {
+		t.Fatalf("Expected gpu-1, got %v, %v", host, err)
+	}
+	if _, err := pool.placeOnHost("vm-2", "gpu-1"); err == nil || !strings.Contains(err.Error(), "capacity of 1") {
+		t.Errorf("Expected an error for a full host, got %v", err)
+	}
+	if _, err := pool.placeOnHost("vm-2", "missing"); err == nil {
+		t.Errorf("Expected an error for an unknown host")
+	}
+}
+
+func TestResourceFirecrackerVMCreate_pooledHost(t *testing.T) {
+	ctx := context.Background()
+	server := firecrackertest.NewServer(t)
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"state_dir": t.TempDir(),
+		"host": []interface{}{
+			map[string]interface{}{"name": "node-1", "base_url": server.URL},
+		},
+	})
+	raw, diags := configureProvider(ctx, d)
+	if diags.HasError() {
+		t.Fatalf("Failed to configure provider: %v", diags)
+	}
+	provider := raw.(*FirecrackerClient)
+
+	// The kernel and drive images are on the host, not on the one running Terraform
+	config := map[string]interface{}{
+		"kernel_image_path": "/srv/images/vmlinux",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/srv/images/rootfs.ext4", "is_root_device": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+		},
+	}
+	if _, err := resourceFirecrackerVM().Diff(ctx, nil, terraform.NewResourceConfigRaw(config), provider); err != nil {
+		t.Fatalf("Expected the files not to be checked at plan time, got %v", err)
+	}
+	vm := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config)
+	if diags := resourceFirecrackerVMCreate(ctx, vm, provider); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+	if vm.Get("host") != "node-1" || server.State() != firecrackertest.StateRunning {
+		t.Errorf("Expected the VM to be started on node-1, got host %v, state %s", vm.Get("host"), server.State())
+	}
+	if bootSource, _ := server.Config()["boot-source"].(map[string]interface{}); bootSource["kernel_image_path"] != "/srv/images/vmlinux" {
+		t.Errorf("Expected the kernel on the host to be booted, got %v", server.Config()["boot-source"])
+	}
+}
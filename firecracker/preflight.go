@@ -0,0 +1,90 @@
+package firecracker
+
+import (
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// checkHostFiles verifies at plan time that the kernel, initrd and drive images of a VM
+// running on the host running Terraform exist and can be read, so a missing or unreadable
+// file is reported with the attribute naming it instead of as an API error when the VM
+// starts. Only files of new VMs and changed paths are checked: a running VM keeps its files
+// open, so removing them later does not break it. Paths not known until apply are skipped,
+// and the paths of a jailed VM whose files are not staged are looked up in its chroot.
+func checkHostFiles(d *schema.ResourceDiff) error {
+    // Paths of jailed VMs are in the chroot, which is only known once the jailer ID is.
+    // Staged files are checked where they are staged from.
+    jailer, jailed := jailerSpecFromConfig(d.Get("jailer"), d.Id())
+    if jailed && !d.NewValueKnown("jailer") {
+        return nil
+    }
+    jailed = jailed && !jailer.StageFiles
+    if jailed && jailer.ID == "" {
+        return nil
+    }
+
+    check := func(key, attr string) error {
+        if !d.NewValueKnown(key) || (d.Id() != "" && !d.HasChange(key)) {
+            return nil
+        }
+        path, _ := d.Get(key).(string)
+        if path == "" {
+            return nil
+        }
+        if jailed {
+            path = jailer.hostPath(path)
+        }
+        return checkReadableFile(attr, path)
+    }
+
+    if d.NewValueKnown("config_json") && d.HasChange("config_json") {
+        if err := checkConfigFileFiles(d.Get("config_json").(string)); err != nil {
+            return err
+        }
+    }
+    if err := check("kernel_image_path", "kernel_image_path"); err != nil {
+        return err
+    }
+    if err := check("initrd_path", "initrd_path"); err != nil {
+        return err
+    }
+    for i, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        // Drives restored from a backup are created before the VM boots
+        if backup, _ := drive["restore_from"].(string); !ok || backup != "" {
+            continue
+        }
+        if err := check(fmt.Sprintf("drives.%d.path_on_host", i), fmt.Sprintf("path_on_host of drive %s", drive["drive_id"])); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// checkReadableFile returns an error explaining what to do when path, named by attr, is not
+// a regular file the provider can read.
+func checkReadableFile(attr, path string) error {
+    f, err := os.Open(path)
+    switch {
+    case errors.Is(err, fs.ErrNotExist):
+        return fmt.Errorf("%s %s does not exist on the host running Terraform. If another resource creates it, reference that resource's attribute so the file exists before the VM is created", attr, path)
+    case errors.Is(err, fs.ErrPermission):
+        return fmt.Errorf("%s %s is not readable. Firecracker needs to read it, make it readable by the user running Firecracker and Terraform", attr, path)
+    case err != nil:
+        return fmt.Errorf("%s %s cannot be read: %w", attr, path, err)
+    }
+    defer f.Close()
+
+    info, err := f.Stat()
+    if err != nil {
+        return fmt.Errorf("%s %s cannot be read: %w", attr, path, err)
+    }
+    if !info.Mode().IsRegular() && info.Mode()&fs.ModeDevice == 0 {
+        return fmt.Errorf("%s %s is not a file", attr, path)
+    }
+    return nil
+}
@@ -0,0 +1,81 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestCheckHostFiles(t *testing.T) {
+	dir := t.TempDir()
+	kernel := filepath.Join(dir, "vmlinux")
+	rootfs := filepath.Join(dir, "rootfs.ext4")
+	for _, path := range []string{kernel, rootfs} {
+		if err := os.WriteFile(path, []byte("image"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := filepath.Join(dir, "missing")
+	// Values not known until apply are set to this by Terraform
+	unknown := "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+	cases := map[string]struct {
+		kernel   string
+		initrd   string
+		rootfs   string
+		provider *FirecrackerClient
+		expected string
+	}{
+		"present":         {kernel, "", rootfs, &FirecrackerClient{}, ""},
+		"missing kernel":  {missing, "", rootfs, &FirecrackerClient{}, "kernel_image_path " + missing + " does not exist"},
+		"missing initrd":  {kernel, missing, rootfs, &FirecrackerClient{}, "initrd_path " + missing + " does not exist"},
+		"missing drive":   {kernel, "", missing, &FirecrackerClient{}, "path_on_host of drive rootfs " + missing + " does not exist"},
+		"directory":       {dir, "", rootfs, &FirecrackerClient{}, "is not a file"},
+		"unknown":         {unknown, "", unknown, &FirecrackerClient{}, ""},
+		"pooled host":     {missing, "", missing, &FirecrackerClient{hosts: []*hostEntry{{Name: "edge-1"}}}, ""},
+		"no provider yet": {missing, "", missing, nil, ""},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"kernel_image_path": tc.kernel,
+				"drives": []interface{}{
+					map[string]interface{}{"drive_id": "rootfs", "path_on_host": tc.rootfs, "is_root_device": true},
+				},
+				"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+			}
+			if tc.initrd != "" {
+				raw["initrd_path"] = tc.initrd
+			}
+			var m interface{}
+			if tc.provider != nil {
+				m = tc.provider
+			}
+			_, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), m)
+			if tc.expected == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tc.expected != "" && (err == nil || !strings.Contains(err.Error(), tc.expected)) {
+				t.Errorf("Expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestCheckReadableFile_permission(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("File permissions do not apply to root")
+	}
+	path := filepath.Join(t.TempDir(), "vmlinux")
+	if err := os.WriteFile(path, []byte("kernel"), 0o000); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkReadableFile("kernel_image_path", path); err == nil || !strings.Contains(err.Error(), "is not readable") {
+		t.Errorf("Expected a permission error, got %v", err)
+	}
+}
@@ -2,12 +2,17 @@ package firecracker
 
 import (
     "context"
+    "fmt"
     "net/http"
+    "os"
+    "path/filepath"
+    "sort"
     "time"
  
     "github.com/hashicorp/terraform-plugin-log/tflog"
     "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
     "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // FirecrackerClient represents the client for interacting with the Firecracker API.
@@ -16,6 +21,72 @@ type FirecrackerClient struct {
     BaseURL    string
     HTTPClient httpClient
     Timeout    time.Duration
+
+    // StateDir is the directory where the provider keeps local state such as IPAM allocations.
+    StateDir string
+
+    // VMRegistryDir is the directory of the VM registry. Empty means the vms directory in StateDir.
+    VMRegistryDir string
+
+    // TolerateUnreachableHosts keeps prior state with a warning when refresh cannot reach the API.
+    TolerateUnreachableHosts bool
+
+    // ReadOnly rejects requests changing the API and creating, updating or destroying
+    // resources, for auditing hosts without risk of changing them.
+    ReadOnly bool
+
+    // mock is set in mock mode, where every Firecracker API is simulated by a fake API and
+    // checks of files on the Firecracker host are skipped.
+    mock bool
+
+    // bootThrottle limits how quickly VMs are started; nil means unlimited.
+    bootThrottle *bootThrottle
+
+    // retry says how requests the API could not be reached for are sent again; nil means
+    // they are not.
+    retry *retryPolicy
+
+    // requests serializes the requests to each API endpoint and limits how many are in
+    // flight; nil means requests are sent as they come.
+    requests *requestLimiter
+
+    // headers are sent with every request, such as the credentials of a proxy in front of
+    // the API.
+    headers http.Header
+
+    // allowedPaths are the globs the host files VMs use must match; empty means any file.
+    allowedPaths []string
+
+    // hosts is the pool of Firecracker hosts VMs can be placed on; empty means single-host mode.
+    hosts []*hostEntry
+
+    // placementStrategy chooses among the hosts a VM may be placed on: spread or binpack.
+    placementStrategy string
+
+    // hostLoads tracks the hosts VMs are placed on during this run.
+    hostLoads *hostLoads
+
+    // placementGroups tracks placement group members across resources.
+    placementGroups *placementGroups
+
+    // sharedDrives tracks the drive images attached by VMs across resources.
+    sharedDrives *sharedDrives
+
+    // experiments holds the experimental features enabled in the provider configuration.
+    experiments map[string]bool
+
+    // secrets are the sources secret:// references in MMDS contents and user data are
+    // resolved from.
+    secrets secretSources
+
+    // httpDump records the exchanges with the API for bug reports; nil means they are not.
+    httpDump *httpDump
+
+    // auditLog records the changes the provider makes; nil means they are not.
+    auditLog *auditLog
+
+    // asyncCreates provisions the VMs created with async.
+    asyncCreates *asyncCreates
 }
 
 // Provider returns a *schema.Provider for Firecracker.
@@ -25,51 +96,568 @@ func Provider() *schema.Provider {
         Schema: map[string]*schema.Schema{
             "base_url": {
                 Type:        schema.TypeString,
-                Required:    true,
-                Description: "The base URL for the Firecracker API.",
+                Optional:    true,
+                DefaultFunc: schema.EnvDefaultFunc("FIRECRACKER_BASE_URL", nil),
+                Description: "The base URL for the Firecracker API. Required unless `socket` or `host` blocks are configured or `mode` is `mock`. A `fake://<name>` URL serves a fake API inside the provider for `terraform test`. Can also be set with the FIRECRACKER_BASE_URL environment variable.",
+            },
+            "socket": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                DefaultFunc: schema.EnvDefaultFunc("FIRECRACKER_SOCKET", nil),
+                Description: "Path of the Unix socket the Firecracker API is served on. Requests are sent over the socket, to base_url if set. Cannot be used with `host` blocks. Can also be set with the FIRECRACKER_SOCKET environment variable.",
+            },
+            "mode": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                Default:      providerModeAPI,
+                Description:  "How the provider talks to Firecracker: `api` sends requests to the Firecracker APIs, `mock` simulates every Firecracker API inside the provider, for running plans, applies and tests on machines without virtualization support.",
+                ValidateFunc: validation.StringInSlice([]string{providerModeAPI, providerModeMock}, false),
+            },
+            "state_dir": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                DefaultFunc: defaultStateDir,
+                Description: "Directory where the provider keeps local state such as IP address allocations. Defaults to ~/.terraform.d/firecracker.",
+            },
+            "vm_registry_dir": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "Directory of the registry recording the configuration and Firecracker process of every VM the provider creates. Defaults to the vms directory in state_dir.",
+            },
+            "host": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                Description: "Pool of Firecracker hosts VMs can be placed on. When set, each VM is scheduled onto one of these hosts.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "name": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            Description:  "Unique name of the host. It is recorded in the state of VMs placed on it.",
+                            ValidateFunc: validation.StringIsNotEmpty,
+                        },
+                        "base_url": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            Description:  "The base URL for the Firecracker API on this host.",
+                            ValidateFunc: validation.StringIsNotEmpty,
+                        },
+                        "labels": {
+                            Type:        schema.TypeMap,
+                            Optional:    true,
+                            Description: "Labels describing the host (e.g., zone = \"rack1\"), matched against VM placement selectors.",
+                            Elem:        &schema.Schema{Type: schema.TypeString},
+                        },
+                        "ssh_host": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "Address files are copied to and from the host over SSH, such as when VMs are moved between hosts. Defaults to the host of base_url.",
+                        },
+                        "capacity": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            Default:      0,
+                            Description:  "Largest number of VMs placed on the host. 0 means unlimited.",
+                            ValidateFunc: validation.IntAtLeast(0),
+                        },
+                    },
+                },
+            },
+            "placement_strategy": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                Default:      placementStrategySpread,
+                Description:  "How VMs are placed among the hosts matching their placement: spread places a VM on the host running the fewest VMs, binpack on the host running the most VMs that has capacity left.",
+                ValidateFunc: validation.StringInSlice([]string{placementStrategySpread, placementStrategyBinpack}, false),
             },
             "timeout": {
                 Type:        schema.TypeInt,
                 Optional:    true,
-                Default:     30,
-                Description: "Timeout in seconds for API operations.",
+                DefaultFunc: schema.EnvDefaultFunc("FIRECRACKER_TIMEOUT", 30),
+                Description: "Timeout in seconds for API operations. Can also be set with the FIRECRACKER_TIMEOUT environment variable.",
+            },
+            "max_boots_per_minute": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                Default:      0,
+                Description:  "Maximum number of VM boots per minute. Boots are spaced evenly and excess creations wait in a queue. 0 disables the limit.",
+                ValidateFunc: validation.IntAtLeast(0),
+            },
+            "max_concurrent_requests": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                Default:      0,
+                Description:  "Maximum number of requests in flight at once, across the Firecracker APIs of all hosts and VMs. 0 disables the limit.",
+                ValidateFunc: validation.IntAtLeast(0),
+            },
+            "debug": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "Settings for debugging the provider, such as recording its exchanges with the Firecracker APIs.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "dump_http_to": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "Directory each run of the provider records its requests to the Firecracker APIs and their responses in, as a file of JSON lines. Credentials, MMDS contents and sensitive kernel parameters are redacted.",
+                        },
+                    },
+                },
+            },
+            "retry": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "How requests are sent again when the Firecracker API cannot be reached, such as when its socket is not listening yet. Requests the API rejects are never sent again.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "max_attempts": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            Default:      defaultRetryMaxAttempts,
+                            Description:  "Number of attempts at sending a request, including the first. 1 disables retries.",
+                            ValidateFunc: validation.IntAtLeast(1),
+                        },
+                        "min_backoff": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Default:      defaultRetryMinBackoff,
+                            Description:  "Wait before the first retry, doubled for every further retry.",
+                            ValidateFunc: validateDuration,
+                        },
+                        "max_backoff": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Default:      defaultRetryMaxBackoff,
+                            Description:  "Longest wait between two attempts.",
+                            ValidateFunc: validateDuration,
+                        },
+                    },
+                },
+            },
+            "transport": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "How connections to the Firecracker APIs are made and reused. Unix socket servers such as Firecracker may close idle connections differently from HTTP servers, which can make reused connections fail.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "keep_alive": {
+                            Type:        schema.TypeBool,
+                            Optional:    true,
+                            Default:     true,
+                            Description: "Reuse connections for further requests. When false, every request opens a connection of its own.",
+                        },
+                        "max_idle_conns": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            Default:      defaultMaxIdleConns,
+                            Description:  "Maximum number of idle connections kept open across all APIs. 0 means no limit.",
+                            ValidateFunc: validation.IntAtLeast(0),
+                        },
+                        "max_idle_conns_per_host": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            Default:      defaultMaxIdleConnsPerHost,
+                            Description:  "Maximum number of idle connections kept open to each API.",
+                            ValidateFunc: validation.IntAtLeast(1),
+                        },
+                        "idle_conn_timeout": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Default:      defaultIdleConnTimeout,
+                            Description:  "How long an idle connection is kept open before it is closed.",
+                            ValidateFunc: validateDuration,
+                        },
+                        "dial_timeout": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Description:  "Longest wait for a connection to an API to be made. Without it, connecting is only bounded by timeout.",
+                            ValidateFunc: validateDuration,
+                        },
+                    },
+                },
+            },
+            "tls": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "TLS settings for Firecracker APIs served over HTTPS, such as by a REST proxy in front of the API sockets.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "ca_file": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "PEM file of the certificate authorities the API's certificate is verified against, instead of the system's.",
+                        },
+                        "cert_file": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "PEM file of the client certificate presented to the API, for mutual TLS. Requires key_file.",
+                        },
+                        "key_file": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "PEM file of the private key of cert_file.",
+                        },
+                        "insecure_skip_verify": {
+                            Type:        schema.TypeBool,
+                            Optional:    true,
+                            Default:     false,
+                            Description: "Skip verifying the API's certificate. Only meant for testing.",
+                        },
+                    },
+                },
+            },
+            "headers": {
+                Type:        schema.TypeMap,
+                Optional:    true,
+                Sensitive:   true,
+                Description: "HTTP headers sent with every request to the Firecracker APIs, such as static credentials of a proxy in front of them.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "bearer_token": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Sensitive:   true,
+                DefaultFunc: schema.EnvDefaultFunc("FIRECRACKER_BEARER_TOKEN", ""),
+                Description: "Token sent as `Authorization: Bearer <token>` with every request to the Firecracker APIs. Can also be set with the FIRECRACKER_BEARER_TOKEN environment variable.",
+            },
+            "tolerate_unreachable_hosts": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                Default:     false,
+                Description: "When true, refreshing a VM whose Firecracker API cannot be reached keeps its prior state and reports a warning instead of failing the plan.",
+            },
+            "allowed_paths": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                Description: "Absolute globs the host files VMs use must match, such as /srv/images/*.ext4, or /srv/tenants/** for everything under a directory. Kernel, initrd, drive and snapshot paths outside them fail the plan. Unset allows any path.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "async_workers": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                Default:      defaultAsyncWorkers,
+                Description:  "Number of firecracker_vm resources with async = true the provider configures and boots at a time. Others wait for a free worker.",
+                ValidateFunc: validation.IntAtLeast(1),
+            },
+            "audit_log": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Description: "File every create, update and destroy the provider performs is appended to as a line of JSON, recording who ran it, when, on which resource, and the requests it sent to the Firecracker APIs. Credentials, MMDS contents and sensitive kernel parameters are redacted. Created with mode 0600 if missing; never truncated.",
+            },
+            "read_only": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                Default:     false,
+                Description: "When true, the provider changes nothing: creating, updating and destroying resources fails, refreshing does not heal or relaunch VMs, and requests that would change a Firecracker API are rejected. For auditing production hosts with plans and refreshes.",
+            },
+            "validate_on_configure": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                Default:     false,
+                Description: "When true, the provider requests the instance information of base_url and every host when it is configured, and fails if any of them cannot be reached.",
+            },
+            "experiments": {
+                Type:        schema.TypeSet,
+                Optional:    true,
+                Description: "Experimental features to enable, such as warm_pools, migration or containerd_backend. Experimental features may change in future releases.",
+                Elem: &schema.Schema{
+                    Type:         schema.TypeString,
+                    ValidateFunc: validation.StringInSlice(experimentalFeatureNames(), false),
+                },
+            },
+            "secret_source": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                Description: "Sources that `secret://<name>/<key>` references in VM MMDS contents and cloud-init data are resolved from when VMs are created, so secrets never appear in the configuration or state.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "name": {
+                            Type:        schema.TypeString,
+                            Required:    true,
+                            Description: "Name references use to select the source, as in secret://<name>/<key>.",
+                        },
+                        "type": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            Description:  "Where secrets are fetched from: exec runs command with the key as its last argument, vault reads a field of a KV secret with the vault CLI, and ssm reads an SSM parameter with the aws CLI.",
+                            ValidateFunc: validation.StringInSlice([]string{secretSourceExec, secretSourceVault, secretSourceSSM}, false),
+                        },
+                        "command": {
+                            Type:        schema.TypeList,
+                            Optional:    true,
+                            Description: "Command printing the secret whose key is appended to it, for exec sources.",
+                            Elem:        &schema.Schema{Type: schema.TypeString},
+                        },
+                    },
+                },
             },
         },
         ResourcesMap: map[string]*schema.Resource{
-            "firecracker_vm": resourceFirecrackerVM(),
+            "firecracker_vm":                resourceFirecrackerVM(),
+            "firecracker_tap":               resourceFirecrackerTap(),
+            "firecracker_bridge":            resourceFirecrackerBridge(),
+            "firecracker_network":           resourceFirecrackerNetwork(),
+            "firecracker_ip_allocation":     resourceFirecrackerIPAllocation(),
+            "firecracker_rootfs":            resourceFirecrackerRootfs(),
+            "firecracker_overlay_drive":     resourceFirecrackerOverlayDrive(),
+            "firecracker_test_image":        resourceFirecrackerTestImage(),
+            "firecracker_drive_backup":      resourceFirecrackerDriveBackup(),
+            "firecracker_wait":              resourceFirecrackerWait(),
+            "firecracker_vm_clone":          resourceFirecrackerVMClone(),
+            "firecracker_network_interface": resourceFirecrackerNetworkInterface(),
+            "firecracker_drive":             resourceFirecrackerDrive(),
+            "firecracker_mmds_contents":     resourceFirecrackerMMDSContents(),
         },
         DataSourcesMap: map[string]*schema.Resource{
-            "firecracker_vm": dataSourceFirecrackerVM(),
+            "firecracker_vm":              dataSourceFirecrackerVM(),
+            "firecracker_memory_report":   dataSourceFirecrackerMemoryReport(),
+            "firecracker_interface_stats": dataSourceFirecrackerInterfaceStats(),
+            "firecracker_image":           dataSourceFirecrackerImage(),
+            "firecracker_version":         dataSourceFirecrackerVersion(),
+            "firecracker_balloon_stats":   dataSourceFirecrackerBalloonStats(),
+            "firecracker_host":            dataSourceFirecrackerHost(),
+            "firecracker_vms":             dataSourceFirecrackerVMs(),
+            "firecracker_vm_status":       dataSourceFirecrackerVMStatus(),
         },
         ConfigureContextFunc: configureProvider,
     }
+    for name, r := range p.ResourcesMap {
+        auditResource(name, r)
+        guardReadOnly(name, r)
+        traceResource(name, r)
+    }
+    for name, r := range p.DataSourcesMap {
+        traceResource(name, r)
+    }
     
     return p
 }
 
+// defaultStateDir returns the default directory for provider-local state.
+func defaultStateDir() (interface{}, error) {
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return nil, fmt.Errorf("failed to determine home directory for state_dir: %w", err)
+    }
+    return filepath.Join(home, ".terraform.d", "firecracker"), nil
+}
+
+// connectivityErrors returns an error for base_url and each host whose Firecracker API does
+// not answer a request for its instance information.
+func connectivityErrors(ctx context.Context, client *FirecrackerClient) diag.Diagnostics {
+    clients := map[string]*FirecrackerClient{}
+    if client.BaseURL != "" {
+        clients["base_url"] = client
+    }
+    for _, host := range client.hosts {
+        clients[fmt.Sprintf("host %q", host.Name)] = host.Client
+    }
+
+    names := make([]string, 0, len(clients))
+    for name := range clients {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    var diags diag.Diagnostics
+    for _, name := range names {
+        c := clients[name]
+        if _, err := c.api().InstanceInfo(ctx); err != nil {
+            diags = append(diags, diag.Diagnostic{
+                Severity: diag.Error,
+                Summary:  "Firecracker API unreachable",
+                Detail:   fmt.Sprintf("The Firecracker API of %s at %s did not answer: %s. Check that Firecracker is running and that the provider can reach its API, or set validate_on_configure to false.", name, c.BaseURL, err),
+            })
+        }
+    }
+    return diags
+}
+
 // configureProvider initializes the FirecrackerClient with the provided configuration.
 // It creates an HTTP client with appropriate timeouts and connection settings.
 func configureProvider(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
     baseURL := d.Get("base_url").(string)
+    socket := d.Get("socket").(string)
+    if socket != "" {
+        if len(d.Get("host").([]interface{})) > 0 {
+            return nil, diag.FromErr(fmt.Errorf("socket cannot be used with host blocks"))
+        }
+        // The host of the URL is ignored, as requests are sent over the socket
+        if baseURL == "" {
+            baseURL = "http://localhost"
+        }
+    }
+    mock := d.Get("mode").(string) == providerModeMock
+    if mock && baseURL == "" && len(d.Get("host").([]interface{})) == 0 {
+        baseURL = fakeAPIScheme + "://" + mockAPIDefaultName
+    }
     timeout := d.Get("timeout").(int)
+    maxBootsPerMinute := d.Get("max_boots_per_minute").(int)
+
+    experiments, err := enabledFeatures(d.Get("experiments").(*schema.Set).List())
+    if err != nil {
+        return nil, diag.FromErr(err)
+    }
+    secrets, err := secretSourcesFromConfig(d.Get("secret_source").([]interface{}))
+    if err != nil {
+        return nil, diag.FromErr(err)
+    }
+    retry, err := retryPolicyFromConfig(d.Get("retry").([]interface{}))
+    if err != nil {
+        return nil, diag.FromErr(err)
+    }
+    tlsConfig, err := tlsConfigFromConfig(d.Get("tls").([]interface{}))
+    if err != nil {
+        return nil, diag.FromErr(err)
+    }
+    transport, err := transportFromConfig(d.Get("transport").([]interface{}), tlsConfig, socket)
+    if err != nil {
+        return nil, diag.FromErr(err)
+    }
+    headers, err := requestHeadersFromConfig(d.Get("headers").(map[string]interface{}), d.Get("bearer_token").(string))
+    if err != nil {
+        return nil, diag.FromErr(err)
+    }
+    allowedPaths, err := allowedPathsFromConfig(d.Get("allowed_paths").([]interface{}))
+    if err != nil {
+        return nil, diag.FromErr(err)
+    }
+    auditLog, err := openAuditLog(d.Get("audit_log").(string))
+    if err != nil {
+        return nil, diag.FromErr(err)
+    }
     
     tflog.Info(ctx, "Configuring Firecracker provider", map[string]interface{}{
-        "base_url": baseURL,
-        "timeout":  timeout,
+        "base_url":                baseURL,
+        "socket":                  socket,
+        "mode":                    d.Get("mode").(string),
+        "timeout":                 timeout,
+        "max_boots_per_minute":    maxBootsPerMinute,
+        "max_concurrent_requests": d.Get("max_concurrent_requests").(int),
     })
     
     httpClient := &http.Client{
-        Timeout: time.Duration(timeout) * time.Second,
-        Transport: &http.Transport{
-            MaxIdleConns:        100,
-            MaxIdleConnsPerHost: 20,
-            IdleConnTimeout:     90 * time.Second,
-        },
+        Timeout:   time.Duration(timeout) * time.Second,
+        Transport: transport,
     }
     
-    return &FirecrackerClient{
+    client := &FirecrackerClient{
         BaseURL:    baseURL,
         HTTPClient: httpClient,
         Timeout:    time.Duration(timeout) * time.Second,
-    }, nil
+        StateDir:   d.Get("state_dir").(string),
+
+        VMRegistryDir: d.Get("vm_registry_dir").(string),
+
+        TolerateUnreachableHosts: d.Get("tolerate_unreachable_hosts").(bool),
+        ReadOnly:                 d.Get("read_only").(bool),
+
+        mock: mock,
+
+        bootThrottle: newBootThrottle(maxBootsPerMinute),
+        retry:        retry,
+        requests:     newRequestLimiter(d.Get("max_concurrent_requests").(int)),
+        headers:      headers,
+
+        allowedPaths: allowedPaths,
+
+        placementStrategy: d.Get("placement_strategy").(string),
+        hostLoads:         newHostLoads(),
+        placementGroups:   newPlacementGroups(),
+        sharedDrives:      newSharedDrives(),
+
+        experiments: experiments,
+        secrets:     secrets,
+
+        httpDump: httpDumpFromConfig(d.Get("debug").([]interface{}), headers),
+        auditLog: auditLog,
+
+        asyncCreates: newAsyncCreates(d.Get("async_workers").(int)),
+    }
+
+    // Fake APIs are served inside the provider, for testing without a hypervisor
+    if isFakeAPIURL(baseURL) || (mock && baseURL != "") {
+        fake, err := fakeAPIClient(mockAPIURL(baseURL, mockAPIDefaultName), client.StateDir)
+        if err != nil {
+            return nil, diag.FromErr(err)
+        }
+        client.HTTPClient = fake
+    }
+
+    // Each pooled host gets its own client sharing the provider settings
+    seen := map[string]bool{}
+    for _, raw := range d.Get("host").([]interface{}) {
+        host := raw.(map[string]interface{})
+        name := host["name"].(string)
+        if seen[name] {
+            return nil, diag.FromErr(fmt.Errorf("duplicate host name %q", name))
+        }
+        seen[name] = true
+
+        hostBaseURL := host["base_url"].(string)
+        hostHTTPClient := httpClient
+        if isFakeAPIURL(hostBaseURL) || mock {
+            fake, err := fakeAPIClient(mockAPIURL(hostBaseURL, name), client.StateDir)
+            if err != nil {
+                return nil, diag.FromErr(err)
+            }
+            hostHTTPClient = fake
+        }
+
+        client.hosts = append(client.hosts, &hostEntry{
+            Name:     name,
+            Labels:   expandLabels(host["labels"].(map[string]interface{})),
+            Capacity: host["capacity"].(int),
+            SSHHost:  host["ssh_host"].(string),
+            Client: &FirecrackerClient{
+                BaseURL:    hostBaseURL,
+                HTTPClient: hostHTTPClient,
+                Timeout:    client.Timeout,
+
+                TolerateUnreachableHosts: client.TolerateUnreachableHosts,
+                ReadOnly:                 client.ReadOnly,
+
+                mock: mock,
+
+                bootThrottle: newBootThrottle(maxBootsPerMinute),
+                retry:        retry,
+                requests:     client.requests,
+                headers:      headers,
+
+                experiments: experiments,
+
+                httpDump: client.httpDump,
+            },
+        })
+    }
+
+    if baseURL == "" && len(client.hosts) == 0 {
+        return nil, diag.FromErr(fmt.Errorf("either base_url, socket or at least one host block must be configured"))
+    }
+
+    diags := append(experimentsWarning(experiments), tracingWarning(setupTracing(ctx))...)
+
+    if d.Get("validate_on_configure").(bool) {
+        if errs := connectivityErrors(ctx, client); errs.HasError() {
+            return nil, append(diags, errs...)
+        }
+    }
+
+    // Abort host operations cleanly when terminated, and clean up after a run that was not
+    if client.StateDir != "" {
+        handleShutdownSignals()
+        if !client.ReadOnly {
+            recovered, err := recoverHostOperations(ctx, client.StateDir)
+            diags = append(diags, recoveredOperationsWarning(recovered, err)...)
+        }
+    }
+    if client.ReadOnly {
+        diags = append(diags, readOnlyUpkeepWarning(client)...)
+    }
+
+    return client, diags
 }
@@ -1,8 +1,16 @@
 package firecracker
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
@@ -16,6 +24,124 @@ func TestProvider_impl(t *testing.T) {
 	var _ *schema.Provider = Provider()
 }
 
+func TestProvider_environment(t *testing.T) {
+	t.Setenv("FIRECRACKER_BASE_URL", "http://firecracker.example:8080")
+	t.Setenv("FIRECRACKER_TIMEOUT", "5")
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"state_dir": t.TempDir(),
+	})
+	client, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("Failed to configure provider: %v", diags)
+	}
+	c := client.(*FirecrackerClient)
+	if c.BaseURL != "http://firecracker.example:8080" {
+		t.Errorf("Expected base_url from the environment, got %q", c.BaseURL)
+	}
+	if c.Timeout != 5*time.Second {
+		t.Errorf("Expected timeout from the environment, got %v", c.Timeout)
+	}
+
+	// Configuration takes precedence over the environment
+	d = schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"base_url":  "http://localhost:8080",
+		"timeout":   10,
+		"state_dir": t.TempDir(),
+	})
+	client, diags = configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("Failed to configure provider: %v", diags)
+	}
+	c = client.(*FirecrackerClient)
+	if c.BaseURL != "http://localhost:8080" || c.Timeout != 10*time.Second {
+		t.Errorf("Expected the configured base_url and timeout, got %q and %v", c.BaseURL, c.Timeout)
+	}
+}
+
+func TestProvider_socket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "firecracker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix sockets unavailable: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"firecracker_version": "1.10.1"}`))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	t.Setenv("FIRECRACKER_SOCKET", socket)
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"state_dir": t.TempDir(),
+	})
+	client, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("Failed to configure provider: %v", diags)
+	}
+
+	version, err := client.(*FirecrackerClient).api().Version(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to reach the API over the socket: %v", err)
+	}
+	if version != "1.10.1" {
+		t.Errorf("Expected version 1.10.1, got %q", version)
+	}
+}
+
+func TestProvider_socketWithHosts(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"socket":    "/run/firecracker.sock",
+		"state_dir": t.TempDir(),
+		"host": []interface{}{
+			map[string]interface{}{"name": "a", "base_url": "http://a:8080"},
+		},
+	})
+	if _, diags := configureProvider(context.Background(), d); !diags.HasError() {
+		t.Fatal("Expected socket to be rejected with host blocks")
+	}
+}
+
+func TestProvider_validateOnConfigure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": "vm", "state": "Not started"}`))
+	}))
+	defer server.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	configure := func(raw map[string]interface{}) diag.Diagnostics {
+		raw["validate_on_configure"] = true
+		raw["state_dir"] = t.TempDir()
+		raw["retry"] = []interface{}{
+			map[string]interface{}{"max_attempts": 1, "min_backoff": "1ms", "max_backoff": "1ms"},
+		}
+		_, diags := configureProvider(context.Background(), schema.TestResourceDataRaw(t, Provider().Schema, raw))
+		return diags
+	}
+
+	if diags := configure(map[string]interface{}{"base_url": server.URL}); diags.HasError() {
+		t.Fatalf("Expected a reachable API to pass, got %v", diags)
+	}
+
+	diags := configure(map[string]interface{}{"base_url": unreachable.URL})
+	if !diags.HasError() || !strings.Contains(diags[0].Detail, unreachable.URL) {
+		t.Fatalf("Expected an unreachable base_url to be reported, got %v", diags)
+	}
+
+	// Every host is checked, and each one failing is reported
+	diags = configure(map[string]interface{}{
+		"host": []interface{}{
+			map[string]interface{}{"name": "a", "base_url": server.URL},
+			map[string]interface{}{"name": "b", "base_url": unreachable.URL},
+		},
+	})
+	if len(diags) != 1 || !strings.Contains(diags[0].Detail, `host "b"`) {
+		t.Fatalf("Expected only host b to be reported, got %v", diags)
+	}
+}
+
 func testAccPreCheck(t *testing.T) {
 	// Add any pre-check logic here if needed
 	// For example, checking if required environment variables are set
@@ -0,0 +1,76 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// errReadOnly is returned for requests that would change a Firecracker API while the
+// provider is read-only.
+var errReadOnly = fmt.Errorf("the provider is read-only (read_only = true)")
+
+// providerReadOnly reports whether the provider is configured with read_only.
+func providerReadOnly(m interface{}) bool {
+    client, ok := m.(*FirecrackerClient)
+    return ok && client != nil && client.ReadOnly
+}
+
+// readOnlyMethod reports whether a request with the method leaves the API unchanged.
+func readOnlyMethod(method string) bool {
+    return method == http.MethodGet || method == http.MethodHead
+}
+
+// guardReadOnly makes creating, updating and destroying instances of the resource fail
+// while the provider is read-only, before anything is changed. Reads are left as they are,
+// so refreshing state still works.
+func guardReadOnly(name string, r *schema.Resource) {
+    wrap := func(op string, f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+        if f == nil {
+            return nil
+        }
+        return func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+            if !providerReadOnly(m) {
+                return f(ctx, d, m)
+            }
+            target := name
+            if d.Id() != "" {
+                target = fmt.Sprintf("%s %s", name, d.Id())
+            }
+            return diag.Diagnostics{{
+                Severity: diag.Error,
+                Summary:  "Provider is read-only",
+                Detail:   fmt.Sprintf("Cannot %s %s: the provider is configured with read_only = true, which only allows plans and refreshes. Set read_only to false to apply changes.", op, target),
+            }}
+        }
+    }
+
+    r.CreateContext = wrap("create", r.CreateContext)
+    r.UpdateContext = wrap("update", r.UpdateContext)
+    r.DeleteContext = wrap("destroy", r.DeleteContext)
+}
+
+// readOnlyUpkeepWarning lists the upkeep a read-only provider skips on the host running
+// Terraform: recording the VMs it refreshes in the VM registry, and cleaning up after host
+// operations an earlier run was stopped during.
+func readOnlyUpkeepWarning(c *FirecrackerClient) diag.Diagnostics {
+    skipped := []string{fmt.Sprintf("recording the VMs it refreshes in the VM registry at %s", c.vmRegistryDir())}
+    if c.StateDir != "" {
+        interrupted, err := interruptedHostOperations(c.StateDir)
+        for _, op := range interrupted {
+            skipped = append(skipped, fmt.Sprintf("cleaning up after the interrupted operation %q", op.record.Description))
+        }
+        if err != nil {
+            skipped = append(skipped, fmt.Sprintf("cleaning up after interrupted operations it could not read (%s)", err))
+        }
+    }
+    return diag.Diagnostics{{
+        Severity: diag.Warning,
+        Summary:  "Read-only provider skipped host upkeep",
+        Detail:   fmt.Sprintf("The provider is read-only, so it left the host running Terraform as it is, skipping %s.", strings.Join(skipped, "; ")),
+    }}
+}
@@ -0,0 +1,143 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestReadOnlyProvider(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := map[string]interface{}{
+		"kernel_image_path": image,
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config)
+	if diags := resourceFirecrackerVMCreate(ctx, d, client); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	client.ReadOnly = true
+	r := Provider().ResourcesMap["firecracker_vm"]
+
+	// Refreshing still works
+	state, diags := r.RefreshWithoutUpgrade(ctx, d.State(), client)
+	if diags.HasError() || state == nil || state.ID != d.Id() {
+		t.Fatalf("Expected the VM to be refreshed, got %v, %v", state, diags)
+	}
+
+	// Changes are refused before anything is sent
+	config["tags"] = map[string]interface{}{"role": "web"}
+	diff, err := r.Diff(ctx, d.State(), terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("Failed to plan the change: %v", err)
+	}
+	if _, diags := r.Apply(ctx, d.State(), diff, client); !diags.HasError() || diags[0].Summary != "Provider is read-only" {
+		t.Errorf("Expected the update to be refused, got %v", diags)
+	}
+	if _, diags := r.Apply(ctx, d.State(), &terraform.InstanceDiff{Destroy: true}, client); !diags.HasError() || !strings.Contains(diags[0].Detail, "Cannot destroy firecracker_vm "+d.Id()) {
+		t.Errorf("Expected destroying the VM to be refused, got %v", diags)
+	}
+
+	// Requests changing the API are rejected, whoever sends them
+	if err := client.sendComponent(ctx, http.MethodPut, client.BaseURL+"/machine-config", map[string]interface{}{"vcpu_count": 2}); !errors.Is(err, errReadOnly) {
+		t.Errorf("Expected the request to be rejected, got %v", err)
+	}
+	if _, err := client.api().InstanceInfo(ctx); err != nil {
+		t.Errorf("Expected reading the API to work, got %v", err)
+	}
+}
+
+func TestReadOnlyProvider_configure(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+
+	// A process that has exited stands in for a provider killed during an operation
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("Cannot run a process: %v", err)
+	}
+	leftover := filepath.Join(stateDir, "rootfs", "app.ext4.tmp")
+	for _, dir := range []string{filepath.Dir(leftover), hostOperationsDir(stateDir)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(leftover, []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	killed := &hostOperation{
+		path: filepath.Join(hostOperationsDir(stateDir), "killed.json"),
+		record: hostOperationRecord{
+			Description: "build rootfs app.ext4",
+			PID:         cmd.Process.Pid,
+			Artifacts:   []hostArtifact{{Path: leftover}},
+		},
+	}
+	if err := killed.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The interrupted operation is reported, but left in place
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"base_url":  "fake://test",
+		"state_dir": stateDir,
+		"read_only": true,
+	})
+	m, diags := configureProvider(ctx, d)
+	if diags.HasError() || len(diags) != 1 || !strings.Contains(diags[0].Detail, `"build rootfs app.ext4"`) {
+		t.Fatalf("Expected a warning listing the interrupted operation, got %v", diags)
+	}
+	for _, path := range []string{killed.path, leftover} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", path, err)
+		}
+	}
+
+	// Refreshing a VM does not register it
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vm := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": image,
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+	})
+	provider := m.(*FirecrackerClient)
+	provider.ReadOnly = false
+	if diags := resourceFirecrackerVMCreate(ctx, vm, provider); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+	if err := unregisterVM(provider.vmRegistryDir(), vm.Id()); err != nil {
+		t.Fatal(err)
+	}
+	provider.ReadOnly = true
+	if diags := resourceFirecrackerVMRead(ctx, vm, provider); diags.HasError() {
+		t.Fatalf("Failed to read VM: %v", diags)
+	}
+	if record, err := lookupVMRecord(provider.vmRegistryDir(), vm.Id()); err != nil || record != nil {
+		t.Errorf("Expected the VM to stay unregistered, got %v, %v", record, err)
+	}
+}
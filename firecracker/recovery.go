@@ -0,0 +1,214 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    // recoveryPolicyRemove removes a lost VM from the state, so it is created again.
+    recoveryPolicyRemove = "remove"
+    // recoveryPolicyReplace keeps a lost VM in the state and plans its replacement, as if
+    // it were tainted.
+    recoveryPolicyReplace = "replace"
+    // recoveryPolicyRelaunch configures and starts a lost VM again, with the same ID, once
+    // its Firecracker API is back.
+    recoveryPolicyRelaunch = "relaunch"
+)
+
+// recoverLostVM applies the VM's recovery_policy to a VM that was lost, such as to a host
+// reboot: its registered Firecracker process is gone, or a new Firecracker process serves
+// its API and has not started a VM. reachable says whether the API answers, which
+// relaunching the VM needs.
+func recoverLostVM(ctx context.Context, d *schema.ResourceData, m interface{}, client *FirecrackerClient, reason string, reachable bool) diag.Diagnostics {
+    vmID := d.Id()
+    policy := vmRecoveryPolicy(d)
+    tflog.Warn(ctx, "Firecracker VM was lost", map[string]interface{}{
+        "id":              vmID,
+        "reason":          reason,
+        "recovery_policy": policy,
+    })
+
+    // The unit of a supervised VM boots it again, in a process systemd restarts from then on
+    if !reachable && vmSupervisedByUnit(d) && !providerReadOnly(m) {
+        return restartSupervisedVM(ctx, d, m.(*FirecrackerClient), client, reason)
+    }
+
+    switch policy {
+    case recoveryPolicyReplace:
+        d.Set("recovery_pending", true)
+        recordVMHealth(d, vmHealthLost, reason)
+        return diag.Diagnostics{{
+            Severity: diag.Warning,
+            Summary:  "Firecracker VM lost",
+            Detail:   fmt.Sprintf("%s, so VM %s will be replaced on the next apply.", reason, vmID),
+        }}
+
+    case recoveryPolicyRelaunch:
+        if providerReadOnly(m) {
+            recordVMHealth(d, vmHealthLost, reason)
+            return diag.Diagnostics{{
+                Severity: diag.Warning,
+                Summary:  "Firecracker VM lost",
+                Detail:   fmt.Sprintf("%s. VM %s is not relaunched while the provider is read-only.", reason, vmID),
+            }}
+        }
+        if !reachable {
+            recordVMHealth(d, vmHealthLost, reason)
+            return diag.Diagnostics{{
+                Severity: diag.Warning,
+                Summary:  "Firecracker VM lost",
+                Detail:   fmt.Sprintf("%s. VM %s will be relaunched on a refresh once its Firecracker API is back.", reason, vmID),
+            }}
+        }
+        if err := relaunchVM(ctx, d, m.(*FirecrackerClient), client); err != nil {
+            recordVMHealth(d, vmHealthLost, reason)
+            return diag.Diagnostics{{
+                Severity: diag.Warning,
+                Summary:  "Failed to relaunch Firecracker VM",
+                Detail:   fmt.Sprintf("%s, and relaunching VM %s failed: %s. It will be retried on the next refresh.", reason, vmID, err),
+            }}
+        }
+        diags := diag.Diagnostics{{
+            Severity: diag.Warning,
+            Summary:  "Firecracker VM relaunched",
+            Detail:   fmt.Sprintf("%s, so VM %s was configured and started again from its configuration.", reason, vmID),
+        }}
+        if err := runVMHook(ctx, d, client, hookEventStart); err != nil {
+            diags = append(diags, diag.Diagnostic{
+                Severity: diag.Warning,
+                Summary:  "Firecracker VM hook failed",
+                Detail:   err.Error(),
+            })
+        }
+        return diags
+
+    default:
+        d.SetId("")
+        return diag.Diagnostics{{
+            Severity: diag.Warning,
+            Summary:  "Firecracker VM lost",
+            Detail:   fmt.Sprintf("%s, so VM %s will be created again.", reason, vmID),
+        }}
+    }
+}
+
+// restartSupervisedVM starts the systemd unit of a VM whose Firecracker process is gone. The
+// process the VM was created in was not started by systemd, so the VM is only supervised
+// once its unit has started it. A unit that fails to start is started again on the next
+// refresh.
+func restartSupervisedVM(ctx context.Context, d *schema.ResourceData, provider *FirecrackerClient, client *FirecrackerClient, reason string) diag.Diagnostics {
+    vmID := d.Id()
+    if err := startVMUnit(ctx, vmID); err != nil {
+        recordVMHealth(d, vmHealthLost, reason)
+        return diag.Diagnostics{{
+            Severity: diag.Warning,
+            Summary:  "Failed to restart Firecracker VM",
+            Detail:   fmt.Sprintf("%s, and starting the systemd unit of VM %s failed: %s. It will be retried on the next refresh.", reason, vmID, err),
+        }}
+    }
+    tflog.Info(ctx, "Firecracker VM restarted by its systemd unit", map[string]interface{}{
+        "id":   vmID,
+        "unit": systemdUnitName(vmID),
+    })
+
+    diags := diag.Diagnostics{{
+        Severity: diag.Warning,
+        Summary:  "Firecracker VM restarted",
+        Detail:   fmt.Sprintf("%s, so the systemd unit of VM %s was started, booting it again from its configuration.", reason, vmID),
+    }}
+    if err := ensureVMRegistered(provider.vmRegistryDir(), vmRecordFromConfig(d, client)); err != nil {
+        return append(diags, diag.FromErr(err)...)
+    }
+    return diags
+}
+
+// vmRecoveryPolicy returns the recovery_policy of the VM, which recreate_on_failure turns
+// into replace.
+func vmRecoveryPolicy(d *schema.ResourceData) string {
+    if d.Get("recreate_on_failure").(bool) {
+        return recoveryPolicyReplace
+    }
+    return d.Get("recovery_policy").(string)
+}
+
+// relaunchVM configures a lost VM again from its configuration and starts it. The files
+// the provider created for it, such as its scratch drives and seed image, are reused as
+// they are, so scratch drives keep their data. The host devices the VM uses, such as its
+// TAP devices, must be back already.
+func relaunchVM(ctx context.Context, d *schema.ResourceData, provider *FirecrackerClient, client *FirecrackerClient) error {
+    ctx = maskSensitiveBootArgs(ctx, d)
+    vmID := d.Id()
+    if _, ok := cniSpecFromConfig(d); ok {
+        return fmt.Errorf("VMs attached to a CNI network cannot be relaunched")
+    }
+
+    extras := existingVMPayloadExtras(d, provider.StateDir, vmID)
+
+    // The chroot of a jailed VM may have been emptied with the host
+    if spec, files := jailerStagedFiles(d, provider.StateDir, vmID); len(files) > 0 {
+        opCtx, op, err := startHostOperation(ctx, provider.StateDir, "stage files of VM "+vmID+" in the jailer chroot")
+        if err != nil {
+            return err
+        }
+        if err := op.finish(stageJailerFiles(opCtx, spec, files)); err != nil {
+            return err
+        }
+    }
+    if d.Get("cpu_quota_percent").(int) > 0 {
+        if err := applyCPUQuota(ctx, d, vmID); err != nil {
+            return err
+        }
+    }
+    if d.Get("cpuset").(string) != "" || d.Get("numa_node").(int) >= 0 {
+        if err := applyCPUPinning(ctx, d, vmID); err != nil {
+            return err
+        }
+    }
+    for _, rawIface := range vmNetworkInterfaces(d) {
+        iface := rawIface.(map[string]interface{})
+        if bridge, ok := iface["bridge"].(string); ok && bridge != "" {
+            if err := withNetNS(d.Get("netns").(string), func() error { return setLinkMaster(iface["host_dev_name"].(string), bridge) }); err != nil {
+                return err
+            }
+        }
+    }
+
+    payload, err := renderVMPayload(d, vmID, extras)
+    if err != nil {
+        return err
+    }
+    if err := setRenderedConfig(d, payload); err != nil {
+        return err
+    }
+    if payload["mmds"] != nil {
+        if payload["mmds"], err = provider.secrets.resolver().resolveValue(ctx, payload["mmds"]); err != nil {
+            return err
+        }
+    }
+    if err := client.CreateVM(ctx, payload); err != nil {
+        return fmt.Errorf("failed to relaunch VM: %w", err)
+    }
+    tflog.Info(ctx, "Firecracker VM relaunched", map[string]interface{}{
+        "id": vmID,
+    })
+
+    d.Set("recovery_pending", false)
+    return ensureVMRegistered(provider.vmRegistryDir(), vmRecordFromConfig(d, client))
+}
+
+// planRecovery plans the replacement of a VM that was lost and whose recovery_policy is
+// replace, the way tainting it would.
+func planRecovery(d *schema.ResourceDiff) error {
+    if d.Id() == "" || !d.Get("recovery_pending").(bool) {
+        return nil
+    }
+    if err := d.SetNew("recovery_pending", false); err != nil {
+        return err
+    }
+    return d.ForceNew("recovery_pending")
+}
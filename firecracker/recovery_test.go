@@ -0,0 +1,207 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestResourceFirecrackerVMRead_recovery(t *testing.T) {
+	ctx := context.Background()
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+	defer resourceFirecrackerTestImageDelete(ctx, image, nil)
+
+	config := func(policy string) map[string]interface{} {
+		return map[string]interface{}{
+			"kernel_image_path": image.Get("path").(string),
+			"recovery_policy":   policy,
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true},
+			},
+			"machine_config": []interface{}{
+				map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+			},
+		}
+	}
+
+	for _, policy := range []string{recoveryPolicyRemove, recoveryPolicyReplace, recoveryPolicyRelaunch} {
+		t.Run(policy, func(t *testing.T) {
+			stateDir := t.TempDir()
+			client := configureFakeProvider(t, stateDir)
+			d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config(policy))
+			if diags := resourceFirecrackerVMCreate(ctx, d, client); diags.HasError() {
+				t.Fatalf("Failed to create VM: %v", diags)
+			}
+			vmID := d.Id()
+
+			// The host rebooted and Firecracker was started again, without a VM
+			actionsLog := filepath.Join(stateDir, "fake", "test", "actions.log")
+			if err := os.Remove(actionsLog); err != nil {
+				t.Fatal(err)
+			}
+
+			diags := resourceFirecrackerVMRead(ctx, d, client)
+			if diags.HasError() || len(diags) == 0 {
+				t.Fatalf("Expected a warning, got %v", diags)
+			}
+
+			switch policy {
+			case recoveryPolicyRemove:
+				if d.Id() != "" {
+					t.Errorf("Expected the VM to be removed from state, got %s", d.Id())
+				}
+			case recoveryPolicyReplace:
+				if d.Id() != vmID || !d.Get("recovery_pending").(bool) || d.Get("health_status") != vmHealthLost {
+					t.Errorf("Expected the VM to be kept and marked for recovery, got ID %s, recovery_pending %v, health %s", d.Id(), d.Get("recovery_pending"), d.Get("health_status"))
+				}
+				diff, err := resourceFirecrackerVM().Diff(ctx, d.State(), terraform.NewResourceConfigRaw(config(policy)), nil)
+				if err != nil || diff == nil || !diff.RequiresNew() {
+					t.Errorf("Expected the lost VM to be replaced, got %v, %v", diff, err)
+				}
+			case recoveryPolicyRelaunch:
+				actions, err := os.ReadFile(actionsLog)
+				if d.Id() != vmID || err != nil || !strings.Contains(string(actions), "InstanceStart") {
+					t.Errorf("Expected VM %s to be started again, got ID %s and actions %q, %v", vmID, d.Id(), actions, err)
+				}
+				if diags[0].Summary != "Firecracker VM relaunched" {
+					t.Errorf("Unexpected warning %v", diags)
+				}
+			}
+		})
+	}
+}
+
+func TestResourceFirecrackerVMRead_relaunchUnreachable(t *testing.T) {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("Cannot run a process: %v", err)
+	}
+
+	client := &FirecrackerClient{
+		BaseURL:  "http://localhost:8080",
+		StateDir: t.TempDir(),
+		HTTPClient: &mockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		}},
+	}
+	if err := ensureVMRegistered(client.vmRegistryDir(), vmRecord{ID: "vm-1", BaseURL: client.BaseURL, PID: cmd.Process.Pid, Process: "true"}); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+
+	// The VM is kept until its Firecracker API is back
+	d := resourceFirecrackerVM().TestResourceData()
+	d.SetId("vm-1")
+	d.Set("recovery_policy", recoveryPolicyRelaunch)
+	diags := resourceFirecrackerVMRead(context.Background(), d, client)
+	if diags.HasError() || len(diags) != 1 {
+		t.Fatalf("Expected a warning, got %v", diags)
+	}
+	if d.Id() != "vm-1" || d.Get("health_status") != vmHealthLost {
+		t.Errorf("Expected the VM to be kept as lost, got ID %s, health %s", d.Id(), d.Get("health_status"))
+	}
+}
+
+func TestResourceFirecrackerVMRead_restartPolicy(t *testing.T) {
+	var commands []string
+	originalRun := runCommand
+	defer func() { runCommand = originalRun }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		return nil, nil
+	}
+
+	client := &FirecrackerClient{
+		BaseURL:  "http://localhost:8080",
+		StateDir: t.TempDir(),
+		HTTPClient: &mockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		}},
+	}
+
+	// The VM's API socket is gone, so its systemd unit boots it again
+	d := resourceFirecrackerVM().TestResourceData()
+	d.SetId("vm-1")
+	d.Set("restart_policy", restartPolicyOnFailure)
+	d.Set("systemd_unit", []interface{}{map[string]interface{}{"api_socket": "/run/firecracker/vm-1.sock"}})
+	diags := resourceFirecrackerVMRead(context.Background(), d, client)
+	if diags.HasError() || len(diags) != 1 || diags[0].Summary != "Firecracker VM restarted" {
+		t.Fatalf("Expected the VM to be restarted, got %v", diags)
+	}
+	if d.Id() != "vm-1" {
+		t.Errorf("Expected the VM to be kept, got ID %q", d.Id())
+	}
+	if len(commands) != 1 || commands[0] != "systemctl start firecracker-vm-1.service" {
+		t.Errorf("Expected the unit to be started, got %v", commands)
+	}
+
+	// A read-only provider leaves the VM alone
+	commands = nil
+	client.ReadOnly = true
+	if diags := resourceFirecrackerVMRead(context.Background(), d, client); !diags.HasError() {
+		t.Errorf("Expected the unreachable VM to fail the refresh, got %v", diags)
+	}
+	if len(commands) != 0 {
+		t.Errorf("Expected no commands, got %v", commands)
+	}
+}
+
+func TestResourceFirecrackerVMRead_recreateOnFailure(t *testing.T) {
+	ctx := context.Background()
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+	defer resourceFirecrackerTestImageDelete(ctx, image, nil)
+
+	config := map[string]interface{}{
+		"kernel_image_path":   image.Get("path").(string),
+		"recreate_on_failure": true,
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true},
+		},
+	}
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config)
+	if diags := resourceFirecrackerVMCreate(ctx, d, client); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+	vmID := d.Id()
+
+	// The guest crashed and its Firecracker process was started again, without a VM
+	if err := os.Remove(filepath.Join(stateDir, "fake", "test", "actions.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	if diags := resourceFirecrackerVMRead(ctx, d, client); diags.HasError() || len(diags) == 0 {
+		t.Fatalf("Expected a warning, got %v", diags)
+	}
+	if d.Id() != vmID || !d.Get("recovery_pending").(bool) || d.Get("health_status") != vmHealthLost {
+		t.Errorf("Expected the VM to be kept and marked for replacement, got ID %s, recovery_pending %v, health %s", d.Id(), d.Get("recovery_pending"), d.Get("health_status"))
+	}
+	diff, err := resourceFirecrackerVM().Diff(ctx, d.State(), terraform.NewResourceConfigRaw(config), nil)
+	if err != nil || diff == nil || !diff.RequiresNew() {
+		t.Errorf("Expected the crashed VM to be replaced, got %v, %v", diff, err)
+	}
+
+	// Relaunching conflicts with replacing
+	config["recovery_policy"] = recoveryPolicyRelaunch
+	if _, err := resourceFirecrackerVM().Diff(ctx, nil, terraform.NewResourceConfigRaw(config), nil); err == nil {
+		t.Error("Expected recreate_on_failure to be rejected with recovery_policy relaunch")
+	}
+}
@@ -0,0 +1,82 @@
+package firecracker
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of TestRenderVMPayload")
+
+// renderFixture is a firecracker_vm configuration in Terraform's JSON syntax, along with
+// the results of the side effects of creating the VM.
+type renderFixture struct {
+	VMID          string                 `json:"vm_id"`
+	Config        map[string]interface{} `json:"config"`
+	SeedImagePath string                 `json:"seed_image_path"`
+	CNIInterface  map[string]interface{} `json:"cni_interface"`
+	ScratchDrives map[string]string      `json:"scratch_drive_paths"`
+}
+
+// TestRenderVMPayload renders the Firecracker configuration of every fixture in
+// testdata/render and compares it against its golden file. Run with -update to accept
+// a change to the rendered configuration.
+func TestRenderVMPayload(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "render", "*.json"))
+	if err != nil {
+		t.Fatalf("Failed to list fixtures: %v", err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("No fixtures found in testdata/render")
+	}
+
+	for _, fixturePath := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixturePath), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(fixturePath)
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+			var fixture renderFixture
+			if err := json.Unmarshal(data, &fixture); err != nil {
+				t.Fatalf("Failed to parse fixture: %v", err)
+			}
+
+			d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, fixture.Config)
+			payload, err := renderVMPayload(d, fixture.VMID, vmPayloadExtras{
+				SeedImagePath:     fixture.SeedImagePath,
+				CNIInterface:      fixture.CNIInterface,
+				ScratchDrivePaths: fixture.ScratchDrives,
+			})
+			if err != nil {
+				t.Fatalf("Failed to render payload: %v", err)
+			}
+			rendered, err := json.MarshalIndent(payload, "", "  ")
+			if err != nil {
+				t.Fatalf("Failed to encode payload: %v", err)
+			}
+			rendered = append(rendered, '\n')
+
+			goldenPath := filepath.Join("testdata", "render", name+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, rendered, 0o644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+				return
+			}
+
+			golden, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("Failed to read golden file, run with -update to create it: %v", err)
+			}
+			if string(golden) != string(rendered) {
+				t.Errorf("Rendered configuration differs from %s, run with -update if the change is intended.\nGot:\n%s\nExpected:\n%s", goldenPath, rendered, golden)
+			}
+		})
+	}
+}
@@ -0,0 +1,565 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "net"
+    "sort"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceFirecrackerBridge defines the schema and CRUD operations for the firecracker_bridge resource.
+// This resource manages a Linux bridge on the host running Terraform, providing the L2 network
+// that TAP devices of microVMs are attached to.
+func resourceFirecrackerBridge() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: withOperationTimeout(schema.TimeoutCreate, resourceFirecrackerBridgeCreate),
+        ReadContext:   resourceFirecrackerBridgeRead,
+        UpdateContext: resourceFirecrackerBridgeUpdate,
+        DeleteContext: withOperationTimeout(schema.TimeoutDelete, resourceFirecrackerBridgeDelete),
+        CustomizeDiff: resourceFirecrackerBridgeCustomizeDiff,
+        Schema: map[string]*schema.Schema{
+            "name": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Name of the bridge (e.g., 'fcbr0'). Must be a valid Linux interface name of at most 15 characters.",
+                ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+            },
+            "address": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                Description:  "Gateway address of the bridge in CIDR notation (e.g., '172.16.0.1/24'). VMs on the bridge use it as their default gateway.",
+                ValidateFunc: validation.IsCIDR,
+            },
+            "mtu": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                Computed:     true,
+                Description:  "MTU of the bridge. If not specified, the kernel default is used.",
+                ValidateFunc: validation.IntBetween(68, 65535),
+            },
+            "enable_ip_forwarding": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                Default:     true,
+                Description: "Whether to enable IPv4 forwarding on the host so traffic from VMs can be routed beyond the bridge. Forwarding is a host-wide setting and is left enabled on destroy.",
+            },
+            "interfaces": {
+                Type:        schema.TypeSet,
+                Optional:    true,
+                Description: "Names of existing host interfaces, typically TAP devices created by `firecracker_tap`, to attach to the bridge.",
+                Elem: &schema.Schema{
+                    Type:         schema.TypeString,
+                    ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+                },
+            },
+            "nat": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "Installs iptables MASQUERADE and FORWARD rules so VMs on the bridge get outbound access. The rules are removed when the block is removed or the bridge is destroyed. Requires `address`.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "outbound_interface": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Description:  "Host interface that egress traffic leaves through (e.g., 'eth0'). If not specified, traffic leaving through any other interface is masqueraded.",
+                            ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+                        },
+                    },
+                },
+            },
+            "uplink_bond": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "Bonds several host NICs into a single uplink attached to the bridge, so VM traffic survives the failure of a NIC. The bond is deleted when the block is removed or the bridge is destroyed.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "name": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            Description:  "Name of the bond device (e.g., 'bond0').",
+                            ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+                        },
+                        "mode": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Default:      "active-backup",
+                            Description:  "Bonding mode, such as 'active-backup' or '802.3ad'.",
+                            ValidateFunc: validation.StringInSlice(bondModes, false),
+                        },
+                        "miimon": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            Default:      100,
+                            Description:  "Interval in milliseconds at which the link state of the members is checked. 0 disables link monitoring.",
+                            ValidateFunc: validation.IntAtLeast(0),
+                        },
+                        "members": {
+                            Type:        schema.TypeSet,
+                            Required:    true,
+                            MinItems:    1,
+                            Description: "Names of the host NICs to add to the bond. Their addresses are not moved to the bridge.",
+                            Elem: &schema.Schema{
+                                Type:         schema.TypeString,
+                                ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+                            },
+                        },
+                    },
+                },
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Create: schema.DefaultTimeout(1 * time.Minute),
+            Delete: schema.DefaultTimeout(1 * time.Minute),
+        },
+        Importer: &schema.ResourceImporter{
+            StateContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+                // The ID of a bridge is its name
+                d.Set("name", d.Id())
+                d.Set("enable_ip_forwarding", true)
+                return []*schema.ResourceData{d}, nil
+            },
+        },
+    }
+}
+
+// resourceFirecrackerBridgeCustomizeDiff performs plan-time validation across attributes.
+func resourceFirecrackerBridgeCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+    if len(d.Get("nat").([]interface{})) > 0 && d.NewValueKnown("address") && d.Get("address").(string) == "" {
+        return fmt.Errorf("address must be set when nat is enabled, it defines the subnet to masquerade")
+    }
+
+    if bond := bondSpecFromConfig(d.Get("uplink_bond").([]interface{})); bond != nil {
+        if d.Get("interfaces").(*schema.Set).Contains(bond.Name) {
+            return fmt.Errorf("uplink bond %s is attached to the bridge automatically and must not be listed in interfaces", bond.Name)
+        }
+    }
+
+    return nil
+}
+
+// bridgeNATRules returns the iptables rules for the given bridge settings,
+// or nil if NAT is not enabled.
+func bridgeNATRules(name, address string, natRaw []interface{}) ([]iptablesRule, error) {
+    if len(natRaw) == 0 {
+        return nil, nil
+    }
+
+    _, subnet, err := net.ParseCIDR(address)
+    if err != nil {
+        return nil, fmt.Errorf("invalid bridge address %q: %w", address, err)
+    }
+
+    outboundInterface := ""
+    if nat, ok := natRaw[0].(map[string]interface{}); ok {
+        outboundInterface = nat["outbound_interface"].(string)
+    }
+
+    return natRules(name, subnet.String(), outboundInterface), nil
+}
+
+// bondModes are the bonding modes supported by the Linux bonding driver.
+var bondModes = []string{"balance-rr", "active-backup", "balance-xor", "broadcast", "802.3ad", "balance-tlb", "balance-alb"}
+
+// bondSpecFromConfig returns the bond described by an uplink_bond block, or nil if there is none.
+func bondSpecFromConfig(raw []interface{}) *bondSpec {
+    if len(raw) == 0 || raw[0] == nil {
+        return nil
+    }
+    cfg := raw[0].(map[string]interface{})
+
+    spec := &bondSpec{
+        Name:   cfg["name"].(string),
+        Mode:   cfg["mode"].(string),
+        Miimon: cfg["miimon"].(int),
+    }
+    for _, member := range cfg["members"].(*schema.Set).List() {
+        spec.Members = append(spec.Members, member.(string))
+    }
+    sort.Strings(spec.Members)
+
+    return spec
+}
+
+// attachUplinkBond creates the bond, adds its members and attaches it to the bridge.
+func attachUplinkBond(ctx context.Context, bridge string, spec *bondSpec) error {
+    tflog.Info(ctx, "Creating uplink bond", map[string]interface{}{
+        "bridge":  bridge,
+        "bond":    spec.Name,
+        "mode":    spec.Mode,
+        "members": spec.Members,
+    })
+
+    // A bond left behind detached from the bridge is reused
+    info, err := getLinkInfo(spec.Name)
+    if err != nil {
+        return err
+    }
+    if info == nil {
+        if err := createBond(*spec); err != nil {
+            return err
+        }
+    }
+    members, err := listLinkMembers(spec.Name)
+    if err != nil {
+        return err
+    }
+    for _, member := range spec.Members {
+        if containsString(members, member) {
+            continue
+        }
+        if err := addBondMember(spec.Name, member); err != nil {
+            return err
+        }
+    }
+
+    return setLinkMaster(spec.Name, bridge)
+}
+
+func resourceFirecrackerBridgeCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    name := d.Get("name").(string)
+
+    tflog.Info(ctx, "Creating bridge", map[string]interface{}{
+        "name": name,
+    })
+
+    if err := createBridge(name, d.Get("mtu").(int)); err != nil {
+        return diag.FromErr(err)
+    }
+    d.SetId(name)
+
+    if address := d.Get("address").(string); address != "" {
+        if err := addLinkAddress(name, address); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    if d.Get("enable_ip_forwarding").(bool) {
+        if err := enableIPForwarding(); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    for _, iface := range d.Get("interfaces").(*schema.Set).List() {
+        if err := setLinkMaster(iface.(string), name); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    if bond := bondSpecFromConfig(d.Get("uplink_bond").([]interface{})); bond != nil {
+        if err := attachUplinkBond(ctx, name, bond); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    rules, err := bridgeNATRules(name, d.Get("address").(string), d.Get("nat").([]interface{}))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    for _, rule := range rules {
+        if err := ensureIptablesRule(ctx, rule); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    tflog.Info(ctx, "Bridge created successfully", map[string]interface{}{
+        "name": name,
+    })
+
+    return resourceFirecrackerBridgeRead(ctx, d, m)
+}
+
+func resourceFirecrackerBridgeRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    var diags diag.Diagnostics
+
+    name := d.Id()
+    info, err := getLinkInfo(name)
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading bridge: %w", err))
+    }
+
+    // If the bridge is gone, remove it from state
+    if info == nil {
+        tflog.Warn(ctx, "Bridge not found, removing from state", map[string]interface{}{
+            "name": name,
+        })
+        d.SetId("")
+        return diags
+    }
+
+    d.Set("name", info.Name)
+    d.Set("mtu", info.MTU)
+
+    // Only report the configured address if it is still assigned, so removal shows up as drift
+    if address := d.Get("address").(string); address != "" {
+        addrs, err := getLinkAddresses(name)
+        if err != nil {
+            return diag.FromErr(fmt.Errorf("error reading bridge addresses: %w", err))
+        }
+        if !containsString(addrs, address) {
+            d.Set("address", "")
+        }
+    }
+
+    // Only track members managed through this resource; TAP devices attached via
+    // firecracker_tap.bridge are not reported as drift
+    members, err := listLinkMembers(name)
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading bridge members: %w", err))
+    }
+    attached := []interface{}{}
+    for _, iface := range d.Get("interfaces").(*schema.Set).List() {
+        if containsString(members, iface.(string)) {
+            attached = append(attached, iface)
+        }
+    }
+    d.Set("interfaces", attached)
+
+    // Report the bond as missing if it is gone or no longer attached, and track its actual members
+    if bond := bondSpecFromConfig(d.Get("uplink_bond").([]interface{})); bond != nil {
+        bondInfo, err := getLinkInfo(bond.Name)
+        if err != nil {
+            return diag.FromErr(fmt.Errorf("error reading uplink bond: %w", err))
+        }
+        if bondInfo == nil || bondInfo.Master != name {
+            tflog.Warn(ctx, "Uplink bond is missing or detached from bridge", map[string]interface{}{
+                "name": name,
+                "bond": bond.Name,
+            })
+            d.Set("uplink_bond", []interface{}{})
+        } else {
+            members, err := listLinkMembers(bond.Name)
+            if err != nil {
+                return diag.FromErr(fmt.Errorf("error reading uplink bond members: %w", err))
+            }
+            cfg := d.Get("uplink_bond").([]interface{})[0].(map[string]interface{})
+            cfg["members"] = members
+            d.Set("uplink_bond", []interface{}{cfg})
+        }
+    }
+
+    // Report NAT as disabled if any of its rules went missing, so the next apply reinstalls them.
+    // The rules are derived from the address, so they are reinstalled along with a missing one.
+    if d.Get("address").(string) == "" {
+        if len(d.Get("nat").([]interface{})) > 0 {
+            tflog.Warn(ctx, "Bridge address for NAT is missing", map[string]interface{}{
+                "name": name,
+            })
+            d.Set("nat", []interface{}{})
+        }
+        return diags
+    }
+    rules, err := bridgeNATRules(name, d.Get("address").(string), d.Get("nat").([]interface{}))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    for _, rule := range rules {
+        exists, err := iptablesRuleExists(ctx, rule)
+        if err != nil {
+            return diag.FromErr(fmt.Errorf("error reading NAT rules: %w", err))
+        }
+        if !exists {
+            tflog.Warn(ctx, "NAT rule for bridge is missing", map[string]interface{}{
+                "name": name,
+                "rule": rule.Args,
+            })
+            d.Set("nat", []interface{}{})
+            break
+        }
+    }
+
+    return diags
+}
+
+func resourceFirecrackerBridgeUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    name := d.Id()
+
+    if d.HasChange("mtu") {
+        if err := setLinkMTU(name, d.Get("mtu").(int)); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // NAT rules depend on the address, so replace them before the address changes. Without
+    // the old address, which went missing from the bridge, the rules are only reinstalled.
+    if oldAddress, _ := d.GetChange("address"); d.HasChanges("nat", "address") && oldAddress.(string) != "" {
+        oldNAT, _ := d.GetChange("nat")
+        oldRules, err := bridgeNATRules(name, oldAddress.(string), oldNAT.([]interface{}))
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        for _, rule := range oldRules {
+            if err := removeIptablesRule(ctx, rule); err != nil {
+                return diag.FromErr(err)
+            }
+        }
+    }
+
+    if d.HasChange("address") {
+        oldAddress, newAddress := d.GetChange("address")
+        if oldAddress.(string) != "" {
+            if err := deleteLinkAddress(name, oldAddress.(string)); err != nil {
+                return diag.FromErr(err)
+            }
+        }
+        if newAddress.(string) != "" {
+            if err := addLinkAddress(name, newAddress.(string)); err != nil {
+                return diag.FromErr(err)
+            }
+        }
+    }
+
+    if d.HasChanges("nat", "address") {
+        rules, err := bridgeNATRules(name, d.Get("address").(string), d.Get("nat").([]interface{}))
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        for _, rule := range rules {
+            if err := ensureIptablesRule(ctx, rule); err != nil {
+                return diag.FromErr(err)
+            }
+        }
+    }
+
+    if d.HasChange("enable_ip_forwarding") && d.Get("enable_ip_forwarding").(bool) {
+        if err := enableIPForwarding(); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    if d.HasChange("interfaces") {
+        oldRaw, newRaw := d.GetChange("interfaces")
+        oldSet, newSet := oldRaw.(*schema.Set), newRaw.(*schema.Set)
+
+        for _, iface := range oldSet.Difference(newSet).List() {
+            tflog.Info(ctx, "Detaching interface from bridge", map[string]interface{}{
+                "bridge":    name,
+                "interface": iface,
+            })
+            if err := setLinkMaster(iface.(string), ""); err != nil {
+                return diag.FromErr(err)
+            }
+        }
+        for _, iface := range newSet.Difference(oldSet).List() {
+            tflog.Info(ctx, "Attaching interface to bridge", map[string]interface{}{
+                "bridge":    name,
+                "interface": iface,
+            })
+            if err := setLinkMaster(iface.(string), name); err != nil {
+                return diag.FromErr(err)
+            }
+        }
+    }
+
+    if d.HasChange("uplink_bond") {
+        if err := updateUplinkBond(ctx, d); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    return resourceFirecrackerBridgeRead(ctx, d, m)
+}
+
+// updateUplinkBond applies changes of the uplink_bond block. Membership changes are applied
+// in place; any other change replaces the bond, since its mode cannot change while it has members.
+func updateUplinkBond(ctx context.Context, d *schema.ResourceData) error {
+    name := d.Id()
+    oldRaw, newRaw := d.GetChange("uplink_bond")
+    oldBond := bondSpecFromConfig(oldRaw.([]interface{}))
+    newBond := bondSpecFromConfig(newRaw.([]interface{}))
+
+    if oldBond != nil && newBond != nil && oldBond.Name == newBond.Name && oldBond.Mode == newBond.Mode && oldBond.Miimon == newBond.Miimon {
+        for _, member := range oldBond.Members {
+            if !containsString(newBond.Members, member) {
+                tflog.Info(ctx, "Removing member from uplink bond", map[string]interface{}{
+                    "bond":   newBond.Name,
+                    "member": member,
+                })
+                if err := setLinkMaster(member, ""); err != nil {
+                    return err
+                }
+            }
+        }
+        for _, member := range newBond.Members {
+            if !containsString(oldBond.Members, member) {
+                tflog.Info(ctx, "Adding member to uplink bond", map[string]interface{}{
+                    "bond":   newBond.Name,
+                    "member": member,
+                })
+                if err := addBondMember(newBond.Name, member); err != nil {
+                    return err
+                }
+            }
+        }
+        return nil
+    }
+
+    if oldBond != nil {
+        tflog.Info(ctx, "Deleting uplink bond", map[string]interface{}{
+            "bridge": name,
+            "bond":   oldBond.Name,
+        })
+        if err := deleteLink(oldBond.Name); err != nil {
+            return err
+        }
+    }
+
+    if newBond != nil {
+        return attachUplinkBond(ctx, name, newBond)
+    }
+
+    return nil
+}
+
+func resourceFirecrackerBridgeDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    var diags diag.Diagnostics
+
+    name := d.Id()
+    tflog.Info(ctx, "Deleting bridge", map[string]interface{}{
+        "name": name,
+    })
+
+    rules, err := bridgeNATRules(name, d.Get("address").(string), d.Get("nat").([]interface{}))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    for _, rule := range rules {
+        if err := removeIptablesRule(ctx, rule); err != nil {
+            return diag.FromErr(fmt.Errorf("error removing NAT rules: %w", err))
+        }
+    }
+
+    // Deleting the bond releases its members back to the host
+    if bond := bondSpecFromConfig(d.Get("uplink_bond").([]interface{})); bond != nil {
+        if err := deleteLink(bond.Name); err != nil {
+            return diag.FromErr(fmt.Errorf("error deleting uplink bond: %w", err))
+        }
+    }
+
+    // Deleting the bridge releases any attached interfaces; the TAP devices themselves are kept
+    if err := deleteLink(name); err != nil {
+        return diag.FromErr(fmt.Errorf("error deleting bridge: %w", err))
+    }
+
+    d.SetId("")
+
+    return diags
+}
+
+// containsString reports whether s is present in values.
+func containsString(values []string, s string) bool {
+    for _, v := range values {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
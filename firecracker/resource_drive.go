@@ -0,0 +1,270 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceFirecrackerDrive defines the schema and CRUD operations for the firecracker_drive
+// resource. This resource manages the image backing a drive of a running VM apart from the
+// firecracker_vm, so data volumes can be handled by their own modules and swapped without
+// touching the VM. Firecracker cannot add drives to a running guest, so the VM declares the
+// drive, typically with a placeholder image, and this resource swaps in the image to use.
+func resourceFirecrackerDrive() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: resourceFirecrackerDriveCreate,
+        ReadContext:   resourceFirecrackerDriveRead,
+        UpdateContext: resourceFirecrackerDriveUpdate,
+        DeleteContext: resourceFirecrackerDriveDelete,
+        CustomizeDiff: resourceFirecrackerDriveCustomizeDiff,
+        Schema: map[string]*schema.Schema{
+            "vm_id": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "ID of the VM the drive belongs to.",
+                ValidateFunc: validation.StringMatch(networkNameRegexp, "must be at most 63 letters, digits, '.', '_' or '-'"),
+            },
+            "drive_id": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "ID of the drive, as declared in the VM's drives. It must not be the root device.",
+                ValidateFunc: validation.StringMatch(networkNameRegexp, "must be at most 63 letters, digits, '.', '_' or '-'"),
+            },
+            "path_on_host": {
+                Type:         schema.TypeString,
+                Required:     true,
+                Description:  "Image backing the drive, as Firecracker opens it. Changing it swaps the image of the running VM.",
+                ValidateFunc: validation.StringIsNotEmpty,
+            },
+            "host": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                ForceNew:    true,
+                Description: "Host from the provider's host pool the VM runs on. Defaults to the host the VM is registered on.",
+            },
+            "original_path": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Image the VM was created with for the drive, restored when this resource is destroyed.",
+            },
+        },
+        Importer: &schema.ResourceImporter{
+            StateContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+                vmID, driveID, ok := strings.Cut(d.Id(), "/")
+                if !ok {
+                    return nil, fmt.Errorf("import ID must be <vm_id>/<drive_id>, got %q", d.Id())
+                }
+                d.Set("vm_id", vmID)
+                d.Set("drive_id", driveID)
+                return []*schema.ResourceData{d}, nil
+            },
+        },
+    }
+}
+
+// resourceFirecrackerDriveCustomizeDiff fails the plan when path_on_host is outside the
+// provider's allowed_paths. The images of jailed VMs are in their chroot, so the chroot base
+// directory of the VM, as registered, must be allowed instead.
+func resourceFirecrackerDriveCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+    provider, _ := m.(*FirecrackerClient)
+    if provider == nil || len(provider.allowedPaths) == 0 || !d.NewValueKnown("path_on_host") || !d.NewValueKnown("vm_id") {
+        return nil
+    }
+    local := d.Get("host").(string) == "" && len(provider.hosts) == 0
+
+    vmID := d.Get("vm_id").(string)
+    record, err := lookupVMRecord(provider.vmRegistryDir(), vmID)
+    if err != nil {
+        return err
+    }
+    if record != nil {
+        if blocks, _ := record.Config["jailer"].([]interface{}); len(blocks) > 0 {
+            block, _ := blocks[0].(map[string]interface{})
+            base, _ := block["chroot_base_dir"].(string)
+            return checkAllowedPath(provider.allowedPaths, fmt.Sprintf("jailer chroot_base_dir of VM %s", vmID), base, local)
+        }
+    }
+    return checkAllowedPath(provider.allowedPaths, "path_on_host", d.Get("path_on_host").(string), local)
+}
+
+func resourceFirecrackerDriveCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    vmID := d.Get("vm_id").(string)
+    driveID := d.Get("drive_id").(string)
+    pathOnHost := d.Get("path_on_host").(string)
+
+    client, err := provider.clientForVM(vmID, d.Get("host").(string))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    drive, err := client.vmDrive(ctx, driveID)
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading drives of VM %s: %w", vmID, err))
+    }
+    if drive == nil {
+        return diag.FromErr(fmt.Errorf("VM %s has no drive %s: Firecracker cannot add drives to a running VM, so declare the drive in the VM's drives, for example with a placeholder image", vmID, driveID))
+    }
+    if root, _ := drive["is_root_device"].(bool); root {
+        return diag.FromErr(fmt.Errorf("drive %s is the root device of VM %s, which cannot be swapped while the guest runs", driveID, vmID))
+    }
+
+    // The attachment is recorded first, so the VM keeps its configured image in state while
+    // the drive uses another one
+    original, _ := drive["path_on_host"].(string)
+    if existing, err := loadDriveAttachment(provider.StateDir, vmID, driveID); err != nil {
+        return diag.FromErr(err)
+    } else if existing != nil {
+        return diag.FromErr(fmt.Errorf("drive %s of VM %s is already managed by a firecracker_drive", driveID, vmID))
+    }
+    record := driveAttachment{VMID: vmID, DriveID: driveID, PathOnHost: pathOnHost, OriginalPath: original}
+    if err := saveDriveAttachment(provider.StateDir, record); err != nil {
+        return diag.FromErr(err)
+    }
+
+    tflog.Info(ctx, "Attaching drive image", map[string]interface{}{
+        "vm_id":         vmID,
+        "drive_id":      driveID,
+        "path_on_host":  pathOnHost,
+        "original_path": original,
+    })
+    if pathOnHost != original {
+        if err := client.UpdateDrivePath(ctx, driveID, pathOnHost); err != nil {
+            deleteDriveAttachment(provider.StateDir, vmID, driveID)
+            return apiErrorDiagnostics("failed to attach drive", err)
+        }
+    }
+
+    d.SetId(vmID + "/" + driveID)
+    d.Set("original_path", original)
+    return resourceFirecrackerDriveRead(ctx, d, m)
+}
+
+// resourceFirecrackerDriveRead refreshes the image backing the drive. The drive is removed
+// from state when its VM no longer has it, such as when the VM was replaced.
+func resourceFirecrackerDriveRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    var diags diag.Diagnostics
+
+    vmID := d.Get("vm_id").(string)
+    driveID := d.Get("drive_id").(string)
+    record, err := loadDriveAttachment(provider.StateDir, vmID, driveID)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    if record == nil {
+        tflog.Warn(ctx, "Drive attachment not found, removing from state", map[string]interface{}{
+            "vm_id":    vmID,
+            "drive_id": driveID,
+        })
+        d.SetId("")
+        return diags
+    }
+    d.Set("original_path", record.OriginalPath)
+
+    client, err := provider.clientForVM(vmID, d.Get("host").(string))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    drive, err := client.vmDrive(ctx, driveID)
+    if errors.Is(err, errHostUnreachable) && provider.TolerateUnreachableHosts {
+        return diag.Diagnostics{{
+            Severity: diag.Warning,
+            Summary:  "Firecracker host unreachable",
+            Detail:   fmt.Sprintf("Could not refresh drive %s of VM %s, keeping its prior state: %s", driveID, vmID, err),
+        }}
+    }
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading drives of VM %s: %w", vmID, err))
+    }
+    if drive == nil {
+        tflog.Warn(ctx, "VM no longer has the drive, removing from state", map[string]interface{}{
+            "vm_id":    vmID,
+            "drive_id": driveID,
+        })
+        deleteDriveAttachment(provider.StateDir, vmID, driveID)
+        d.SetId("")
+        return diags
+    }
+
+    // An image swapped outside of Terraform shows as a change to path_on_host
+    if path, ok := drive["path_on_host"].(string); ok && path != "" {
+        d.Set("path_on_host", path)
+    }
+    return diags
+}
+
+func resourceFirecrackerDriveUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    vmID := d.Get("vm_id").(string)
+    driveID := d.Get("drive_id").(string)
+
+    if d.HasChange("path_on_host") {
+        pathOnHost := d.Get("path_on_host").(string)
+        client, err := provider.clientForVM(vmID, d.Get("host").(string))
+        if err != nil {
+            return diag.FromErr(err)
+        }
+
+        tflog.Info(ctx, "Swapping drive image", map[string]interface{}{
+            "vm_id":        vmID,
+            "drive_id":     driveID,
+            "path_on_host": pathOnHost,
+        })
+        if err := client.UpdateDrivePath(ctx, driveID, pathOnHost); err != nil {
+            return apiErrorDiagnostics("failed to swap drive image", err)
+        }
+        if err := saveDriveAttachment(provider.StateDir, driveAttachment{
+            VMID:         vmID,
+            DriveID:      driveID,
+            PathOnHost:   pathOnHost,
+            OriginalPath: d.Get("original_path").(string),
+        }); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    return resourceFirecrackerDriveRead(ctx, d, m)
+}
+
+// resourceFirecrackerDriveDelete puts the image the VM was created with back, so the VM and
+// its configuration agree again.
+func resourceFirecrackerDriveDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    vmID := d.Get("vm_id").(string)
+    driveID := d.Get("drive_id").(string)
+
+    client, err := provider.clientForVM(vmID, d.Get("host").(string))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    drive, err := client.vmDrive(ctx, driveID)
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading drives of VM %s: %w", vmID, err))
+    }
+    original := d.Get("original_path").(string)
+    if current, _ := drive["path_on_host"].(string); drive != nil && original != "" && current != original {
+        tflog.Info(ctx, "Restoring drive image", map[string]interface{}{
+            "vm_id":        vmID,
+            "drive_id":     driveID,
+            "path_on_host": original,
+        })
+        if err := client.UpdateDrivePath(ctx, driveID, original); err != nil {
+            return apiErrorDiagnostics("failed to restore drive image", err)
+        }
+    }
+
+    if err := deleteDriveAttachment(provider.StateDir, vmID, driveID); err != nil {
+        return diag.FromErr(err)
+    }
+    d.SetId("")
+    return nil
+}
@@ -0,0 +1,188 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceFirecrackerDriveBackup defines the schema and CRUD operations for the
+// firecracker_drive_backup resource. This resource copies the image of a VM's drive on the
+// host running Terraform to a backup directory or S3, taking a new backup whenever its
+// triggers change.
+func resourceFirecrackerDriveBackup() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: withOperationTimeout(schema.TimeoutCreate, resourceFirecrackerDriveBackupCreate),
+        ReadContext:   resourceFirecrackerDriveBackupRead,
+        UpdateContext: resourceFirecrackerDriveBackupUpdate,
+        DeleteContext: resourceFirecrackerDriveBackupDelete,
+        Schema: map[string]*schema.Schema{
+            "vm_id": {
+                Type:        schema.TypeString,
+                Required:    true,
+                ForceNew:    true,
+                Description: "ID of the VM using the drive. It is paused while the image is copied with the pause method.",
+            },
+            "drive_path": {
+                Type:        schema.TypeString,
+                Required:    true,
+                ForceNew:    true,
+                Description: "Image of the drive to back up, the path_on_host of the VM's drive.",
+            },
+            "destination": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Directory on the host or s3://bucket/prefix URL to store the backup in.",
+                ValidateFunc: validation.StringIsNotEmpty,
+            },
+            "method": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      backupMethodAuto,
+                Description:  "How a consistent copy is taken: `reflink` clones the image sharing its extents while the VM runs, `pause` pauses the VM while the image is copied, `auto` tries reflink first.",
+                ValidateFunc: validation.StringInSlice([]string{backupMethodAuto, backupMethodReflink, backupMethodPause}, false),
+            },
+            "triggers": {
+                Type:        schema.TypeMap,
+                Optional:    true,
+                ForceNew:    true,
+                Elem:        &schema.Schema{Type: schema.TypeString},
+                Description: "Arbitrary values that take a new backup when changed.",
+            },
+            "retain_on_destroy": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                Default:     true,
+                Description: "Keep the backup when the resource is destroyed or replaced by a new backup.",
+            },
+            "location": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Path or S3 URL of the backup.",
+            },
+            "backend": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Method the backup was taken with, `reflink` or `pause`.",
+            },
+            "sha256": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "SHA-256 checksum of the backup.",
+            },
+            "size_bytes": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Size of the backup in bytes.",
+            },
+            "created_at": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Time the backup was taken, in RFC 3339 format.",
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Create: schema.DefaultTimeout(30 * time.Minute),
+        },
+    }
+}
+
+func resourceFirecrackerDriveBackupCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+
+    spec := driveBackupSpec{
+        VMID:        d.Get("vm_id").(string),
+        DrivePath:   d.Get("drive_path").(string),
+        Destination: d.Get("destination").(string),
+        Method:      d.Get("method").(string),
+        StateDir:    client.StateDir,
+    }
+    if _, err := os.Stat(spec.DrivePath); err != nil {
+        return diag.FromErr(fmt.Errorf("error reading drive image: %w", err))
+    }
+
+    tflog.Info(ctx, "Backing up drive", map[string]interface{}{
+        "vm_id":       spec.VMID,
+        "drive_path":  spec.DrivePath,
+        "destination": spec.Destination,
+        "method":      spec.Method,
+    })
+
+    opCtx, op, err := startHostOperation(ctx, client.StateDir, "back up drive "+spec.DrivePath)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    backup, err := backupDrive(opCtx, client, spec)
+    if err := op.finish(err); err != nil {
+        return diag.FromErr(fmt.Errorf("error backing up drive: %w", err))
+    }
+    d.SetId(backup.Location)
+    d.Set("location", backup.Location)
+    d.Set("backend", backup.Method)
+    d.Set("sha256", backup.SHA256)
+    d.Set("size_bytes", backup.Size)
+    d.Set("created_at", backup.Created.Format(time.RFC3339))
+
+    tflog.Info(ctx, "Drive backed up successfully", map[string]interface{}{
+        "location": backup.Location,
+        "backend":  backup.Method,
+    })
+
+    return resourceFirecrackerDriveBackupRead(ctx, d, m)
+}
+
+func resourceFirecrackerDriveBackupRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    var diags diag.Diagnostics
+
+    // Backups in S3 are not listed on every refresh, only local ones are checked
+    if isS3Destination(d.Id()) {
+        return diags
+    }
+    if _, err := os.Stat(d.Id()); errors.Is(err, os.ErrNotExist) {
+        tflog.Warn(ctx, "Drive backup not found, removing from state", map[string]interface{}{
+            "location": d.Id(),
+        })
+        d.SetId("")
+        return diags
+    } else if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading drive backup: %w", err))
+    }
+
+    return diags
+}
+
+// resourceFirecrackerDriveBackupUpdate only records a changed retain_on_destroy, which
+// applies when the backup is deleted.
+func resourceFirecrackerDriveBackupUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    return resourceFirecrackerDriveBackupRead(ctx, d, m)
+}
+
+func resourceFirecrackerDriveBackupDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    if d.Get("retain_on_destroy").(bool) {
+        tflog.Info(ctx, "Keeping drive backup", map[string]interface{}{
+            "location": d.Id(),
+        })
+        d.SetId("")
+        return nil
+    }
+
+    tflog.Info(ctx, "Deleting drive backup", map[string]interface{}{
+        "location": d.Id(),
+    })
+    if err := removeDriveBackup(ctx, d.Id()); err != nil {
+        return diag.FromErr(fmt.Errorf("error deleting drive backup: %w", err))
+    }
+
+    d.SetId("")
+
+    return nil
+}
@@ -0,0 +1,138 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceFirecrackerIPAllocation defines the schema and CRUD operations for the firecracker_ip_allocation resource.
+// An allocation reserves a conflict-free guest address in a firecracker_network.
+func resourceFirecrackerIPAllocation() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: resourceFirecrackerIPAllocationCreate,
+        ReadContext:   resourceFirecrackerIPAllocationRead,
+        DeleteContext: resourceFirecrackerIPAllocationDelete,
+        Schema: map[string]*schema.Schema{
+            "network": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Name of the `firecracker_network` to allocate from.",
+                ValidateFunc: validation.StringIsNotEmpty,
+            },
+            "key": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Unique key of the allocation within the network, typically the VM name. The address is derived from the key, so the same key gets the same address while it is free.",
+                ValidateFunc: validation.StringIsNotEmpty,
+            },
+            "guest_ip": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Allocated guest address.",
+            },
+            "guest_ip_cidr": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Allocated guest address with the network prefix length (e.g., '172.16.0.23/24').",
+            },
+            "gateway": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Gateway address of the network.",
+            },
+            "netmask": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Netmask of the network in dotted decimal notation.",
+            },
+        },
+        Importer: &schema.ResourceImporter{
+            StateContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+                network, key, ok := strings.Cut(d.Id(), "/")
+                if !ok {
+                    return nil, fmt.Errorf("import ID must be <network>/<key>, got %q", d.Id())
+                }
+                d.Set("network", network)
+                d.Set("key", key)
+                return []*schema.ResourceData{d}, nil
+            },
+        },
+    }
+}
+
+func resourceFirecrackerIPAllocationCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+    network := d.Get("network").(string)
+    key := d.Get("key").(string)
+
+    ip, _, err := allocateIP(client.StateDir, network, key)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    d.SetId(network + "/" + key)
+
+    tflog.Info(ctx, "Allocated guest address", map[string]interface{}{
+        "network": network,
+        "key":     key,
+        "ip":      ip,
+    })
+
+    return resourceFirecrackerIPAllocationRead(ctx, d, m)
+}
+
+func resourceFirecrackerIPAllocationRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+    var diags diag.Diagnostics
+
+    network := d.Get("network").(string)
+    key := d.Get("key").(string)
+
+    record, err := loadIPAMNetwork(client.StateDir, network)
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading allocation: %w", err))
+    }
+
+    ip := ""
+    if record != nil {
+        ip = record.Allocations[key]
+    }
+    if ip == "" {
+        tflog.Warn(ctx, "Address allocation not found, removing from state", map[string]interface{}{
+            "network": network,
+            "key":     key,
+        })
+        d.SetId("")
+        return diags
+    }
+
+    settings, err := networkSettings(record, ip)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    d.Set("guest_ip", ip)
+    d.Set("guest_ip_cidr", settings.AddressCIDR)
+    d.Set("gateway", record.Gateway)
+    d.Set("netmask", settings.Netmask)
+
+    return diags
+}
+
+func resourceFirecrackerIPAllocationDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+    var diags diag.Diagnostics
+
+    if err := releaseIP(client.StateDir, d.Get("network").(string), d.Get("key").(string)); err != nil {
+        return diag.FromErr(fmt.Errorf("error releasing address: %w", err))
+    }
+    d.SetId("")
+
+    return diags
+}
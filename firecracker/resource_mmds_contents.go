@@ -0,0 +1,191 @@
+package firecracker
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/structure"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceFirecrackerMMDSContents defines the schema and CRUD operations for the
+// firecracker_mmds_contents resource. This resource publishes application metadata under a
+// top-level key of a running VM's MMDS data store, so it can be changed in place, such as
+// to flip feature flags or rotate credentials, without planning changes to the VM.
+func resourceFirecrackerMMDSContents() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: resourceFirecrackerMMDSContentsCreate,
+        ReadContext:   resourceFirecrackerMMDSContentsRead,
+        UpdateContext: resourceFirecrackerMMDSContentsUpdate,
+        DeleteContext: resourceFirecrackerMMDSContentsDelete,
+        CustomizeDiff: resourceFirecrackerMMDSContentsCustomizeDiff,
+        Schema: map[string]*schema.Schema{
+            "vm_id": {
+                Type:        schema.TypeString,
+                Required:    true,
+                ForceNew:    true,
+                Description: "ID of the VM whose MMDS data store the contents are published in. The VM must have MMDS enabled.",
+            },
+            "key": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Top-level MMDS key the contents are published under. The keys the provider publishes for the VM itself, `firecracker` and `latest`, are reserved.",
+                ValidateFunc: validation.All(validation.StringIsNotEmpty, validation.StringNotInSlice(mmdsReservedKeys, false)),
+            },
+            "data": {
+                Type:             schema.TypeString,
+                Required:         true,
+                Sensitive:        true,
+                Description:      "JSON-encoded contents, typically from jsonencode(). Strings may contain secret://<source>/<key> references, which are resolved when the contents are published.",
+                ValidateFunc:     validation.StringIsJSON,
+                DiffSuppressFunc: structure.SuppressJsonDiff,
+            },
+            "host": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                ForceNew:    true,
+                Description: "Host from the provider's host pool the VM runs on. Defaults to the host the VM is registered on.",
+            },
+        },
+        Importer: &schema.ResourceImporter{
+            StateContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+                vmID, key, ok := strings.Cut(d.Id(), "/")
+                if !ok {
+                    return nil, fmt.Errorf("import ID must be <vm_id>/<key>, got %q", d.Id())
+                }
+                d.Set("vm_id", vmID)
+                d.Set("key", key)
+                d.Set("data", "{}")
+                return []*schema.ResourceData{d}, nil
+            },
+        },
+    }
+}
+
+// resourceFirecrackerMMDSContentsCustomizeDiff fails the plan when the contents refer to a
+// secret source the provider does not configure.
+func resourceFirecrackerMMDSContentsCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+    provider, ok := m.(*FirecrackerClient)
+    if !ok || !d.NewValueKnown("data") {
+        return nil
+    }
+    return provider.secrets.check("data", d.Get("data").(string))
+}
+
+// publishMMDSContents resolves the secret references in the resource's contents and
+// publishes them under its key.
+func publishMMDSContents(ctx context.Context, d *schema.ResourceData, provider *FirecrackerClient) diag.Diagnostics {
+    vmID := d.Get("vm_id").(string)
+    key := d.Get("key").(string)
+
+    var data interface{}
+    if err := json.Unmarshal([]byte(d.Get("data").(string)), &data); err != nil {
+        return diag.FromErr(fmt.Errorf("invalid data: %w", err))
+    }
+    data, err := provider.secrets.resolver().resolveValue(ctx, data)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    client, err := provider.clientForVM(vmID, d.Get("host").(string))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    tflog.Info(ctx, "Publishing MMDS contents", map[string]interface{}{
+        "vm_id": vmID,
+        "key":   key,
+    })
+    if err := client.SetMMDSKey(ctx, key, data); err != nil {
+        return apiErrorDiagnostics("failed to publish MMDS contents", err)
+    }
+    return nil
+}
+
+func resourceFirecrackerMMDSContentsCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    if diags := publishMMDSContents(ctx, d, m.(*FirecrackerClient)); diags.HasError() {
+        return diags
+    }
+    d.SetId(d.Get("vm_id").(string) + "/" + d.Get("key").(string))
+    return resourceFirecrackerMMDSContentsRead(ctx, d, m)
+}
+
+// resourceFirecrackerMMDSContentsRead refreshes the published contents. The resource is
+// removed from state when its key is gone, such as when the VM was replaced, so the next
+// apply publishes the contents again. Contents with secret references are kept as they are
+// in state, since the data store holds the secrets instead.
+func resourceFirecrackerMMDSContentsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    vmID := d.Get("vm_id").(string)
+    key := d.Get("key").(string)
+
+    client, err := provider.clientForVM(vmID, d.Get("host").(string))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    value, ok, err := client.GetMMDSKey(ctx, key)
+    if errors.Is(err, errHostUnreachable) && provider.TolerateUnreachableHosts {
+        return diag.Diagnostics{{
+            Severity: diag.Warning,
+            Summary:  "Firecracker host unreachable",
+            Detail:   fmt.Sprintf("Could not refresh MMDS key %s of VM %s, keeping its prior state: %s", key, vmID, err),
+        }}
+    }
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading MMDS of VM %s: %w", vmID, err))
+    }
+    if !ok {
+        tflog.Warn(ctx, "MMDS key not found, removing from state", map[string]interface{}{
+            "vm_id": vmID,
+            "key":   key,
+        })
+        d.SetId("")
+        return nil
+    }
+
+    if !secretReferenceRegexp.MatchString(d.Get("data").(string)) {
+        data, err := json.Marshal(value)
+        if err != nil {
+            return diag.FromErr(fmt.Errorf("failed to encode MMDS key %s: %w", key, err))
+        }
+        d.Set("data", string(data))
+    }
+    return nil
+}
+
+func resourceFirecrackerMMDSContentsUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    if d.HasChange("data") {
+        if diags := publishMMDSContents(ctx, d, m.(*FirecrackerClient)); diags.HasError() {
+            return diags
+        }
+    }
+    return resourceFirecrackerMMDSContentsRead(ctx, d, m)
+}
+
+func resourceFirecrackerMMDSContentsDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    vmID := d.Get("vm_id").(string)
+    key := d.Get("key").(string)
+
+    client, err := provider.clientForVM(vmID, d.Get("host").(string))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    tflog.Info(ctx, "Removing MMDS contents", map[string]interface{}{
+        "vm_id": vmID,
+        "key":   key,
+    })
+    if err := client.DeleteMMDSKey(ctx, key); err != nil {
+        return diag.FromErr(err)
+    }
+    d.SetId("")
+    return nil
+}
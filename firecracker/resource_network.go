@@ -0,0 +1,132 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "regexp"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// networkNameRegexp matches network names, which are also used as IPAM file names.
+var networkNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,63}$`)
+
+// resourceFirecrackerNetwork defines the schema and CRUD operations for the firecracker_network resource.
+// A network is an address range managed by the provider's IPAM, from which
+// firecracker_ip_allocation resources receive guest addresses.
+func resourceFirecrackerNetwork() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: resourceFirecrackerNetworkCreate,
+        ReadContext:   resourceFirecrackerNetworkRead,
+        DeleteContext: resourceFirecrackerNetworkDelete,
+        Schema: map[string]*schema.Schema{
+            "name": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Unique name of the network.",
+                ValidateFunc: validation.StringMatch(networkNameRegexp, "must be at most 63 letters, digits, '.', '_' or '-'"),
+            },
+            "cidr": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "IPv4 address range of the network in CIDR notation (e.g., '172.16.0.0/24').",
+                ValidateFunc: validation.IsCIDR,
+            },
+            "gateway": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                Computed:     true,
+                ForceNew:     true,
+                Description:  "Gateway address of the network. Defaults to the first host address. It is never allocated to a guest.",
+                ValidateFunc: validation.IsIPv4Address,
+            },
+            "gateway_cidr": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Gateway address with the network prefix length (e.g., '172.16.0.1/24'), suitable for `firecracker_bridge.address`.",
+            },
+            "netmask": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Netmask of the network in dotted decimal notation.",
+            },
+        },
+        Importer: &schema.ResourceImporter{
+            StateContext: schema.ImportStatePassthroughContext,
+        },
+    }
+}
+
+func resourceFirecrackerNetworkCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+    name := d.Get("name").(string)
+
+    tflog.Info(ctx, "Creating IPAM network", map[string]interface{}{
+        "name": name,
+        "cidr": d.Get("cidr").(string),
+    })
+
+    if _, err := createIPAMNetwork(client.StateDir, name, d.Get("cidr").(string), d.Get("gateway").(string)); err != nil {
+        return diag.FromErr(err)
+    }
+    d.SetId(name)
+
+    return resourceFirecrackerNetworkRead(ctx, d, m)
+}
+
+func resourceFirecrackerNetworkRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+    var diags diag.Diagnostics
+
+    record, err := loadIPAMNetwork(client.StateDir, d.Id())
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading network: %w", err))
+    }
+
+    // If the network record is gone, remove it from state
+    if record == nil {
+        tflog.Warn(ctx, "IPAM network not found, removing from state", map[string]interface{}{
+            "name": d.Id(),
+        })
+        d.SetId("")
+        return diags
+    }
+
+    d.Set("name", record.Name)
+    d.Set("cidr", record.CIDR)
+    d.Set("gateway", record.Gateway)
+
+    settings, err := networkSettings(record, record.Gateway)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    d.Set("gateway_cidr", settings.AddressCIDR)
+    d.Set("netmask", settings.Netmask)
+
+    return diags
+}
+
+func resourceFirecrackerNetworkDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+    var diags diag.Diagnostics
+
+    record, err := loadIPAMNetwork(client.StateDir, d.Id())
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    if record != nil && len(record.Allocations) > 0 {
+        return diag.FromErr(fmt.Errorf("network %s still has %d address allocations", d.Id(), len(record.Allocations)))
+    }
+
+    if err := deleteIPAMNetwork(client.StateDir, d.Id()); err != nil {
+        return diag.FromErr(err)
+    }
+    d.SetId("")
+
+    return diags
+}
@@ -0,0 +1,177 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "regexp"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceFirecrackerNetworkInterface defines the schema and CRUD operations for the
+// firecracker_network_interface resource. This resource attaches a network interface to
+// the firecracker_vm with the given name, so a VM's interfaces can be composed with count,
+// for_each and modules instead of being listed in its network_interfaces. The VM picks up
+// its interfaces when it is created, since Firecracker cannot add them to a running guest.
+func resourceFirecrackerNetworkInterface() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: resourceFirecrackerNetworkInterfaceCreate,
+        ReadContext:   resourceFirecrackerNetworkInterfaceRead,
+        DeleteContext: resourceFirecrackerNetworkInterfaceDelete,
+        Schema: map[string]*schema.Schema{
+            "vm": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Name of the firecracker_vm the interface is attached to. The VM must depend on this resource, so the interface exists when the VM is created.",
+                ValidateFunc: validation.StringMatch(networkNameRegexp, "must be at most 63 letters, digits, '.', '_' or '-'"),
+            },
+            "iface_id": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "ID of the network interface within Firecracker. Must be unique within the VM, including its network_interfaces.",
+                ValidateFunc: validation.StringMatch(networkNameRegexp, "must be at most 63 letters, digits, '.', '_' or '-'"),
+            },
+            "host_dev_name": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Host device name for the interface. This should be a TAP device that exists on the host (e.g., 'tap0').",
+                ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+            },
+            "guest_mac": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Description:  "MAC address for the guest network interface. If not specified, Firecracker will generate one. Format: 'XX:XX:XX:XX:XX:XX'.",
+                ValidateFunc: validation.StringMatch(regexp.MustCompile(`^([0-9A-Fa-f]{2}[:-]){5}([0-9A-Fa-f]{2})$`), "must be a valid MAC address"),
+            },
+            "bridge": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Description:  "Name of the Linux bridge the TAP device should be attached to when the VM is created.",
+                ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+            },
+        },
+        Importer: &schema.ResourceImporter{
+            StateContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+                vm, ifaceID, ok := strings.Cut(d.Id(), "/")
+                if !ok {
+                    return nil, fmt.Errorf("import ID must be <vm>/<iface_id>, got %q", d.Id())
+                }
+                d.Set("vm", vm)
+                d.Set("iface_id", ifaceID)
+                return []*schema.ResourceData{d}, nil
+            },
+        },
+    }
+}
+
+// runningVMNamed returns the ID of a registered VM with the given name, or "" if there is
+// none. The registry is only consulted when the provider keeps local state.
+func runningVMNamed(client *FirecrackerClient, name string) (string, error) {
+    if client.StateDir == "" && client.VMRegistryDir == "" {
+        return "", nil
+    }
+    records, err := listRegisteredVMs(client.vmRegistryDir())
+    if err != nil {
+        return "", err
+    }
+    for _, record := range records {
+        if record.Name == name {
+            return record.ID, nil
+        }
+    }
+    return "", nil
+}
+
+func resourceFirecrackerNetworkInterfaceCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+    var diags diag.Diagnostics
+
+    record := interfaceAttachment{
+        VM:          d.Get("vm").(string),
+        IfaceID:     d.Get("iface_id").(string),
+        HostDevName: d.Get("host_dev_name").(string),
+        GuestMAC:    d.Get("guest_mac").(string),
+        Bridge:      d.Get("bridge").(string),
+    }
+    if err := createInterfaceAttachment(client.StateDir, record); err != nil {
+        return diag.FromErr(err)
+    }
+    d.SetId(record.id())
+
+    tflog.Info(ctx, "Attached network interface", map[string]interface{}{
+        "vm":       record.VM,
+        "iface_id": record.IfaceID,
+    })
+
+    vmID, err := runningVMNamed(client, record.VM)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    if vmID != "" {
+        diags = append(diags, diag.Diagnostic{
+            Severity: diag.Warning,
+            Summary:  "VM already running",
+            Detail:   fmt.Sprintf("VM %s (%s) is already running, and Firecracker cannot add network interfaces to a running guest. Interface %s is attached when the VM is next created; replace the VM to attach it now, and make the VM depend on its network interfaces.", record.VM, vmID, record.IfaceID),
+        })
+    }
+
+    return append(diags, resourceFirecrackerNetworkInterfaceRead(ctx, d, m)...)
+}
+
+func resourceFirecrackerNetworkInterfaceRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+    var diags diag.Diagnostics
+
+    vm := d.Get("vm").(string)
+    ifaceID := d.Get("iface_id").(string)
+    record, err := loadInterfaceAttachment(client.StateDir, vm, ifaceID)
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading network interface: %w", err))
+    }
+    if record == nil {
+        tflog.Warn(ctx, "Network interface not found, removing from state", map[string]interface{}{
+            "vm":       vm,
+            "iface_id": ifaceID,
+        })
+        d.SetId("")
+        return diags
+    }
+
+    d.Set("host_dev_name", record.HostDevName)
+    d.Set("guest_mac", record.GuestMAC)
+    d.Set("bridge", record.Bridge)
+
+    return diags
+}
+
+func resourceFirecrackerNetworkInterfaceDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+    var diags diag.Diagnostics
+
+    vm := d.Get("vm").(string)
+    ifaceID := d.Get("iface_id").(string)
+    if err := deleteInterfaceAttachment(client.StateDir, vm, ifaceID); err != nil {
+        return diag.FromErr(fmt.Errorf("error detaching network interface: %w", err))
+    }
+    d.SetId("")
+
+    // A running guest keeps the interface until the VM is created again
+    if vmID, err := runningVMNamed(client, vm); err == nil && vmID != "" {
+        diags = append(diags, diag.Diagnostic{
+            Severity: diag.Warning,
+            Summary:  "VM still uses the network interface",
+            Detail:   fmt.Sprintf("Interface %s was detached from VM %s (%s), but Firecracker cannot remove network interfaces from a running guest. The VM keeps it until it is next created.", ifaceID, vm, vmID),
+        })
+    }
+
+    return diags
+}
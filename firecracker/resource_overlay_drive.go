@@ -0,0 +1,182 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceFirecrackerOverlayDrive defines the schema and CRUD operations for the
+// firecracker_overlay_drive resource. This resource gives a VM a writable copy-on-write
+// view of a shared read-only base image on the host running Terraform, so many VMs can
+// boot from one golden rootfs without a full copy each.
+func resourceFirecrackerOverlayDrive() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: withOperationTimeout(schema.TimeoutCreate, resourceFirecrackerOverlayDriveCreate),
+        ReadContext:   resourceFirecrackerOverlayDriveRead,
+        DeleteContext: resourceFirecrackerOverlayDriveDelete,
+        Schema: map[string]*schema.Schema{
+            "name": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Name of the overlay. The default path and the snapshot device name are derived from it.",
+                ValidateFunc: validation.StringMatch(networkNameRegexp, "must be at most 63 letters, digits, '.', '_' or '-'"),
+            },
+            "base_image": {
+                Type:        schema.TypeString,
+                Required:    true,
+                ForceNew:    true,
+                Description: "Read-only base image shared by the overlays. It must not be modified while overlays use it.",
+            },
+            "path": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Computed:    true,
+                ForceNew:    true,
+                Description: "Path of the overlay file on the host. Defaults to overlays/<name>.img in the provider's state_dir.",
+            },
+            "method": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      overlayMethodAuto,
+                Description:  "How the overlay is created: `reflink` copies the base image sharing its extents, `dm-snapshot` stacks a sparse file on it with device-mapper, `auto` tries reflink first.",
+                ValidateFunc: validation.StringInSlice([]string{overlayMethodAuto, overlayMethodReflink, overlayMethodDMSnapshot}, false),
+            },
+            "snapshot_size_mib": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      1024,
+                Description:  "Size in MiB of the sparse file holding the guest's writes with the dm-snapshot method. The snapshot becomes invalid once the guest writes more than this.",
+                ValidateFunc: validation.IntAtLeast(1),
+            },
+            "backend": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Method the overlay was created with, `reflink` or `dm-snapshot`.",
+            },
+            "drive_path": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Path to attach as the VM's drive: the overlay file with reflink, the snapshot device with dm-snapshot.",
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Create: schema.DefaultTimeout(5 * time.Minute),
+        },
+    }
+}
+
+// overlaySpecFromConfig returns the settings of a firecracker_overlay_drive resource.
+func overlaySpecFromConfig(d *schema.ResourceData) overlaySpec {
+    return overlaySpec{
+        Name:            d.Get("name").(string),
+        BaseImage:       d.Get("base_image").(string),
+        Path:            d.Get("path").(string),
+        Method:          d.Get("method").(string),
+        SnapshotSizeMiB: d.Get("snapshot_size_mib").(int),
+    }
+}
+
+func resourceFirecrackerOverlayDriveCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+
+    if d.Get("path").(string) == "" {
+        d.Set("path", filepath.Join(client.StateDir, "overlays", d.Get("name").(string)+".img"))
+    }
+    spec := overlaySpecFromConfig(d)
+
+    if _, err := os.Stat(spec.BaseImage); err != nil {
+        return diag.FromErr(fmt.Errorf("error reading base image: %w", err))
+    }
+    if _, err := os.Stat(spec.Path); err == nil {
+        return diag.FromErr(fmt.Errorf("overlay file %s already exists", spec.Path))
+    }
+
+    tflog.Info(ctx, "Creating overlay drive", map[string]interface{}{
+        "name":       spec.Name,
+        "base_image": spec.BaseImage,
+        "method":     spec.Method,
+    })
+
+    opCtx, op, err := startHostOperation(ctx, client.StateDir, "create overlay drive "+spec.Name)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    backend, drivePath, err := createOverlay(opCtx, spec)
+    if err := op.finish(err); err != nil {
+        return diag.FromErr(err)
+    }
+    d.SetId(spec.Path)
+    d.Set("backend", backend)
+    d.Set("drive_path", drivePath)
+
+    tflog.Info(ctx, "Overlay drive created successfully", map[string]interface{}{
+        "name":       spec.Name,
+        "backend":    backend,
+        "drive_path": drivePath,
+    })
+
+    return resourceFirecrackerOverlayDriveRead(ctx, d, m)
+}
+
+func resourceFirecrackerOverlayDriveRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    var diags diag.Diagnostics
+
+    // If the overlay file is gone, the guest's writes are lost, so create it again
+    if _, err := os.Stat(d.Id()); errors.Is(err, os.ErrNotExist) {
+        tflog.Warn(ctx, "Overlay file not found, removing from state", map[string]interface{}{
+            "path": d.Id(),
+        })
+        d.SetId("")
+        return diags
+    } else if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading overlay file: %w", err))
+    }
+
+    d.Set("path", d.Id())
+    spec := overlaySpecFromConfig(d)
+
+    // Snapshot devices do not survive a host reboot, but the overlay file keeps the writes
+    if d.Get("backend").(string) == overlayMethodDMSnapshot {
+        if _, err := os.Stat(d.Get("drive_path").(string)); errors.Is(err, os.ErrNotExist) {
+            tflog.Info(ctx, "Snapshot device not found, assembling it again", map[string]interface{}{
+                "name": spec.Name,
+            })
+            drivePath, err := assembleSnapshotOverlay(ctx, spec)
+            if err != nil {
+                return diag.FromErr(err)
+            }
+            d.Set("drive_path", drivePath)
+        }
+    }
+
+    return diags
+}
+
+func resourceFirecrackerOverlayDriveDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    spec := overlaySpecFromConfig(d)
+
+    tflog.Info(ctx, "Deleting overlay drive", map[string]interface{}{
+        "name": spec.Name,
+        "path": spec.Path,
+    })
+
+    if err := removeOverlay(ctx, spec, d.Get("backend").(string)); err != nil {
+        return diag.FromErr(fmt.Errorf("error deleting overlay drive: %w", err))
+    }
+
+    d.SetId("")
+
+    return nil
+}
@@ -0,0 +1,238 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// fileModeRegexp matches octal permission bits such as "0644" or "755".
+var fileModeRegexp = regexp.MustCompile(`^0?[0-7]{3}$`)
+
+// rootfsSources are the mutually exclusive attributes a root filesystem is built from.
+var rootfsSources = []string{"source_dir", "source_tarball", "base_image"}
+
+// resourceFirecrackerRootfs defines the schema and CRUD operations for the firecracker_rootfs resource.
+// This resource builds an ext4 root filesystem image on the host running Terraform, which VMs
+// attach through their drives.
+func resourceFirecrackerRootfs() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: withOperationTimeout(schema.TimeoutCreate, resourceFirecrackerRootfsCreate),
+        ReadContext:   resourceFirecrackerRootfsRead,
+        DeleteContext: resourceFirecrackerRootfsDelete,
+        CustomizeDiff: resourceFirecrackerRootfsCustomizeDiff,
+        Schema: map[string]*schema.Schema{
+            "name": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Name of the image. The default path is derived from it.",
+                ValidateFunc: validation.StringMatch(networkNameRegexp, "must be at most 63 letters, digits, '.', '_' or '-'"),
+            },
+            "path": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                Computed:    true,
+                ForceNew:    true,
+                Description: "Path of the image on the host. Defaults to rootfs/<name>.ext4 in the provider's state_dir.",
+            },
+            "size_mib": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      1024,
+                Description:  "Size of the image in MiB.",
+                ValidateFunc: validation.IntAtLeast(8),
+            },
+            "label": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      "rootfs",
+                Description:  "Label of the ext4 filesystem, at most 16 characters.",
+                ValidateFunc: validation.StringLenBetween(1, 16),
+            },
+            "source_dir": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                ExactlyOneOf: rootfsSources,
+                Description:  "Directory whose contents become the root of the filesystem.",
+            },
+            "source_tarball": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                ExactlyOneOf: rootfsSources,
+                Description:  "Tarball, optionally compressed, whose contents become the root of the filesystem.",
+            },
+            "base_image": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                ExactlyOneOf: rootfsSources,
+                Description:  "Existing ext4 image to copy and grow to size_mib. The base image itself is never modified.",
+            },
+            "file": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                ForceNew:    true,
+                Description: "Files written into the filesystem on top of its source.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "destination": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            ForceNew:     true,
+                            Description:  "Absolute path of the file inside the filesystem.",
+                            ValidateFunc: validation.StringMatch(regexp.MustCompile(`^/[^\s]*[^/\s]$`), "must be an absolute file path"),
+                        },
+                        "content": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Description: "Contents of the file. Conflicts with source.",
+                        },
+                        "source": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Description: "Path of a file on the host to copy. Conflicts with content.",
+                        },
+                        "mode": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Default:      "0644",
+                            Description:  "Permission bits of the file in octal.",
+                            ValidateFunc: validation.StringMatch(fileModeRegexp, "must be octal permission bits such as '0644'"),
+                        },
+                    },
+                },
+            },
+            "checksum": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "SHA-256 checksum of everything the image is built from. A change rebuilds the image.",
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Create: schema.DefaultTimeout(10 * time.Minute),
+        },
+    }
+}
+
+// resourceFirecrackerRootfsCustomizeDiff computes the checksum of the image inputs at plan time
+// and replaces the image when it differs from the one it was built from.
+func resourceFirecrackerRootfsCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+    for _, key := range append([]string{"file"}, rootfsSources...) {
+        if !d.NewValueKnown(key) {
+            return d.SetNewComputed("checksum")
+        }
+    }
+
+    spec, err := rootfsSpecFromConfig(d)
+    if err != nil {
+        return err
+    }
+    checksum, err := rootfsChecksum(spec)
+    if err != nil {
+        return err
+    }
+
+    if d.Id() == "" {
+        return d.SetNew("checksum", checksum)
+    }
+    if checksum != d.Get("checksum").(string) {
+        tflog.Info(ctx, "Rootfs inputs changed, rebuilding image", map[string]interface{}{
+            "path": d.Id(),
+        })
+        if err := d.SetNew("checksum", checksum); err != nil {
+            return err
+        }
+        return d.ForceNew("checksum")
+    }
+    return nil
+}
+
+func resourceFirecrackerRootfsCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    client := m.(*FirecrackerClient)
+
+    if d.Get("path").(string) == "" {
+        d.Set("path", filepath.Join(client.StateDir, "rootfs", d.Get("name").(string)+".ext4"))
+    }
+
+    spec, err := rootfsSpecFromConfig(d)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    checksum, err := rootfsChecksum(spec)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    tflog.Info(ctx, "Building rootfs image", map[string]interface{}{
+        "path":     spec.Path,
+        "size_mib": spec.SizeMiB,
+    })
+
+    opCtx, op, err := startHostOperation(ctx, client.StateDir, "build rootfs "+spec.Path)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    if err := op.finish(buildRootfs(opCtx, spec)); err != nil {
+        return diag.FromErr(err)
+    }
+    d.SetId(spec.Path)
+    d.Set("checksum", checksum)
+
+    tflog.Info(ctx, "Rootfs image built successfully", map[string]interface{}{
+        "path": spec.Path,
+    })
+
+    return resourceFirecrackerRootfsRead(ctx, d, m)
+}
+
+func resourceFirecrackerRootfsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    var diags diag.Diagnostics
+
+    // If the image is gone, remove it from state so it is built again
+    if _, err := os.Stat(d.Id()); errors.Is(err, os.ErrNotExist) {
+        tflog.Warn(ctx, "Rootfs image not found, removing from state", map[string]interface{}{
+            "path": d.Id(),
+        })
+        d.SetId("")
+        return diags
+    } else if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading rootfs image: %w", err))
+    }
+
+    d.Set("path", d.Id())
+
+    return diags
+}
+
+func resourceFirecrackerRootfsDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    path := d.Id()
+
+    tflog.Info(ctx, "Deleting rootfs image", map[string]interface{}{
+        "path": path,
+    })
+
+    if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return diag.FromErr(fmt.Errorf("error deleting rootfs image: %w", err))
+    }
+
+    d.SetId("")
+
+    return nil
+}
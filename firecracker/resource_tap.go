@@ -0,0 +1,250 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// linkNameRegexp matches valid Linux interface names (at most 15 characters, no slashes or whitespace).
+var linkNameRegexp = regexp.MustCompile(`^[^\s/:]{1,15}$`)
+
+// resourceFirecrackerTap defines the schema and CRUD operations for the firecracker_tap resource.
+// This resource manages a TAP device on the host running Terraform, which VMs use as the
+// backend of their network interfaces.
+func resourceFirecrackerTap() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: withOperationTimeout(schema.TimeoutCreate, resourceFirecrackerTapCreate),
+        ReadContext:   resourceFirecrackerTapRead,
+        UpdateContext: resourceFirecrackerTapUpdate,
+        DeleteContext: withOperationTimeout(schema.TimeoutDelete, resourceFirecrackerTapDelete),
+        Schema: map[string]*schema.Schema{
+            "name": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Name of the TAP device (e.g., 'tap0'). Must be a valid Linux interface name of at most 15 characters.",
+                ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+            },
+            "owner": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      -1,
+                Description:  "UID allowed to open the TAP device, typically the user Firecracker or the jailer runs as. -1 leaves the device without an owner.",
+                ValidateFunc: validation.IntAtLeast(-1),
+            },
+            "group": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      -1,
+                Description:  "GID allowed to open the TAP device. -1 leaves the device without a group.",
+                ValidateFunc: validation.IntAtLeast(-1),
+            },
+            "mtu": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                Computed:     true,
+                Description:  "MTU of the TAP device. If not specified, the kernel default is used.",
+                ValidateFunc: validation.IntBetween(68, 65535),
+            },
+            "bridge": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                Description:  "Name of an existing Linux bridge to attach the TAP device to.",
+                ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+            },
+            "netns": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Description:  "Name of the network namespace to create the TAP device in, which is created if it does not exist and removed with its last interface. Devices in different namespaces may have the same name.",
+                ValidateFunc: validation.StringMatch(netnsNameRegexp, "must be a network namespace name of at most 64 letters, digits, '_', '.' or '-'"),
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Create: schema.DefaultTimeout(1 * time.Minute),
+            Delete: schema.DefaultTimeout(1 * time.Minute),
+        },
+        Importer: &schema.ResourceImporter{
+            StateContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+                // The ID of a TAP device is its name, prefixed with its network namespace
+                name := d.Id()
+                if netns, device, ok := strings.Cut(name, "/"); ok {
+                    d.Set("netns", netns)
+                    name = device
+                }
+                d.Set("name", name)
+                d.Set("owner", -1)
+                d.Set("group", -1)
+                return []*schema.ResourceData{d}, nil
+            },
+        },
+    }
+}
+
+func resourceFirecrackerTapCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    name := d.Get("name").(string)
+    netns := d.Get("netns").(string)
+
+    tflog.Info(ctx, "Creating TAP device", map[string]interface{}{
+        "name":  name,
+        "netns": netns,
+    })
+
+    if netns != "" {
+        created, err := ensureNetNS(netns)
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        if created {
+            tflog.Info(ctx, "Created network namespace", map[string]interface{}{
+                "netns": netns,
+            })
+        }
+    }
+
+    spec := tapDeviceSpec{
+        Name:  name,
+        Owner: d.Get("owner").(int),
+        Group: d.Get("group").(int),
+        MTU:   d.Get("mtu").(int),
+    }
+
+    if err := withNetNS(netns, func() error { return createTapDevice(spec) }); err != nil {
+        return diag.FromErr(err)
+    }
+    d.SetId(tapID(netns, name))
+
+    if bridge := d.Get("bridge").(string); bridge != "" {
+        if err := withNetNS(netns, func() error { return setLinkMaster(name, bridge) }); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    tflog.Info(ctx, "TAP device created successfully", map[string]interface{}{
+        "name": name,
+    })
+
+    return resourceFirecrackerTapRead(ctx, d, m)
+}
+
+func resourceFirecrackerTapRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    var diags diag.Diagnostics
+
+    name := d.Get("name").(string)
+    netns := d.Get("netns").(string)
+    if _, err := os.Stat(netnsPath(netns)); netns != "" && errors.Is(err, os.ErrNotExist) {
+        tflog.Warn(ctx, "Network namespace of TAP device not found, removing from state", map[string]interface{}{
+            "name":  name,
+            "netns": netns,
+        })
+        d.SetId("")
+        return diags
+    }
+
+    var info *hostLinkInfo
+    err := withNetNS(netns, func() error {
+        var err error
+        info, err = getLinkInfo(name)
+        return err
+    })
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading TAP device: %w", err))
+    }
+
+    // If the device is gone, remove it from state
+    if info == nil {
+        tflog.Warn(ctx, "TAP device not found, removing from state", map[string]interface{}{
+            "name": name,
+        })
+        d.SetId("")
+        return diags
+    }
+
+    d.Set("name", info.Name)
+    d.Set("mtu", info.MTU)
+    // Without bridge, the device may be attached by a firecracker_bridge's interfaces
+    if d.Get("bridge").(string) != "" {
+        d.Set("bridge", info.Master)
+    }
+
+    return diags
+}
+
+func resourceFirecrackerTapUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    name := d.Get("name").(string)
+    netns := d.Get("netns").(string)
+
+    if d.HasChange("mtu") {
+        if err := withNetNS(netns, func() error { return setLinkMTU(name, d.Get("mtu").(int)) }); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    if d.HasChange("bridge") {
+        tflog.Info(ctx, "Changing TAP device bridge", map[string]interface{}{
+            "name":   name,
+            "bridge": d.Get("bridge").(string),
+        })
+        if err := withNetNS(netns, func() error { return setLinkMaster(name, d.Get("bridge").(string)) }); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    return resourceFirecrackerTapRead(ctx, d, m)
+}
+
+func resourceFirecrackerTapDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    var diags diag.Diagnostics
+
+    name := d.Get("name").(string)
+    netns := d.Get("netns").(string)
+    tflog.Info(ctx, "Deleting TAP device", map[string]interface{}{
+        "name":  name,
+        "netns": netns,
+    })
+
+    if _, err := os.Stat(netnsPath(netns)); netns != "" && errors.Is(err, os.ErrNotExist) {
+        d.SetId("")
+        return diags
+    }
+    if err := withNetNS(netns, func() error { return deleteLink(name) }); err != nil {
+        return diag.FromErr(fmt.Errorf("error deleting TAP device: %w", err))
+    }
+
+    // The namespace goes with the last device the provider created in it
+    if netns != "" {
+        removed, err := deleteNetNSIfEmpty(netns)
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        if removed {
+            tflog.Info(ctx, "Deleted network namespace", map[string]interface{}{
+                "netns": netns,
+            })
+        }
+    }
+
+    d.SetId("")
+
+    return diags
+}
+
+// tapID returns the ID of a TAP device: its name, prefixed with the network namespace it is in.
+func tapID(netns, name string) string {
+    if netns == "" {
+        return name
+    }
+    return netns + "/" + name
+}
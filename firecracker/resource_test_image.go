@@ -0,0 +1,104 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceFirecrackerTestImage defines the schema and CRUD operations for the
+// firecracker_test_image resource. This resource creates an empty placeholder file to stand
+// in for a kernel or rootfs image in tests, and deletes it on destroy.
+func resourceFirecrackerTestImage() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: resourceFirecrackerTestImageCreate,
+        ReadContext:   resourceFirecrackerTestImageRead,
+        DeleteContext: resourceFirecrackerTestImageDelete,
+        Schema: map[string]*schema.Schema{
+            "directory": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                ForceNew:    true,
+                Description: "Directory the image is created in. Defaults to the system's temporary directory.",
+            },
+            "size_mib": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      1,
+                Description:  "Size of the image in MiB. The image is a sparse file and takes no space.",
+                ValidateFunc: validation.IntAtLeast(0),
+            },
+            "path": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Path of the image.",
+            },
+        },
+    }
+}
+
+func resourceFirecrackerTestImageCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    dir := d.Get("directory").(string)
+    if dir != "" {
+        if err := os.MkdirAll(dir, 0o755); err != nil {
+            return diag.FromErr(fmt.Errorf("error creating test image directory: %w", err))
+        }
+    }
+
+    f, err := os.CreateTemp(dir, "firecracker-test-image-*.img")
+    if err != nil {
+        return diag.FromErr(fmt.Errorf("error creating test image: %w", err))
+    }
+    err = f.Truncate(int64(d.Get("size_mib").(int)) * 1024 * 1024)
+    if closeErr := f.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        os.Remove(f.Name())
+        return diag.FromErr(fmt.Errorf("error sizing test image: %w", err))
+    }
+
+    tflog.Info(ctx, "Created test image", map[string]interface{}{
+        "path": f.Name(),
+    })
+
+    d.SetId(f.Name())
+
+    return resourceFirecrackerTestImageRead(ctx, d, m)
+}
+
+func resourceFirecrackerTestImageRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    var diags diag.Diagnostics
+
+    // If the image is gone, such as after a reboot cleared the temporary directory, create it again
+    if _, err := os.Stat(d.Id()); errors.Is(err, os.ErrNotExist) {
+        tflog.Warn(ctx, "Test image not found, removing from state", map[string]interface{}{
+            "path": d.Id(),
+        })
+        d.SetId("")
+        return diags
+    } else if err != nil {
+        return diag.FromErr(fmt.Errorf("error reading test image: %w", err))
+    }
+
+    d.Set("path", d.Id())
+
+    return diags
+}
+
+func resourceFirecrackerTestImageDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    if err := os.Remove(d.Id()); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return diag.FromErr(fmt.Errorf("error deleting test image: %w", err))
+    }
+
+    d.SetId("")
+
+    return nil
+}
@@ -2,15 +2,19 @@ package firecracker
 
 import (
     "context"
+    "errors"
     "fmt"
+    "path/filepath"
     "regexp"
     "strings"
     "time"
 
+    sdk "github.com/avkcode/terraform-provider-firecracker/client"
     "github.com/google/uuid"
     "github.com/hashicorp/terraform-plugin-log/tflog"
     "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
     "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/structure"
     "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
@@ -18,15 +22,59 @@ import (
 // This resource allows users to create, read, update, and delete Firecracker microVMs.
 func resourceFirecrackerVM() *schema.Resource {
     return &schema.Resource{
-        CreateContext: resourceFirecrackerVMCreate,
-        ReadContext:   resourceFirecrackerVMRead,
-        UpdateContext: resourceFirecrackerVMUpdate,
-        DeleteContext: resourceFirecrackerVMDelete,
+        CreateContext: withVMLogFields(withVMErrorRecording(withOperationTimeout(schema.TimeoutCreate, resourceFirecrackerVMCreate))),
+        ReadContext:   withVMLogFields(resourceFirecrackerVMRead),
+        UpdateContext: withVMLogFields(withVMErrorRecording(withOperationTimeout(schema.TimeoutUpdate, resourceFirecrackerVMUpdate))),
+        DeleteContext: withVMLogFields(withOperationTimeout(schema.TimeoutDelete, resourceFirecrackerVMDelete)),
+        CustomizeDiff: resourceFirecrackerVMCustomizeDiff,
         Schema: map[string]*schema.Schema{
+            "name": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Description:  "Human-readable name of the VM. Required when `id_source` is `name-hash`, in which case the VM ID is derived from it.",
+                ValidateFunc: validation.StringIsNotEmpty,
+            },
+            "tags": {
+                Type:        schema.TypeMap,
+                Optional:    true,
+                Description: "Tags identifying the VM, such as its role or environment. They are recorded in the provider's VM registry, listed by the firecracker_vms data source, added to the provider's logs about the VM and given to its hooks. Changing them does not replace the VM.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "publish_tags": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                Default:     false,
+                Description: "Publish the VM's name and tags to the guest through MMDS under the `firecracker` key. Tags changed later are published to the running VM.",
+            },
+            "id_source": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      idSourceUUID,
+                Description:  "How the VM ID is generated. `uuid` assigns a random UUID on every create, `name-hash` derives a stable UUID from `name` so the ID survives rebuilds.",
+                ValidateFunc: validation.StringInSlice([]string{idSourceUUID, idSourceNameHash}, false),
+            },
+            "config_json": {
+                Type:             schema.TypeString,
+                Optional:         true,
+                ForceNew:         true,
+                Description:      "Existing Firecracker configuration file, as `firecracker --config-file` takes it, to create the VM from instead of kernel_image_path, drives, machine_config and the other device attributes. It is validated at plan time and applied through the API as it is written.",
+                ValidateFunc:     validateConfigJSON,
+                DiffSuppressFunc: structure.SuppressJsonDiff,
+                ConflictsWith:    configFileConflicts,
+            },
             "kernel_image_path": {
                 Type:         schema.TypeString,
-                Required:     true,
-                Description:  "Path to the kernel image. Must be accessible by the Firecracker process. This should be an uncompressed Linux kernel binary (vmlinux format).",
+                Optional:     true,
+                Description:  "Path to the kernel image. Must be accessible by the Firecracker process. This should be an uncompressed Linux kernel binary (vmlinux format). Required unless config_json is set.",
+                ValidateFunc: validation.StringIsNotEmpty,
+                AtLeastOneOf: []string{"kernel_image_path", "config_json"},
+            },
+            "initrd_path": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                Description:  "Path to an initrd image loaded along with the kernel. Must be accessible by the Firecracker process.",
                 ValidateFunc: validation.StringIsNotEmpty,
             },
             "boot_args": {
@@ -35,12 +83,20 @@ func resourceFirecrackerVM() *schema.Resource {
                 Default:     "console=ttyS0 noapic reboot=k panic=1 pci=off root=/dev/vda rootfstype=ext4 rw init=/sbin/init",
                 Description: "Boot arguments for the kernel. These are passed to the kernel at boot time. The default arguments are suitable for most Linux distributions with an ext4 root filesystem.",
             },
+            "sensitive_boot_args": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                ForceNew:    true,
+                Sensitive:   true,
+                Description: "Kernel parameters carrying secrets, such as tokens read by the guest's init system. They are added to boot_args when the VM boots, and kept out of plans and logs.",
+            },
             "drives": {
-                Type:        schema.TypeList,
-                Required:    true,
-                Description: "List of drives attached to the VM. At least one drive must be specified, typically containing the root filesystem.",
-                MinItems:    1,
-                Elem: &schema.Resource{
+                Type:         schema.TypeList,
+                Optional:     true,
+                Description:  "List of drives attached to the VM. At least one drive must be specified unless config_json is set, typically containing the root filesystem. Drives are matched by drive_id, so reordering them does not change the VM.",
+                MinItems:     1,
+                AtLeastOneOf: []string{"drives", "config_json"},
+                Elem: matchElementsByID("drives", "drive_id", &schema.Resource{
                     Schema: map[string]*schema.Schema{
                         "drive_id": {
                             Type:         schema.TypeString,
@@ -50,10 +106,41 @@ func resourceFirecrackerVM() *schema.Resource {
                         },
                         "path_on_host": {
                             Type:         schema.TypeString,
-                            Required:     true,
-                            Description:  "Path to the drive on the host. This must be accessible by the Firecracker process and should be a valid disk image (e.g., ext4 filesystem).",
+                            Optional:     true,
+                            Description:  "Path to the drive on the host. This must be accessible by the Firecracker process and should be a valid disk image (e.g., ext4 filesystem). Omit it and set size_mib for a scratch drive.",
                             ValidateFunc: validation.StringIsNotEmpty,
                         },
+                        "size_mib": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Description:  "Size in MiB of a scratch drive the provider creates as a sparse file and deletes with the VM. Conflicts with path_on_host.",
+                            ValidateFunc: validation.IntAtLeast(1),
+                        },
+                        "format": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Description:  "Format of the scratch drive: `ext4`, `xfs` or `swap`. Without it the drive is left blank. Requires size_mib.",
+                            ValidateFunc: validation.StringInSlice([]string{scratchFormatExt4, scratchFormatXFS, scratchFormatSwap}, false),
+                        },
+                        "compact_interval": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Description:  "How often the drive image is compacted, as a duration such as '24h'. Once the interval has elapsed, a plan updates the VM, and the apply punches holes into the zeroed ranges of the image, pausing the VM meanwhile if it is running. Only for writable drives of VMs on the host running Terraform.",
+                            ValidateFunc: validateDuration,
+                        },
+                        "restore_from": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "ID of a firecracker_drive_backup, a path or S3 URL, to recreate the drive image from before the VM boots. Setting or changing it replaces the VM. Only for VMs on the host running Terraform.",
+                        },
+                        "partuuid": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Description:  "Unique ID of the partition holding the root filesystem, for root drives with a partition table. The guest then mounts its root filesystem with root=PARTUUID=<partuuid> unless boot_args names a root device.",
+                            ValidateFunc: validation.StringMatch(partUUIDRegexp, "must be a partition UUID such as 1a2b3c4d-01"),
+                        },
                         "is_root_device": {
                             Type:        schema.TypeBool,
                             Required:    true,
@@ -66,13 +153,14 @@ func resourceFirecrackerVM() *schema.Resource {
                             Description: "Whether the drive is read-only. Set to true for immutable drives like OS images, and false for drives that need to persist data.",
                         },
                     },
-                },
+                }),
             },
             "machine_config": {
-                Type:        schema.TypeList,
-                MaxItems:    1,
-                Required:    true,
-                Description: "Machine configuration for the VM. This defines the virtual hardware resources allocated to the VM.",
+                Type:         schema.TypeList,
+                MaxItems:     1,
+                Optional:     true,
+                Description:  "Machine configuration for the VM. This defines the virtual hardware resources allocated to the VM. Required unless config_json is set.",
+                AtLeastOneOf: []string{"machine_config", "config_json"},
                 Elem: &schema.Resource{
                     Schema: map[string]*schema.Schema{
                         "vcpu_count": {
@@ -90,11 +178,38 @@ func resourceFirecrackerVM() *schema.Resource {
                     },
                 },
             },
+            "cpu_config_json": {
+                Type:             schema.TypeString,
+                Optional:         true,
+                ForceNew:         true,
+                Description:      "Custom CPU template, as PUT /cpu-config takes it, masking or setting the CPUID leaves, MSRs or registers the guest sees so VMs see the same CPU features across a fleet. It is validated at plan time and applied as it is written. Requires Firecracker 1.4.0 or later.",
+                ValidateFunc:     validateCPUConfigJSON,
+                DiffSuppressFunc: structure.SuppressJsonDiff,
+            },
+            "cpu_quota_percent": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                Description:  "CPU time the VM's Firecracker process may use, in percent of one CPU, enforced through the cpu.max file of its cgroup. Requires a jailer block with cgroup_version 2. Can be changed without replacing the VM.",
+                ValidateFunc: validation.IntAtLeast(1),
+            },
+            "cpuset": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                Description:  "CPUs the VM's Firecracker process, its vCPU threads included, may run on, as a CPU list such as \"0-3,8\", enforced through the cpuset.cpus file of its cgroup. Requires a jailer block using the cpuset controller. Can be changed without replacing the VM.",
+                ValidateFunc: validateCPUList,
+            },
+            "numa_node": {
+                Type:         schema.TypeInt,
+                Optional:     true,
+                Default:      -1,
+                Description:  "NUMA node the VM's memory is allocated on, enforced through the cpuset.mems file of its cgroup. Without cpuset, the VM runs on the CPUs of the node. Requires a jailer block using the cpuset controller. -1 leaves the VM unpinned. Can be changed without replacing the VM.",
+                ValidateFunc: validation.IntAtLeast(-1),
+            },
             "network_interfaces": {
                 Type:        schema.TypeList,
                 Optional:    true,
-                Description: "List of network interfaces attached to the VM. Each interface connects to a TAP device on the host.",
-                Elem: &schema.Resource{
+                Description: "List of network interfaces attached to the VM. Each interface connects to a TAP device on the host. Interfaces are matched by iface_id, so reordering them does not change the VM.",
+                Elem: matchElementsByID("network_interfaces", "iface_id", &schema.Resource{
                     Schema: map[string]*schema.Schema{
                         "iface_id": {
                             Type:         schema.TypeString,
@@ -111,12 +226,863 @@ func resourceFirecrackerVM() *schema.Resource {
                         "guest_mac": {
                             Type:         schema.TypeString,
                             Optional:     true,
-                            Description:  "MAC address for the guest network interface. If not specified, Firecracker will generate one. Format: 'XX:XX:XX:XX:XX:XX'.",
+                            Computed:     true,
+                            Description:  "MAC address for the guest network interface. If not specified, a stable, locally administered address is derived from the VM ID and iface_id when the VM is created. Format: 'XX:XX:XX:XX:XX:XX'.",
                             ValidateFunc: validation.StringMatch(regexp.MustCompile(`^([0-9A-Fa-f]{2}[:-]){5}([0-9A-Fa-f]{2})$`), "must be a valid MAC address"),
                         },
+                        "bridge": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Description:  "Name of the Linux bridge the TAP device should be attached to. The TAP device is attached when the VM is created and its attachment is checked on every refresh.",
+                            ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+                        },
+                    },
+                }),
+            },
+            "attached_network_interfaces": {
+                Type:        schema.TypeList,
+                Computed:    true,
+                Description: "Network interfaces attached to the VM with firecracker_network_interface when it was created.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "iface_id": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "ID of the network interface.",
+                        },
+                        "host_dev_name": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Host device name for the interface.",
+                        },
+                        "guest_mac": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "MAC address for the guest network interface, if set.",
+                        },
+                        "bridge": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Name of the Linux bridge the TAP device is attached to, if any.",
+                        },
+                    },
+                },
+            },
+            "wait_for_ssh": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "Wait for the guest's SSH server after the VM starts, so provisioners can connect reliably.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "host": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "Address of the guest's SSH server. Defaults to the address from guest_ip or the CNI attachment.",
+                        },
+                        "port": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            Default:      22,
+                            Description:  "Port of the guest's SSH server.",
+                            ValidateFunc: validation.IsPortNumber,
+                        },
+                        "user": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Default:     "root",
+                            Description: "User to log in as.",
+                        },
+                        "private_key": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Sensitive:   true,
+                            Description: "Private key used to log in. When set, the guest is ready once the user can log in; otherwise once the SSH server answers.",
+                        },
+                        "timeout": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Default:      "5m",
+                            Description:  "How long to wait for SSH, as a duration such as '90s' or '5m'.",
+                            ValidateFunc: validateDuration,
+                        },
+                    },
+                },
+            },
+            "ssh_host": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Address of the guest's SSH server, set when wait_for_ssh is configured.",
+            },
+            "ssh_port": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Port of the guest's SSH server, set when wait_for_ssh is configured.",
+            },
+            "ssh_user": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "User to log in to the guest as, set when wait_for_ssh is configured.",
+            },
+            "boot_time_ms": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Milliseconds from starting the VM until the guest passed its readiness checks, wait_for_ssh and guest_agent. Only set when one of them is configured.",
+            },
+            "mmds": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                ForceNew:    true,
+                MaxItems:    1,
+                Description: "Settings of the microVM metadata service (MMDS). Without this block, MMDS is only enabled when the provider publishes guest settings, using version V1.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "version": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Default:      mmdsVersionV1,
+                            Description:  "MMDS version. `V2` only answers requests carrying a session token, rejecting V1-style requests.",
+                            ValidateFunc: validation.StringInSlice([]string{mmdsVersionV1, mmdsVersionV2}, false),
+                        },
+                        "ipv4_address": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Description:  "IPv4 address the guest reaches MMDS at. Defaults to Firecracker's 169.254.169.254.",
+                            ValidateFunc: validation.IsIPv4Address,
+                        },
+                        "token_ttl_seconds": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Description:  "Lifetime guests should request for their V2 session tokens, published to the guest through MMDS. Defaults to the maximum of 21600. Requires version `V2`.",
+                            ValidateFunc: validation.IntBetween(1, mmdsMaxTokenTTL),
+                        },
+                        "network_interfaces": {
+                            Type:        schema.TypeList,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Description: "iface_id of the network interfaces the guest reaches MMDS through. Defaults to all of the VM's interfaces.",
+                            Elem: &schema.Schema{
+                                Type:         schema.TypeString,
+                                ValidateFunc: validation.StringIsNotEmpty,
+                            },
+                        },
+                    },
+                },
+            },
+            "cloud_init": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                ForceNew:    true,
+                MaxItems:    1,
+                Description: "cloud-init data for the guest, served from a NoCloud seed drive or through MMDS in the EC2 metadata layout.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "datasource": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Default:      cloudInitDatasourceNoCloud,
+                            Description:  "How the data reaches the guest: `nocloud` attaches a read-only seed drive labelled `cidata`, `mmds` publishes it through MMDS for cloud-init's EC2 datasource.",
+                            ValidateFunc: validation.StringInSlice([]string{cloudInitDatasourceNoCloud, cloudInitDatasourceMMDS}, false),
+                        },
+                        "user_data": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Sensitive:   true,
+                            Description: "User data, such as a #cloud-config document or a shell script.",
+                        },
+                        "meta_data": {
+                            Type:        schema.TypeMap,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Sensitive:   true,
+                            Elem:        &schema.Schema{Type: schema.TypeString},
+                            Description: "Instance metadata, such as local-hostname. instance-id defaults to the VM ID.",
+                        },
+                        "network_config": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Sensitive:   true,
+                            Description: "Network configuration in cloud-init's network config format. Only supported by the nocloud datasource.",
+                        },
+                    },
+                },
+            },
+            "entropy_device": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                ForceNew:    true,
+                Default:     false,
+                Description: "Attach a virtio-rng entropy device feeding the guest's random number generator from the host. Requires Firecracker 1.4.0 or later.",
+            },
+            "vsock": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                ForceNew:    true,
+                MaxItems:    1,
+                Description: "Virtio vsock device connecting the guest to a Unix domain socket on the host.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "guest_cid": {
+                            Type:         schema.TypeInt,
+                            Required:     true,
+                            ForceNew:     true,
+                            Description:  "Context ID of the guest. Must be at least 3.",
+                            ValidateFunc: validation.IntAtLeast(3),
+                        },
+                        "uds_path": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            ForceNew:     true,
+                            Description:  "Path of the Unix domain socket Firecracker creates on the host for the vsock device.",
+                            ValidateFunc: validation.StringIsNotEmpty,
+                        },
+                    },
+                },
+            },
+            "guest_agent": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "Guest agent reached over the vsock device. The VM is only considered created once the agent answers health checks.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "port": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            Default:      defaultGuestAgentPort,
+                            Description:  "Vsock port the guest agent listens on.",
+                            ValidateFunc: validation.IntAtLeast(1),
+                        },
+                        "health_timeout": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Default:      "2m",
+                            Description:  "How long to wait for the guest agent after boot, as a duration such as '90s' or '5m'.",
+                            ValidateFunc: validateDuration,
+                        },
+                    },
+                },
+            },
+            "guest_exec": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                Description: "Commands run in the guest through the guest agent after the VM starts, in order. Like provisioners, they only run when the VM is created.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "command": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            Description:  "Shell command to run in the guest.",
+                            ValidateFunc: validation.StringIsNotEmpty,
+                        },
+                        "timeout": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Default:      "1m",
+                            Description:  "How long the command may run, as a duration such as '90s' or '5m'.",
+                            ValidateFunc: validateDuration,
+                        },
+                    },
+                },
+            },
+            "guest_agent_healthy": {
+                Type:        schema.TypeBool,
+                Computed:    true,
+                Description: "Whether the guest agent answered its health check on the last refresh.",
+            },
+            "health_status": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Health of the VM on the last create, update or refresh: `healthy`, `unhealthy` when the VM runs but a check failed, `unreachable` when its host could not be reached, `failed` when creating or updating it failed, `provisioning` while an `async` VM is being configured and booted, or `lost` when its Firecracker process was lost, such as to a host reboot.",
+            },
+            "rendered_config_json": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Configuration the VM was created with, as a JSON document `firecracker --config-file` boots the same VM from, to reproduce or debug it outside Terraform. MMDS contents and sensitive_boot_args are left out.",
+            },
+            "instance_state": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "State Firecracker reported for the VM on the last refresh: `Not started`, `Running` or `Paused`.",
+            },
+            "vmm_version": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Firecracker release serving the VM's API, as reported on the last refresh.",
+            },
+            "app_name": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Name of the application serving the VM's API, as reported on the last refresh.",
+            },
+            "async": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                Default:     false,
+                Description: "When true, creating the VM only places it and gives it its ID, and the provider's workers configure and boot it while Terraform moves on to other resources. Refreshes report its progress in health_status, and the firecracker_vm_status data source waits for it. A VM whose provisioning failed is replaced on the next apply.",
+            },
+            "recovery_policy": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                Default:      recoveryPolicyRemove,
+                Description:  "What a refresh does with the VM when it was lost, such as to a host reboot: `remove` it from the state so it is created again, plan its `replace`ment, or `relaunch` it with the same ID once its Firecracker API is back.",
+                ValidateFunc: validation.StringInSlice([]string{recoveryPolicyRemove, recoveryPolicyReplace, recoveryPolicyRelaunch}, false),
+            },
+            "recreate_on_failure": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                Default:     false,
+                Description: "When true, a VM a refresh finds crashed or exited, its Firecracker process gone or no longer running a VM, is kept in the state and replaced on the next apply, as with recovery_policy `replace`, rather than removed from the state. Cannot be used with recovery_policy `relaunch`.",
+            },
+            "recovery_pending": {
+                Type:        schema.TypeBool,
+                Computed:    true,
+                Description: "Whether the VM was lost and its recovery_policy replaces it on the next apply.",
+            },
+            "last_error": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "Most recent error creating or updating the VM, such as a boot failure.",
+            },
+            "last_error_time": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "When last_error occurred, in RFC 3339 format.",
+            },
+            "health_history": {
+                Type:        schema.TypeList,
+                Computed:    true,
+                Description: "The most recent changes of health_status, oldest first.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "status": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Health status the VM changed to.",
+                        },
+                        "message": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Why the VM is not healthy. Empty for `healthy`.",
+                        },
+                        "time": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "When the change was observed, in RFC 3339 format.",
+                        },
                     },
                 },
             },
+            "drive_compacted_at": {
+                Type:        schema.TypeMap,
+                Computed:    true,
+                Description: "When each drive with a compact_interval was last compacted, in RFC 3339 format, by drive ID.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "golden_snapshot": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "Take a snapshot of the VM once it has passed its health checks after its first boot, registered under a name for clones to start from. Of several VMs sharing the name, only the first one to become healthy takes it.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "name": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            Description:  "Name the snapshot is registered under.",
+                            ValidateFunc: validation.StringMatch(networkNameRegexp, "must be at most 63 letters, digits, '.', '_' or '-'"),
+                        },
+                        "directory": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "Directory Firecracker writes the snapshot files to, as seen by the Firecracker process. Defaults to a directory named after the snapshot under the provider's state_dir/snapshots.",
+                        },
+                        "snapshot_path": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Path of the snapshot's VM state file, once taken.",
+                        },
+                        "mem_file_path": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Path of the snapshot's guest memory file, once taken.",
+                        },
+                        "source_vm_id": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "ID of the VM the snapshot was taken from.",
+                        },
+                    },
+                },
+            },
+            "jailer": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "How the jailer runs the VM's Firecracker process, when it is jailed. The provider does not start the jailer, these settings compute where it puts the VM's files and where the provider stages them.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "id": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Description:  "ID the jailer was started with (--id). Defaults to the VM ID.",
+                            ValidateFunc: validation.StringMatch(jailerIDRegexp, "must be at most 64 letters, digits or '-'"),
+                        },
+                        "exec_file": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Default:     defaultJailerExecFile,
+                            Description: "Firecracker binary the jailer runs (--exec-file). Only its file name is used.",
+                        },
+                        "chroot_base_dir": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Default:     defaultJailerChrootBaseDir,
+                            Description: "Base directory of the jailer's chroots (--chroot-base-dir).",
+                        },
+                        "cgroup_version": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            Default:      1,
+                            Description:  "cgroup version the jailer uses (--cgroup-version), 1 or 2.",
+                            ValidateFunc: validation.IntInSlice([]int{1, 2}),
+                        },
+                        "parent_cgroup": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "Parent cgroup of the VM's cgroups (--parent-cgroup). Defaults to the file name of exec_file.",
+                        },
+                        "cgroup_controllers": {
+                            Type:        schema.TypeList,
+                            Optional:    true,
+                            Description: "cgroup v1 controllers the jailer configures, those of its --cgroup arguments. Defaults to cpu, cpuset and memory.",
+                            Elem:        &schema.Schema{Type: schema.TypeString},
+                        },
+                        "stage_files": {
+                            Type:        schema.TypeBool,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Default:     true,
+                            Description: "Hard-link, or copy when the chroot is on another filesystem, the kernel, initrd and drive images into the chroot before configuring the VM, and give Firecracker their paths in the chroot. When false, paths such as path_on_host are relative to the chroot and the files must already be there.",
+                        },
+                        "chroot_path": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Directory the jailer chroots Firecracker into.",
+                        },
+                        "api_socket_path": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Path of Firecracker's API socket on the host.",
+                        },
+                        "cgroup_paths": {
+                            Type:        schema.TypeList,
+                            Computed:    true,
+                            Description: "cgroups Firecracker runs in.",
+                            Elem:        &schema.Schema{Type: schema.TypeString},
+                        },
+                        "device_paths": {
+                            Type:        schema.TypeMap,
+                            Computed:    true,
+                            Description: "Device nodes the jailer creates in the chroot, by name: kvm, net_tun and urandom.",
+                            Elem:        &schema.Schema{Type: schema.TypeString},
+                        },
+                        "drive_paths": {
+                            Type:        schema.TypeMap,
+                            Computed:    true,
+                            Description: "Where the image of each drive is in the chroot on the host, by drive ID.",
+                            Elem:        &schema.Schema{Type: schema.TypeString},
+                        },
+                    },
+                },
+            },
+            "hooks": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "Commands run on the host running Terraform when the VM is created, started or destroyed, with the VM described by FIRECRACKER_* environment variables.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "on_create": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "Command run once the VM was created and booted. Failing taints the VM.",
+                        },
+                        "on_start": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "Command run every time the provider starts the VM: after creating it, and when relaunching it after it was lost.",
+                        },
+                        "on_destroy": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "Command run before the VM is shut down and destroyed. Failing stops the VM from being destroyed.",
+                        },
+                        "interpreter": {
+                            Type:        schema.TypeList,
+                            Optional:    true,
+                            Description: "Program and arguments running the commands, which are passed as the last argument. Defaults to /bin/sh -c.",
+                            Elem:        &schema.Schema{Type: schema.TypeString},
+                        },
+                    },
+                },
+            },
+            "restart_policy": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                RequiredWith: []string{"systemd_unit"},
+                Description:  "When the VM is started again after its Firecracker process exits, such as on a guest crash: `never`, `on-failure` or `always`. The process is supervised by the VM's systemd_unit, whose `restart` this overrides.",
+                ValidateFunc: validation.StringInSlice([]string{restartPolicyNever, restartPolicyOnFailure, restartPolicyAlways}, false),
+            },
+            "systemd_unit": {
+                Type:          schema.TypeList,
+                Optional:      true,
+                ForceNew:      true,
+                MaxItems:      1,
+                ConflictsWith: []string{"staging", "cni"},
+                Description:   "Install a systemd unit starting the VM's Firecracker process, or the jailer, at boot, booting the VM from its configuration, so the VM survives host reboots. Only for VMs on the host running Terraform. Destroying the VM removes the unit.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "api_socket": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "Path of the API socket Firecracker is started with, where the provider reaches the VM's API. Required unless the VM has a jailer block, whose socket is in the chroot.",
+                        },
+                        "firecracker_binary": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "Path of the Firecracker binary. Defaults to the exec_file of the jailer block, or firecracker, in /usr/bin.",
+                        },
+                        "jailer_binary": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Default:     filepath.Join(defaultSystemdBinDir, "jailer"),
+                            Description: "Path of the jailer binary, for VMs with a jailer block.",
+                        },
+                        "uid": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            Default:      0,
+                            Description:  "User the jailer runs Firecracker as (--uid), for VMs with a jailer block.",
+                            ValidateFunc: validation.IntAtLeast(0),
+                        },
+                        "gid": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            Default:      0,
+                            Description:  "Group the jailer runs Firecracker as (--gid), for VMs with a jailer block.",
+                            ValidateFunc: validation.IntAtLeast(0),
+                        },
+                        "restart": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Default:      systemdRestartOnFailure,
+                            Description:  "When systemd starts the VM again after its Firecracker process exits: no, on-failure or always.",
+                            ValidateFunc: validation.StringInSlice([]string{systemdRestartNo, systemdRestartOnFailure, systemdRestartAlways}, false),
+                        },
+                        "unit_dir": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Default:     defaultSystemdUnitDir,
+                            Description: "Directory the unit is installed in.",
+                        },
+                        "unit_name": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Name of the unit, firecracker-<VM ID>.service.",
+                        },
+                        "unit_path": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Path of the unit file.",
+                        },
+                        "config_path": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Path of the configuration file the VM boots from.",
+                        },
+                    },
+                },
+            },
+            "staging": {
+                Type:          schema.TypeList,
+                Optional:      true,
+                ForceNew:      true,
+                MaxItems:      1,
+                ConflictsWith: []string{"jailer"},
+                Description:   "Upload the kernel, initrd and drive images from the host running Terraform to the host running the VM's Firecracker process before creating the VM, over SSH.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "method": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Default:      stagingMethodRsync,
+                            Description:  "How files are uploaded: rsync, which only transfers what changed and keeps sparse images sparse, scp, sftp, or http, which sends them with PUT requests to upload_url.",
+                            ValidateFunc: validation.StringInSlice([]string{stagingMethodSCP, stagingMethodRsync, stagingMethodSFTP, stagingMethodHTTP}, false),
+                        },
+                        "target_dir": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            ForceNew:     true,
+                            Description:  "Directory on the remote host files are uploaded to, in a directory named after the VM ID.",
+                            ValidateFunc: validation.StringMatch(stagingTargetDirRegexp, "must be an absolute path of letters, digits, '.', '_', '-' and '/'"),
+                        },
+                        "ssh_host": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Description: "Host to connect to over SSH. Defaults to the host of the Firecracker API URL serving the VM.",
+                        },
+                        "ssh_user": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Description: "User to connect as. Defaults to the SSH client's configuration.",
+                        },
+                        "ssh_port": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Description:  "Port of the SSH server. Defaults to the SSH client's configuration.",
+                            ValidateFunc: validation.IsPortNumber,
+                        },
+                        "upload_url": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Description:  "URL of the directory served from target_dir that files are uploaded to with the http method.",
+                            ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+                        },
+                        "staged_paths": {
+                            Type:        schema.TypeMap,
+                            Computed:    true,
+                            Description: "Where files were uploaded to on the remote host: kernel, initrd and drive IDs.",
+                            Elem:        &schema.Schema{Type: schema.TypeString},
+                        },
+                    },
+                },
+            },
+            "migration": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                MaxItems:    1,
+                Description: "How the VM is moved when its host changes, with the migration experiment enabled. Files are copied between the hosts over SSH.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "directory": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            Default:      defaultMigrationDir,
+                            Description:  "Directory on both hosts the VM's snapshot is written to, in a directory named after the VM ID.",
+                            ValidateFunc: validation.StringMatch(stagingTargetDirRegexp, "must be an absolute path of letters, digits, '.', '_', '-' and '/'"),
+                        },
+                        "ssh_user": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            Description: "User to connect to the hosts as. Defaults to the SSH client's configuration.",
+                        },
+                        "ssh_port": {
+                            Type:         schema.TypeInt,
+                            Optional:     true,
+                            Description:  "Port of the hosts' SSH servers. Defaults to the SSH client's configuration.",
+                            ValidateFunc: validation.IsPortNumber,
+                        },
+                    },
+                },
+            },
+            "netns": {
+                Type:          schema.TypeString,
+                Optional:      true,
+                ForceNew:      true,
+                Description:   "Name of the network namespace the VM's Firecracker process runs in, the one its TAP devices are created in with the netns of firecracker_tap. The namespace must exist when the VM is created. Bridges the interfaces are attached to must be in the namespace too.",
+                ValidateFunc:  validation.StringMatch(netnsNameRegexp, "must be a network namespace name of at most 64 letters, digits, '_', '.' or '-'"),
+                ConflictsWith: []string{"cni"},
+            },
+            "heal_networking": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                Default:     false,
+                Description: "When true, TAP devices found detached from their `bridge` on refresh (e.g., after host networking was restarted) are attached again. When false, the drift is reported as a warning.",
+            },
+            "cni": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                ForceNew:    true,
+                MaxItems:    1,
+                Description: "Attach the VM to a CNI network. The plugin chain must end with tc-redirect-tap, which provides the TAP device used as an additional network interface.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "network_name": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            ForceNew:     true,
+                            Description:  "Name of the CNI network list to use, as found in config_dir.",
+                            ValidateFunc: validation.StringIsNotEmpty,
+                        },
+                        "config_dir": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Default:     defaultCNIConfigDir,
+                            Description: "Directory containing the CNI network configuration files.",
+                        },
+                        "bin_dir": {
+                            Type:        schema.TypeString,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Default:     defaultCNIBinDir,
+                            Description: "Directory containing the CNI plugin binaries.",
+                        },
+                        "netns": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            ForceNew:     true,
+                            Description:  "Path of the network namespace Firecracker runs in (e.g., '/var/run/netns/vm1'). The TAP device is created in this namespace.",
+                            ValidateFunc: validation.StringIsNotEmpty,
+                        },
+                        "if_name": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Default:      defaultCNIIfName,
+                            Description:  "Name of the interface the CNI plugins create inside the network namespace.",
+                            ValidateFunc: validation.StringMatch(linkNameRegexp, "must be a valid interface name of at most 15 characters"),
+                        },
+                        "iface_id": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Default:      "eth0",
+                            Description:  "Firecracker ID of the network interface backed by the CNI TAP device. Must not clash with network_interfaces.",
+                            ValidateFunc: validation.StringIsNotEmpty,
+                        },
+                        "tap_device": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Name of the TAP device created by the CNI plugins.",
+                        },
+                        "guest_mac": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "MAC address assigned to the guest interface.",
+                        },
+                        "guest_ip": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Guest IPv4 address in CIDR notation assigned by the IPAM plugin.",
+                        },
+                        "gateway": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Gateway assigned by the IPAM plugin.",
+                        },
+                    },
+                },
+            },
+            "host": {
+                Type:          schema.TypeString,
+                Optional:      true,
+                Computed:      true,
+                ConflictsWith: []string{"placement", "placement_group"},
+                Description:   "Name of the host from the provider's host pool that the VM runs on. When set, the VM is placed on this host instead of one chosen by the provider. Changing it replaces the VM, or moves it with the migration experiment. Empty when the provider is configured with a single base_url.",
+            },
+            "placement": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                ForceNew:    true,
+                MaxItems:    1,
+                Description: "Placement constraints used to choose a host from the provider's host pool.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "selector": {
+                            Type:        schema.TypeMap,
+                            Optional:    true,
+                            ForceNew:    true,
+                            Description: "Labels a host must carry for the VM to be placed on it.",
+                            Elem:        &schema.Schema{Type: schema.TypeString},
+                        },
+                    },
+                },
+            },
+            "placement_group": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                ForceNew:    true,
+                MaxItems:    1,
+                Description: "Placement group the VM belongs to. With the `anti-affinity` policy, members of the group are placed on different hosts of the provider's host pool.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "name": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            ForceNew:     true,
+                            Description:  "Name of the placement group shared by all of its members.",
+                            ValidateFunc: validation.StringIsNotEmpty,
+                        },
+                        "policy": {
+                            Type:         schema.TypeString,
+                            Optional:     true,
+                            ForceNew:     true,
+                            Default:      placementPolicyAntiAffinity,
+                            Description:  "Placement policy of the group. Only `anti-affinity` is supported.",
+                            ValidateFunc: validation.StringInSlice([]string{placementPolicyAntiAffinity}, false),
+                        },
+                    },
+                },
+            },
+            "guest_ip": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Description:  "Guest IPv4 address in CIDR notation (e.g., `firecracker_ip_allocation.web.guest_ip_cidr`). Published to the guest through MMDS under the `firecracker` key.",
+                ValidateFunc: validation.IsCIDR,
+            },
+            "guest_gateway": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Description:  "Default gateway of the guest. Published to the guest through MMDS under the `firecracker` key.",
+                ValidateFunc: validation.IsIPv4Address,
+            },
+            "timezone": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Description:  "IANA time zone for the guest (e.g., 'Europe/Berlin'). Published to the guest through MMDS under the `firecracker` key, and applied by cloud-init with a `cloud_init` block.",
+                ValidateFunc: validation.StringMatch(timezoneRegexp, "must be an IANA time zone name such as 'UTC' or 'Europe/Berlin'"),
+            },
+            "locale": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Description:  "Locale for the guest (e.g., 'en_US.UTF-8'). Published to the guest through MMDS under the `firecracker` key, and applied by cloud-init with a `cloud_init` block.",
+                ValidateFunc: validation.StringMatch(localeRegexp, "must be a locale name such as 'C.UTF-8' or 'en_US.UTF-8'"),
+            },
+            "hostname": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Description:  "Hostname of the guest. Published to the guest through MMDS under the `firecracker` key, and as local-hostname to cloud-init.",
+                ValidateFunc: validation.StringMatch(hostnameRegexp, "must be a valid hostname of at most 63 characters per label"),
+            },
+            "ssh_authorized_keys": {
+                Type:        schema.TypeList,
+                Optional:    true,
+                ForceNew:    true,
+                Description: "SSH public keys allowed to log in to the guest. Published to the guest through MMDS under the `firecracker` key, and as public-keys to cloud-init.",
+                Elem: &schema.Schema{
+                    Type:         schema.TypeString,
+                    ValidateFunc: validateAuthorizedKey,
+                },
+            },
         },
         Timeouts: &schema.ResourceTimeout{
             Create: schema.DefaultTimeout(10 * time.Minute),
@@ -125,169 +1091,593 @@ func resourceFirecrackerVM() *schema.Resource {
             Read:   schema.DefaultTimeout(1 * time.Minute),
         },
         Importer: &schema.ResourceImporter{
-            StateContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
-                client := meta.(*FirecrackerClient)
-                vmID := d.Id()
-                
-                tflog.Info(ctx, "Importing Firecracker VM", map[string]interface{}{
-                    "id": vmID,
-                })
-                
-                // Get VM details from API
-                vmInfo, err := client.GetVM(ctx, vmID)
-                if err != nil {
-                    return nil, fmt.Errorf("error importing VM %s: %w", vmID, err)
-                }
-                
-                if vmInfo == nil {
-                    return nil, fmt.Errorf("VM with ID %s not found", vmID)
-                }
-                
-                // Read the resource data from the imported VM
-                d.SetId(vmID)
-                resourceFirecrackerVMRead(ctx, d, meta)
-                
-                return []*schema.ResourceData{d}, nil
-            },
+            StateContext: resourceFirecrackerVMImport,
         },
     }
 }
 
+const (
+    idSourceUUID     = "uuid"
+    idSourceNameHash = "name-hash"
+)
+
+// vmIDNamespace is the UUID namespace used to derive name-hash VM IDs.
+// Changing it would change the ID of every name-hash VM, so it must stay fixed.
+var vmIDNamespace = uuid.MustParse("6f1c3b8e-3d2a-5b7e-9c41-0a8f2e7d4b19")
+
+// vmIDFromConfig returns the ID for a new VM based on the id_source setting.
+// For name-hash, the same name always yields the same UUID (version 5).
+func vmIDFromConfig(idSource, name string) (string, error) {
+    switch idSource {
+    case "", idSourceUUID:
+        return uuid.New().String(), nil
+    case idSourceNameHash:
+        if name == "" {
+            return "", fmt.Errorf("name must be set when id_source is %q", idSourceNameHash)
+        }
+        return uuid.NewSHA1(vmIDNamespace, []byte(name)).String(), nil
+    default:
+        return "", fmt.Errorf("unsupported id_source %q", idSource)
+    }
+}
+
+// resourceFirecrackerVMCustomizeDiff performs plan-time validation across attributes.
+func resourceFirecrackerVMCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+    if d.Get("id_source").(string) == idSourceNameHash && d.NewValueKnown("name") && d.Get("name").(string) == "" {
+        return fmt.Errorf("name must be set when id_source is %q", idSourceNameHash)
+    }
+
+    if len(d.Get("systemd_unit").([]interface{})) > 0 && len(d.Get("jailer").([]interface{})) == 0 && d.NewValueKnown("systemd_unit.0.api_socket") && d.Get("systemd_unit.0.api_socket").(string) == "" {
+        return fmt.Errorf("systemd_unit api_socket must be set for VMs without a jailer block")
+    }
+
+    if d.Get("recreate_on_failure").(bool) && d.Get("recovery_policy").(string) == recoveryPolicyRelaunch {
+        return fmt.Errorf("recreate_on_failure cannot be used with recovery_policy %q", recoveryPolicyRelaunch)
+    }
+
+    if d.Get("mmds.0.token_ttl_seconds").(int) != 0 && d.Get("mmds.0.version").(string) != mmdsVersionV2 {
+        return fmt.Errorf("mmds token_ttl_seconds requires version %q, V1 does not use session tokens", mmdsVersionV2)
+    }
+
+    if err := checkMMDSInterfaces(d); err != nil {
+        return err
+    }
+
+    if d.Get("cloud_init.0.network_config").(string) != "" && d.Get("cloud_init.0.datasource").(string) == cloudInitDatasourceMMDS {
+        return fmt.Errorf("cloud_init network_config requires the %q datasource, the EC2 layout served through MMDS has no network config", cloudInitDatasourceNoCloud)
+    }
+
+    if len(d.Get("guest_agent").([]interface{})) > 0 && len(d.Get("vsock").([]interface{})) == 0 {
+        return fmt.Errorf("guest_agent requires a vsock block")
+    }
+    if len(d.Get("guest_exec").([]interface{})) > 0 && len(d.Get("guest_agent").([]interface{})) == 0 {
+        return fmt.Errorf("guest_exec requires a guest_agent block")
+    }
+
+    if err := checkScratchDrives(d); err != nil {
+        return err
+    }
+    if err := checkDriveIDs(d); err != nil {
+        return err
+    }
+    if err := checkDriveRestores(d); err != nil {
+        return err
+    }
+    if err := checkStaging(d); err != nil {
+        return err
+    }
+    if err := checkCPUQuota(d); err != nil {
+        return err
+    }
+    if err := checkCPUPinning(d); err != nil {
+        return err
+    }
+    if err := planRecovery(d); err != nil {
+        return err
+    }
+    if err := planCompaction(d, m); err != nil {
+        return err
+    }
+    provider, _ := m.(*FirecrackerClient)
+    if err := planMigration(provider, d); err != nil {
+        return err
+    }
+
+    if provider != nil {
+        if err := checkPlacementGroup(provider, d); err != nil {
+            return err
+        }
+        if err := checkSharedDrives(provider, d); err != nil {
+            return err
+        }
+        if err := checkSecretReferences(provider, d); err != nil {
+            return err
+        }
+        // The policy is checked first, so files outside it are not even looked at
+        local := len(provider.hosts) == 0 || len(d.Get("staging").([]interface{})) > 0
+        if err := checkVMAllowedPaths(d, provider.allowedPaths, local); err != nil {
+            return err
+        }
+        // Files of VMs placed on a pooled host are on that host, unless they are uploaded there
+        if local {
+            if err := checkHostFiles(d); err != nil {
+                return err
+            }
+        }
+    }
+
+    return nil
+}
+
+// checkDriveIDs fails the plan unless exactly one drive is the root device and drive IDs
+// are unique, which Firecracker would otherwise only reject at apply time. The root device
+// is always attached as "rootfs", and the NoCloud seed image as "cidata", so other drives
+// cannot use these IDs. Drives with an unknown drive_id or is_root_device are not checked.
+func checkDriveIDs(d *schema.ResourceDiff) error {
+    // VMs created from config_json have no drives here
+    if !d.NewValueKnown("drives") || len(d.Get("drives").([]interface{})) == 0 {
+        return nil
+    }
+
+    seen := map[string]bool{}
+    if len(d.Get("cloud_init").([]interface{})) > 0 && d.Get("cloud_init.0.datasource").(string) != cloudInitDatasourceMMDS {
+        seen[cloudInitDriveID] = true
+    }
+    var roots []string
+    for i, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok || !d.NewValueKnown(fmt.Sprintf("drives.%d.drive_id", i)) || !d.NewValueKnown(fmt.Sprintf("drives.%d.is_root_device", i)) {
+            return nil
+        }
+
+        driveID, _ := drive["drive_id"].(string)
+        if isRootDevice, _ := drive["is_root_device"].(bool); isRootDevice {
+            roots = append(roots, driveID)
+            continue
+        }
+        if driveID == "rootfs" {
+            return fmt.Errorf("drive_id %q is reserved for the root device, rename the drive", driveID)
+        }
+        if seen[driveID] {
+            if driveID == cloudInitDriveID {
+                return fmt.Errorf("drive_id %q is reserved for the cloud_init seed drive, rename the drive", driveID)
+            }
+            return fmt.Errorf("drive_id %q is used by more than one drive", driveID)
+        }
+        seen[driveID] = true
+    }
+
+    switch len(roots) {
+    case 0:
+        return fmt.Errorf("no drive sets is_root_device = true, exactly one drive must hold the root filesystem")
+    case 1:
+        return nil
+    default:
+        return fmt.Errorf("drives %s all set is_root_device = true, exactly one drive can be the root device", strings.Join(roots, ", "))
+    }
+}
+
+// placementGroupName returns the name of the VM's placement group, or "" if it has none.
+func placementGroupName(raw interface{}) string {
+    groups, _ := raw.([]interface{})
+    if len(groups) == 0 || groups[0] == nil {
+        return ""
+    }
+    return groups[0].(map[string]interface{})["name"].(string)
+}
+
+// checkPlacementGroup registers the planned VM with its placement group and fails the plan
+// when the group has more members than there are hosts to keep them apart.
+func checkPlacementGroup(provider *FirecrackerClient, d *schema.ResourceDiff) error {
+    group := placementGroupName(d.Get("placement_group"))
+    if group == "" || provider.placementGroups == nil {
+        return nil
+    }
+
+    if len(provider.hosts) == 0 {
+        return fmt.Errorf("placement_group requires host blocks in the provider configuration")
+    }
+
+    // Existing members keep their host, new members are counted until they are placed
+    size := 0
+    if d.Id() != "" && !d.HasChange("placement_group") {
+        host, _ := d.Get("host").(string)
+        provider.placementGroups.record(group, d.Id(), host)
+        size = provider.placementGroups.size(group)
+    } else {
+        size = provider.placementGroups.recordPlanned(group, plannedMemberKey(d.Get))
+    }
+
+    var selector map[string]string
+    if placement := d.Get("placement").([]interface{}); len(placement) > 0 && placement[0] != nil {
+        selector = expandLabels(placement[0].(map[string]interface{})["selector"].(map[string]interface{}))
+    }
+    if available := len(provider.matchingHosts(selector)); size > available {
+        return fmt.Errorf("placement group %q has %d members but only %d hosts match placement selector %s, anti-affinity cannot be satisfied", group, size, available, formatSelector(selector))
+    }
+
+    return nil
+}
+
+// placementSelector returns the host selector configured in the placement block.
+func placementSelector(d *schema.ResourceData) map[string]string {
+    placement := d.Get("placement").([]interface{})
+    if len(placement) == 0 || placement[0] == nil {
+        return map[string]string{}
+    }
+    return expandLabels(placement[0].(map[string]interface{})["selector"].(map[string]interface{}))
+}
+
+// assignGuestMACs sets the guest_mac of the network interfaces that do not set one to an
+// address derived from the VM ID and the interface ID, so the address is known before the
+// VM boots instead of being picked by Firecracker.
+func assignGuestMACs(d *schema.ResourceData, vmID string) error {
+    ifaces := d.Get("network_interfaces").([]interface{})
+    for _, raw := range ifaces {
+        iface, ok := raw.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        if mac, _ := iface["guest_mac"].(string); mac == "" {
+            iface["guest_mac"] = guestMAC(vmID + "/" + iface["iface_id"].(string))
+        }
+    }
+    if err := d.Set("network_interfaces", ifaces); err != nil {
+        return fmt.Errorf("failed to set guest MAC addresses: %w", err)
+    }
+    return nil
+}
+
 // resourceFirecrackerVMCreate creates a new Firecracker VM.
 func resourceFirecrackerVMCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-    client := m.(*FirecrackerClient)
+    provider := m.(*FirecrackerClient)
+    ctx = maskSensitiveBootArgs(ctx, d)
+
+    // Generate the VM ID according to the configured id_source
+    vmID, err := vmIDFromConfig(d.Get("id_source").(string), d.Get("name").(string))
+    if err != nil {
+        return diag.FromErr(err)
+    }
 
-    // Generate a unique ID for the VM
-    vmID := uuid.New().String()
+    // Place the VM on a host when the provider manages a host pool
+    client := provider
+    if len(provider.hosts) > 0 {
+        var host *hostEntry
+        if name := d.Get("host").(string); name != "" {
+            host, err = provider.placeOnHost(vmID, name)
+        } else if group := placementGroupName(d.Get("placement_group")); group != "" {
+            host, err = provider.placementGroups.place(provider, group, vmID, plannedMemberKey(d.Get), placementSelector(d))
+        } else {
+            host, err = provider.selectHost(vmID, placementSelector(d), nil)
+        }
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        tflog.Info(ctx, "Placing Firecracker VM", map[string]interface{}{
+            "id":   vmID,
+            "host": host.Name,
+        })
+        d.Set("host", host.Name)
+        client = host.Client
+
+        // Free the host's capacity when the VM is not created
+        defer func() {
+            if d.Id() == "" {
+                provider.hostLoads.release(vmID)
+            }
+        }()
+    } else if d.Get("host").(string) != "" || len(placementSelector(d)) > 0 || placementGroupName(d.Get("placement_group")) != "" {
+        return diag.FromErr(fmt.Errorf("placement requires host blocks in the provider configuration"))
+    }
+    // Refuse settings the host's Firecracker release does not support before creating anything
+    diags := checkFirecrackerVersion(ctx, client, d)
+    if diags.HasError() {
+        return diags
+    }
+    // Refuse to share a writable drive image with a VM created since the plan
+    if err := recordSharedDrives(provider, d, vmID, true); err != nil {
+        return diag.FromErr(err)
+    }
     d.SetId(vmID)
+    if err := assignGuestMACs(d, vmID); err != nil {
+        return diag.FromErr(err)
+    }
+
+    // Async VMs are provisioned by the provider's workers while Terraform moves on
+    if d.Get("async").(bool) {
+        return append(diags, startAsyncCreate(ctx, d, provider, client)...)
+    }
+    return append(diags, provisionVM(ctx, d, m, client)...)
+}
+
+// provisionVM creates the host files of a VM that was given its ID and placed on a host,
+// configures the VM through the host's API and starts it.
+func provisionVM(ctx context.Context, d *schema.ResourceData, m interface{}, client *FirecrackerClient) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    vmID := d.Id()
+    var diags diag.Diagnostics
+    var err error
 
     tflog.Info(ctx, "Creating Firecracker VM", map[string]interface{}{
         "id": vmID,
     })
 
-    // Get boot args and ensure it has the correct root device specification
-    bootArgs := d.Get("boot_args").(string)
-    
-    // Ensure we have the correct root device in boot args
-    // Remove any existing root= parameter
-    re := regexp.MustCompile(`root=\S+`)
-    bootArgs = re.ReplaceAllString(bootArgs, "")
-    
-    // Add root=/dev/vda1 with explicit rootfstype
-    bootArgs = strings.TrimSpace(bootArgs) + " root=/dev/vda1 rootfstype=ext4 rw"
-    
-    // Ensure we have rootfstype if not already present
-    if !strings.Contains(bootArgs, "rootfstype=") {
-        bootArgs = strings.TrimSpace(bootArgs) + " rootfstype=ext4"
+    var extras vmPayloadExtras
+
+    // Build the cloud-init seed image, attached as a read-only drive, and the scratch drives,
+    // which are deleted with the VM, and restore drives from their backups. They are removed
+    // again if building them is interrupted.
+    seed, hasSeed := cloudInitSpecFromConfig(d, vmID)
+    hasSeed = hasSeed && seed.Datasource == cloudInitDatasourceNoCloud
+    if hasSeed && d.Get("host").(string) != "" {
+        return diag.FromErr(fmt.Errorf("the nocloud cloud_init datasource is only supported for VMs running on the host running Terraform"))
     }
-    
-    // Add other important kernel parameters if not present
-    if !strings.Contains(bootArgs, "console=") {
-        bootArgs = strings.TrimSpace(bootArgs) + " console=ttyS0"
+    drives := scratchDrivesFromConfig(d)
+    if len(drives) > 0 && d.Get("host").(string) != "" {
+        return diag.FromErr(fmt.Errorf("scratch drives are only supported for VMs running on the host running Terraform"))
     }
-    
-    // Construct the boot source payload
-    bootSource := map[string]interface{}{
-        "kernel_image_path": d.Get("kernel_image_path").(string),
-        "boot_args":         bootArgs,
-    }
-
-    // Construct the drives payload
-    drives := []map[string]interface{}{}
-    for _, rawDrive := range d.Get("drives").([]interface{}) {
-        drive := rawDrive.(map[string]interface{})
-        driveMap := map[string]interface{}{
-            "drive_id":       drive["drive_id"].(string),
-            "path_on_host":   drive["path_on_host"].(string),
-            "is_root_device": drive["is_root_device"].(bool),
-            "is_read_only":   drive["is_read_only"].(bool),
-        }
-        
-        // Explicitly convert to bool to ensure proper type for Firecracker API
-        isRootDevice, ok := drive["is_root_device"].(bool)
-        if !ok {
-            if strVal, ok := drive["is_root_device"].(string); ok {
-                isRootDevice = strVal == "true"
-            }
+    restores := driveRestoresFromConfig(d, provider.StateDir, vmID)
+    if len(restores) > 0 && d.Get("host").(string) != "" {
+        return diag.FromErr(fmt.Errorf("restoring drives from backups is only supported for VMs running on the host running Terraform"))
+    }
+    // Secrets referred to by the seed image and MMDS contents are fetched now, and only ever
+    // written to the seed image and the Firecracker API
+    secrets := provider.secrets.resolver()
+    if hasSeed {
+        if seed, err = secrets.resolveCloudInit(ctx, seed); err != nil {
+            return diag.FromErr(err)
         }
-        driveMap["is_root_device"] = isRootDevice
-        
-        isReadOnly, ok := drive["is_read_only"].(bool)
-        if !ok {
-            if strVal, ok := drive["is_read_only"].(string); ok {
-                isReadOnly = strVal == "true"
-            }
+    }
+    if hasSeed || len(drives) > 0 || len(restores) > 0 {
+        opCtx, op, err := startHostOperation(ctx, provider.StateDir, "create drives of VM "+vmID)
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        if err := op.finish(createVMHostDrives(opCtx, provider.StateDir, vmID, seed, hasSeed, drives, restores, &extras)); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // Upload the kernel, initrd and drive images to the host running the Firecracker process
+    if spec, ok := stagingSpecFromConfig(d); ok {
+        destination, err := spec.destination(client)
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        if err := uploadStagedFiles(ctx, d, spec, destination, vmID); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // Put the kernel, initrd and drive images where the jailed Firecracker process can open
+    // them. They are removed again if staging them is interrupted.
+    if spec, files := jailerStagedFiles(d, provider.StateDir, vmID); len(files) > 0 {
+        if d.Get("host").(string) != "" {
+            return diag.FromErr(fmt.Errorf("jailer stage_files is only supported for VMs running on the host running Terraform"))
+        }
+        opCtx, op, err := startHostOperation(ctx, provider.StateDir, "stage files of VM "+vmID+" in the jailer chroot")
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        if err := op.finish(stageJailerFiles(opCtx, spec, files)); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // Limit the VM's CPU time before it starts using any
+    if d.Get("cpu_quota_percent").(int) > 0 {
+        if d.Get("host").(string) != "" {
+            return diag.FromErr(fmt.Errorf("cpu_quota_percent is only supported for VMs running on the host running Terraform"))
+        }
+        if err := applyCPUQuota(ctx, d, vmID); err != nil {
+            return diag.FromErr(err)
         }
-        driveMap["is_read_only"] = isReadOnly
-        
-        // Log the drive configuration for debugging
-        tflog.Debug(ctx, "Drive configuration", map[string]interface{}{
-            "drive_id":       driveMap["drive_id"],
-            "path_on_host":   driveMap["path_on_host"],
-            "is_root_device": driveMap["is_root_device"],
-            "is_read_only":   driveMap["is_read_only"],
-        })
-        
-        // Log drive configuration for debugging
-        tflog.Debug(ctx, "Configuring drive for VM", map[string]interface{}{
-            "drive_id":       driveMap["drive_id"],
-            "path_on_host":   driveMap["path_on_host"],
-            "is_root_device": driveMap["is_root_device"],
-            "is_read_only":   driveMap["is_read_only"],
-        })
-        
-        drives = append(drives, driveMap)
     }
 
-    // Construct the machine config payload
-    machineConfigRaw := d.Get("machine_config").([]interface{})[0].(map[string]interface{})
-    machineConfig := map[string]interface{}{
-        "vcpu_count":   machineConfigRaw["vcpu_count"].(int),
-        "mem_size_mib": machineConfigRaw["mem_size_mib"].(int),
+    // Pin the VM to its CPUs and NUMA node before it starts allocating memory
+    if d.Get("cpuset").(string) != "" || d.Get("numa_node").(int) >= 0 {
+        if d.Get("host").(string) != "" {
+            return diag.FromErr(fmt.Errorf("cpuset and numa_node are only supported for VMs running on the host running Terraform"))
+        }
+        if err := applyCPUPinning(ctx, d, vmID); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // Add the interfaces attached with firecracker_network_interface while the VM can take them
+    if err := attachInterfaces(d, provider.StateDir); err != nil {
+        return diag.FromErr(err)
     }
 
-    // Construct the network interfaces payload
-    networkInterfaces := []map[string]interface{}{}
-    for _, rawIface := range d.Get("network_interfaces").([]interface{}) {
+    // Attach the TAP devices to their bridges before the guest starts using them
+    ifaceIDs := map[string]bool{}
+    for _, rawIface := range vmNetworkInterfaces(d) {
         iface := rawIface.(map[string]interface{})
-        ifaceMap := map[string]interface{}{
-            "iface_id":      iface["iface_id"].(string),
-            "host_dev_name": iface["host_dev_name"].(string),
+        ifaceIDs[iface["iface_id"].(string)] = true
+        if bridge, ok := iface["bridge"].(string); ok && bridge != "" {
+            if err := withNetNS(d.Get("netns").(string), func() error { return setLinkMaster(iface["host_dev_name"].(string), bridge) }); err != nil {
+                return diag.FromErr(err)
+            }
+        }
+    }
+
+    // Attach the VM to its CNI network and use the resulting TAP device
+    if spec, ok := cniSpecFromConfig(d); ok {
+        ifaceID := d.Get("cni.0.iface_id").(string)
+        if ifaceIDs[ifaceID] {
+            return diag.FromErr(fmt.Errorf("network interface %s is already used by the cni block", ifaceID))
         }
-        
-        // Only add guest_mac if it's set
-        if mac, ok := iface["guest_mac"].(string); ok && mac != "" {
-            ifaceMap["guest_mac"] = mac
+
+        tflog.Info(ctx, "Adding Firecracker VM to CNI network", map[string]interface{}{
+            "id":      vmID,
+            "network": spec.NetworkName,
+        })
+        attachment, err := cniAdd(ctx, spec, vmID)
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        setCNIAttachment(d, attachment)
+
+        extras.CNIInterface = map[string]interface{}{
+            "iface_id":      ifaceID,
+            "host_dev_name": attachment.TapName,
+        }
+        if attachment.GuestMAC != "" {
+            extras.CNIInterface["guest_mac"] = attachment.GuestMAC
         }
-        
-        networkInterfaces = append(networkInterfaces, ifaceMap)
     }
 
     // Construct the full payload
-    payload := map[string]interface{}{
-        "boot-source":        bootSource,
-        "drives":             drives,
-        "machine-config":     machineConfig,
-        "network-interfaces": networkInterfaces,
-        "vm-id":              vmID,
+    payload, err := renderVMPayload(d, vmID, extras)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    if (payload["mmds"] != nil || mmdsBlock(d) != nil) && payload["mmds-config"] == nil {
+        tflog.Warn(ctx, "VM has no network interfaces, MMDS contents will not be reachable from the guest", map[string]interface{}{
+            "id": vmID,
+        })
+    }
+    tflog.Debug(ctx, "Rendered VM configuration", map[string]interface{}{
+        "id":      vmID,
+        "payload": redactVMConfig(payload),
+    })
+    if err := setRenderedConfig(d, payload); err != nil {
+        return diag.FromErr(err)
+    }
+    if payload["mmds"] != nil {
+        if payload["mmds"], err = secrets.resolveValue(ctx, payload["mmds"]); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    if _, ok := systemdUnitSpecFromConfig(d); ok && d.Get("host").(string) != "" {
+        return diag.FromErr(fmt.Errorf("systemd_unit is only supported for VMs running on the host running Terraform"))
     }
 
     // Send the request to the Firecracker API
-    err := client.CreateVM(ctx, payload)
+    err = client.CreateVM(ctx, payload)
     if err != nil {
-        return diag.FromErr(fmt.Errorf("failed to create VM: %w", err))
+        return apiErrorDiagnostics("failed to create VM", err)
     }
+    // CreateVM returns once InstanceStart is accepted, so boot time is measured from here
+    startedAt := time.Now()
 
     tflog.Info(ctx, "Firecracker VM created successfully", map[string]interface{}{
         "id": vmID,
     })
 
+    // Record the VM's configuration and process, which the Firecracker API cannot report
+    if err := ensureVMRegistered(provider.vmRegistryDir(), vmRecordFromConfig(d, client)); err != nil {
+        diags = append(diags, diag.Diagnostic{
+            Severity: diag.Warning,
+            Summary:  "Failed to register VM",
+            Detail:   err.Error(),
+        })
+    }
+
+    // Have systemd boot the VM from its configuration when the host restarts
+    if err := installVMUnit(ctx, d, provider.StateDir, vmID); err != nil {
+        return append(diags, diag.FromErr(err)...)
+    }
+
+    // Configure the guest through its agent, which works without guest networking
+    if spec, ok, err := guestAgentSpecFromConfig(d); err != nil {
+        return diag.FromErr(err)
+    } else if ok {
+        if d.Get("host").(string) != "" {
+            return diag.FromErr(fmt.Errorf("guest_agent is only supported for VMs running on the host running Terraform"))
+        }
+        if err := waitForGuestAgent(ctx, spec); err != nil {
+            return diag.FromErr(err)
+        }
+        commands, err := guestExecCommandsFromConfig(d)
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        for _, cmd := range commands {
+            if err := execInGuest(ctx, spec, cmd); err != nil {
+                return diag.FromErr(err)
+            }
+        }
+    }
+
+    // Wait until provisioners can connect to the guest
+    if spec, ok, err := sshSpecFromConfig(d); err != nil {
+        return diag.FromErr(err)
+    } else if ok {
+        if err := waitForSSH(ctx, spec); err != nil {
+            return diag.FromErr(err)
+        }
+        setSSHConnection(d, spec)
+    }
+    setBootTime(ctx, d, startedAt)
+
+    // Take the golden snapshot clones start from, now that the guest is up
+    if spec, ok := goldenSnapshotSpecFromConfig(d, provider.StateDir); ok {
+        diags = append(diags, ensureGoldenSnapshot(ctx, client, provider.StateDir, spec, vmID, d.Get("host").(string))...)
+    }
+
+    // Let the user's systems know about the new VM
+    for _, event := range []string{hookEventCreate, hookEventStart} {
+        if err := runVMHook(ctx, d, client, event); err != nil {
+            return append(diags, diag.FromErr(err)...)
+        }
+    }
+
     // Read the resource to ensure state is consistent
-    return resourceFirecrackerVMRead(ctx, d, m)
+    return append(diags, resourceFirecrackerVMRead(ctx, d, m)...)
+}
+
+// createVMHostDrives builds a VM's seed image, when it has one, and its scratch drives, and
+// records their paths in extras. Drives with a backup to restore from are restored last, so
+// a restored scratch drive replaces the blank one.
+func createVMHostDrives(ctx context.Context, stateDir, vmID string, seed cloudInitSpec, hasSeed bool, drives []scratchDrive, restores []driveRestore, extras *vmPayloadExtras) error {
+    if hasSeed {
+        extras.SeedImagePath = seedImagePath(stateDir, vmID)
+        if err := buildSeedImage(ctx, extras.SeedImagePath, seed); err != nil {
+            return err
+        }
+    }
+
+    if len(drives) > 0 {
+        extras.ScratchDrivePaths = map[string]string{}
+    }
+    for _, drive := range drives {
+        path := scratchDrivePath(stateDir, vmID, drive.DriveID)
+        tflog.Info(ctx, "Creating scratch drive", map[string]interface{}{
+            "id":       vmID,
+            "drive_id": drive.DriveID,
+            "size_mib": drive.SizeMiB,
+            "format":   drive.Format,
+        })
+        if err := createScratchDrive(ctx, path, drive); err != nil {
+            return err
+        }
+        extras.ScratchDrivePaths[drive.DriveID] = path
+    }
+    return restoreDrives(ctx, vmID, restores)
+}
+
+// restoreDrives recreates the images of drives restored from backups.
+func restoreDrives(ctx context.Context, vmID string, restores []driveRestore) error {
+    for _, restore := range restores {
+        tflog.Info(ctx, "Restoring drive from backup", map[string]interface{}{
+            "id":       vmID,
+            "drive_id": restore.DriveID,
+            "backup":   restore.Backup,
+        })
+        if err := restoreDriveBackup(ctx, restore); err != nil {
+            return err
+        }
+    }
+    return nil
 }
 
 func resourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-    client := m.(*FirecrackerClient)
+    client, err := vmClient(d, m)
+    if err != nil {
+        return diag.FromErr(err)
+    }
     var diags diag.Diagnostics
 
     vmID := d.Id()
@@ -295,9 +1685,35 @@ func resourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m in
         "id": vmID,
     })
 
+    // Async VMs are only refreshed once they are provisioned
+    if provisioned, asyncDiags := refreshAsyncCreate(ctx, d, m.(*FirecrackerClient)); !provisioned {
+        return asyncDiags
+    }
+
     // Get VM details from the API
     vmInfo, err := client.GetVM(ctx, vmID)
     if err != nil {
+        // A VM whose registered Firecracker process has exited is gone for good
+        if errors.Is(err, errHostUnreachable) && d.Get("host").(string) == "" {
+            if record, _ := lookupVMRecord(m.(*FirecrackerClient).vmRegistryDir(), vmID); record.processGone() {
+                return recoverLostVM(ctx, d, m, client, fmt.Sprintf("Firecracker process %d serving VM %s is no longer running", record.PID, vmID), false)
+            }
+            // The API socket of a VM with a systemd unit is on this host, so no process serves it
+            if vmSupervisedByUnit(d) && !providerReadOnly(m) {
+                return restartSupervisedVM(ctx, d, m.(*FirecrackerClient), client, fmt.Sprintf("Firecracker API of VM %s is unreachable", vmID))
+            }
+        }
+        if errors.Is(err, errHostUnreachable) && client.TolerateUnreachableHosts {
+            tflog.Warn(ctx, "Firecracker API unreachable, keeping prior state", map[string]interface{}{
+                "id": vmID,
+            })
+            recordVMHealth(d, vmHealthUnreachable, err.Error())
+            return diag.Diagnostics{{
+                Severity: diag.Warning,
+                Summary:  "Firecracker host unreachable",
+                Detail:   fmt.Sprintf("Could not refresh VM %s, keeping its prior state: %s", vmID, err),
+            }}
+        }
         return diag.FromErr(fmt.Errorf("error reading VM: %w", err))
     }
 
@@ -310,9 +1726,122 @@ func resourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m in
         return diags
     }
     
+    // Report what Firecracker says about the process serving the VM
+    info, _ := vmInfo["instance-info"].(*sdk.InstanceInfo)
+    if info != nil {
+        d.Set("instance_state", info.State)
+        d.Set("vmm_version", info.VMMVersion)
+        d.Set("app_name", info.AppName)
+    }
+
+    // A registered VM served by a Firecracker process that has not started a VM was lost,
+    // such as to a host reboot after which Firecracker was started again
+    if record, _ := lookupVMRecord(m.(*FirecrackerClient).vmRegistryDir(), vmID); record != nil {
+        if info != nil && info.State == sdk.StateNotStarted {
+            diags = append(diags, recoverLostVM(ctx, d, m, client, fmt.Sprintf("The Firecracker process serving VM %s has not started it", vmID), true)...)
+            // Only a relaunched VM has anything left to refresh
+            if d.Id() == "" || d.Get("health_status").(string) == vmHealthLost {
+                return diags
+            }
+        }
+    }
+
     // Set the ID to ensure it's properly tracked in state
     d.SetId(vmID)
 
+    // Keep track of the hosts used by the VM's placement group
+    if group := placementGroupName(d.Get("placement_group")); group != "" {
+        if provider := m.(*FirecrackerClient); provider.placementGroups != nil {
+            provider.placementGroups.record(group, vmID, d.Get("host").(string))
+        }
+    }
+
+    // Keep the VM's registry record up to date, registering VMs created before the registry
+    if !providerReadOnly(m) {
+        if err := ensureVMRegistered(m.(*FirecrackerClient).vmRegistryDir(), vmRecordFromConfig(d, client)); err != nil {
+            diags = append(diags, diag.Diagnostic{
+                Severity: diag.Warning,
+                Summary:  "Failed to register VM",
+                Detail:   err.Error(),
+            })
+        }
+    }
+
+    // Keep track of the drive images the VM attaches, which other VMs may only share read-only
+    if err := recordSharedDrives(m.(*FirecrackerClient), d, vmID, false); err != nil {
+        diags = append(diags, diag.Diagnostic{
+            Severity: diag.Warning,
+            Summary:  "Drive image shared by VMs",
+            Detail:   err.Error(),
+        })
+    }
+
+    // Problems found by the health checks below, which make the VM unhealthy
+    problems := []string{}
+
+    // Report whether the guest agent still answers, without failing the refresh
+    if spec, ok, _ := guestAgentSpecFromConfig(d); ok && d.Get("host").(string) == "" {
+        pingCtx, cancel := context.WithTimeout(ctx, guestAgentPingTimeout)
+        err := pingGuestAgent(pingCtx, spec)
+        cancel()
+        if err != nil {
+            tflog.Warn(ctx, "Guest agent health check failed", map[string]interface{}{
+                "id":    vmID,
+                "error": err.Error(),
+            })
+            problems = append(problems, fmt.Sprintf("guest agent health check failed: %s", err))
+        }
+        d.Set("guest_agent_healthy", err == nil)
+    }
+
+    // Bridges are managed on the host running Terraform, so only local VMs can be checked
+    if d.Get("host").(string) == "" {
+        bridgeDiags := checkBridgeAttachments(ctx, vmID, d.Get("netns").(string), vmNetworkInterfaces(d), d.Get("heal_networking").(bool) && !providerReadOnly(m))
+        diags = append(diags, bridgeDiags...)
+        if diags.HasError() {
+            return diags
+        }
+        for _, diagnostic := range bridgeDiags {
+            problems = append(problems, diagnostic.Detail)
+        }
+    }
+
+    if len(problems) > 0 {
+        recordVMHealth(d, vmHealthUnhealthy, strings.Join(problems, "; "))
+    } else {
+        recordVMHealth(d, vmHealthHealthy, "")
+    }
+
+    // Report where the golden snapshot is, once one of the VMs sharing it has taken it
+    if err := setGoldenSnapshot(d, m.(*FirecrackerClient).StateDir); err != nil {
+        return append(diags, diag.FromErr(err)...)
+    }
+
+    // Report where the jailer keeps the VM's files
+    if err := setJailerPaths(d, m.(*FirecrackerClient).StateDir); err != nil {
+        return append(diags, diag.FromErr(err)...)
+    }
+
+    // Report where the VM's files were uploaded to
+    if err := setStagedPaths(d); err != nil {
+        return append(diags, diag.FromErr(err)...)
+    }
+
+    // Report the CPU quota the VM's cgroup enforces, which can be changed outside Terraform
+    if d.Get("host").(string) == "" {
+        if err := readCPUQuota(d); err != nil {
+            return append(diags, diag.FromErr(err)...)
+        }
+    }
+
+    // VMs created from config_json are described by it rather than by these attributes
+    if d.Get("config_json").(string) != "" {
+        tflog.Debug(ctx, "Firecracker VM read completed", map[string]interface{}{
+            "id": vmID,
+        })
+        return diags
+    }
+
     // Update the resource data based on the VM info
     // This is a simplified example - you would need to adapt this to match
     // the actual structure of your API response
@@ -320,8 +1849,11 @@ func resourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m in
         if kernelPath, ok := bootSource["kernel_image_path"].(string); ok {
             d.Set("kernel_image_path", kernelPath)
         }
+        if initrdPath, ok := bootSource["initrd_path"].(string); ok {
+            d.Set("initrd_path", initrdPath)
+        }
         if bootArgs, ok := bootSource["boot_args"].(string); ok {
-            d.Set("boot_args", bootArgs)
+            d.Set("boot_args", withoutSensitiveBootArgs(bootArgs, d.Get("sensitive_boot_args").(string)))
         }
     }
 
@@ -336,8 +1868,21 @@ func resourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m in
         d.Set("machine_config", newMachineConfig)
     }
 
-    // Handle drives
-    if drives, ok := vmInfo["drives"].([]interface{}); ok {
+    // Handle drives. The API does not list drives yet, in which case the configured drives are
+    // kept rather than emptied. Settings only the provider knows about are kept either way, as
+    // are the images of drives whose image is managed by firecracker_drive.
+    if drives, ok := vmInfo["drives"].([]interface{}); ok && len(drives) > 0 {
+        configured := map[interface{}]map[string]interface{}{}
+        for _, raw := range d.Get("drives").([]interface{}) {
+            if drive, ok := raw.(map[string]interface{}); ok {
+                configured[drive["drive_id"]] = drive
+            }
+        }
+        attached, err := attachedDriveIDs(m.(*FirecrackerClient).StateDir, vmID)
+        if err != nil {
+            return diag.FromErr(err)
+        }
+
         newDrives := make([]map[string]interface{}, 0, len(drives))
         for _, driveRaw := range drives {
             if drive, ok := driveRaw.(map[string]interface{}); ok {
@@ -346,6 +1891,13 @@ func resourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m in
                     "path_on_host":   drive["path_on_host"],
                     "is_root_device": drive["is_root_device"],
                     "is_read_only":   drive["is_read_only"],
+                    "partuuid":       drive["partuuid"],
+                }
+                for _, key := range []string{"size_mib", "format", "compact_interval", "restore_from"} {
+                    newDrive[key] = configured[drive["drive_id"]][key]
+                }
+                if id, _ := drive["drive_id"].(string); attached[id] && configured[id] != nil {
+                    newDrive["path_on_host"] = configured[id]["path_on_host"]
                 }
                 newDrives = append(newDrives, newDrive)
             }
@@ -353,11 +1905,26 @@ func resourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m in
         d.Set("drives", newDrives)
     }
 
-    // Handle network interfaces
-    if networkInterfaces, ok := vmInfo["network-interfaces"].([]interface{}); ok {
+    // Handle network interfaces. As with drives, the configured interfaces are kept when the
+    // API does not list them. Interfaces attached with firecracker_network_interface are kept
+    // in attached_network_interfaces instead.
+    if networkInterfaces, ok := vmInfo["network-interfaces"].([]interface{}); ok && len(networkInterfaces) > 0 {
+        bridges := map[interface{}]interface{}{}
+        for _, raw := range d.Get("network_interfaces").([]interface{}) {
+            if iface, ok := raw.(map[string]interface{}); ok {
+                bridges[iface["iface_id"]] = iface["bridge"]
+            }
+        }
+        attached := map[interface{}]bool{}
+        for _, raw := range d.Get("attached_network_interfaces").([]interface{}) {
+            if iface, ok := raw.(map[string]interface{}); ok {
+                attached[iface["iface_id"]] = true
+            }
+        }
+
         newInterfaces := make([]map[string]interface{}, 0, len(networkInterfaces))
         for _, ifaceRaw := range networkInterfaces {
-            if iface, ok := ifaceRaw.(map[string]interface{}); ok {
+            if iface, ok := ifaceRaw.(map[string]interface{}); ok && !attached[iface["iface_id"]] {
                 newIface := map[string]interface{}{
                     "iface_id":      iface["iface_id"],
                     "host_dev_name": iface["host_dev_name"],
@@ -365,6 +1932,10 @@ func resourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m in
                 if guestMac, ok := iface["guest_mac"].(string); ok {
                     newIface["guest_mac"] = guestMac
                 }
+                // The bridge is not known to Firecracker, so keep the configured one
+                if bridge, ok := bridges[iface["iface_id"]]; ok {
+                    newIface["bridge"] = bridge
+                }
                 newInterfaces = append(newInterfaces, newIface)
             }
         }
@@ -379,8 +1950,38 @@ func resourceFirecrackerVMRead(ctx context.Context, d *schema.ResourceData, m in
 }
 
 func resourceFirecrackerVMUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-    client := m.(*FirecrackerClient)
     vmID := d.Id()
+    var diags diag.Diagnostics
+
+    // An async VM is changed once it is provisioned
+    if err := waitAsyncCreate(ctx, m.(*FirecrackerClient), vmID); err != nil {
+        return diag.FromErr(err)
+    }
+
+    // Move the VM to its new host, which planning only allows with the migration experiment
+    if d.HasChange("host") {
+        from, to := d.GetChange("host")
+        if diags = append(diags, m.(*FirecrackerClient).requireFeature(featureMigration, "Moving a VM to another host")...); diags.HasError() {
+            d.Set("host", from)
+            return diags
+        }
+        if diags = append(diags, migrateVM(ctx, d, m.(*FirecrackerClient), vmID, from.(string), to.(string))...); diags.HasError() {
+            d.Set("host", from)
+            return diags
+        }
+    }
+
+    client, err := vmClient(d, m)
+    if err != nil {
+        return append(diags, diag.FromErr(err)...)
+    }
+
+    // The registry follows the VM to its new host
+    if d.HasChange("host") {
+        if err := ensureVMRegistered(m.(*FirecrackerClient).vmRegistryDir(), vmRecordFromConfig(d, client)); err != nil {
+            return append(diags, diag.FromErr(err)...)
+        }
+    }
     
     tflog.Info(ctx, "Updating Firecracker VM", map[string]interface{}{
         "id": vmID,
@@ -404,7 +2005,7 @@ func resourceFirecrackerVMUpdate(ctx context.Context, d *schema.ResourceData, m
         hasChanges = true
     }
     
-    if d.HasChange("kernel_image_path") || d.HasChange("boot_args") {
+    if d.HasChange("kernel_image_path") || d.HasChange("initrd_path") || d.HasChange("boot_args") {
         tflog.Warn(ctx, "Boot configuration changes require VM recreation", map[string]interface{}{
             "id": vmID,
         })
@@ -418,13 +2019,47 @@ func resourceFirecrackerVMUpdate(ctx context.Context, d *schema.ResourceData, m
         hasChanges = true
     }
     
-    // If there are changes, call the API (which will just log a warning)
-    if hasChanges {
-        err := client.UpdateVM(ctx, vmID, nil)
+    // The CPU quota and pinning are changed in place, on the running VM
+    if d.HasChange("cpu_quota_percent") {
+        if d.Get("host").(string) != "" {
+            return diag.FromErr(fmt.Errorf("cpu_quota_percent is only supported for VMs running on the host running Terraform"))
+        }
+        if err := applyCPUQuota(ctx, d, vmID); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+    if d.HasChange("cpuset") || d.HasChange("numa_node") {
+        if d.Get("host").(string) != "" {
+            return diag.FromErr(fmt.Errorf("cpuset and numa_node are only supported for VMs running on the host running Terraform"))
+        }
+        if err := applyCPUPinning(ctx, d, vmID); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // Tags are published to the running VM
+    if err := updatePublishedTags(ctx, d, client); err != nil {
+        return diag.FromErr(err)
+    }
+
+    // Drive images due for compaction are on the host running Terraform
+    if d.Get("host").(string) == "" {
+        if diags = append(diags, compactDrives(ctx, client, d, m.(*FirecrackerClient).StateDir)...); diags.HasError() {
+            return diags
+        }
+    }
+
+    // The readiness check only runs on create, but the connection details follow the config
+    if d.HasChange("wait_for_ssh") {
+        spec, _, err := sshSpecFromConfig(d)
         if err != nil {
-            return diag.FromErr(fmt.Errorf("failed to update VM: %w", err))
+            return diag.FromErr(err)
         }
-        
+        setSSHConnection(d, spec)
+    }
+    
+    // Changes Firecracker cannot apply to the running VM were logged above
+    if hasChanges {
         tflog.Info(ctx, "Firecracker VM update processed (note: most changes require recreation)", map[string]interface{}{
             "id": vmID,
         })
@@ -435,23 +2070,113 @@ func resourceFirecrackerVMUpdate(ctx context.Context, d *schema.ResourceData, m
     }
     
     // Read the resource to ensure state is consistent
-    return resourceFirecrackerVMRead(ctx, d, m)
+    return append(diags, resourceFirecrackerVMRead(ctx, d, m)...)
 }
 
 func resourceFirecrackerVMDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-    client := m.(*FirecrackerClient)
+    client, err := vmClient(d, m)
+    if err != nil {
+        return diag.FromErr(err)
+    }
     var diags diag.Diagnostics
     
     vmID := d.Id()
     tflog.Info(ctx, "Deleting Firecracker VM", map[string]interface{}{
         "id": vmID,
     })
+
+    // Abort provisioning an async VM, then delete what was provisioned of it so far
+    m.(*FirecrackerClient).asyncCreates.stop(vmID)
     
-    err := client.DeleteVM(ctx, vmID)
+    record, err := lookupVMRecord(m.(*FirecrackerClient).vmRegistryDir(), vmID)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    if err := runVMHook(ctx, d, client, hookEventDestroy); err != nil {
+        return diag.FromErr(err)
+    }
+
+    // Keep systemd from starting the VM again once it is shut down
+    if err := disableVMUnit(ctx, d, vmID); err != nil {
+        return diag.FromErr(err)
+    }
+
+    err = client.DeleteVM(ctx, vmID)
     if err != nil {
         return diag.FromErr(fmt.Errorf("error deleting VM: %w", err))
     }
-    
+
+    // Make sure the VM's Firecracker process is gone before its files are removed
+    if d.Get("host").(string) == "" {
+        if err := stopVMProcess(ctx, record); err != nil {
+            return diag.FromErr(err)
+        }
+        if err := removeVMUnit(ctx, d, m.(*FirecrackerClient).StateDir, vmID); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // Release the VM's CNI resources, such as its IPAM lease and TAP device
+    if spec, ok := cniSpecFromConfig(d); ok {
+        if err := cniDel(ctx, spec, vmID); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // Remove the VM's cloud-init seed image
+    if spec, ok := cloudInitSpecFromConfig(d, vmID); ok && spec.Datasource == cloudInitDatasourceNoCloud {
+        if err := removeSeedImage(seedImagePath(m.(*FirecrackerClient).StateDir, vmID)); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // Remove the files staged in the VM's jailer chroot
+    if spec, files := jailerStagedFiles(d, m.(*FirecrackerClient).StateDir, vmID); len(files) > 0 {
+        if err := removeJailerFiles(spec, files); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // Remove the files uploaded to the host running the VM
+    if spec, ok := stagingSpecFromConfig(d); ok {
+        destination, err := spec.destination(client)
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        if err := removeStagedFiles(ctx, spec, destination, vmID); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // Remove the VM's scratch drives
+    if len(scratchDrivesFromConfig(d)) > 0 {
+        if err := removeScratchDrives(m.(*FirecrackerClient).StateDir, vmID); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    // Free the VM's host for other members of its placement group
+    if group := placementGroupName(d.Get("placement_group")); group != "" {
+        if provider := m.(*FirecrackerClient); provider.placementGroups != nil {
+            provider.placementGroups.remove(group, vmID)
+        }
+    }
+
+    // Remove the VM from the list of VMs the provider manages
+    if err := unregisterVM(m.(*FirecrackerClient).vmRegistryDir(), vmID); err != nil {
+        return diag.FromErr(err)
+    }
+    if err := removeAsyncCreate(m.(*FirecrackerClient).StateDir, vmID); err != nil {
+        return diag.FromErr(err)
+    }
+    m.(*FirecrackerClient).hostLoads.release(vmID)
+
+    // Let other VMs attach the VM's drive images
+    if provider := m.(*FirecrackerClient); provider.sharedDrives != nil {
+        provider.sharedDrives.remove(vmID)
+    }
+
     // Remove the VM from state
     d.SetId("")
     
@@ -0,0 +1,320 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/structure"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceFirecrackerVMClone defines the firecracker_vm_clone resource, which restores VMs
+// from a golden snapshot taken by a firecracker_vm instead of booting them, or boots them
+// from a configuration until the snapshot is taken.
+func resourceFirecrackerVMClone() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: withOperationTimeout(schema.TimeoutCreate, resourceFirecrackerVMCloneCreate),
+        ReadContext:   resourceFirecrackerVMCloneRead,
+        DeleteContext: withOperationTimeout(schema.TimeoutDelete, resourceFirecrackerVMCloneDelete),
+        CustomizeDiff: resourceFirecrackerVMCloneCustomizeDiff,
+        Schema: map[string]*schema.Schema{
+            "snapshot": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "Name of the golden snapshot the clones are restored from, as in the golden_snapshot block of the firecracker_vm taking it.",
+                ValidateFunc: validation.StringIsNotEmpty,
+            },
+            "base_urls": {
+                Type:        schema.TypeList,
+                Required:    true,
+                ForceNew:    true,
+                MinItems:    1,
+                Description: "Firecracker APIs the clones are restored in, one clone each. Each must be served by a Firecracker process that has not started a VM.",
+                Elem: &schema.Schema{
+                    Type:         schema.TypeString,
+                    ValidateFunc: validation.StringIsNotEmpty,
+                },
+            },
+            "provisioning_mode": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      cloneProvisioningRestore,
+                Description:  "How the clones are provisioned: `restore` restores them from the snapshot, or, when it has not been taken yet and config_json is set, boots them and takes the snapshot from the first; `boot` always boots them from config_json.",
+                ValidateFunc: validation.StringInSlice([]string{cloneProvisioningRestore, cloneProvisioningBoot}, false),
+            },
+            "config_json": {
+                Type:             schema.TypeString,
+                Optional:         true,
+                ForceNew:         true,
+                Description:      "Firecracker configuration file, as `firecracker --config-file` takes it, the clones are booted from when they are not restored.",
+                ValidateFunc:     validateConfigJSON,
+                DiffSuppressFunc: structure.SuppressJsonDiff,
+            },
+            "resume": {
+                Type:        schema.TypeBool,
+                Optional:    true,
+                ForceNew:    true,
+                Default:     true,
+                Description: "Resume the clones once restored. When false, they are left paused.",
+            },
+            "clones": {
+                Type:        schema.TypeList,
+                Computed:    true,
+                Description: "The restored VMs, in the order of base_urls.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "id": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "ID of the clone.",
+                        },
+                        "base_url": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "Firecracker API serving the clone.",
+                        },
+                        "state": {
+                            Type:        schema.TypeString,
+                            Computed:    true,
+                            Description: "State Firecracker reports for the clone: Running or Paused.",
+                        },
+                    },
+                },
+            },
+            "provisioned_by": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "How the clones were provisioned: `restore` or `boot`.",
+            },
+            "restore_time_ms": {
+                Type:        schema.TypeInt,
+                Computed:    true,
+                Description: "Milliseconds it took to restore or boot all clones.",
+            },
+        },
+        Timeouts: &schema.ResourceTimeout{
+            Create: schema.DefaultTimeout(5 * time.Minute),
+            Delete: schema.DefaultTimeout(5 * time.Minute),
+        },
+    }
+}
+
+const (
+    cloneProvisioningRestore = "restore"
+    cloneProvisioningBoot    = "boot"
+)
+
+// resourceFirecrackerVMCloneCustomizeDiff fails the plan unless warm pools are enabled, or
+// when the clones are to be booted without a configuration to boot them from.
+func resourceFirecrackerVMCloneCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+    if d.Get("provisioning_mode").(string) == cloneProvisioningBoot && d.NewValueKnown("config_json") && d.Get("config_json").(string) == "" {
+        return fmt.Errorf("provisioning_mode %q requires config_json", cloneProvisioningBoot)
+    }
+
+    provider, ok := m.(*FirecrackerClient)
+    if !ok {
+        return nil
+    }
+    if diags := provider.requireFeature(featureWarmPools, "firecracker_vm_clone"); diags.HasError() {
+        return fmt.Errorf("%s: %s", diags[0].Summary, diags[0].Detail)
+    }
+    return nil
+}
+
+// cloneID returns the ID of the clone restored in the index-th API.
+func cloneID(id string, index int) string {
+    return fmt.Sprintf("%s-%d", id, index)
+}
+
+func resourceFirecrackerVMCloneCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    if diags := provider.requireFeature(featureWarmPools, "firecracker_vm_clone"); diags.HasError() {
+        return diags
+    }
+
+    // Clones are restored from the snapshot once it was taken, and booted otherwise
+    name := d.Get("snapshot").(string)
+    configJSON := d.Get("config_json").(string)
+    var snapshot *goldenSnapshot
+    var err error
+    if d.Get("provisioning_mode").(string) == cloneProvisioningRestore {
+        if snapshot, err = loadGoldenSnapshot(provider.StateDir, name); err != nil {
+            return diag.FromErr(err)
+        }
+        if snapshot == nil && configJSON == "" {
+            return diag.FromErr(fmt.Errorf("golden snapshot %q has not been taken yet: create a firecracker_vm with a golden_snapshot block named %q first, and make the clones depend on it, or set config_json to boot the clones and take it from them", name, name))
+        }
+    }
+    provisionedBy := cloneProvisioningBoot
+    if snapshot != nil {
+        provisionedBy = cloneProvisioningRestore
+    }
+
+    id := uuid.New().String()
+    resume := d.Get("resume").(bool)
+    baseURLs := d.Get("base_urls").([]interface{})
+    clients := make([]*FirecrackerClient, len(baseURLs))
+    for i, raw := range baseURLs {
+        if clients[i], err = provider.clientForBaseURL(raw.(string)); err != nil {
+            return diag.FromErr(err)
+        }
+    }
+
+    tflog.Info(ctx, "Provisioning Firecracker VM clones", map[string]interface{}{
+        "id":             id,
+        "snapshot":       name,
+        "clones":         len(clients),
+        "provisioned_by": provisionedBy,
+    })
+
+    // Clones are provisioned in parallel, each in its own Firecracker process
+    startedAt := time.Now()
+    errs := make([]error, len(clients))
+    var wg sync.WaitGroup
+    for i, client := range clients {
+        wg.Add(1)
+        go func(i int, client *FirecrackerClient) {
+            defer wg.Done()
+            var err error
+            if snapshot != nil {
+                err = client.LoadSnapshot(ctx, snapshot.SnapshotPath, snapshot.MemFilePath, resume)
+            } else {
+                err = bootClone(ctx, client, configJSON, cloneID(id, i))
+            }
+            if err != nil {
+                errs[i] = fmt.Errorf("clone %s at %s: %w", cloneID(id, i), client.BaseURL, err)
+            }
+        }(i, client)
+    }
+    wg.Wait()
+    restoreTime := time.Since(startedAt)
+
+    if err := errors.Join(errs...); err != nil {
+        // Shut down the clones that were provisioned, so the Firecracker processes can be reused
+        for i, client := range clients {
+            if errs[i] == nil {
+                client.DeleteVM(ctx, cloneID(id, i))
+            }
+        }
+        return diag.FromErr(fmt.Errorf("failed to provision clones of golden snapshot %s: %w", name, err))
+    }
+
+    var diags diag.Diagnostics
+    if snapshot == nil {
+        // Booted clones prime the snapshot, so later clones are restored from it
+        if d.Get("provisioning_mode").(string) == cloneProvisioningRestore {
+            spec := goldenSnapshotSpec{Name: name, Directory: goldenSnapshotDir(provider.StateDir, name)}
+            diags = append(diags, ensureGoldenSnapshot(ctx, clients[0], provider.StateDir, spec, cloneID(id, 0), "")...)
+        }
+        if !resume {
+            for i, client := range clients {
+                if err := client.api().Pause(ctx); err != nil {
+                    return append(diags, diag.FromErr(fmt.Errorf("failed to pause clone %s: %w", cloneID(id, i), err))...)
+                }
+            }
+        }
+    }
+
+    d.SetId(id)
+    d.Set("provisioned_by", provisionedBy)
+    d.Set("restore_time_ms", int(restoreTime.Milliseconds()))
+    tflog.Info(ctx, "Provisioned Firecracker VM clones", map[string]interface{}{
+        "id":              id,
+        "provisioned_by":  provisionedBy,
+        "restore_time_ms": restoreTime.Milliseconds(),
+    })
+
+    return append(diags, resourceFirecrackerVMCloneRead(ctx, d, m)...)
+}
+
+// bootClone boots a clone in a Firecracker process that has not started a VM, from a
+// Firecracker configuration file.
+func bootClone(ctx context.Context, client *FirecrackerClient, configJSON, cloneID string) error {
+    config, err := configFilePayload(configJSON, cloneID)
+    if err != nil {
+        return err
+    }
+    return client.CreateVM(ctx, config)
+}
+
+// resourceFirecrackerVMCloneRead refreshes the state of the clones. The clones are removed
+// from state when one of them was lost, such as when its Firecracker process was restarted,
+// so the next apply restores them again.
+func resourceFirecrackerVMCloneRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    id := d.Id()
+
+    var clones []interface{}
+    for i, raw := range d.Get("base_urls").([]interface{}) {
+        client, err := provider.clientForBaseURL(raw.(string))
+        if err != nil {
+            return diag.FromErr(err)
+        }
+
+        state, err := client.GetInstanceState(ctx)
+        if errors.Is(err, errHostUnreachable) && provider.TolerateUnreachableHosts {
+            return diag.Diagnostics{{
+                Severity: diag.Warning,
+                Summary:  "Firecracker host unreachable",
+                Detail:   fmt.Sprintf("Could not refresh clone %s, keeping the prior state of the clones: %s", cloneID(id, i), err),
+            }}
+        }
+        if err != nil {
+            return diag.FromErr(fmt.Errorf("error reading clone %s: %w", cloneID(id, i), err))
+        }
+
+        if state == "Not started" {
+            tflog.Warn(ctx, "Firecracker VM clone lost, removing the clones from state", map[string]interface{}{
+                "id":    cloneID(id, i),
+                "state": state,
+            })
+            d.SetId("")
+            return diag.Diagnostics{{
+                Severity: diag.Warning,
+                Summary:  "VM clone lost",
+                Detail:   fmt.Sprintf("The Firecracker process at %s no longer runs clone %s. The clones are removed from state and restored again on the next apply.", client.BaseURL, cloneID(id, i)),
+            }}
+        }
+
+        clones = append(clones, map[string]interface{}{
+            "id":       cloneID(id, i),
+            "base_url": client.BaseURL,
+            "state":    state,
+        })
+    }
+
+    if err := d.Set("clones", clones); err != nil {
+        return diag.FromErr(fmt.Errorf("failed to set clones: %w", err))
+    }
+    return nil
+}
+
+func resourceFirecrackerVMCloneDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    id := d.Id()
+
+    for i, raw := range d.Get("base_urls").([]interface{}) {
+        client, err := provider.clientForBaseURL(raw.(string))
+        if err != nil {
+            return diag.FromErr(err)
+        }
+        tflog.Info(ctx, "Deleting Firecracker VM clone", map[string]interface{}{
+            "id": cloneID(id, i),
+        })
+        if err := client.DeleteVM(ctx, cloneID(id, i)); err != nil {
+            return diag.FromErr(fmt.Errorf("error deleting clone %s: %w", cloneID(id, i), err))
+        }
+    }
+
+    d.SetId("")
+    return nil
+}
@@ -0,0 +1,194 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// configureWarmPoolProvider configures a provider serving fake APIs with warm pools enabled.
+func configureWarmPoolProvider(t *testing.T, stateDir string) *FirecrackerClient {
+	t.Helper()
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"base_url":    "fake://test",
+		"state_dir":   stateDir,
+		"experiments": []interface{}{featureWarmPools},
+	})
+	client, diags := configureProvider(context.Background(), d)
+	if diags.HasError() {
+		t.Fatalf("Failed to configure provider: %v", diags)
+	}
+	return client.(*FirecrackerClient)
+}
+
+func TestResourceFirecrackerVMClone(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	provider := configureWarmPoolProvider(t, stateDir)
+
+	if err := saveGoldenSnapshot(stateDir, &goldenSnapshot{
+		Name:         "ci",
+		VMID:         "vm-golden",
+		SnapshotPath: "/snapshots/ci/vmstate",
+		MemFilePath:  "/snapshots/ci/memory",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVMClone().Schema, map[string]interface{}{
+		"snapshot":  "ci",
+		"base_urls": []interface{}{"fake://clone-a", "fake://clone-b"},
+	})
+	if diags := resourceFirecrackerVMCloneCreate(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to create clones: %v", diags)
+	}
+
+	for i, name := range []string{"clone-a", "clone-b"} {
+		load, err := os.ReadFile(filepath.Join(stateDir, "fake", name, "snapshot_load.json"))
+		if err != nil || !strings.Contains(string(load), `"backend_path":"/snapshots/ci/memory"`) || !strings.Contains(string(load), `"resume_vm":true`) {
+			t.Errorf("Expected %s to load the golden snapshot, got %s, %v", name, load, err)
+		}
+		if state := d.Get(fmt.Sprintf("clones.%d.state", i)); state != "Running" {
+			t.Errorf("Expected clone %d to be running, got %v", i, state)
+		}
+	}
+	if id := d.Get("clones.1.id"); id != d.Id()+"-1" {
+		t.Errorf("Unexpected clone ID %v", id)
+	}
+
+	// A clone whose Firecracker process was restarted is lost
+	if err := os.RemoveAll(filepath.Join(stateDir, "fake", "clone-b")); err != nil {
+		t.Fatal(err)
+	}
+	diags := resourceFirecrackerVMCloneRead(ctx, d, provider)
+	if diags.HasError() || len(diags) != 1 || diags[0].Summary != "VM clone lost" {
+		t.Fatalf("Expected a warning about the lost clone, got %v", diags)
+	}
+	if d.Id() != "" {
+		t.Errorf("Expected the clones to be removed from state, got ID %s", d.Id())
+	}
+}
+
+func TestResourceFirecrackerVMClone_snapshotMissing(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVMClone().Schema, map[string]interface{}{
+		"snapshot":  "ci",
+		"base_urls": []interface{}{"fake://clone-a"},
+	})
+	diags := resourceFirecrackerVMCloneCreate(context.Background(), d, configureWarmPoolProvider(t, t.TempDir()))
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "has not been taken yet") {
+		t.Errorf("Expected an error about the missing snapshot, got %v", diags)
+	}
+}
+
+func TestResourceFirecrackerVMClone_requiresWarmPools(t *testing.T) {
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"snapshot":  "ci",
+		"base_urls": []interface{}{"fake://clone-a"},
+	})
+	_, err := resourceFirecrackerVMClone().Diff(context.Background(), nil, config, configureFakeProvider(t, t.TempDir()))
+	if err == nil || !strings.Contains(err.Error(), `experiments = ["warm_pools"]`) {
+		t.Errorf("Expected an error explaining how to enable warm pools, got %v", err)
+	}
+}
+
+// cloneConfigJSON is a Firecracker configuration clones are booted from.
+const cloneConfigJSON = `{
+  "boot-source": {"kernel_image_path": "/vmlinux"},
+  "drives": [{"drive_id": "rootfs", "path_on_host": "/rootfs.ext4", "is_root_device": true, "is_read_only": true}],
+  "machine-config": {"vcpu_count": 1, "mem_size_mib": 128}
+}`
+
+func TestResourceFirecrackerVMClone_primesSnapshot(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	provider := configureWarmPoolProvider(t, stateDir)
+
+	// Without the snapshot, the clones are booted and the snapshot taken from the first
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVMClone().Schema, map[string]interface{}{
+		"snapshot":    "ci",
+		"base_urls":   []interface{}{"fake://clone-a", "fake://clone-b"},
+		"config_json": cloneConfigJSON,
+	})
+	if diags := resourceFirecrackerVMCloneCreate(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to create clones: %v", diags)
+	}
+	if by := d.Get("provisioned_by"); by != cloneProvisioningBoot {
+		t.Errorf("Expected the clones to be booted, got %v", by)
+	}
+	for i, name := range []string{"clone-a", "clone-b"} {
+		actions, err := os.ReadFile(filepath.Join(stateDir, "fake", name, "actions.log"))
+		if err != nil || !strings.Contains(string(actions), "InstanceStart") {
+			t.Errorf("Expected %s to be booted, got %s, %v", name, actions, err)
+		}
+		if state := d.Get(fmt.Sprintf("clones.%d.state", i)); state != "Running" {
+			t.Errorf("Expected clone %d to be running, got %v", i, state)
+		}
+	}
+	snapshot, err := loadGoldenSnapshot(stateDir, "ci")
+	if err != nil || snapshot == nil || snapshot.VMID != d.Id()+"-0" {
+		t.Fatalf("Expected the snapshot to be taken from the first clone, got %+v, %v", snapshot, err)
+	}
+
+	// Later clones are restored from it
+	d = schema.TestResourceDataRaw(t, resourceFirecrackerVMClone().Schema, map[string]interface{}{
+		"snapshot":    "ci",
+		"base_urls":   []interface{}{"fake://clone-c"},
+		"config_json": cloneConfigJSON,
+	})
+	if diags := resourceFirecrackerVMCloneCreate(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to create clones: %v", diags)
+	}
+	if by := d.Get("provisioned_by"); by != cloneProvisioningRestore {
+		t.Errorf("Expected the clones to be restored, got %v", by)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "fake", "clone-c", "snapshot_load.json")); err != nil {
+		t.Errorf("Expected clone-c to load the snapshot, got %v", err)
+	}
+}
+
+func TestResourceFirecrackerVMClone_boot(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	provider := configureWarmPoolProvider(t, stateDir)
+	if err := saveGoldenSnapshot(stateDir, &goldenSnapshot{Name: "ci", SnapshotPath: "/snapshots/ci/vmstate", MemFilePath: "/snapshots/ci/memory"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Booting ignores the snapshot
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVMClone().Schema, map[string]interface{}{
+		"snapshot":          "ci",
+		"base_urls":         []interface{}{"fake://clone-a"},
+		"provisioning_mode": cloneProvisioningBoot,
+		"config_json":       cloneConfigJSON,
+		"resume":            false,
+	})
+	if diags := resourceFirecrackerVMCloneCreate(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to create clones: %v", diags)
+	}
+	if by := d.Get("provisioned_by"); by != cloneProvisioningBoot {
+		t.Errorf("Expected the clones to be booted, got %v", by)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "fake", "clone-a", "snapshot_load.json")); err == nil {
+		t.Error("Expected the snapshot not to be loaded")
+	}
+	if state := d.Get("clones.0.state"); state != "Paused" {
+		t.Errorf("Expected the clone to be left paused, got %v", state)
+	}
+
+	// Booting requires a configuration
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"snapshot":          "ci",
+		"base_urls":         []interface{}{"fake://clone-a"},
+		"provisioning_mode": cloneProvisioningBoot,
+	})
+	if _, err := resourceFirecrackerVMClone().Diff(ctx, nil, config, provider); err == nil || !strings.Contains(err.Error(), "requires config_json") {
+		t.Errorf("Expected an error about the missing config_json, got %v", err)
+	}
+}
@@ -2,11 +2,16 @@ package firecracker
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
@@ -41,6 +46,78 @@ func TestResourceFirecrackerVM_basic(t *testing.T) {
 	})
 }
 
+func TestVMIDFromConfig(t *testing.T) {
+	first, err := vmIDFromConfig(idSourceNameHash, "web-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, _ := vmIDFromConfig(idSourceNameHash, "web-1")
+	if first != second {
+		t.Errorf("Expected name-hash IDs to be stable, got %s and %s", first, second)
+	}
+	other, _ := vmIDFromConfig(idSourceNameHash, "web-2")
+	if first == other {
+		t.Errorf("Expected different names to yield different IDs, got %s for both", first)
+	}
+
+	if _, err := vmIDFromConfig(idSourceNameHash, ""); err == nil {
+		t.Errorf("Expected an error when name is empty for name-hash")
+	}
+
+	a, _ := vmIDFromConfig(idSourceUUID, "web-1")
+	b, _ := vmIDFromConfig(idSourceUUID, "web-1")
+	if a == b {
+		t.Errorf("Expected uuid IDs to differ between creates, got %s twice", a)
+	}
+}
+
+func TestCheckDriveIDs(t *testing.T) {
+	drive := func(id string, root bool) map[string]interface{} {
+		return map[string]interface{}{"drive_id": id, "path_on_host": "/images/" + id + ".ext4", "is_root_device": root}
+	}
+	unknown := "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+	cases := map[string]struct {
+		drives    []interface{}
+		cloudInit bool
+		expected  string
+	}{
+		"one root":       {[]interface{}{drive("rootfs", true), drive("data", false)}, false, ""},
+		"no root":        {[]interface{}{drive("data", false)}, false, "no drive sets is_root_device = true"},
+		"two roots":      {[]interface{}{drive("rootfs", true), drive("other", true)}, false, "drives rootfs, other all set is_root_device = true"},
+		"duplicate":      {[]interface{}{drive("rootfs", true), drive("data", false), drive("data", false)}, false, `drive_id "data" is used by more than one drive`},
+		"reserved root":  {[]interface{}{drive("root", true), drive("rootfs", false)}, false, `drive_id "rootfs" is reserved for the root device`},
+		"seed drive":     {[]interface{}{drive("rootfs", true), drive("cidata", false)}, true, `drive_id "cidata" is reserved for the cloud_init seed drive`},
+		"no seed drive":  {[]interface{}{drive("rootfs", true), drive("cidata", false)}, false, ""},
+		"unknown root":   {[]interface{}{map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": unknown}}, false, ""},
+		"unknown id":     {[]interface{}{drive("rootfs", true), drive(unknown, false), drive("data", false)}, false, ""},
+		"unknown drives": {nil, false, ""},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"kernel_image_path": "/images/vmlinux",
+				"drives":            tc.drives,
+				"machine_config":    []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+			}
+			if tc.drives == nil {
+				raw["drives"] = unknown
+			}
+			if tc.cloudInit {
+				raw["cloud_init"] = []interface{}{map[string]interface{}{"user_data": "#cloud-config\n"}}
+			}
+			_, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), nil)
+			if tc.expected == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tc.expected != "" && (err == nil || !strings.Contains(err.Error(), tc.expected)) {
+				t.Errorf("Expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
 func testAccProviders() map[string]*schema.Provider {
 	provider := Provider()
 	// Configure the provider with mock client for testing
@@ -50,7 +127,7 @@ func testAccProviders() map[string]*schema.Provider {
 	}
 }
 
-func testProviderConfigure(_ context.Context, d *schema.ResourceData) (interface{}, error) {
+func testProviderConfigure(_ context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	// Create a test server that will respond to API requests
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -156,3 +233,91 @@ resource "firecracker_vm" "test" {
   }
 }
 `
+
+func TestResourceFirecrackerVMCreate_guestMAC(t *testing.T) {
+	stateDir := t.TempDir()
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": image,
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+		"network_interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+			map[string]interface{}{"iface_id": "eth1", "host_dev_name": "tap1", "guest_mac": "AA:FC:00:00:00:01"},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(context.Background(), d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	// Interfaces without an address get one derived from the VM and interface IDs
+	generated := d.Get("network_interfaces.0.guest_mac").(string)
+	if generated != guestMAC(d.Id()+"/eth0") {
+		t.Errorf("Expected the address derived from the VM ID, got %q", generated)
+	}
+	if got := d.Get("network_interfaces.1.guest_mac"); got != "AA:FC:00:00:00:01" {
+		t.Errorf("Expected the configured address to be kept, got %v", got)
+	}
+
+	// Firecracker is given the generated address
+	data, err := os.ReadFile(filepath.Join(stateDir, "fake", "test", "network-interfaces_eth0.json"))
+	if err != nil {
+		t.Fatalf("Failed to read network interface: %v", err)
+	}
+	if !strings.Contains(string(data), generated) {
+		t.Errorf("Expected the interface to be configured with %s, got %s", generated, data)
+	}
+}
+
+func TestResourceFirecrackerVMCreate_renderedConfig(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path":   image,
+		"boot_args":           "console=ttyS0",
+		"sensitive_boot_args": "token=hunter2",
+		"hostname":            "web-1",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 2, "mem_size_mib": 256},
+		},
+		"network_interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(context.Background(), d, configureFakeProvider(t, t.TempDir())); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	rendered := d.Get("rendered_config_json").(string)
+	var config map[string]interface{}
+	if err := json.Unmarshal([]byte(rendered), &config); err != nil {
+		t.Fatalf("Expected rendered_config_json to be JSON, got %q: %v", rendered, err)
+	}
+	for _, key := range []string{"boot-source", "drives", "machine-config", "network-interfaces", "mmds-config"} {
+		if config[key] == nil {
+			t.Errorf("Expected %s in the rendered configuration, got %s", key, rendered)
+		}
+	}
+	for _, key := range []string{"vm-id", "mmds", "sensitive_boot_args"} {
+		if _, ok := config[key]; ok {
+			t.Errorf("Expected %s to be left out of the rendered configuration, got %s", key, rendered)
+		}
+	}
+	if strings.Contains(rendered, "hunter2") {
+		t.Errorf("Expected sensitive_boot_args to be left out, got %s", rendered)
+	}
+	if got := config["machine-config"].(map[string]interface{})["vcpu_count"]; got != float64(2) {
+		t.Errorf("Expected vcpu_count 2, got %v", got)
+	}
+}
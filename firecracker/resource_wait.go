@@ -0,0 +1,318 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// waitConditions are the arguments of firecracker_wait that each add a condition.
+var waitConditions = []string{"state", "port", "mmds_key", "console"}
+
+// resourceFirecrackerWait defines the firecracker_wait resource, which waits until
+// conditions about a VM are met when it is created, so resources depending on it are only
+// created once the VM is ready for them.
+func resourceFirecrackerWait() *schema.Resource {
+    return &schema.Resource{
+        CreateContext: withOperationTimeout(schema.TimeoutCreate, resourceFirecrackerWaitCreate),
+        ReadContext:   resourceFirecrackerWaitRead,
+        DeleteContext: resourceFirecrackerWaitDelete,
+        Schema: map[string]*schema.Schema{
+            "vm_id": {
+                Type:         schema.TypeString,
+                Required:     true,
+                ForceNew:     true,
+                Description:  "ID of the VM to wait for.",
+                ValidateFunc: validation.StringIsNotEmpty,
+            },
+            "host": {
+                Type:        schema.TypeString,
+                Optional:    true,
+                ForceNew:    true,
+                Description: "Host from the provider's host pool the VM runs on. Defaults to the host the VM is registered on.",
+            },
+            "state": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                AtLeastOneOf: waitConditions,
+                Description:  "Wait until Firecracker reports this state for the VM: Not started, Running or Paused.",
+                ValidateFunc: validation.StringInSlice([]string{"Not started", "Running", "Paused"}, false),
+            },
+            "port": {
+                Type:         schema.TypeList,
+                Optional:     true,
+                ForceNew:     true,
+                MaxItems:     1,
+                AtLeastOneOf: waitConditions,
+                Description:  "Wait until a TCP port of the guest accepts connections.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "address": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            ForceNew:     true,
+                            Description:  "Address of the guest.",
+                            ValidateFunc: validation.StringIsNotEmpty,
+                        },
+                        "port": {
+                            Type:         schema.TypeInt,
+                            Required:     true,
+                            ForceNew:     true,
+                            Description:  "TCP port.",
+                            ValidateFunc: validation.IsPortNumber,
+                        },
+                    },
+                },
+            },
+            "mmds_key": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                AtLeastOneOf: waitConditions,
+                Description:  "Wait until this key is present in the VM's MMDS data store, as a path such as latest/meta-data/instance-id.",
+                ValidateFunc: validation.StringIsNotEmpty,
+            },
+            "console": {
+                Type:         schema.TypeList,
+                Optional:     true,
+                ForceNew:     true,
+                MaxItems:     1,
+                AtLeastOneOf: waitConditions,
+                Description:  "Wait until the VM's serial console output, written to a file on the host running Terraform, matches a pattern.",
+                Elem: &schema.Resource{
+                    Schema: map[string]*schema.Schema{
+                        "path": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            ForceNew:     true,
+                            Description:  "File the Firecracker process's serial console output is written to.",
+                            ValidateFunc: validation.StringIsNotEmpty,
+                        },
+                        "pattern": {
+                            Type:         schema.TypeString,
+                            Required:     true,
+                            ForceNew:     true,
+                            Description:  "Regular expression the output must match, such as 'login:'.",
+                            ValidateFunc: validation.StringIsValidRegExp,
+                        },
+                    },
+                },
+            },
+            "timeout": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      "5m",
+                Description:  "How long to wait for the conditions, as a duration such as '90s' or '5m'.",
+                ValidateFunc: validateDuration,
+            },
+            "interval": {
+                Type:         schema.TypeString,
+                Optional:     true,
+                ForceNew:     true,
+                Default:      "2s",
+                Description:  "How often the conditions are checked, as a duration such as '500ms'.",
+                ValidateFunc: validateDuration,
+            },
+            "triggers": {
+                Type:        schema.TypeMap,
+                Optional:    true,
+                ForceNew:    true,
+                Description: "Arbitrary values that wait again when changed, such as the ID of a resource the conditions depend on.",
+                Elem:        &schema.Schema{Type: schema.TypeString},
+            },
+            "ready_at": {
+                Type:        schema.TypeString,
+                Computed:    true,
+                Description: "When the conditions were met, in RFC 3339 format.",
+            },
+        },
+    }
+}
+
+// waitCondition is a condition firecracker_wait waits for.
+type waitCondition struct {
+    // Description says what is waited for.
+    Description string
+    // Check returns why the condition is not met yet, or nil once it is.
+    Check func(ctx context.Context) error
+}
+
+// waitConditionsFromConfig returns the conditions of a firecracker_wait resource.
+func waitConditionsFromConfig(d *schema.ResourceData, client *FirecrackerClient, interval time.Duration) ([]waitCondition, error) {
+    var conditions []waitCondition
+
+    if state := d.Get("state").(string); state != "" {
+        conditions = append(conditions, waitCondition{
+            Description: fmt.Sprintf("state %s", state),
+            Check: func(ctx context.Context) error {
+                current, err := client.GetInstanceState(ctx)
+                if err != nil {
+                    return err
+                }
+                if current != state {
+                    return fmt.Errorf("VM is %s", current)
+                }
+                return nil
+            },
+        })
+    }
+
+    if raw := d.Get("port").([]interface{}); len(raw) > 0 && raw[0] != nil {
+        block := raw[0].(map[string]interface{})
+        address := net.JoinHostPort(block["address"].(string), strconv.Itoa(block["port"].(int)))
+        conditions = append(conditions, waitCondition{
+            Description: fmt.Sprintf("port %s", address),
+            Check: func(ctx context.Context) error {
+                dialer := net.Dialer{Timeout: interval}
+                conn, err := dialer.DialContext(ctx, "tcp", address)
+                if err != nil {
+                    return err
+                }
+                return conn.Close()
+            },
+        })
+    }
+
+    if key := strings.Trim(d.Get("mmds_key").(string), "/"); key != "" {
+        conditions = append(conditions, waitCondition{
+            Description: fmt.Sprintf("MMDS key %s", key),
+            Check: func(ctx context.Context) error {
+                contents, err := client.getComponent(ctx, client.BaseURL+"/mmds")
+                if err != nil {
+                    return err
+                }
+                var value interface{} = contents
+                for _, part := range strings.Split(key, "/") {
+                    object, ok := value.(map[string]interface{})
+                    if !ok {
+                        return fmt.Errorf("%s is not present", key)
+                    }
+                    if value, ok = object[part]; !ok {
+                        return fmt.Errorf("%s is not present", key)
+                    }
+                }
+                return nil
+            },
+        })
+    }
+
+    if raw := d.Get("console").([]interface{}); len(raw) > 0 && raw[0] != nil {
+        if d.Get("host").(string) != "" {
+            return nil, fmt.Errorf("console is only supported for VMs running on the host running Terraform")
+        }
+        block := raw[0].(map[string]interface{})
+        path := block["path"].(string)
+        pattern, err := regexp.Compile(block["pattern"].(string))
+        if err != nil {
+            return nil, fmt.Errorf("invalid console pattern: %w", err)
+        }
+        conditions = append(conditions, waitCondition{
+            Description: fmt.Sprintf("console output matching %q", pattern),
+            Check: func(ctx context.Context) error {
+                output, err := os.ReadFile(path)
+                if err != nil {
+                    return err
+                }
+                if !pattern.Match(output) {
+                    return fmt.Errorf("%s does not match yet", path)
+                }
+                return nil
+            },
+        })
+    }
+
+    return conditions, nil
+}
+
+// waitFor checks the conditions every interval until they are all met, or the timeout
+// expires. Conditions stay met once they are.
+func waitFor(operation context.Context, vmID string, conditions []waitCondition, timeout, interval time.Duration) error {
+    ctx, cancel := context.WithTimeout(operation, timeout)
+    defer cancel()
+
+    pending := conditions
+    for attempt := 1; ; attempt++ {
+        var unmet []string
+        var remaining []waitCondition
+        for _, condition := range pending {
+            if err := condition.Check(ctx); err != nil {
+                unmet = append(unmet, fmt.Sprintf("%s: %s", condition.Description, err))
+                remaining = append(remaining, condition)
+            }
+        }
+        pending = remaining
+        if len(pending) == 0 {
+            tflog.Info(ctx, "VM is ready", map[string]interface{}{
+                "id":       vmID,
+                "attempts": attempt,
+            })
+            return nil
+        }
+
+        tflog.Debug(ctx, "VM not ready yet", map[string]interface{}{
+            "id":      vmID,
+            "attempt": attempt,
+            "unmet":   unmet,
+        })
+
+        select {
+        case <-ctx.Done():
+            return waitAbortedError(operation, "VM "+vmID, timeout, errors.New(strings.Join(unmet, "; ")))
+        case <-time.After(interval):
+        }
+    }
+}
+
+func resourceFirecrackerWaitCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    provider := m.(*FirecrackerClient)
+    vmID := d.Get("vm_id").(string)
+
+    client, err := provider.clientForVM(vmID, d.Get("host").(string))
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    timeout, _ := time.ParseDuration(d.Get("timeout").(string))
+    interval, _ := time.ParseDuration(d.Get("interval").(string))
+    conditions, err := waitConditionsFromConfig(d, client, interval)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+
+    tflog.Info(ctx, "Waiting for VM", map[string]interface{}{
+        "id":         vmID,
+        "conditions": len(conditions),
+        "timeout":    timeout.String(),
+    })
+    if err := waitFor(ctx, vmID, conditions, timeout, interval); err != nil {
+        return diag.FromErr(err)
+    }
+
+    d.SetId(vmID)
+    d.Set("ready_at", time.Now().UTC().Format(time.RFC3339))
+    return nil
+}
+
+// resourceFirecrackerWaitRead keeps the state as it is: the conditions were met when the
+// resource was created, and a VM that is no longer ready does not undo that.
+func resourceFirecrackerWaitRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    return nil
+}
+
+func resourceFirecrackerWaitDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+    d.SetId("")
+    return nil
+}
@@ -0,0 +1,76 @@
+package firecracker
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestResourceFirecrackerWait(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+
+	fakeDir := filepath.Join(stateDir, "fake", "test")
+	if err := os.MkdirAll(fakeDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"actions.log": `{"action_type":"InstanceStart"}` + "\n",
+		"mmds.json":   `{"latest":{"meta-data":{"instance-id":"vm-1"}}}`,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(fakeDir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	console := filepath.Join(t.TempDir(), "console.log")
+	if err := os.WriteFile(console, []byte("Welcome\nvm-1 login: "), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen: %v", err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerWait().Schema, map[string]interface{}{
+		"vm_id":    "vm-1",
+		"state":    "Running",
+		"mmds_key": "latest/meta-data/instance-id",
+		"port":     []interface{}{map[string]interface{}{"address": "127.0.0.1", "port": port}},
+		"console":  []interface{}{map[string]interface{}{"path": console, "pattern": "login: $"}},
+		"interval": "10ms",
+	})
+	if diags := resourceFirecrackerWaitCreate(ctx, d, client); diags.HasError() {
+		t.Fatalf("Expected the conditions to be met, got %v", diags)
+	}
+	if d.Id() != "vm-1" || d.Get("ready_at").(string) == "" {
+		t.Errorf("Unexpected ID %q or ready_at %q", d.Id(), d.Get("ready_at"))
+	}
+}
+
+func TestResourceFirecrackerWait_timeout(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerWait().Schema, map[string]interface{}{
+		"vm_id":    "vm-1",
+		"state":    "Paused",
+		"mmds_key": "latest/user-data",
+		"timeout":  "50ms",
+		"interval": "10ms",
+	})
+	diags := resourceFirecrackerWaitCreate(context.Background(), d, configureFakeProvider(t, t.TempDir()))
+	if !diags.HasError() {
+		t.Fatal("Expected the wait to time out")
+	}
+	for _, expected := range []string{"timed out after 50ms", "state Paused: VM is Not started", "MMDS key latest/user-data"} {
+		if !strings.Contains(diags[0].Summary, expected) {
+			t.Errorf("Expected the error to contain %q, got %s", expected, diags[0].Summary)
+		}
+	}
+}
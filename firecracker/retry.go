@@ -0,0 +1,126 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "syscall"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const (
+    defaultRetryMaxAttempts = 3
+    defaultRetryMinBackoff  = "200ms"
+    defaultRetryMaxBackoff  = "2s"
+)
+
+// retryPolicy says how often, and how long apart, requests the Firecracker API could not
+// be reached for are sent again.
+type retryPolicy struct {
+    MaxAttempts int
+    MinBackoff  time.Duration
+    MaxBackoff  time.Duration
+}
+
+// retryPolicyFromConfig returns the policy of the provider's retry block, or the default
+// policy without one.
+func retryPolicyFromConfig(raw []interface{}) (*retryPolicy, error) {
+    block := map[string]interface{}{
+        "max_attempts": defaultRetryMaxAttempts,
+        "min_backoff":  defaultRetryMinBackoff,
+        "max_backoff":  defaultRetryMaxBackoff,
+    }
+    if len(raw) > 0 && raw[0] != nil {
+        block = raw[0].(map[string]interface{})
+    }
+
+    minBackoff, err := time.ParseDuration(block["min_backoff"].(string))
+    if err != nil {
+        return nil, fmt.Errorf("invalid retry min_backoff: %w", err)
+    }
+    maxBackoff, err := time.ParseDuration(block["max_backoff"].(string))
+    if err != nil {
+        return nil, fmt.Errorf("invalid retry max_backoff: %w", err)
+    }
+    if maxBackoff < minBackoff {
+        return nil, fmt.Errorf("retry max_backoff %s is shorter than min_backoff %s", maxBackoff, minBackoff)
+    }
+    return &retryPolicy{MaxAttempts: block["max_attempts"].(int), MinBackoff: minBackoff, MaxBackoff: maxBackoff}, nil
+}
+
+// backoff returns how long to wait before the attempt after the given one: MinBackoff,
+// doubled with every attempt, up to MaxBackoff.
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+    delay := p.MinBackoff
+    for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+        delay *= 2
+    }
+    if delay > p.MaxBackoff {
+        delay = p.MaxBackoff
+    }
+    return delay
+}
+
+// isRetryableError reports whether a request with the method failed because the Firecracker
+// API could not be reached yet, such as when its socket is not listening or does not exist
+// yet. Requests the API answered are never retried: it rejected them, and would reject them
+// again. Requests changing the VM are only retried when they failed before they were sent,
+// since a connection reset or timed out afterwards may have been applied, and an action such
+// as InstanceStart must not be applied twice. Reads are also retried after those.
+func isRetryableError(method string, err error) bool {
+    if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+        return false
+    }
+    for _, errno := range []syscall.Errno{syscall.ECONNREFUSED, syscall.ENOENT, syscall.EAGAIN} {
+        if errors.Is(err, errno) {
+            return true
+        }
+    }
+    var opErr *net.OpError
+    if errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout() {
+        return true
+    }
+    if !readOnlyMethod(method) {
+        return false
+    }
+    var netErr net.Error
+    return errors.Is(err, syscall.ECONNRESET) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// sendWithRetries sends a request with send, sending it again by the client's retry policy
+// while the API cannot be reached. The context of the request bounds the retries.
+func (c *FirecrackerClient) sendWithRetries(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+    ctx := req.Context()
+    for attempt := 1; ; attempt++ {
+        resp, err := send(req)
+        if err == nil || c.retry == nil || attempt >= c.retry.MaxAttempts || !isRetryableError(req.Method, err) || ctx.Err() != nil {
+            return resp, err
+        }
+
+        delay := c.retry.backoff(attempt)
+        tflog.Debug(ctx, "Firecracker API not reachable yet, retrying", map[string]interface{}{
+            "url":     req.URL.String(),
+            "attempt": attempt,
+            "backoff": delay.String(),
+            "error":   err.Error(),
+        })
+        select {
+        case <-ctx.Done():
+            return nil, err
+        case <-time.After(delay):
+        }
+
+        // Send the body again
+        if req.GetBody != nil {
+            body, bodyErr := req.GetBody()
+            if bodyErr != nil {
+                return nil, err
+            }
+            req.Body = body
+        }
+    }
+}
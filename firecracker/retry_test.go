@@ -0,0 +1,126 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	refused := &url.Error{Op: "Put", URL: "http://localhost/boot-source", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}
+	missingSocket := &net.OpError{Op: "dial", Net: "unix", Err: os.NewSyscallError("connect", syscall.ENOENT)}
+	reset := &net.OpError{Op: "read", Net: "unix", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+
+	for name, tc := range map[string]struct {
+		method    string
+		err       error
+		retryable bool
+	}{
+		"connection refused":       {http.MethodPut, refused, true},
+		"missing socket":           {http.MethodPatch, missingSocket, true},
+		"connection reset on read": {http.MethodGet, reset, true},
+		"connection reset on put":  {http.MethodPut, reset, false},
+		"cancelled":                {http.MethodGet, fmt.Errorf("request: %w", context.Canceled), false},
+		"other":                    {http.MethodGet, errors.New("malformed response"), false},
+	} {
+		if got := isRetryableError(tc.method, tc.err); got != tc.retryable {
+			t.Errorf("%s: expected retryable to be %t, got %t", name, tc.retryable, got)
+		}
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := &retryPolicy{MaxAttempts: 5, MinBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	for attempt, expected := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond, 4: 300 * time.Millisecond} {
+		if got := policy.backoff(attempt); got != expected {
+			t.Errorf("Expected a backoff of %s after attempt %d, got %s", expected, attempt, got)
+		}
+	}
+
+	if _, err := retryPolicyFromConfig([]interface{}{map[string]interface{}{"max_attempts": 3, "min_backoff": "2s", "max_backoff": "1s"}}); err == nil {
+		t.Error("Expected an error when max_backoff is shorter than min_backoff")
+	}
+}
+
+func TestSendComponent_retries(t *testing.T) {
+	attempts := 0
+	client := &FirecrackerClient{
+		BaseURL: "http://localhost",
+		retry:   &retryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		HTTPClient: &mockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, &net.OpError{Op: "dial", Net: "unix", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+			}
+			body, _ := io.ReadAll(req.Body)
+			if string(body) != `{"vcpu_count":1}` {
+				return nil, fmt.Errorf("unexpected body %q", body)
+			}
+			return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}},
+	}
+
+	if err := client.sendComponent(context.Background(), http.MethodPut, client.BaseURL+"/machine-config", map[string]interface{}{"vcpu_count": 1}); err != nil {
+		t.Fatalf("Expected the request to succeed once the API is up, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestSendComponent_resetNotRetried(t *testing.T) {
+	attempts := 0
+	client := &FirecrackerClient{
+		BaseURL: "http://localhost",
+		retry:   &retryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		HTTPClient: &mockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+			attempts++
+			return nil, &net.OpError{Op: "read", Net: "unix", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+		}},
+	}
+
+	// The API may have started the VM before the connection was reset
+	err := client.sendComponent(context.Background(), http.MethodPut, client.BaseURL+"/actions", map[string]interface{}{"action_type": "InstanceStart"})
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("Expected the connection reset to be returned, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected the request not to be sent again, got %d attempts", attempts)
+	}
+}
+
+func TestSendComponent_faultMessage(t *testing.T) {
+	attempts := 0
+	client := &FirecrackerClient{
+		BaseURL: "http://localhost",
+		retry:   &retryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		HTTPClient: &mockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+			attempts++
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Body:       io.NopCloser(strings.NewReader(`{"fault_message":"The memory size (MiB) is invalid."}`)),
+			}, nil
+		}},
+	}
+
+	err := client.sendComponent(context.Background(), http.MethodPut, client.BaseURL+"/machine-config", map[string]interface{}{"mem_size_mib": 0})
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected an API error, got %v", err)
+	}
+	if expected := "PUT /machine-config: Firecracker rejected the request (400 Bad Request): The memory size (MiB) is invalid."; err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a rejected request not to be retried, got %d attempts", attempts)
+	}
+}
@@ -0,0 +1,352 @@
+package firecracker
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "hash"
+    "io"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// rootfsFile is a file written into a root filesystem on top of its source.
+type rootfsFile struct {
+    Destination string
+    Source      string
+    Content     string
+    Mode        os.FileMode
+}
+
+// rootfsSpec describes how to build an ext4 root filesystem image.
+// Exactly one of SourceDir, SourceTarball and BaseImage is set.
+type rootfsSpec struct {
+    Path          string
+    SizeMiB       int
+    Label         string
+    SourceDir     string
+    SourceTarball string
+    BaseImage     string
+    Files         []rootfsFile
+}
+
+// rootfsConfig is implemented by both *schema.ResourceData and *schema.ResourceDiff,
+// so the spec can be built at plan time to compute the checksum.
+type rootfsConfig interface {
+    Get(key string) interface{}
+}
+
+// rootfsSpecFromConfig returns the build settings of a firecracker_rootfs resource.
+func rootfsSpecFromConfig(d rootfsConfig) (rootfsSpec, error) {
+    spec := rootfsSpec{
+        Path:          d.Get("path").(string),
+        SizeMiB:       d.Get("size_mib").(int),
+        Label:         d.Get("label").(string),
+        SourceDir:     d.Get("source_dir").(string),
+        SourceTarball: d.Get("source_tarball").(string),
+        BaseImage:     d.Get("base_image").(string),
+    }
+
+    for _, raw := range d.Get("file").([]interface{}) {
+        cfg := raw.(map[string]interface{})
+        mode, err := strconv.ParseUint(cfg["mode"].(string), 8, 32)
+        if err != nil {
+            return rootfsSpec{}, fmt.Errorf("invalid mode %q for %s: %w", cfg["mode"], cfg["destination"], err)
+        }
+        file := rootfsFile{
+            Destination: cfg["destination"].(string),
+            Source:      cfg["source"].(string),
+            Content:     cfg["content"].(string),
+            Mode:        os.FileMode(mode),
+        }
+        if file.Source != "" && file.Content != "" {
+            return rootfsSpec{}, fmt.Errorf("file %s must set either source or content, not both", file.Destination)
+        }
+        spec.Files = append(spec.Files, file)
+    }
+
+    return spec, nil
+}
+
+// rootfsChecksum hashes everything the image is built from: its settings, the contents
+// of its source and the overlay files. A change in any of them calls for a rebuild.
+func rootfsChecksum(spec rootfsSpec) (string, error) {
+    h := sha256.New()
+    fmt.Fprintf(h, "size=%d\nlabel=%s\n", spec.SizeMiB, spec.Label)
+
+    switch {
+    case spec.SourceDir != "":
+        fmt.Fprintf(h, "dir\n")
+        if err := hashDir(h, spec.SourceDir); err != nil {
+            return "", err
+        }
+    case spec.SourceTarball != "":
+        fmt.Fprintf(h, "tarball\n")
+        if err := hashFile(h, spec.SourceTarball); err != nil {
+            return "", err
+        }
+    case spec.BaseImage != "":
+        fmt.Fprintf(h, "image\n")
+        if err := hashFile(h, spec.BaseImage); err != nil {
+            return "", err
+        }
+    }
+
+    for _, file := range spec.Files {
+        fmt.Fprintf(h, "file=%s mode=%o\n", file.Destination, file.Mode)
+        if file.Source != "" {
+            if err := hashFile(h, file.Source); err != nil {
+                return "", err
+            }
+        } else {
+            io.WriteString(h, file.Content)
+        }
+    }
+
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile adds the contents of a file to a hash.
+func hashFile(h hash.Hash, name string) error {
+    f, err := os.Open(name)
+    if err != nil {
+        return fmt.Errorf("failed to read %s: %w", name, err)
+    }
+    defer f.Close()
+
+    if _, err := io.Copy(h, f); err != nil {
+        return fmt.Errorf("failed to read %s: %w", name, err)
+    }
+    return nil
+}
+
+// hashDir adds the names, modes and contents of everything under a directory to a hash.
+func hashDir(h hash.Hash, dir string) error {
+    return filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+        if err != nil {
+            return fmt.Errorf("failed to read %s: %w", name, err)
+        }
+        info, err := entry.Info()
+        if err != nil {
+            return fmt.Errorf("failed to read %s: %w", name, err)
+        }
+
+        rel, _ := filepath.Rel(dir, name)
+        fmt.Fprintf(h, "%s %s\n", filepath.ToSlash(rel), info.Mode())
+
+        switch {
+        case info.Mode()&fs.ModeSymlink != 0:
+            target, err := os.Readlink(name)
+            if err != nil {
+                return fmt.Errorf("failed to read %s: %w", name, err)
+            }
+            io.WriteString(h, target)
+        case info.Mode().IsRegular():
+            return hashFile(h, name)
+        }
+        return nil
+    })
+}
+
+// buildRootfs builds the image into a temporary file next to its destination and
+// moves it into place once complete, so a failed build never leaves a partial image.
+func buildRootfs(ctx context.Context, spec rootfsSpec) error {
+    if err := os.MkdirAll(filepath.Dir(spec.Path), 0o755); err != nil {
+        return fmt.Errorf("failed to create rootfs directory: %w", err)
+    }
+
+    tmp := spec.Path + ".tmp"
+    defer os.Remove(tmp)
+    if err := trackArtifact(ctx, hostArtifact{Path: tmp}); err != nil {
+        return err
+    }
+
+    var err error
+    if spec.BaseImage != "" {
+        err = buildRootfsFromImage(ctx, tmp, spec)
+    } else {
+        err = buildRootfsFromTree(ctx, tmp, spec)
+    }
+    if err != nil {
+        return err
+    }
+
+    if err := os.Rename(tmp, spec.Path); err != nil {
+        return fmt.Errorf("failed to move rootfs image into place: %w", err)
+    }
+    return nil
+}
+
+// buildRootfsFromTree populates a staging directory from the source directory or tarball
+// plus the overlay files, and creates a new ext4 filesystem from it.
+func buildRootfsFromTree(ctx context.Context, image string, spec rootfsSpec) error {
+    stage, err := os.MkdirTemp("", "rootfs")
+    if err != nil {
+        return fmt.Errorf("failed to create staging directory: %w", err)
+    }
+    defer os.RemoveAll(stage)
+    if err := trackArtifact(ctx, hostArtifact{Path: stage}); err != nil {
+        return err
+    }
+
+    if spec.SourceDir != "" {
+        if output, err := runCommand(ctx, "cp", "-a", filepath.Clean(spec.SourceDir)+"/.", stage); err != nil {
+            return fmt.Errorf("failed to copy %s: %w: %s", spec.SourceDir, err, strings.TrimSpace(string(output)))
+        }
+    } else {
+        if output, err := runCommand(ctx, "tar", "-xf", spec.SourceTarball, "-C", stage); err != nil {
+            return fmt.Errorf("failed to extract %s: %w: %s", spec.SourceTarball, err, strings.TrimSpace(string(output)))
+        }
+    }
+
+    for _, file := range spec.Files {
+        dest := filepath.Join(stage, filepath.FromSlash(file.Destination))
+        if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+            return fmt.Errorf("failed to create directory for %s: %w", file.Destination, err)
+        }
+        data, err := rootfsFileContents(file)
+        if err != nil {
+            return err
+        }
+        if err := os.WriteFile(dest, data, file.Mode); err != nil {
+            return fmt.Errorf("failed to write %s: %w", file.Destination, err)
+        }
+        // WriteFile only applies the mode to new files and is subject to the umask
+        if err := os.Chmod(dest, file.Mode); err != nil {
+            return fmt.Errorf("failed to set mode of %s: %w", file.Destination, err)
+        }
+    }
+
+    args := []string{"-q", "-F", "-t", "ext4", "-L", spec.Label, "-d", stage, image, fmt.Sprintf("%dM", spec.SizeMiB)}
+    if output, err := runCommand(ctx, "mke2fs", args...); err != nil {
+        return fmt.Errorf("failed to create ext4 filesystem: %w: %s", err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// buildRootfsFromImage copies the base image, grows it to the requested size, relabels it
+// and writes the overlay files into it with debugfs, without mounting it.
+func buildRootfsFromImage(ctx context.Context, image string, spec rootfsSpec) error {
+    info, err := os.Stat(spec.BaseImage)
+    if err != nil {
+        return fmt.Errorf("failed to read base image: %w", err)
+    }
+    size := int64(spec.SizeMiB) * 1024 * 1024
+    if size < info.Size() {
+        return fmt.Errorf("size_mib %d is smaller than base image %s (%d MiB)", spec.SizeMiB, spec.BaseImage, info.Size()/(1024*1024))
+    }
+
+    if err := copyFile(spec.BaseImage, image); err != nil {
+        return err
+    }
+
+    commands := [][]string{}
+    if size > info.Size() {
+        if err := os.Truncate(image, size); err != nil {
+            return fmt.Errorf("failed to grow rootfs image: %w", err)
+        }
+        commands = append(commands,
+            []string{"e2fsck", "-f", "-p", image},
+            []string{"resize2fs", image},
+        )
+    }
+    commands = append(commands, []string{"tune2fs", "-L", spec.Label, image})
+    for _, command := range commands {
+        if output, err := runCommand(ctx, command[0], command[1:]...); err != nil {
+            return fmt.Errorf("failed to run %s: %w: %s", command[0], err, strings.TrimSpace(string(output)))
+        }
+    }
+
+    if len(spec.Files) == 0 {
+        return nil
+    }
+
+    stage, err := os.MkdirTemp("", "rootfs")
+    if err != nil {
+        return fmt.Errorf("failed to create staging directory: %w", err)
+    }
+    defer os.RemoveAll(stage)
+    if err := trackArtifact(ctx, hostArtifact{Path: stage}); err != nil {
+        return err
+    }
+
+    script, err := debugfsOverlayScript(stage, spec.Files)
+    if err != nil {
+        return err
+    }
+    scriptPath := filepath.Join(stage, "debugfs.cmd")
+    if err := os.WriteFile(scriptPath, []byte(script), 0o600); err != nil {
+        return fmt.Errorf("failed to write debugfs commands: %w", err)
+    }
+    if output, err := runCommand(ctx, "debugfs", "-w", "-f", scriptPath, image); err != nil {
+        return fmt.Errorf("failed to write files into rootfs image: %w: %s", err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// debugfsOverlayScript stages the overlay files in dir and returns the debugfs commands
+// writing them into an image. debugfs carries on after failed commands, so creating
+// directories that already exist and removing files that do not are harmless.
+func debugfsOverlayScript(dir string, files []rootfsFile) (string, error) {
+    var b strings.Builder
+    for i, file := range files {
+        data, err := rootfsFileContents(file)
+        if err != nil {
+            return "", err
+        }
+        local := filepath.Join(dir, strconv.Itoa(i))
+        if err := os.WriteFile(local, data, 0o600); err != nil {
+            return "", fmt.Errorf("failed to stage %s: %w", file.Destination, err)
+        }
+
+        parent := path.Dir(file.Destination)
+        parents := []string{}
+        for ; parent != "/"; parent = path.Dir(parent) {
+            parents = append([]string{parent}, parents...)
+        }
+        for _, parentDir := range parents {
+            fmt.Fprintf(&b, "mkdir %s\n", parentDir)
+        }
+        fmt.Fprintf(&b, "rm %s\n", file.Destination)
+        fmt.Fprintf(&b, "write %s %s\n", local, file.Destination)
+        // The inode mode includes the regular file type bits
+        fmt.Fprintf(&b, "sif %s mode 0%o\n", file.Destination, 0o100000|uint32(file.Mode.Perm()))
+    }
+    return b.String(), nil
+}
+
+// rootfsFileContents returns the contents of an overlay file.
+func rootfsFileContents(file rootfsFile) ([]byte, error) {
+    if file.Source == "" {
+        return []byte(file.Content), nil
+    }
+    data, err := os.ReadFile(file.Source)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read %s: %w", file.Source, err)
+    }
+    return data, nil
+}
+
+// copyFile copies the contents of src to dst, replacing dst if it exists.
+func copyFile(src, dst string) error {
+    in, err := os.Open(src)
+    if err != nil {
+        return fmt.Errorf("failed to read %s: %w", src, err)
+    }
+    defer in.Close()
+
+    out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+    if err != nil {
+        return fmt.Errorf("failed to create %s: %w", dst, err)
+    }
+    if _, err := io.Copy(out, in); err != nil {
+        out.Close()
+        return fmt.Errorf("failed to copy %s: %w", src, err)
+    }
+    return out.Close()
+}
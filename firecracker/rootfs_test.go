@@ -0,0 +1,135 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestRootfsChecksum(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "init"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+
+	spec := rootfsSpec{SizeMiB: 512, Label: "rootfs", SourceDir: dir}
+	before, err := rootfsChecksum(spec)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if again, _ := rootfsChecksum(spec); again != before {
+		t.Errorf("Expected a stable checksum, got %s and %s", before, again)
+	}
+
+	// Changing a source file changes the checksum
+	if err := os.WriteFile(filepath.Join(dir, "init"), []byte("#!/bin/sh\nexec /sbin/init\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	if after, _ := rootfsChecksum(spec); after == before {
+		t.Errorf("Expected the checksum to change with the source")
+	}
+
+	// So does an overlay file
+	spec.Files = []rootfsFile{{Destination: "/etc/hostname", Content: "web-1\n", Mode: 0o644}}
+	withFile, _ := rootfsChecksum(spec)
+	spec.Files[0].Content = "web-2\n"
+	if changed, _ := rootfsChecksum(spec); changed == withFile {
+		t.Errorf("Expected the checksum to change with the overlay files")
+	}
+}
+
+func TestRootfsSpecFromConfig(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerRootfs().Schema, map[string]interface{}{
+		"name":           "base",
+		"source_tarball": "/images/base.tar.gz",
+		"file": []interface{}{
+			map[string]interface{}{
+				"destination": "/usr/local/bin/agent",
+				"source":      "/build/agent",
+				"mode":        "0755",
+			},
+		},
+	})
+
+	spec, err := rootfsSpecFromConfig(d)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if spec.SizeMiB != 1024 || spec.Label != "rootfs" {
+		t.Errorf("Expected default size and label, got %d and %s", spec.SizeMiB, spec.Label)
+	}
+	if len(spec.Files) != 1 || spec.Files[0].Mode != 0o755 {
+		t.Errorf("Unexpected files %v", spec.Files)
+	}
+}
+
+func TestBuildRootfs_tarball(t *testing.T) {
+	var commands []string
+	original := runCommand
+	defer func() { runCommand = original }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		// Stand in for mke2fs creating the image
+		if name == "mke2fs" {
+			return nil, os.WriteFile(args[len(args)-2], []byte("ext4"), 0o644)
+		}
+		return nil, nil
+	}
+
+	path := filepath.Join(t.TempDir(), "rootfs", "base.ext4")
+	spec := rootfsSpec{
+		Path:          path,
+		SizeMiB:       256,
+		Label:         "base",
+		SourceTarball: "/images/base.tar.gz",
+		Files:         []rootfsFile{{Destination: "/etc/hostname", Content: "web-1\n", Mode: 0o644}},
+	}
+	if err := buildRootfs(context.Background(), spec); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(commands) != 2 || !strings.HasPrefix(commands[0], "tar -xf /images/base.tar.gz -C ") {
+		t.Fatalf("Unexpected commands %v", commands)
+	}
+	if !strings.HasPrefix(commands[1], "mke2fs -q -F -t ext4 -L base -d ") || !strings.HasSuffix(commands[1], path+".tmp 256M") {
+		t.Errorf("Unexpected mke2fs command %s", commands[1])
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the image to be moved into place, got %v", err)
+	}
+}
+
+func TestBuildRootfs_baseImageTooLarge(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "base.ext4")
+	if err := os.WriteFile(base, make([]byte, 2*1024*1024), 0o644); err != nil {
+		t.Fatalf("Failed to write base image: %v", err)
+	}
+
+	spec := rootfsSpec{Path: filepath.Join(t.TempDir(), "vm.ext4"), SizeMiB: 1, Label: "rootfs", BaseImage: base}
+	if err := buildRootfs(context.Background(), spec); err == nil || !strings.Contains(err.Error(), "smaller than base image") {
+		t.Errorf("Expected an error for a size below the base image, got %v", err)
+	}
+}
+
+func TestDebugfsOverlayScript(t *testing.T) {
+	script, err := debugfsOverlayScript(t.TempDir(), []rootfsFile{
+		{Destination: "/etc/ssh/sshd_config.d/vm.conf", Content: "PermitRootLogin no\n", Mode: 0o600},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, expected := range []string{
+		"mkdir /etc\nmkdir /etc/ssh\nmkdir /etc/ssh/sshd_config.d\n",
+		"rm /etc/ssh/sshd_config.d/vm.conf\n",
+		"sif /etc/ssh/sshd_config.d/vm.conf mode 0100600\n",
+	} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("Expected %q in script:\n%s", expected, script)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    scratchFormatExt4 = "ext4"
+    scratchFormatXFS  = "xfs"
+    scratchFormatSwap = "swap"
+)
+
+// scratchFormatCommands are the commands formatting a scratch drive, by format.
+// The path of the drive is appended to the arguments.
+var scratchFormatCommands = map[string][]string{
+    scratchFormatExt4: {"mke2fs", "-q", "-F", "-t", "ext4"},
+    scratchFormatXFS:  {"mkfs.xfs", "-q", "-f"},
+    scratchFormatSwap: {"mkswap"},
+}
+
+// scratchDrive is a drive the provider creates for a VM and deletes with it.
+type scratchDrive struct {
+    DriveID string
+    SizeMiB int
+    Format  string
+}
+
+// scratchDrivesFromConfig returns the VM's drives with a size_mib, which the provider creates.
+func scratchDrivesFromConfig(d *schema.ResourceData) []scratchDrive {
+    drives := []scratchDrive{}
+    for _, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        if sizeMiB, _ := drive["size_mib"].(int); sizeMiB > 0 {
+            format, _ := drive["format"].(string)
+            drives = append(drives, scratchDrive{
+                DriveID: drive["drive_id"].(string),
+                SizeMiB: sizeMiB,
+                Format:  format,
+            })
+        }
+    }
+    return drives
+}
+
+// checkScratchDrives verifies at plan time that every drive either names an image with
+// path_on_host or is a scratch drive with size_mib, and that only scratch drives have a format.
+func checkScratchDrives(d *schema.ResourceDiff) error {
+    for i, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok || !d.NewValueKnown(fmt.Sprintf("drives.%d.path_on_host", i)) {
+            continue
+        }
+
+        path, _ := drive["path_on_host"].(string)
+        sizeMiB, _ := drive["size_mib"].(int)
+        format, _ := drive["format"].(string)
+        isRootDevice, _ := drive["is_root_device"].(bool)
+        switch {
+        case path == "" && sizeMiB == 0:
+            return fmt.Errorf("drive %s must set either path_on_host or size_mib", drive["drive_id"])
+        case path != "" && sizeMiB > 0:
+            return fmt.Errorf("drive %s sets both path_on_host and size_mib, scratch drives are created by the provider", drive["drive_id"])
+        case format != "" && sizeMiB == 0:
+            return fmt.Errorf("drive %s sets format without size_mib, only scratch drives are formatted", drive["drive_id"])
+        case sizeMiB > 0 && isRootDevice:
+            return fmt.Errorf("drive %s is a scratch drive and cannot be the root device", drive["drive_id"])
+        case sizeMiB > 0 && strings.ContainsAny(drive["drive_id"].(string), `/\`):
+            return fmt.Errorf("drive %s is a scratch drive, its drive_id names its file and cannot contain path separators", drive["drive_id"])
+        }
+    }
+    return nil
+}
+
+// scratchDriveDir returns the directory holding a VM's scratch drives.
+func scratchDriveDir(stateDir, vmID string) string {
+    return filepath.Join(stateDir, "scratch", vmID)
+}
+
+// scratchDrivePath returns where a VM's scratch drive is created.
+func scratchDrivePath(stateDir, vmID, driveID string) string {
+    return filepath.Join(scratchDriveDir(stateDir, vmID), driveID+".img")
+}
+
+// createScratchDrive creates a sparse file of the drive's size at path, replacing any
+// left over from an earlier attempt, and formats it when the drive has a format.
+func createScratchDrive(ctx context.Context, path string, drive scratchDrive) error {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return fmt.Errorf("failed to create scratch drive directory: %w", err)
+    }
+    if err := trackArtifact(ctx, hostArtifact{Path: path}); err != nil {
+        return err
+    }
+
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+    if err != nil {
+        return fmt.Errorf("failed to create scratch drive %s: %w", drive.DriveID, err)
+    }
+    err = f.Truncate(int64(drive.SizeMiB) * 1024 * 1024)
+    if closeErr := f.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return fmt.Errorf("failed to size scratch drive %s: %w", drive.DriveID, err)
+    }
+
+    if drive.Format == "" {
+        return nil
+    }
+    command, ok := scratchFormatCommands[drive.Format]
+    if !ok {
+        return fmt.Errorf("unsupported format %q for scratch drive %s", drive.Format, drive.DriveID)
+    }
+    args := append(append([]string{}, command[1:]...), path)
+    if output, err := runCommand(ctx, command[0], args...); err != nil {
+        return fmt.Errorf("failed to format scratch drive %s as %s: %w: %s", drive.DriveID, drive.Format, err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// removeScratchDrives deletes all of a VM's scratch drives.
+func removeScratchDrives(stateDir, vmID string) error {
+    // Never remove the directory of every VM's drives
+    if vmID == "" {
+        return nil
+    }
+    if err := os.RemoveAll(scratchDriveDir(stateDir, vmID)); err != nil {
+        return fmt.Errorf("failed to delete scratch drives: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,92 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestCreateScratchDrive(t *testing.T) {
+	var commands []string
+	original := runCommand
+	defer func() { runCommand = original }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		return nil, nil
+	}
+
+	dir := t.TempDir()
+	for _, drive := range []scratchDrive{
+		{DriveID: "var", SizeMiB: 2048, Format: scratchFormatExt4},
+		{DriveID: "swap", SizeMiB: 512, Format: scratchFormatSwap},
+		{DriveID: "scratch", SizeMiB: 64},
+	} {
+		path := scratchDrivePath(dir, "vm-1", drive.DriveID)
+		if err := createScratchDrive(context.Background(), path, drive); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if info, err := os.Stat(path); err != nil || info.Size() != int64(drive.SizeMiB)*1024*1024 {
+			t.Errorf("Expected a %d MiB drive at %s, got %v, %v", drive.SizeMiB, path, info, err)
+		}
+	}
+
+	expected := []string{
+		"mke2fs -q -F -t ext4 " + scratchDrivePath(dir, "vm-1", "var"),
+		"mkswap " + scratchDrivePath(dir, "vm-1", "swap"),
+	}
+	if strings.Join(commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected commands %v", commands)
+	}
+
+	if err := removeScratchDrives(dir, "vm-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scratch", "vm-1")); !os.IsNotExist(err) {
+		t.Errorf("Expected the scratch drives to be removed, got %v", err)
+	}
+}
+
+func TestCheckScratchDrives(t *testing.T) {
+	drive := func(fields map[string]interface{}) map[string]interface{} {
+		fields["drive_id"] = "data"
+		if _, ok := fields["is_root_device"]; !ok {
+			fields["is_root_device"] = false
+		}
+		return fields
+	}
+	cases := map[string]struct {
+		drive    map[string]interface{}
+		expected string
+	}{
+		"image":        {drive(map[string]interface{}{"path_on_host": "/images/data.ext4"}), ""},
+		"scratch":      {drive(map[string]interface{}{"size_mib": 1024, "format": "xfs"}), ""},
+		"neither":      {drive(map[string]interface{}{}), "must set either path_on_host or size_mib"},
+		"both":         {drive(map[string]interface{}{"path_on_host": "/images/data.ext4", "size_mib": 1024}), "sets both"},
+		"format":       {drive(map[string]interface{}{"path_on_host": "/images/data.ext4", "format": "ext4"}), "format without size_mib"},
+		"root scratch": {drive(map[string]interface{}{"size_mib": 1024, "is_root_device": true}), "cannot be the root device"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := terraform.NewResourceConfigRaw(map[string]interface{}{
+				"kernel_image_path": "/images/vmlinux",
+				"drives": []interface{}{
+					map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true},
+					tc.drive,
+				},
+				"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+			})
+			_, err := resourceFirecrackerVM().Diff(context.Background(), nil, config, nil)
+			if tc.expected == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tc.expected != "" && (err == nil || !strings.Contains(err.Error(), tc.expected)) {
+				t.Errorf("Expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
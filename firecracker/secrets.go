@@ -0,0 +1,241 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os/exec"
+    "regexp"
+    "sort"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    secretSourceExec  = "exec"
+    secretSourceVault = "vault"
+    secretSourceSSM   = "ssm"
+)
+
+// secretReferenceRegexp matches a secret://<source>/<key> reference to a secret. The key
+// ends at the first character that cannot be part of a Vault path or SSM parameter name,
+// such as whitespace, a quote or a comma.
+var secretReferenceRegexp = regexp.MustCompile(`secret://([A-Za-z0-9_-]+)/([A-Za-z0-9_./#-]+)`)
+
+// fetchSecretOutput runs a command fetching a secret and returns what it printed on its
+// standard output, which is kept apart from its diagnostics. It is a variable so tests can
+// fake secret stores.
+var fetchSecretOutput = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+    output, err := exec.CommandContext(ctx, name, args...).Output()
+    var exitErr *exec.ExitError
+    if errors.As(err, &exitErr) {
+        return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+    }
+    return output, err
+}
+
+// secretSource is a secret_source block of the provider configuration.
+type secretSource struct {
+    Name    string
+    Type    string
+    Command []string
+}
+
+// secretSources are the provider's secret sources, by name.
+type secretSources map[string]secretSource
+
+// secretSourcesFromConfig returns the secret sources of the provider configuration.
+func secretSourcesFromConfig(raw []interface{}) (secretSources, error) {
+    sources := secretSources{}
+    for _, item := range raw {
+        block := item.(map[string]interface{})
+        source := secretSource{
+            Name: block["name"].(string),
+            Type: block["type"].(string),
+        }
+        for _, arg := range block["command"].([]interface{}) {
+            source.Command = append(source.Command, arg.(string))
+        }
+        if _, ok := sources[source.Name]; ok {
+            return nil, fmt.Errorf("duplicate secret_source name %q", source.Name)
+        }
+        if source.Type == secretSourceExec && len(source.Command) == 0 {
+            return nil, fmt.Errorf("secret_source %q of type %s requires a command", source.Name, secretSourceExec)
+        }
+        if source.Type != secretSourceExec && len(source.Command) > 0 {
+            return nil, fmt.Errorf("secret_source %q of type %s does not take a command", source.Name, source.Type)
+        }
+        sources[source.Name] = source
+    }
+    return sources, nil
+}
+
+// check returns an error when text refers to a secret source that is not configured, so
+// a misspelled reference fails the plan rather than the apply.
+func (s secretSources) check(attr, text string) error {
+    for _, match := range secretReferenceRegexp.FindAllStringSubmatch(text, -1) {
+        if _, ok := s[match[1]]; !ok {
+            names := make([]string, 0, len(s))
+            for name := range s {
+                names = append(names, name)
+            }
+            sort.Strings(names)
+            if len(names) == 0 {
+                return fmt.Errorf("%s refers to %s, but the provider configuration has no secret_source blocks", attr, match[0])
+            }
+            return fmt.Errorf("%s refers to %s, but the provider has no secret_source named %q, only %s", attr, match[0], match[1], strings.Join(names, ", "))
+        }
+    }
+    return nil
+}
+
+// checkSecretReferences fails the plan when the VM's cloud-init data refers to a secret
+// source the provider does not configure. Values not known until apply are checked then.
+func checkSecretReferences(provider *FirecrackerClient, d *schema.ResourceDiff) error {
+    for _, attr := range []string{"cloud_init.0.user_data", "cloud_init.0.network_config"} {
+        if !d.NewValueKnown(attr) {
+            continue
+        }
+        if err := provider.secrets.check("cloud_init "+strings.TrimPrefix(attr, "cloud_init.0."), d.Get(attr).(string)); err != nil {
+            return err
+        }
+    }
+    if d.NewValueKnown("cloud_init.0.meta_data") {
+        for key, value := range d.Get("cloud_init.0.meta_data").(map[string]interface{}) {
+            if err := provider.secrets.check(fmt.Sprintf("cloud_init meta_data %q", key), value.(string)); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// fetch returns the secret key of a source.
+func (s secretSource) fetch(ctx context.Context, key string) (string, error) {
+    var command []string
+    switch s.Type {
+    case secretSourceExec:
+        command = append(append([]string{}, s.Command...), key)
+    case secretSourceVault:
+        // Vault keys name a KV secret and one of its fields, as path#field
+        path, field, ok := strings.Cut(key, "#")
+        if !ok || path == "" || field == "" {
+            return "", fmt.Errorf("secret://%s/%s must name a field of the Vault secret, as <path>#<field>", s.Name, key)
+        }
+        command = []string{"vault", "kv", "get", "-field=" + field, path}
+    case secretSourceSSM:
+        // Hierarchical parameter names always start with a slash
+        if strings.Contains(key, "/") && !strings.HasPrefix(key, "/") {
+            key = "/" + key
+        }
+        command = []string{"aws", "ssm", "get-parameter", "--name", key, "--with-decryption", "--query", "Parameter.Value", "--output", "text"}
+    default:
+        return "", fmt.Errorf("unsupported secret_source type %q", s.Type)
+    }
+
+    output, err := fetchSecretOutput(ctx, command[0], command[1:]...)
+    if err != nil {
+        return "", fmt.Errorf("failed to fetch secret://%s/%s: %w", s.Name, key, err)
+    }
+    return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// secretResolver replaces secret references with the secrets they refer to. Each secret is
+// fetched once, however often it is referred to.
+type secretResolver struct {
+    sources secretSources
+    cache   map[string]string
+}
+
+func (s secretSources) resolver() *secretResolver {
+    return &secretResolver{sources: s, cache: map[string]string{}}
+}
+
+// resolveString replaces the secret references in text.
+func (r *secretResolver) resolveString(ctx context.Context, text string) (string, error) {
+    var resolveErr error
+    resolved := secretReferenceRegexp.ReplaceAllStringFunc(text, func(reference string) string {
+        if resolveErr != nil {
+            return reference
+        }
+        if secret, ok := r.cache[reference]; ok {
+            return secret
+        }
+        match := secretReferenceRegexp.FindStringSubmatch(reference)
+        source, ok := r.sources[match[1]]
+        if !ok {
+            resolveErr = fmt.Errorf("no secret_source named %q is configured for %s", match[1], reference)
+            return reference
+        }
+        secret, err := source.fetch(ctx, match[2])
+        if err != nil {
+            resolveErr = err
+            return reference
+        }
+        r.cache[reference] = secret
+        return secret
+    })
+    return resolved, resolveErr
+}
+
+// resolveValue replaces the secret references in the strings of a JSON-like value, such as
+// the MMDS contents of a VM.
+func (r *secretResolver) resolveValue(ctx context.Context, value interface{}) (interface{}, error) {
+    switch v := value.(type) {
+    case string:
+        return r.resolveString(ctx, v)
+    case map[string]interface{}:
+        resolved := make(map[string]interface{}, len(v))
+        for key, item := range v {
+            item, err := r.resolveValue(ctx, item)
+            if err != nil {
+                return nil, err
+            }
+            resolved[key] = item
+        }
+        return resolved, nil
+    case []interface{}:
+        resolved := make([]interface{}, 0, len(v))
+        for _, item := range v {
+            item, err := r.resolveValue(ctx, item)
+            if err != nil {
+                return nil, err
+            }
+            resolved = append(resolved, item)
+        }
+        return resolved, nil
+    case []string:
+        resolved := make([]string, 0, len(v))
+        for _, item := range v {
+            item, err := r.resolveString(ctx, item)
+            if err != nil {
+                return nil, err
+            }
+            resolved = append(resolved, item)
+        }
+        return resolved, nil
+    default:
+        return value, nil
+    }
+}
+
+// resolveCloudInit replaces the secret references in the user data, metadata and network
+// config of a cloud-init seed image.
+func (r *secretResolver) resolveCloudInit(ctx context.Context, spec cloudInitSpec) (cloudInitSpec, error) {
+    var err error
+    if spec.UserData, err = r.resolveString(ctx, spec.UserData); err != nil {
+        return spec, err
+    }
+    if spec.NetworkConfig, err = r.resolveString(ctx, spec.NetworkConfig); err != nil {
+        return spec, err
+    }
+    metaData := make(map[string]string, len(spec.MetaData))
+    for key, value := range spec.MetaData {
+        if metaData[key], err = r.resolveString(ctx, value); err != nil {
+            return spec, err
+        }
+    }
+    spec.MetaData = metaData
+    return spec, nil
+}
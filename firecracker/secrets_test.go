@@ -0,0 +1,207 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// stubSecretCommands replaces fetchSecretOutput with a fake printing the secrets in store,
+// by key, and records every command run.
+func stubSecretCommands(t *testing.T, store map[string]string) *[]string {
+	t.Helper()
+
+	commands := []string{}
+	original := fetchSecretOutput
+	t.Cleanup(func() { fetchSecretOutput = original })
+	fetchSecretOutput = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		secret, ok := store[args[len(args)-1]]
+		if name == "aws" {
+			secret, ok = store[args[3]]
+		}
+		if !ok {
+			return nil, errors.New("exit status 1: not found")
+		}
+		return []byte(secret + "\n"), nil
+	}
+	return &commands
+}
+
+func TestSecretSourcesFromConfig(t *testing.T) {
+	for name, tc := range map[string]struct {
+		raw []interface{}
+		err string
+	}{
+		"valid": {raw: []interface{}{
+			map[string]interface{}{"name": "pass", "type": "exec", "command": []interface{}{"pass", "show"}},
+			map[string]interface{}{"name": "vault", "type": "vault", "command": []interface{}{}},
+		}},
+		"duplicate": {raw: []interface{}{
+			map[string]interface{}{"name": "vault", "type": "vault", "command": []interface{}{}},
+			map[string]interface{}{"name": "vault", "type": "ssm", "command": []interface{}{}},
+		}, err: "duplicate secret_source name"},
+		"exec without command": {raw: []interface{}{
+			map[string]interface{}{"name": "pass", "type": "exec", "command": []interface{}{}},
+		}, err: "requires a command"},
+		"command for vault": {raw: []interface{}{
+			map[string]interface{}{"name": "vault", "type": "vault", "command": []interface{}{"vault"}},
+		}, err: "does not take a command"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := secretSourcesFromConfig(tc.raw)
+			if tc.err == "" && err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Fatalf("Expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestSecretResolver(t *testing.T) {
+	commands := stubSecretCommands(t, map[string]string{
+		"db/password":     "hunter2",
+		"secret/app":      "s3cr3t",
+		"/prod/api-token": "token",
+	})
+	sources := secretSources{
+		"pass":  {Name: "pass", Type: secretSourceExec, Command: []string{"pass", "show"}},
+		"vault": {Name: "vault", Type: secretSourceVault},
+		"ssm":   {Name: "ssm", Type: secretSourceSSM},
+	}
+
+	resolver := sources.resolver()
+	resolved, err := resolver.resolveValue(context.Background(), map[string]interface{}{
+		"password": "secret://pass/db/password",
+		"nested": map[string]interface{}{
+			"api_key": "key=secret://vault/secret/app#api_key",
+			"tokens":  []interface{}{"secret://ssm/prod/api-token", "secret://pass/db/password"},
+		},
+		"port": 5432,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	value := resolved.(map[string]interface{})
+	nested := value["nested"].(map[string]interface{})
+	if value["password"] != "hunter2" || nested["api_key"] != "key=s3cr3t" || nested["tokens"].([]interface{})[0] != "token" || value["port"] != 5432 {
+		t.Errorf("Unexpected resolved value %v", resolved)
+	}
+
+	// Each secret is fetched once
+	expected := []string{
+		"pass show db/password",
+		"vault kv get -field=api_key secret/app",
+		"aws ssm get-parameter --name /prod/api-token --with-decryption --query Parameter.Value --output text",
+	}
+	got := append([]string{}, *commands...)
+	if len(got) != len(expected) {
+		t.Fatalf("Unexpected commands:\n%s", strings.Join(got, "\n"))
+	}
+	for _, command := range expected {
+		found := false
+		for _, run := range got {
+			found = found || run == command
+		}
+		if !found {
+			t.Errorf("Expected %q to be run, got:\n%s", command, strings.Join(got, "\n"))
+		}
+	}
+
+	// Errors name the reference, never a secret
+	for text, message := range map[string]string{
+		"secret://vault/secret/app":    "must name a field",
+		"secret://pass/missing":        "failed to fetch secret://pass/missing",
+		"secret://unknown/db/password": `no secret_source named "unknown"`,
+	} {
+		if _, err := resolver.resolveString(context.Background(), text); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing %q for %s, got %v", message, text, err)
+		}
+	}
+}
+
+func TestResourceFirecrackerVMCreate_secrets(t *testing.T) {
+	stubSecretCommands(t, map[string]string{"db/password": "hunter2"})
+	ctx := context.Background()
+	stateDir := t.TempDir()
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+	defer resourceFirecrackerTestImageDelete(ctx, image, nil)
+
+	provider := configureFakeProvider(t, stateDir)
+	provider.secrets = secretSources{"pass": {Name: "pass", Type: secretSourceExec, Command: []string{"pass", "show"}}}
+
+	userData := "#cloud-config\nwrite_files:\n  - path: /etc/app/password\n    content: secret://pass/db/password\n"
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": image.Get("path").(string),
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+		},
+		"network_interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+		},
+		"cloud_init": []interface{}{
+			map[string]interface{}{"datasource": "mmds", "user_data": userData},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, d, provider); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	mmds, err := os.ReadFile(filepath.Join(stateDir, "fake", "test", "mmds.json"))
+	if err != nil || !strings.Contains(string(mmds), "content: hunter2") {
+		t.Errorf("Expected the secret to be published through MMDS, got %s, %v", mmds, err)
+	}
+	if got := d.Get("cloud_init.0.user_data").(string); got != userData {
+		t.Errorf("Expected the state to keep the secret reference, got %q", got)
+	}
+}
+
+func TestCheckSecretReferences(t *testing.T) {
+	config := func(userData string) map[string]interface{} {
+		return map[string]interface{}{
+			"kernel_image_path": "/images/vmlinux",
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": "/images/rootfs.ext4", "is_root_device": true},
+			},
+			"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+			"cloud_init": []interface{}{
+				map[string]interface{}{"datasource": "mmds", "user_data": userData},
+			},
+		}
+	}
+	provider := &FirecrackerClient{
+		placementGroups: newPlacementGroups(),
+		sharedDrives:    newSharedDrives(),
+		secrets:         secretSources{"vault": {Name: "vault", Type: secretSourceVault}},
+		hosts:           []*hostEntry{{Name: "a", Client: &FirecrackerClient{}}},
+	}
+
+	for userData, message := range map[string]string{
+		"password: secret://vault/secret/db#password": "",
+		"password: secret://valut/secret/db#password": `no secret_source named "valut", only vault`,
+	} {
+		_, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config(userData)), provider)
+		if message == "" && err != nil {
+			t.Errorf("Expected no error for %q, got %v", userData, err)
+		}
+		if message != "" && (err == nil || !strings.Contains(err.Error(), message)) {
+			t.Errorf("Expected an error containing %q for %q, got %v", message, userData, err)
+		}
+	}
+}
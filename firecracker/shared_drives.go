@@ -0,0 +1,209 @@
+package firecracker
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// attachedImage is a drive image a VM attaches with path_on_host.
+type attachedImage struct {
+    DriveID  string
+    Path     string
+    Writable bool
+}
+
+// sharedDriveUser is a VM attaching a drive image.
+type sharedDriveUser struct {
+    VM       string
+    DriveID  string
+    Writable bool
+    // Planned is set for VMs that are planned but not created yet.
+    Planned bool
+}
+
+// sharedDrives tracks which VMs attach each drive image, so an image attached by more than
+// one VM can be required to be read-only: two guests mounting the same filesystem
+// read-write corrupt it. Resources do not see each other, so the provider records VMs as
+// they are refreshed, planned and created within a single Terraform run. Images are told
+// apart by host, since pooled hosts have their own filesystems.
+type sharedDrives struct {
+    mu      sync.Mutex
+    users map[string]map[string]sharedDriveUser // host and image path -> VM key -> user
+}
+
+func newSharedDrives() *sharedDrives {
+    return &sharedDrives{users: map[string]map[string]sharedDriveUser{}}
+}
+
+// sharedDriveKey identifies an image on a host. The host is empty for the provider's base_url.
+func sharedDriveKey(host, path string) string {
+    return host + "\x00" + path
+}
+
+// plannedSharedDriveKey returns the key of a VM that has no ID yet. Terraform may check a
+// planned VM more than once, so the key is derived from its configuration: its name, or its
+// images when it has none.
+func plannedSharedDriveKey(name string, images []attachedImage) string {
+    if name != "" {
+        return "planned:" + name
+    }
+    paths := make([]string, 0, len(images))
+    for _, image := range images {
+        paths = append(paths, image.Path)
+    }
+    return "planned:" + strings.Join(paths, ",")
+}
+
+// record registers the images attached by a VM, replacing those registered for it before,
+// and returns an error when one of them is also attached by another VM while either
+// attaches it writable. When createdOnly is set, VMs that are only planned are not compared
+// against, since the VM being created is among them under another key.
+func (s *sharedDrives) record(key string, user sharedDriveUser, host string, images []attachedImage, createdOnly bool) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.removeLocked(key)
+    for _, image := range images {
+        imageKey := sharedDriveKey(host, image.Path)
+        if s.users[imageKey] == nil {
+            s.users[imageKey] = map[string]sharedDriveUser{}
+        }
+        entry := user
+        entry.DriveID = image.DriveID
+        // A VM attaching an image twice uses it writable if any of its drives does
+        if previous, ok := s.users[imageKey][key]; ok {
+            entry.Writable = entry.Writable || previous.Writable
+        }
+        entry.Writable = entry.Writable || image.Writable
+        s.users[imageKey][key] = entry
+    }
+
+    for _, image := range images {
+        self := s.users[sharedDriveKey(host, image.Path)][key]
+        others := []sharedDriveUser{}
+        for otherKey, other := range s.users[sharedDriveKey(host, image.Path)] {
+            if otherKey == key || (createdOnly && other.Planned) {
+                continue
+            }
+            others = append(others, other)
+        }
+        if len(others) == 0 {
+            continue
+        }
+        sort.Slice(others, func(i, j int) bool { return others[i].VM < others[j].VM })
+
+        writer := sharedDriveUser{}
+        if self.Writable {
+            writer = self
+        } else {
+            for _, other := range others {
+                if other.Writable {
+                    writer = other
+                    break
+                }
+            }
+        }
+        if writer.VM == "" {
+            continue
+        }
+        return fmt.Errorf("path_on_host %s of drive %s is also attached by %s as drive %s, but %s attaches it as drive %s without is_read_only = true. Guests writing to a shared image corrupt its filesystem: set is_read_only = true on every drive attaching an image used by more than one VM, or give each VM its own image", image.Path, self.DriveID, others[0].VM, others[0].DriveID, writer.VM, writer.DriveID)
+    }
+    return nil
+}
+
+// remove unregisters the images attached by a VM.
+func (s *sharedDrives) remove(key string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.removeLocked(key)
+}
+
+func (s *sharedDrives) removeLocked(key string) {
+    for imageKey, users := range s.users {
+        delete(users, key)
+        if len(users) == 0 {
+            delete(s.users, imageKey)
+        }
+    }
+}
+
+// sharedDriveUserLabel names a VM in shared drive errors.
+func sharedDriveUserLabel(id, name string) string {
+    switch {
+    case id != "":
+        return "VM " + id
+    case name != "":
+        return "new VM " + name
+    default:
+        return "a new VM"
+    }
+}
+
+// attachedImagesFromConfig returns the images attached by the drives of a VM with
+// path_on_host. known reports whether an attribute of the configuration is known; drives
+// with an unknown path_on_host or is_read_only are left out. A jailed VM whose files are
+// not staged attaches images in its own chroot, which no other VM uses.
+func attachedImagesFromConfig(get func(string) interface{}, known func(string) bool, vmID string) []attachedImage {
+    if spec, ok := jailerSpecFromConfig(get("jailer"), vmID); ok && !spec.StageFiles {
+        return nil
+    }
+    // Each VM gets its own copy of the images uploaded to its host
+    if staging, _ := get("staging").([]interface{}); len(staging) > 0 {
+        return nil
+    }
+
+    images := []attachedImage{}
+    for i, raw := range get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok || !known(fmt.Sprintf("drives.%d.path_on_host", i)) || !known(fmt.Sprintf("drives.%d.is_read_only", i)) {
+            continue
+        }
+        path, _ := drive["path_on_host"].(string)
+        if path == "" {
+            continue
+        }
+        readOnly, _ := drive["is_read_only"].(bool)
+        images = append(images, attachedImage{DriveID: drive["drive_id"].(string), Path: path, Writable: !readOnly})
+    }
+    return images
+}
+
+// checkSharedDrives registers the images the planned VM attaches and fails the plan when
+// an image attached by another VM is writable by either of them. New VMs placed on a pooled
+// host, and new VMs without a name attaching the same images, are only checked once they
+// are created, since they cannot be told apart until then.
+func checkSharedDrives(provider *FirecrackerClient, d *schema.ResourceDiff) error {
+    if provider.sharedDrives == nil || !d.NewValueKnown("drives") || !d.NewValueKnown("jailer") {
+        return nil
+    }
+    host, _ := d.Get("host").(string)
+    if len(provider.hosts) > 0 && host == "" {
+        return nil
+    }
+
+    images := attachedImagesFromConfig(d.Get, d.NewValueKnown, d.Id())
+    user := sharedDriveUser{VM: sharedDriveUserLabel(d.Id(), d.Get("name").(string))}
+    key := d.Id()
+    if key == "" {
+        key = plannedSharedDriveKey(d.Get("name").(string), images)
+        user.Planned = true
+    }
+    return provider.sharedDrives.record(key, user, host, images, false)
+}
+
+// recordSharedDrives registers the images a created VM attaches. When created is set, the
+// VM is being created and an error is returned if it would share a writable image with a
+// VM that exists already.
+func recordSharedDrives(provider *FirecrackerClient, d *schema.ResourceData, vmID string, created bool) error {
+    if provider.sharedDrives == nil {
+        return nil
+    }
+    known := func(string) bool { return true }
+    images := attachedImagesFromConfig(d.Get, known, vmID)
+    user := sharedDriveUser{VM: sharedDriveUserLabel(vmID, "")}
+    return provider.sharedDrives.record(vmID, user, d.Get("host").(string), images, created)
+}
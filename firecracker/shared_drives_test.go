@@ -0,0 +1,91 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestCheckSharedDrives(t *testing.T) {
+	// Plan-time checks need the images to exist
+	images := t.TempDir()
+	for _, name := range []string{"vmlinux", "a.ext4", "b.ext4", "shared.ext4"} {
+		if err := os.WriteFile(filepath.Join(images, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := func(name string, readOnly bool) map[string]interface{} {
+		return map[string]interface{}{
+			"name":              name,
+			"kernel_image_path": filepath.Join(images, "vmlinux"),
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": filepath.Join(images, name+".ext4"), "is_root_device": true},
+				map[string]interface{}{"drive_id": "shared", "path_on_host": filepath.Join(images, "shared.ext4"), "is_root_device": false, "is_read_only": readOnly},
+			},
+			"machine_config": []interface{}{map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128}},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		first, second bool
+		expected      string
+	}{
+		"read-only":       {true, true, ""},
+		"second writable": {true, false, "new VM b attaches it as drive shared without is_read_only = true"},
+		"first writable":  {false, true, "new VM a attaches it as drive shared without is_read_only = true"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			provider := &FirecrackerClient{sharedDrives: newSharedDrives()}
+			if _, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config("a", tc.first)), provider); err != nil {
+				t.Fatalf("Expected the first VM to plan, got %v", err)
+			}
+			_, err := resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config("b", tc.second)), provider)
+			if tc.expected == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tc.expected != "" && (err == nil || !strings.Contains(err.Error(), tc.expected)) {
+				t.Errorf("Expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestSharedDrives_record(t *testing.T) {
+	drives := newSharedDrives()
+	writable := []attachedImage{{DriveID: "data", Path: "/images/data.ext4", Writable: true}}
+
+	// The same image on different hosts is not shared
+	if err := drives.record("vm-1", sharedDriveUser{VM: "VM vm-1"}, "edge-1", writable, false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := drives.record("vm-2", sharedDriveUser{VM: "VM vm-2"}, "edge-2", writable, false); err != nil {
+		t.Errorf("Expected images on different hosts not to conflict, got %v", err)
+	}
+
+	// Recording a VM again replaces its images rather than sharing them with itself
+	if err := drives.record("vm-1", sharedDriveUser{VM: "VM vm-1"}, "edge-1", writable, false); err != nil {
+		t.Errorf("Expected a VM not to conflict with itself, got %v", err)
+	}
+
+	// Planned VMs are skipped when checking a VM being created
+	if err := drives.record(plannedSharedDriveKey("", writable), sharedDriveUser{VM: "a new VM", Planned: true}, "edge-3", writable, false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := drives.record("vm-3", sharedDriveUser{VM: "VM vm-3"}, "edge-3", writable, true); err != nil {
+		t.Errorf("Expected planned VMs to be skipped, got %v", err)
+	}
+	if err := drives.record("vm-4", sharedDriveUser{VM: "VM vm-4"}, "edge-3", writable, true); err == nil || !strings.Contains(err.Error(), "also attached by VM vm-3") {
+		t.Errorf("Expected a conflict with vm-3, got %v", err)
+	}
+
+	// Deleted VMs release their images
+	drives.remove("vm-3")
+	if err := drives.record("vm-4", sharedDriveUser{VM: "VM vm-4"}, "edge-3", writable, true); err != nil {
+		t.Errorf("Expected no conflict once vm-3 is removed, got %v", err)
+	}
+}
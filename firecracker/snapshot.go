@@ -0,0 +1,233 @@
+package firecracker
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+
+    sdk "github.com/avkcode/terraform-provider-firecracker/client"
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// goldenSnapshotMu serializes access to the golden snapshot registry within the provider
+// process, so only the first of several VMs sharing a golden snapshot takes it.
+var goldenSnapshotMu sync.Mutex
+
+// goldenSnapshotSpec describes the golden_snapshot block of a VM.
+type goldenSnapshotSpec struct {
+    Name      string
+    Directory string
+}
+
+// snapshotPath returns where Firecracker writes the VM state of the snapshot.
+func (s goldenSnapshotSpec) snapshotPath() string {
+    return filepath.Join(s.Directory, "vmstate")
+}
+
+// memFilePath returns where Firecracker writes the guest memory of the snapshot.
+func (s goldenSnapshotSpec) memFilePath() string {
+    return filepath.Join(s.Directory, "memory")
+}
+
+// goldenSnapshot is the registry record of a golden snapshot, which clones are restored from.
+type goldenSnapshot struct {
+    Name         string    `json:"name"`
+    VMID         string    `json:"vm_id"`
+    Host         string    `json:"host,omitempty"`
+    SnapshotPath string    `json:"snapshot_path"`
+    MemFilePath  string    `json:"mem_file_path"`
+    Created      time.Time `json:"created"`
+}
+
+// goldenSnapshotSpecFromConfig returns the VM's golden_snapshot block, if it has one. The
+// snapshot files default to a directory named after the snapshot under the state directory.
+func goldenSnapshotSpecFromConfig(d *schema.ResourceData, stateDir string) (goldenSnapshotSpec, bool) {
+    raw := d.Get("golden_snapshot").([]interface{})
+    if len(raw) == 0 || raw[0] == nil {
+        return goldenSnapshotSpec{}, false
+    }
+    block := raw[0].(map[string]interface{})
+
+    spec := goldenSnapshotSpec{
+        Name:      block["name"].(string),
+        Directory: block["directory"].(string),
+    }
+    if spec.Directory == "" {
+        spec.Directory = goldenSnapshotDir(stateDir, spec.Name)
+    }
+    return spec, true
+}
+
+// goldenSnapshotDir returns the directory the files of a golden snapshot are written to when
+// no other is configured.
+func goldenSnapshotDir(stateDir, name string) string {
+    return filepath.Join(stateDir, "snapshots", name)
+}
+
+// goldenSnapshotRecordPath returns the file registering a golden snapshot.
+func goldenSnapshotRecordPath(stateDir, name string) string {
+    return filepath.Join(stateDir, "snapshots", name+".json")
+}
+
+// loadGoldenSnapshot reads a golden snapshot record. It returns nil if the snapshot has
+// not been taken.
+func loadGoldenSnapshot(stateDir, name string) (*goldenSnapshot, error) {
+    data, err := os.ReadFile(goldenSnapshotRecordPath(stateDir, name))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read golden snapshot %s: %w", name, err)
+    }
+
+    var record goldenSnapshot
+    if err := json.Unmarshal(data, &record); err != nil {
+        return nil, fmt.Errorf("failed to parse golden snapshot %s: %w", name, err)
+    }
+    return &record, nil
+}
+
+// saveGoldenSnapshot registers a golden snapshot atomically.
+func saveGoldenSnapshot(stateDir string, record *goldenSnapshot) error {
+    path := goldenSnapshotRecordPath(stateDir, record.Name)
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return fmt.Errorf("failed to create snapshot directory: %w", err)
+    }
+
+    data, err := json.MarshalIndent(record, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode golden snapshot %s: %w", record.Name, err)
+    }
+
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0o644); err != nil {
+        return fmt.Errorf("failed to write golden snapshot %s: %w", record.Name, err)
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        return fmt.Errorf("failed to write golden snapshot %s: %w", record.Name, err)
+    }
+    return nil
+}
+
+// CreateSnapshot pauses the VM, writes a full snapshot of it to snapshotPath and memFilePath
+// and resumes it. The VM is resumed even when taking the snapshot fails.
+func (c *FirecrackerClient) CreateSnapshot(ctx context.Context, snapshotPath, memFilePath string) error {
+    api := c.api()
+    if err := api.Pause(ctx); err != nil {
+        return fmt.Errorf("failed to pause VM: %w", err)
+    }
+
+    err := api.CreateSnapshot(ctx, sdk.SnapshotCreateParams{
+        SnapshotType: sdk.SnapshotTypeFull,
+        SnapshotPath: snapshotPath,
+        MemFilePath:  memFilePath,
+    })
+    if err != nil {
+        err = fmt.Errorf("failed to create snapshot: %w", err)
+    }
+
+    if resumeErr := api.Resume(ctx); resumeErr != nil {
+        return errors.Join(err, fmt.Errorf("failed to resume VM: %w", resumeErr))
+    }
+    return err
+}
+
+// LoadSnapshot restores a VM from a full snapshot in a Firecracker process that has not
+// started a VM. The memory file is mapped privately, so VMs restored from the same snapshot
+// share its pages until they write to them. The VM is resumed unless resume is false.
+func (c *FirecrackerClient) LoadSnapshot(ctx context.Context, snapshotPath, memFilePath string, resume bool) error {
+    err := c.api().LoadSnapshot(ctx, sdk.SnapshotLoadParams{
+        SnapshotPath: snapshotPath,
+        MemBackend: &sdk.MemoryBackend{
+            BackendType: "File",
+            BackendPath: memFilePath,
+        },
+        ResumeVM: resume,
+    })
+    if err != nil {
+        return fmt.Errorf("failed to load snapshot: %w", err)
+    }
+    return nil
+}
+
+// ensureGoldenSnapshot takes the golden snapshot from a VM that has just passed its health
+// checks, unless another VM already took it. Failing to take it is reported as a warning,
+// since the VM itself is healthy, and the next VM created with the snapshot tries again.
+func ensureGoldenSnapshot(ctx context.Context, client *FirecrackerClient, stateDir string, spec goldenSnapshotSpec, vmID, host string) diag.Diagnostics {
+    goldenSnapshotMu.Lock()
+    defer goldenSnapshotMu.Unlock()
+
+    existing, err := loadGoldenSnapshot(stateDir, spec.Name)
+    if err != nil {
+        return diag.FromErr(err)
+    }
+    if existing != nil {
+        return nil
+    }
+
+    tflog.Info(ctx, "Taking golden snapshot", map[string]interface{}{
+        "id":       vmID,
+        "snapshot": spec.Name,
+        "path":     spec.Directory,
+    })
+
+    // Firecracker writes the files itself, so only a local directory can be prepared
+    if host == "" {
+        if err := os.MkdirAll(spec.Directory, 0o755); err != nil {
+            return goldenSnapshotWarning(spec, fmt.Errorf("failed to create snapshot directory: %w", err))
+        }
+    }
+    if err := client.CreateSnapshot(ctx, spec.snapshotPath(), spec.memFilePath()); err != nil {
+        return goldenSnapshotWarning(spec, err)
+    }
+
+    err = saveGoldenSnapshot(stateDir, &goldenSnapshot{
+        Name:         spec.Name,
+        VMID:         vmID,
+        Host:         host,
+        SnapshotPath: spec.snapshotPath(),
+        MemFilePath:  spec.memFilePath(),
+        Created:      time.Now().UTC(),
+    })
+    if err != nil {
+        return goldenSnapshotWarning(spec, err)
+    }
+    return nil
+}
+
+// goldenSnapshotWarning reports that a golden snapshot could not be taken.
+func goldenSnapshotWarning(spec goldenSnapshotSpec, err error) diag.Diagnostics {
+    return diag.Diagnostics{{
+        Severity: diag.Warning,
+        Summary:  "Failed to take golden snapshot",
+        Detail:   fmt.Sprintf("The VM was created, but golden snapshot %s could not be taken from it: %s. The next VM created with the snapshot tries again.", spec.Name, err),
+    }}
+}
+
+// setGoldenSnapshot records in the VM's state where its golden snapshot is, once taken.
+func setGoldenSnapshot(d *schema.ResourceData, stateDir string) error {
+    raw := d.Get("golden_snapshot").([]interface{})
+    if len(raw) == 0 || raw[0] == nil {
+        return nil
+    }
+    block := raw[0].(map[string]interface{})
+
+    record, err := loadGoldenSnapshot(stateDir, block["name"].(string))
+    if err != nil {
+        return err
+    }
+    block["snapshot_path"], block["mem_file_path"], block["source_vm_id"] = "", "", ""
+    if record != nil {
+        block["snapshot_path"] = record.SnapshotPath
+        block["mem_file_path"] = record.MemFilePath
+        block["source_vm_id"] = record.VMID
+    }
+    return d.Set("golden_snapshot", []interface{}{block})
+}
@@ -0,0 +1,94 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestCreateSnapshot(t *testing.T) {
+	for name, status := range map[string]int{"success": http.StatusNoContent, "failure": http.StatusBadRequest} {
+		t.Run(name, func(t *testing.T) {
+			var calls []string
+			client := &FirecrackerClient{
+				BaseURL: "http://localhost:8080",
+				HTTPClient: &mockHTTPClient{
+					DoFunc: func(req *http.Request) (*http.Response, error) {
+						body, _ := io.ReadAll(req.Body)
+						calls = append(calls, req.Method+" "+req.URL.Path+" "+string(body))
+						code := http.StatusNoContent
+						if req.URL.Path == "/snapshot/create" {
+							code = status
+						}
+						return &http.Response{StatusCode: code, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+					},
+				},
+			}
+
+			err := client.CreateSnapshot(context.Background(), "/snapshots/web/vmstate", "/snapshots/web/memory")
+			if (err != nil) != (status != http.StatusNoContent) {
+				t.Errorf("Unexpected error %v", err)
+			}
+
+			// The VM is resumed whether or not the snapshot could be taken
+			expected := []string{
+				`PATCH /vm {"state":"Paused"}`,
+				`PUT /snapshot/create {"snapshot_type":"Full","snapshot_path":"/snapshots/web/vmstate","mem_file_path":"/snapshots/web/memory"}`,
+				`PATCH /vm {"state":"Resumed"}`,
+			}
+			if strings.Join(calls, "\n") != strings.Join(expected, "\n") {
+				t.Errorf("Unexpected calls:\n%s", strings.Join(calls, "\n"))
+			}
+		})
+	}
+}
+
+func TestGoldenSnapshot_firstVMTakesIt(t *testing.T) {
+	stateDir := t.TempDir()
+	ctx := context.Background()
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+	defer resourceFirecrackerTestImageDelete(ctx, image, nil)
+
+	vms := []*schema.ResourceData{}
+	for i := 0; i < 2; i++ {
+		d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+			"kernel_image_path": image.Get("path").(string),
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true},
+			},
+			"machine_config": []interface{}{
+				map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+			},
+			"golden_snapshot": []interface{}{
+				map[string]interface{}{"name": "web"},
+			},
+		})
+		if diags := resourceFirecrackerVMCreate(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+			t.Fatalf("Failed to create VM: %v", diags)
+		}
+		vms = append(vms, d)
+	}
+
+	record, err := loadGoldenSnapshot(stateDir, "web")
+	if err != nil || record == nil {
+		t.Fatalf("Expected the golden snapshot to be registered, got %v, %v", record, err)
+	}
+	if record.VMID != vms[0].Id() {
+		t.Errorf("Expected the first VM %s to take the snapshot, got %s", vms[0].Id(), record.VMID)
+	}
+	for _, d := range vms {
+		if d.Get("golden_snapshot.0.source_vm_id").(string) != vms[0].Id() || d.Get("golden_snapshot.0.snapshot_path").(string) != record.SnapshotPath {
+			t.Errorf("Expected VM %s to report the golden snapshot, got %v", d.Id(), d.Get("golden_snapshot"))
+		}
+	}
+}
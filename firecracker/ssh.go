@@ -0,0 +1,200 @@
+package firecracker
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "net"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "golang.org/x/crypto/ssh"
+)
+
+// sshRetryInterval is how long to wait between SSH connection attempts.
+const sshRetryInterval = 2 * time.Second
+
+// sshSpec describes how to reach the SSH server of a guest.
+type sshSpec struct {
+    Host       string
+    Port       int
+    User       string
+    PrivateKey string
+    Timeout    time.Duration
+}
+
+// Address returns the host:port address of the SSH server.
+func (s sshSpec) Address() string {
+    return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// sshSpecFromConfig returns the SSH readiness check described by the VM's wait_for_ssh block, if any.
+// Without an explicit host, the guest address from guest_ip or the CNI attachment is used.
+func sshSpecFromConfig(d *schema.ResourceData) (sshSpec, bool, error) {
+    raw := d.Get("wait_for_ssh").([]interface{})
+    if len(raw) == 0 || raw[0] == nil {
+        return sshSpec{}, false, nil
+    }
+    cfg := raw[0].(map[string]interface{})
+
+    timeout, err := time.ParseDuration(cfg["timeout"].(string))
+    if err != nil {
+        return sshSpec{}, false, fmt.Errorf("invalid wait_for_ssh timeout: %w", err)
+    }
+
+    spec := sshSpec{
+        Host:       cfg["host"].(string),
+        Port:       cfg["port"].(int),
+        User:       cfg["user"].(string),
+        PrivateKey: cfg["private_key"].(string),
+        Timeout:    timeout,
+    }
+
+    if spec.Host == "" {
+        if guestIP, ok := d.GetOk("guest_ip"); ok {
+            spec.Host = hostFromCIDR(guestIP.(string))
+        } else if guestIP, ok := d.GetOk("cni.0.guest_ip"); ok {
+            spec.Host = hostFromCIDR(guestIP.(string))
+        }
+    }
+    if spec.Host == "" {
+        return sshSpec{}, false, fmt.Errorf("wait_for_ssh requires host unless guest_ip or cni provides the guest address")
+    }
+
+    return spec, true, nil
+}
+
+// hostFromCIDR strips the prefix length from an address in CIDR notation.
+func hostFromCIDR(cidr string) string {
+    host, _, _ := strings.Cut(cidr, "/")
+    return host
+}
+
+// waitForSSH polls the guest until its SSH server is ready or the timeout expires.
+// With a private key the server is ready once the user can log in; without one,
+// once it answers with an SSH protocol banner.
+func waitForSSH(operation context.Context, spec sshSpec) error {
+    ctx, cancel := context.WithTimeout(operation, spec.Timeout)
+    defer cancel()
+
+    var signer ssh.Signer
+    if spec.PrivateKey != "" {
+        var err error
+        signer, err = ssh.ParsePrivateKey([]byte(spec.PrivateKey))
+        if err != nil {
+            return fmt.Errorf("failed to parse wait_for_ssh private_key: %w", err)
+        }
+    }
+
+    tflog.Info(ctx, "Waiting for SSH", map[string]interface{}{
+        "address": spec.Address(),
+        "timeout": spec.Timeout.String(),
+    })
+
+    for attempt := 1; ; attempt++ {
+        err := trySSH(ctx, spec, signer)
+        if err == nil {
+            tflog.Info(ctx, "SSH is ready", map[string]interface{}{
+                "address":  spec.Address(),
+                "attempts": attempt,
+            })
+            return nil
+        }
+
+        tflog.Debug(ctx, "SSH not ready yet", map[string]interface{}{
+            "address": spec.Address(),
+            "attempt": attempt,
+            "error":   err.Error(),
+        })
+
+        select {
+        case <-ctx.Done():
+            return waitAbortedError(operation, "SSH on "+spec.Address(), spec.Timeout, err)
+        case <-time.After(sshRetryInterval):
+        }
+    }
+}
+
+// trySSH makes a single attempt to reach the SSH server.
+func trySSH(ctx context.Context, spec sshSpec, signer ssh.Signer) error {
+    dialer := &net.Dialer{Timeout: sshRetryInterval}
+    conn, err := dialer.DialContext(ctx, "tcp", spec.Address())
+    if err != nil {
+        return err
+    }
+    defer conn.Close()
+
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetDeadline(deadline)
+    }
+
+    if signer == nil {
+        banner, err := bufio.NewReader(conn).ReadString('\n')
+        if err != nil {
+            return fmt.Errorf("failed to read SSH banner: %w", err)
+        }
+        if !strings.HasPrefix(banner, "SSH-") {
+            return fmt.Errorf("unexpected SSH banner %q", strings.TrimSpace(banner))
+        }
+        return nil
+    }
+
+    // Freshly booted guests have host keys nobody could have pinned yet
+    config := &ssh.ClientConfig{
+        User:            spec.User,
+        Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+        HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+    }
+    sshConn, chans, reqs, err := ssh.NewClientConn(conn, spec.Address(), config)
+    if err != nil {
+        return err
+    }
+    ssh.NewClient(sshConn, chans, reqs).Close()
+
+    return nil
+}
+
+// setSSHConnection records how to connect to the guest for use in connection blocks.
+func setSSHConnection(d *schema.ResourceData, spec sshSpec) {
+    d.Set("ssh_host", spec.Host)
+    d.Set("ssh_port", spec.Port)
+    d.Set("ssh_user", spec.User)
+}
+
+// setBootTime records how long the guest took to pass its readiness checks after the VM
+// started, for tracking boot performance across images. Without a readiness check there is
+// no point at which the guest is known to be up, so nothing is recorded.
+func setBootTime(ctx context.Context, d *schema.ResourceData, startedAt time.Time) {
+    if len(d.Get("wait_for_ssh").([]interface{})) == 0 && len(d.Get("guest_agent").([]interface{})) == 0 {
+        return
+    }
+    bootTime := time.Since(startedAt)
+    tflog.Info(ctx, "Guest is ready", map[string]interface{}{
+        "id":        d.Id(),
+        "boot_time": bootTime.String(),
+    })
+    d.Set("boot_time_ms", int(bootTime.Milliseconds()))
+}
+
+// validateDuration checks that a string attribute is a valid positive Go duration.
+func validateDuration(v interface{}, k string) ([]string, []error) {
+    duration, err := time.ParseDuration(v.(string))
+    if err != nil {
+        return nil, []error{fmt.Errorf("%q must be a duration such as '90s' or '5m': %v", k, err)}
+    }
+    if duration <= 0 {
+        return nil, []error{fmt.Errorf("%q must be positive", k)}
+    }
+    return nil, nil
+}
+
+// validateAuthorizedKey checks that a value is an SSH public key in authorized_keys format.
+func validateAuthorizedKey(v interface{}, k string) ([]string, []error) {
+    if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(v.(string))); err != nil {
+        return nil, []error{fmt.Errorf("%q must be an SSH public key such as 'ssh-ed25519 AAAA... user@host': %v", k, err)}
+    }
+    return nil, nil
+}
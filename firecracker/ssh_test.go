@@ -0,0 +1,106 @@
+package firecracker
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestWaitForSSH_banner(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	spec := sshSpec{Host: "127.0.0.1", Port: addr.Port, User: "root", Timeout: 5 * time.Second}
+	if err := waitForSSH(context.Background(), spec); err != nil {
+		t.Errorf("Expected SSH to be ready, got %v", err)
+	}
+}
+
+func TestWaitForSSH_timeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	spec := sshSpec{Host: "127.0.0.1", Port: port, User: "root", Timeout: 100 * time.Millisecond}
+	err = waitForSSH(context.Background(), spec)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+}
+
+func TestSetBootTime(t *testing.T) {
+	startedAt := time.Now().Add(-1500 * time.Millisecond)
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"wait_for_ssh": []interface{}{map[string]interface{}{"host": "127.0.0.1"}},
+	})
+	setBootTime(context.Background(), d, startedAt)
+	if bootTime := d.Get("boot_time_ms").(int); bootTime < 1500 || bootTime > 60000 {
+		t.Errorf("Expected a boot time of about 1500ms, got %d", bootTime)
+	}
+
+	// Without a readiness check, the guest is never known to be up
+	d = schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{})
+	setBootTime(context.Background(), d, startedAt)
+	if _, ok := d.GetOk("boot_time_ms"); ok {
+		t.Errorf("Expected no boot time, got %d", d.Get("boot_time_ms"))
+	}
+}
+
+func TestValidateDuration(t *testing.T) {
+	if _, errs := validateDuration("90s", "timeout"); len(errs) != 0 {
+		t.Errorf("Expected 90s to be valid, got %v", errs)
+	}
+	for _, value := range []string{"5", "-1m", "soon"} {
+		if _, errs := validateDuration(value, "timeout"); len(errs) == 0 {
+			t.Errorf("Expected %q to be invalid", value)
+		}
+	}
+}
+
+// testAuthorizedKey returns a freshly generated SSH public key in authorized_keys format.
+func testAuthorizedKey(t *testing.T) string {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatalf("Failed to convert key: %v", err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))) + " test@example"
+}
+
+func TestValidateAuthorizedKey(t *testing.T) {
+	if _, errs := validateAuthorizedKey(testAuthorizedKey(t), "ssh_authorized_keys.0"); len(errs) != 0 {
+		t.Errorf("Expected the key to be valid, got %v", errs)
+	}
+	if _, errs := validateAuthorizedKey("ssh-ed25519 not-a-key", "ssh_authorized_keys.0"); len(errs) == 0 {
+		t.Errorf("Expected a malformed key to be invalid")
+	}
+}
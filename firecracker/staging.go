@@ -0,0 +1,462 @@
+package firecracker
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "fmt"
+    "net/http"
+    "net/url"
+    "os"
+    "path"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    stagingMethodSCP   = "scp"
+    stagingMethodRsync = "rsync"
+    stagingMethodSFTP  = "sftp"
+    stagingMethodHTTP  = "http"
+
+    // stagingKernelKey and stagingInitrdKey are the staged_paths keys of the kernel and initrd.
+    // Drives are keyed by their IDs.
+    stagingKernelKey = "kernel"
+    stagingInitrdKey = "initrd"
+)
+
+// stagingTargetDirRegexp matches the target directories files can be uploaded to. They are
+// passed to commands run by the remote shell, so characters it would interpret are refused.
+var stagingTargetDirRegexp = regexp.MustCompile(`^/[A-Za-z0-9._/-]+$`)
+
+// stagingHTTPClient uploads files with the http method. Like image downloads, uploads of
+// large images have no overall timeout.
+var stagingHTTPClient httpClient = &http.Client{}
+
+// stagingSpec describes how a VM's kernel, initrd and drive images are uploaded to the host
+// running its Firecracker process.
+type stagingSpec struct {
+    Method    string
+    TargetDir string
+    SSHHost   string
+    SSHUser   string
+    SSHPort   int
+    UploadURL string
+}
+
+// stagingSpecFromConfig returns the settings of the VM's staging block, if it has one.
+func stagingSpecFromConfig(d *schema.ResourceData) (stagingSpec, bool) {
+    raw := d.Get("staging").([]interface{})
+    if len(raw) == 0 || raw[0] == nil {
+        return stagingSpec{}, false
+    }
+    block := raw[0].(map[string]interface{})
+    return stagingSpec{
+        Method:    block["method"].(string),
+        TargetDir: block["target_dir"].(string),
+        SSHHost:   block["ssh_host"].(string),
+        SSHUser:   block["ssh_user"].(string),
+        SSHPort:   block["ssh_port"].(int),
+        UploadURL: block["upload_url"].(string),
+    }, true
+}
+
+// vmDir returns the directory on the remote host the files of a VM are uploaded to. Every VM
+// gets its own, so VMs never write to each other's drive images.
+func (s stagingSpec) vmDir(vmID string) string {
+    return path.Join(s.TargetDir, vmID)
+}
+
+// destination returns the SSH destination files are uploaded to for a VM served by client.
+// The host defaults to the one serving the Firecracker API. With the http method, it is the
+// upload URL.
+func (s stagingSpec) destination(client *FirecrackerClient) (string, error) {
+    if s.Method == stagingMethodHTTP {
+        return strings.TrimSuffix(s.UploadURL, "/"), nil
+    }
+    host := s.SSHHost
+    if host == "" {
+        u, err := url.Parse(client.BaseURL)
+        if err != nil || u.Hostname() == "" || isFakeAPIURL(client.BaseURL) {
+            return "", fmt.Errorf("staging ssh_host must be set, the host cannot be derived from the Firecracker API URL %q", client.BaseURL)
+        }
+        host = u.Hostname()
+    }
+    if s.SSHUser != "" {
+        host = s.SSHUser + "@" + host
+    }
+    return host, nil
+}
+
+// sshArgs returns the options of the ssh commands run on the remote host. Batch mode makes
+// ssh fail instead of prompting, which Terraform could not answer.
+func (s stagingSpec) sshArgs() []string {
+    args := []string{"-o", "BatchMode=yes"}
+    if s.SSHPort != 0 {
+        args = append(args, "-p", strconv.Itoa(s.SSHPort))
+    }
+    return args
+}
+
+// stagedPaths returns where the VM's kernel, initrd and drive images configured with
+// path_on_host are uploaded to, by staged_paths key. They are laid out like the files staged
+// in a jailer chroot.
+func stagedPaths(d *schema.ResourceData, spec stagingSpec, vmID string) map[string]string {
+    dir := spec.vmDir(vmID)
+    paths := map[string]string{stagingKernelKey: path.Join(dir, jailerKernelPath)}
+    if d.Get("initrd_path").(string) != "" {
+        paths[stagingInitrdKey] = path.Join(dir, jailerInitrdPath)
+    }
+    for _, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        if source, _ := drive["path_on_host"].(string); source != "" {
+            driveID := drive["drive_id"].(string)
+            paths[driveID] = path.Join(dir, stagedDrivePath(driveID, source))
+        }
+    }
+    return paths
+}
+
+// stagingSources returns the local files uploaded for the VM, by staged_paths key.
+func stagingSources(d *schema.ResourceData) map[string]string {
+    sources := map[string]string{stagingKernelKey: d.Get("kernel_image_path").(string)}
+    if initrdPath := d.Get("initrd_path").(string); initrdPath != "" {
+        sources[stagingInitrdKey] = initrdPath
+    }
+    for _, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        if source, _ := drive["path_on_host"].(string); source != "" {
+            sources[drive["drive_id"].(string)] = source
+        }
+    }
+    return sources
+}
+
+// checkStaging fails the plan when a VM whose files are uploaded to its host also has files
+// the provider creates on the host running Terraform, which are not uploaded.
+func checkStaging(d *schema.ResourceDiff) error {
+    if len(d.Get("staging").([]interface{})) == 0 {
+        return nil
+    }
+    method := d.Get("staging.0.method").(string)
+    uploadURL := d.Get("staging.0.upload_url").(string)
+    if method == stagingMethodHTTP && uploadURL == "" && d.NewValueKnown("staging.0.upload_url") {
+        return fmt.Errorf("staging upload_url must be set with the %q method", stagingMethodHTTP)
+    }
+    if method != stagingMethodHTTP && uploadURL != "" {
+        return fmt.Errorf("staging upload_url is only used by the %q method", stagingMethodHTTP)
+    }
+    if len(d.Get("cloud_init").([]interface{})) > 0 && d.Get("cloud_init.0.datasource").(string) == cloudInitDatasourceNoCloud {
+        return fmt.Errorf("staging does not upload cloud-init seed images, use the %q cloud_init datasource", cloudInitDatasourceMMDS)
+    }
+    for _, raw := range d.Get("drives").([]interface{}) {
+        drive, ok := raw.(map[string]interface{})
+        if !ok {
+            continue
+        }
+        if size, _ := drive["size_mib"].(int); size > 0 {
+            return fmt.Errorf("staging does not upload scratch drives, drive %s must set path_on_host", drive["drive_id"])
+        }
+        if backup, _ := drive["restore_from"].(string); backup != "" {
+            return fmt.Errorf("staging does not upload drives restored from backups, drive %s must not set restore_from", drive["drive_id"])
+        }
+    }
+    return nil
+}
+
+// uploadStagedFiles uploads the VM's kernel, initrd and drive images to the remote host with
+// scp, rsync, sftp or HTTP PUT. Files already on the remote host with the same SHA-256
+// checksum, such as after an interrupted create, are not uploaded again. rsync also keeps
+// sparse images sparse.
+func uploadStagedFiles(ctx context.Context, d *schema.ResourceData, spec stagingSpec, destination, vmID string) error {
+    remote := stagedPaths(d, spec, vmID)
+    sources := stagingSources(d)
+    keys := make([]string, 0, len(sources))
+    for key := range sources {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    if spec.Method == stagingMethodHTTP {
+        for _, key := range keys {
+            if err := uploadStagedFileHTTP(ctx, spec, destination, sources[key], remote[key], vmID); err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+
+    dirs := map[string]bool{}
+    for _, remotePath := range remote {
+        dirs[path.Dir(remotePath)] = true
+    }
+    mkdir := append(spec.sshArgs(), destination, "mkdir", "-p")
+    for dir := range dirs {
+        mkdir = append(mkdir, dir)
+    }
+    sort.Strings(mkdir[len(mkdir)-len(dirs):])
+    if output, err := runCommand(ctx, "ssh", mkdir...); err != nil {
+        return fmt.Errorf("failed to create %s on %s: %w: %s", spec.vmDir(vmID), destination, err, strings.TrimSpace(string(output)))
+    }
+
+    unchanged, err := unchangedStagedFiles(ctx, spec, destination, sources, remote, keys)
+    if err != nil {
+        return err
+    }
+
+    var batch []string
+    for _, key := range keys {
+        source := sources[key]
+        if unchanged[key] {
+            tflog.Info(ctx, "Skipping upload of unchanged file", map[string]interface{}{
+                "id":     vmID,
+                "source": source,
+                "target": remote[key],
+            })
+            continue
+        }
+        target := destination + ":" + remote[key]
+        tflog.Info(ctx, "Uploading file to Firecracker host", map[string]interface{}{
+            "id":     vmID,
+            "source": source,
+            "target": target,
+            "method": spec.Method,
+        })
+
+        var args []string
+        name := spec.Method
+        switch spec.Method {
+        case stagingMethodSFTP:
+            // sftp transfers all files in one session, run below
+            batch = append(batch, fmt.Sprintf("put %s %s", strconv.Quote(source), strconv.Quote(remote[key])))
+            continue
+        case stagingMethodRsync:
+            args = []string{"--sparse", "--times", "--partial", "-e", "ssh " + strings.Join(spec.sshArgs(), " "), source, target}
+        default:
+            name = stagingMethodSCP
+            args = []string{"-q", "-o", "BatchMode=yes"}
+            if spec.SSHPort != 0 {
+                args = append(args, "-P", strconv.Itoa(spec.SSHPort))
+            }
+            args = append(args, source, target)
+        }
+        if output, err := runCommand(ctx, name, args...); err != nil {
+            return fmt.Errorf("failed to upload %s to %s: %w: %s", filepath.Base(source), destination, err, strings.TrimSpace(string(output)))
+        }
+    }
+
+    if len(batch) > 0 {
+        return runSFTPBatch(ctx, spec, destination, batch)
+    }
+    return nil
+}
+
+// unchangedStagedFiles returns the staged_paths keys of the files whose copy on the remote
+// host has the same SHA-256 checksum as the local file. Remote checksums are computed with
+// sha256sum; when it cannot run, every file is uploaded.
+func unchangedStagedFiles(ctx context.Context, spec stagingSpec, destination string, sources, remote map[string]string, keys []string) (map[string]bool, error) {
+    args := append(spec.sshArgs(), destination, "sha256sum", "--")
+    for _, key := range keys {
+        args = append(args, remote[key])
+    }
+    // sha256sum fails when some files are missing, but still prints the others' checksums
+    output, _ := runCommand(ctx, "ssh", args...)
+
+    remoteSums := map[string]string{}
+    for _, line := range strings.Split(string(output), "\n") {
+        fields := strings.Fields(line)
+        if len(fields) == 2 && sha256Regexp.MatchString(fields[0]) {
+            remoteSums[fields[1]] = strings.ToLower(fields[0])
+        }
+    }
+
+    unchanged := map[string]bool{}
+    for _, key := range keys {
+        remoteSum, ok := remoteSums[remote[key]]
+        if !ok {
+            continue
+        }
+        localSum, err := fileSHA256(sources[key])
+        if err != nil {
+            return nil, err
+        }
+        unchanged[key] = localSum == remoteSum
+    }
+    return unchanged, nil
+}
+
+// runSFTPBatch runs sftp commands on the remote host from a batch file. Batch mode makes
+// sftp stop at the first failing command.
+func runSFTPBatch(ctx context.Context, spec stagingSpec, destination string, commands []string) error {
+    batch, err := os.CreateTemp("", "firecracker-sftp-*")
+    if err != nil {
+        return fmt.Errorf("failed to create sftp batch file: %w", err)
+    }
+    defer os.Remove(batch.Name())
+    _, err = batch.WriteString(strings.Join(commands, "\n") + "\n")
+    if closeErr := batch.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return fmt.Errorf("failed to write sftp batch file: %w", err)
+    }
+
+    args := []string{"-q", "-b", batch.Name(), "-o", "BatchMode=yes"}
+    if spec.SSHPort != 0 {
+        args = append(args, "-P", strconv.Itoa(spec.SSHPort))
+    }
+    args = append(args, destination)
+    if output, err := runCommand(ctx, stagingMethodSFTP, args...); err != nil {
+        return fmt.Errorf("failed to upload files to %s: %w: %s", destination, err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// stagedFileURL returns the URL a file uploaded to remotePath is sent to with the http
+// method: its path below the target directory, appended to the upload URL.
+func stagedFileURL(spec stagingSpec, destination, remotePath string) string {
+    return destination + "/" + strings.TrimPrefix(remotePath, strings.TrimSuffix(spec.TargetDir, "/")+"/")
+}
+
+// uploadStagedFileHTTP uploads a file with an HTTP PUT request. When the server reports the
+// SHA-256 digest of the file it already has in the Digest header of a HEAD response, as
+// described in RFC 3230, and it matches the local file, the file is not uploaded again.
+func uploadStagedFileHTTP(ctx context.Context, spec stagingSpec, destination, source, remotePath, vmID string) error {
+    target := stagedFileURL(spec, destination, remotePath)
+
+    localSum, err := fileSHA256(source)
+    if err != nil {
+        return err
+    }
+    if remoteSum, err := httpStagedDigest(ctx, target); err != nil {
+        return err
+    } else if remoteSum == localSum {
+        tflog.Info(ctx, "Skipping upload of unchanged file", map[string]interface{}{
+            "id":     vmID,
+            "source": source,
+            "target": target,
+        })
+        return nil
+    }
+
+    tflog.Info(ctx, "Uploading file to Firecracker host", map[string]interface{}{
+        "id":     vmID,
+        "source": source,
+        "target": target,
+        "method": spec.Method,
+    })
+    file, err := os.Open(source)
+    if err != nil {
+        return fmt.Errorf("failed to open %s: %w", source, err)
+    }
+    defer file.Close()
+    info, err := file.Stat()
+    if err != nil {
+        return fmt.Errorf("failed to open %s: %w", source, err)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, file)
+    if err != nil {
+        return fmt.Errorf("invalid upload URL %q: %w", target, err)
+    }
+    req.ContentLength = info.Size()
+    req.Header.Set("Content-Type", "application/octet-stream")
+    resp, err := stagingHTTPClient.Do(req)
+    if err != nil {
+        return fmt.Errorf("failed to upload %s: %w", filepath.Base(source), err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        return fmt.Errorf("failed to upload %s to %s: %s", filepath.Base(source), target, resp.Status)
+    }
+    return nil
+}
+
+// httpStagedDigest returns the hex-encoded SHA-256 digest the server reports for an uploaded
+// file, or "" when it does not have the file or does not report digests.
+func httpStagedDigest(ctx context.Context, target string) (string, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+    if err != nil {
+        return "", fmt.Errorf("invalid upload URL %q: %w", target, err)
+    }
+    resp, err := stagingHTTPClient.Do(req)
+    if err != nil {
+        return "", fmt.Errorf("failed to check %s: %w", target, err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return "", nil
+    }
+
+    for _, value := range resp.Header.Values("Digest") {
+        for _, digest := range strings.Split(value, ",") {
+            algorithm, encoded, ok := strings.Cut(strings.TrimSpace(digest), "=")
+            if !ok || !strings.EqualFold(algorithm, "sha-256") {
+                continue
+            }
+            sum, err := base64.StdEncoding.DecodeString(encoded)
+            if err == nil && len(sum) == sha256.Size {
+                return hex.EncodeToString(sum), nil
+            }
+        }
+    }
+    return "", nil
+}
+
+// removeStagedFiles removes the files uploaded for a VM from the remote host. With the http
+// method, the VM's directory is removed with a DELETE request.
+func removeStagedFiles(ctx context.Context, spec stagingSpec, destination, vmID string) error {
+    if spec.Method == stagingMethodHTTP {
+        target := stagedFileURL(spec, destination, spec.vmDir(vmID)) + "/"
+        req, err := http.NewRequestWithContext(ctx, http.MethodDelete, target, nil)
+        if err != nil {
+            return fmt.Errorf("invalid upload URL %q: %w", target, err)
+        }
+        resp, err := stagingHTTPClient.Do(req)
+        if err != nil {
+            return fmt.Errorf("failed to remove %s: %w", target, err)
+        }
+        resp.Body.Close()
+        if resp.StatusCode != http.StatusNotFound && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+            return fmt.Errorf("failed to remove %s: %s", target, resp.Status)
+        }
+        return nil
+    }
+
+    args := append(spec.sshArgs(), destination, "rm", "-rf", spec.vmDir(vmID))
+    if output, err := runCommand(ctx, "ssh", args...); err != nil {
+        return fmt.Errorf("failed to remove %s from %s: %w: %s", spec.vmDir(vmID), destination, err, strings.TrimSpace(string(output)))
+    }
+    return nil
+}
+
+// setStagedPaths records in the VM's state where its files were uploaded to.
+func setStagedPaths(d *schema.ResourceData) error {
+    spec, ok := stagingSpecFromConfig(d)
+    if !ok {
+        return nil
+    }
+    block := d.Get("staging").([]interface{})[0].(map[string]interface{})
+    paths := map[string]interface{}{}
+    for key, remotePath := range stagedPaths(d, spec, d.Id()) {
+        paths[key] = remotePath
+    }
+    block["staged_paths"] = paths
+    if err := d.Set("staging", []interface{}{block}); err != nil {
+        return fmt.Errorf("failed to record staged paths: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,287 @@
+package firecracker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// stubStagingCommands replaces runCommand with a fake recording every command run.
+func stubStagingCommands(t *testing.T) *[]string {
+	t.Helper()
+
+	commands := []string{}
+	original := runCommand
+	t.Cleanup(func() { runCommand = original })
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		return nil, nil
+	}
+	return &commands
+}
+
+func stagingTestConfig(kernel, rootfs string, staging map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"kernel_image_path": kernel,
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": rootfs, "is_root_device": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+		},
+		"staging": []interface{}{staging},
+	}
+}
+
+func TestUploadStagedFiles(t *testing.T) {
+	for name, tc := range map[string]struct {
+		staging  map[string]interface{}
+		expected []string
+	}{
+		"rsync": {
+			staging: map[string]interface{}{"method": "rsync", "target_dir": "/srv/staged"},
+			expected: []string{
+				"ssh -o BatchMode=yes edge-1 mkdir -p /srv/staged/vm-1/boot /srv/staged/vm-1/drives",
+				"ssh -o BatchMode=yes edge-1 sha256sum -- /srv/staged/vm-1/boot/kernel /srv/staged/vm-1/drives/rootfs.ext4",
+				"rsync --sparse --times --partial -e ssh -o BatchMode=yes /images/vmlinux edge-1:/srv/staged/vm-1/boot/kernel",
+				"rsync --sparse --times --partial -e ssh -o BatchMode=yes /images/rootfs.ext4 edge-1:/srv/staged/vm-1/drives/rootfs.ext4",
+			},
+		},
+		"scp": {
+			staging: map[string]interface{}{"method": "scp", "target_dir": "/srv/staged", "ssh_user": "deploy", "ssh_port": 2222},
+			expected: []string{
+				"ssh -o BatchMode=yes -p 2222 deploy@edge-1 mkdir -p /srv/staged/vm-1/boot /srv/staged/vm-1/drives",
+				"ssh -o BatchMode=yes -p 2222 deploy@edge-1 sha256sum -- /srv/staged/vm-1/boot/kernel /srv/staged/vm-1/drives/rootfs.ext4",
+				"scp -q -o BatchMode=yes -P 2222 /images/vmlinux deploy@edge-1:/srv/staged/vm-1/boot/kernel",
+				"scp -q -o BatchMode=yes -P 2222 /images/rootfs.ext4 deploy@edge-1:/srv/staged/vm-1/drives/rootfs.ext4",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			commands := stubStagingCommands(t)
+			d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, stagingTestConfig("/images/vmlinux", "/images/rootfs.ext4", tc.staging))
+			spec, _ := stagingSpecFromConfig(d)
+
+			destination, err := spec.destination(&FirecrackerClient{BaseURL: "http://edge-1:8080"})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if err := uploadStagedFiles(context.Background(), d, spec, destination, "vm-1"); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := strings.Join(*commands, "\n"); got != strings.Join(tc.expected, "\n") {
+				t.Errorf("Unexpected commands:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestUploadStagedFiles_unchanged(t *testing.T) {
+	images := t.TempDir()
+	kernel, rootfs := filepath.Join(images, "vmlinux"), filepath.Join(images, "rootfs.ext4")
+	for path, content := range map[string]string{kernel: "kernel", rootfs: "rootfs"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kernelSum, err := fileSHA256(kernel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands := []string{}
+	original := runCommand
+	t.Cleanup(func() { runCommand = original })
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		if strings.Contains(strings.Join(args, " "), "sha256sum") {
+			// The kernel was uploaded before, the rootfs differs
+			return []byte(kernelSum + "  /srv/staged/vm-1/boot/kernel\n" + strings.Repeat("0", 64) + "  /srv/staged/vm-1/drives/rootfs.ext4\n"), nil
+		}
+		return nil, nil
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, stagingTestConfig(kernel, rootfs, map[string]interface{}{"method": "sftp", "target_dir": "/srv/staged"}))
+	spec, _ := stagingSpecFromConfig(d)
+	if err := uploadStagedFiles(context.Background(), d, spec, "edge-1", "vm-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(commands) != 3 || !strings.HasPrefix(commands[2], "sftp -q -b ") || !strings.HasSuffix(commands[2], " -o BatchMode=yes edge-1") {
+		t.Fatalf("Expected a single sftp session, got %v", commands)
+	}
+	for _, command := range commands {
+		if strings.Contains(command, "vmlinux") {
+			t.Errorf("Expected the unchanged kernel not to be uploaded, got %s", command)
+		}
+	}
+}
+
+func TestUploadStagedFiles_http(t *testing.T) {
+	images := t.TempDir()
+	kernel, rootfs := filepath.Join(images, "vmlinux"), filepath.Join(images, "rootfs.ext4")
+	for path, content := range map[string]string{kernel: "kernel", rootfs: "rootfs"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kernelDigest := sha256.Sum256([]byte("kernel"))
+
+	requests := []string{}
+	uploaded := map[string]string{}
+	original := stagingHTTPClient
+	t.Cleanup(func() { stagingHTTPClient = original })
+	stagingHTTPClient = &mockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.Method+" "+req.URL.String())
+		resp := &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+		switch req.Method {
+		case http.MethodHead:
+			resp.StatusCode = http.StatusNotFound
+			if strings.HasSuffix(req.URL.Path, "/boot/kernel") {
+				resp.StatusCode = http.StatusOK
+				resp.Header.Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(kernelDigest[:]))
+			}
+		case http.MethodPut:
+			body, _ := io.ReadAll(req.Body)
+			uploaded[req.URL.Path] = string(body)
+		}
+		return resp, nil
+	}}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, stagingTestConfig(kernel, rootfs, map[string]interface{}{
+		"method":     "http",
+		"target_dir": "/srv/staged",
+		"upload_url": "https://edge-1/staged/",
+	}))
+	spec, _ := stagingSpecFromConfig(d)
+	destination, err := spec.destination(&FirecrackerClient{BaseURL: "fake://test"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := uploadStagedFiles(context.Background(), d, spec, destination, "vm-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(uploaded) != 1 || uploaded["/staged/vm-1/drives/rootfs.ext4"] != "rootfs" {
+		t.Errorf("Expected only the changed rootfs to be uploaded, got %v", uploaded)
+	}
+
+	if err := removeStagedFiles(context.Background(), spec, destination, "vm-1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if last := requests[len(requests)-1]; last != "DELETE https://edge-1/staged/vm-1/" {
+		t.Errorf("Expected the VM's directory to be deleted, got %s", last)
+	}
+}
+
+func TestStagingDestination_fakeAPI(t *testing.T) {
+	spec := stagingSpec{TargetDir: "/srv/staged"}
+	if _, err := spec.destination(&FirecrackerClient{BaseURL: "fake://test"}); err == nil || !strings.Contains(err.Error(), "ssh_host must be set") {
+		t.Errorf("Expected an error asking for ssh_host, got %v", err)
+	}
+}
+
+func TestResourceFirecrackerVM_staging(t *testing.T) {
+	commands := stubStagingCommands(t)
+	ctx := context.Background()
+	stateDir := t.TempDir()
+
+	// Staged files are only checked on the host running Terraform
+	images := t.TempDir()
+	kernel, rootfs := filepath.Join(images, "vmlinux"), filepath.Join(images, "rootfs.ext4")
+	for _, path := range []string{kernel, rootfs} {
+		if err := os.WriteFile(path, []byte("image"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, stagingTestConfig(kernel, rootfs, map[string]interface{}{
+		"target_dir": "/srv/staged",
+		"ssh_host":   "edge-1",
+	}))
+	if diags := resourceFirecrackerVMCreate(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+
+	remote := "/srv/staged/" + d.Id()
+	bootSource, err := os.ReadFile(filepath.Join(stateDir, "fake", "test", "boot-source.json"))
+	if err != nil || !strings.Contains(string(bootSource), remote+"/boot/kernel") {
+		t.Errorf("Expected the VM to boot the uploaded kernel, got %s, %v", bootSource, err)
+	}
+	if got := d.Get("staging.0.staged_paths.rootfs"); got != remote+"/drives/rootfs.ext4" {
+		t.Errorf("Unexpected staged path of rootfs %v", got)
+	}
+	if got := d.Get("drives.0.path_on_host"); got != rootfs {
+		t.Errorf("Expected the state to keep the local path, got %v", got)
+	}
+
+	if diags := resourceFirecrackerVMDelete(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to delete VM: %v", diags)
+	}
+	if last := (*commands)[len(*commands)-1]; last != "ssh -o BatchMode=yes edge-1 rm -rf "+remote {
+		t.Errorf("Expected the uploaded files to be removed, got %s", last)
+	}
+}
+
+func TestCheckStaging(t *testing.T) {
+	staging := map[string]interface{}{"target_dir": "/srv/staged", "ssh_host": "edge-1"}
+	for name, tc := range map[string]struct {
+		change func(config map[string]interface{})
+		err    string
+	}{
+		"scratch drive": {
+			change: func(config map[string]interface{}) {
+				config["drives"] = append(config["drives"].([]interface{}), map[string]interface{}{"drive_id": "scratch", "size_mib": 64, "is_root_device": false})
+			},
+			err: "does not upload scratch drives",
+		},
+		"nocloud seed": {
+			change: func(config map[string]interface{}) {
+				config["cloud_init"] = []interface{}{map[string]interface{}{"user_data": "#cloud-config"}}
+			},
+			err: "does not upload cloud-init seed images",
+		},
+		"http without upload_url": {
+			change: func(config map[string]interface{}) {
+				config["staging"] = []interface{}{map[string]interface{}{"method": "http", "target_dir": "/srv/staged"}}
+			},
+			err: "upload_url must be set",
+		},
+		"upload_url without http": {
+			change: func(config map[string]interface{}) {
+				config["staging"] = []interface{}{map[string]interface{}{"target_dir": "/srv/staged", "upload_url": "https://edge-1/staged"}}
+			},
+			err: "only used by the \"http\" method",
+		},
+		"jailer": {
+			change: func(config map[string]interface{}) {
+				config["jailer"] = []interface{}{map[string]interface{}{"id": "vm-1"}}
+			},
+			err: "conflicts with jailer",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := stagingTestConfig("/images/vmlinux", "/images/rootfs.ext4", staging)
+			tc.change(config)
+			provider := &FirecrackerClient{hosts: []*hostEntry{{Name: "edge-1", Client: &FirecrackerClient{}}}}
+			var err error
+			if diags := resourceFirecrackerVM().Validate(terraform.NewResourceConfigRaw(config)); diags.HasError() {
+				err = errors.New(diags[0].Detail)
+			} else {
+				_, err = resourceFirecrackerVM().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), provider)
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("Expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package firecracker
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    defaultSystemdUnitDir = "/etc/systemd/system"
+    defaultSystemdBinDir  = "/usr/bin"
+
+    // Restart settings of the unit, as systemd names them.
+    systemdRestartNo        = "no"
+    systemdRestartOnFailure = "on-failure"
+    systemdRestartAlways    = "always"
+
+    // Restart policies of a VM, which set the restart setting of its unit.
+    restartPolicyNever     = "never"
+    restartPolicyOnFailure = "on-failure"
+    restartPolicyAlways    = "always"
+
+    // jailerConfigFile is where the configuration a jailed VM boots from is put in the chroot.
+    jailerConfigFile = "/vm_config.json"
+)
+
+// bootConfigExcludedKeys are the keys of a rendered VM payload that Firecracker's
+// --config-file does not take. MMDS contents are left out as well, since they may hold
+// resolved secrets.
+var bootConfigExcludedKeys = []string{"vm-id", "sensitive_boot_args", "chroot", "staging_dir", "host", "mmds", configFileKey}
+
+// systemdUnitSpec describes the systemd unit starting a VM's Firecracker process at boot,
+// from the settings of the VM's systemd_unit block.
+type systemdUnitSpec struct {
+    UnitDir           string
+    FirecrackerBinary string
+    JailerBinary      string
+    APISocket         string
+    Restart           string
+    UID               int
+    GID               int
+
+    // CgroupArgs are the --cgroup arguments the jailer is started with, pinning the VM.
+    CgroupArgs []string
+}
+
+// systemdUnitSpecFromConfig returns the settings of the VM's systemd_unit block, if it has one.
+func systemdUnitSpecFromConfig(d *schema.ResourceData) (systemdUnitSpec, bool) {
+    blocks := d.Get("systemd_unit").([]interface{})
+    if len(blocks) == 0 || blocks[0] == nil {
+        return systemdUnitSpec{}, false
+    }
+    block := blocks[0].(map[string]interface{})
+
+    spec := systemdUnitSpec{
+        UnitDir:           block["unit_dir"].(string),
+        FirecrackerBinary: block["firecracker_binary"].(string),
+        JailerBinary:      block["jailer_binary"].(string),
+        APISocket:         block["api_socket"].(string),
+        Restart:           block["restart"].(string),
+        UID:               block["uid"].(int),
+        GID:               block["gid"].(int),
+    }
+    switch policy := d.Get("restart_policy").(string); policy {
+    case restartPolicyNever:
+        spec.Restart = systemdRestartNo
+    case restartPolicyOnFailure, restartPolicyAlways:
+        spec.Restart = policy
+    }
+    if spec.FirecrackerBinary == "" {
+        spec.FirecrackerBinary = filepath.Join(defaultSystemdBinDir, defaultJailerExecFile)
+        if jailer, ok := jailerSpecFromConfig(d.Get("jailer"), d.Id()); ok {
+            spec.FirecrackerBinary = filepath.Join(defaultSystemdBinDir, filepath.Base(jailer.ExecFile))
+        }
+    }
+    return spec, true
+}
+
+// systemdUnitName returns the name of the unit starting the VM.
+func systemdUnitName(vmID string) string {
+    return "firecracker-" + vmID + ".service"
+}
+
+// systemdBootConfigPath returns where the configuration the VM boots from is written on the
+// host: in the chroot of a jailed VM, next to the provider's other files otherwise.
+func systemdBootConfigPath(d *schema.ResourceData, stateDir, vmID string) string {
+    if jailer, ok := jailerSpecFromConfig(d.Get("jailer"), vmID); ok {
+        return jailer.hostPath(jailerConfigFile)
+    }
+    return filepath.Join(stateDir, "units", vmID+".json")
+}
+
+// bootConfig returns the configuration file Firecracker boots the VM from, the payload the
+// provider configures it with through the API without what the API does not take.
+func bootConfig(payload map[string]interface{}) map[string]interface{} {
+    config := make(map[string]interface{}, len(payload))
+    for key, value := range payload {
+        config[key] = value
+    }
+    for _, key := range bootConfigExcludedKeys {
+        delete(config, key)
+    }
+    return config
+}
+
+// systemdDeviceUnit returns the name of the device unit systemd has for a network interface,
+// which the VM's unit waits for.
+func systemdDeviceUnit(ifname string) string {
+    var escaped strings.Builder
+    for i, c := range []byte(ifname) {
+        switch {
+        case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.' && i > 0:
+            escaped.WriteByte(c)
+        default:
+            fmt.Fprintf(&escaped, `\x%02x`, c)
+        }
+    }
+    return "sys-subsystem-net-devices-" + escaped.String() + ".device"
+}
+
+// systemdQuote quotes an argument of a command line in a unit, so systemd neither splits it
+// nor expands specifiers or variables in it.
+func systemdQuote(arg string) string {
+    arg = strings.NewReplacer("%", "%%", "$", "$$").Replace(arg)
+    if arg == "" || strings.ContainsAny(arg, " \t\"'\\;") {
+        return strconv.Quote(arg)
+    }
+    return arg
+}
+
+// systemdCommand joins a command line for a unit.
+func systemdCommand(args ...string) string {
+    quoted := make([]string, len(args))
+    for i, arg := range args {
+        quoted[i] = systemdQuote(arg)
+    }
+    return strings.Join(quoted, " ")
+}
+
+// renderSystemdUnit returns the unit starting the VM's Firecracker process, or the jailer,
+// booting the VM from the configuration at configPath once its TAP devices exist.
+func renderSystemdUnit(d *schema.ResourceData, spec systemdUnitSpec, vmID, configPath string) string {
+    // systemd only has device units for the links of its own network namespace
+    netns := d.Get("netns").(string)
+    devices := []string{}
+    for _, raw := range vmNetworkInterfaces(d) {
+        if iface, ok := raw.(map[string]interface{}); ok && netns == "" {
+            devices = append(devices, systemdDeviceUnit(iface["host_dev_name"].(string)))
+        }
+    }
+
+    var execStartPre, execStart, networkNamespace string
+    if jailer, ok := jailerSpecFromConfig(d.Get("jailer"), vmID); ok {
+        // The jailer creates the device nodes and API socket of the chroot anew
+        execStartPre = systemdCommand("/bin/rm", "-rf", jailer.hostPath("dev"), jailer.hostPath("run"))
+        args := []string{
+            spec.JailerBinary,
+            "--id", jailer.ID,
+            "--exec-file", spec.FirecrackerBinary,
+            "--uid", strconv.Itoa(spec.UID),
+            "--gid", strconv.Itoa(spec.GID),
+            "--chroot-base-dir", jailer.ChrootBaseDir,
+            "--cgroup-version", strconv.Itoa(jailer.CgroupVersion),
+            "--parent-cgroup", jailer.ParentCgroup,
+        }
+        args = append(args, spec.CgroupArgs...)
+        if netns != "" {
+            args = append(args, "--netns", netnsPath(netns))
+        }
+        args = append(args,
+            "--",
+            "--api-sock", jailerAPISocket,
+            "--config-file", jailerConfigFile,
+        )
+        execStart = systemdCommand(args...)
+    } else {
+        // Firecracker does not start while the socket of a previous process is left over
+        execStartPre = systemdCommand("/bin/rm", "-f", spec.APISocket)
+        execStart = systemdCommand(spec.FirecrackerBinary, "--api-sock", spec.APISocket, "--config-file", configPath)
+        if netns != "" {
+            networkNamespace = netnsPath(netns)
+        }
+    }
+
+    var unit strings.Builder
+    fmt.Fprintf(&unit, "# Managed by the Firecracker Terraform provider. Changes are overwritten.\n")
+    fmt.Fprintf(&unit, "[Unit]\n")
+    fmt.Fprintf(&unit, "Description=Firecracker VM %s\n", vmID)
+    fmt.Fprintf(&unit, "After=%s\n", strings.Join(append([]string{"network.target"}, devices...), " "))
+    if len(devices) > 0 {
+        fmt.Fprintf(&unit, "Requires=%s\n", strings.Join(devices, " "))
+    }
+    fmt.Fprintf(&unit, "\n[Service]\n")
+    fmt.Fprintf(&unit, "ExecStartPre=%s\n", execStartPre)
+    fmt.Fprintf(&unit, "ExecStart=%s\n", execStart)
+    if networkNamespace != "" {
+        fmt.Fprintf(&unit, "NetworkNamespacePath=%s\n", networkNamespace)
+    }
+    fmt.Fprintf(&unit, "Restart=%s\n", spec.Restart)
+    fmt.Fprintf(&unit, "\n[Install]\n")
+    fmt.Fprintf(&unit, "WantedBy=multi-user.target\n")
+    return unit.String()
+}
+
+// installVMUnit writes the configuration the VM boots from and the unit starting it, and
+// enables the unit so systemd starts the VM at boot. The unit is not started: the VM is
+// already running in the Firecracker process the provider configured.
+func installVMUnit(ctx context.Context, d *schema.ResourceData, stateDir, vmID string) error {
+    spec, ok := systemdUnitSpecFromConfig(d)
+    if !ok {
+        return nil
+    }
+    if _, ok := cniSpecFromConfig(d); ok {
+        return fmt.Errorf("VMs attached to a CNI network cannot be started by a systemd unit")
+    }
+    cgroupArgs, err := cpuPinningJailerArgs(d)
+    if err != nil {
+        return err
+    }
+    spec.CgroupArgs = cgroupArgs
+
+    payload, err := renderVMPayload(d, vmID, existingVMPayloadExtras(d, stateDir, vmID))
+    if err != nil {
+        return err
+    }
+    config, err := json.MarshalIndent(bootConfig(payload), "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode the configuration of VM %s: %w", vmID, err)
+    }
+    configPath := systemdBootConfigPath(d, stateDir, vmID)
+    if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+        return fmt.Errorf("failed to create directory for the configuration of VM %s: %w", vmID, err)
+    }
+    // The kernel command line may hold sensitive_boot_args
+    if err := os.WriteFile(configPath, config, 0o600); err != nil {
+        return fmt.Errorf("failed to write the configuration of VM %s: %w", vmID, err)
+    }
+
+    unitPath := filepath.Join(spec.UnitDir, systemdUnitName(vmID))
+    if err := os.WriteFile(unitPath, []byte(renderSystemdUnit(d, spec, vmID, configPath)), 0o644); err != nil {
+        return fmt.Errorf("failed to write systemd unit of VM %s: %w", vmID, err)
+    }
+
+    tflog.Info(ctx, "Enabling systemd unit of Firecracker VM", map[string]interface{}{
+        "id":   vmID,
+        "unit": unitPath,
+    })
+    if out, err := runCommand(ctx, "systemctl", "daemon-reload"); err != nil {
+        return fmt.Errorf("systemctl daemon-reload failed: %w: %s", err, strings.TrimSpace(string(out)))
+    }
+    if out, err := runCommand(ctx, "systemctl", "enable", systemdUnitName(vmID)); err != nil {
+        return fmt.Errorf("failed to enable %s: %w: %s", systemdUnitName(vmID), err, strings.TrimSpace(string(out)))
+    }
+
+    return d.Set("systemd_unit", []interface{}{map[string]interface{}{
+        "unit_dir":           spec.UnitDir,
+        "firecracker_binary": d.Get("systemd_unit.0.firecracker_binary"),
+        "jailer_binary":      spec.JailerBinary,
+        "api_socket":         spec.APISocket,
+        "restart":            d.Get("systemd_unit.0.restart"),
+        "uid":                spec.UID,
+        "gid":                spec.GID,
+        "unit_name":          systemdUnitName(vmID),
+        "unit_path":          unitPath,
+        "config_path":        configPath,
+    }})
+}
+
+// vmSupervisedByUnit reports whether the VM's restart_policy has its systemd unit start it
+// again after its Firecracker process exits.
+func vmSupervisedByUnit(d *schema.ResourceData) bool {
+    if len(d.Get("systemd_unit").([]interface{})) == 0 {
+        return false
+    }
+    policy := d.Get("restart_policy").(string)
+    return policy == restartPolicyOnFailure || policy == restartPolicyAlways
+}
+
+// startVMUnit starts the VM's unit, booting the VM from its configuration in a Firecracker
+// process that systemd supervises.
+func startVMUnit(ctx context.Context, vmID string) error {
+    if out, err := runCommand(ctx, "systemctl", "start", systemdUnitName(vmID)); err != nil {
+        return fmt.Errorf("failed to start %s: %w: %s", systemdUnitName(vmID), err, strings.TrimSpace(string(out)))
+    }
+    return nil
+}
+
+// disableVMUnit keeps systemd from starting the VM again, before it is shut down.
+func disableVMUnit(ctx context.Context, d *schema.ResourceData, vmID string) error {
+    if _, ok := systemdUnitSpecFromConfig(d); !ok {
+        return nil
+    }
+    if out, err := runCommand(ctx, "systemctl", "disable", systemdUnitName(vmID)); err != nil {
+        return fmt.Errorf("failed to disable %s: %w: %s", systemdUnitName(vmID), err, strings.TrimSpace(string(out)))
+    }
+    return nil
+}
+
+// removeVMUnit stops the VM's unit, in case systemd started the VM, and removes the unit and
+// the configuration the VM booted from.
+func removeVMUnit(ctx context.Context, d *schema.ResourceData, stateDir, vmID string) error {
+    spec, ok := systemdUnitSpecFromConfig(d)
+    if !ok {
+        return nil
+    }
+    if out, err := runCommand(ctx, "systemctl", "stop", systemdUnitName(vmID)); err != nil {
+        return fmt.Errorf("failed to stop %s: %w: %s", systemdUnitName(vmID), err, strings.TrimSpace(string(out)))
+    }
+    for _, path := range []string{filepath.Join(spec.UnitDir, systemdUnitName(vmID)), systemdBootConfigPath(d, stateDir, vmID)} {
+        if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+            return fmt.Errorf("failed to remove %s: %w", path, err)
+        }
+    }
+    if out, err := runCommand(ctx, "systemctl", "daemon-reload"); err != nil {
+        return fmt.Errorf("systemctl daemon-reload failed: %w: %s", err, strings.TrimSpace(string(out)))
+    }
+    return nil
+}
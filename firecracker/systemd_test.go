@@ -0,0 +1,208 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestResourceFirecrackerVM_systemdUnit(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	unitDir := t.TempDir()
+
+	commands := []string{}
+	originalRun := runCommand
+	t.Cleanup(func() { runCommand = originalRun })
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		if name == "systemctl" {
+			commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		}
+		return nil, nil
+	}
+
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path":   image,
+		"sensitive_boot_args": "password=secret",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+		},
+		"network_interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "fc-tap0"},
+		},
+		"hostname": "web",
+		"systemd_unit": []interface{}{
+			map[string]interface{}{"api_socket": "/run/firecracker/web.socket", "unit_dir": unitDir, "restart": "always"},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+	vmID := d.Id()
+	unitName := "firecracker-" + vmID + ".service"
+
+	unit, err := os.ReadFile(filepath.Join(unitDir, unitName))
+	if err != nil {
+		t.Fatalf("Failed to read unit: %v", err)
+	}
+	configPath := d.Get("systemd_unit.0.config_path").(string)
+	for _, line := range []string{
+		`Requires=sys-subsystem-net-devices-fc\x2dtap0.device`,
+		"ExecStartPre=/bin/rm -f /run/firecracker/web.socket",
+		"ExecStart=/usr/bin/firecracker --api-sock /run/firecracker/web.socket --config-file " + configPath,
+		"Restart=always",
+	} {
+		if !strings.Contains(string(unit), line+"\n") {
+			t.Errorf("Expected unit to contain %q, got:\n%s", line, unit)
+		}
+	}
+	if d.Get("systemd_unit.0.unit_name") != unitName {
+		t.Errorf("Expected unit_name %s, got %v", unitName, d.Get("systemd_unit.0.unit_name"))
+	}
+
+	// The VM boots from the configuration it was created with, without its MMDS contents
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read configuration: %v", err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("Failed to parse configuration: %v", err)
+	}
+	if config["mmds-config"] == nil {
+		t.Errorf("Expected the MMDS configuration, got %v", config)
+	}
+	for _, key := range []string{"vm-id", "sensitive_boot_args", "mmds"} {
+		if _, ok := config[key]; ok {
+			t.Errorf("Expected no %s in the configuration, got %v", key, config[key])
+		}
+	}
+	if !strings.Contains(config["boot-source"].(map[string]interface{})["boot_args"].(string), "password=secret") {
+		t.Errorf("Expected the sensitive boot arguments on the kernel command line, got %v", config["boot-source"])
+	}
+	if info, err := os.Stat(configPath); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the configuration to be readable by its owner only, got %v, %v", info, err)
+	}
+
+	if diags := resourceFirecrackerVMDelete(ctx, d, configureFakeProvider(t, stateDir)); diags.HasError() {
+		t.Fatalf("Failed to delete VM: %v", diags)
+	}
+	for _, path := range []string{filepath.Join(unitDir, unitName), configPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", path, err)
+		}
+	}
+	expected := []string{
+		"systemctl daemon-reload",
+		"systemctl enable " + unitName,
+		"systemctl disable " + unitName,
+		"systemctl stop " + unitName,
+		"systemctl daemon-reload",
+	}
+	if strings.Join(commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected commands:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(commands, "\n"))
+	}
+}
+
+func TestRenderSystemdUnit_jailer(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": "/images/vmlinux",
+		"jailer": []interface{}{
+			map[string]interface{}{"id": "web-1", "exec_file": "firecracker-v1.10", "cgroup_version": 2},
+		},
+		"systemd_unit": []interface{}{
+			map[string]interface{}{"uid": 1000, "gid": 1000},
+		},
+	})
+	d.SetId("web-1")
+	spec, _ := systemdUnitSpecFromConfig(d)
+	unit := renderSystemdUnit(d, spec, "web-1", systemdBootConfigPath(d, t.TempDir(), "web-1"))
+
+	for _, line := range []string{
+		"ExecStartPre=/bin/rm -rf /srv/jailer/firecracker-v1.10/web-1/root/dev /srv/jailer/firecracker-v1.10/web-1/root/run",
+		"ExecStart=/usr/bin/jailer --id web-1 --exec-file /usr/bin/firecracker-v1.10 --uid 1000 --gid 1000 --chroot-base-dir /srv/jailer --cgroup-version 2 --parent-cgroup firecracker-v1.10 -- --api-sock /run/firecracker.socket --config-file /vm_config.json",
+		"Restart=on-failure",
+	} {
+		if !strings.Contains(unit, line+"\n") {
+			t.Errorf("Expected unit to contain %q, got:\n%s", line, unit)
+		}
+	}
+	if strings.Contains(unit, "Requires=") {
+		t.Errorf("Expected no device dependencies without network interfaces, got:\n%s", unit)
+	}
+}
+
+func TestRenderSystemdUnit_netns(t *testing.T) {
+	config := map[string]interface{}{
+		"kernel_image_path": "/images/vmlinux",
+		"netns":             "tenant-a",
+		"network_interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+		},
+		"systemd_unit": []interface{}{
+			map[string]interface{}{"uid": 1000, "gid": 1000},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config)
+	spec, _ := systemdUnitSpecFromConfig(d)
+	unit := renderSystemdUnit(d, spec, "web-1", "/var/lib/firecracker/web-1.json")
+	if !strings.Contains(unit, "NetworkNamespacePath=/run/netns/tenant-a\n") {
+		t.Errorf("Expected Firecracker to be started in the namespace, got:\n%s", unit)
+	}
+	if strings.Contains(unit, "Requires=") {
+		t.Errorf("Expected no device dependencies on devices systemd cannot see, got:\n%s", unit)
+	}
+
+	// The jailer enters the namespace itself
+	config["jailer"] = []interface{}{map[string]interface{}{"id": "web-1", "cgroup_version": 2}}
+	d = schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config)
+	unit = renderSystemdUnit(d, spec, "web-1", systemdBootConfigPath(d, t.TempDir(), "web-1"))
+	if !strings.Contains(unit, "--parent-cgroup firecracker --netns /run/netns/tenant-a -- ") {
+		t.Errorf("Expected the jailer to be given the namespace, got:\n%s", unit)
+	}
+	if strings.Contains(unit, "NetworkNamespacePath=") {
+		t.Errorf("Expected the jailer rather than systemd to enter the namespace, got:\n%s", unit)
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	for arg, expected := range map[string]string{
+		"/run/fc.socket":        "/run/fc.socket",
+		"/srv/my vms/fc.socket": `"/srv/my vms/fc.socket"`,
+		"/run/100%.socket":      "/run/100%%.socket",
+		"":                      `""`,
+	} {
+		if got := systemdQuote(arg); got != expected {
+			t.Errorf("systemdQuote(%q) = %s, expected %s", arg, got, expected)
+		}
+	}
+}
+
+func TestRenderSystemdUnit_restartPolicy(t *testing.T) {
+	for policy, restart := range map[string]string{"": "on-failure", "never": "no", "on-failure": "on-failure", "always": "always"} {
+		config := map[string]interface{}{
+			"kernel_image_path": "/images/vmlinux",
+			"systemd_unit": []interface{}{
+				map[string]interface{}{"api_socket": "/run/firecracker.socket"},
+			},
+		}
+		if policy != "" {
+			config["restart_policy"] = policy
+		}
+		d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config)
+		spec, _ := systemdUnitSpecFromConfig(d)
+		unit := renderSystemdUnit(d, spec, "web-1", "/var/lib/firecracker/web-1.json")
+		if !strings.Contains(unit, "Restart="+restart+"\n") {
+			t.Errorf("Expected restart_policy %q to restart with %s, got:\n%s", policy, restart, unit)
+		}
+	}
+}
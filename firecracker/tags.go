@@ -0,0 +1,75 @@
+package firecracker
+
+import (
+    "context"
+    "encoding/json"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// vmTags returns the tags of a VM.
+func vmTags(d *schema.ResourceData) map[string]string {
+    raw := d.Get("tags").(map[string]interface{})
+    if len(raw) == 0 {
+        return nil
+    }
+    tags := make(map[string]string, len(raw))
+    for key, value := range raw {
+        tags[key] = value.(string)
+    }
+    return tags
+}
+
+// hasTags reports whether tags holds every one of want.
+func hasTags(tags map[string]string, want map[string]interface{}) bool {
+    for key, value := range want {
+        if got, ok := tags[key]; !ok || got != value.(string) {
+            return false
+        }
+    }
+    return true
+}
+
+// tagsJSON returns the tags of a VM as a JSON object, as hooks are given them.
+func tagsJSON(d *schema.ResourceData) string {
+    tags := vmTags(d)
+    if tags == nil {
+        tags = map[string]string{}
+    }
+    data, _ := json.Marshal(tags)
+    return string(data)
+}
+
+// withVMLogFields adds the name and tags of a VM to the provider's logs about it, so VMs can
+// be told apart by more than their IDs.
+func withVMLogFields(f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+    return func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+        if name := d.Get("name").(string); name != "" {
+            ctx = tflog.SetField(ctx, "vm_name", name)
+        }
+        if tags := vmTags(d); tags != nil {
+            ctx = tflog.SetField(ctx, "vm_tags", tags)
+        }
+        return f(ctx, d, m)
+    }
+}
+
+// updatePublishedTags publishes the changed name and tags of a running VM through MMDS, along
+// with the other settings published under mmdsProviderKey.
+func updatePublishedTags(ctx context.Context, d *schema.ResourceData, client *FirecrackerClient) error {
+    if !d.HasChange("tags") && !d.HasChange("publish_tags") {
+        return nil
+    }
+    wasPublished, published := d.GetChange("publish_tags")
+    if !wasPublished.(bool) && !published.(bool) {
+        return nil
+    }
+
+    tflog.Debug(ctx, "Publishing VM tags through MMDS", map[string]interface{}{
+        "id":        d.Id(),
+        "published": published,
+    })
+    return client.SetMMDSKey(ctx, mmdsProviderKey, guestSettingsFromConfig(d))
+}
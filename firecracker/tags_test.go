@@ -0,0 +1,100 @@
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestResourceFirecrackerVM_publishTags(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := func(tags map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"name":              "web",
+			"tags":              tags,
+			"publish_tags":      true,
+			"kernel_image_path": image,
+			"drives": []interface{}{
+				map[string]interface{}{"drive_id": "rootfs", "path_on_host": image, "is_root_device": true},
+			},
+			"machine_config": []interface{}{
+				map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+			},
+			"network_interfaces": []interface{}{
+				map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0"},
+			},
+		}
+	}
+	published := func() map[string]interface{} {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(stateDir, "fake", "test", "mmds.json"))
+		if err != nil {
+			t.Fatalf("Expected MMDS to be populated: %v", err)
+		}
+		var contents map[string]interface{}
+		if err := json.Unmarshal(data, &contents); err != nil {
+			t.Fatalf("Failed to parse MMDS contents: %v", err)
+		}
+		settings, _ := contents[mmdsProviderKey].(map[string]interface{})
+		return settings
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, config(map[string]interface{}{"role": "frontend", "env": "staging"}))
+	if diags := resourceFirecrackerVMCreate(ctx, d, client); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+	if settings := published(); settings["name"] != "web" || !reflect.DeepEqual(settings["tags"], map[string]interface{}{"role": "frontend", "env": "staging"}) {
+		t.Errorf("Expected the name and tags to be published, got %v", settings)
+	}
+	record, err := lookupVMRecord(client.vmRegistryDir(), d.Id())
+	if err != nil || record == nil || !reflect.DeepEqual(record.Tags, map[string]string{"role": "frontend", "env": "staging"}) {
+		t.Fatalf("Expected the tags to be registered, got %+v (%v)", record, err)
+	}
+
+	// Changed tags are published to the running VM, without replacing it
+	r := resourceFirecrackerVM()
+	diff, err := r.Diff(ctx, d.State(), terraform.NewResourceConfigRaw(config(map[string]interface{}{"role": "backend"})), client)
+	if err != nil {
+		t.Fatalf("Failed to plan the change: %v", err)
+	}
+	if diff.RequiresNew() {
+		t.Fatalf("Expected changing tags not to replace the VM")
+	}
+	state, diags := r.Apply(ctx, d.State(), diff, client)
+	if diags.HasError() {
+		t.Fatalf("Failed to apply the change: %v", diags)
+	}
+	if state.ID != d.Id() {
+		t.Errorf("Expected VM %s to be kept, got %s", d.Id(), state.ID)
+	}
+	if settings := published(); !reflect.DeepEqual(settings["tags"], map[string]interface{}{"role": "backend"}) {
+		t.Errorf("Expected the new tags to be published, got %v", settings["tags"])
+	}
+	record, err = lookupVMRecord(client.vmRegistryDir(), d.Id())
+	if err != nil || record == nil || !reflect.DeepEqual(record.Tags, map[string]string{"role": "backend"}) {
+		t.Errorf("Expected the new tags to be registered, got %+v (%v)", record, err)
+	}
+}
+
+func TestGuestSettingsFromConfig_tags(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"name": "web",
+		"tags": map[string]interface{}{"role": "frontend"},
+	})
+	if settings := guestSettingsFromConfig(d); len(settings) != 0 {
+		t.Errorf("Expected tags not to be published unless publish_tags is set, got %v", settings)
+	}
+}
@@ -0,0 +1,194 @@
+package firecracker
+
+import (
+    "context"
+    "sync"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// bootThrottleProgressInterval is how often a queued boot logs that it is still waiting.
+const bootThrottleProgressInterval = 10 * time.Second
+
+// bootThrottle is a token bucket with a capacity of one that spaces VM boots evenly,
+// so a large apply doesn't start hundreds of microVMs at the same instant.
+// A nil *bootThrottle does not limit anything.
+type bootThrottle struct {
+    mu       sync.Mutex
+    interval time.Duration
+    next     time.Time
+    queued   int
+}
+
+// newBootThrottle returns a throttle allowing perMinute boots per minute,
+// or nil if perMinute is not positive.
+func newBootThrottle(perMinute int) *bootThrottle {
+    if perMinute <= 0 {
+        return nil
+    }
+
+    return &bootThrottle{
+        interval: time.Minute / time.Duration(perMinute),
+    }
+}
+
+// Wait blocks until the caller may boot a VM or the context is done.
+func (t *bootThrottle) Wait(ctx context.Context) error {
+    if t == nil {
+        return nil
+    }
+
+    // Reserve the next free slot
+    t.mu.Lock()
+    now := time.Now()
+    slot := t.next
+    if slot.Before(now) {
+        slot = now
+    }
+    t.next = slot.Add(t.interval)
+    t.queued++
+    position := t.queued
+    t.mu.Unlock()
+
+    defer func() {
+        t.mu.Lock()
+        t.queued--
+        t.mu.Unlock()
+    }()
+
+    delay := slot.Sub(now)
+    if delay <= 0 {
+        return nil
+    }
+
+    tflog.Info(ctx, "VM boot queued by boot throttle", map[string]interface{}{
+        "wait":           delay.String(),
+        "queue_position": position,
+    })
+
+    timer := time.NewTimer(delay)
+    defer timer.Stop()
+    ticker := time.NewTicker(bootThrottleProgressInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-timer.C:
+            return nil
+        case <-ticker.C:
+            tflog.Info(ctx, "Still waiting for a boot slot", map[string]interface{}{
+                "remaining": time.Until(slot).Round(time.Second).String(),
+            })
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+    }
+}
+
+// requestLimiter serializes the requests sent to each Firecracker API endpoint, whose
+// process handles one request at a time, so concurrent operations on a VM cannot interleave,
+// and optionally limits how many requests are in flight across all endpoints.
+type requestLimiter struct {
+    mu        sync.Mutex
+    endpoints map[string]chan struct{}
+    // slots has a buffer of max_concurrent_requests, or is nil without a limit.
+    slots chan struct{}
+}
+
+// newRequestLimiter returns a limiter allowing maxConcurrent requests in flight, or any
+// number if maxConcurrent is not positive.
+func newRequestLimiter(maxConcurrent int) *requestLimiter {
+    limiter := &requestLimiter{endpoints: map[string]chan struct{}{}}
+    if maxConcurrent > 0 {
+        limiter.slots = make(chan struct{}, maxConcurrent)
+    }
+    return limiter
+}
+
+// heldEndpointKey is the context key of the endpoint whose lock a batch of requests holds.
+type heldEndpointKey struct{}
+
+// acquire blocks until a request to endpoint may be sent or the context is done. The
+// returned function must be called once the response is read. Requests of a batch holding
+// the endpoint, as returned by hold, only wait for a slot.
+func (l *requestLimiter) acquire(ctx context.Context, endpoint string) (func(), error) {
+    if l == nil {
+        return func() {}, nil
+    }
+
+    if held, _ := ctx.Value(heldEndpointKey{}).(string); held == endpoint {
+        return l.takeSlot(ctx, func() {})
+    }
+    unlock, err := l.lockEndpoint(ctx, endpoint)
+    if err != nil {
+        return nil, err
+    }
+    // The endpoint is locked before taking a slot, so requests queued behind another
+    // request to their endpoint do not hold slots other endpoints could use
+    return l.takeSlot(ctx, unlock)
+}
+
+// hold locks endpoint for a batch of requests that may be sent to it concurrently, such as
+// the devices of a VM being configured, so no other request interleaves with them. The
+// requests must be sent with the returned context, and the returned function called once
+// they are all done.
+func (l *requestLimiter) hold(ctx context.Context, endpoint string) (context.Context, func(), error) {
+    if l == nil {
+        return ctx, func() {}, nil
+    }
+    if held, _ := ctx.Value(heldEndpointKey{}).(string); held == endpoint {
+        return ctx, func() {}, nil
+    }
+    unlock, err := l.lockEndpoint(ctx, endpoint)
+    if err != nil {
+        return nil, nil, err
+    }
+    return context.WithValue(ctx, heldEndpointKey{}, endpoint), unlock, nil
+}
+
+// lockEndpoint blocks until no other request is sent to endpoint or the context is done.
+// The returned function unlocks it.
+func (l *requestLimiter) lockEndpoint(ctx context.Context, endpoint string) (func(), error) {
+    l.mu.Lock()
+    lock, ok := l.endpoints[endpoint]
+    if !ok {
+        lock = make(chan struct{}, 1)
+        l.endpoints[endpoint] = lock
+    }
+    l.mu.Unlock()
+
+    select {
+    case lock <- struct{}{}:
+    default:
+        tflog.Debug(ctx, "Request queued behind another request to the Firecracker API", map[string]interface{}{
+            "endpoint": endpoint,
+        })
+        select {
+        case lock <- struct{}{}:
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+    }
+    return func() { <-lock }, nil
+}
+
+// takeSlot blocks until fewer than max_concurrent_requests requests are in flight or the
+// context is done, calling unlock if it is. The returned function frees the slot and then
+// calls unlock.
+func (l *requestLimiter) takeSlot(ctx context.Context, unlock func()) (func(), error) {
+    if l.slots != nil {
+        select {
+        case l.slots <- struct{}{}:
+        case <-ctx.Done():
+            unlock()
+            return nil, ctx.Err()
+        }
+    }
+    return func() {
+        if l.slots != nil {
+            <-l.slots
+        }
+        unlock()
+    }, nil
+}
@@ -0,0 +1,231 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/avkcode/terraform-provider-firecracker/client"
+)
+
+func TestBootThrottle_disabled(t *testing.T) {
+	throttle := newBootThrottle(0)
+	if throttle != nil {
+		t.Fatalf("Expected no throttle when the limit is 0, got %v", throttle)
+	}
+	if err := throttle.Wait(context.Background()); err != nil {
+		t.Errorf("Expected nil throttle to never block, got %v", err)
+	}
+}
+
+func TestBootThrottle_spacing(t *testing.T) {
+	// 600 boots per minute is one boot every 100ms
+	throttle := newBootThrottle(600)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := throttle.Wait(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected three boots to take at least 200ms, took %s", elapsed)
+	}
+}
+
+func TestBootThrottle_cancel(t *testing.T) {
+	throttle := newBootThrottle(1)
+	if err := throttle.Wait(context.Background()); err != nil {
+		t.Fatalf("Expected the first boot to proceed, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := throttle.Wait(ctx); err == nil {
+		t.Errorf("Expected the second boot to be cancelled with the context")
+	}
+}
+
+// inFlightRecorder is an HTTP client recording the most requests in flight at once, to each
+// endpoint and in total.
+type inFlightRecorder struct {
+	mu          sync.Mutex
+	inFlight    map[string]int
+	total       int
+	maxEndpoint int
+	maxTotal    int
+}
+
+func (r *inFlightRecorder) Do(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.inFlight[req.URL.Host]++
+	r.total++
+	if r.inFlight[req.URL.Host] > r.maxEndpoint {
+		r.maxEndpoint = r.inFlight[req.URL.Host]
+	}
+	if r.total > r.maxTotal {
+		r.maxTotal = r.total
+	}
+	r.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	r.mu.Lock()
+	r.inFlight[req.URL.Host]--
+	r.total--
+	r.mu.Unlock()
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"vcpu_count":1}`))}, nil
+}
+
+func TestRequestLimiter(t *testing.T) {
+	for name, tc := range map[string]struct {
+		maxConcurrent int
+		maxTotal      int
+	}{
+		"serialized per endpoint": {0, 3},
+		"limited":                 {2, 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			recorder := &inFlightRecorder{inFlight: map[string]int{}}
+			limiter := newRequestLimiter(tc.maxConcurrent)
+
+			var wg sync.WaitGroup
+			for _, host := range []string{"vm-1", "vm-2", "vm-3"} {
+				client := &FirecrackerClient{BaseURL: "http://" + host, HTTPClient: recorder, requests: limiter}
+				for i := 0; i < 3; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := client.getComponent(context.Background(), client.BaseURL+"/machine-config"); err != nil {
+							t.Errorf("Expected no error, got %v", err)
+						}
+					}()
+				}
+			}
+			wg.Wait()
+
+			if recorder.maxEndpoint != 1 {
+				t.Errorf("Expected one request at a time to each endpoint, got %d", recorder.maxEndpoint)
+			}
+			if recorder.maxTotal > tc.maxTotal {
+				t.Errorf("Expected at most %d requests in flight, got %d", tc.maxTotal, recorder.maxTotal)
+			}
+		})
+	}
+}
+
+func TestRequestLimiter_cancelled(t *testing.T) {
+	limiter := newRequestLimiter(0)
+	release, err := limiter.acquire(context.Background(), "vm-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(ctx, "vm-1"); err == nil {
+		t.Error("Expected waiting for a busy endpoint to end with the context")
+	}
+}
+
+func TestRequestLimiter_hold(t *testing.T) {
+	limiter := newRequestLimiter(0)
+	ctx, release, err := limiter.hold(context.Background(), "vm-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer release()
+
+	// Requests of the batch share the endpoint
+	for i := 0; i < 2; i++ {
+		done, err := limiter.acquire(ctx, "vm-1")
+		if err != nil {
+			t.Fatalf("Expected requests of the batch to be sent, got %v", err)
+		}
+		defer done()
+	}
+
+	// Other requests wait for the batch
+	other, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(other, "vm-1"); err == nil {
+		t.Error("Expected requests outside the batch to wait for it")
+	}
+}
+
+func TestPutComponents(t *testing.T) {
+	recorder := &inFlightRecorder{inFlight: map[string]int{}}
+	client := &FirecrackerClient{BaseURL: "http://vm-1", HTTPClient: recorder, requests: newRequestLimiter(0)}
+
+	var components []apiComponent
+	for i := 0; i < 6; i++ {
+		id := fmt.Sprintf("data%d", i)
+		components = append(components, apiComponent{
+			Name: "drive " + id,
+			Path: "/drives/" + id,
+			Put: func(ctx context.Context, api *sdk.Client) error {
+				return api.PutDrive(ctx, sdk.Drive{DriveID: id, PathOnHost: "/images/" + id + ".ext4"})
+			},
+		})
+	}
+	if err := client.putComponents(context.Background(), components); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if recorder.maxEndpoint < 2 || recorder.maxEndpoint > componentParallelism {
+		t.Errorf("Expected between 2 and %d requests in flight, got %d", componentParallelism, recorder.maxEndpoint)
+	}
+}
+
+func TestCreateVM_holdsAPI(t *testing.T) {
+	kernelPath := filepath.Join(t.TempDir(), "vmlinux")
+	if err := os.WriteFile(kernelPath, []byte("kernel"), 0o644); err != nil {
+		t.Fatalf("failed to write kernel image: %v", err)
+	}
+
+	limiter := newRequestLimiter(0)
+	// otherRequest reports whether a request of another resource could be sent
+	otherRequest := func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		done, err := limiter.acquire(ctx, "localhost:8080")
+		if err != nil {
+			return false
+		}
+		done()
+		return true
+	}
+	client := &FirecrackerClient{
+		BaseURL:  "http://localhost:8080",
+		requests: limiter,
+		HTTPClient: &mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Path == "/boot-source" && otherRequest() {
+					t.Error("Expected other requests to wait while the VM is configured")
+				}
+				return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(strings.NewReader(""))}, nil
+			},
+		},
+	}
+
+	config := map[string]interface{}{
+		"boot-source": map[string]interface{}{"kernel_image_path": kernelPath},
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "data", "path_on_host": "/data.ext4", "is_root_device": false, "is_read_only": false},
+		},
+	}
+	if err := client.CreateVM(context.Background(), config); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !otherRequest() {
+		t.Error("Expected the API to be released once the VM is created")
+	}
+}
@@ -0,0 +1,48 @@
+package firecracker
+
+import (
+    "context"
+    "errors"
+    "fmt"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// withOperationTimeout wraps a create, update or delete function whose operation is limited
+// by the timeout with the given key, such as schema.TimeoutCreate. Terraform cancels the
+// context of an operation once its timeout expires, which aborts API requests, host
+// commands and waits alike; errors caused by that say which timeout expired, and how to
+// raise it, rather than only that a context deadline was exceeded.
+func withOperationTimeout(key string, f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+    return func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+        diags := f(ctx, d, m)
+        if !diags.HasError() || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+            return diags
+        }
+        for i := range diags {
+            if diags[i].Severity != diag.Error {
+                continue
+            }
+            diags[i].Detail = joinDetail(diags[i].Detail, fmt.Sprintf("The %s timeout of %s expired. Raise it with %s in the resource's timeouts block.", key, d.Timeout(key), key))
+        }
+        return diags
+    }
+}
+
+// joinDetail appends a sentence to a diagnostic's detail.
+func joinDetail(detail, sentence string) string {
+    if detail == "" {
+        return sentence
+    }
+    return detail + "\n\n" + sentence
+}
+
+// waitAbortedError returns the error of a wait whose context is done: the wait's own
+// timeout expired, unless the context of the operation it is part of was done first.
+func waitAbortedError(operation context.Context, what string, timeout fmt.Stringer, lastErr error) error {
+    if err := operation.Err(); err != nil {
+        return fmt.Errorf("gave up waiting for %s: %w: %v", what, err, lastErr)
+    }
+    return fmt.Errorf("timed out after %s waiting for %s: %w", timeout, what, lastErr)
+}
@@ -0,0 +1,53 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestWithOperationTimeout(t *testing.T) {
+	create := withOperationTimeout(schema.TimeoutCreate, func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		<-ctx.Done()
+		return diag.FromErr(ctx.Err())
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	diags := create(ctx, resourceFirecrackerVM().TestResourceData(), nil)
+	if len(diags) != 1 || !strings.Contains(diags[0].Detail, "The create timeout of ") {
+		t.Errorf("Expected the error to name the create timeout, got %v", diags)
+	}
+
+	// Errors unrelated to the timeout are left alone
+	failing := withOperationTimeout(schema.TimeoutCreate, func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		return diag.Errorf("boot failed")
+	})
+	if diags := failing(context.Background(), resourceFirecrackerVM().TestResourceData(), nil); len(diags) != 1 || diags[0].Detail != "" {
+		t.Errorf("Expected the error to be unchanged, got %v", diags)
+	}
+}
+
+func TestWaitForSSH_operationTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	// The operation's timeout expires long before the wait's own
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	spec := sshSpec{Host: "127.0.0.1", Port: port, User: "root", Timeout: 5 * time.Minute}
+	err = waitForSSH(ctx, spec)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "gave up waiting for SSH") {
+		t.Errorf("Expected the wait to end with the operation, got %v", err)
+	}
+}
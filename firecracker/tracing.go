@@ -0,0 +1,400 @@
+package firecracker
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/propagation"
+    "go.opentelemetry.io/otel/sdk/resource"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+    "go.opentelemetry.io/otel/trace"
+)
+
+const (
+    tracerName         = "github.com/avkcode/terraform-provider-firecracker"
+    tracingServiceName = "terraform-provider-firecracker"
+
+    // Exporters OTEL_TRACES_EXPORTER selects, as the OpenTelemetry specification names them.
+    tracesExporterOTLP    = "otlp"
+    tracesExporterConsole = "console"
+    tracesExporterNone    = "none"
+
+    defaultOTLPTracesEndpoint = "http://localhost:4318/v1/traces"
+)
+
+var (
+    tracingOnce     sync.Once
+    tracingErr      error
+    tracingProvider *sdktrace.TracerProvider
+)
+
+// tracer returns the tracer spans of the provider are started with. Spans are dropped until
+// setupTracing installs an exporter.
+func tracer() trace.Tracer {
+    return otel.Tracer(tracerName)
+}
+
+// setupTracing installs the exporter OTEL_TRACES_EXPORTER selects, once per provider process.
+// Spans go to the OTLP endpoint when one is set in the environment without an exporter, and
+// nowhere otherwise.
+func setupTracing(ctx context.Context) error {
+    tracingOnce.Do(func() {
+        exporter, err := tracesExporterFromEnv()
+        if err != nil || exporter == nil {
+            tracingErr = err
+            return
+        }
+        res, err := resource.Merge(resource.NewSchemaless(attribute.String("service.name", tracingServiceName)), resource.Environment())
+        if err != nil {
+            res = resource.NewSchemaless(attribute.String("service.name", tracingServiceName))
+        }
+        tracingProvider = sdktrace.NewTracerProvider(
+            sdktrace.WithBatcher(exporter),
+            sdktrace.WithResource(res),
+        )
+        otel.SetTracerProvider(tracingProvider)
+        tflog.Info(ctx, "Exporting OpenTelemetry traces", map[string]interface{}{
+            "exporter": os.Getenv("OTEL_TRACES_EXPORTER"),
+        })
+    })
+    return tracingErr
+}
+
+// tracesExporterFromEnv returns the exporter the OpenTelemetry environment variables select,
+// or nil when traces are not exported.
+func tracesExporterFromEnv() (sdktrace.SpanExporter, error) {
+    name := strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER")))
+    if name == "" && (os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "") {
+        name = tracesExporterOTLP
+    }
+
+    switch name {
+    case "", tracesExporterNone:
+        return nil, nil
+    case tracesExporterConsole:
+        return &jsonSpanExporter{write: writeSpanLines(os.Stderr)}, nil
+    case tracesExporterOTLP:
+        if protocol := otlpEnv("PROTOCOL"); protocol != "" && protocol != "http/json" {
+            return nil, fmt.Errorf("OTLP protocol %q is not supported, only http/json is", protocol)
+        }
+        endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+        if endpoint == "" {
+            endpoint = defaultOTLPTracesEndpoint
+            if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+                endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+            }
+        }
+        headers, err := parseOTLPHeaders(otlpEnv("HEADERS"))
+        if err != nil {
+            return nil, err
+        }
+        timeout := 10 * time.Second
+        if raw := otlpEnv("TIMEOUT"); raw != "" {
+            ms, err := strconv.Atoi(raw)
+            if err != nil {
+                return nil, fmt.Errorf("invalid OTLP timeout %q: %w", raw, err)
+            }
+            timeout = time.Duration(ms) * time.Millisecond
+        }
+        client := &http.Client{Timeout: timeout}
+        return &jsonSpanExporter{write: postOTLP(client, endpoint, headers)}, nil
+    default:
+        return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q, must be one of %s, %s or %s", name, tracesExporterOTLP, tracesExporterConsole, tracesExporterNone)
+    }
+}
+
+// otlpEnv returns the OTLP exporter setting for traces, falling back to the one for all
+// signals.
+func otlpEnv(name string) string {
+    if value := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_" + name); value != "" {
+        return value
+    }
+    return os.Getenv("OTEL_EXPORTER_OTLP_" + name)
+}
+
+// parseOTLPHeaders parses the comma separated key=value pairs of OTEL_EXPORTER_OTLP_HEADERS.
+func parseOTLPHeaders(raw string) (map[string]string, error) {
+    headers := map[string]string{}
+    for _, pair := range strings.Split(raw, ",") {
+        if strings.TrimSpace(pair) == "" {
+            continue
+        }
+        key, value, ok := strings.Cut(pair, "=")
+        if !ok || strings.TrimSpace(key) == "" {
+            return nil, fmt.Errorf("invalid OTLP header %q, must be key=value", pair)
+        }
+        headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+    }
+    return headers, nil
+}
+
+// tracingWarning reports tracing that could not be set up. Traces are not needed to manage
+// VMs, so it does not fail the run.
+func tracingWarning(err error) diag.Diagnostics {
+    if err == nil {
+        return nil
+    }
+    return diag.Diagnostics{{
+        Severity: diag.Warning,
+        Summary:  "OpenTelemetry tracing disabled",
+        Detail:   err.Error(),
+    }}
+}
+
+// startSpan starts a span of the provider. The first span of an operation continues the trace
+// of the process that ran Terraform, when it passed one in TRACEPARENT.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+    if !trace.SpanContextFromContext(ctx).IsValid() {
+        if parent := os.Getenv("TRACEPARENT"); parent != "" {
+            ctx = propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": parent})
+        }
+    }
+    return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends a span, recording err as its outcome.
+func endSpan(span trace.Span, err error) {
+    if err != nil {
+        span.RecordError(err)
+        span.SetStatus(codes.Error, err.Error())
+    }
+    span.End()
+}
+
+// startAPISpan starts the span of a request to the Firecracker API of c.
+func (c *FirecrackerClient) startAPISpan(ctx context.Context, method, path string) (context.Context, trace.Span) {
+    return startSpan(ctx, method+" "+path,
+        attribute.String("http.request.method", method),
+        attribute.String("url.path", path),
+        attribute.String("server.address", c.BaseURL),
+    )
+}
+
+// endAPISpan ends the span of a request to the API, recording the status Firecracker
+// rejected it with.
+func endAPISpan(span trace.Span, err error) {
+    var apiErr *apiError
+    if errors.As(err, &apiErr) {
+        span.SetAttributes(attribute.Int("http.response.status_code", apiErr.StatusCode))
+    }
+    endSpan(span, err)
+}
+
+// flushTraces exports the spans of an operation before Terraform can stop the provider.
+func flushTraces(ctx context.Context) {
+    if tracingProvider == nil {
+        return
+    }
+    if err := tracingProvider.ForceFlush(ctx); err != nil {
+        tflog.Warn(ctx, "Failed to export OpenTelemetry traces", map[string]interface{}{
+            "error": err.Error(),
+        })
+    }
+}
+
+// traceResource wraps the operations of a resource or data source in spans named after it,
+// such as firecracker_vm.create.
+func traceResource(name string, r *schema.Resource) {
+    wrap := func(op string, f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+        if f == nil {
+            return nil
+        }
+        return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+            ctx, span := startSpan(ctx, name+"."+op, attribute.String("terraform.resource.type", name))
+            diags := f(ctx, d, meta)
+            span.SetAttributes(attribute.String("terraform.resource.id", d.Id()))
+            var err error
+            for _, diagnostic := range diags {
+                if diagnostic.Severity == diag.Error {
+                    err = fmt.Errorf("%s", diagnostic.Summary)
+                    break
+                }
+            }
+            endSpan(span, err)
+            flushTraces(ctx)
+            return diags
+        }
+    }
+
+    r.CreateContext = wrap("create", r.CreateContext)
+    r.ReadContext = wrap("read", r.ReadContext)
+    r.UpdateContext = wrap("update", r.UpdateContext)
+    r.DeleteContext = wrap("delete", r.DeleteContext)
+}
+
+// jsonSpanExporter exports spans as an OTLP/JSON ExportTraceServiceRequest.
+type jsonSpanExporter struct {
+    write func(ctx context.Context, request []byte) error
+}
+
+func (e *jsonSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+    if len(spans) == 0 {
+        return nil
+    }
+    request, err := json.Marshal(otlpTraceRequest(spans))
+    if err != nil {
+        return fmt.Errorf("failed to encode spans: %w", err)
+    }
+    return e.write(ctx, request)
+}
+
+func (e *jsonSpanExporter) Shutdown(context.Context) error {
+    return nil
+}
+
+// writeSpanLines writes each export request to w on a line of its own.
+func writeSpanLines(w io.Writer) func(context.Context, []byte) error {
+    var mu sync.Mutex
+    return func(_ context.Context, request []byte) error {
+        mu.Lock()
+        defer mu.Unlock()
+        _, err := w.Write(append(request, '\n'))
+        return err
+    }
+}
+
+// postOTLP sends each export request to an OTLP/HTTP endpoint.
+func postOTLP(client *http.Client, endpoint string, headers map[string]string) func(context.Context, []byte) error {
+    return func(ctx context.Context, request []byte) error {
+        req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(request))
+        if err != nil {
+            return err
+        }
+        req.Header.Set("Content-Type", "application/json")
+        for key, value := range headers {
+            req.Header.Set(key, value)
+        }
+        resp, err := client.Do(req)
+        if err != nil {
+            return fmt.Errorf("failed to send spans to %s: %w", endpoint, err)
+        }
+        defer resp.Body.Close()
+        if resp.StatusCode/100 != 2 {
+            body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+            return fmt.Errorf("sending spans to %s failed with status %d: %s", endpoint, resp.StatusCode, strings.TrimSpace(string(body)))
+        }
+        return nil
+    }
+}
+
+// otlpTraceRequest returns the OTLP/JSON encoding of spans, grouped by resource and scope as
+// the protocol has them.
+func otlpTraceRequest(spans []sdktrace.ReadOnlySpan) map[string]interface{} {
+    type group struct {
+        resource map[string]interface{}
+        scopes   map[string][]interface{}
+        order    []string
+    }
+    groups := map[string]*group{}
+    var order []string
+    for _, span := range spans {
+        resKey := ""
+        var resAttrs []attribute.KeyValue
+        if span.Resource() != nil {
+            resKey = span.Resource().Encoded(attribute.DefaultEncoder())
+            resAttrs = span.Resource().Attributes()
+        }
+        g, ok := groups[resKey]
+        if !ok {
+            g = &group{
+                resource: map[string]interface{}{"attributes": otlpAttributes(resAttrs)},
+                scopes:   map[string][]interface{}{},
+            }
+            groups[resKey] = g
+            order = append(order, resKey)
+        }
+        scope := span.InstrumentationScope().Name
+        if _, ok := g.scopes[scope]; !ok {
+            g.order = append(g.order, scope)
+        }
+        g.scopes[scope] = append(g.scopes[scope], otlpSpan(span))
+    }
+
+    resourceSpans := make([]interface{}, 0, len(order))
+    for _, resKey := range order {
+        g := groups[resKey]
+        scopeSpans := make([]interface{}, 0, len(g.order))
+        for _, scope := range g.order {
+            scopeSpans = append(scopeSpans, map[string]interface{}{
+                "scope": map[string]interface{}{"name": scope},
+                "spans": g.scopes[scope],
+            })
+        }
+        resourceSpans = append(resourceSpans, map[string]interface{}{
+            "resource":   g.resource,
+            "scopeSpans": scopeSpans,
+        })
+    }
+    return map[string]interface{}{"resourceSpans": resourceSpans}
+}
+
+// otlpSpan returns the OTLP/JSON encoding of a span.
+func otlpSpan(span sdktrace.ReadOnlySpan) map[string]interface{} {
+    encoded := map[string]interface{}{
+        "traceId":           span.SpanContext().TraceID().String(),
+        "spanId":            span.SpanContext().SpanID().String(),
+        "name":              span.Name(),
+        "kind":              int(span.SpanKind()),
+        "startTimeUnixNano": strconv.FormatInt(span.StartTime().UnixNano(), 10),
+        "endTimeUnixNano":   strconv.FormatInt(span.EndTime().UnixNano(), 10),
+        "attributes":        otlpAttributes(span.Attributes()),
+    }
+    if span.Parent().IsValid() {
+        encoded["parentSpanId"] = span.Parent().SpanID().String()
+    }
+    if events := span.Events(); len(events) > 0 {
+        encodedEvents := make([]interface{}, len(events))
+        for i, event := range events {
+            encodedEvents[i] = map[string]interface{}{
+                "name":         event.Name,
+                "timeUnixNano": strconv.FormatInt(event.Time.UnixNano(), 10),
+                "attributes":   otlpAttributes(event.Attributes),
+            }
+        }
+        encoded["events"] = encodedEvents
+    }
+    // OTLP numbers status codes the other way around from the Go API
+    switch span.Status().Code {
+    case codes.Ok:
+        encoded["status"] = map[string]interface{}{"code": 1}
+    case codes.Error:
+        encoded["status"] = map[string]interface{}{"code": 2, "message": span.Status().Description}
+    }
+    return encoded
+}
+
+// otlpAttributes returns the OTLP/JSON encoding of attributes.
+func otlpAttributes(attrs []attribute.KeyValue) []interface{} {
+    encoded := make([]interface{}, 0, len(attrs))
+    for _, attr := range attrs {
+        var value map[string]interface{}
+        switch attr.Value.Type() {
+        case attribute.BOOL:
+            value = map[string]interface{}{"boolValue": attr.Value.AsBool()}
+        case attribute.INT64:
+            value = map[string]interface{}{"intValue": strconv.FormatInt(attr.Value.AsInt64(), 10)}
+        case attribute.FLOAT64:
+            value = map[string]interface{}{"doubleValue": attr.Value.AsFloat64()}
+        default:
+            value = map[string]interface{}{"stringValue": attr.Value.Emit()}
+        }
+        encoded = append(encoded, map[string]interface{}{"key": string(attr.Key), "value": value})
+    }
+    return encoded
+}
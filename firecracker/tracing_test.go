@@ -0,0 +1,186 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider recording the spans of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestTracesExporterFromEnv(t *testing.T) {
+	cases := []struct {
+		name     string
+		env      map[string]string
+		exporter bool
+		err      string
+	}{
+		{name: "unset"},
+		{name: "none", env: map[string]string{"OTEL_TRACES_EXPORTER": "none", "OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318"}},
+		{name: "console", env: map[string]string{"OTEL_TRACES_EXPORTER": "console"}, exporter: true},
+		{name: "endpoint", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318"}, exporter: true},
+		{name: "otlp", env: map[string]string{"OTEL_TRACES_EXPORTER": "otlp", "OTEL_EXPORTER_OTLP_HEADERS": "x-token=secret"}, exporter: true},
+		{name: "grpc", env: map[string]string{"OTEL_TRACES_EXPORTER": "otlp", "OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"}, err: "not supported"},
+		{name: "headers", env: map[string]string{"OTEL_TRACES_EXPORTER": "otlp", "OTEL_EXPORTER_OTLP_HEADERS": "x-token"}, err: "invalid OTLP header"},
+		{name: "unknown", env: map[string]string{"OTEL_TRACES_EXPORTER": "zipkin"}, err: "unsupported OTEL_TRACES_EXPORTER"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, name := range []string{"OTEL_TRACES_EXPORTER", "OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_HEADERS"} {
+				t.Setenv(name, tc.env[name])
+			}
+			exporter, err := tracesExporterFromEnv()
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Expected error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if (exporter != nil) != tc.exporter {
+				t.Errorf("Expected exporter %v, got %v", tc.exporter, exporter)
+			}
+		})
+	}
+}
+
+func TestPostOTLP(t *testing.T) {
+	var request map[string]interface{}
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Expected a JSON request, got %v", err)
+		}
+	}))
+	defer server.Close()
+
+	exporter := &jsonSpanExporter{write: postOTLP(server.Client(), server.URL+"/v1/traces", map[string]string{"x-token": "secret"})}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	ctx, parent := provider.Tracer(tracerName).Start(context.Background(), "firecracker_vm.create")
+	_, child := provider.Tracer(tracerName).Start(ctx, "PUT /boot-source")
+	child.SetStatus(codes.Error, "rejected")
+	child.End()
+	parent.End()
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("Expected spans to be exported, got %v", err)
+	}
+
+	if headers.Get("x-token") != "secret" || headers.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the configured headers, got %v", headers)
+	}
+	encoded, _ := json.Marshal(request)
+	for _, want := range []string{
+		`"name":"firecracker_vm.create"`,
+		`"key":"service.name"`,
+		`"parentSpanId":"` + parent.SpanContext().SpanID().String() + `"`,
+		`"traceId":"` + parent.SpanContext().TraceID().String() + `"`,
+		`"status":{"code":2,"message":"rejected"}`,
+	} {
+		if !strings.Contains(string(encoded), want) {
+			t.Errorf("Expected export request to contain %s, got %s", want, encoded)
+		}
+	}
+}
+
+func TestWriteSpanLines(t *testing.T) {
+	var out bytes.Buffer
+	exporter := &jsonSpanExporter{write: writeSpanLines(&out)}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	_, span := provider.Tracer(tracerName).Start(context.Background(), "firecracker_vm.read")
+	span.End()
+
+	line, err := out.ReadString('\n')
+	if err != nil || !strings.Contains(line, `"name":"firecracker_vm.read"`) {
+		t.Errorf("Expected a line with the span, got %q (%v)", line, err)
+	}
+}
+
+func TestTraceResource(t *testing.T) {
+	recorder := recordSpans(t)
+
+	client := &FirecrackerClient{
+		BaseURL: "http://localhost:8080",
+		HTTPClient: &mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusBadRequest,
+					Body:       io.NopCloser(strings.NewReader(`{"fault_message": "Invalid kernel"}`)),
+				}, nil
+			},
+		},
+	}
+	r := &schema.Resource{
+		Schema: map[string]*schema.Schema{},
+		CreateContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			d.SetId("vm-1")
+			return diag.FromErr(meta.(*FirecrackerClient).sendComponent(ctx, http.MethodPut, client.BaseURL+"/boot-source", map[string]interface{}{}))
+		},
+	}
+	traceResource("firecracker_vm", r)
+	if r.ReadContext != nil {
+		t.Fatalf("Expected missing operations to stay unset")
+	}
+
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{})
+	if diags := r.CreateContext(context.Background(), d, client); !diags.HasError() {
+		t.Fatalf("Expected the create to fail")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	api, create := spans[0], spans[1]
+	if api.Name() != "PUT /boot-source" || create.Name() != "firecracker_vm.create" {
+		t.Fatalf("Expected API and create spans, got %q and %q", api.Name(), create.Name())
+	}
+	if api.Parent().SpanID() != create.SpanContext().SpanID() {
+		t.Errorf("Expected the API span to be a child of the create span")
+	}
+	if api.Status().Code != codes.Error || create.Status().Code != codes.Error {
+		t.Errorf("Expected both spans to fail, got %v and %v", api.Status(), create.Status())
+	}
+	attrs := map[string]string{}
+	for _, attr := range append(api.Attributes(), create.Attributes()...) {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs["http.response.status_code"] != "400" || attrs["terraform.resource.id"] != "vm-1" {
+		t.Errorf("Expected status code and resource ID attributes, got %v", attrs)
+	}
+}
+
+func TestStartSpan_traceparent(t *testing.T) {
+	recorder := recordSpans(t)
+	t.Setenv("TRACEPARENT", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	_, span := startSpan(context.Background(), "firecracker_vm.read")
+	span.End()
+
+	ended := recorder.Ended()[0]
+	if ended.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || ended.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the span to continue the trace in TRACEPARENT, got %v with parent %v", ended.SpanContext(), ended.Parent())
+	}
+}
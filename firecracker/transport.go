@@ -0,0 +1,59 @@
+package firecracker
+
+import (
+    "context"
+    "crypto/tls"
+    "fmt"
+    "net"
+    "net/http"
+    "time"
+)
+
+const (
+    defaultMaxIdleConns        = 100
+    defaultMaxIdleConnsPerHost = 20
+    defaultIdleConnTimeout     = "90s"
+)
+
+// transportFromConfig builds the HTTP transport requests to the Firecracker APIs are sent
+// with from the provider's transport block, or the default settings without one. Requests
+// to socket are sent over it whatever their URL.
+func transportFromConfig(raw []interface{}, tlsConfig *tls.Config, socket string) (*http.Transport, error) {
+    block := map[string]interface{}{
+        "keep_alive":              true,
+        "max_idle_conns":          defaultMaxIdleConns,
+        "max_idle_conns_per_host": defaultMaxIdleConnsPerHost,
+        "idle_conn_timeout":       defaultIdleConnTimeout,
+        "dial_timeout":            "",
+    }
+    if len(raw) > 0 && raw[0] != nil {
+        block = raw[0].(map[string]interface{})
+    }
+
+    idleConnTimeout, err := time.ParseDuration(block["idle_conn_timeout"].(string))
+    if err != nil {
+        return nil, fmt.Errorf("invalid transport idle_conn_timeout: %w", err)
+    }
+    // Without a dial timeout, connecting is only bounded by the provider's timeout
+    var dialer net.Dialer
+    if dialTimeout := block["dial_timeout"].(string); dialTimeout != "" {
+        if dialer.Timeout, err = time.ParseDuration(dialTimeout); err != nil {
+            return nil, fmt.Errorf("invalid transport dial_timeout: %w", err)
+        }
+    }
+
+    transport := &http.Transport{
+        DialContext:         dialer.DialContext,
+        DisableKeepAlives:   !block["keep_alive"].(bool),
+        MaxIdleConns:        block["max_idle_conns"].(int),
+        MaxIdleConnsPerHost: block["max_idle_conns_per_host"].(int),
+        IdleConnTimeout:     idleConnTimeout,
+        TLSClientConfig:     tlsConfig,
+    }
+    if socket != "" {
+        transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+            return dialer.DialContext(ctx, "unix", socket)
+        }
+    }
+    return transport, nil
+}
@@ -0,0 +1,100 @@
+package firecracker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestTransportFromConfig(t *testing.T) {
+	transport, err := transportFromConfig(nil, nil, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if transport.DisableKeepAlives || transport.MaxIdleConns != 100 || transport.MaxIdleConnsPerHost != 20 || transport.IdleConnTimeout != 90*time.Second {
+		t.Errorf("Expected the default settings, got %+v", transport)
+	}
+
+	transport, err = transportFromConfig([]interface{}{
+		map[string]interface{}{
+			"keep_alive":              false,
+			"max_idle_conns":          10,
+			"max_idle_conns_per_host": 2,
+			"idle_conn_timeout":       "5s",
+			"dial_timeout":            "1s",
+		},
+	}, nil, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !transport.DisableKeepAlives || transport.MaxIdleConns != 10 || transport.MaxIdleConnsPerHost != 2 || transport.IdleConnTimeout != 5*time.Second {
+		t.Errorf("Expected the block's settings, got %+v", transport)
+	}
+
+	if _, err := transportFromConfig([]interface{}{
+		map[string]interface{}{
+			"keep_alive":              true,
+			"max_idle_conns":          10,
+			"max_idle_conns_per_host": 2,
+			"idle_conn_timeout":       "5s",
+			"dial_timeout":            "soon",
+		},
+	}, nil, ""); err == nil {
+		t.Error("Expected an invalid dial_timeout to be rejected")
+	}
+}
+
+func TestProvider_transportKeepAlive(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "firecracker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix sockets unavailable: %v", err)
+	}
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"firecracker_version": "1.10.1"}`))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	for _, keepAlive := range []bool{true, false} {
+		atomic.StoreInt32(&conns, 0)
+		d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+			"socket":    socket,
+			"state_dir": t.TempDir(),
+			"transport": []interface{}{
+				map[string]interface{}{"keep_alive": keepAlive},
+			},
+		})
+		client, diags := configureProvider(context.Background(), d)
+		if diags.HasError() {
+			t.Fatalf("Failed to configure provider: %v", diags)
+		}
+		for i := 0; i < 3; i++ {
+			if _, err := client.(*FirecrackerClient).api().Version(context.Background()); err != nil {
+				t.Fatalf("Failed to reach the API over the socket: %v", err)
+			}
+		}
+
+		want := int32(1)
+		if !keepAlive {
+			want = 3
+		}
+		if got := atomic.LoadInt32(&conns); got != want {
+			t.Errorf("Expected %d connections with keep_alive %v, got %d", want, keepAlive, got)
+		}
+	}
+}
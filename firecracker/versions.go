@@ -0,0 +1,151 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// firecrackerVersion is a Firecracker release, as reported by GET /version.
+type firecrackerVersion struct {
+    Major int
+    Minor int
+    Patch int
+}
+
+// parseFirecrackerVersion parses a release such as "1.10.1". A leading "v" and suffixes
+// such as "-dev" are ignored.
+func parseFirecrackerVersion(raw string) (firecrackerVersion, error) {
+    version := strings.TrimPrefix(raw, "v")
+    if i := strings.IndexAny(version, "-+"); i >= 0 {
+        version = version[:i]
+    }
+
+    parts := strings.Split(version, ".")
+    if len(parts) != 3 {
+        return firecrackerVersion{}, fmt.Errorf("invalid Firecracker version %q, expected major.minor.patch", raw)
+    }
+    var numbers [3]int
+    for i, part := range parts {
+        n, err := strconv.Atoi(part)
+        if err != nil || n < 0 {
+            return firecrackerVersion{}, fmt.Errorf("invalid Firecracker version %q, expected major.minor.patch", raw)
+        }
+        numbers[i] = n
+    }
+    return firecrackerVersion{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+func (v firecrackerVersion) String() string {
+    return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// atLeast reports whether v is the same release as other or a newer one.
+func (v firecrackerVersion) atLeast(other firecrackerVersion) bool {
+    if v.Major != other.Major {
+        return v.Major > other.Major
+    }
+    if v.Minor != other.Minor {
+        return v.Minor > other.Minor
+    }
+    return v.Patch >= other.Patch
+}
+
+// versionedFeature is a VM setting that needs a minimum Firecracker release.
+type versionedFeature struct {
+    // Name is the attribute enabling the feature.
+    Name string
+    // Since is the first release supporting the feature.
+    Since firecrackerVersion
+    // Description says what the feature is.
+    Description string
+    // Used reports whether a VM's configuration uses the feature.
+    Used func(d *schema.ResourceData) bool
+}
+
+// versionedFeatures lists the VM settings that older Firecracker releases reject.
+// Keep in sync with contractCapabilities.
+var versionedFeatures = []versionedFeature{
+    {
+        Name:        "entropy_device",
+        Since:       firecrackerVersion{Major: 1, Minor: 4, Patch: 0},
+        Description: "a virtio-rng device feeding the guest's random number generator",
+        Used: func(d *schema.ResourceData) bool {
+            return d.Get("entropy_device").(bool)
+        },
+    },
+    {
+        Name:        "cpu_config_json",
+        Since:       firecrackerVersion{Major: 1, Minor: 4, Patch: 0},
+        Description: "custom CPU templates",
+        Used: func(d *schema.ResourceData) bool {
+            return d.Get("cpu_config_json").(string) != ""
+        },
+    },
+}
+
+// GetVersion returns the version of the Firecracker process serving the API.
+func (c *FirecrackerClient) GetVersion(ctx context.Context) (firecrackerVersion, error) {
+    info, err := c.getComponent(ctx, fmt.Sprintf("%s/version", c.BaseURL))
+    if err != nil {
+        return firecrackerVersion{}, fmt.Errorf("failed to get Firecracker version: %w", err)
+    }
+    raw, _ := info["firecracker_version"].(string)
+    if raw == "" {
+        return firecrackerVersion{}, fmt.Errorf("failed to get Firecracker version: the API did not report it")
+    }
+    return parseFirecrackerVersion(raw)
+}
+
+// checkFirecrackerVersion fails with a diagnostic naming the setting and the release it
+// needs when the VM uses a feature the Firecracker serving client does not support, instead
+// of letting the API reject it halfway through configuring the VM. When the version cannot
+// be determined, a warning is returned and the VM is created anyway.
+func checkFirecrackerVersion(ctx context.Context, client *FirecrackerClient, d *schema.ResourceData) diag.Diagnostics {
+    var used []versionedFeature
+    for _, feature := range versionedFeatures {
+        if feature.Used(d) {
+            used = append(used, feature)
+        }
+    }
+    if len(used) == 0 {
+        return nil
+    }
+
+    version, err := client.GetVersion(ctx)
+    if err != nil {
+        return diag.Diagnostics{{
+            Severity: diag.Warning,
+            Summary:  "Could not check the Firecracker version",
+            Detail:   fmt.Sprintf("%s requires Firecracker %s or later, but the version of the Firecracker API at %s could not be determined: %s. Creating the VM anyway.", used[0].Name, used[0].Since, client.BaseURL, err),
+        }}
+    }
+
+    var diags diag.Diagnostics
+    for _, feature := range used {
+        if version.atLeast(feature.Since) {
+            continue
+        }
+        diags = append(diags, diag.Diagnostic{
+            Severity: diag.Error,
+            Summary:  fmt.Sprintf("%s requires Firecracker %s or later", feature.Name, feature.Since),
+            Detail:   fmt.Sprintf("The Firecracker API at %s runs version %s, which does not support %s (%s). Upgrade Firecracker or remove %s from the configuration.", client.BaseURL, version, feature.Name, feature.Description, feature.Name),
+        })
+    }
+    return diags
+}
+
+// supportedFeatures returns the names of the versioned features a release supports.
+func supportedFeatures(version firecrackerVersion) []string {
+    names := []string{}
+    for _, feature := range versionedFeatures {
+        if version.atLeast(feature.Since) {
+            names = append(names, feature.Name)
+        }
+    }
+    return names
+}
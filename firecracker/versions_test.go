@@ -0,0 +1,138 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestParseFirecrackerVersion(t *testing.T) {
+	cases := map[string]struct {
+		expected firecrackerVersion
+		valid    bool
+	}{
+		"1.10.1":     {firecrackerVersion{1, 10, 1}, true},
+		"v1.4.0":     {firecrackerVersion{1, 4, 0}, true},
+		"1.7.0-dev":  {firecrackerVersion{1, 7, 0}, true},
+		"1.7":        {firecrackerVersion{}, false},
+		"1.x.0":      {firecrackerVersion{}, false},
+		"":           {firecrackerVersion{}, false},
+		"1.2.3.4":    {firecrackerVersion{}, false},
+		"1.-1.0":     {firecrackerVersion{}, false},
+		"0.25.2+foo": {firecrackerVersion{0, 25, 2}, true},
+	}
+
+	for raw, tc := range cases {
+		version, err := parseFirecrackerVersion(raw)
+		if (err == nil) != tc.valid {
+			t.Errorf("%q: expected valid to be %t, got %v", raw, tc.valid, err)
+		}
+		if version != tc.expected {
+			t.Errorf("%q: expected %v, got %v", raw, tc.expected, version)
+		}
+	}
+
+	if !(firecrackerVersion{1, 10, 0}).atLeast(firecrackerVersion{1, 4, 0}) || (firecrackerVersion{1, 3, 9}).atLeast(firecrackerVersion{1, 4, 0}) {
+		t.Errorf("Expected releases to be compared numerically")
+	}
+}
+
+func TestCheckFirecrackerVersion(t *testing.T) {
+	ctx := context.Background()
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+	defer resourceFirecrackerTestImageDelete(ctx, image, nil)
+
+	for name, tc := range map[string]struct {
+		version  string
+		expected string
+	}{
+		"supported":   {"", ""},
+		"unsupported": {`{"firecracker_version":"1.3.0"}`, "entropy_device requires Firecracker 1.4.0 or later"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			stateDir := t.TempDir()
+			provider := configureFakeProvider(t, stateDir)
+			apiDir := filepath.Join(stateDir, "fake", "test")
+			if tc.version != "" {
+				if err := os.MkdirAll(apiDir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(apiDir, "version.json"), []byte(tc.version), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+				"kernel_image_path": image.Get("path").(string),
+				"drives": []interface{}{
+					map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true},
+				},
+				"machine_config": []interface{}{
+					map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128},
+				},
+				"entropy_device": true,
+			})
+			diags := resourceFirecrackerVMCreate(ctx, d, provider)
+
+			_, err := os.Stat(filepath.Join(apiDir, "entropy.json"))
+			if tc.expected == "" {
+				if diags.HasError() {
+					t.Fatalf("Failed to create VM: %v", diags)
+				}
+				if err != nil {
+					t.Errorf("Expected the entropy device to be configured, got %v", err)
+				}
+				return
+			}
+			if !diags.HasError() || !strings.Contains(diags[0].Summary, tc.expected) {
+				t.Fatalf("Expected an error containing %q, got %v", tc.expected, diags)
+			}
+			if d.Id() != "" || err == nil {
+				t.Errorf("Expected nothing to be created, got VM %q", d.Id())
+			}
+		})
+	}
+}
+
+func TestCheckFirecrackerVersion_unknown(t *testing.T) {
+	client := &FirecrackerClient{
+		BaseURL: "http://localhost:8080",
+		HTTPClient: &mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(bytes.NewBufferString(""))}, nil
+			},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{"entropy_device": true})
+
+	diags := checkFirecrackerVersion(context.Background(), client, d)
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Errorf("Expected a warning when the version is unknown, got %v", diags)
+	}
+}
+
+func TestDataSourceFirecrackerVersion(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceFirecrackerVersion().Schema, map[string]interface{}{})
+	if diags := dataSourceFirecrackerVersionRead(context.Background(), d, configureFakeProvider(t, t.TempDir())); diags.HasError() {
+		t.Fatalf("Failed to read version: %v", diags)
+	}
+	if d.Get("version").(string) != "1.10.1" || d.Get("minor").(int) != 10 {
+		t.Errorf("Unexpected version %s", d.Get("version"))
+	}
+	if features := d.Get("supported_features").([]interface{}); len(features) != 2 || features[0] != "entropy_device" || features[1] != "cpu_config_json" {
+		t.Errorf("Expected entropy_device and cpu_config_json to be supported, got %v", features)
+	}
+}
@@ -0,0 +1,75 @@
+package firecracker
+
+import (
+    "context"
+    "strings"
+    "time"
+
+    "github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+    vmHealthHealthy      = "healthy"
+    vmHealthUnhealthy    = "unhealthy"
+    vmHealthUnreachable  = "unreachable"
+    vmHealthFailed       = "failed"
+    vmHealthLost         = "lost"
+    vmHealthProvisioning = "provisioning"
+
+    // vmHealthHistoryLimit is how many health changes are kept in a VM's health_history.
+    vmHealthHistoryLimit = 10
+)
+
+// healthNow returns the current time, replaced in tests.
+var healthNow = time.Now
+
+// recordVMHealth sets the VM's health status, adding an entry to its health history when
+// the status changes. Unchanged statuses are not recorded, so refreshing a healthy VM
+// leaves its state alone.
+func recordVMHealth(d *schema.ResourceData, status, message string) {
+    previous := d.Get("health_status").(string)
+    d.Set("health_status", status)
+    if status == previous {
+        return
+    }
+
+    history := d.Get("health_history").([]interface{})
+    history = append(history, map[string]interface{}{
+        "status":  status,
+        "message": message,
+        "time":    healthNow().UTC().Format(time.RFC3339),
+    })
+    if len(history) > vmHealthHistoryLimit {
+        history = history[len(history)-vmHealthHistoryLimit:]
+    }
+    d.Set("health_history", history)
+}
+
+// recordVMError records an error creating or updating the VM as its last error.
+func recordVMError(d *schema.ResourceData, diags diag.Diagnostics) {
+    messages := []string{}
+    for _, diagnostic := range diags {
+        if diagnostic.Severity == diag.Error {
+            messages = append(messages, diagnostic.Summary)
+        }
+    }
+    message := strings.Join(messages, "; ")
+
+    d.Set("last_error", message)
+    d.Set("last_error_time", healthNow().UTC().Format(time.RFC3339))
+    recordVMHealth(d, vmHealthFailed, message)
+}
+
+// withVMErrorRecording wraps a create or update function to record its errors in the VM's
+// state. Terraform keeps the state of a VM whose creation failed once it has an ID, marked
+// as tainted, so the error is recorded along with it.
+func withVMErrorRecording(f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+    return func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+        diags := f(ctx, d, m)
+        if diags.HasError() && d.Id() != "" {
+            recordVMError(d, diags)
+        }
+        return diags
+    }
+}
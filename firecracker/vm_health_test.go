@@ -0,0 +1,120 @@
+package firecracker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestRecordVMHealth(t *testing.T) {
+	original := healthNow
+	defer func() { healthNow = original }()
+	clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	healthNow = func() time.Time { return clock }
+
+	d := resourceFirecrackerVM().TestResourceData()
+	recordVMHealth(d, vmHealthHealthy, "")
+	recordVMHealth(d, vmHealthHealthy, "")
+	if history := d.Get("health_history").([]interface{}); len(history) != 1 {
+		t.Fatalf("Expected an unchanged status not to be recorded, got %v", history)
+	}
+
+	clock = clock.Add(time.Minute)
+	recordVMHealth(d, vmHealthUnhealthy, "guest agent health check failed")
+	if d.Get("health_status").(string) != vmHealthUnhealthy {
+		t.Errorf("Expected the VM to be unhealthy, got %q", d.Get("health_status"))
+	}
+	entry := d.Get("health_history.1").(map[string]interface{})
+	if entry["status"] != vmHealthUnhealthy || entry["message"] != "guest agent health check failed" || entry["time"] != "2024-05-01T12:01:00Z" {
+		t.Errorf("Unexpected history entry %v", entry)
+	}
+
+	for i := 0; i < vmHealthHistoryLimit; i++ {
+		recordVMHealth(d, vmHealthHealthy, "")
+		recordVMHealth(d, vmHealthUnreachable, fmt.Sprintf("attempt %d", i))
+	}
+	history := d.Get("health_history").([]interface{})
+	if len(history) != vmHealthHistoryLimit {
+		t.Fatalf("Expected the history to be limited to %d entries, got %d", vmHealthHistoryLimit, len(history))
+	}
+	if last := history[len(history)-1].(map[string]interface{}); last["message"] != fmt.Sprintf("attempt %d", vmHealthHistoryLimit-1) {
+		t.Errorf("Expected the most recent change last, got %v", last)
+	}
+}
+
+func TestWithVMErrorRecording(t *testing.T) {
+	original := healthNow
+	defer func() { healthNow = original }()
+	healthNow = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	create := withVMErrorRecording(func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		d.SetId("vm-1")
+		return diag.Errorf("failed to create VM: InstanceStart failed")
+	})
+
+	d := resourceFirecrackerVM().TestResourceData()
+	if diags := create(context.Background(), d, nil); !diags.HasError() {
+		t.Fatal("Expected the error to be returned")
+	}
+	if d.Get("last_error").(string) != "failed to create VM: InstanceStart failed" || d.Get("last_error_time").(string) != "2024-05-01T12:00:00Z" {
+		t.Errorf("Expected the boot failure to be recorded, got %q at %q", d.Get("last_error"), d.Get("last_error_time"))
+	}
+	if d.Get("health_status").(string) != vmHealthFailed {
+		t.Errorf("Expected the VM to have failed, got %q", d.Get("health_status"))
+	}
+
+	// Nothing is recorded for a VM that never got an ID, since Terraform does not keep it
+	d = resourceFirecrackerVM().TestResourceData()
+	create = withVMErrorRecording(func(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+		return diag.Errorf("invalid configuration")
+	})
+	create(context.Background(), d, nil)
+	if d.Get("last_error").(string) != "" {
+		t.Errorf("Expected no error to be recorded, got %q", d.Get("last_error"))
+	}
+}
+
+func TestVMRead_warningsKeepHealth(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+	image := filepath.Join(t.TempDir(), "image")
+	if err := os.WriteFile(image, []byte("image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := asyncVMConfig(image)
+	config["async"] = false
+
+	r := Provider().ResourcesMap["firecracker_vm"]
+	diff, err := r.Diff(ctx, nil, terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("Failed to plan: %v", err)
+	}
+	state, diags := r.Apply(ctx, nil, diff, client)
+	if diags.HasError() || state == nil || state.ID == "" {
+		t.Fatalf("Expected the VM to be created, got %v, %v", state, diags)
+	}
+
+	// Another VM attaching the image writable makes the refresh warn
+	client = configureFakeProvider(t, stateDir)
+	if err := client.sharedDrives.record("vm-other", sharedDriveUser{VM: "vm-other"}, "", []attachedImage{{DriveID: "rootfs", Path: image, Writable: true}}, false); err != nil {
+		t.Fatal(err)
+	}
+	state, diags = r.RefreshWithoutUpgrade(ctx, state, client)
+	if diags.HasError() || state == nil {
+		t.Fatalf("Failed to refresh: %v", diags)
+	}
+	if len(diags) == 0 {
+		t.Fatal("Expected the shared drive to be warned about")
+	}
+	if status := state.Attributes["health_status"]; status != vmHealthHealthy {
+		t.Errorf("Expected warnings unrelated to the VM's health to keep it healthy, got %q", status)
+	}
+}
@@ -0,0 +1,182 @@
+package firecracker
+
+import (
+    "context"
+    "fmt"
+    "path/filepath"
+    "strings"
+
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceFirecrackerVMImport imports a VM by its ID, as <host>/<vm-id> for VMs on a pooled
+// host, or by the path of its API socket on the host. The configuration is restored from
+// the VM's registry record when it has one, and otherwise from what the Firecracker API
+// reports, so the imported state describes the VM rather than empty structures.
+func resourceFirecrackerVMImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+    provider := meta.(*FirecrackerClient)
+    vmID := d.Id()
+
+    var record *vmRecord
+    var err error
+    if filepath.IsAbs(vmID) {
+        // Only registered VMs are known by their API socket
+        record, err = lookupVMRecordBySocket(provider.vmRegistryDir(), vmID)
+        if err != nil {
+            return nil, err
+        }
+        if record == nil {
+            return nil, fmt.Errorf("no VM in the VM registry has the API socket %s, import it by ID instead", vmID)
+        }
+        vmID = record.ID
+    } else {
+        if host, id, ok := strings.Cut(vmID, "/"); ok {
+            d.Set("host", host)
+            vmID = id
+        }
+        record, err = lookupVMRecord(provider.vmRegistryDir(), vmID)
+        if err != nil {
+            return nil, err
+        }
+    }
+    if record != nil && d.Get("host").(string) == "" {
+        d.Set("host", record.Host)
+    }
+
+    client, err := vmClient(d, meta)
+    if err != nil {
+        return nil, err
+    }
+
+    tflog.Info(ctx, "Importing Firecracker VM", map[string]interface{}{
+        "id":         vmID,
+        "registered": record != nil,
+    })
+
+    // Get VM details from API
+    vmInfo, err := client.GetVM(ctx, vmID)
+    if err != nil {
+        return nil, fmt.Errorf("error importing VM %s: %w", vmID, err)
+    }
+    if vmInfo == nil {
+        return nil, fmt.Errorf("VM with ID %s not found", vmID)
+    }
+
+    // The registry record holds the configuration the VM was created with, including the
+    // settings only the provider knows about, so it is preferred over the API
+    if record != nil {
+        if err := restoreRecordedConfig(d, record); err != nil {
+            return nil, fmt.Errorf("error importing VM %s: %w", vmID, err)
+        }
+    } else {
+        config, err := client.GetVMConfig(ctx)
+        if err != nil {
+            return nil, fmt.Errorf("error importing VM %s: %w", vmID, err)
+        }
+        if config == nil {
+            tflog.Warn(ctx, "Firecracker API does not report the VM configuration, importing what it reports", map[string]interface{}{
+                "id": vmID,
+            })
+        } else if err := setConfigFromAPI(d, config); err != nil {
+            return nil, fmt.Errorf("error importing VM %s: %w", vmID, err)
+        }
+    }
+
+    // Read the resource data from the imported VM
+    d.SetId(vmID)
+    if diags := resourceFirecrackerVMRead(ctx, d, meta); diags.HasError() {
+        return nil, fmt.Errorf("error importing VM %s: %s", vmID, diags[0].Summary)
+    }
+
+    return []*schema.ResourceData{d}, nil
+}
+
+// lookupVMRecordBySocket returns the registry record of the VM with the API socket, or nil
+// if no registered VM has it.
+func lookupVMRecordBySocket(dir, socketPath string) (*vmRecord, error) {
+    records, err := listRegisteredVMs(dir)
+    if err != nil {
+        return nil, err
+    }
+    for i := range records {
+        if records[i].APISocketPath != "" && filepath.Clean(records[i].APISocketPath) == filepath.Clean(socketPath) {
+            return &records[i], nil
+        }
+    }
+    return nil, nil
+}
+
+// setConfigFromAPI sets the arguments of an imported VM from its configuration as reported
+// by GET /vm/config: its kernel, boot arguments, drives, machine configuration, network
+// interfaces, vsock device and entropy device.
+func setConfigFromAPI(d *schema.ResourceData, config map[string]interface{}) error {
+    if bootSource, ok := config["boot-source"].(map[string]interface{}); ok {
+        for key, attr := range map[string]string{"kernel_image_path": "kernel_image_path", "initrd_path": "initrd_path", "boot_args": "boot_args"} {
+            if value, ok := bootSource[key].(string); ok {
+                d.Set(attr, value)
+            }
+        }
+    }
+
+    if machineConfig, ok := config["machine-config"].(map[string]interface{}); ok {
+        d.Set("machine_config", []interface{}{map[string]interface{}{
+            "vcpu_count":   machineConfig["vcpu_count"],
+            "mem_size_mib": machineConfig["mem_size_mib"],
+        }})
+    }
+
+    if raw, ok := config["drives"].([]interface{}); ok {
+        drives := make([]interface{}, 0, len(raw))
+        for _, item := range raw {
+            drive, ok := item.(map[string]interface{})
+            if !ok {
+                continue
+            }
+            imported := map[string]interface{}{}
+            for _, key := range []string{"drive_id", "path_on_host", "is_root_device", "is_read_only", "partuuid"} {
+                if value, ok := drive[key]; ok {
+                    imported[key] = value
+                }
+            }
+            drives = append(drives, imported)
+        }
+        if err := d.Set("drives", drives); err != nil {
+            return fmt.Errorf("failed to import drives: %w", err)
+        }
+    }
+
+    if raw, ok := config["network-interfaces"].([]interface{}); ok {
+        ifaces := make([]interface{}, 0, len(raw))
+        for _, item := range raw {
+            iface, ok := item.(map[string]interface{})
+            if !ok {
+                continue
+            }
+            imported := map[string]interface{}{}
+            for _, key := range []string{"iface_id", "host_dev_name", "guest_mac"} {
+                if value, ok := iface[key]; ok {
+                    imported[key] = value
+                }
+            }
+            ifaces = append(ifaces, imported)
+        }
+        if err := d.Set("network_interfaces", ifaces); err != nil {
+            return fmt.Errorf("failed to import network interfaces: %w", err)
+        }
+    }
+
+    if vsock, ok := config["vsock"].(map[string]interface{}); ok {
+        if err := d.Set("vsock", []interface{}{map[string]interface{}{
+            "guest_cid": vsock["guest_cid"],
+            "uds_path":  vsock["uds_path"],
+        }}); err != nil {
+            return fmt.Errorf("failed to import vsock: %w", err)
+        }
+    }
+
+    if _, ok := config["entropy"].(map[string]interface{}); ok {
+        d.Set("entropy_device", true)
+    }
+    return nil
+}
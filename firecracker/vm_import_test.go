@@ -0,0 +1,116 @@
+package firecracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestResourceFirecrackerVMImport_fromAPI(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+	defer resourceFirecrackerTestImageDelete(ctx, image, nil)
+
+	client := configureFakeProvider(t, stateDir)
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"kernel_image_path": image.Get("path").(string),
+		"boot_args":         "console=ttyS0 quiet",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true, "is_read_only": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 2, "mem_size_mib": 256},
+		},
+		"network_interfaces": []interface{}{
+			map[string]interface{}{"iface_id": "eth0", "host_dev_name": "tap0", "guest_mac": "AA:FC:00:00:00:01"},
+		},
+	})
+	if diags := resourceFirecrackerVMCreate(ctx, d, client); diags.HasError() {
+		t.Fatalf("Failed to create VM: %v", diags)
+	}
+	// A VM the provider did not create has no registry record
+	if err := unregisterVM(client.vmRegistryDir(), d.Id()); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := resourceFirecrackerVM().TestResourceData()
+	imported.SetId(d.Id())
+	if _, err := resourceFirecrackerVMImport(ctx, imported, client); err != nil {
+		t.Fatalf("Failed to import VM: %v", err)
+	}
+
+	for attr, expected := range map[string]interface{}{
+		"kernel_image_path":                  image.Get("path").(string),
+		"boot_args":                          "console=ttyS0 quiet root=/dev/vda rootfstype=ext4 rw",
+		"machine_config.0.vcpu_count":        2,
+		"machine_config.0.mem_size_mib":      256,
+		"drives.0.drive_id":                  "rootfs",
+		"drives.0.path_on_host":              image.Get("path").(string),
+		"drives.0.is_root_device":            true,
+		"drives.0.is_read_only":              true,
+		"network_interfaces.0.iface_id":      "eth0",
+		"network_interfaces.0.host_dev_name": "tap0",
+		"network_interfaces.0.guest_mac":     "AA:FC:00:00:00:01",
+	} {
+		if got := imported.Get(attr); got != expected {
+			t.Errorf("Expected %s to be imported as %v, got %v", attr, expected, got)
+		}
+	}
+}
+
+func TestResourceFirecrackerVMImport_bySocket(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+	client := configureFakeProvider(t, stateDir)
+
+	// The VM runs, so it is not taken for a lost one
+	fakeDir := filepath.Join(stateDir, "fake", "test")
+	if err := os.MkdirAll(fakeDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(fakeDir, "actions.log"), []byte(`{"action_type":"InstanceStart"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	record := vmRecord{
+		ID:            "vm-1",
+		Name:          "web",
+		BaseURL:       client.BaseURL,
+		APISocketPath: "/srv/jailer/firecracker/vm-1/root/run/firecracker.socket",
+		Config:        map[string]interface{}{"name": "web", "boot_args": "console=ttyS0"},
+	}
+	if err := ensureVMRegistered(client.vmRegistryDir(), record); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+
+	imported := resourceFirecrackerVM().TestResourceData()
+	imported.SetId(record.APISocketPath)
+	if _, err := resourceFirecrackerVMImport(ctx, imported, client); err != nil {
+		t.Fatalf("Failed to import VM: %v", err)
+	}
+	if imported.Id() != "vm-1" || imported.Get("name") != "web" || imported.Get("boot_args") != "console=ttyS0" {
+		t.Errorf("Expected vm-1 to be imported from the registry, got %s with name %q, boot_args %q", imported.Id(), imported.Get("name"), imported.Get("boot_args"))
+	}
+
+	unknown := resourceFirecrackerVM().TestResourceData()
+	unknown.SetId("/run/other.socket")
+	if _, err := resourceFirecrackerVMImport(ctx, unknown, client); err == nil {
+		t.Error("Expected an error importing an unregistered socket")
+	}
+}
+
+func TestLookupVMRecordBySocket_noRegistry(t *testing.T) {
+	record, err := lookupVMRecordBySocket(t.TempDir()+"/missing", "/run/firecracker.socket")
+	if record != nil || (err != nil && !os.IsNotExist(err)) {
+		t.Errorf("Expected no record, got %v, %v", record, err)
+	}
+}
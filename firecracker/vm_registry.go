@@ -0,0 +1,390 @@
+package firecracker
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "syscall"
+    "time"
+
+    sdk "github.com/avkcode/terraform-provider-firecracker/client"
+    "github.com/hashicorp/terraform-plugin-log/tflog"
+    "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// vmStateUnreachable is reported for VMs whose Firecracker API cannot be reached.
+const vmStateUnreachable = "Unreachable"
+
+// vmRegistryMu serializes access to the VM registry within the provider process.
+var vmRegistryMu sync.Mutex
+
+// vmProcessExitTimeout is how long a VM's Firecracker process is given to exit after the
+// VM is shut down, and then after being asked to terminate, before it is killed.
+var vmProcessExitTimeout = 10 * time.Second
+
+// vmRecord is the registry record of a VM managed by the provider. Firecracker keeps no
+// inventory of its own, so the record is what the provider knows about the VM beyond what
+// its API reports: the configuration it was created with, and the process serving it.
+type vmRecord struct {
+    ID            string    `json:"id"`
+    Name          string    `json:"name,omitempty"`
+    Host          string    `json:"host,omitempty"`
+    BaseURL       string    `json:"base_url"`
+    APISocketPath string    `json:"api_socket_path,omitempty"`
+    Registered    time.Time `json:"registered"`
+
+    // Tags holds the tags the VM is identified by.
+    Tags map[string]string `json:"tags,omitempty"`
+
+    // Config holds the VM's configured arguments, without sensitive values.
+    Config map[string]interface{} `json:"config,omitempty"`
+
+    // PID is the Firecracker process serving the VM, when known, and Process its name.
+    PID     int    `json:"pid,omitempty"`
+    Process string `json:"process,omitempty"`
+}
+
+// guestAddress returns the guest address of a registered VM, from its guest_ip or its CNI
+// attachment, without the prefix length. It is empty when not known.
+func (r vmRecord) guestAddress() string {
+    if ip, _ := r.Config["guest_ip"].(string); ip != "" {
+        return hostFromCIDR(ip)
+    }
+    if cni, _ := r.Config["cni"].([]interface{}); len(cni) > 0 {
+        if block, ok := cni[0].(map[string]interface{}); ok {
+            if ip, _ := block["guest_ip"].(string); ip != "" {
+                return hostFromCIDR(ip)
+            }
+        }
+    }
+    return ""
+}
+
+// vmRegistryDir returns the directory of the VM registry.
+func (c *FirecrackerClient) vmRegistryDir() string {
+    if c.VMRegistryDir != "" {
+        return c.VMRegistryDir
+    }
+    return filepath.Join(c.StateDir, "vms")
+}
+
+// vmRecordPath returns the file registering a VM.
+func vmRecordPath(dir, vmID string) string {
+    return filepath.Join(dir, vmID+".json")
+}
+
+// vmRecordFromConfig returns the registry record of a VM served by client.
+func vmRecordFromConfig(d *schema.ResourceData, client *FirecrackerClient) vmRecord {
+    record := vmRecord{
+        ID:      d.Id(),
+        Name:    d.Get("name").(string),
+        Host:    d.Get("host").(string),
+        BaseURL: client.BaseURL,
+        Tags:    vmTags(d),
+        Config:  vmRecordedConfig(d),
+    }
+    if spec, ok := jailerSpecFromConfig(d.Get("jailer"), d.Id()); ok {
+        record.APISocketPath = spec.hostPath(jailerAPISocket)
+        record.Process = filepath.Base(spec.ExecFile)
+        // The jailer writes the PID of the Firecracker process it starts next to it
+        if data, err := os.ReadFile(spec.hostPath(record.Process + ".pid")); err == nil {
+            record.PID, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+        }
+    }
+    return record
+}
+
+// vmRecordedConfig returns the arguments of the VM's configuration, as recorded in the
+// registry. Computed attributes, the host and sensitive values are left out.
+func vmRecordedConfig(d *schema.ResourceData) map[string]interface{} {
+    config := map[string]interface{}{}
+    for key, s := range resourceFirecrackerVM().Schema {
+        if (!s.Optional && !s.Required) || s.Sensitive || key == "host" {
+            continue
+        }
+        config[key] = withoutSensitiveValues(s, d.Get(key))
+    }
+    return config
+}
+
+// withoutSensitiveValues returns value, an attribute with schema s, with its sensitive nested
+// attributes removed, and its sets as lists.
+func withoutSensitiveValues(s *schema.Schema, value interface{}) interface{} {
+    if set, ok := value.(*schema.Set); ok {
+        value = set.List()
+    }
+    elem, ok := s.Elem.(*schema.Resource)
+    items, isList := value.([]interface{})
+    if !ok || !isList {
+        return value
+    }
+    cleaned := make([]interface{}, 0, len(items))
+    for _, item := range items {
+        block, ok := item.(map[string]interface{})
+        if !ok {
+            cleaned = append(cleaned, item)
+            continue
+        }
+        copied := map[string]interface{}{}
+        for key, nested := range block {
+            if nestedSchema, ok := elem.Schema[key]; ok {
+                if nestedSchema.Sensitive {
+                    continue
+                }
+                nested = withoutSensitiveValues(nestedSchema, nested)
+            }
+            copied[key] = nested
+        }
+        cleaned = append(cleaned, copied)
+    }
+    return cleaned
+}
+
+// ensureVMRegistered registers a VM unless it is registered already with the same record,
+// so VMs created before the registry existed are registered on their next refresh. When
+// the record does not know the VM's process, the registered one is kept.
+func ensureVMRegistered(dir string, record vmRecord) error {
+    vmRegistryMu.Lock()
+    defer vmRegistryMu.Unlock()
+
+    path := vmRecordPath(dir, record.ID)
+    if data, err := os.ReadFile(path); err == nil {
+        var existing vmRecord
+        if json.Unmarshal(data, &existing) == nil {
+            record.Registered = existing.Registered
+            if record.PID == 0 {
+                record.PID, record.Process = existing.PID, existing.Process
+            }
+            if vmRecordsEqual(existing, record) {
+                return nil
+            }
+        }
+    }
+    if record.Registered.IsZero() {
+        record.Registered = time.Now().UTC()
+    }
+
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return fmt.Errorf("failed to create VM registry directory: %w", err)
+    }
+    data, err := json.MarshalIndent(record, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode VM %s: %w", record.ID, err)
+    }
+    // Configurations may hold paths and user data that are nobody else's business
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0o600); err != nil {
+        return fmt.Errorf("failed to register VM %s: %w", record.ID, err)
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        return fmt.Errorf("failed to register VM %s: %w", record.ID, err)
+    }
+    return nil
+}
+
+// vmRecordsEqual reports whether two records register a VM the same way. They are compared
+// in their JSON form, which is how they are stored.
+func vmRecordsEqual(a, b vmRecord) bool {
+    dataA, errA := json.Marshal(a)
+    dataB, errB := json.Marshal(b)
+    return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
+
+// lookupVMRecord returns the registry record of a VM, or nil if it is not registered.
+func lookupVMRecord(dir, vmID string) (*vmRecord, error) {
+    vmRegistryMu.Lock()
+    defer vmRegistryMu.Unlock()
+
+    data, err := os.ReadFile(vmRecordPath(dir, vmID))
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read VM registry: %w", err)
+    }
+    var record vmRecord
+    if err := json.Unmarshal(data, &record); err != nil {
+        return nil, fmt.Errorf("failed to parse VM registry entry of %s: %w", vmID, err)
+    }
+    return &record, nil
+}
+
+// unregisterVM removes a VM from the registry.
+func unregisterVM(dir, vmID string) error {
+    vmRegistryMu.Lock()
+    defer vmRegistryMu.Unlock()
+
+    if err := os.Remove(vmRecordPath(dir, vmID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("failed to unregister VM %s: %w", vmID, err)
+    }
+    return nil
+}
+
+// listRegisteredVMs returns the registered VMs, ordered by ID.
+func listRegisteredVMs(dir string) ([]vmRecord, error) {
+    vmRegistryMu.Lock()
+    defer vmRegistryMu.Unlock()
+
+    entries, err := os.ReadDir(dir)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read VM registry: %w", err)
+    }
+
+    records := []vmRecord{}
+    for _, entry := range entries {
+        if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+            continue
+        }
+        data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+        if err != nil {
+            return nil, fmt.Errorf("failed to read VM registry: %w", err)
+        }
+        var record vmRecord
+        if err := json.Unmarshal(data, &record); err != nil {
+            return nil, fmt.Errorf("failed to parse VM registry entry %s: %w", entry.Name(), err)
+        }
+        records = append(records, record)
+    }
+    sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+    return records, nil
+}
+
+// findRegisteredVMs returns the registered VMs with the given name, on the given host and
+// with all of the given tags, ordered by ID. Empty criteria match every VM.
+func findRegisteredVMs(dir, name, host string, tags map[string]interface{}) ([]vmRecord, error) {
+    records, err := listRegisteredVMs(dir)
+    if err != nil {
+        return nil, err
+    }
+    matching := []vmRecord{}
+    for _, record := range records {
+        if (name != "" && record.Name != name) || (host != "" && record.Host != host) || !hasTags(record.Tags, tags) {
+            continue
+        }
+        matching = append(matching, record)
+    }
+    return matching, nil
+}
+
+// processGone reports whether the registered Firecracker process of a VM is known to have
+// exited. It is false when the process is not known.
+func (r *vmRecord) processGone() bool {
+    return r != nil && r.PID > 0 && !vmProcessRunning(r)
+}
+
+// vmProcessRunning reports whether the registered process of a VM still runs. A process with
+// the same PID but another name is a different process that reused the PID.
+func vmProcessRunning(r *vmRecord) bool {
+    if !processAlive(r.PID) {
+        return false
+    }
+    comm, err := os.ReadFile(filepath.Join(hostProcDir, strconv.Itoa(r.PID), "comm"))
+    if err != nil {
+        return true
+    }
+    // The kernel truncates process names to 15 characters
+    name := r.Process
+    if len(name) > 15 {
+        name = name[:15]
+    }
+    return name == "" || strings.TrimSpace(string(comm)) == name
+}
+
+// stopVMProcess makes sure the registered Firecracker process of a shut down VM exits. It
+// is given time to exit on its own, then asked to terminate, and killed as a last resort.
+func stopVMProcess(ctx context.Context, r *vmRecord) error {
+    if r == nil || r.PID <= 0 {
+        return nil
+    }
+    for _, signal := range []syscall.Signal{0, syscall.SIGTERM, syscall.SIGKILL} {
+        if signal != 0 {
+            if !vmProcessRunning(r) {
+                return nil
+            }
+            tflog.Warn(ctx, "Firecracker process still running, signalling it", map[string]interface{}{
+                "id":     r.ID,
+                "pid":    r.PID,
+                "signal": signal.String(),
+            })
+            if err := syscall.Kill(r.PID, signal); err != nil && !errors.Is(err, syscall.ESRCH) {
+                return fmt.Errorf("failed to stop Firecracker process %d of VM %s: %w", r.PID, r.ID, err)
+            }
+        }
+        deadline := time.Now().Add(vmProcessExitTimeout)
+        for time.Now().Before(deadline) {
+            if !vmProcessRunning(r) {
+                return nil
+            }
+            select {
+            case <-ctx.Done():
+                return ctx.Err()
+            case <-time.After(100 * time.Millisecond):
+            }
+        }
+    }
+    if vmProcessRunning(r) {
+        return fmt.Errorf("Firecracker process %d of VM %s did not exit", r.PID, r.ID)
+    }
+    return nil
+}
+
+// GetInstanceInfo returns what Firecracker reports at GET / about its process and the state
+// of its VM, or nil when the API does not report it.
+func (c *FirecrackerClient) GetInstanceInfo(ctx context.Context) (*sdk.InstanceInfo, error) {
+    info, err := c.api().InstanceInfo(ctx)
+    var apiErr *apiError
+    switch {
+    case sdk.IsNotFound(err) || sdk.IsStatus(err, http.StatusBadRequest):
+        return nil, nil
+    case errors.As(err, &apiErr):
+        return nil, err
+    case err != nil:
+        return nil, fmt.Errorf("%w: %v", errHostUnreachable, err)
+    case info.State == "":
+        return nil, nil
+    }
+    return info, nil
+}
+
+// GetInstanceState returns the state Firecracker reports for its VM: "Not started",
+// "Running" or "Paused".
+func (c *FirecrackerClient) GetInstanceState(ctx context.Context) (string, error) {
+    info, err := c.GetInstanceInfo(ctx)
+    if err != nil && !errors.Is(err, errHostUnreachable) {
+        err = fmt.Errorf("%w: %v", errHostUnreachable, err)
+    }
+    if err != nil {
+        return "", err
+    }
+    if info == nil {
+        return "", fmt.Errorf("failed to get instance state: the API did not report it")
+    }
+    return info.State, nil
+}
+
+// restoreRecordedConfig sets the configured arguments of an imported VM from its registry
+// record. Arguments recorded by releases that had them but this one does not are ignored.
+func restoreRecordedConfig(d *schema.ResourceData, record *vmRecord) error {
+    known := resourceFirecrackerVM().Schema
+    for key, value := range record.Config {
+        if _, ok := known[key]; !ok {
+            continue
+        }
+        if err := d.Set(key, value); err != nil {
+            return fmt.Errorf("failed to restore %s from the VM registry: %w", key, err)
+        }
+    }
+    return nil
+}
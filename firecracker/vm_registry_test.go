@@ -0,0 +1,149 @@
+package firecracker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestVMRegistry_import(t *testing.T) {
+	ctx := context.Background()
+	stateDir := t.TempDir()
+
+	image := resourceFirecrackerTestImage().TestResourceData()
+	image.Set("directory", t.TempDir())
+	if diags := resourceFirecrackerTestImageCreate(ctx, image, nil); diags.HasError() {
+		t.Fatalf("Failed to create test image: %v", diags)
+	}
+	defer resourceFirecrackerTestImageDelete(ctx, image, nil)
+
+	client := configureFakeProvider(t, stateDir)
+	d := schema.TestResourceDataRaw(t, resourceFirecrackerVM().Schema, map[string]interface{}{
+		"name":              "web",
+		"kernel_image_path": image.Get("path").(string),
+		"boot_args":         "console=ttyS0 quiet",
+		"drives": []interface{}{
+			map[string]interface{}{"drive_id": "rootfs", "path_on_host": image.Get("path").(string), "is_root_device": true},
+		},
+		"machine_config": []interface{}{
+			map[string]interface{}{"vcpu_count": 2, "mem_size_mib": 256},
+		},
+		"wait_for_ssh": []interface{}{
+			map[string]interface{}{"host": "127.0.0.1", "private_key": "PRIVATE KEY"},
+		},
+	})
+	// Only the registry record matters here, so the VM is registered rather than created
+	d.SetId("vm-1")
+	if err := ensureVMRegistered(client.vmRegistryDir(), vmRecordFromConfig(d, client)); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+
+	record, err := lookupVMRecord(client.vmRegistryDir(), "vm-1")
+	if err != nil || record == nil {
+		t.Fatalf("Expected the VM to be registered, got %v, %v", record, err)
+	}
+	ssh := record.Config["wait_for_ssh"].([]interface{})[0].(map[string]interface{})
+	if _, ok := ssh["private_key"]; ok || ssh["host"] != "127.0.0.1" {
+		t.Errorf("Expected the private key to be left out of the record, got %v", ssh)
+	}
+
+	imported := resourceFirecrackerVM().TestResourceData()
+	imported.SetId("vm-1")
+	states, err := resourceFirecrackerVM().Importer.StateContext(ctx, imported, client)
+	if err != nil || len(states) != 1 {
+		t.Fatalf("Failed to import VM: %v", err)
+	}
+	if imported.Get("name") != "web" || imported.Get("boot_args") != "console=ttyS0 quiet" || imported.Get("drives.0.path_on_host") != image.Get("path") {
+		t.Errorf("Expected the configuration to be restored from the registry, got name %q, boot_args %q, drives %v", imported.Get("name"), imported.Get("boot_args"), imported.Get("drives"))
+	}
+	if imported.Get("wait_for_ssh.0.private_key") != "" {
+		t.Errorf("Expected no private key to be restored, got %q", imported.Get("wait_for_ssh.0.private_key"))
+	}
+}
+
+func TestLookupVMRecord_notRegistered(t *testing.T) {
+	record, err := lookupVMRecord(t.TempDir(), "vm-1")
+	if record != nil || err != nil {
+		t.Errorf("Expected no record, got %v, %v", record, err)
+	}
+}
+
+// startProcess starts a process that runs until stopped, reaping it once it exits.
+func startProcess(t *testing.T) *exec.Cmd {
+	t.Helper()
+
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("Cannot start a process: %v", err)
+	}
+	go cmd.Wait()
+	t.Cleanup(func() { cmd.Process.Kill() })
+	return cmd
+}
+
+func TestStopVMProcess(t *testing.T) {
+	original := vmProcessExitTimeout
+	t.Cleanup(func() { vmProcessExitTimeout = original })
+	vmProcessExitTimeout = 50 * time.Millisecond
+
+	cmd := startProcess(t)
+	record := &vmRecord{ID: "vm-1", PID: cmd.Process.Pid, Process: "sleep"}
+	if record.processGone() {
+		t.Fatal("Expected the process to be running")
+	}
+
+	// A different process reusing the PID is left alone
+	other := &vmRecord{ID: "vm-1", PID: cmd.Process.Pid, Process: "firecracker"}
+	if !other.processGone() {
+		t.Error("Expected a process with another name not to count as the VM's")
+	}
+
+	if err := stopVMProcess(context.Background(), record); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !record.processGone() {
+		t.Error("Expected the process to be stopped")
+	}
+}
+
+func TestResourceFirecrackerVMRead_processGone(t *testing.T) {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("Cannot run a process: %v", err)
+	}
+
+	client := &FirecrackerClient{
+		BaseURL:  "http://localhost:8080",
+		StateDir: t.TempDir(),
+		HTTPClient: &mockHTTPClient{DoFunc: func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		}},
+	}
+	if err := ensureVMRegistered(client.vmRegistryDir(), vmRecord{ID: "vm-1", BaseURL: client.BaseURL, PID: cmd.Process.Pid, Process: "true"}); err != nil {
+		t.Fatalf("Failed to register VM: %v", err)
+	}
+
+	d := resourceFirecrackerVM().TestResourceData()
+	d.SetId("vm-1")
+	diags := resourceFirecrackerVMRead(context.Background(), d, client)
+	if diags.HasError() || len(diags) != 1 {
+		t.Fatalf("Expected a warning, got %v", diags)
+	}
+	if d.Id() != "" {
+		t.Errorf("Expected the VM to be removed from state, got %s", d.Id())
+	}
+
+	// Without a registered process, an unreachable API says nothing about the VM
+	if err := unregisterVM(client.vmRegistryDir(), "vm-1"); err != nil {
+		t.Fatal(err)
+	}
+	d.SetId("vm-1")
+	if diags := resourceFirecrackerVMRead(context.Background(), d, client); !diags.HasError() {
+		t.Errorf("Expected an error, got %v", diags)
+	}
+}
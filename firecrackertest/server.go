@@ -0,0 +1,590 @@
+// Package firecrackertest provides a fake Firecracker API for tests. A Server answers the
+// requests of the Firecracker HTTP API the way a Firecracker process does: it keeps the VM
+// configuration in memory, rejects configuration changes the VM's state does not allow,
+// tracks the VM through being started, paused and resumed, and writes snapshots that can be
+// loaded by another Server, so moving VMs between hosts can be tested too.
+//
+// Servers listen on a local TCP port, so they are used by setting the provider's base_url,
+// or a client's base URL, to Server.URL.
+package firecrackertest
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// VM states reported by GET /.
+const (
+    StateNotStarted = "Not started"
+    StateRunning    = "Running"
+    StatePaused     = "Paused"
+)
+
+// DefaultVersion is the Firecracker release a Server reports unless SetVersion is called.
+const DefaultVersion = "1.10.1"
+
+// defaultMachineConfig is what Firecracker reports before the machine is configured.
+var defaultMachineConfig = map[string]interface{}{"vcpu_count": 1, "mem_size_mib": 128, "smt": false}
+
+// preBootComponents are the components configured with PUT before the VM is started, as
+// they appear in GET /vm/config.
+var preBootComponents = map[string]string{
+    "boot-source":    "boot-source",
+    "machine-config": "machine-config",
+    "balloon":        "balloon",
+    "vsock":          "vsock",
+    "entropy":        "entropy",
+    "logger":         "logger",
+    "metrics":        "metrics",
+    "cpu-config":     "cpu-config",
+    "mmds/config":    "mmds-config",
+}
+
+// Server is a fake Firecracker API. It is safe for concurrent use.
+type Server struct {
+    // URL is the base URL of the API, such as http://127.0.0.1:41233.
+    URL string
+
+    server   *httptest.Server
+    requests atomic.Int64
+    latency  atomic.Int64
+
+    mu                sync.Mutex
+    version           string
+    state             string
+    components        map[string]map[string]interface{}
+    drives            map[string]map[string]interface{}
+    networkInterfaces map[string]map[string]interface{}
+    mmds              interface{}
+    actions           []string
+    snapshots         []string
+}
+
+// NewServer starts a fake Firecracker API for a VM that has not been configured yet. It is
+// closed when the test finishes.
+func NewServer(tb testing.TB) *Server {
+    tb.Helper()
+
+    s := &Server{
+        version:           DefaultVersion,
+        state:             StateNotStarted,
+        components:        map[string]map[string]interface{}{},
+        drives:            map[string]map[string]interface{}{},
+        networkInterfaces: map[string]map[string]interface{}{},
+    }
+    s.server = httptest.NewServer(s)
+    s.URL = s.server.URL
+    tb.Cleanup(s.server.Close)
+    return s
+}
+
+// Close shuts the API down, as if the Firecracker process exited. Requests sent afterwards
+// fail to connect.
+func (s *Server) Close() {
+    s.server.Close()
+}
+
+// SetLatency delays every response by d, simulating the API socket of a loaded host.
+func (s *Server) SetLatency(d time.Duration) {
+    s.latency.Store(int64(d))
+}
+
+// SetVersion sets the Firecracker release reported by GET /version and GET /.
+func (s *Server) SetVersion(version string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.version = version
+}
+
+// Requests returns the number of requests the API has answered.
+func (s *Server) Requests() int64 {
+    return s.requests.Load()
+}
+
+// State returns the state of the VM: StateNotStarted, StateRunning or StatePaused.
+func (s *Server) State() string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.state
+}
+
+// Actions returns the action types accepted by PUT /actions, in the order they were sent.
+func (s *Server) Actions() []string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return append([]string(nil), s.actions...)
+}
+
+// Snapshots returns the paths of the snapshots written by PUT /snapshot/create.
+func (s *Server) Snapshots() []string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return append([]string(nil), s.snapshots...)
+}
+
+// Drive returns the configuration of a drive, or nil when it is not configured.
+func (s *Server) Drive(id string) map[string]interface{} {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return copyMap(s.drives[id])
+}
+
+// NetworkInterface returns the configuration of a network interface, or nil when it is not
+// configured.
+func (s *Server) NetworkInterface(id string) map[string]interface{} {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return copyMap(s.networkInterfaces[id])
+}
+
+// MachineConfig returns the machine configuration, Firecracker's defaults until it is
+// configured.
+func (s *Server) MachineConfig() map[string]interface{} {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return copyMap(s.machineConfig())
+}
+
+// MMDS returns the contents of the MMDS data store.
+func (s *Server) MMDS() interface{} {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.mmds
+}
+
+// Config returns the configuration of the VM as GET /vm/config reports it.
+func (s *Server) Config() map[string]interface{} {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.vmConfig()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    s.requests.Add(1)
+    if latency := time.Duration(s.latency.Load()); latency > 0 {
+        time.Sleep(latency)
+    }
+
+    var body map[string]interface{}
+    var raw interface{}
+    if r.Method == http.MethodPut || r.Method == http.MethodPatch {
+        data, err := io.ReadAll(r.Body)
+        if err != nil {
+            writeFault(w, "failed to read request body: %v", err)
+            return
+        }
+        if err := json.Unmarshal(data, &raw); err != nil {
+            writeFault(w, "invalid request body: %v", err)
+            return
+        }
+        body, _ = raw.(map[string]interface{})
+        if body == nil && !strings.HasPrefix(r.URL.Path, "/mmds") {
+            writeFault(w, "invalid request body: expected an object")
+            return
+        }
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    path := strings.Trim(r.URL.Path, "/")
+    collection, id, _ := strings.Cut(path, "/")
+    switch {
+    case r.Method == http.MethodGet && path == "":
+        writeJSON(w, map[string]interface{}{
+            "id":          "anonymous-instance",
+            "state":       s.state,
+            "vmm_version": s.version,
+            "app_name":    "Firecracker",
+        })
+    case r.Method == http.MethodGet && path == "version":
+        writeJSON(w, map[string]interface{}{"firecracker_version": s.version})
+    case r.Method == http.MethodGet && path == "vm/config":
+        writeJSON(w, s.vmConfig())
+    case r.Method == http.MethodGet && path == "machine-config":
+        writeJSON(w, s.machineConfig())
+    case r.Method == http.MethodGet && path == "balloon":
+        if s.components["balloon"] == nil {
+            writeFault(w, "Balloon device not configured.")
+            return
+        }
+        writeJSON(w, s.components["balloon"])
+    case r.Method == http.MethodGet && path == "mmds":
+        if s.mmds == nil {
+            writeJSON(w, map[string]interface{}{})
+            return
+        }
+        writeJSON(w, s.mmds)
+    case r.Method == http.MethodPut && path == "actions":
+        s.action(w, body)
+    case r.Method == http.MethodPatch && path == "vm":
+        s.setState(w, body)
+    case r.Method == http.MethodPut && path == "snapshot/create":
+        s.createSnapshot(w, body)
+    case r.Method == http.MethodPut && path == "snapshot/load":
+        s.loadSnapshot(w, body)
+    case path == "mmds" && (r.Method == http.MethodPut || r.Method == http.MethodPatch):
+        if r.Method == http.MethodPut {
+            s.mmds = raw
+        } else {
+            s.mmds = mergePatch(s.mmds, raw)
+        }
+        w.WriteHeader(http.StatusNoContent)
+    case (collection == "drives" || collection == "network-interfaces") && id != "" && r.Method == http.MethodPut:
+        s.putDevice(w, collection, id, body)
+    case (collection == "drives" || collection == "network-interfaces") && id != "" && r.Method == http.MethodPatch:
+        s.patchDevice(w, collection, id, body)
+    case preBootComponents[path] != "" && r.Method == http.MethodPut:
+        if s.state != StateNotStarted {
+            writeFault(w, "The requested operation is not supported after starting the microVM.")
+            return
+        }
+        if err := validateComponent(path, body); err != nil {
+            writeFault(w, "%v", err)
+            return
+        }
+        s.components[path] = body
+        w.WriteHeader(http.StatusNoContent)
+    case (path == "machine-config" || path == "balloon") && r.Method == http.MethodPatch:
+        s.patchComponent(w, path, body)
+    default:
+        writeFault(w, "Invalid request method and/or path: %s %s.", r.Method, r.URL.Path)
+    }
+}
+
+// machineConfig returns the configured machine, or Firecracker's defaults.
+func (s *Server) machineConfig() map[string]interface{} {
+    if config := s.components["machine-config"]; config != nil {
+        return config
+    }
+    return defaultMachineConfig
+}
+
+// vmConfig assembles the configuration of the VM the way GET /vm/config reports it.
+func (s *Server) vmConfig() map[string]interface{} {
+    config := map[string]interface{}{
+        "machine-config":     copyMap(s.machineConfig()),
+        "drives":             sortedDevices(s.drives),
+        "network-interfaces": sortedDevices(s.networkInterfaces),
+    }
+    for path, key := range preBootComponents {
+        if component := s.components[path]; component != nil && path != "machine-config" {
+            config[key] = copyMap(component)
+        }
+    }
+    return config
+}
+
+// validateComponent checks the arguments Firecracker requires in a component.
+func validateComponent(path string, body map[string]interface{}) error {
+    switch path {
+    case "boot-source":
+        if kernel, _ := body["kernel_image_path"].(string); kernel == "" {
+            return fmt.Errorf("missing field `kernel_image_path`")
+        }
+    case "machine-config":
+        vcpus, _ := body["vcpu_count"].(float64)
+        memory, _ := body["mem_size_mib"].(float64)
+        if vcpus < 1 || vcpus > 32 {
+            return fmt.Errorf("Invalid vCPU count: %v. The vCPU count must be between 1 and 32.", body["vcpu_count"])
+        }
+        if memory < 1 {
+            return fmt.Errorf("The memory size (MiB) is invalid.")
+        }
+    }
+    return nil
+}
+
+// patchComponent updates a component in place. The machine can only be changed before the
+// VM is started, the balloon only afterwards.
+func (s *Server) patchComponent(w http.ResponseWriter, path string, body map[string]interface{}) {
+    if path == "machine-config" && s.state != StateNotStarted {
+        writeFault(w, "The requested operation is not supported after starting the microVM.")
+        return
+    }
+    if path == "balloon" {
+        if s.state == StateNotStarted {
+            writeFault(w, "The requested operation is not supported before starting the microVM.")
+            return
+        }
+        if s.components["balloon"] == nil {
+            writeFault(w, "Balloon device not configured.")
+            return
+        }
+    }
+    updated := copyMap(s.machineConfig())
+    if path == "balloon" {
+        updated = copyMap(s.components["balloon"])
+    }
+    for key, value := range body {
+        updated[key] = value
+    }
+    if err := validateComponent(path, updated); err != nil {
+        writeFault(w, "%v", err)
+        return
+    }
+    s.components[path] = updated
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// putDevice configures a drive or network interface before the VM is started. Only one
+// drive may be the root device.
+func (s *Server) putDevice(w http.ResponseWriter, collection, id string, body map[string]interface{}) {
+    if s.state != StateNotStarted {
+        writeFault(w, "The requested operation is not supported after starting the microVM.")
+        return
+    }
+
+    devices, idField, pathField := s.drives, "drive_id", "path_on_host"
+    if collection == "network-interfaces" {
+        devices, idField, pathField = s.networkInterfaces, "iface_id", "host_dev_name"
+    }
+    if body[idField] != id {
+        writeFault(w, "The id from the path [%s] does not match the id from the body [%v]!", id, body[idField])
+        return
+    }
+    if value, _ := body[pathField].(string); value == "" {
+        writeFault(w, "missing field `%s`", pathField)
+        return
+    }
+    if root, _ := body["is_root_device"].(bool); root {
+        for other, drive := range devices {
+            if isRoot, _ := drive["is_root_device"].(bool); isRoot && other != id {
+                writeFault(w, "A root block device already exists!")
+                return
+            }
+        }
+    }
+    devices[id] = body
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// patchDevice updates a drive's image or rate limiters, or a network interface's rate
+// limiters, of a started VM.
+func (s *Server) patchDevice(w http.ResponseWriter, collection, id string, body map[string]interface{}) {
+    if s.state == StateNotStarted {
+        writeFault(w, "The requested operation is not supported before starting the microVM.")
+        return
+    }
+
+    devices, idField := s.drives, "drive_id"
+    if collection == "network-interfaces" {
+        devices, idField = s.networkInterfaces, "iface_id"
+    }
+    device := devices[id]
+    if device == nil {
+        writeFault(w, "Invalid device ID: %s", id)
+        return
+    }
+    if body[idField] != nil && body[idField] != id {
+        writeFault(w, "The id from the path [%s] does not match the id from the body [%v]!", id, body[idField])
+        return
+    }
+    updated := copyMap(device)
+    for key, value := range body {
+        updated[key] = value
+    }
+    devices[id] = updated
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// action performs a PUT /actions request.
+func (s *Server) action(w http.ResponseWriter, body map[string]interface{}) {
+    actionType, _ := body["action_type"].(string)
+    switch actionType {
+    case "InstanceStart":
+        if s.state != StateNotStarted {
+            writeFault(w, "The requested operation is not supported after starting the microVM.")
+            return
+        }
+        if s.components["boot-source"] == nil {
+            writeFault(w, "Cannot start microvm without kernel configuration.")
+            return
+        }
+        s.state = StateRunning
+    case "SendCtrlAltDel", "FlushMetrics":
+        if s.state == StateNotStarted {
+            writeFault(w, "The requested operation is not supported before starting the microVM.")
+            return
+        }
+    default:
+        writeFault(w, "unknown variant `%s`, expected one of `FlushMetrics`, `InstanceStart`, `SendCtrlAltDel`", actionType)
+        return
+    }
+    s.actions = append(s.actions, actionType)
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// setState pauses or resumes a started VM.
+func (s *Server) setState(w http.ResponseWriter, body map[string]interface{}) {
+    if s.state == StateNotStarted {
+        writeFault(w, "The requested operation is not supported before starting the microVM.")
+        return
+    }
+    switch body["state"] {
+    case "Paused":
+        s.state = StatePaused
+    case "Resumed":
+        s.state = StateRunning
+    default:
+        writeFault(w, "unknown variant `%v`, expected `Paused` or `Resumed`", body["state"])
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// snapshot is what a Server writes to a snapshot file: the configuration of the VM, so
+// another Server can load it.
+type snapshot struct {
+    Config map[string]interface{} `json:"config"`
+    MMDS   interface{}            `json:"mmds,omitempty"`
+}
+
+// createSnapshot writes the configuration of a paused VM to snapshot_path, and an empty
+// memory file to mem_file_path.
+func (s *Server) createSnapshot(w http.ResponseWriter, body map[string]interface{}) {
+    if s.state != StatePaused {
+        writeFault(w, "Cannot create a snapshot of a microVM that is not paused.")
+        return
+    }
+    snapshotPath, _ := body["snapshot_path"].(string)
+    memFilePath, _ := body["mem_file_path"].(string)
+    if snapshotPath == "" || memFilePath == "" {
+        writeFault(w, "missing field `snapshot_path` or `mem_file_path`")
+        return
+    }
+
+    data, err := json.Marshal(snapshot{Config: s.vmConfig(), MMDS: s.mmds})
+    if err != nil {
+        writeFault(w, "Cannot save the microVM state: %v", err)
+        return
+    }
+    if err := os.WriteFile(snapshotPath, data, 0o644); err != nil {
+        writeFault(w, "Cannot save the microVM state: %v", err)
+        return
+    }
+    if err := os.WriteFile(memFilePath, nil, 0o644); err != nil {
+        writeFault(w, "Cannot dump memory: %v", err)
+        return
+    }
+    s.snapshots = append(s.snapshots, snapshotPath)
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// loadSnapshot restores a VM from a snapshot written by a Server. The VM is paused after
+// loading unless resume_vm is set.
+func (s *Server) loadSnapshot(w http.ResponseWriter, body map[string]interface{}) {
+    if s.state != StateNotStarted || len(s.components) > 0 || len(s.drives) > 0 || len(s.networkInterfaces) > 0 {
+        writeFault(w, "Loading a microVM snapshot not allowed after configuring boot-specific resources.")
+        return
+    }
+    snapshotPath, _ := body["snapshot_path"].(string)
+    data, err := os.ReadFile(snapshotPath)
+    if err != nil {
+        writeFault(w, "Load microVM snapshot error: Cannot open snapshot file: %v", err)
+        return
+    }
+    var loaded snapshot
+    if err := json.Unmarshal(data, &loaded); err != nil || loaded.Config == nil {
+        writeFault(w, "Load microVM snapshot error: Cannot deserialize the microVM state.")
+        return
+    }
+
+    for path, key := range preBootComponents {
+        if component, ok := loaded.Config[key].(map[string]interface{}); ok {
+            s.components[path] = component
+        }
+    }
+    drives, _ := loaded.Config["drives"].([]interface{})
+    for _, raw := range drives {
+        if drive, ok := raw.(map[string]interface{}); ok {
+            s.drives[fmt.Sprint(drive["drive_id"])] = drive
+        }
+    }
+    ifaces, _ := loaded.Config["network-interfaces"].([]interface{})
+    for _, raw := range ifaces {
+        if iface, ok := raw.(map[string]interface{}); ok {
+            s.networkInterfaces[fmt.Sprint(iface["iface_id"])] = iface
+        }
+    }
+    s.mmds = loaded.MMDS
+
+    s.state = StatePaused
+    if resume, _ := body["resume_vm"].(bool); resume {
+        s.state = StateRunning
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// mergePatch applies a JSON merge patch (RFC 7396) to target, as Firecracker does for
+// PATCH /mmds: objects are merged and null removes a key.
+func mergePatch(target, patch interface{}) interface{} {
+    patchMap, ok := patch.(map[string]interface{})
+    if !ok {
+        return patch
+    }
+    targetMap, ok := target.(map[string]interface{})
+    if !ok {
+        targetMap = map[string]interface{}{}
+    }
+    merged := copyMap(targetMap)
+    for key, value := range patchMap {
+        if value == nil {
+            delete(merged, key)
+            continue
+        }
+        merged[key] = mergePatch(merged[key], value)
+    }
+    return merged
+}
+
+// sortedDevices lists drives or network interfaces ordered by ID.
+func sortedDevices(devices map[string]map[string]interface{}) []interface{} {
+    ids := make([]string, 0, len(devices))
+    for id := range devices {
+        ids = append(ids, id)
+    }
+    sort.Strings(ids)
+
+    list := make([]interface{}, 0, len(ids))
+    for _, id := range ids {
+        list = append(list, copyMap(devices[id]))
+    }
+    return list
+}
+
+// copyMap returns a shallow copy of m, or nil when m is nil.
+func copyMap(m map[string]interface{}) map[string]interface{} {
+    if m == nil {
+        return nil
+    }
+    copied := make(map[string]interface{}, len(m))
+    for key, value := range m {
+        copied[key] = value
+    }
+    return copied
+}
+
+// writeJSON answers a request with a JSON body.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(value)
+}
+
+// writeFault answers a request the way Firecracker rejects one: 400 Bad Request with a
+// fault message.
+func writeFault(w http.ResponseWriter, format string, args ...interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusBadRequest)
+    json.NewEncoder(w).Encode(map[string]string{"fault_message": fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,155 @@
+package firecrackertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// send sends a request with a JSON body to the server and returns the response status and
+// body.
+func send(t *testing.T, s *Server, method, path, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, s.URL+path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+// mustSend sends a request that must succeed.
+func mustSend(t *testing.T, s *Server, method, path, body string) string {
+	t.Helper()
+
+	status, data := send(t, s, method, path, body)
+	if status >= 300 {
+		t.Fatalf("%s %s: expected success, got %d %s", method, path, status, data)
+	}
+	return data
+}
+
+// boot configures and starts a VM with a root drive.
+func boot(t *testing.T, s *Server) {
+	t.Helper()
+
+	mustSend(t, s, http.MethodPut, "/boot-source", `{"kernel_image_path":"/images/vmlinux"}`)
+	mustSend(t, s, http.MethodPut, "/machine-config", `{"vcpu_count":2,"mem_size_mib":512}`)
+	mustSend(t, s, http.MethodPut, "/drives/rootfs", `{"drive_id":"rootfs","path_on_host":"/images/rootfs.ext4","is_root_device":true,"is_read_only":false}`)
+	mustSend(t, s, http.MethodPut, "/network-interfaces/eth0", `{"iface_id":"eth0","host_dev_name":"tap0"}`)
+	mustSend(t, s, http.MethodPut, "/actions", `{"action_type":"InstanceStart"}`)
+}
+
+func TestServer_lifecycle(t *testing.T) {
+	s := NewServer(t)
+
+	var info map[string]interface{}
+	json.Unmarshal([]byte(mustSend(t, s, http.MethodGet, "/", "")), &info)
+	if info["state"] != StateNotStarted || info["vmm_version"] != DefaultVersion {
+		t.Errorf("Expected a VM that has not started, got %v", info)
+	}
+	if got := s.MachineConfig()["mem_size_mib"]; got != 128 {
+		t.Errorf("Expected Firecracker's default memory size, got %v", got)
+	}
+
+	boot(t, s)
+	if s.State() != StateRunning {
+		t.Errorf("Expected the VM to be running, got %s", s.State())
+	}
+
+	var config map[string]interface{}
+	json.Unmarshal([]byte(mustSend(t, s, http.MethodGet, "/vm/config", "")), &config)
+	drives := config["drives"].([]interface{})
+	if len(drives) != 1 || config["boot-source"] == nil {
+		t.Errorf("Expected the configured VM, got %v", config)
+	}
+
+	// Drives can only have their image swapped once the VM runs
+	mustSend(t, s, http.MethodPatch, "/drives/rootfs", `{"drive_id":"rootfs","path_on_host":"/images/rootfs-v2.ext4"}`)
+	if got := s.Drive("rootfs")["path_on_host"]; got != "/images/rootfs-v2.ext4" {
+		t.Errorf("Expected the swapped image, got %v", got)
+	}
+
+	mustSend(t, s, http.MethodPatch, "/vm", `{"state":"Paused"}`)
+	if s.State() != StatePaused {
+		t.Errorf("Expected the VM to be paused, got %s", s.State())
+	}
+	mustSend(t, s, http.MethodPatch, "/vm", `{"state":"Resumed"}`)
+	mustSend(t, s, http.MethodPut, "/actions", `{"action_type":"SendCtrlAltDel"}`)
+	if got := strings.Join(s.Actions(), ","); got != "InstanceStart,SendCtrlAltDel" {
+		t.Errorf("Unexpected actions %s", got)
+	}
+}
+
+func TestServer_rejectsInvalidRequests(t *testing.T) {
+	s := NewServer(t)
+
+	for _, tc := range []struct {
+		name, method, path, body string
+	}{
+		{"start without kernel", http.MethodPut, "/actions", `{"action_type":"InstanceStart"}`},
+		{"invalid vCPU count", http.MethodPut, "/machine-config", `{"vcpu_count":0,"mem_size_mib":128}`},
+		{"mismatched drive ID", http.MethodPut, "/drives/rootfs", `{"drive_id":"data","path_on_host":"/images/data.ext4"}`},
+		{"patch before start", http.MethodPatch, "/drives/rootfs", `{"drive_id":"rootfs","path_on_host":"/images/data.ext4"}`},
+		{"pause before start", http.MethodPatch, "/vm", `{"state":"Paused"}`},
+		{"unknown path", http.MethodPut, "/hotplug", `{}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status, body := send(t, s, tc.method, tc.path, tc.body)
+			if status != http.StatusBadRequest || !strings.Contains(body, "fault_message") {
+				t.Errorf("Expected a fault, got %d %s", status, body)
+			}
+		})
+	}
+
+	boot(t, s)
+	if status, _ := send(t, s, http.MethodPut, "/drives/data", `{"drive_id":"data","path_on_host":"/images/data.ext4","is_root_device":false}`); status != http.StatusBadRequest {
+		t.Errorf("Expected adding a drive to a running VM to fail, got %d", status)
+	}
+	if status, _ := send(t, s, http.MethodPut, "/snapshot/create", `{"snapshot_path":"/tmp/vmstate","mem_file_path":"/tmp/memory"}`); status != http.StatusBadRequest {
+		t.Errorf("Expected snapshotting a running VM to fail, got %d", status)
+	}
+}
+
+func TestServer_snapshot(t *testing.T) {
+	source := NewServer(t)
+	boot(t, source)
+	mustSend(t, source, http.MethodPut, "/mmds", `{"app":{"flags":{"beta":true}}}`)
+	mustSend(t, source, http.MethodPatch, "/mmds", `{"app":{"flags":{"beta":null}}}`)
+
+	dir := t.TempDir()
+	snapshot := `{"snapshot_path":"` + filepath.Join(dir, "vmstate") + `","mem_file_path":"` + filepath.Join(dir, "memory") + `"}`
+	mustSend(t, source, http.MethodPatch, "/vm", `{"state":"Paused"}`)
+	mustSend(t, source, http.MethodPut, "/snapshot/create", snapshot)
+	if got := source.Snapshots(); len(got) != 1 {
+		t.Errorf("Expected one snapshot, got %v", got)
+	}
+
+	target := NewServer(t)
+	mustSend(t, target, http.MethodPut, "/snapshot/load", `{"snapshot_path":"`+filepath.Join(dir, "vmstate")+`","resume_vm":false}`)
+	if target.State() != StatePaused {
+		t.Errorf("Expected the restored VM to be paused, got %s", target.State())
+	}
+	if got := target.Drive("rootfs")["path_on_host"]; got != "/images/rootfs.ext4" {
+		t.Errorf("Expected the restored drive, got %v", got)
+	}
+	if got, _ := json.Marshal(target.MMDS()); string(got) != `{"app":{"flags":{}}}` {
+		t.Errorf("Expected the restored MMDS data store, got %s", got)
+	}
+
+	// A configured VM cannot load a snapshot
+	if status, _ := send(t, source, http.MethodPut, "/snapshot/load", `{"snapshot_path":"`+filepath.Join(dir, "vmstate")+`"}`); status != http.StatusBadRequest {
+		t.Errorf("Expected loading a snapshot into a configured VM to fail, got %d", status)
+	}
+}